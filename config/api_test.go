@@ -0,0 +1,69 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	v3_0 "github.com/coreos/ignition/v2/config/v3_0"
+	types_3_0 "github.com/coreos/ignition/v2/config/v3_0/types"
+	types_exp "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/coreos/vcontext/report"
+	"github.com/stretchr/testify/assert"
+)
+
+// The signatures of the supported API. Changing any of them breaks
+// compatibility and requires a new major version.
+var (
+	_ func([]byte) (types_exp.Config, report.Report, error)       = Parse
+	_ func([]byte) (types_exp.Config, report.Report, error)       = ParseYAML
+	_ func(types_exp.Config) report.Report                        = Validate
+	_ func(types_exp.Config, types_exp.Config) types_exp.Config   = Merge
+	_ func([]byte, semver.Version) ([]byte, report.Report, error) = Translate
+	_ func(semver.Version) ([]byte, error)                        = Schema
+	_ func([]byte) (types_3_0.Config, report.Report, error)       = v3_0.Parse
+	_ func(types_3_0.Config, types_3_0.Config) types_3_0.Config   = v3_0.Merge
+)
+
+func TestValidate(t *testing.T) {
+	cfg := types_exp.Config{
+		Ignition: types_exp.Ignition{Version: types_exp.MaxVersion.String()},
+		Storage: types_exp.Storage{
+			Files: []types_exp.File{{Node: types_exp.Node{Path: "etc/motd"}}},
+		},
+	}
+	assert.True(t, Validate(cfg).IsFatal(), "relative path wasn't rejected")
+
+	cfg.Storage.Files[0].Path = "/etc/motd"
+	assert.False(t, Validate(cfg).IsFatal(), "valid config was rejected")
+}
+
+func TestMerge(t *testing.T) {
+	parent := types_exp.Config{
+		Storage: types_exp.Storage{
+			Files: []types_exp.File{{Node: types_exp.Node{Path: "/etc/motd"}}},
+		},
+	}
+	child := types_exp.Config{
+		Storage: types_exp.Storage{
+			Files: []types_exp.File{{Node: types_exp.Node{Path: "/etc/motd", Overwrite: util.BoolToPtr(true)}}},
+		},
+	}
+	out := Merge(parent, child)
+	assert.Equal(t, child.Storage.Files, out.Storage.Files)
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compress shrinks configs by compressing the large inline contents
+// of their files, so that they fit within the user-data limits of platforms.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+const (
+	// Gzip compresses contents with gzip, and sets their compression.
+	Gzip = "gzip"
+	// Zstd compresses contents with the zstd command, and marks their data
+	// URLs with a zstd parameter. Ignition needs the zstd command too to
+	// decompress them.
+	Zstd = "zstd"
+
+	// DefaultThreshold is the size in bytes below which contents are left
+	// alone if Options doesn't set one.
+	DefaultThreshold = 1024
+)
+
+var ErrMethodUnknown = errors.New("compression method must be gzip or zstd")
+
+// zstdCmd is the command contents are compressed with by Zstd.
+const zstdCmd = "zstd"
+
+// Options says how contents are compressed.
+type Options struct {
+	// Method is Gzip or Zstd.
+	Method string
+	// Threshold is the decoded size in bytes below which contents are
+	// left alone.
+	Threshold int
+}
+
+// Config returns cfg with the data URL contents and appended contents of
+// its files compressed, where they're at least opts.Threshold bytes and
+// compressing them makes the URL shorter. Contents which are already
+// compressed or are encrypted or patches are left alone. Verification
+// hashes stay valid, since they apply to the decompressed contents. cfg
+// isn't modified.
+func Config(cfg types.Config, opts Options) (types.Config, error) {
+	if opts.Method != Gzip && opts.Method != Zstd {
+		return types.Config{}, ErrMethodUnknown
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = DefaultThreshold
+	}
+
+	files := make([]types.File, len(cfg.Storage.Files))
+	for i, f := range cfg.Storage.Files {
+		contents, err := compress(f.Contents, opts)
+		if err != nil {
+			return types.Config{}, fmt.Errorf("compressing the contents of %s: %v", f.Path, err)
+		}
+		f.Contents = contents
+		if f.Append != nil {
+			f.Append = make([]types.FileContents, len(f.Append))
+		}
+		for j, a := range cfg.Storage.Files[i].Append {
+			if f.Append[j], err = compress(a, opts); err != nil {
+				return types.Config{}, fmt.Errorf("compressing the appended contents of %s: %v", f.Path, err)
+			}
+		}
+		files[i] = f
+	}
+	if cfg.Storage.Files != nil {
+		cfg.Storage.Files = files
+	}
+	return cfg, nil
+}
+
+func compress(c types.FileContents, opts Options) (types.FileContents, error) {
+	if c.Source == nil || !strings.HasPrefix(*c.Source, "data:") || !util.NilOrEmpty(c.Compression) ||
+		c.Encryption.IsSet() || c.PatchFrom != nil {
+		return c, nil
+	}
+	if _, zstd := util.SplitZstdDataURL(*c.Source); zstd {
+		return c, nil
+	}
+	url, err := dataurl.DecodeString(*c.Source)
+	if err != nil {
+		return types.FileContents{}, err
+	}
+	if len(url.Data) < opts.Threshold {
+		return c, nil
+	}
+
+	var compressed []byte
+	prefix := "data:;base64,"
+	switch opts.Method {
+	case Gzip:
+		compressed, err = gzipData(url.Data)
+	case Zstd:
+		compressed, err = zstdData(url.Data)
+		prefix = "data:;zstd;base64,"
+	}
+	if err != nil {
+		return types.FileContents{}, err
+	}
+	source := prefix + base64.StdEncoding.EncodeToString(compressed)
+	if len(source) >= len(*c.Source) {
+		return c, nil
+	}
+	c.Source = &source
+	if opts.Method == Gzip {
+		c.Compression = util.StrToPtr(Gzip)
+	}
+	return c, nil
+}
+
+func gzipData(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func zstdData(data []byte) ([]byte, error) {
+	cmd := exec.Command(zstdCmd, "-19", "--quiet", "--stdout")
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %v: %s", zstdCmd, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
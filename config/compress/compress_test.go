@@ -0,0 +1,133 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+func file(path string, contents types.FileContents, appended ...types.FileContents) types.File {
+	return types.File{
+		Node: types.Node{Path: path},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: contents,
+			Append:   appended,
+		},
+	}
+}
+
+func TestConfig(t *testing.T) {
+	large := strings.Repeat("hello world\n", 200)
+	inline := types.FileContents{Source: util.StrToPtr(dataurl.EncodeBytes([]byte(large)))}
+	small := types.FileContents{Source: util.StrToPtr("data:,hello")}
+	remote := types.FileContents{Source: util.StrToPtr("https://example.com/motd")}
+	compressed := types.FileContents{Source: inline.Source, Compression: util.StrToPtr("gzip")}
+	encrypted := types.FileContents{Source: inline.Source, Encryption: types.Encryption{Key: util.StrToPtr("vault:transit/keys/node#k")}}
+	config := func() types.Config {
+		return types.Config{
+			Storage: types.Storage{Files: []types.File{
+				file("/etc/large", inline, inline, small),
+				file("/etc/small", small),
+				file("/etc/remote", remote),
+				file("/etc/compressed", compressed),
+				file("/etc/encrypted", encrypted),
+			}},
+		}
+	}
+	cfg, original := config(), config()
+
+	out, err := Config(cfg, Options{Method: Gzip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, original) {
+		t.Error("the config was modified")
+	}
+	files := out.Storage.Files
+	for i, c := range []types.FileContents{files[0].Contents, files[0].Append[0]} {
+		if c.Compression == nil || *c.Compression != "gzip" {
+			t.Errorf("#%d: compression not set", i)
+			continue
+		}
+		if len(*c.Source) >= len(*inline.Source) {
+			t.Errorf("#%d: source wasn't shortened", i)
+		}
+		url, err := dataurl.DecodeString(*c.Source)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		r, err := gzip.NewReader(bytes.NewReader(url.Data))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if data, err := ioutil.ReadAll(r); err != nil || string(data) != large {
+			t.Errorf("#%d: contents don't decompress to the original: %v", i, err)
+		}
+	}
+	unchanged := []types.FileContents{files[0].Append[1], files[1].Contents, files[2].Contents, files[3].Contents, files[4].Contents}
+	for i, c := range []types.FileContents{small, small, remote, compressed, encrypted} {
+		if !reflect.DeepEqual(unchanged[i], c) {
+			t.Errorf("#%d: contents were changed: %+v", i, unchanged[i])
+		}
+	}
+
+	if _, err := Config(cfg, Options{Method: "xz"}); err != ErrMethodUnknown {
+		t.Errorf("bad error: want %v, got %v", ErrMethodUnknown, err)
+	}
+}
+
+func TestConfigZstd(t *testing.T) {
+	if _, err := exec.LookPath(zstdCmd); err != nil {
+		t.Skipf("%s not found", zstdCmd)
+	}
+	large := strings.Repeat("hello world\n", 200)
+	cfg := types.Config{
+		Storage: types.Storage{Files: []types.File{
+			file("/etc/large", types.FileContents{Source: util.StrToPtr(dataurl.EncodeBytes([]byte(large)))}),
+		}},
+	}
+	out, err := Config(cfg, Options{Method: Zstd, Threshold: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := out.Storage.Files[0].Contents
+	if c.Compression != nil {
+		t.Errorf("compression set for zstd: %q", *c.Compression)
+	}
+	plain, zstd := util.SplitZstdDataURL(*c.Source)
+	if !zstd {
+		t.Fatalf("source isn't a zstd data URL: %q", *c.Source)
+	}
+	url, err := dataurl.DecodeString(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(zstdCmd, "--decompress", "--stdout")
+	cmd.Stdin = bytes.NewReader(url.Data)
+	if data, err := cmd.Output(); err != nil || string(data) != large {
+		t.Errorf("contents don't decompress to the original: %v", err)
+	}
+}
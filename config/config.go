@@ -12,16 +12,35 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package config is the supported Go API for parsing, validating, merging,
+// and translating Ignition configs, for tools which generate or serve
+// configs.
+//
+// The exported identifiers of this package, and of the packages for stable
+// spec versions (e.g. config/v3_0 and config/v3_0/types), follow semantic
+// versioning: they are only removed or changed incompatibly in a new major
+// version of the module. The types of an experimental spec (e.g.
+// config/v3_1_experimental/types), which Parse, Validate, and Merge operate
+// on, may gain or lose fields in any release along with the experimental
+// spec. Tools which need stable types should translate configs to a stable
+// version with Translate and parse them with that version's package. All
+// other packages are internal to Ignition.
 package config
 
 import (
+	"encoding/json"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v2_3"
+	types_2_3 "github.com/coreos/ignition/v2/config/v2_3/types"
 	"github.com/coreos/ignition/v2/config/v3_0"
+	trans_3_0 "github.com/coreos/ignition/v2/config/v3_0/translate"
 	types_3_0 "github.com/coreos/ignition/v2/config/v3_0/types"
 	"github.com/coreos/ignition/v2/config/v3_1_experimental"
 	trans_exp "github.com/coreos/ignition/v2/config/v3_1_experimental/translate"
 	types_exp "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/config/validate"
 
 	"github.com/coreos/go-semver/semver"
 	"github.com/coreos/vcontext/report"
@@ -36,34 +55,170 @@ type versionStub struct {
 // Parse parses a config of any supported version and returns the equivalent config at the latest
 // supported version.
 func Parse(raw []byte) (types_exp.Config, report.Report, error) {
+	version, rpt, err := parseVersion(raw)
+	if err != nil {
+		return types_exp.Config{}, rpt, err
+	}
+
+	switch version {
+	case types_exp.MaxVersion:
+		return v3_1_experimental.Parse(raw)
+	case types_3_0.MaxVersion:
+		return from3_0(v3_0.Parse(raw))
+	case types_2_3.MaxVersion, types_2_3.MinVersion:
+		return from2_3(v2_3.Parse(raw))
+	default:
+		return types_exp.Config{}, report.Report{}, errors.ErrUnknownVersion
+	}
+}
+
+// Validate validates a config which wasn't produced by Parse, e.g. one
+// built in Go. The report has no line or column numbers.
+func Validate(cfg types_exp.Config) report.Report {
+	return validate.ValidateWithContext(cfg, nil)
+}
+
+// Merge merges child into parent, following the same rules as Ignition uses
+// for configs referenced by ignition.config.merge. Both configs should be
+// valid, but the result may not be.
+func Merge(parent, child types_exp.Config) types_exp.Config {
+	return v3_1_experimental.Merge(parent, child)
+}
+
+// Schema returns the JSON Schema describing configs of the given version,
+// so that configs can be checked by tools other than Ignition.
+func Schema(version semver.Version) ([]byte, error) {
+	switch version {
+	case types_exp.MaxVersion:
+		return []byte(types_exp.JSONSchema), nil
+	case types_3_0.MaxVersion:
+		return []byte(types_3_0.JSONSchema), nil
+	default:
+		return nil, errors.ErrUnknownVersion
+	}
+}
+
+// ParseYAML is like Parse, but also accepts configs written in YAML. YAML
+// configs are converted to JSON before they are parsed, so their report
+// doesn't include line and column numbers.
+func ParseYAML(raw []byte) (types_exp.Config, report.Report, error) {
 	if len(raw) == 0 {
 		return types_exp.Config{}, report.Report{}, errors.ErrEmpty
 	}
+	if json.Valid(raw) {
+		return Parse(raw)
+	}
 
-	stub := versionStub{}
-	rpt, err := util.HandleParseErrors(raw, &stub)
+	converted, rpt, err := util.YAMLToJSON(raw)
 	if err != nil {
 		return types_exp.Config{}, rpt, err
 	}
+	cfg, r, err := Parse(converted)
+	rpt.Merge(util.StripMarkers(r))
+	return cfg, rpt, err
+}
 
-	version, err := semver.NewVersion(stub.Ignition.Version)
+func from3_0(cfg types_3_0.Config, r report.Report, err error) (types_exp.Config, report.Report, error) {
 	if err != nil {
-		return types_exp.Config{}, report.Report{}, errors.ErrInvalidVersion
+		return types_exp.Config{}, r, err
 	}
+	return trans_exp.Translate(cfg), r, nil
+}
 
-	switch *version {
+// from2_3 translates a legacy config to 3.0 and validates the result. The
+// report includes anything which couldn't be translated faithfully.
+func from2_3(cfg types_2_3.Config, r report.Report, err error) (types_exp.Config, report.Report, error) {
+	translated, r, err := from2_3To3_0(cfg, r, err)
+	if err != nil {
+		return types_exp.Config{}, r, err
+	}
+	return trans_exp.Translate(translated), r, nil
+}
+
+func from2_3To3_0(cfg types_2_3.Config, r report.Report, err error) (types_3_0.Config, report.Report, error) {
+	if err != nil {
+		return types_3_0.Config{}, r, err
+	}
+	translated, rpt := trans_3_0.Translate(cfg)
+	r.Merge(rpt)
+	r.Merge(validate.ValidateWithContext(translated, nil))
+	if r.IsFatal() {
+		return types_3_0.Config{}, r, errors.ErrInvalid
+	}
+	return translated, r, nil
+}
+
+// fromExpTo3_0 downgrades a 3.1.0-experimental config to 3.0 and validates
+// the result. The report lists every field which would be dropped.
+func fromExpTo3_0(cfg types_exp.Config, r report.Report, err error) (types_3_0.Config, report.Report, error) {
+	if err != nil {
+		return types_3_0.Config{}, r, err
+	}
+	translated, rpt := trans_3_0.Downgrade(cfg)
+	r.Merge(rpt)
+	r.Merge(validate.ValidateWithContext(translated, nil))
+	if r.IsFatal() {
+		return types_3_0.Config{}, r, errors.ErrInvalid
+	}
+	return translated, r, nil
+}
+
+// Translate parses a config of any supported version and returns the
+// equivalent config at version to, serialized as JSON. Fields which can't be
+// represented at version to are reported as errors and the translation fails.
+func Translate(raw []byte, to semver.Version) ([]byte, report.Report, error) {
+	var cfg interface{}
+	var rpt report.Report
+	var err error
+	switch to {
 	case types_exp.MaxVersion:
-		return v3_1_experimental.Parse(raw)
+		cfg, rpt, err = Parse(raw)
 	case types_3_0.MaxVersion:
-		return from3_0(v3_0.Parse(raw))
+		cfg, rpt, err = parse3_0(raw)
 	default:
-		return types_exp.Config{}, report.Report{}, errors.ErrUnknownVersion
+		return nil, report.Report{}, errors.ErrTranslateUnsupportedVersion
 	}
+	if err != nil {
+		return nil, rpt, err
+	}
+	out, err := json.Marshal(cfg)
+	return out, rpt, err
 }
 
-func from3_0(cfg types_3_0.Config, r report.Report, err error) (types_exp.Config, report.Report, error) {
+// parse3_0 parses a config of any supported version and returns the
+// equivalent 3.0.0 config.
+func parse3_0(raw []byte) (types_3_0.Config, report.Report, error) {
+	version, rpt, err := parseVersion(raw)
 	if err != nil {
-		return types_exp.Config{}, r, err
+		return types_3_0.Config{}, rpt, err
 	}
-	return trans_exp.Translate(cfg), r, nil
+
+	switch version {
+	case types_exp.MaxVersion:
+		return fromExpTo3_0(v3_1_experimental.Parse(raw))
+	case types_3_0.MaxVersion:
+		return v3_0.Parse(raw)
+	case types_2_3.MaxVersion, types_2_3.MinVersion:
+		return from2_3To3_0(v2_3.Parse(raw))
+	default:
+		return types_3_0.Config{}, report.Report{}, errors.ErrUnknownVersion
+	}
+}
+
+func parseVersion(raw []byte) (semver.Version, report.Report, error) {
+	if len(raw) == 0 {
+		return semver.Version{}, report.Report{}, errors.ErrEmpty
+	}
+
+	stub := versionStub{}
+	rpt, err := util.HandleParseErrors(raw, &stub)
+	if err != nil {
+		return semver.Version{}, rpt, err
+	}
+
+	version, err := semver.NewVersion(stub.Ignition.Version)
+	if err != nil {
+		return semver.Version{}, report.Report{}, errors.ErrInvalidVersion
+	}
+	return *version, report.Report{}, nil
 }
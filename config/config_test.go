@@ -15,12 +15,19 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
 	v3_0 "github.com/coreos/ignition/v2/config/v3_0/types"
+	types_exp "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/coreos/go-semver/semver"
 )
 
 func testConfigType(t reflect.Type) error {
@@ -92,3 +99,146 @@ func TestConfigStructure(t *testing.T) {
 		}
 	}
 }
+
+func TestParseV2(t *testing.T) {
+	tests := []struct {
+		in  string
+		err error
+	}{
+		{
+			in:  `{"ignition": {"version": "2.2.0"}, "storage": {"files": [{"filesystem": "root", "path": "/etc/motd", "contents": {"source": "data:,hello"}}]}}`,
+			err: nil,
+		},
+		{
+			in:  `{"ignition": {"version": "2.3.0"}, "systemd": {"units": [{"name": "foo.service", "enable": true, "contents": "[Install]\nWantedBy=multi-user.target"}]}}`,
+			err: nil,
+		},
+		{
+			in:  `{"ignition": {"version": "2.3.0"}, "storage": {"files": [{"filesystem": "oem", "path": "/grub.cfg"}]}}`,
+			err: errors.ErrInvalid,
+		},
+		{
+			in:  `{"ignition": {"version": "2.1.0"}}`,
+			err: errors.ErrUnknownVersion,
+		},
+	}
+
+	for i, test := range tests {
+		cfg, r, err := Parse([]byte(test.in))
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v, report: %v", i, test.err, err, r)
+		}
+		if err == nil && cfg.Ignition.Version != types_exp.MaxVersion.String() {
+			t.Errorf("#%d: bad version: %q", i, cfg.Ignition.Version)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		in  string
+		to  semver.Version
+		out string
+		err error
+	}{
+		{
+			in:  `{"ignition": {"version": "3.1.0-experimental"}, "systemd": {"units": [{"name": "foo.service", "enabled": true}]}}`,
+			to:  v3_0.MaxVersion,
+			out: `"version":"3.0.0"`,
+		},
+		{
+			in:  `{"ignition": {"version": "2.2.0"}, "systemd": {"units": [{"name": "foo.service", "enable": true}]}}`,
+			to:  v3_0.MaxVersion,
+			out: `"enabled":true`,
+		},
+		{
+			in:  `{"ignition": {"version": "3.0.0"}}`,
+			to:  types_exp.MaxVersion,
+			out: `"version":"3.1.0-experimental"`,
+		},
+		{
+			in:  `{"ignition": {"version": "3.1.0-experimental"}, "storage": {"files": [{"path": "/etc/motd", "edits": [{"regex": "^foo$", "line": "bar"}]}]}}`,
+			to:  v3_0.MaxVersion,
+			err: errors.ErrInvalid,
+		},
+		{
+			in:  `{"ignition": {"version": "3.0.0"}}`,
+			to:  semver.Version{Major: 2, Minor: 3},
+			err: errors.ErrTranslateUnsupportedVersion,
+		},
+	}
+
+	for i, test := range tests {
+		out, r, err := Translate([]byte(test.in), test.to)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v, report: %v", i, test.err, err, r)
+		}
+		if !strings.Contains(string(out), test.out) {
+			t.Errorf("#%d: expected %s in output, got %s", i, test.out, out)
+		}
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		in  string
+		err error
+	}{
+		{
+			in:  "ignition:\n  version: 3.1.0-experimental\nsystemd:\n  units:\n    - name: foo.service\n      enabled: true\n",
+			err: nil,
+		},
+		{
+			in:  `{"ignition": {"version": "3.0.0"}}`,
+			err: nil,
+		},
+		{
+			in:  "ignition:\n  version: 3.0.0\nstorage:\n  files:\n    - path: etc/motd\n",
+			err: errors.ErrInvalid,
+		},
+		{
+			in:  "",
+			err: errors.ErrEmpty,
+		},
+	}
+
+	for i, test := range tests {
+		cfg, r, err := ParseYAML([]byte(test.in))
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v, report: %v", i, test.err, err, r)
+		}
+		if err == nil && cfg.Ignition.Version != types_exp.MaxVersion.String() {
+			t.Errorf("#%d: bad version: %q", i, cfg.Ignition.Version)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		version semver.Version
+		path    string
+	}{
+		{v3_0.MaxVersion, "v3_0/schema/ignition.json"},
+		{types_exp.MaxVersion, "v3_1_experimental/schema/ignition.json"},
+	}
+
+	for i, test := range tests {
+		schema, err := Schema(test.version)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		expected, err := ioutil.ReadFile(test.path)
+		if err != nil {
+			t.Fatalf("#%d: couldn't read schema: %v", i, err)
+		}
+		// the embedded schema is regenerated by ./generate
+		if !bytes.Equal(bytes.TrimSpace(expected), bytes.TrimSpace(schema)) {
+			t.Errorf("#%d: embedded schema differs from %s; run ./generate", i, test.path)
+		}
+	}
+
+	if _, err := Schema(semver.Version{Major: 2, Minor: 3}); err != errors.ErrUnknownVersion {
+		t.Errorf("bad error for unsupported version: %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package diff reports the differences between two configs in terms of
+// what they do to a machine, such as files whose contents change or units
+// which are no longer enabled, rather than as changes to their JSON. It's
+// meant for reviewing changes to rendered configs.
+package diff
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+const (
+	// the modes Ignition gives files and directories without one
+	defaultFileMode      = 0644
+	defaultDirectoryMode = 0755
+)
+
+type Kind string
+
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Change is a difference between two configs in one entry of a section,
+// such as a file or a partition.
+type Change struct {
+	Kind Kind
+	// Section is the kind of entry, such as "file" or "partition".
+	Section string
+	// Key identifies the entry within its section, such as the path of a
+	// file.
+	Key string
+	// Fields describes how a changed entry differs, with one element per
+	// field in the form "name: old -> new".
+	Fields []string
+}
+
+func (c Change) String() string {
+	prefix := map[Kind]string{Added: "+", Removed: "-", Changed: "~"}[c.Kind]
+	s := fmt.Sprintf("%s %s %s", prefix, c.Section, c.Key)
+	if len(c.Fields) > 0 {
+		s += ": " + strings.Join(c.Fields, ", ")
+	}
+	return s
+}
+
+// field is a named value of an entry, rendered for comparison and display.
+type field struct {
+	name  string
+	value string
+}
+
+// entry is an entry of a section, reduced to the fields which are compared.
+type entry struct {
+	key    string
+	fields []field
+}
+
+// Diff returns the changes which turn config a into config b, section by
+// section. File and unit contents are compared by digest, so the contents
+// of data URLs aren't shown; remote contents are compared by their URL and
+// verification hash, and aren't fetched.
+func Diff(a, b types.Config) []Change {
+	sections := []struct {
+		name    string
+		entries func(types.Config) []entry
+	}{
+		{"disk", disks},
+		{"partition", partitions},
+		{"raid", raids},
+		{"filesystem", filesystems},
+		{"directory", directories},
+		{"file", files},
+		{"link", links},
+		{"unit", units},
+		{"dropin", dropins},
+		{"user", users},
+		{"group", groups},
+	}
+	var changes []Change
+	for _, s := range sections {
+		changes = append(changes, compare(s.name, s.entries(a), s.entries(b))...)
+	}
+	return changes
+}
+
+// compare returns the changes between the entries of a section in a and b,
+// sorted by key.
+func compare(section string, a, b []entry) []Change {
+	before := map[string]entry{}
+	for _, e := range a {
+		before[e.key] = e
+	}
+	after := map[string]entry{}
+	for _, e := range b {
+		after[e.key] = e
+	}
+
+	var changes []Change
+	for key, old := range before {
+		updated, ok := after[key]
+		if !ok {
+			changes = append(changes, Change{Kind: Removed, Section: section, Key: key})
+			continue
+		}
+		if fields := changedFields(old, updated); len(fields) > 0 {
+			changes = append(changes, Change{Kind: Changed, Section: section, Key: key, Fields: fields})
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			changes = append(changes, Change{Kind: Added, Section: section, Key: key})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// changedFields describes the fields which differ between two versions of
+// an entry. Both have the same fields in the same order.
+func changedFields(old, updated entry) []string {
+	var ret []string
+	for i := range old.fields {
+		if old.fields[i].value != updated.fields[i].value {
+			ret = append(ret, fmt.Sprintf("%s: %s -> %s", old.fields[i].name, old.fields[i].value, updated.fields[i].value))
+		}
+	}
+	return ret
+}
+
+func str(s *string) string {
+	if s == nil {
+		return "unset"
+	}
+	return fmt.Sprintf("%q", *s)
+}
+
+func integer(i *int) string {
+	if i == nil {
+		return "unset"
+	}
+	return fmt.Sprint(*i)
+}
+
+func boolean(b *bool) string {
+	if b == nil {
+		return "unset"
+	}
+	return fmt.Sprint(*b)
+}
+
+// mode renders a mode, which defaults to def when unset.
+func mode(m *int, def int) string {
+	if m == nil {
+		return fmt.Sprintf("%04o", def)
+	}
+	return fmt.Sprintf("%04o", *m)
+}
+
+func owner(id *int, name *string) string {
+	if name != nil {
+		return *name
+	}
+	return integer(id)
+}
+
+// digest returns a short digest identifying data.
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// contents identifies the contents fetched from c: the digest of a data
+// URL's data, or a remote URL and its verification hash or checksums entry.
+func contents(c types.FileContents) string {
+	if c.Source == nil {
+		return "unset"
+	}
+	source := *c.Source
+	if strings.HasPrefix(source, "data:") {
+		stripped, _ := util.SplitZstdDataURL(source)
+		if url, err := dataurl.DecodeString(stripped); err == nil {
+			return digest(url.Data)
+		}
+		return digest([]byte(source))
+	}
+	if c.Verification.Hash != nil {
+		return fmt.Sprintf("%s (%s)", source, *c.Verification.Hash)
+	}
+	if sums := c.Verification.Checksums; sums.Source != nil && sums.Name != nil {
+		return fmt.Sprintf("%s (%s in %s)", source, *sums.Name, *sums.Source)
+	}
+	return source
+}
+
+func disks(cfg types.Config) []entry {
+	var ret []entry
+	for _, d := range cfg.Storage.Disks {
+		ret = append(ret, entry{d.Device, []field{
+			{"wipeTable", boolean(d.WipeTable)},
+		}})
+	}
+	return ret
+}
+
+// partitions returns the partitions of every disk, keyed by the disk's
+// device and the partition's number or label.
+func partitions(cfg types.Config) []entry {
+	var ret []entry
+	for _, d := range cfg.Storage.Disks {
+		for _, p := range d.Partitions {
+			ret = append(ret, entry{d.Device + " " + p.Key(), []field{
+				{"number", fmt.Sprint(p.Number)},
+				{"label", str(p.Label)},
+				{"startMiB", integer(p.StartMiB)},
+				{"sizeMiB", integer(p.SizeMiB)},
+				{"typeGuid", str(p.TypeGUID)},
+				{"guid", str(p.GUID)},
+				{"shouldExist", boolean(p.ShouldExist)},
+				{"wipePartitionEntry", boolean(p.WipePartitionEntry)},
+			}})
+		}
+	}
+	return ret
+}
+
+func raids(cfg types.Config) []entry {
+	var ret []entry
+	for _, r := range cfg.Storage.Raid {
+		var devices []string
+		for _, d := range r.Devices {
+			devices = append(devices, string(d))
+		}
+		ret = append(ret, entry{r.Name, []field{
+			{"level", r.Level},
+			{"devices", strings.Join(devices, " ")},
+			{"spares", integer(r.Spares)},
+		}})
+	}
+	return ret
+}
+
+func filesystems(cfg types.Config) []entry {
+	var ret []entry
+	for _, f := range cfg.Storage.Filesystems {
+		ret = append(ret, entry{f.Device, []field{
+			{"format", str(f.Format)},
+			{"path", str(f.Path)},
+			{"label", str(f.Label)},
+			{"uuid", str(f.UUID)},
+			{"wipeFilesystem", boolean(f.WipeFilesystem)},
+		}})
+	}
+	return ret
+}
+
+func nodeFields(n types.Node) []field {
+	return []field{
+		{"user", owner(n.User.ID, n.User.Name)},
+		{"group", owner(n.Group.ID, n.Group.Name)},
+		{"overwrite", boolean(n.Overwrite)},
+	}
+}
+
+func directories(cfg types.Config) []entry {
+	var ret []entry
+	for _, d := range cfg.Storage.Directories {
+		ret = append(ret, entry{d.Path, append(nodeFields(d.Node),
+			field{"mode", mode(d.Mode, defaultDirectoryMode)},
+		)})
+	}
+	return ret
+}
+
+func files(cfg types.Config) []entry {
+	var ret []entry
+	for _, f := range cfg.Storage.Files {
+		var appended []string
+		for _, a := range f.Append {
+			appended = append(appended, contents(a))
+		}
+		ret = append(ret, entry{f.Path, append(nodeFields(f.Node),
+			field{"contents", contents(f.Contents)},
+			field{"append", "[" + strings.Join(appended, ", ") + "]"},
+			field{"mode", mode(f.Mode, defaultFileMode)},
+		)})
+	}
+	return ret
+}
+
+func links(cfg types.Config) []entry {
+	var ret []entry
+	for _, l := range cfg.Storage.Links {
+		ret = append(ret, entry{l.Path, append(nodeFields(l.Node),
+			field{"target", l.Target},
+			field{"hard", boolean(l.Hard)},
+		)})
+	}
+	return ret
+}
+
+func units(cfg types.Config) []entry {
+	var ret []entry
+	for _, u := range cfg.Systemd.Units {
+		unitContents := "unset"
+		if u.Contents != nil {
+			unitContents = digest([]byte(*u.Contents))
+		}
+		ret = append(ret, entry{u.Name, []field{
+			{"contents", unitContents},
+			{"enabled", boolean(u.Enabled)},
+			{"mask", boolean(u.Mask)},
+		}})
+	}
+	return ret
+}
+
+// dropins returns the dropins of every unit, keyed by the unit's name and
+// the dropin's.
+func dropins(cfg types.Config) []entry {
+	var ret []entry
+	for _, u := range cfg.Systemd.Units {
+		for _, d := range u.Dropins {
+			dropinContents := "unset"
+			if d.Contents != nil {
+				dropinContents = digest([]byte(*d.Contents))
+			}
+			ret = append(ret, entry{u.Name + "/" + d.Name, []field{
+				{"contents", dropinContents},
+			}})
+		}
+	}
+	return ret
+}
+
+func users(cfg types.Config) []entry {
+	var ret []entry
+	for _, u := range cfg.Passwd.Users {
+		var keys []string
+		for _, k := range u.SSHAuthorizedKeys {
+			keys = append(keys, string(k))
+		}
+		var userGroups []string
+		for _, g := range u.Groups {
+			userGroups = append(userGroups, string(g))
+		}
+		passwordHash := "unset"
+		if u.PasswordHash != nil {
+			// don't show the hash itself
+			passwordHash = digest([]byte(*u.PasswordHash))
+		}
+		ret = append(ret, entry{u.Name, []field{
+			{"uid", integer(u.UID)},
+			{"passwordHash", passwordHash},
+			{"sshAuthorizedKeys", fmt.Sprintf("%d (%s)", len(keys), digest([]byte(strings.Join(keys, "\n"))))},
+			{"primaryGroup", str(u.PrimaryGroup)},
+			{"groups", "[" + strings.Join(userGroups, " ") + "]"},
+			{"homeDir", str(u.HomeDir)},
+			{"shell", str(u.Shell)},
+		}})
+	}
+	return ret
+}
+
+func groups(cfg types.Config) []entry {
+	var ret []entry
+	for _, g := range cfg.Passwd.Groups {
+		passwordHash := "unset"
+		if g.PasswordHash != nil {
+			passwordHash = digest([]byte(*g.PasswordHash))
+		}
+		ret = append(ret, entry{g.Name, []field{
+			{"gid", integer(g.Gid)},
+			{"passwordHash", passwordHash},
+		}})
+	}
+	return ret
+}
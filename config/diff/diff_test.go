@@ -0,0 +1,139 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+func file(path, source string) types.File {
+	return types.File{
+		Node: types.Node{Path: path},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.FileContents{Source: util.StrToPtr(source)},
+		},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b types.Config
+		out  []string
+	}{
+		{
+			// an unset mode is the default mode
+			a: types.Config{
+				Storage: types.Storage{Files: []types.File{file("/etc/motd", "data:,hello")}},
+			},
+			b: types.Config{
+				Storage: types.Storage{Files: []types.File{{
+					Node: types.Node{Path: "/etc/motd"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.FileContents{Source: util.StrToPtr("data:,hello")},
+						Mode:     util.IntToPtr(0644),
+					},
+				}}},
+			},
+		},
+		{
+			// contents are compared by digest, and data URLs are decoded
+			a: types.Config{
+				Storage: types.Storage{Files: []types.File{
+					file("/etc/motd", "data:,hello"),
+					file("/etc/issue", "data:,x"),
+					file("/etc/hostname", "data:,node1"),
+				}},
+			},
+			b: types.Config{
+				Storage: types.Storage{Files: []types.File{
+					file("/etc/motd", "data:;base64,aGVsbG8="),
+					file("/etc/hostname", "data:,node2"),
+					file("/etc/resolv.conf", "https://example.com/resolv.conf"),
+				}},
+			},
+			out: []string{
+				"~ file /etc/hostname: contents: sha256-ca12f31b8cbf -> sha256-15b18a724325",
+				"- file /etc/issue",
+				"+ file /etc/resolv.conf",
+			},
+		},
+		{
+			a: types.Config{
+				Storage: types.Storage{Disks: []types.Disk{{
+					Device: "/dev/vda",
+					Partitions: []types.Partition{
+						{Number: 1, Label: util.StrToPtr("boot"), SizeMiB: util.IntToPtr(100)},
+						{Label: util.StrToPtr("root")},
+					},
+				}}},
+				Systemd: types.Systemd{Units: []types.Unit{
+					{Name: "a.service", Enabled: util.BoolToPtr(true)},
+					{Name: "b.service", Dropins: []types.Dropin{{Name: "x.conf", Contents: util.StrToPtr("[Unit]")}}},
+				}},
+			},
+			b: types.Config{
+				Storage: types.Storage{Disks: []types.Disk{{
+					Device:    "/dev/vda",
+					WipeTable: util.BoolToPtr(true),
+					Partitions: []types.Partition{
+						{Number: 1, Label: util.StrToPtr("boot"), SizeMiB: util.IntToPtr(200)},
+						{Number: 2, Label: util.StrToPtr("root")},
+					},
+				}}},
+				Systemd: types.Systemd{Units: []types.Unit{
+					{Name: "a.service", Enabled: util.BoolToPtr(false)},
+					{Name: "b.service"},
+				}},
+			},
+			out: []string{
+				"~ disk /dev/vda: wipeTable: unset -> true",
+				`- partition /dev/vda label:root`,
+				`~ partition /dev/vda number:1: sizeMiB: 100 -> 200`,
+				`+ partition /dev/vda number:2`,
+				"~ unit a.service: enabled: true -> false",
+				"- dropin b.service/x.conf",
+			},
+		},
+		{
+			a: types.Config{
+				Passwd: types.Passwd{Users: []types.PasswdUser{
+					{Name: "core", PasswordHash: util.StrToPtr("$6$a")},
+				}},
+			},
+			b: types.Config{
+				Passwd: types.Passwd{Users: []types.PasswdUser{
+					{Name: "core", SSHAuthorizedKeys: []types.SSHAuthorizedKey{"ssh-ed25519 AAAA"}},
+				}},
+			},
+			out: []string{
+				"~ user core: passwordHash: sha256-ea5825deca62 -> unset, sshAuthorizedKeys: 0 (sha256-e3b0c44298fc) -> 1 (sha256-9de8a23119db)",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		var out []string
+		for _, c := range Diff(test.a, test.b) {
+			out = append(out, c.String())
+		}
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: expected:\n%q\ngot:\n%q", i, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	types_3_0 "github.com/coreos/ignition/v2/config/v3_0/types"
+	types_exp "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+// The fuzz targets check that no input, however malformed, makes the
+// functions Ignition runs on user data panic. Their seeds run with the rest
+// of the tests; run them with e.g. go test -fuzz FuzzParse ./config.
+
+// fuzzSeeds are configs exercising each spec version and most sections.
+var fuzzSeeds = []string{
+	``,
+	`{}`,
+	`{"ignition": {"version": "2.3.0"}, "storage": {"files": [{"filesystem": "root", "path": "/etc/motd", "contents": {"source": "data:,hello"}}]}}`,
+	`{"ignition": {"version": "3.0.0"}, "storage": {"disks": [{"device": "/dev/vda", "partitions": [{"number": 1, "label": "root", "sizeMiB": 0}]}], "filesystems": [{"device": "/dev/vda1", "format": "ext4", "path": "/var"}]}}`,
+	`{"ignition": {"version": "3.0.0"}, "passwd": {"users": [{"name": "core", "sshAuthorizedKeys": ["ssh-ed25519 AAAA"]}]}, "systemd": {"units": [{"name": "a.service", "enabled": true, "dropins": [{"name": "b.conf", "contents": "[Service]"}]}]}}`,
+	`{"ignition": {"version": "3.1.0-experimental", "config": {"merge": [{"source": "https://example.com/a.ign"}]}}, "storage": {"links": [{"path": "/a", "target": "/b", "hard": true}], "directories": [{"path": "/a/b", "overwrite": true}]}}`,
+	`{"ignition": {"version": "3.1.0-experimental"}, "storage": {"files": [{"path": "/a", "append": [{"source": "data:,x"}], "contents": {"compression": "gzip", "verification": {"hash": "sha512-00"}}}]}}`,
+	"ignition:\n  version: 3.0.0\nstorage:\n  files:\n    - path: /etc/motd\n",
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		cfg, _, err := ParseYAML(raw)
+		if err != nil {
+			return
+		}
+		Validate(cfg)
+		PlatformLimits("metal").Check(cfg)
+	})
+}
+
+func FuzzMerge(f *testing.F) {
+	for _, parent := range fuzzSeeds {
+		for _, child := range fuzzSeeds {
+			f.Add([]byte(parent), []byte(child))
+		}
+	}
+	f.Fuzz(func(t *testing.T, rawParent, rawChild []byte) {
+		parent, _, err := Parse(rawParent)
+		if err != nil {
+			return
+		}
+		child, _, err := Parse(rawChild)
+		if err != nil {
+			return
+		}
+		Validate(Merge(parent, child))
+	})
+}
+
+func FuzzTranslate(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		_, _, expErr := Translate(raw, types_exp.MaxVersion)
+		down, _, err := Translate(raw, types_3_0.MaxVersion)
+		if err != nil {
+			return
+		}
+		// anything translatable to 3.0.0 is a valid config, and so is the
+		// translation
+		if expErr != nil {
+			t.Fatalf("config translates to %s but doesn't parse: %v", types_3_0.MaxVersion, expErr)
+		}
+		if _, _, err := Translate(down, types_3_0.MaxVersion); err != nil {
+			t.Fatalf("translation doesn't parse: %v\n%s", err, down)
+		}
+	})
+}
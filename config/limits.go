@@ -0,0 +1,93 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	types_exp "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// Limits bounds the size and complexity of configs, so that oversized
+// configs fail with an actionable error instead of exhausting memory in the
+// initramfs. Zero fields are unlimited.
+type Limits struct {
+	// MaxConfigBytes is the maximum size of a raw config.
+	MaxConfigBytes int
+	// MaxNodes is the maximum total number of files, directories, and links.
+	MaxNodes int
+	// MaxInlineBytes is the maximum length of a data URL in the config.
+	MaxInlineBytes int
+}
+
+var (
+	// DefaultLimits are the limits Ignition applies unless configured
+	// otherwise.
+	DefaultLimits = Limits{
+		MaxConfigBytes: 64 * 1024 * 1024,
+		MaxNodes:       100000,
+		MaxInlineBytes: 32 * 1024 * 1024,
+	}
+
+	// platformConfigBytes are the maximum sizes of the configs platforms
+	// can deliver to instances.
+	platformConfigBytes = map[string]int{
+		"aws":          16 * 1024,
+		"digitalocean": 64 * 1024,
+		"exoscale":     32 * 1024,
+		"gcp":          256 * 1024,
+	}
+)
+
+// PlatformLimits returns DefaultLimits, further restricted by the maximum
+// config size of the given platform if it has one, e.g. the 16 KiB limit of
+// EC2 user data.
+func PlatformLimits(platform string) Limits {
+	l := DefaultLimits
+	if max, ok := platformConfigBytes[platform]; ok && max < l.MaxConfigBytes {
+		l.MaxConfigBytes = max
+	}
+	return l
+}
+
+// CheckSize returns an error if raw is larger than the config size limit.
+// It should be called before raw is parsed.
+func (l Limits) CheckSize(raw []byte) error {
+	if l.MaxConfigBytes > 0 && len(raw) > l.MaxConfigBytes {
+		return fmt.Errorf("%v: %d bytes exceeds the limit of %d bytes; move large contents to remote sources or split the config with ignition.config.merge", errors.ErrConfigTooLarge, len(raw), l.MaxConfigBytes)
+	}
+	return nil
+}
+
+// Check reports the parts of cfg which exceed the limits.
+func (l Limits) Check(cfg types_exp.Config) (r report.Report) {
+	nodes := len(cfg.Storage.Files) + len(cfg.Storage.Directories) + len(cfg.Storage.Links)
+	if l.MaxNodes > 0 && nodes > l.MaxNodes {
+		r.AddOnError(path.New("json", "storage"), fmt.Errorf("%v: %d exceeds the limit of %d; include them in the image instead", errors.ErrTooManyNodes, nodes, l.MaxNodes))
+	}
+	if l.MaxInlineBytes > 0 {
+		for _, s := range cfg.Sources() {
+			if s.Source != nil && strings.HasPrefix(*s.Source, "data:") && len(*s.Source) > l.MaxInlineBytes {
+				r.AddOnError(s.Path, fmt.Errorf("%v: %d bytes exceeds the limit of %d bytes; fetch the contents from a remote source instead", errors.ErrInlineTooLarge, len(*s.Source), l.MaxInlineBytes))
+			}
+		}
+	}
+	return
+}
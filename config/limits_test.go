@@ -0,0 +1,98 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	types_exp "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatformLimits(t *testing.T) {
+	assert.Equal(t, 16*1024, PlatformLimits("aws").MaxConfigBytes)
+	assert.Equal(t, DefaultLimits, PlatformLimits("qemu"))
+	assert.Equal(t, DefaultLimits, PlatformLimits(""))
+}
+
+func TestLimitsCheckSize(t *testing.T) {
+	l := Limits{MaxConfigBytes: 10}
+	assert.NoError(t, l.CheckSize([]byte("0123456789")))
+	err := l.CheckSize([]byte("0123456789a"))
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), errors.ErrConfigTooLarge.Error()), "bad error: %v", err)
+	}
+	assert.NoError(t, Limits{}.CheckSize([]byte("0123456789a")))
+}
+
+func TestLimitsCheck(t *testing.T) {
+	cfg := types_exp.Config{
+		Ignition: types_exp.Ignition{
+			Config: types_exp.IgnitionConfig{
+				Merge: []types_exp.ConfigReference{{Source: util.StrToPtr("data:,0123456789")}},
+			},
+		},
+		Storage: types_exp.Storage{
+			Files: []types_exp.File{
+				{
+					Node: types_exp.Node{Path: "/a"},
+					FileEmbedded1: types_exp.FileEmbedded1{
+						Contents: types_exp.FileContents{Source: util.StrToPtr("https://example.com/0123456789")},
+					},
+				},
+			},
+			Directories: []types_exp.Directory{{Node: types_exp.Node{Path: "/b"}}},
+		},
+	}
+
+	tests := []struct {
+		limits Limits
+		at     []path.ContextPath
+		errs   []error
+	}{
+		{
+			limits: Limits{},
+		},
+		{
+			limits: Limits{MaxNodes: 2, MaxInlineBytes: 16},
+		},
+		{
+			limits: Limits{MaxNodes: 1, MaxInlineBytes: 15},
+			at: []path.ContextPath{
+				path.New("json", "storage"),
+				path.New("json", "ignition", "config", "merge", 0, "source"),
+			},
+			errs: []error{errors.ErrTooManyNodes, errors.ErrInlineTooLarge},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.limits.Check(cfg)
+		if !assert.Equal(t, len(test.errs), len(r.Entries), "#%d: bad report: %v", i, r) {
+			continue
+		}
+		for j, e := range r.Entries {
+			assert.Equal(t, report.Error, e.Kind, "#%d: bad kind", i)
+			assert.Equal(t, test.at[j], e.Context, "#%d: bad path", i)
+			assert.True(t, strings.HasPrefix(e.Message, test.errs[j].Error()), "#%d: bad message: %s", i, e.Message)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint checks configs for patterns which are valid but are usually
+// mistakes or bad practice. Linting is opt-in and only produces warnings.
+package lint
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+const (
+	// LargeDataURLSize is the length above which inline data URLs are
+	// reported by the large-data-url rule.
+	LargeDataURLSize = 64 * 1024
+)
+
+// Rule is a single lint check.
+type Rule struct {
+	Name        string
+	Description string
+	check       func(types.Config) report.Report
+}
+
+// Rules lists every lint rule, in the order they are run.
+var Rules = []Rule{
+	{
+		Name:        "large-data-url",
+		Description: fmt.Sprintf("inline data URLs longer than %d bytes", LargeDataURLSize),
+		check:       checkLargeDataURLs,
+	},
+	{
+		Name:        "world-writable",
+		Description: "files and directories made world-writable with allowWorldWritable",
+		check:       checkWorldWritable,
+	},
+	{
+		Name:        "password-hash-x",
+		Description: `users and groups with passwordHash "x"`,
+		check:       checkPasswordHashX,
+	},
+	{
+		Name:        "unverified-http",
+		Description: "http sources without a verification hash",
+		check:       checkUnverifiedHTTP,
+	},
+}
+
+var suppression = regexp.MustCompile(`ignition-lint:\s*ignore\s+([a-z0-9-]+(?:\s*,\s*[a-z0-9-]+)*)`)
+
+// Suppressions returns the rules disabled by comments of the form
+// "ignition-lint: ignore rule1,rule2" anywhere in raw. Such comments can be
+// written as YAML comments or, in JSON configs, inside the contents of a
+// unit.
+func Suppressions(raw []byte) map[string]bool {
+	ret := map[string]bool{}
+	for _, m := range suppression.FindAllSubmatch(raw, -1) {
+		for _, name := range strings.Split(string(m[1]), ",") {
+			ret[strings.TrimSpace(name)] = true
+		}
+	}
+	return ret
+}
+
+// Lint runs every rule not in ignore against cfg. Each finding is a warning
+// whose message ends with the name of the rule which produced it.
+func Lint(cfg types.Config, ignore map[string]bool) (r report.Report) {
+	for _, rule := range Rules {
+		if ignore[rule.Name] {
+			continue
+		}
+		found := rule.check(cfg)
+		for i := range found.Entries {
+			found.Entries[i].Message = fmt.Sprintf("%s [%s]", found.Entries[i].Message, rule.Name)
+		}
+		r.Merge(found)
+	}
+	return
+}
+
+func checkLargeDataURLs(cfg types.Config) (r report.Report) {
+	for _, s := range cfg.Sources() {
+		if s.Source != nil && strings.HasPrefix(*s.Source, "data:") && len(*s.Source) > LargeDataURLSize {
+			r.AddOnWarn(s.Path, errors.ErrLintLargeDataURL)
+		}
+	}
+	return
+}
+
+func checkUnverifiedHTTP(cfg types.Config) (r report.Report) {
+	for _, s := range cfg.Sources() {
+		if s.Source == nil || s.Verification.Hash != nil {
+			continue
+		}
+		source := s.Source
+		if sums := s.Verification.Checksums; sums.Source != nil {
+			if sums.Signature != nil {
+				continue
+			}
+			// the contents are only as trustworthy as the manifest
+			source = sums.Source
+		}
+		if u, err := url.Parse(*source); err == nil && u.Scheme == "http" {
+			r.AddOnWarn(s.Path, errors.ErrLintUnverifiedHTTP)
+		}
+	}
+	return
+}
+
+func checkWorldWritable(cfg types.Config) (r report.Report) {
+	for i, f := range cfg.Storage.Files {
+		mode, err := f.ResolvedMode()
+		if err == nil && worldWritable(mode, f.AllowWorldWritable) {
+			r.AddOnWarn(path.New("json", "storage", "files", i, "allowWorldWritable"), errors.ErrLintWorldWritable)
+		}
+	}
+	for i, d := range cfg.Storage.Directories {
+		mode, err := d.ResolvedMode()
+		// sticky directories like /tmp are expected to be world-writable
+		if err == nil && worldWritable(mode, d.AllowWorldWritable) && *mode&01000 == 0 {
+			r.AddOnWarn(path.New("json", "storage", "directories", i, "allowWorldWritable"), errors.ErrLintWorldWritable)
+		}
+	}
+	return
+}
+
+func worldWritable(mode *int, allowWorldWritable *bool) bool {
+	return mode != nil && *mode&02 != 0 && allowWorldWritable != nil && *allowWorldWritable
+}
+
+func checkPasswordHashX(cfg types.Config) (r report.Report) {
+	for i, u := range cfg.Passwd.Users {
+		if u.PasswordHash != nil && *u.PasswordHash == "x" {
+			r.AddOnWarn(path.New("json", "passwd", "users", i, "passwordHash"), errors.ErrLintPasswordHashX)
+		}
+	}
+	for i, g := range cfg.Passwd.Groups {
+		if g.PasswordHash != nil && *g.PasswordHash == "x" {
+			r.AddOnWarn(path.New("json", "passwd", "groups", i, "passwordHash"), errors.ErrLintPasswordHashX)
+		}
+	}
+	return
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestLint(t *testing.T) {
+	warn := func(rule string, err error, c path.ContextPath) report.Entry {
+		return report.Entry{
+			Kind:    report.Warn,
+			Message: err.Error() + " [" + rule + "]",
+			Context: c,
+		}
+	}
+
+	tests := []struct {
+		in     types.Config
+		ignore map[string]bool
+		out    []report.Entry
+	}{
+		{
+			in: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{Path: "/etc/motd"},
+							FileEmbedded1: types.FileEmbedded1{
+								Contents: types.FileContents{Source: util.StrToPtr("data:,hello")},
+								Mode:     util.IntToPtr(0644),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			in: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{Path: "/etc/big"},
+							FileEmbedded1: types.FileEmbedded1{
+								Contents: types.FileContents{Source: util.StrToPtr("data:," + strings.Repeat("a", LargeDataURLSize))},
+							},
+						},
+					},
+				},
+			},
+			out: []report.Entry{
+				warn("large-data-url", errors.ErrLintLargeDataURL, path.New("json", "storage", "files", 0, "contents", "source")),
+			},
+		},
+		{
+			in: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{Path: "/etc/shared"},
+							FileEmbedded1: types.FileEmbedded1{
+								AllowWorldWritable: util.BoolToPtr(true),
+								Mode:               util.IntToPtr(0666),
+							},
+						},
+					},
+					Directories: []types.Directory{
+						{
+							Node: types.Node{Path: "/srv/tmp"},
+							DirectoryEmbedded1: types.DirectoryEmbedded1{
+								AllowWorldWritable: util.BoolToPtr(true),
+								Mode:               util.IntToPtr(01777),
+							},
+						},
+						{
+							Node: types.Node{Path: "/srv/drop"},
+							DirectoryEmbedded1: types.DirectoryEmbedded1{
+								AllowWorldWritable: util.BoolToPtr(true),
+								SymbolicMode:       util.StrToPtr("a=rwx"),
+							},
+						},
+					},
+				},
+			},
+			out: []report.Entry{
+				warn("world-writable", errors.ErrLintWorldWritable, path.New("json", "storage", "files", 0, "allowWorldWritable")),
+				warn("world-writable", errors.ErrLintWorldWritable, path.New("json", "storage", "directories", 1, "allowWorldWritable")),
+			},
+		},
+		{
+			in: types.Config{
+				Passwd: types.Passwd{
+					Users:  []types.PasswdUser{{Name: "core", PasswordHash: util.StrToPtr("x")}},
+					Groups: []types.PasswdGroup{{Name: "wheel", PasswordHash: util.StrToPtr("x")}},
+				},
+			},
+			out: []report.Entry{
+				warn("password-hash-x", errors.ErrLintPasswordHashX, path.New("json", "passwd", "users", 0, "passwordHash")),
+				warn("password-hash-x", errors.ErrLintPasswordHashX, path.New("json", "passwd", "groups", 0, "passwordHash")),
+			},
+		},
+		{
+			in: types.Config{
+				Ignition: types.Ignition{
+					Config: types.IgnitionConfig{
+						Merge: []types.ConfigReference{
+							{Source: util.StrToPtr("http://example.com/a.ign")},
+							{
+								Source:       util.StrToPtr("http://example.com/b.ign"),
+								Verification: types.Verification{Hash: util.StrToPtr("sha512-0123")},
+							},
+							{Source: util.StrToPtr("https://example.com/c.ign")},
+							{
+								// the manifest is as good as a hash if it's signed
+								Source: util.StrToPtr("http://example.com/d.ign"),
+								Verification: types.Verification{Checksums: types.VerificationChecksums{
+									Source:    util.StrToPtr("http://example.com/SHA256SUMS"),
+									Name:      util.StrToPtr("d.ign"),
+									Signature: util.StrToPtr("http://example.com/SHA256SUMS.sig"),
+								}},
+							},
+							{
+								// or is fetched securely
+								Source: util.StrToPtr("http://example.com/e.ign"),
+								Verification: types.Verification{Checksums: types.VerificationChecksums{
+									Source: util.StrToPtr("https://example.com/SHA256SUMS"),
+									Name:   util.StrToPtr("e.ign"),
+								}},
+							},
+							{
+								Source: util.StrToPtr("https://example.com/f.ign"),
+								Verification: types.Verification{Checksums: types.VerificationChecksums{
+									Source: util.StrToPtr("http://example.com/SHA256SUMS"),
+									Name:   util.StrToPtr("f.ign"),
+								}},
+							},
+						},
+					},
+				},
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{Path: "/etc/motd"},
+							FileEmbedded1: types.FileEmbedded1{
+								Append: []types.FileContents{{Source: util.StrToPtr("http://example.com/motd")}},
+							},
+						},
+					},
+				},
+			},
+			out: []report.Entry{
+				warn("unverified-http", errors.ErrLintUnverifiedHTTP, path.New("json", "ignition", "config", "merge", 0, "source")),
+				warn("unverified-http", errors.ErrLintUnverifiedHTTP, path.New("json", "ignition", "config", "merge", 5, "source")),
+				warn("unverified-http", errors.ErrLintUnverifiedHTTP, path.New("json", "storage", "files", 0, "append", 0, "source")),
+			},
+		},
+		{
+			in: types.Config{
+				Ignition: types.Ignition{
+					Config: types.IgnitionConfig{
+						Replace: types.ConfigReference{Source: util.StrToPtr("http://example.com/a.ign")},
+					},
+				},
+				Passwd: types.Passwd{
+					Users: []types.PasswdUser{{Name: "core", PasswordHash: util.StrToPtr("x")}},
+				},
+			},
+			ignore: map[string]bool{"unverified-http": true},
+			out: []report.Entry{
+				warn("password-hash-x", errors.ErrLintPasswordHashX, path.New("json", "passwd", "users", 0, "passwordHash")),
+			},
+		},
+	}
+
+	for i, test := range tests {
+		r := Lint(test.in, test.ignore)
+		if !reflect.DeepEqual(test.out, r.Entries) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r.Entries)
+		}
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	tests := []struct {
+		in  string
+		out map[string]bool
+	}{
+		{
+			in:  `{"ignition": {"version": "3.1.0-experimental"}}`,
+			out: map[string]bool{},
+		},
+		{
+			in: "# ignition-lint: ignore unverified-http, world-writable\nignition:\n  version: 3.1.0-experimental\n",
+			out: map[string]bool{
+				"unverified-http": true,
+				"world-writable":  true,
+			},
+		},
+		{
+			in:  `{"systemd": {"units": [{"name": "a.service", "contents": "# ignition-lint: ignore password-hash-x\n[Unit]"}]}}`,
+			out: map[string]bool{"password-hash-x": true},
+		},
+	}
+
+	for i, test := range tests {
+		out := Suppressions([]byte(test.in))
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad suppressions: want %v, got %v", i, test.out, out)
+		}
+	}
+}
@@ -16,6 +16,7 @@ package merge
 
 import (
 	"reflect"
+	"strings"
 
 	"github.com/coreos/ignition/v2/config/util"
 )
@@ -34,6 +35,7 @@ import (
 //      - merge entries with the same Key() that are in the same list
 //      - remove entries from the parent with the same Key() that are not in the same list
 //      - append entries that are unique to the child
+//   g) Lists which are replaced by a merge policy: use the child's list, even if it is empty
 
 // appendToSlice is a helper that appends to a slice without returning a new one.
 // panics if len >= cap
@@ -141,12 +143,29 @@ func newStructInfo(parent, child reflect.Value) structInfo {
 // typed. Use that one instead.
 // parent and child MUST be the same type
 func MergeStruct(parent, child reflect.Value) reflect.Value {
+	return mergeStruct(parent, child, "", nil)
+}
+
+// MergeStructReplacing is like MergeStruct, but the lists whose dotted JSON paths (e.g. "storage.files") are in
+// replaced are replaced by the child's lists rather than merged with them. Lists within list entries can't be
+// replaced.
+func MergeStructReplacing(parent, child reflect.Value, replaced map[string]bool) reflect.Value {
+	return mergeStruct(parent, child, "", replaced)
+}
+
+// mergeStruct merges parent and child, which are found at the dotted JSON path prefix.
+func mergeStruct(parent, child reflect.Value, prefix string, replaced map[string]bool) reflect.Value {
 	// use New() so it's settable, addr-able, etc
 	result := reflect.New(parent.Type()).Elem()
 	info := newStructInfo(parent, child)
 
 	for i := 0; i < parent.NumField(); i++ {
-		fieldName := parent.Type().Field(i).Name
+		field := parent.Type().Field(i)
+		fieldName := field.Name
+		fieldPath := prefix
+		if !field.Anonymous {
+			fieldPath = joinPath(prefix, strings.Split(field.Tag.Get("json"), ",")[0])
+		}
 		parentField := parent.Field(i)
 		childField := child.Field(i)
 		resultField := result.Field(i)
@@ -160,7 +179,9 @@ func MergeStruct(parent, child reflect.Value) reflect.Value {
 		case kind == reflect.Ptr && !childField.IsNil():
 			resultField.Set(childField)
 		case kind == reflect.Struct:
-			resultField.Set(MergeStruct(parentField, childField))
+			resultField.Set(mergeStruct(parentField, childField, fieldPath, replaced))
+		case kind == reflect.Slice && replaced[fieldPath]:
+			resultField.Set(childField)
 		case kind == reflect.Slice && info.ignoreField(fieldName):
 			if parentField.Len()+childField.Len() == 0 {
 				continue
@@ -214,6 +235,13 @@ func MergeStruct(parent, child reflect.Value) reflect.Value {
 	return result
 }
 
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
 // getKeySet takes a value of a slice and returns the set of all the Key() values in that slice
 func getKeySet(list reflect.Value) map[string]struct{} {
 	m := map[string]struct{}{}
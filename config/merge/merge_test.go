@@ -224,3 +224,100 @@ func TestMerge(t *testing.T) {
 		assert.Equal(t, test.out, out, "#%d bas merge", i)
 	}
 }
+
+func TestMergeReplacing(t *testing.T) {
+	type test struct {
+		in1      types.Config
+		in2      types.Config
+		replaced map[string]bool
+		out      types.Config
+	}
+
+	parent := types.Config{
+		Storage: types.Storage{
+			Files: []types.File{
+				{Node: types.Node{Path: "/foo"}},
+				{Node: types.Node{Path: "/bar"}},
+			},
+			Links: []types.Link{
+				{Node: types.Node{Path: "/baz"}},
+			},
+		},
+		Systemd: types.Systemd{
+			Units: []types.Unit{{Name: "foo.service"}},
+		},
+	}
+
+	tests := []test{
+		{
+			// no policies behaves like MergeStruct
+			in1: parent,
+			in2: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{{Node: types.Node{Path: "/bar", Overwrite: util.BoolToPtr(true)}}},
+				},
+			},
+			out: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{Node: types.Node{Path: "/foo"}},
+						{Node: types.Node{Path: "/bar", Overwrite: util.BoolToPtr(true)}},
+					},
+					Links: []types.Link{
+						{Node: types.Node{Path: "/baz"}},
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{{Name: "foo.service"}},
+				},
+			},
+		},
+		{
+			// replaced lists drop the parent's entries, other lists are merged
+			in1: parent,
+			in2: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{{Node: types.Node{Path: "/bar", Overwrite: util.BoolToPtr(true)}}},
+					Links: []types.Link{{Node: types.Node{Path: "/qux"}}},
+				},
+			},
+			replaced: map[string]bool{"storage.files": true},
+			out: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{Node: types.Node{Path: "/bar", Overwrite: util.BoolToPtr(true)}},
+					},
+					Links: []types.Link{
+						{Node: types.Node{Path: "/baz"}},
+						{Node: types.Node{Path: "/qux"}},
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{{Name: "foo.service"}},
+				},
+			},
+		},
+		{
+			// an empty child list clears the parent's
+			in1:      parent,
+			in2:      types.Config{},
+			replaced: map[string]bool{"systemd.units": true, "storage.links": true},
+			out: types.Config{
+				Storage: types.Storage{
+					Files: []types.File{
+						{Node: types.Node{Path: "/foo"}},
+						{Node: types.Node{Path: "/bar"}},
+					},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		in1v := reflect.ValueOf(test.in1)
+		in2v := reflect.ValueOf(test.in2)
+		out := MergeStructReplacing(in1v, in2v, test.replaced).Interface().(types.Config)
+
+		assert.Equal(t, test.out, out, "#%d bad merge", i)
+	}
+}
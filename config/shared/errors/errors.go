@@ -27,8 +27,35 @@ var (
 	ErrDuplicate = errors.New("duplicate entry defined")
 
 	// Ignition section errors
-	ErrInvalidVersion = errors.New("invalid config version (couldn't parse)")
-	ErrUnknownVersion = errors.New("unsupported config version")
+	ErrInvalidVersion        = errors.New("invalid config version (couldn't parse)")
+	ErrUnknownVersion        = errors.New("unsupported config version")
+	ErrInvalidListPolicy     = errors.New("list policy must be one of union or replace")
+	ErrListPolicyNotList     = errors.New("list must be the dotted path of a list in the config, e.g. storage.files")
+	ErrListPoliciesOnReplace = errors.New("listPolicies has no effect on a replacement config")
+
+	// Vault and signature errors
+	ErrVaultAddressRequired = errors.New("vault address is required when vault authentication is specified")
+	ErrVaultAuthRequired    = errors.New("one of token, appRole, or identity is required when a vault address is specified")
+	ErrVaultMultipleAuth    = errors.New("only one of token, appRole, or identity may be specified")
+	ErrVaultInsecure        = errors.New("vault address uses http; secrets and credentials will be sent unencrypted")
+	ErrVaultRoleIDRequired  = errors.New("roleId is required")
+	ErrVaultIdentityMethod  = errors.New("identity method must be one of aws or gcp")
+	ErrVaultRoleRequired    = errors.New("role is required")
+	ErrVaultFieldRequired   = errors.New("vault URLs must name a field, e.g. vault://secret/data/tls#cert")
+	ErrSignatureKeyInvalid  = errors.New("signature keys must be PEM-encoded ECDSA or RSA public keys, or ASCII-armored OpenPGP public key blocks")
+
+	// HTTP credential errors
+	ErrHTTPCredentialHostInvalid      = errors.New("host must be a hostname, optionally with a port")
+	ErrHTTPCredentialAuthRequired     = errors.New("one of token or username is required")
+	ErrHTTPCredentialMultipleAuth     = errors.New("only one of token or username may be specified")
+	ErrHTTPCredentialUsernameRequired = errors.New("username is required when a password is specified")
+
+	// Encryption errors
+	ErrEncryptionProviderInvalid = errors.New("encryption provider must be one of aws or gcp")
+	ErrEncryptionKeyInvalid      = errors.New("encryption key must be a KMS key ARN for aws or a Cloud KMS CryptoKey resource name for gcp")
+	ErrEncryptionDataKeyInvalid  = errors.New("encryption dataKey must be the base64-encoded encrypted data key")
+	ErrEncryptionAndNilSource    = errors.New("encryption specified without a source")
+	ErrEncryptionS3              = errors.New("encrypted contents cannot be fetched from s3")
 
 	ErrDeprecated         = errors.New("config format deprecated")
 	ErrCompressionInvalid = errors.New("invalid compression method")
@@ -39,8 +66,25 @@ var (
 	ErrFileUsedSymlink           = errors.New("file path includes link in config")
 	ErrDirectoryUsedSymlink      = errors.New("directory path includes link in config")
 	ErrLinkUsedSymlink           = errors.New("link path includes link in config")
+	ErrFileUsedFile              = errors.New("file path includes file in config")
+	ErrDirectoryUsedFile         = errors.New("directory path includes file in config")
+	ErrLinkUsedFile              = errors.New("link path includes file in config")
+	ErrDeviceNotDeclared         = errors.New("device is not declared in storage and must already exist")
+	ErrNodeUnderUnmountedFs      = errors.New("path is beneath a filesystem which Ignition does not mount")
+	ErrUserNotDeclared           = errors.New("user is not declared in passwd and must already exist")
+	ErrGroupNotDeclared          = errors.New("group is not declared in passwd and must already exist")
 	ErrHardLinkToDirectory       = errors.New("hard link target is a directory")
 	ErrDiskDeviceRequired        = errors.New("disk device is required")
+	ErrInvalidWaitTimeout        = errors.New("waitTimeout must be greater than or equal to 0")
+	ErrInvalidOnFailure          = errors.New("onFailure must be one of warn or fail")
+	ErrAssertWithWipeTable       = errors.New("wipeTable cannot be true if assert is true")
+	ErrPruneWithoutManifest      = errors.New("prune requires manifest to be true")
+	ErrAssertWithWipeEntry       = errors.New("wipePartitionEntry cannot be true if assert is true")
+	ErrAssertWithWipeFilesystem  = errors.New("wipeFilesystem cannot be true if assert is true")
+	ErrAssertNeedsNumber         = errors.New("a partition number >= 1 must be specified if assert is true")
+	ErrAssertNeedsFormat         = errors.New("format must be specified if assert is true")
+	ErrInvalidAllocation         = errors.New("allocationStrategy must be first-fit, append, or exact-start")
+	ErrExactStartWithZeroStart   = errors.New("startMiB cannot be 0 if allocationStrategy is exact-start")
 	ErrPartitionNumbersCollide   = errors.New("partition numbers collide")
 	ErrPartitionsOverlap         = errors.New("partitions overlap")
 	ErrPartitionsMisaligned      = errors.New("partitions misaligned")
@@ -54,7 +98,14 @@ var (
 	ErrXfsLabelTooLong           = errors.New("filesystem labels cannot be longer than 12 characters when using xfs")
 	ErrSwapLabelTooLong          = errors.New("filesystem labels cannot be longer than 15 characters when using swap")
 	ErrVfatLabelTooLong          = errors.New("filesystem labels cannot be longer than 11 characters when using vfat")
+	ErrUUIDNeedsFormat           = errors.New("filesystem must specify format if uuid is specified")
+	ErrFilesystemInvalidUUID     = errors.New("filesystem uuid must be of the form \"01234567-89AB-CDEF-EDCB-A98765432101\"")
+	ErrVfatInvalidUUID           = errors.New("vfat volume IDs must be of the form \"A1B2-C3D4\" or \"A1B2C3D4\"")
 	ErrFileIllegalMode           = errors.New("illegal file mode")
+	ErrInvalidSymbolicMode       = errors.New("invalid symbolic mode")
+	ErrModeAndSymbolicMode       = errors.New("mode and symbolicMode cannot both be specified")
+	ErrModeWorldWritable         = errors.New("mode is world-writable; set allowWorldWritable to permit it")
+	ErrModeSetuid                = errors.New("mode sets the setuid or setgid bit")
 	ErrBothIDAndNameSet          = errors.New("cannot set both id and name")
 	ErrLabelTooLong              = errors.New("partition labels may not exceed 36 characters")
 	ErrDoesntMatchGUIDRegex      = errors.New("doesn't match the form \"01234567-89AB-CDEF-EDCB-A98765432101\"")
@@ -64,16 +115,128 @@ var (
 	ErrDirtyPath                 = errors.New("path is not fully simplified")
 	ErrSparesUnsupportedForLevel = errors.New("spares unsupported for arrays with a level greater than 0")
 	ErrUnrecognizedRaidLevel     = errors.New("unrecognized raid level")
+	ErrInvalidRaidUUID           = errors.New("raid uuid must be 32 hexadecimal digits, optionally separated by colons, dashes, or periods")
+	ErrAssembleNeedsUUID         = errors.New("uuid must be specified if assemble is true")
+	ErrAssembleWithSpares        = errors.New("spares has no effect if assemble is true")
 	ErrShouldNotExistWithOthers  = errors.New("shouldExist specified false with other options also specified")
 	ErrZeroesWithShouldNotExist  = errors.New("shouldExist is false for a partition and other partition(s) has start or size 0")
 	ErrNeedLabelOrNumber         = errors.New("a partition number >= 1 or a label must be specified")
 	ErrDuplicateLabels           = errors.New("cannot use the same partition label twice")
 	ErrInvalidProxy              = errors.New("proxies must be http(s)")
 	ErrInsecureProxy             = errors.New("insecure plaintext HTTP proxy specified for HTTPS resources")
+	ErrInvalidTypeConflict       = errors.New("typeConflict must be fail, replace, or adopt")
+	ErrTypeConflictWithOverwrite = errors.New("typeConflict has no effect if overwrite is true")
+	ErrInvalidTargetStyle        = errors.New("targetStyle must be literal, absolute, or relative")
+	ErrTargetStyleWithHardLink   = errors.New("targetStyle cannot be specified for hard links")
+	ErrLinkTargetInSysroot       = errors.New("link target is under /sysroot, which will not exist once the system has booted")
+	ErrEfiNeedsPath              = errors.New("path must be specified if archive or bootEntries are specified")
+	ErrEfiFilesystemUndefined    = errors.New("efi system partition path does not match the path of any filesystem")
+	ErrEfiFilesystemNotVfat      = errors.New("efi system partition filesystem must be vfat")
+	ErrBootEntryLabelRequired    = errors.New("boot entry label is required")
+	ErrMarkerOnContents          = errors.New("markers can only be specified for appended contents")
+	ErrMarkerContainsNewline     = errors.New("markers cannot contain newlines")
+	ErrEditNeedsLineOrAbsent     = errors.New("edit must specify either line or absent")
+	ErrEditAbsentWithLine        = errors.New("edit cannot specify line if absent is true")
+	ErrEditLineContainsNewline   = errors.New("edit line cannot contain newlines")
+	ErrBackupWithoutEdits        = errors.New("backup has no effect without edits")
+	ErrInvalidMerge              = errors.New("merge must be one of ini, toml, or json")
+	ErrMergeOnAppend             = errors.New("merge can only be specified for file contents")
+	ErrMergeWithoutSource        = errors.New("merge has no effect without a source")
+	ErrMergeWithOverwrite        = errors.New("merge has no effect when overwrite is true")
+	ErrPatchFromOnAppend         = errors.New("patchFrom can only be specified for file contents")
+	ErrPatchFromWithoutSource    = errors.New("patchFrom requires a source")
+	ErrPatchFromCompressed       = errors.New("patchFrom can't be used with compression or encryption")
+	ErrPatchFromOverwritten      = errors.New("patchFrom can't be the file's own path when overwrite is true, since the file is removed first")
+	ErrPostCommandNoArgs         = errors.New("postCommand requires args")
+	ErrPostCommandRelative       = errors.New("the program run by postCommand must be an absolute path")
+	ErrPostCommandTimeout        = errors.New("postCommand timeout must be positive")
+	ErrInvalidTimestamp          = errors.New("timestamps must be in RFC 3339 format")
+
+	// Passwd section errors
+	ErrExistingUserField = errors.New("only groups and sshAuthorizedKeys can be specified for existing users")
 
 	// Systemd section errors
 	ErrInvalidSystemdExt       = errors.New("invalid systemd unit extension")
 	ErrInvalidSystemdDropinExt = errors.New("invalid systemd drop-in extension")
+	ErrUnitConflictsWithNode   = errors.New("unit path is also defined in storage")
+	ErrDropinConflictsWithNode = errors.New("drop-in path is also defined in storage")
+
+	// SELinux section errors
+	ErrSelinuxModuleNameInvalid = errors.New("module name must consist of letters, digits, underscores, and dashes")
+	ErrSelinuxModuleNoSource    = errors.New("module source is required")
+
+	// Security section errors
+	ErrTrustedCertNameInvalid     = errors.New("certificate name must consist of letters, digits, underscores, periods, and dashes, and not start with a period")
+	ErrTrustedCertNoSource        = errors.New("certificate source is required")
+	ErrFipsConflictsWithNode      = errors.New("path is written by security.fips")
+	ErrSSHHostKeyTypeInvalid      = errors.New("host key type must be rsa, ecdsa, or ed25519")
+	ErrSSHHostKeyNoSource         = errors.New("host key source is required")
+	ErrSSHHostKeyPublicKeyInvalid = errors.New("public key must be an OpenSSH public key of the host key's type")
+	ErrSSHHostKeyTypeNotAllowed   = errors.New("host key type is not listed in types")
+	ErrHostCertProtocolRequired   = errors.New("exactly one of acme or scep must be specified")
+	ErrHostCertDirectoryRequired  = errors.New("directory is required")
+	ErrHostCertURLRequired        = errors.New("url is required")
+	ErrHostCertNoDNSNames         = errors.New("at least one DNS name is required")
+	ErrHostCertDNSNameInvalid     = errors.New("DNS name must be a hostname without a trailing period")
+	ErrHostCertKeyTypeInvalid     = errors.New("key type must be ecdsa or rsa")
+	ErrHostCertSCEPKeyType        = errors.New("SCEP requires an rsa key")
+	ErrHostCertFingerprintMissing = errors.New("caFingerprint is required, since the CA certificate is fetched without authentication")
+	ErrHostCertFingerprintInvalid = errors.New("fingerprint must be in the form sha256-<hex>")
+	ErrHostCertSamePath           = errors.New("keyPath must differ from certificatePath")
+	ErrHostCertConflictsWithNode  = errors.New("path is written by security.hostCertificates")
+
+	// Machine ID errors
+	ErrMachineIDPolicyInvalid     = errors.New("machine ID policy must be set, firstboot, or platform")
+	ErrMachineIDValueRequired     = errors.New("value is required if the policy is set")
+	ErrMachineIDValueUnused       = errors.New("value can only be specified if the policy is set")
+	ErrMachineIDValueInvalid      = errors.New("machine ID must be 32 lowercase hexadecimal digits and not all zeros")
+	ErrMachineIDConflictsWithNode = errors.New("path is written by machineId")
+
+	// Containers section errors
+	ErrContainerImageInvalid    = errors.New("image must be a registry reference without a transport, e.g. quay.io/example/app:latest")
+	ErrContainerUnitNameInvalid = errors.New("container unit name may only contain letters, digits, underscores, periods, and dashes")
+	ErrContainerEnvNameInvalid  = errors.New("environment variable name must be a letter or underscore followed by letters, digits, and underscores")
+	ErrContainerEnvValueInvalid = errors.New("environment variable value may not contain newlines")
+	ErrContainerPortInvalid     = errors.New("port must be [[<ip>:][<hostPort>]:]<containerPort>[/<protocol>]")
+	ErrContainerVolumeInvalid   = errors.New("volume must be <source>:<destination>[:<options>] with an absolute destination")
+
+	// Next boot errors
+	ErrNextBootConflictsWithNode = errors.New("path is written by nextBoot")
+
+	// OS section errors
+	ErrOSPackageInvalid          = errors.New("package must be a package name, optionally with a version, and may not start with a dash")
+	ErrOSRebootUnused            = errors.New("reboot can only be specified with an image or packages")
+	ErrOSUpdateConflictsWithNode = errors.New("path is written by os")
+
+	// Time section errors
+	ErrTimeSourceInvalid = errors.New("time source must be a hostname or IP address")
+
+	// Spec 2 translation errors
+	ErrTranslateFilesystemPath     = errors.New("filesystems specified by path cannot be translated to spec 3")
+	ErrTranslateNonRootFilesystem  = errors.New("nodes on filesystems other than root cannot be translated to spec 3, since the filesystem's mount point is unknown")
+	ErrTranslateUnalignedPartition = errors.New("partition start and size must be multiples of 2048 sectors to be translated to MiB")
+	ErrTranslateSectorSize         = errors.New("partition start and size were translated to MiB assuming 512-byte sectors")
+	ErrTranslateCreate             = errors.New("create is deprecated and its settings were merged into the enclosing object")
+	ErrTranslateEnable             = errors.New("enable is deprecated and was translated to enabled")
+	ErrTranslateNetworkdUnit       = errors.New("networkd unit was translated to a file in /etc/systemd/network")
+
+	// Translation errors
+	ErrTranslateUnsupportedVersion = errors.New("translation to the requested version is not supported")
+	ErrDowngradeUnrepresentable    = errors.New("field cannot be represented in the requested version and would be dropped")
+
+	// YAML errors
+	ErrYAMLKeyNotString = errors.New("keys must be strings")
+
+	// Limit errors
+	ErrConfigTooLarge = errors.New("config is too large")
+	ErrTooManyNodes   = errors.New("config has too many files, directories, and links")
+	ErrInlineTooLarge = errors.New("data URL is too large")
+
+	// Lint warnings
+	ErrLintLargeDataURL   = errors.New("inline data URL is large; consider fetching the contents from a remote source")
+	ErrLintWorldWritable  = errors.New("mode is world-writable")
+	ErrLintPasswordHashX  = errors.New(`passwordHash "x" is not a password hash and makes the password unusable`)
+	ErrLintUnverifiedHTTP = errors.New("source is fetched over plain HTTP without verification")
 
 	// Misc errors
 	ErrInvalidScheme       = errors.New("invalid url scheme")
@@ -81,10 +244,20 @@ var (
 	ErrHashMalformed       = errors.New("malformed hash specifier")
 	ErrHashWrongSize       = errors.New("incorrect size for hash sum")
 	ErrHashUnrecognized    = errors.New("unrecognized hash function")
+	ErrHashWithChecksums   = errors.New("hash and checksums are mutually exclusive")
+	ErrChecksumsNoSource   = errors.New("checksums require a source")
+	ErrChecksumsNoName     = errors.New("checksums require the name of the entry to use")
 	ErrEngineConfiguration = errors.New("engine incorrectly configured")
 
 	// AWS S3 specific errors
 	ErrInvalidS3ObjectVersionId = errors.New("invalid S3 object VersionId")
+
+	// Mirror specific errors
+	ErrMirrorsWithoutSource = errors.New("mirrors require a source")
+	ErrMirrorScheme         = errors.New("mirrors of configs and the source they mirror must be http or https URLs")
+
+	// Unix socket specific errors
+	ErrInvalidUnixSocketURL = errors.New("http+unix URLs must name an absolute socket path and a path to request, e.g. http+unix:/run/provision.sock:/config")
 )
 
 // NewNoInstallSectionError produces an error indicating the given unit, named
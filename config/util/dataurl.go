@@ -0,0 +1,48 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"strings"
+)
+
+// SplitZstdDataURL reports whether the data URL s declares zstd-compressed
+// contents with a "zstd" parameter (e.g. "data:;zstd;base64,..."), and
+// returns s with the parameter removed so it can be decoded as a plain data
+// URL.
+func SplitZstdDataURL(s string) (string, bool) {
+	if !strings.HasPrefix(s, "data:") {
+		return s, false
+	}
+	comma := strings.IndexByte(s, ',')
+	if comma < 0 {
+		return s, false
+	}
+
+	params := strings.Split(s[len("data:"):comma], ";")
+	kept := params[:0]
+	zstd := false
+	for _, p := range params {
+		if p == "zstd" {
+			zstd = true
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	if !zstd {
+		return s, false
+	}
+	return "data:" + strings.Join(kept, ";") + s[comma:], true
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+)
+
+func TestSplitZstdDataURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		out  string
+		zstd bool
+	}{
+		{"data:,hello", "data:,hello", false},
+		{"data:;base64,aGk=", "data:;base64,aGk=", false},
+		{"data:;zstd;base64,aGk=", "data:;base64,aGk=", true},
+		{"data:text/plain;zstd;base64,aGk=", "data:text/plain;base64,aGk=", true},
+		{"data:zstd,hi", "data:,hi", true},
+		{"data:;base64,zstd", "data:;base64,zstd", false},
+		{"data:;zstdx;base64,aGk=", "data:;zstdx;base64,aGk=", false},
+		{"https://example.com/;zstd,", "https://example.com/;zstd,", false},
+	}
+
+	for i, test := range tests {
+		out, zstd := SplitZstdDataURL(test.in)
+		if out != test.out || zstd != test.zstd {
+			t.Errorf("#%d: want (%q, %v), got (%q, %v)", i, test.out, test.zstd, out, zstd)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// ReportEntry is the machine-readable form of a report.Entry.
+type ReportEntry struct {
+	// Kind is "error", "warning", or "info". Only errors make a config
+	// invalid.
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Path is the JSON pointer of the offending value.
+	Path   string `json:"path"`
+	Line   int64  `json:"line,omitempty"`
+	Column int64  `json:"column,omitempty"`
+}
+
+// ReportEntries converts the entries of r to their machine-readable form.
+func ReportEntries(r report.Report) []ReportEntry {
+	entries := make([]ReportEntry, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		entry := ReportEntry{
+			Kind:    e.Kind.String(),
+			Message: e.Message,
+			Path:    JSONPointer(e.Context),
+		}
+		if e.Marker.StartP != nil {
+			entry.Line, entry.Column = e.Marker.Start()
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// JSONPointer formats c as an RFC 6901 JSON pointer.
+func JSONPointer(c path.ContextPath) string {
+	var b strings.Builder
+	for _, e := range c.Path {
+		s := fmt.Sprint(e)
+		s = strings.Replace(s, "~", "~0", -1)
+		s = strings.Replace(s, "/", "~1", -1)
+		b.WriteString("/" + s)
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/coreos/vcontext/tree"
+)
+
+func TestJSONPointer(t *testing.T) {
+	tests := []struct {
+		in  path.ContextPath
+		out string
+	}{
+		{path.New("json"), ""},
+		{path.New("json", "storage", "files", 3), "/storage/files/3"},
+		{path.New("json", "a/b", "c~d"), "/a~1b/c~0d"},
+	}
+
+	for i, test := range tests {
+		if out := JSONPointer(test.in); out != test.out {
+			t.Errorf("#%d: want %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestReportEntries(t *testing.T) {
+	in := report.Report{
+		Entries: []report.Entry{
+			{
+				Kind:    report.Error,
+				Message: "bad",
+				Context: path.New("json", "storage", "files", 0, "path"),
+				Marker:  tree.Marker{StartP: &tree.Pos{Line: 3, Column: 14}},
+			},
+			{
+				Kind:    report.Warn,
+				Message: "odd",
+				Context: path.New("json", "systemd"),
+			},
+			{
+				Kind:    report.Info,
+				Message: "fyi",
+			},
+		},
+	}
+	expected := []ReportEntry{
+		{Kind: "error", Message: "bad", Path: "/storage/files/0/path", Line: 3, Column: 14},
+		{Kind: "warning", Message: "odd", Path: "/systemd"},
+		{Kind: "info", Message: "fyi", Path: ""},
+	}
+
+	if out := ReportEntries(in); !reflect.DeepEqual(expected, out) {
+		t.Errorf("want %+v, got %+v", expected, out)
+	}
+	if out := ReportEntries(report.Report{}); out == nil || len(out) != 0 {
+		t.Errorf("want empty list, got %+v", out)
+	}
+}
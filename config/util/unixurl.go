@@ -0,0 +1,35 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SplitUnixSocketURL splits an http+unix URL, e.g.
+// "http+unix:/run/provision.sock:/config", into the absolute path of the
+// socket and the path to request over it. ok is false if u isn't of that
+// form.
+func SplitUnixSocketURL(u url.URL) (socket string, path string, ok bool) {
+	if u.Scheme != "http+unix" || u.Opaque != "" || u.Host != "" {
+		return "", "", false
+	}
+	parts := strings.SplitN(u.Path, ":", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") || !strings.HasPrefix(parts[1], "/") {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
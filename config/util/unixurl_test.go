@@ -0,0 +1,48 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSplitUnixSocketURL(t *testing.T) {
+	tests := []struct {
+		in     string
+		socket string
+		path   string
+		ok     bool
+	}{
+		{"http+unix:/run/provision.sock:/config", "/run/provision.sock", "/config", true},
+		{"http+unix:/run/provision.sock:/v1/config?role=worker", "/run/provision.sock", "/v1/config", true},
+		{"http+unix:/run/provision.sock", "", "", false},
+		{"http+unix:run/provision.sock:/config", "", "", false},
+		{"http+unix:/run/provision.sock:config", "", "", false},
+		{"http+unix://host/run/provision.sock:/config", "", "", false},
+		{"http:/run/provision.sock:/config", "", "", false},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		socket, path, ok := SplitUnixSocketURL(*u)
+		if socket != test.socket || path != test.path || ok != test.ok {
+			t.Errorf("#%d: want (%q, %q, %v), got (%q, %q, %v)", i, test.socket, test.path, test.ok, socket, path, ok)
+		}
+	}
+}
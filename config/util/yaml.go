@@ -0,0 +1,86 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/coreos/vcontext/tree"
+	"gopkg.in/yaml.v2"
+)
+
+// YAMLToJSON converts a config written in YAML to the equivalent JSON, so it
+// can be parsed and validated like any other config. Duplicate keys are
+// rejected, as are keys which aren't strings.
+func YAMLToJSON(rawConfig []byte) ([]byte, report.Report, error) {
+	r := report.Report{}
+	var doc interface{}
+	if err := yaml.UnmarshalStrict(rawConfig, &doc); err != nil {
+		r.AddOnError(path.ContextPath{Tag: "yaml"}, err)
+		return nil, r, errors.ErrInvalid
+	}
+	doc = jsonCompatible(doc, path.ContextPath{Tag: "yaml"}, &r)
+	if r.IsFatal() {
+		return nil, r, errors.ErrInvalid
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, r, err
+	}
+	return out, r, nil
+}
+
+// jsonCompatible replaces the map[interface{}]interface{} values produced by
+// the yaml decoder with map[string]interface{}, which encoding/json requires.
+func jsonCompatible(v interface{}, c path.ContextPath, r *report.Report) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			key, ok := k.(string)
+			if !ok {
+				// copy the path since Append may share its backing array
+				// with the paths of later siblings
+				p := append([]interface{}{}, c.Path...)
+				r.AddOnError(path.New(c.Tag, append(p, fmt.Sprint(k))...), errors.ErrYAMLKeyNotString)
+				continue
+			}
+			m[key] = jsonCompatible(v, c.Append(key), r)
+		}
+		return m
+	case []interface{}:
+		for i, v := range t {
+			t[i] = jsonCompatible(v, c.Append(i), r)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// StripMarkers removes the line and column numbers from the entries of r.
+// Reports on configs converted from YAML would otherwise point into the
+// generated JSON rather than the YAML the user wrote.
+func StripMarkers(r report.Report) report.Report {
+	for i := range r.Entries {
+		r.Entries[i].Marker = tree.Marker{}
+	}
+	return r
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+		err error
+	}{
+		{
+			in:  "ignition:\n  version: 3.0.0\n",
+			out: `{"ignition":{"version":"3.0.0"}}`,
+		},
+		{
+			in:  "storage:\n  files:\n    - path: /etc/motd\n      mode: 0644\n",
+			out: `{"storage":{"files":[{"mode":420,"path":"/etc/motd"}]}}`,
+		},
+		{
+			in:  "ignition: {}\nignition: {}\n",
+			err: errors.ErrInvalid,
+		},
+		{
+			in:  "storage:\n  1: foo\n",
+			err: errors.ErrInvalid,
+		},
+		{
+			in:  "ignition: [\n",
+			err: errors.ErrInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		out, r, err := YAMLToJSON([]byte(test.in))
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v, report: %v", i, test.err, err, r)
+		}
+		if string(out) != test.out {
+			t.Errorf("#%d: want %s, got %s", i, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2_3
+
+import (
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v2_3/types"
+	"github.com/coreos/ignition/v2/config/validate"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/coreos/vcontext/report"
+)
+
+// Parse parses a spec 2.2.0 or 2.3.0 config into a types.Config struct. The
+// config is not validated, since it is only meant to be translated to spec 3
+// and validated there. The report contains any keys the types don't describe.
+func Parse(rawConfig []byte) (types.Config, report.Report, error) {
+	if isEmpty(rawConfig) {
+		return types.Config{}, report.Report{}, errors.ErrEmpty
+	}
+
+	var config types.Config
+	if rpt, err := util.HandleParseErrors(rawConfig, &config); err != nil {
+		return types.Config{}, rpt, err
+	}
+
+	version, err := semver.NewVersion(config.Ignition.Version)
+
+	if err != nil || (*version != types.MaxVersion && *version != types.MinVersion) {
+		return types.Config{}, report.Report{}, errors.ErrUnknownVersion
+	}
+
+	return config, validate.ValidateUnusedKeysWithContext(config, rawConfig), nil
+}
+
+func isEmpty(userdata []byte) bool {
+	return len(userdata) == 0
+}
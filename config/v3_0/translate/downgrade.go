@@ -0,0 +1,287 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3_0
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/translate"
+	"github.com/coreos/ignition/v2/config/v3_0/types"
+	exp_types "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func downgradeFilesystem(old exp_types.Filesystem) (ret types.Filesystem) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Device, &ret.Device)
+	tr.Translate(&old.Format, &ret.Format)
+	tr.Translate(&old.Label, &ret.Label)
+	tr.Translate(&old.Options, &ret.Options)
+	tr.Translate(&old.Path, &ret.Path)
+	tr.Translate(&old.UUID, &ret.UUID)
+	tr.Translate(&old.WipeFilesystem, &ret.WipeFilesystem)
+	return
+}
+
+func downgradeVerification(old exp_types.Verification) (ret types.Verification) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Hash, &ret.Hash)
+	return
+}
+
+func downgradeConfigReference(old exp_types.ConfigReference) (ret types.ConfigReference) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradeVerification)
+	tr.Translate(&old.Source, &ret.Source)
+	tr.Translate(&old.Verification, &ret.Verification)
+	return
+}
+
+func downgradeSecurity(old exp_types.Security) (ret types.Security) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradeVerification)
+	tr.Translate(&old.TLS, &ret.TLS)
+	return
+}
+
+func downgradeIgnition(old exp_types.Ignition) (ret types.Ignition) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradeConfigReference)
+	tr.AddCustomTranslator(downgradeSecurity)
+	tr.Translate(&old.Config, &ret.Config)
+	tr.Translate(&old.Security, &ret.Security)
+	tr.Translate(&old.Timeouts, &ret.Timeouts)
+	ret.Version = types.MaxVersion.String()
+	return
+}
+
+func downgradeNode(old exp_types.Node) (ret types.Node) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Group, &ret.Group)
+	tr.Translate(&old.Overwrite, &ret.Overwrite)
+	tr.Translate(&old.Path, &ret.Path)
+	tr.Translate(&old.User, &ret.User)
+	return
+}
+
+func downgradeLinkEmbedded1(old exp_types.LinkEmbedded1) (ret types.LinkEmbedded1) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Hard, &ret.Hard)
+	tr.Translate(&old.Target, &ret.Target)
+	return
+}
+
+func downgradeFileContents(old exp_types.FileContents) (ret types.FileContents) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradeVerification)
+	tr.Translate(&old.Compression, &ret.Compression)
+	tr.Translate(&old.Source, &ret.Source)
+	tr.Translate(&old.Verification, &ret.Verification)
+	return
+}
+
+func downgradeFileEmbedded1(old exp_types.FileEmbedded1) (ret types.FileEmbedded1) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradeFileContents)
+	tr.Translate(&old.Append, &ret.Append)
+	tr.Translate(&old.Contents, &ret.Contents)
+	tr.Translate(&old.Mode, &ret.Mode)
+	return
+}
+
+func downgradeDirectoryEmbedded1(old exp_types.DirectoryEmbedded1) (ret types.DirectoryEmbedded1) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Mode, &ret.Mode)
+	return
+}
+
+func downgradePartition(old exp_types.Partition) (ret types.Partition) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.GUID, &ret.GUID)
+	tr.Translate(&old.Label, &ret.Label)
+	tr.Translate(&old.Number, &ret.Number)
+	tr.Translate(&old.ShouldExist, &ret.ShouldExist)
+	tr.Translate(&old.SizeMiB, &ret.SizeMiB)
+	tr.Translate(&old.StartMiB, &ret.StartMiB)
+	tr.Translate(&old.TypeGUID, &ret.TypeGUID)
+	tr.Translate(&old.WipePartitionEntry, &ret.WipePartitionEntry)
+	return
+}
+
+func downgradeDisk(old exp_types.Disk) (ret types.Disk) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradePartition)
+	tr.Translate(&old.Device, &ret.Device)
+	tr.Translate(&old.Partitions, &ret.Partitions)
+	tr.Translate(&old.WipeTable, &ret.WipeTable)
+	return
+}
+
+func downgradeRaid(old exp_types.Raid) (ret types.Raid) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Devices, &ret.Devices)
+	tr.Translate(&old.Level, &ret.Level)
+	tr.Translate(&old.Name, &ret.Name)
+	tr.Translate(&old.Options, &ret.Options)
+	tr.Translate(&old.Spares, &ret.Spares)
+	return
+}
+
+func downgradePasswdUser(old exp_types.PasswdUser) (ret types.PasswdUser) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Gecos, &ret.Gecos)
+	tr.Translate(&old.Groups, &ret.Groups)
+	tr.Translate(&old.HomeDir, &ret.HomeDir)
+	tr.Translate(&old.Name, &ret.Name)
+	tr.Translate(&old.NoCreateHome, &ret.NoCreateHome)
+	tr.Translate(&old.NoLogInit, &ret.NoLogInit)
+	tr.Translate(&old.NoUserGroup, &ret.NoUserGroup)
+	tr.Translate(&old.PasswordHash, &ret.PasswordHash)
+	tr.Translate(&old.PrimaryGroup, &ret.PrimaryGroup)
+	tr.Translate(&old.SSHAuthorizedKeys, &ret.SSHAuthorizedKeys)
+	tr.Translate(&old.Shell, &ret.Shell)
+	tr.Translate(&old.System, &ret.System)
+	tr.Translate(&old.UID, &ret.UID)
+	return
+}
+
+func downgradePasswd(old exp_types.Passwd) (ret types.Passwd) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradePasswdUser)
+	tr.Translate(&old.Groups, &ret.Groups)
+	tr.Translate(&old.Users, &ret.Users)
+	return
+}
+
+func downgradeStorage(old exp_types.Storage) (ret types.Storage) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradeFilesystem)
+	tr.AddCustomTranslator(downgradeNode)
+	tr.AddCustomTranslator(downgradeLinkEmbedded1)
+	tr.AddCustomTranslator(downgradeFileContents)
+	tr.AddCustomTranslator(downgradeFileEmbedded1)
+	tr.AddCustomTranslator(downgradeDirectoryEmbedded1)
+	tr.AddCustomTranslator(downgradeDisk)
+	tr.AddCustomTranslator(downgradeRaid)
+	tr.Translate(&old.Directories, &ret.Directories)
+	tr.Translate(&old.Disks, &ret.Disks)
+	tr.Translate(&old.Files, &ret.Files)
+	tr.Translate(&old.Filesystems, &ret.Filesystems)
+	tr.Translate(&old.Links, &ret.Links)
+	tr.Translate(&old.Raid, &ret.Raid)
+	return
+}
+
+func downgradeUnit(old exp_types.Unit) (ret types.Unit) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Contents, &ret.Contents)
+	tr.Translate(&old.Dropins, &ret.Dropins)
+	tr.Translate(&old.Enabled, &ret.Enabled)
+	tr.Translate(&old.Mask, &ret.Mask)
+	tr.Translate(&old.Name, &ret.Name)
+	return
+}
+
+// Downgrade translates a 3.1.0-experimental config to 3.0.0. Every field
+// which is set but can't be represented in 3.0.0 is dropped and reported as
+// an error, so callers can decide whether the loss is acceptable.
+func Downgrade(old exp_types.Config) (ret types.Config, r report.Report) {
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(downgradeIgnition)
+	tr.AddCustomTranslator(downgradePasswd)
+	tr.AddCustomTranslator(downgradeStorage)
+	tr.AddCustomTranslator(downgradeUnit)
+	tr.Translate(&old.Ignition, &ret.Ignition)
+	tr.Translate(&old.Passwd, &ret.Passwd)
+	tr.Translate(&old.Storage, &ret.Storage)
+	tr.Translate(&old.Systemd, &ret.Systemd)
+	reportLostFields(reflect.ValueOf(old), reflect.TypeOf(ret), path.New("json"), &r)
+	return
+}
+
+// reportLostFields walks v alongside the type it was translated to and
+// reports every non-empty field of v which has no counterpart in t.
+func reportLostFields(v reflect.Value, t reflect.Type, c path.ContextPath, r *report.Report) {
+	if v.Kind() != t.Kind() {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			reportLostFields(v.Elem(), t.Elem(), c, r)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			reportLostFields(v.Index(i), t.Elem(), c.Append(i), r)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			fc := c
+			if !field.Anonymous {
+				fc = c.Append(strings.Split(field.Tag.Get("json"), ",")[0])
+			}
+			tf, ok := t.FieldByName(field.Name)
+			if !ok {
+				if !isZero(v.Field(i)) {
+					// copy the path since Append may share its backing
+					// array with the paths of later siblings
+					lost := path.New(fc.Tag, append([]interface{}{}, fc.Path...)...)
+					r.AddOnError(lost, errors.ErrDowngradeUnrepresentable)
+				}
+				continue
+			}
+			reportLostFields(v.Field(i), tf.Type, fc, r)
+		}
+	}
+}
+
+// isZero treats empty slices and structs without any set fields as unset,
+// since they don't carry anything which would be lost.
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice:
+		return v.Len() == 0
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZero(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3_0
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_0/types"
+	exp_types "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDowngrade(t *testing.T) {
+	tests := []struct {
+		in     exp_types.Config
+		out    types.Config
+		report report.Report
+	}{
+		{
+			in: exp_types.Config{
+				Ignition: exp_types.Ignition{
+					Version: "3.1.0-experimental",
+					Timeouts: exp_types.Timeouts{
+						HTTPTotal: util.IntToPtr(30),
+					},
+				},
+				Storage: exp_types.Storage{
+					Disks: []exp_types.Disk{
+						{
+							Device:     "/dev/sda",
+							Partitions: []exp_types.Partition{{Label: util.StrToPtr("data"), SizeMiB: util.IntToPtr(512)}},
+						},
+					},
+					Files: []exp_types.File{
+						{
+							Node: exp_types.Node{Path: "/etc/motd"},
+							FileEmbedded1: exp_types.FileEmbedded1{
+								Contents: exp_types.FileContents{Source: util.StrToPtr("data:,hello")},
+								Mode:     util.IntToPtr(0644),
+							},
+						},
+					},
+				},
+			},
+			out: types.Config{
+				Ignition: types.Ignition{
+					Version: "3.0.0",
+					Timeouts: types.Timeouts{
+						HTTPTotal: util.IntToPtr(30),
+					},
+				},
+				Storage: types.Storage{
+					Disks: []types.Disk{
+						{
+							Device:     "/dev/sda",
+							Partitions: []types.Partition{{Label: util.StrToPtr("data"), SizeMiB: util.IntToPtr(512)}},
+						},
+					},
+					Files: []types.File{
+						{
+							Node: types.Node{Path: "/etc/motd"},
+							FileEmbedded1: types.FileEmbedded1{
+								Contents: types.FileContents{Source: util.StrToPtr("data:,hello")},
+								Mode:     util.IntToPtr(0644),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			in: exp_types.Config{
+				Ignition: exp_types.Ignition{
+					Version: "3.1.0-experimental",
+					Config: exp_types.IgnitionConfig{
+						Merge: []exp_types.ConfigReference{
+							{
+								Source:       util.StrToPtr("https://example.com/overlay.ign"),
+								ListPolicies: []exp_types.ListPolicy{{List: "storage.files", Policy: "replace"}},
+								Verification: exp_types.Verification{Checksums: exp_types.VerificationChecksums{
+									Source: util.StrToPtr("https://example.com/SHA256SUMS"),
+									Name:   util.StrToPtr("overlay.ign"),
+								}},
+							},
+						},
+					},
+					Proxy: exp_types.Proxy{HTTPProxy: util.StrToPtr("http://proxy.example.com")},
+					Security: exp_types.Security{
+						HTTP: exp_types.HTTP{
+							Credentials: []exp_types.HTTPCredential{{Host: "mirror.example.com", Token: util.StrToPtr("t0ken")}},
+						},
+						Signature: exp_types.Signature{Keys: []string{"-----BEGIN PUBLIC KEY-----"}},
+						Vault: exp_types.Vault{
+							Address: util.StrToPtr("https://vault.example.com:8200"),
+							Token:   util.StrToPtr("s.token"),
+						},
+					},
+				},
+				MachineID: exp_types.MachineID{Policy: util.StrToPtr("firstboot")},
+				Passwd: exp_types.Passwd{
+					Users: []exp_types.PasswdUser{{Name: "core", Existing: util.BoolToPtr(true)}},
+				},
+				Security: exp_types.SystemSecurity{
+					Fips:                util.BoolToPtr(true),
+					TrustedCertificates: []exp_types.TrustedCertificate{{Name: "corp-root", Source: util.StrToPtr("https://example.com/root.pem")}},
+				},
+				Selinux: exp_types.Selinux{
+					Modules: []exp_types.SelinuxModule{{Name: "my-app", Source: util.StrToPtr("https://example.com/my-app.pp")}},
+				},
+				Storage: exp_types.Storage{
+					Audit:    util.BoolToPtr(true),
+					Rollback: util.BoolToPtr(true),
+					Disks: []exp_types.Disk{
+						{
+							Device:     "/dev/sda",
+							Partitions: []exp_types.Partition{{Number: 1, Assert: util.BoolToPtr(true)}},
+						},
+					},
+					Files: []exp_types.File{
+						{
+							Node:          exp_types.Node{Path: "/etc/motd"},
+							FileEmbedded1: exp_types.FileEmbedded1{Mtime: util.StrToPtr("2020-04-01T12:00:00Z")},
+						},
+						{
+							Node: exp_types.Node{Path: "/etc/hosts"},
+							FileEmbedded1: exp_types.FileEmbedded1{
+								Edits: []exp_types.FileEdit{{Regex: "^foo$", Line: util.StrToPtr("bar")}},
+								// empty sections don't lose anything
+								Append: []exp_types.FileContents{},
+							},
+						},
+					},
+				},
+				Time: exp_types.Time{Servers: []exp_types.TimeSource{"time.example.com"}},
+				Containers: exp_types.Containers{
+					Images: []exp_types.ContainerImage{{Name: "quay.io/example/app:latest"}},
+				},
+				OS:       exp_types.OS{Packages: []string{"vim-enhanced"}},
+				NextBoot: exp_types.NextBoot{Source: util.StrToPtr("https://example.com/disk.ign")},
+			},
+			out: types.Config{
+				Ignition: types.Ignition{
+					Version: "3.0.0",
+					Config: types.IgnitionConfig{
+						Merge: []types.ConfigReference{{Source: util.StrToPtr("https://example.com/overlay.ign")}},
+					},
+				},
+				Passwd: types.Passwd{
+					Users: []types.PasswdUser{{Name: "core"}},
+				},
+				Storage: types.Storage{
+					Disks: []types.Disk{
+						{
+							Device:     "/dev/sda",
+							Partitions: []types.Partition{{Number: 1}},
+						},
+					},
+					Files: []types.File{
+						{
+							Node: types.Node{Path: "/etc/motd"},
+						},
+						{
+							Node: types.Node{Path: "/etc/hosts"},
+							FileEmbedded1: types.FileEmbedded1{
+								Append: []types.FileContents{},
+							},
+						},
+					},
+				},
+			},
+			report: report.Report{
+				Entries: []report.Entry{
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "containers"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "ignition", "config", "merge", 0, "listPolicies"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "ignition", "config", "merge", 0, "verification", "checksums"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "ignition", "proxy"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "ignition", "security", "http"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "ignition", "security", "signature"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "ignition", "security", "vault"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "machineId"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "nextBoot"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "os"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "passwd", "users", 0, "existing"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "security"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "selinux"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "storage", "audit"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "storage", "disks", 0, "partitions", 0, "assert"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "storage", "files", 0, "mtime"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "storage", "files", 1, "edits"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "storage", "rollback"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrDowngradeUnrepresentable.Error(),
+						Context: path.New("json", "time"),
+					},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		cfg, r := Downgrade(test.in)
+		assert.Equal(t, test.out, cfg, "#%d: bad config", i)
+		assert.Equal(t, test.report, r, "#%d: bad report", i)
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3_0
+
+import (
+	"path"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	old_types "github.com/coreos/ignition/v2/config/v2_3/types"
+	"github.com/coreos/ignition/v2/config/v3_0/types"
+
+	cpath "github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/vincent-petithory/dataurl"
+)
+
+const (
+	rootFilesystem = "root"
+	networkdDir    = "/etc/systemd/network"
+
+	// spec 2 partitions are measured in sectors
+	sectorsPerMiB = 2048
+)
+
+// Translate translates a spec 2.2.0 or 2.3.0 config to spec 3.0.0. Settings
+// which can't be represented in spec 3 are reported as errors. Settings whose
+// meaning may have changed are reported as warnings.
+func Translate(old old_types.Config) (ret types.Config, r report.Report) {
+	c := cpath.New("json")
+	ret.Ignition = translateIgnition(old.Ignition)
+	ret.Passwd, r = translatePasswd(old.Passwd, c.Append("passwd"))
+	var rpt report.Report
+	ret.Storage, rpt = translateStorage(old.Storage, c.Append("storage"))
+	r.Merge(rpt)
+	ret.Systemd, rpt = translateSystemd(old.Systemd, c.Append("systemd"))
+	r.Merge(rpt)
+	files, rpt := translateNetworkd(old.Networkd, c.Append("networkd"))
+	r.Merge(rpt)
+	ret.Storage.Files = append(ret.Storage.Files, files...)
+	return
+}
+
+func translateConfigReference(old old_types.ConfigReference) types.ConfigReference {
+	return types.ConfigReference{
+		Source:       util.StrToPtr(old.Source),
+		Verification: types.Verification{Hash: old.Verification.Hash},
+	}
+}
+
+func translateIgnition(old old_types.Ignition) (ret types.Ignition) {
+	ret.Version = types.MaxVersion.String()
+	for _, ref := range old.Config.Append {
+		ret.Config.Merge = append(ret.Config.Merge, translateConfigReference(ref))
+	}
+	if old.Config.Replace != nil {
+		ret.Config.Replace = translateConfigReference(*old.Config.Replace)
+	}
+	for _, ca := range old.Security.TLS.CertificateAuthorities {
+		ret.Security.TLS.CertificateAuthorities = append(ret.Security.TLS.CertificateAuthorities, types.CaReference{
+			Source:       ca.Source,
+			Verification: types.Verification{Hash: ca.Verification.Hash},
+		})
+	}
+	ret.Timeouts.HTTPResponseHeaders = old.Timeouts.HTTPResponseHeaders
+	ret.Timeouts.HTTPTotal = old.Timeouts.HTTPTotal
+	return
+}
+
+func translatePasswd(old old_types.Passwd, c cpath.ContextPath) (ret types.Passwd, r report.Report) {
+	for i, u := range old.Users {
+		user := types.PasswdUser{
+			Name:         u.Name,
+			PasswordHash: u.PasswordHash,
+			UID:          u.UID,
+			Gecos:        strToPtrNonEmpty(u.Gecos),
+			HomeDir:      strToPtrNonEmpty(u.HomeDir),
+			NoCreateHome: boolToPtrTrue(u.NoCreateHome),
+			PrimaryGroup: strToPtrNonEmpty(u.PrimaryGroup),
+			NoUserGroup:  boolToPtrTrue(u.NoUserGroup),
+			NoLogInit:    boolToPtrTrue(u.NoLogInit),
+			Shell:        strToPtrNonEmpty(u.Shell),
+			System:       boolToPtrTrue(u.System),
+		}
+		for _, key := range u.SSHAuthorizedKeys {
+			user.SSHAuthorizedKeys = append(user.SSHAuthorizedKeys, types.SSHAuthorizedKey(key))
+		}
+		for _, group := range u.Groups {
+			user.Groups = append(user.Groups, types.Group(group))
+		}
+		if u.Create != nil {
+			r.AddOnWarn(c.Append("users", i, "create"), errors.ErrTranslateCreate)
+			if user.UID == nil {
+				user.UID = u.Create.UID
+			}
+			if user.Gecos == nil {
+				user.Gecos = strToPtrNonEmpty(u.Create.Gecos)
+			}
+			if user.HomeDir == nil {
+				user.HomeDir = strToPtrNonEmpty(u.Create.HomeDir)
+			}
+			if user.NoCreateHome == nil {
+				user.NoCreateHome = boolToPtrTrue(u.Create.NoCreateHome)
+			}
+			if user.PrimaryGroup == nil {
+				user.PrimaryGroup = strToPtrNonEmpty(u.Create.PrimaryGroup)
+			}
+			if user.NoUserGroup == nil {
+				user.NoUserGroup = boolToPtrTrue(u.Create.NoUserGroup)
+			}
+			if user.NoLogInit == nil {
+				user.NoLogInit = boolToPtrTrue(u.Create.NoLogInit)
+			}
+			if user.Shell == nil {
+				user.Shell = strToPtrNonEmpty(u.Create.Shell)
+			}
+			if user.System == nil {
+				user.System = boolToPtrTrue(u.Create.System)
+			}
+			if len(user.Groups) == 0 {
+				for _, group := range u.Create.Groups {
+					user.Groups = append(user.Groups, types.Group(group))
+				}
+			}
+		}
+		ret.Users = append(ret.Users, user)
+	}
+	for _, g := range old.Groups {
+		ret.Groups = append(ret.Groups, types.PasswdGroup{
+			Name:         g.Name,
+			Gid:          g.Gid,
+			PasswordHash: strToPtrNonEmpty(g.PasswordHash),
+			System:       boolToPtrTrue(g.System),
+		})
+	}
+	return
+}
+
+func translateStorage(old old_types.Storage, c cpath.ContextPath) (ret types.Storage, r report.Report) {
+	for i, d := range old.Disks {
+		disk, rpt := translateDisk(d, c.Append("disks", i))
+		r.Merge(rpt)
+		ret.Disks = append(ret.Disks, disk)
+	}
+	for _, ra := range old.Raid {
+		raid := types.Raid{
+			Name:   ra.Name,
+			Level:  ra.Level,
+			Spares: intToPtrNonZero(ra.Spares),
+		}
+		for _, dev := range ra.Devices {
+			raid.Devices = append(raid.Devices, types.Device(dev))
+		}
+		for _, opt := range ra.Options {
+			raid.Options = append(raid.Options, types.RaidOption(opt))
+		}
+		ret.Raid = append(ret.Raid, raid)
+	}
+	for i, f := range old.Filesystems {
+		fs, ok, rpt := translateFilesystem(f, c.Append("filesystems", i))
+		r.Merge(rpt)
+		if ok {
+			ret.Filesystems = append(ret.Filesystems, fs)
+		}
+	}
+	for i, f := range old.Files {
+		r.AddOnError(c.Append("files", i, "filesystem"), validateNodeFilesystem(f.Node))
+		ret.Files = append(ret.Files, translateFile(f))
+	}
+	for i, d := range old.Directories {
+		r.AddOnError(c.Append("directories", i, "filesystem"), validateNodeFilesystem(d.Node))
+		ret.Directories = append(ret.Directories, types.Directory{
+			Node:               translateNode(d.Node),
+			DirectoryEmbedded1: types.DirectoryEmbedded1{Mode: d.Mode},
+		})
+	}
+	for i, l := range old.Links {
+		r.AddOnError(c.Append("links", i, "filesystem"), validateNodeFilesystem(l.Node))
+		ret.Links = append(ret.Links, types.Link{
+			Node: translateNode(l.Node),
+			LinkEmbedded1: types.LinkEmbedded1{
+				Hard:   boolToPtrTrue(l.Hard),
+				Target: l.Target,
+			},
+		})
+	}
+	return
+}
+
+func translateDisk(old old_types.Disk, c cpath.ContextPath) (ret types.Disk, r report.Report) {
+	ret.Device = old.Device
+	ret.WipeTable = boolToPtrTrue(old.WipeTable)
+	sized := false
+	for i, p := range old.Partitions {
+		if p.Start%sectorsPerMiB != 0 || p.Size%sectorsPerMiB != 0 {
+			r.AddOnError(c.Append("partitions", i), errors.ErrTranslateUnalignedPartition)
+		}
+		sized = sized || p.Start != 0 || p.Size != 0
+		ret.Partitions = append(ret.Partitions, types.Partition{
+			GUID:     strToPtrNonEmpty(p.GUID),
+			Label:    strToPtrNonEmpty(p.Label),
+			Number:   p.Number,
+			SizeMiB:  intToPtrNonZero(p.Size / sectorsPerMiB),
+			StartMiB: intToPtrNonZero(p.Start / sectorsPerMiB),
+			TypeGUID: strToPtrNonEmpty(p.TypeGUID),
+		})
+	}
+	if sized {
+		r.AddOnWarn(c.Append("partitions"), errors.ErrTranslateSectorSize)
+	}
+	return
+}
+
+// translateFilesystem translates a filesystem with a mount section. The bool
+// is false if there was nothing to translate.
+func translateFilesystem(old old_types.Filesystem, c cpath.ContextPath) (ret types.Filesystem, ok bool, r report.Report) {
+	if old.Path != nil {
+		r.AddOnError(c.Append("path"), errors.ErrTranslateFilesystemPath)
+		return
+	}
+	if old.Mount == nil {
+		return
+	}
+	m := old.Mount
+	ret.Device = m.Device
+	ret.Format = strToPtrNonEmpty(m.Format)
+	ret.Label = m.Label
+	ret.UUID = m.UUID
+	ret.WipeFilesystem = boolToPtrTrue(m.WipeFilesystem)
+	for _, opt := range m.Options {
+		ret.Options = append(ret.Options, types.FilesystemOption(opt))
+	}
+	if m.Create != nil {
+		r.AddOnWarn(c.Append("mount", "create"), errors.ErrTranslateCreate)
+		if m.Create.Force {
+			ret.WipeFilesystem = util.BoolToPtr(true)
+		}
+		for _, opt := range m.Create.Options {
+			ret.Options = append(ret.Options, types.FilesystemOption(opt))
+		}
+	}
+	return ret, true, r
+}
+
+// validateNodeFilesystem checks that the node can be translated. Spec 3 nodes
+// are always relative to the root filesystem.
+func validateNodeFilesystem(old old_types.Node) error {
+	if old.Filesystem != rootFilesystem {
+		return errors.ErrTranslateNonRootFilesystem
+	}
+	return nil
+}
+
+func translateNode(old old_types.Node) (ret types.Node) {
+	ret.Path = old.Path
+	ret.Overwrite = old.Overwrite
+	if old.User != nil {
+		ret.User = types.NodeUser{ID: old.User.ID, Name: strToPtrNonEmpty(old.User.Name)}
+	}
+	if old.Group != nil {
+		ret.Group = types.NodeGroup{ID: old.Group.ID, Name: strToPtrNonEmpty(old.Group.Name)}
+	}
+	return
+}
+
+func translateFile(old old_types.File) (ret types.File) {
+	ret.Node = translateNode(old.Node)
+	ret.Mode = old.Mode
+	contents := types.FileContents{
+		Compression:  strToPtrNonEmpty(old.Contents.Compression),
+		Source:       util.StrToPtr(old.Contents.Source),
+		Verification: types.Verification{Hash: old.Contents.Verification.Hash},
+	}
+	if old.Append {
+		ret.Append = []types.FileContents{contents}
+		ret.Overwrite = nil
+		return
+	}
+	// spec 2 files replace existing files and are empty if they have no
+	// source, unless overwrite is false
+	if old.Contents.Source == "" {
+		contents.Source = util.StrToPtr("data:,")
+	}
+	ret.Contents = contents
+	if ret.Overwrite == nil {
+		ret.Overwrite = util.BoolToPtr(true)
+	}
+	return
+}
+
+func translateSystemd(old old_types.Systemd, c cpath.ContextPath) (ret types.Systemd, r report.Report) {
+	for i, u := range old.Units {
+		unit := types.Unit{
+			Name:     u.Name,
+			Contents: strToPtrNonEmpty(u.Contents),
+			Enabled:  u.Enabled,
+			Mask:     boolToPtrTrue(u.Mask),
+		}
+		if u.Enable {
+			r.AddOnWarn(c.Append("units", i, "enable"), errors.ErrTranslateEnable)
+			if unit.Enabled == nil {
+				unit.Enabled = util.BoolToPtr(true)
+			}
+		}
+		for _, d := range u.Dropins {
+			unit.Dropins = append(unit.Dropins, types.Dropin{
+				Name:     d.Name,
+				Contents: strToPtrNonEmpty(d.Contents),
+			})
+		}
+		ret.Units = append(ret.Units, unit)
+	}
+	return
+}
+
+// translateNetworkd translates networkd units, which spec 3 doesn't have,
+// into the files the spec 2 networkd stage would have written.
+func translateNetworkd(old old_types.Networkd, c cpath.ContextPath) (ret []types.File, r report.Report) {
+	for i, u := range old.Units {
+		r.AddOnInfo(c.Append("units", i), errors.ErrTranslateNetworkdUnit)
+		ret = append(ret, networkdFile(path.Join(networkdDir, u.Name), u.Contents))
+		for _, d := range u.Dropins {
+			ret = append(ret, networkdFile(path.Join(networkdDir, u.Name+".d", d.Name), d.Contents))
+		}
+	}
+	return
+}
+
+func networkdFile(path, contents string) types.File {
+	return types.File{
+		Node: types.Node{
+			Path:      path,
+			Overwrite: util.BoolToPtr(true),
+		},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.FileContents{
+				Source: util.StrToPtr(dataurl.EncodeBytes([]byte(contents))),
+			},
+			Mode: util.IntToPtr(0644),
+		},
+	}
+}
+
+func strToPtrNonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func boolToPtrTrue(b bool) *bool {
+	if !b {
+		return nil
+	}
+	return &b
+}
+
+func intToPtrNonZero(i int) *int {
+	if i == 0 {
+		return nil
+	}
+	return &i
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3_0
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	old_types "github.com/coreos/ignition/v2/config/v2_3/types"
+	"github.com/coreos/ignition/v2/config/v3_0/types"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		in     old_types.Config
+		out    types.Config
+		report report.Report
+	}{
+		{
+			in: old_types.Config{
+				Ignition: old_types.Ignition{
+					Version: "2.2.0",
+					Config: old_types.IgnitionConfig{
+						Append: []old_types.ConfigReference{{Source: "https://example.com/child.ign"}},
+					},
+				},
+			},
+			out: types.Config{
+				Ignition: types.Ignition{
+					Version: "3.0.0",
+					Config: types.IgnitionConfig{
+						Merge: []types.ConfigReference{{Source: util.StrToPtr("https://example.com/child.ign")}},
+					},
+				},
+			},
+		},
+		{
+			in: old_types.Config{
+				Storage: old_types.Storage{
+					Files: []old_types.File{
+						{
+							Node: old_types.Node{Filesystem: "root", Path: "/etc/motd"},
+							FileEmbedded1: old_types.FileEmbedded1{
+								Contents: old_types.FileContents{Source: "data:,hello"},
+								Mode:     util.IntToPtr(0644),
+							},
+						},
+						{
+							Node: old_types.Node{Filesystem: "root", Path: "/etc/empty"},
+						},
+						{
+							Node: old_types.Node{Filesystem: "root", Path: "/etc/profile"},
+							FileEmbedded1: old_types.FileEmbedded1{
+								Append:   true,
+								Contents: old_types.FileContents{Source: "data:,export%20FOO"},
+							},
+						},
+					},
+				},
+			},
+			out: types.Config{
+				Ignition: types.Ignition{Version: "3.0.0"},
+				Storage: types.Storage{
+					Files: []types.File{
+						{
+							Node: types.Node{Path: "/etc/motd", Overwrite: util.BoolToPtr(true)},
+							FileEmbedded1: types.FileEmbedded1{
+								Contents: types.FileContents{Source: util.StrToPtr("data:,hello")},
+								Mode:     util.IntToPtr(0644),
+							},
+						},
+						{
+							Node: types.Node{Path: "/etc/empty", Overwrite: util.BoolToPtr(true)},
+							FileEmbedded1: types.FileEmbedded1{
+								Contents: types.FileContents{Source: util.StrToPtr("data:,")},
+							},
+						},
+						{
+							Node: types.Node{Path: "/etc/profile"},
+							FileEmbedded1: types.FileEmbedded1{
+								Append: []types.FileContents{{Source: util.StrToPtr("data:,export%20FOO")}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			in: old_types.Config{
+				Storage: old_types.Storage{
+					Disks: []old_types.Disk{
+						{
+							Device: "/dev/sda",
+							Partitions: []old_types.Partition{
+								{Label: "data", Number: 1, Start: 2048, Size: 4096},
+								{Label: "odd", Number: 2, Start: 6145},
+							},
+						},
+					},
+					Filesystems: []old_types.Filesystem{
+						{
+							Name: "data",
+							Mount: &old_types.Mount{
+								Device: "/dev/sda1",
+								Format: "xfs",
+								Create: &old_types.Create{Force: true},
+							},
+						},
+						{
+							Name: "oem",
+							Path: util.StrToPtr("/usr/share/oem"),
+						},
+					},
+					Directories: []old_types.Directory{
+						{Node: old_types.Node{Filesystem: "data", Path: "/foo"}},
+					},
+				},
+			},
+			out: types.Config{
+				Ignition: types.Ignition{Version: "3.0.0"},
+				Storage: types.Storage{
+					Disks: []types.Disk{
+						{
+							Device: "/dev/sda",
+							Partitions: []types.Partition{
+								{Label: util.StrToPtr("data"), Number: 1, StartMiB: util.IntToPtr(1), SizeMiB: util.IntToPtr(2)},
+								{Label: util.StrToPtr("odd"), Number: 2, StartMiB: util.IntToPtr(3)},
+							},
+						},
+					},
+					Filesystems: []types.Filesystem{
+						{
+							Device:         "/dev/sda1",
+							Format:         util.StrToPtr("xfs"),
+							WipeFilesystem: util.BoolToPtr(true),
+						},
+					},
+					Directories: []types.Directory{
+						{Node: types.Node{Path: "/foo"}},
+					},
+				},
+			},
+			report: report.Report{
+				Entries: []report.Entry{
+					{
+						Kind:    report.Error,
+						Message: errors.ErrTranslateUnalignedPartition.Error(),
+						Context: path.New("json", "storage", "disks", 0, "partitions", 1),
+					},
+					{
+						Kind:    report.Warn,
+						Message: errors.ErrTranslateSectorSize.Error(),
+						Context: path.New("json", "storage", "disks", 0, "partitions"),
+					},
+					{
+						Kind:    report.Warn,
+						Message: errors.ErrTranslateCreate.Error(),
+						Context: path.New("json", "storage", "filesystems", 0, "mount", "create"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrTranslateFilesystemPath.Error(),
+						Context: path.New("json", "storage", "filesystems", 1, "path"),
+					},
+					{
+						Kind:    report.Error,
+						Message: errors.ErrTranslateNonRootFilesystem.Error(),
+						Context: path.New("json", "storage", "directories", 0, "filesystem"),
+					},
+				},
+			},
+		},
+		{
+			in: old_types.Config{
+				Systemd: old_types.Systemd{
+					Units: []old_types.Unit{{Name: "foo.service", Contents: "[Unit]", Enable: true}},
+				},
+				Networkd: old_types.Networkd{
+					Units: []old_types.Networkdunit{
+						{
+							Name:     "00-eth0.network",
+							Contents: "[Match]\nName=eth0\n",
+							Dropins:  []old_types.NetworkdDropin{{Name: "mtu.conf", Contents: "[Link]\nMTUBytes=9000\n"}},
+						},
+					},
+				},
+			},
+			out: types.Config{
+				Ignition: types.Ignition{Version: "3.0.0"},
+				Storage: types.Storage{
+					Files: []types.File{
+						networkdFile("/etc/systemd/network/00-eth0.network", "[Match]\nName=eth0\n"),
+						networkdFile("/etc/systemd/network/00-eth0.network.d/mtu.conf", "[Link]\nMTUBytes=9000\n"),
+					},
+				},
+				Systemd: types.Systemd{
+					Units: []types.Unit{{Name: "foo.service", Contents: util.StrToPtr("[Unit]"), Enabled: util.BoolToPtr(true)}},
+				},
+			},
+			report: report.Report{
+				Entries: []report.Entry{
+					{
+						Kind:    report.Warn,
+						Message: errors.ErrTranslateEnable.Error(),
+						Context: path.New("json", "systemd", "units", 0, "enable"),
+					},
+					{
+						Kind:    report.Info,
+						Message: errors.ErrTranslateNetworkdUnit.Error(),
+						Context: path.New("json", "networkd", "units", 0),
+					},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		cfg, r := Translate(test.in)
+		assert.Equal(t, test.out, cfg, "#%d: bad config", i)
+		assert.Equal(t, test.report, r, "#%d: bad report", i)
+	}
+}
@@ -15,12 +15,12 @@
 package types
 
 import (
-	"path/filepath"
+	"path"
 	"strings"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 
-	"github.com/coreos/vcontext/path"
+	cpath "github.com/coreos/vcontext/path"
 	"github.com/coreos/vcontext/report"
 )
 
@@ -32,7 +32,7 @@ func (s Storage) MergedKeys() map[string]string {
 	}
 }
 
-func (s Storage) Validate(c path.ContextPath) (r report.Report) {
+func (s Storage) Validate(c cpath.ContextPath) (r report.Report) {
 	for i, d := range s.Directories {
 		for _, l := range s.Links {
 			if strings.HasPrefix(d.Path, l.Path+"/") {
@@ -56,9 +56,9 @@ func (s Storage) Validate(c path.ContextPath) (r report.Report) {
 		if l1.Hard == nil || !*l1.Hard {
 			continue
 		}
-		target := filepath.Clean(l1.Target)
-		if !filepath.IsAbs(target) {
-			target = filepath.Join(l1.Path, l1.Target)
+		target := path.Clean(l1.Target)
+		if !path.IsAbs(target) {
+			target = path.Join(l1.Path, l1.Target)
 		}
 		for _, d := range s.Directories {
 			if target == d.Path {
@@ -36,6 +36,20 @@ func Merge(parent, child types.Config) types.Config {
 	return res
 }
 
+// MergeWithPolicies is like Merge, but the lists named by policies with the
+// "replace" policy are replaced by the child's lists instead of being merged.
+func MergeWithPolicies(parent, child types.Config, policies []types.ListPolicy) types.Config {
+	replaced := map[string]bool{}
+	for _, p := range policies {
+		if p.Policy == "replace" {
+			replaced[p.List] = true
+		}
+	}
+
+	vRes := merge.MergeStructReplacing(reflect.ValueOf(parent), reflect.ValueOf(child), replaced)
+	return vRes.Interface().(types.Config)
+}
+
 // Parse parses the raw config into a types.Config struct and generates a report of any
 // errors, warnings, info, and deprecations it encountered
 func Parse(rawConfig []byte) (types.Config, report.Report, error) {
@@ -33,9 +33,35 @@ func translateFilesystem(old old_types.Filesystem) (ret types.Filesystem) {
 	return
 }
 
+func translateVerification(old old_types.Verification) (ret types.Verification) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Hash, &ret.Hash)
+	return
+}
+
+func translateConfigReference(old old_types.ConfigReference) (ret types.ConfigReference) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translateVerification)
+	tr.Translate(&old.Source, &ret.Source)
+	tr.Translate(&old.Verification, &ret.Verification)
+	return
+}
+
+func translateSecurity(old old_types.Security) (ret types.Security) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translateVerification)
+	tr.Translate(&old.TLS, &ret.TLS)
+	return
+}
+
 func translateIgnition(old old_types.Ignition) (ret types.Ignition) {
 	// use a new translator so we don't recurse infintitely
 	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translateConfigReference)
+	tr.AddCustomTranslator(translateSecurity)
 	tr.Translate(&old.Config, &ret.Config)
 	tr.Translate(&old.Security, &ret.Security)
 	tr.Translate(&old.Timeouts, &ret.Timeouts)
@@ -43,10 +69,153 @@ func translateIgnition(old old_types.Ignition) (ret types.Ignition) {
 	return
 }
 
+func translateNode(old old_types.Node) (ret types.Node) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Group, &ret.Group)
+	tr.Translate(&old.Overwrite, &ret.Overwrite)
+	tr.Translate(&old.Path, &ret.Path)
+	tr.Translate(&old.User, &ret.User)
+	return
+}
+
+func translateLinkEmbedded1(old old_types.LinkEmbedded1) (ret types.LinkEmbedded1) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Hard, &ret.Hard)
+	tr.Translate(&old.Target, &ret.Target)
+	return
+}
+
+func translateFileContents(old old_types.FileContents) (ret types.FileContents) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translateVerification)
+	tr.Translate(&old.Compression, &ret.Compression)
+	tr.Translate(&old.Source, &ret.Source)
+	tr.Translate(&old.Verification, &ret.Verification)
+	return
+}
+
+func translateFileEmbedded1(old old_types.FileEmbedded1) (ret types.FileEmbedded1) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translateFileContents)
+	tr.Translate(&old.Append, &ret.Append)
+	tr.Translate(&old.Contents, &ret.Contents)
+	tr.Translate(&old.Mode, &ret.Mode)
+	return
+}
+
+func translateDirectoryEmbedded1(old old_types.DirectoryEmbedded1) (ret types.DirectoryEmbedded1) {
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Mode, &ret.Mode)
+	return
+}
+
+func translatePartition(old old_types.Partition) (ret types.Partition) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.GUID, &ret.GUID)
+	tr.Translate(&old.Label, &ret.Label)
+	tr.Translate(&old.Number, &ret.Number)
+	tr.Translate(&old.ShouldExist, &ret.ShouldExist)
+	tr.Translate(&old.SizeMiB, &ret.SizeMiB)
+	tr.Translate(&old.StartMiB, &ret.StartMiB)
+	tr.Translate(&old.TypeGUID, &ret.TypeGUID)
+	tr.Translate(&old.WipePartitionEntry, &ret.WipePartitionEntry)
+	return
+}
+
+func translateDisk(old old_types.Disk) (ret types.Disk) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translatePartition)
+	tr.Translate(&old.Device, &ret.Device)
+	tr.Translate(&old.Partitions, &ret.Partitions)
+	tr.Translate(&old.WipeTable, &ret.WipeTable)
+	return
+}
+
+func translateRaid(old old_types.Raid) (ret types.Raid) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Devices, &ret.Devices)
+	tr.Translate(&old.Level, &ret.Level)
+	tr.Translate(&old.Name, &ret.Name)
+	tr.Translate(&old.Options, &ret.Options)
+	tr.Translate(&old.Spares, &ret.Spares)
+	return
+}
+
+func translatePasswdUser(old old_types.PasswdUser) (ret types.PasswdUser) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Gecos, &ret.Gecos)
+	tr.Translate(&old.Groups, &ret.Groups)
+	tr.Translate(&old.HomeDir, &ret.HomeDir)
+	tr.Translate(&old.Name, &ret.Name)
+	tr.Translate(&old.NoCreateHome, &ret.NoCreateHome)
+	tr.Translate(&old.NoLogInit, &ret.NoLogInit)
+	tr.Translate(&old.NoUserGroup, &ret.NoUserGroup)
+	tr.Translate(&old.PasswordHash, &ret.PasswordHash)
+	tr.Translate(&old.PrimaryGroup, &ret.PrimaryGroup)
+	tr.Translate(&old.SSHAuthorizedKeys, &ret.SSHAuthorizedKeys)
+	tr.Translate(&old.Shell, &ret.Shell)
+	tr.Translate(&old.System, &ret.System)
+	tr.Translate(&old.UID, &ret.UID)
+	return
+}
+
+func translatePasswd(old old_types.Passwd) (ret types.Passwd) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translatePasswdUser)
+	tr.Translate(&old.Groups, &ret.Groups)
+	tr.Translate(&old.Users, &ret.Users)
+	return
+}
+
+func translateStorage(old old_types.Storage) (ret types.Storage) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.AddCustomTranslator(translateFilesystem)
+	tr.AddCustomTranslator(translateNode)
+	tr.AddCustomTranslator(translateLinkEmbedded1)
+	tr.AddCustomTranslator(translateFileContents)
+	tr.AddCustomTranslator(translateFileEmbedded1)
+	tr.AddCustomTranslator(translateDirectoryEmbedded1)
+	tr.AddCustomTranslator(translateDisk)
+	tr.AddCustomTranslator(translateRaid)
+	tr.Translate(&old.Directories, &ret.Directories)
+	tr.Translate(&old.Disks, &ret.Disks)
+	tr.Translate(&old.Files, &ret.Files)
+	tr.Translate(&old.Filesystems, &ret.Filesystems)
+	tr.Translate(&old.Links, &ret.Links)
+	tr.Translate(&old.Raid, &ret.Raid)
+	return
+}
+
+func translateUnit(old old_types.Unit) (ret types.Unit) {
+	// use a new translator so we don't recurse infintitely
+	tr := translate.NewTranslator()
+	tr.Translate(&old.Contents, &ret.Contents)
+	tr.Translate(&old.Dropins, &ret.Dropins)
+	tr.Translate(&old.Enabled, &ret.Enabled)
+	tr.Translate(&old.Mask, &ret.Mask)
+	tr.Translate(&old.Name, &ret.Name)
+	return
+}
+
 func Translate(old old_types.Config) (ret types.Config) {
 	tr := translate.NewTranslator()
 	tr.AddCustomTranslator(translateIgnition)
-	tr.AddCustomTranslator(translateFilesystem)
-	tr.Translate(&old, &ret)
+	tr.AddCustomTranslator(translatePasswd)
+	tr.AddCustomTranslator(translateStorage)
+	tr.AddCustomTranslator(translateUnit)
+	tr.Translate(&old.Ignition, &ret.Ignition)
+	tr.Translate(&old.Passwd, &ret.Passwd)
+	tr.Translate(&old.Storage, &ret.Storage)
+	tr.Translate(&old.Systemd, &ret.Systemd)
 	return
 }
@@ -15,7 +15,15 @@
 package types
 
 import (
+	"fmt"
+	"path"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
 	"github.com/coreos/go-semver/semver"
+	cpath "github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
 var (
@@ -25,3 +33,167 @@ var (
 		PreRelease: "experimental",
 	}
 )
+
+// Validate reports systemd units and drop-ins which are written to the same
+// path as a file, directory, or link in storage, nodes which would be
+// overwritten by security.fips, and nodes owned by users or groups which
+// aren't declared in passwd.
+func (cfg Config) Validate(c cpath.ContextPath) (r report.Report) {
+	r.Merge(cfg.validateUnitConflicts(c))
+	r.Merge(cfg.validateFipsConflicts(c))
+	r.Merge(cfg.validateMachineIDConflicts(c))
+	r.Merge(cfg.validateOSUpdateConflicts(c))
+	r.Merge(cfg.validateHostCertificateConflicts(c))
+	r.Merge(cfg.validateNextBootConflicts(c))
+	r.Merge(cfg.validateNodeOwners(c))
+	return
+}
+
+func (cfg Config) validateUnitConflicts(c cpath.ContextPath) (r report.Report) {
+	nodes := map[string]cpath.ContextPath{}
+	for i, d := range cfg.Storage.Directories {
+		nodes[path.Clean(d.Path)] = c.Append("storage", "directories", i)
+	}
+	for i, f := range cfg.Storage.Files {
+		nodes[path.Clean(f.Path)] = c.Append("storage", "files", i)
+	}
+	for i, l := range cfg.Storage.Links {
+		nodes[path.Clean(l.Path)] = c.Append("storage", "links", i)
+	}
+
+	for i, u := range cfg.Systemd.Units {
+		unitPath := path.Join("/etc/systemd/system", u.Name)
+		writesUnit := (u.Contents != nil && *u.Contents != "") || (u.Mask != nil && *u.Mask)
+		if node, ok := nodes[unitPath]; ok && writesUnit {
+			r.AddOnError(c.Append("systemd", "units", i), conflictError(errors.ErrUnitConflictsWithNode, node))
+		}
+		for j, d := range u.Dropins {
+			if d.Contents == nil || *d.Contents == "" {
+				continue
+			}
+			if node, ok := nodes[path.Join(unitPath+".d", d.Name)]; ok {
+				r.AddOnError(c.Append("systemd", "units", i, "dropins", j), conflictError(errors.ErrDropinConflictsWithNode, node))
+			}
+		}
+	}
+	for i, u := range cfg.Containers.Units {
+		if node, ok := nodes[u.Path()]; ok {
+			r.AddOnError(c.Append("containers", "units", i), conflictError(errors.ErrUnitConflictsWithNode, node))
+		}
+	}
+	return
+}
+
+// validateFipsConflicts reports nodes in storage at the paths which enabling
+// FIPS mode writes, since the two would fight over their contents.
+func (cfg Config) validateFipsConflicts(c cpath.ContextPath) (r report.Report) {
+	if cfg.Security.Fips == nil || !*cfg.Security.Fips {
+		return
+	}
+	fipsPaths := map[string]bool{
+		"/etc/crypto-policies/config":     true,
+		"/etc/dracut.conf.d/40-fips.conf": true,
+	}
+	return cfg.validateReservedPaths(c, fipsPaths, errors.ErrFipsConflictsWithNode)
+}
+
+// validateMachineIDConflicts reports a node in storage at /etc/machine-id if
+// machineId writes it.
+func (cfg Config) validateMachineIDConflicts(c cpath.ContextPath) (r report.Report) {
+	if cfg.MachineID.Policy == nil {
+		return
+	}
+	return cfg.validateReservedPaths(c, map[string]bool{"/etc/machine-id": true}, errors.ErrMachineIDConflictsWithNode)
+}
+
+// validateOSUpdateConflicts reports a node in storage at the path of the
+// unit applying the changes in os.
+func (cfg Config) validateOSUpdateConflicts(c cpath.ContextPath) (r report.Report) {
+	if !cfg.OS.HasChanges() {
+		return
+	}
+	unitPath := path.Join("/etc/systemd/system", OSUpdateUnit)
+	return cfg.validateReservedPaths(c, map[string]bool{unitPath: true}, errors.ErrOSUpdateConflictsWithNode)
+}
+
+// validateHostCertificateConflicts reports nodes in storage at the paths to
+// which enrolled certificates and their keys are written.
+func (cfg Config) validateHostCertificateConflicts(c cpath.ContextPath) (r report.Report) {
+	paths := map[string]bool{}
+	for _, h := range cfg.Security.HostCertificates {
+		paths[path.Clean(h.CertificatePath)] = true
+		paths[path.Clean(h.KeyPath)] = true
+	}
+	return cfg.validateReservedPaths(c, paths, errors.ErrHostCertConflictsWithNode)
+}
+
+// validateNextBootConflicts reports nodes in storage at the paths of the
+// next boot's config and of the flag marking that boot as the first.
+func (cfg Config) validateNextBootConflicts(c cpath.ContextPath) (r report.Report) {
+	if cfg.NextBoot.Source == nil {
+		return
+	}
+	paths := map[string]bool{NextBootConfigPath: true, FirstbootFlagPath: true}
+	return cfg.validateReservedPaths(c, paths, errors.ErrNextBootConflictsWithNode)
+}
+
+// validateReservedPaths reports err for each node in storage at one of
+// paths, which another section writes.
+func (cfg Config) validateReservedPaths(c cpath.ContextPath, paths map[string]bool, err error) (r report.Report) {
+	for i, d := range cfg.Storage.Directories {
+		if paths[path.Clean(d.Path)] {
+			r.AddOnError(c.Append("storage", "directories", i), err)
+		}
+	}
+	for i, f := range cfg.Storage.Files {
+		if paths[path.Clean(f.Path)] {
+			r.AddOnError(c.Append("storage", "files", i), err)
+		}
+	}
+	for i, l := range cfg.Storage.Links {
+		if paths[path.Clean(l.Path)] {
+			r.AddOnError(c.Append("storage", "links", i), err)
+		}
+	}
+	return
+}
+
+// validateNodeOwners warns about nodes owned by a user or group name which
+// isn't declared in passwd, since chown will fail unless it already exists
+// in the image. IDs are not checked.
+func (cfg Config) validateNodeOwners(c cpath.ContextPath) (r report.Report) {
+	users := map[string]bool{"root": true}
+	// useradd creates a group named after each user by default
+	groups := map[string]bool{"root": true}
+	for _, u := range cfg.Passwd.Users {
+		users[u.Name] = true
+		groups[u.Name] = true
+	}
+	for _, g := range cfg.Passwd.Groups {
+		groups[g.Name] = true
+	}
+
+	check := func(n Node, c cpath.ContextPath) {
+		if n.User.Name != nil && *n.User.Name != "" && !users[*n.User.Name] {
+			r.AddOnWarn(c.Append("user", "name"), errors.ErrUserNotDeclared)
+		}
+		if n.Group.Name != nil && *n.Group.Name != "" && !groups[*n.Group.Name] {
+			r.AddOnWarn(c.Append("group", "name"), errors.ErrGroupNotDeclared)
+		}
+	}
+	for i, d := range cfg.Storage.Directories {
+		check(d.Node, c.Append("storage", "directories", i))
+	}
+	for i, f := range cfg.Storage.Files {
+		check(f.Node, c.Append("storage", "files", i))
+	}
+	for i, l := range cfg.Storage.Links {
+		check(l.Node, c.Append("storage", "links", i))
+	}
+	return
+}
+
+// conflictError annotates err with the JSON pointer of the conflicting entry.
+func conflictError(err error, conflict cpath.ContextPath) error {
+	return fmt.Errorf("%v (conflicts with %s)", err, util.JSONPointer(conflict))
+}
@@ -0,0 +1,256 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		in  Config
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: Config{
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/etc/systemd/system/foo.service"}}},
+				},
+				Systemd: Systemd{
+					Units: []Unit{{Name: "foo.service", Enabled: util.BoolToPtr(true)}},
+				},
+			},
+			out: nil,
+		},
+		{
+			in: Config{
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/etc/systemd/system/foo.service"}}},
+				},
+				Systemd: Systemd{
+					Units: []Unit{{Name: "foo.service", Contents: util.StrToPtr("[Unit]")}},
+				},
+			},
+			out: conflictError(errors.ErrUnitConflictsWithNode, path.New("", "storage", "files", 0)),
+			at:  path.New("", "systemd", "units", 0),
+		},
+		{
+			in: Config{
+				Storage: Storage{
+					Links: []Link{{Node: Node{Path: "/etc/systemd/system/foo.service"}}},
+				},
+				Systemd: Systemd{
+					Units: []Unit{{Name: "foo.service", Mask: util.BoolToPtr(true)}},
+				},
+			},
+			out: conflictError(errors.ErrUnitConflictsWithNode, path.New("", "storage", "links", 0)),
+			at:  path.New("", "systemd", "units", 0),
+		},
+		{
+			in: Config{
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/etc/systemd/system/foo.service.d/bar.conf"}}},
+				},
+				Systemd: Systemd{
+					Units: []Unit{
+						{
+							Name:    "foo.service",
+							Dropins: []Dropin{{Name: "bar.conf", Contents: util.StrToPtr("[Unit]")}},
+						},
+					},
+				},
+			},
+			out: conflictError(errors.ErrDropinConflictsWithNode, path.New("", "storage", "files", 0)),
+			at:  path.New("", "systemd", "units", 0, "dropins", 0),
+		},
+		{
+			in: Config{
+				Security: SystemSecurity{Fips: util.BoolToPtr(false)},
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/etc/crypto-policies/config"}}},
+				},
+			},
+			out: nil,
+		},
+		{
+			in: Config{
+				Security: SystemSecurity{Fips: util.BoolToPtr(true)},
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/etc/crypto-policies/config"}}},
+				},
+			},
+			out: errors.ErrFipsConflictsWithNode,
+			at:  path.New("", "storage", "files", 0),
+		},
+		{
+			in: Config{
+				Storage: Storage{
+					Links: []Link{{Node: Node{Path: "/etc/machine-id"}}},
+				},
+			},
+			out: nil,
+		},
+		{
+			in: Config{
+				MachineID: MachineID{Policy: util.StrToPtr("firstboot")},
+				Storage: Storage{
+					Links: []Link{{Node: Node{Path: "/etc/machine-id"}}},
+				},
+			},
+			out: errors.ErrMachineIDConflictsWithNode,
+			at:  path.New("", "storage", "links", 0),
+		},
+		{
+			in: Config{
+				Containers: Containers{
+					Units: []ContainerUnit{{Name: "web", Image: "quay.io/example/app"}},
+				},
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/etc/containers/systemd/web.container"}}},
+				},
+			},
+			out: conflictError(errors.ErrUnitConflictsWithNode, path.New("", "storage", "files", 0)),
+			at:  path.New("", "containers", "units", 0),
+		},
+		{
+			in: Config{
+				OS: OS{Packages: []string{"vim-enhanced"}},
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/etc/systemd/system/ignition-os-update.service"}}},
+				},
+			},
+			out: errors.ErrOSUpdateConflictsWithNode,
+			at:  path.New("", "storage", "files", 0),
+		},
+		{
+			in: Config{
+				Security: SystemSecurity{
+					HostCertificates: []HostCertificate{{
+						CertificatePath: "/etc/pki/tls/certs/host.crt",
+						KeyPath:         "/etc/pki/tls/private/host.key",
+					}},
+				},
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/etc/pki/tls/private/host.key"}}},
+				},
+			},
+			out: errors.ErrHostCertConflictsWithNode,
+			at:  path.New("", "storage", "files", 0),
+		},
+		{
+			in: Config{
+				NextBoot: NextBoot{Source: util.StrToPtr("https://example.com/disk.ign")},
+				Storage: Storage{
+					Files: []File{{Node: Node{Path: "/boot/ignition.firstboot"}}},
+				},
+			},
+			out: errors.ErrNextBootConflictsWithNode,
+			at:  path.New("", "storage", "files", 0),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestConfigValidateNodeOwners(t *testing.T) {
+	tests := []struct {
+		in  Config
+		out report.Report
+	}{
+		{
+			in: Config{
+				Passwd: Passwd{
+					Users:  []PasswdUser{{Name: "core"}},
+					Groups: []PasswdGroup{{Name: "wheel"}},
+				},
+				Storage: Storage{
+					Files: []File{
+						{
+							Node: Node{
+								Path:  "/home/core/foo",
+								User:  NodeUser{Name: util.StrToPtr("core")},
+								Group: NodeGroup{Name: util.StrToPtr("core")},
+							},
+						},
+						{
+							Node: Node{
+								Path:  "/etc/foo",
+								User:  NodeUser{Name: util.StrToPtr("root")},
+								Group: NodeGroup{Name: util.StrToPtr("wheel")},
+							},
+						},
+						{
+							Node: Node{
+								Path: "/etc/bar",
+								User: NodeUser{ID: util.IntToPtr(1000)},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			in: Config{
+				Storage: Storage{
+					Directories: []Directory{
+						{
+							Node: Node{
+								Path:  "/srv/app",
+								User:  NodeUser{Name: util.StrToPtr("app")},
+								Group: NodeGroup{Name: util.StrToPtr("app")},
+							},
+						},
+					},
+				},
+			},
+			out: report.Report{
+				Entries: []report.Entry{
+					{
+						Kind:    report.Warn,
+						Message: errors.ErrUserNotDeclared.Error(),
+						Context: path.New("", "storage", "directories", 0, "user", "name"),
+					},
+					{
+						Kind:    report.Warn,
+						Message: errors.ErrGroupNotDeclared.Error(),
+						Context: path.New("", "storage", "directories", 0, "group", "name"),
+					},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.validateNodeOwners(path.New(""))
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	cpath "github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var (
+	// an optional registry, slash-separated path components, and an
+	// optional tag and digest, as in the reference grammar of the
+	// container tools
+	containerImageRegex = regexp.MustCompile(`^` +
+		`([A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*(:[0-9]+)?/)?` +
+		`[a-z0-9]+(([._]|__|-+)[a-z0-9]+)*(/[a-z0-9]+(([._]|__|-+)[a-z0-9]+)*)*` +
+		`(:[A-Za-z0-9_][A-Za-z0-9_.-]{0,127})?` +
+		`(@[A-Za-z][A-Za-z0-9]*([-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?` +
+		`$`)
+	containerUnitNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	containerEnvNameRegex  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	containerVolumeRegex   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+)
+
+// quadletDir is the directory from which podman generates services for
+// container units.
+const quadletDir = "/etc/containers/systemd"
+
+func (a ContainerAuth) Validate(c cpath.ContextPath) (r report.Report) {
+	if a.Verification.Specified() && a.Source == nil {
+		r.AddOnError(c.Append("verification", "hash"), errors.ErrVerificationAndNilSource)
+	}
+	r.AddOnError(c.Append("source"), validateURLNilOK(a.Source))
+	return
+}
+
+func (i ContainerImage) Key() string {
+	return i.Name
+}
+
+func (i ContainerImage) Validate(c cpath.ContextPath) (r report.Report) {
+	if !containerImageRegex.MatchString(i.Name) {
+		r.AddOnError(c.Append("name"), errors.ErrContainerImageInvalid)
+	}
+	return
+}
+
+func (u ContainerUnit) Key() string {
+	return u.Name
+}
+
+// Path returns the path of the quadlet file describing the unit.
+func (u ContainerUnit) Path() string {
+	return path.Join(quadletDir, u.Name+".container")
+}
+
+func (u ContainerUnit) Validate(c cpath.ContextPath) (r report.Report) {
+	if !containerUnitNameRegex.MatchString(u.Name) || strings.HasPrefix(u.Name, ".") {
+		r.AddOnError(c.Append("name"), errors.ErrContainerUnitNameInvalid)
+	}
+	if !containerImageRegex.MatchString(u.Image) {
+		r.AddOnError(c.Append("image"), errors.ErrContainerImageInvalid)
+	}
+	for i, p := range u.Ports {
+		r.AddOnError(c.Append("ports", i), validateContainerPort(p))
+	}
+	for i, v := range u.Volumes {
+		r.AddOnError(c.Append("volumes", i), validateContainerVolume(v))
+	}
+	return
+}
+
+func (e ContainerEnvironment) Key() string {
+	return e.Name
+}
+
+func (e ContainerEnvironment) Validate(c cpath.ContextPath) (r report.Report) {
+	if !containerEnvNameRegex.MatchString(e.Name) {
+		r.AddOnError(c.Append("name"), errors.ErrContainerEnvNameInvalid)
+	}
+	if e.Value != nil && strings.ContainsAny(*e.Value, "\r\n") {
+		r.AddOnError(c.Append("value"), errors.ErrContainerEnvValueInvalid)
+	}
+	return
+}
+
+// validateContainerPort checks a port in podman's --publish format, e.g.
+// 8080:80, 127.0.0.1::53/udp, or [::1]:8443:443.
+func validateContainerPort(p string) error {
+	spec := p
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		switch spec[i+1:] {
+		case "tcp", "udp", "sctp":
+		default:
+			return errors.ErrContainerPortInvalid
+		}
+		spec = spec[:i]
+	}
+	ip := ""
+	hasIP := false
+	if strings.HasPrefix(spec, "[") {
+		i := strings.Index(spec, "]:")
+		if i < 0 {
+			return errors.ErrContainerPortInvalid
+		}
+		ip, spec, hasIP = spec[1:i], spec[i+2:], true
+		if !strings.Contains(ip, ":") {
+			return errors.ErrContainerPortInvalid
+		}
+	}
+	parts := strings.Split(spec, ":")
+	if !hasIP && len(parts) == 3 {
+		ip, parts, hasIP = parts[0], parts[1:], true
+	}
+	if hasIP && net.ParseIP(ip) == nil {
+		return errors.ErrContainerPortInvalid
+	}
+	switch len(parts) {
+	case 1:
+		if hasIP {
+			return errors.ErrContainerPortInvalid
+		}
+	case 2:
+		// the host port may only be left for podman to choose if the
+		// address is given
+		if parts[0] == "" && !hasIP {
+			return errors.ErrContainerPortInvalid
+		}
+		if parts[0] != "" && !validPortRange(parts[0]) {
+			return errors.ErrContainerPortInvalid
+		}
+	default:
+		return errors.ErrContainerPortInvalid
+	}
+	if !validPortRange(parts[len(parts)-1]) {
+		return errors.ErrContainerPortInvalid
+	}
+	return nil
+}
+
+func validPortRange(r string) bool {
+	bounds := strings.SplitN(r, "-", 2)
+	for _, b := range bounds {
+		port, err := strconv.Atoi(b)
+		if err != nil || port < 1 || port > 65535 || strconv.Itoa(port) != b {
+			return false
+		}
+	}
+	return true
+}
+
+// validateContainerVolume checks a volume in podman's --volume format, whose
+// source is a host path or a named volume.
+func validateContainerVolume(v string) error {
+	parts := strings.Split(v, ":")
+	if len(parts) < 2 || len(parts) > 3 || strings.ContainsAny(v, " \t\r\n") {
+		return errors.ErrContainerVolumeInvalid
+	}
+	if !path.IsAbs(parts[0]) && !containerVolumeRegex.MatchString(parts[0]) {
+		return errors.ErrContainerVolumeInvalid
+	}
+	if !path.IsAbs(parts[1]) {
+		return errors.ErrContainerVolumeInvalid
+	}
+	if len(parts) == 3 && parts[2] == "" {
+		return errors.ErrContainerVolumeInvalid
+	}
+	return nil
+}
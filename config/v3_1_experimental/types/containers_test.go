@@ -0,0 +1,187 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestContainerImageValidate(t *testing.T) {
+	tests := []struct {
+		in  string
+		out error
+	}{
+		{"quay.io/example/app:latest", nil},
+		{"registry.example.com:5000/team/app", nil},
+		{"busybox", nil},
+		{"docker.io/library/nginx@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", nil},
+		{"quay.io/example/app:1.0@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", nil},
+		{"", errors.ErrContainerImageInvalid},
+		{"docker://quay.io/example/app", errors.ErrContainerImageInvalid},
+		{"quay.io/Example/app", errors.ErrContainerImageInvalid},
+		{"--src-creds=x quay.io/example/app", errors.ErrContainerImageInvalid},
+		{"quay.io/example/app:", errors.ErrContainerImageInvalid},
+	}
+
+	for i, test := range tests {
+		r := ContainerImage{Name: test.in}.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(path.New("", "name"), test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestContainerAuthValidate(t *testing.T) {
+	tests := []struct {
+		in  ContainerAuth
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: ContainerAuth{},
+		},
+		{
+			in: ContainerAuth{Source: util.StrToPtr("https://example.com/auth.json")},
+		},
+		{
+			in:  ContainerAuth{Source: util.StrToPtr("foo:bar")},
+			at:  path.New("", "source"),
+			out: errors.ErrInvalidScheme,
+		},
+		{
+			in:  ContainerAuth{Verification: Verification{Hash: util.StrToPtr("sha512-0123")}},
+			at:  path.New("", "verification", "hash"),
+			out: errors.ErrVerificationAndNilSource,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestContainerUnitValidate(t *testing.T) {
+	tests := []struct {
+		in  ContainerUnit
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: ContainerUnit{
+				Name:    "web",
+				Image:   "quay.io/example/app:latest",
+				Ports:   []string{"80", "8080:80", "127.0.0.1:8443:443/tcp", "127.0.0.1::53/udp", "[::1]:9000-9001:9000-9001"},
+				Volumes: []string{"/srv/web:/var/www:ro,Z", "cache:/var/cache"},
+			},
+		},
+		{
+			in:  ContainerUnit{Name: "web@1", Image: "quay.io/example/app"},
+			at:  path.New("", "name"),
+			out: errors.ErrContainerUnitNameInvalid,
+		},
+		{
+			in:  ContainerUnit{Name: "web", Image: "docker://quay.io/example/app"},
+			at:  path.New("", "image"),
+			out: errors.ErrContainerImageInvalid,
+		},
+		{
+			in:  ContainerUnit{Name: "web", Image: "quay.io/example/app", Ports: []string{"8080:80/http"}},
+			at:  path.New("", "ports", 0),
+			out: errors.ErrContainerPortInvalid,
+		},
+		{
+			in:  ContainerUnit{Name: "web", Image: "quay.io/example/app", Ports: []string{":80"}},
+			at:  path.New("", "ports", 0),
+			out: errors.ErrContainerPortInvalid,
+		},
+		{
+			in:  ContainerUnit{Name: "web", Image: "quay.io/example/app", Ports: []string{"70000:80"}},
+			at:  path.New("", "ports", 0),
+			out: errors.ErrContainerPortInvalid,
+		},
+		{
+			in:  ContainerUnit{Name: "web", Image: "quay.io/example/app", Ports: []string{"example.com:8080:80"}},
+			at:  path.New("", "ports", 0),
+			out: errors.ErrContainerPortInvalid,
+		},
+		{
+			in:  ContainerUnit{Name: "web", Image: "quay.io/example/app", Volumes: []string{"/srv/web:var/www"}},
+			at:  path.New("", "volumes", 0),
+			out: errors.ErrContainerVolumeInvalid,
+		},
+		{
+			in:  ContainerUnit{Name: "web", Image: "quay.io/example/app", Volumes: []string{"/srv/web"}},
+			at:  path.New("", "volumes", 0),
+			out: errors.ErrContainerVolumeInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestContainerEnvironmentValidate(t *testing.T) {
+	tests := []struct {
+		in  ContainerEnvironment
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: ContainerEnvironment{Name: "LOG_LEVEL", Value: util.StrToPtr("debug \"verbose\" 100%")},
+		},
+		{
+			in: ContainerEnvironment{Name: "_EMPTY"},
+		},
+		{
+			in:  ContainerEnvironment{Name: "1VAR"},
+			at:  path.New("", "name"),
+			out: errors.ErrContainerEnvNameInvalid,
+		},
+		{
+			in:  ContainerEnvironment{Name: "VAR", Value: util.StrToPtr("a\nb")},
+			at:  path.New("", "value"),
+			out: errors.ErrContainerEnvValueInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
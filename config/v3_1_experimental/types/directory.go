@@ -23,8 +23,8 @@ import (
 
 func (d Directory) Validate(c path.ContextPath) (r report.Report) {
 	r.Merge(d.Node.Validate(c))
-	r.AddOnError(c.Append("mode"), validateMode(d.Mode))
-	if d.Mode == nil {
+	r.Merge(validateModePolicy(c, d.Mode, d.SymbolicMode, d.AllowWorldWritable, true))
+	if d.Mode == nil && d.SymbolicMode == nil {
 		r.AddOnWarn(c.Append("mode"), errors.ErrDirectoryPermissionsUnset)
 	}
 	return
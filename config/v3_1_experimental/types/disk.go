@@ -31,6 +31,28 @@ func (n Disk) Validate(c path.ContextPath) (r report.Report) {
 		return
 	}
 	r.AddOnError(c.Append("device"), validatePath(n.Device))
+	if n.WaitTimeout != nil && *n.WaitTimeout < 0 {
+		r.AddOnError(c.Append("waitTimeout"), errors.ErrInvalidWaitTimeout)
+	}
+	r.AddOnError(c.Append("allocationStrategy"), n.validateAllocationStrategy())
+	if n.AllocationStrategy != nil && *n.AllocationStrategy == "exact-start" {
+		for i, p := range n.Partitions {
+			if p.StartMiB != nil && *p.StartMiB == 0 {
+				r.AddOnError(c.Append("partitions", i, "startMiB"), errors.ErrExactStartWithZeroStart)
+			}
+		}
+	}
+	if n.Assert != nil && *n.Assert {
+		if n.WipeTable != nil && *n.WipeTable {
+			r.AddOnError(c.Append("wipeTable"), errors.ErrAssertWithWipeTable)
+		}
+		// asserting a disk asserts all of its partitions
+		for i, p := range n.Partitions {
+			if p.Assert == nil || !*p.Assert {
+				r.AddOnError(c.Append("partitions", i), p.validateAssert())
+			}
+		}
+	}
 
 	if collides, p := n.partitionNumbersCollide(); collides {
 		r.AddOnError(c.Append("partitions", p), errors.ErrPartitionNumbersCollide)
@@ -47,6 +69,18 @@ func (n Disk) Validate(c path.ContextPath) (r report.Report) {
 	return
 }
 
+func (n Disk) validateAllocationStrategy() error {
+	if n.AllocationStrategy == nil {
+		return nil
+	}
+	switch *n.AllocationStrategy {
+	case "first-fit", "append", "exact-start":
+		return nil
+	default:
+		return errors.ErrInvalidAllocation
+	}
+}
+
 // partitionNumbersCollide returns true if partition numbers in n.Partitions are not unique. It also returns the
 // index of the colliding partition
 func (n Disk) partitionNumbersCollide() (bool, int) {
@@ -0,0 +1,75 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func (e EfiSystemPartition) Validate(c path.ContextPath) (r report.Report) {
+	r.AddOnError(c.Append("path"), e.validatePath())
+	return
+}
+
+func (e EfiSystemPartition) validatePath() error {
+	if util.NilOrEmpty(e.Path) {
+		if e.Archive.Source != nil || len(e.BootEntries) != 0 {
+			return errors.ErrEfiNeedsPath
+		}
+		return nil
+	}
+	return validatePath(*e.Path)
+}
+
+func (ea EfiArchive) Validate(c path.ContextPath) (r report.Report) {
+	r.AddOnError(c.Append("compression"), ea.validateCompression())
+	r.AddOnError(c.Append("verification", "hash"), ea.validateVerification())
+	r.AddOnError(c.Append("source"), validateURLNilOK(ea.Source))
+	return
+}
+
+func (ea EfiArchive) validateCompression() error {
+	if ea.Compression != nil {
+		switch *ea.Compression {
+		case "", "gzip":
+		default:
+			return errors.ErrCompressionInvalid
+		}
+	}
+	return nil
+}
+
+func (ea EfiArchive) validateVerification() error {
+	if ea.Verification.Specified() && ea.Source == nil {
+		return errors.ErrVerificationAndNilSource
+	}
+	return nil
+}
+
+func (b EfiBootEntry) Key() string {
+	return b.Label
+}
+
+func (b EfiBootEntry) Validate(c path.ContextPath) (r report.Report) {
+	if b.Label == "" {
+		r.AddOnError(c.Append("label"), errors.ErrBootEntryLabelRequired)
+	}
+	r.AddOnError(c.Append("loader"), validatePath(b.Loader))
+	return
+}
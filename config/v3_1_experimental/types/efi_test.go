@@ -0,0 +1,104 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestEfiSystemPartitionValidate(t *testing.T) {
+	tests := []struct {
+		in  EfiSystemPartition
+		out error
+	}{
+		{
+			in:  EfiSystemPartition{},
+			out: nil,
+		},
+		{
+			in: EfiSystemPartition{
+				Path:    util.StrToPtr("/boot/efi"),
+				Archive: EfiArchive{Source: util.StrToPtr("https://example.com/esp.tar")},
+			},
+			out: nil,
+		},
+		{
+			in: EfiSystemPartition{
+				Archive: EfiArchive{Source: util.StrToPtr("https://example.com/esp.tar")},
+			},
+			out: errors.ErrEfiNeedsPath,
+		},
+		{
+			in: EfiSystemPartition{
+				BootEntries: []EfiBootEntry{{Label: "Fedora", Loader: "/EFI/fedora/shimx64.efi"}},
+			},
+			out: errors.ErrEfiNeedsPath,
+		},
+		{
+			in: EfiSystemPartition{
+				Path: util.StrToPtr("boot/efi"),
+			},
+			out: errors.ErrPathRelative,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(path.New("", "path"), test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestEfiBootEntryValidate(t *testing.T) {
+	tests := []struct {
+		in  EfiBootEntry
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in:  EfiBootEntry{Label: "Fedora", Loader: "/EFI/fedora/shimx64.efi"},
+			out: nil,
+		},
+		{
+			in:  EfiBootEntry{Loader: "/EFI/fedora/shimx64.efi"},
+			at:  path.New("", "label"),
+			out: errors.ErrBootEntryLabelRequired,
+		},
+		{
+			in:  EfiBootEntry{Label: "Fedora", Loader: "EFI/fedora/shimx64.efi"},
+			at:  path.New("", "loader"),
+			out: errors.ErrPathRelative,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
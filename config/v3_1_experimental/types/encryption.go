@@ -0,0 +1,62 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/base64"
+	"regexp"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var (
+	awsKMSKey = regexp.MustCompile(`^arn:aws[a-z-]*:kms:[a-z0-9-]+:[0-9]+:(key|alias)/.+$`)
+	gcpKMSKey = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+)
+
+// IsSet returns true if any field of the encryption is set.
+func (e Encryption) IsSet() bool {
+	return e.DataKey != nil || e.Key != nil || e.Provider != nil
+}
+
+func (e Encryption) Validate(c path.ContextPath) (r report.Report) {
+	if !e.IsSet() {
+		return
+	}
+	var keyPattern *regexp.Regexp
+	if e.Provider != nil {
+		switch *e.Provider {
+		case "aws":
+			keyPattern = awsKMSKey
+		case "gcp":
+			keyPattern = gcpKMSKey
+		}
+	}
+	if keyPattern == nil {
+		r.AddOnError(c.Append("provider"), errors.ErrEncryptionProviderInvalid)
+	} else if e.Key == nil || !keyPattern.MatchString(*e.Key) {
+		r.AddOnError(c.Append("key"), errors.ErrEncryptionKeyInvalid)
+	}
+	if util.NilOrEmpty(e.DataKey) {
+		r.AddOnError(c.Append("dataKey"), errors.ErrEncryptionDataKeyInvalid)
+	} else if _, err := base64.StdEncoding.DecodeString(*e.DataKey); err != nil {
+		r.AddOnError(c.Append("dataKey"), errors.ErrEncryptionDataKeyInvalid)
+	}
+	return
+}
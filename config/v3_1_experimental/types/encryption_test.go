@@ -0,0 +1,106 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestEncryptionValidate(t *testing.T) {
+	tests := []struct {
+		in  Encryption
+		out report.Report
+	}{
+		{
+			in: Encryption{},
+		},
+		{
+			in: Encryption{
+				Provider: util.StrToPtr("aws"),
+				Key:      util.StrToPtr("arn:aws:kms:us-east-1:123456789012:key/1234abcd-12ab-34cd-56ef-1234567890ab"),
+				DataKey:  util.StrToPtr("AQIDAHg="),
+			},
+		},
+		{
+			in: Encryption{
+				Provider: util.StrToPtr("gcp"),
+				Key:      util.StrToPtr("projects/p/locations/global/keyRings/r/cryptoKeys/k"),
+				DataKey:  util.StrToPtr("CiQA"),
+			},
+		},
+		{
+			in: Encryption{
+				Provider: util.StrToPtr("gcp"),
+				Key:      util.StrToPtr("arn:aws:kms:us-east-1:123456789012:key/1234abcd"),
+				DataKey:  util.StrToPtr("not base64!"),
+			},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrEncryptionKeyInvalid.Error(), Context: path.New("json", "key")},
+				{Kind: report.Error, Message: errors.ErrEncryptionDataKeyInvalid.Error(), Context: path.New("json", "dataKey")},
+			}},
+		},
+		{
+			in: Encryption{Provider: util.StrToPtr("azure")},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrEncryptionProviderInvalid.Error(), Context: path.New("json", "provider")},
+				{Kind: report.Error, Message: errors.ErrEncryptionDataKeyInvalid.Error(), Context: path.New("json", "dataKey")},
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New("json"))
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
+func TestFileContentsValidateEncryption(t *testing.T) {
+	encryption := Encryption{Provider: util.StrToPtr("aws")}
+	tests := []struct {
+		in  FileContents
+		out error
+	}{
+		{
+			in: FileContents{Source: util.StrToPtr("s3://bucket/key")},
+		},
+		{
+			in: FileContents{Source: util.StrToPtr("https://example.com/secret"), Encryption: encryption},
+		},
+		{
+			in:  FileContents{Encryption: encryption},
+			out: errors.ErrEncryptionAndNilSource,
+		},
+		{
+			in:  FileContents{Source: util.StrToPtr("s3://bucket/key"), Encryption: encryption},
+			out: errors.ErrEncryptionS3,
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.validateEncryption()
+		if test.out != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
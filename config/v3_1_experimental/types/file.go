@@ -15,6 +15,12 @@
 package types
 
 import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
 
 	"github.com/coreos/vcontext/path"
@@ -23,14 +29,67 @@ import (
 
 func (f File) Validate(c path.ContextPath) (r report.Report) {
 	r.Merge(f.Node.Validate(c))
-	r.AddOnError(c.Append("mode"), validateMode(f.Mode))
-	if f.Mode == nil && f.Contents.Source != nil {
+	r.Merge(validateModePolicy(c, f.Mode, f.SymbolicMode, f.AllowWorldWritable, false))
+	if f.Mode == nil && f.SymbolicMode == nil && f.Contents.Source != nil {
 		r.AddOnWarn(c.Append("mode"), errors.ErrFilePermissionsUnset)
 	}
 	r.AddOnError(c.Append("overwrite"), f.validateOverwrite())
+	if f.Contents.Marker != nil {
+		r.AddOnError(c.Append("contents", "marker"), errors.ErrMarkerOnContents)
+	}
+	if f.Contents.EndMarker != nil {
+		r.AddOnError(c.Append("contents", "endMarker"), errors.ErrMarkerOnContents)
+	}
+	for i, a := range f.Append {
+		if a.Merge != nil {
+			r.AddOnError(c.Append("append", i, "merge"), errors.ErrMergeOnAppend)
+		}
+		if a.PatchFrom != nil {
+			r.AddOnError(c.Append("append", i, "patchFrom"), errors.ErrPatchFromOnAppend)
+		}
+	}
+	if f.Contents.PatchFrom != nil && *f.Contents.PatchFrom == f.Path && f.Overwrite != nil && *f.Overwrite {
+		r.AddOnError(c.Append("contents", "patchFrom"), errors.ErrPatchFromOverwritten)
+	}
+	if f.Contents.Merge != nil {
+		if f.Contents.Source == nil {
+			r.AddOnWarn(c.Append("contents", "merge"), errors.ErrMergeWithoutSource)
+		} else if f.Overwrite != nil && *f.Overwrite {
+			r.AddOnWarn(c.Append("contents", "merge"), errors.ErrMergeWithOverwrite)
+		}
+	}
+	if f.Backup != nil && *f.Backup && len(f.Edits) == 0 {
+		r.AddOnWarn(c.Append("backup"), errors.ErrBackupWithoutEdits)
+	}
+	r.AddOnError(c.Append("atime"), validateTimestamp(f.Atime))
+	r.AddOnError(c.Append("mtime"), validateTimestamp(f.Mtime))
+	r.AddOnError(c.Append("onFailure"), validateOnFailure(f.OnFailure))
 	return
 }
 
+func validateTimestamp(t *string) error {
+	if t == nil {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, *t); err != nil {
+		return errors.ErrInvalidTimestamp
+	}
+	return nil
+}
+
+// Timestamps returns the access and modification times of the file, or nil
+// for times which aren't specified. It assumes the config has been validated.
+func (f File) Timestamps() (atime, mtime *time.Time) {
+	parse := func(s *string) *time.Time {
+		if s == nil {
+			return nil
+		}
+		t, _ := time.Parse(time.RFC3339, *s)
+		return &t
+	}
+	return parse(f.Atime), parse(f.Mtime)
+}
+
 func (f File) validateOverwrite() error {
 	if f.Overwrite != nil && *f.Overwrite && f.Contents.Source == nil {
 		return errors.ErrOverwriteAndNilSource
@@ -48,9 +107,62 @@ func (fc FileContents) Validate(c path.ContextPath) (r report.Report) {
 	r.AddOnError(c.Append("compression"), fc.validateCompression())
 	r.AddOnError(c.Append("verification", "hash"), fc.validateVerification())
 	r.AddOnError(c.Append("source"), validateURLNilOK(fc.Source))
+	r.Merge(validateMirrors(c.Append("mirrors"), fc.Source, fc.Mirrors, false))
+	r.AddOnError(c.Append("marker"), validateMarker(fc.Marker))
+	r.AddOnError(c.Append("endMarker"), validateMarker(fc.EndMarker))
+	r.AddOnError(c.Append("merge"), fc.validateMerge())
+	r.AddOnError(c.Append("encryption"), fc.validateEncryption())
+	r.AddOnError(c.Append("patchFrom"), fc.validatePatchFrom())
 	return
 }
 
+func (fc FileContents) validatePatchFrom() error {
+	if fc.PatchFrom == nil {
+		return nil
+	}
+	if err := validatePath(*fc.PatchFrom); err != nil {
+		return err
+	}
+	if fc.Source == nil {
+		return errors.ErrPatchFromWithoutSource
+	}
+	if (fc.Compression != nil && *fc.Compression != "") || fc.Encryption.IsSet() {
+		return errors.ErrPatchFromCompressed
+	}
+	return nil
+}
+
+func (fc FileContents) validateEncryption() error {
+	if !fc.Encryption.IsSet() {
+		return nil
+	}
+	if fc.Source == nil {
+		return errors.ErrEncryptionAndNilSource
+	}
+	if u, err := url.Parse(*fc.Source); err == nil && u.Scheme == "s3" {
+		return errors.ErrEncryptionS3
+	}
+	return nil
+}
+
+func (fc FileContents) validateMerge() error {
+	if fc.Merge != nil {
+		switch *fc.Merge {
+		case "ini", "toml", "json":
+		default:
+			return errors.ErrInvalidMerge
+		}
+	}
+	return nil
+}
+
+func validateMarker(m *string) error {
+	if m != nil && strings.ContainsAny(*m, "\r\n") {
+		return errors.ErrMarkerContainsNewline
+	}
+	return nil
+}
+
 func (fc FileContents) validateCompression() error {
 	if fc.Compression != nil {
 		switch *fc.Compression {
@@ -63,8 +175,28 @@ func (fc FileContents) validateCompression() error {
 }
 
 func (fc FileContents) validateVerification() error {
-	if fc.Verification.Hash != nil && fc.Source == nil {
+	if fc.Verification.Specified() && fc.Source == nil {
 		return errors.ErrVerificationAndNilSource
 	}
 	return nil
 }
+
+func (fe FileEdit) Key() string {
+	return fe.Regex
+}
+
+func (fe FileEdit) Validate(c path.ContextPath) (r report.Report) {
+	if _, err := regexp.Compile(fe.Regex); err != nil {
+		r.AddOnError(c.Append("regex"), fmt.Errorf("invalid regex: %v", err))
+	}
+	absent := fe.Absent != nil && *fe.Absent
+	switch {
+	case absent && fe.Line != nil:
+		r.AddOnError(c.Append("line"), errors.ErrEditAbsentWithLine)
+	case !absent && fe.Line == nil:
+		r.AddOnError(c, errors.ErrEditNeedsLineOrAbsent)
+	case fe.Line != nil && strings.ContainsAny(*fe.Line, "\r\n"):
+		r.AddOnError(c.Append("line"), errors.ErrEditLineContainsNewline)
+	}
+	return
+}
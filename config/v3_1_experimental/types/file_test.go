@@ -15,10 +15,14 @@
 package types
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
 func TestFileValidateOverwrite(t *testing.T) {
@@ -115,3 +119,339 @@ func TestFileContentsValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestFileValidateMarkers(t *testing.T) {
+	tests := []struct {
+		in  File
+		out error
+		at  path.ContextPath
+	}{
+		{
+			in: File{
+				Node: Node{Path: "/foo"},
+				FileEmbedded1: FileEmbedded1{
+					Mode: util.IntToPtr(0644),
+					Append: []FileContents{
+						{
+							Source:    util.StrToPtr("data:,hello"),
+							Marker:    util.StrToPtr("# hello"),
+							EndMarker: util.StrToPtr("# goodbye"),
+						},
+					},
+				},
+			},
+		},
+		{
+			in: File{
+				Node: Node{Path: "/foo"},
+				FileEmbedded1: FileEmbedded1{
+					Mode: util.IntToPtr(0644),
+					Contents: FileContents{
+						Source: util.StrToPtr("data:,hello"),
+						Marker: util.StrToPtr("# hello"),
+					},
+				},
+			},
+			out: errors.ErrMarkerOnContents,
+			at:  path.New("", "contents", "marker"),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestValidateMarker(t *testing.T) {
+	tests := []struct {
+		in  *string
+		out error
+	}{
+		{nil, nil},
+		{util.StrToPtr("# managed by ignition"), nil},
+		{util.StrToPtr("two\nlines"), errors.ErrMarkerContainsNewline},
+	}
+
+	for i, test := range tests {
+		if err := validateMarker(test.in); err != test.out {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestValidateTimestamp(t *testing.T) {
+	tests := []struct {
+		in  *string
+		out error
+	}{
+		{nil, nil},
+		{util.StrToPtr("2020-04-01T12:00:00Z"), nil},
+		{util.StrToPtr("2020-04-01T12:00:00.5+02:00"), nil},
+		{util.StrToPtr("2020-04-01"), errors.ErrInvalidTimestamp},
+		{util.StrToPtr("1585742400"), errors.ErrInvalidTimestamp},
+	}
+
+	for i, test := range tests {
+		if err := validateTimestamp(test.in); err != test.out {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestFileEditValidate(t *testing.T) {
+	tests := []struct {
+		in  FileEdit
+		out error
+		at  path.ContextPath
+	}{
+		{
+			in: FileEdit{Regex: "^GRUB_TIMEOUT=", Line: util.StrToPtr("GRUB_TIMEOUT=1")},
+		},
+		{
+			in: FileEdit{Regex: "^GRUB_TIMEOUT=", Absent: util.BoolToPtr(true)},
+		},
+		{
+			in:  FileEdit{Regex: "^GRUB_TIMEOUT="},
+			out: errors.ErrEditNeedsLineOrAbsent,
+			at:  path.New(""),
+		},
+		{
+			in:  FileEdit{Regex: "^GRUB_TIMEOUT=", Line: util.StrToPtr("x"), Absent: util.BoolToPtr(false)},
+			out: nil,
+		},
+		{
+			in:  FileEdit{Regex: "^GRUB_TIMEOUT=", Line: util.StrToPtr("x"), Absent: util.BoolToPtr(true)},
+			out: errors.ErrEditAbsentWithLine,
+			at:  path.New("", "line"),
+		},
+		{
+			in:  FileEdit{Regex: "^GRUB_TIMEOUT=", Line: util.StrToPtr("a\nb")},
+			out: errors.ErrEditLineContainsNewline,
+			at:  path.New("", "line"),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+
+	r := FileEdit{Regex: "(", Absent: util.BoolToPtr(true)}.Validate(path.New(""))
+	if !r.IsFatal() {
+		t.Errorf("invalid regex was accepted: %v", r)
+	}
+}
+
+func TestFileValidateMerge(t *testing.T) {
+	tests := []struct {
+		in   File
+		out  error
+		at   path.ContextPath
+		warn bool
+	}{
+		{
+			in: File{
+				Node: Node{Path: "/foo"},
+				FileEmbedded1: FileEmbedded1{
+					Mode: util.IntToPtr(0644),
+					Contents: FileContents{
+						Source: util.StrToPtr("data:,a=1"),
+						Merge:  util.StrToPtr("ini"),
+					},
+				},
+			},
+		},
+		{
+			in: File{
+				Node: Node{Path: "/foo"},
+				FileEmbedded1: FileEmbedded1{
+					Append: []FileContents{
+						{
+							Source: util.StrToPtr("data:,a=1"),
+							Merge:  util.StrToPtr("ini"),
+						},
+					},
+				},
+			},
+			out: errors.ErrMergeOnAppend,
+			at:  path.New("", "append", 0, "merge"),
+		},
+		{
+			in: File{
+				Node: Node{Path: "/foo"},
+				FileEmbedded1: FileEmbedded1{
+					Contents: FileContents{
+						Merge: util.StrToPtr("json"),
+					},
+				},
+			},
+			out:  errors.ErrMergeWithoutSource,
+			at:   path.New("", "contents", "merge"),
+			warn: true,
+		},
+		{
+			in: File{
+				Node: Node{Path: "/foo", Overwrite: util.BoolToPtr(true)},
+				FileEmbedded1: FileEmbedded1{
+					Mode: util.IntToPtr(0644),
+					Contents: FileContents{
+						Source: util.StrToPtr("data:,{}"),
+						Merge:  util.StrToPtr("json"),
+					},
+				},
+			},
+			out:  errors.ErrMergeWithOverwrite,
+			at:   path.New("", "contents", "merge"),
+			warn: true,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		expected := report.Report{}
+		if test.warn {
+			expected.AddOnWarn(test.at, test.out)
+		} else {
+			expected.AddOnError(test.at, test.out)
+		}
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+
+	r := FileContents{Source: util.StrToPtr("data:,a=1"), Merge: util.StrToPtr("yaml")}.Validate(path.New(""))
+	expected := report.Report{}
+	expected.AddOnError(path.New("", "merge"), errors.ErrInvalidMerge)
+	if !reflect.DeepEqual(expected, r) {
+		t.Errorf("bad report for invalid merge: want %v, got %v", expected, r)
+	}
+}
+
+func TestFileContentsValidateMirrors(t *testing.T) {
+	tests := []struct {
+		in  FileContents
+		at  path.ContextPath
+		out error
+	}{
+		{
+			// mirrors of file contents may use any scheme
+			in: FileContents{Source: util.StrToPtr("tftp://example.com/motd"), Mirrors: []string{"https://mirror.example.com/motd", "s3://bucket/motd"}},
+		},
+		{
+			in:  FileContents{Mirrors: []string{"https://mirror.example.com/motd"}},
+			at:  path.New("", "mirrors"),
+			out: errors.ErrMirrorsWithoutSource,
+		},
+		{
+			in:  FileContents{Source: util.StrToPtr("https://example.com/motd"), Mirrors: []string{"https://mirror.example.com/motd", "bad://"}},
+			at:  path.New("", "mirrors", 1),
+			out: errors.ErrInvalidScheme,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestFileValidatePatchFrom(t *testing.T) {
+	tests := []struct {
+		in  File
+		out error
+		at  path.ContextPath
+	}{
+		{
+			in: File{
+				Node: Node{Path: "/var/image.raw"},
+				FileEmbedded1: FileEmbedded1{
+					Mode: util.IntToPtr(0644),
+					Contents: FileContents{
+						Source:    util.StrToPtr("https://example.com/image.patch"),
+						PatchFrom: util.StrToPtr("/var/image.raw"),
+					},
+				},
+			},
+		},
+		{
+			in: File{
+				Node: Node{Path: "/var/image.raw", Overwrite: util.BoolToPtr(true)},
+				FileEmbedded1: FileEmbedded1{
+					Mode: util.IntToPtr(0644),
+					Contents: FileContents{
+						Source:    util.StrToPtr("https://example.com/image.patch"),
+						PatchFrom: util.StrToPtr("/var/image.raw"),
+					},
+				},
+			},
+			out: errors.ErrPatchFromOverwritten,
+			at:  path.New("", "contents", "patchFrom"),
+		},
+		{
+			in: File{
+				Node: Node{Path: "/var/log.txt"},
+				FileEmbedded1: FileEmbedded1{
+					Append: []FileContents{
+						{
+							Source:    util.StrToPtr("https://example.com/log.patch"),
+							PatchFrom: util.StrToPtr("/var/log.txt"),
+						},
+					},
+				},
+			},
+			out: errors.ErrPatchFromOnAppend,
+			at:  path.New("", "append", 0, "patchFrom"),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestFileContentsValidatePatchFrom(t *testing.T) {
+	tests := []struct {
+		in  FileContents
+		out error
+	}{
+		{
+			in: FileContents{Source: util.StrToPtr("https://example.com/image.patch"), PatchFrom: util.StrToPtr("/var/image-v1.raw")},
+		},
+		{
+			in:  FileContents{PatchFrom: util.StrToPtr("/var/image-v1.raw")},
+			out: errors.ErrPatchFromWithoutSource,
+		},
+		{
+			in:  FileContents{Source: util.StrToPtr("https://example.com/image.patch"), Compression: util.StrToPtr("gzip"), PatchFrom: util.StrToPtr("/var/image-v1.raw")},
+			out: errors.ErrPatchFromCompressed,
+		},
+		{
+			in:  FileContents{Source: util.StrToPtr("https://example.com/image.patch"), PatchFrom: util.StrToPtr("var/image-v1.raw")},
+			out: errors.ErrPathRelative,
+		},
+	}
+
+	for i, test := range tests {
+		if err := test.in.validatePatchFrom(); err != test.out {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package types
 
 import (
+	"regexp"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
 
@@ -38,6 +40,15 @@ func (f Filesystem) Validate(c path.ContextPath) (r report.Report) {
 	r.AddOnError(c.Append("device"), validatePath(f.Device))
 	r.AddOnError(c.Append("format"), f.validateFormat())
 	r.AddOnError(c.Append("label"), f.validateLabel())
+	r.AddOnError(c.Append("uuid"), f.validateUUID())
+	if f.Assert != nil && *f.Assert {
+		if util.NilOrEmpty(f.Format) {
+			r.AddOnError(c.Append("format"), errors.ErrAssertNeedsFormat)
+		}
+		if f.WipeFilesystem != nil && *f.WipeFilesystem {
+			r.AddOnError(c.Append("wipeFilesystem"), errors.ErrAssertWithWipeFilesystem)
+		}
+	}
 	return
 }
 
@@ -63,6 +74,34 @@ func (f Filesystem) validateFormat() error {
 	return nil
 }
 
+var (
+	filesystemUUIDRegex = regexp.MustCompile("^[[:xdigit:]]{8}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{4}-[[:xdigit:]]{12}$")
+	vfatVolumeIDRegex   = regexp.MustCompile("^[[:xdigit:]]{4}-?[[:xdigit:]]{4}$")
+)
+
+// validateUUID checks that the UUID is in a form the mkfs for the format
+// can set. vfat has a 32-bit volume ID rather than a UUID.
+func (f Filesystem) validateUUID() error {
+	if util.NilOrEmpty(f.UUID) {
+		return nil
+	}
+	if util.NilOrEmpty(f.Format) {
+		return errors.ErrUUIDNeedsFormat
+	}
+
+	switch *f.Format {
+	case "vfat":
+		if !vfatVolumeIDRegex.MatchString(*f.UUID) {
+			return errors.ErrVfatInvalidUUID
+		}
+	default:
+		if !filesystemUUIDRegex.MatchString(*f.UUID) {
+			return errors.ErrFilesystemInvalidUUID
+		}
+	}
+	return nil
+}
+
 func (f Filesystem) validateLabel() error {
 	if util.NilOrEmpty(f.Label) {
 		return nil
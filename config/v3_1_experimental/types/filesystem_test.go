@@ -172,3 +172,57 @@ func TestLabelValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestUUIDValidate(t *testing.T) {
+	type in struct {
+		filesystem Filesystem
+	}
+	type out struct {
+		err error
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("ext4"), UUID: nil}},
+			out: out{},
+		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("ext4"), UUID: util.StrToPtr("9d6e42cd-dcef-4177-b4c6-2a0c979e3d82")}},
+			out: out{},
+		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("xfs"), UUID: util.StrToPtr("9D6E42CD-DCEF-4177-B4C6-2A0C979E3D82")}},
+			out: out{},
+		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("ext4"), UUID: util.StrToPtr("9d6e42cd")}},
+			out: out{err: errors.ErrFilesystemInvalidUUID},
+		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("vfat"), UUID: util.StrToPtr("2e24ec82")}},
+			out: out{},
+		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("vfat"), UUID: util.StrToPtr("2E24-EC82")}},
+			out: out{},
+		},
+		{
+			in:  in{filesystem: Filesystem{Format: util.StrToPtr("vfat"), UUID: util.StrToPtr("9d6e42cd-dcef-4177-b4c6-2a0c979e3d82")}},
+			out: out{err: errors.ErrVfatInvalidUUID},
+		},
+		{
+			in:  in{filesystem: Filesystem{UUID: util.StrToPtr("9d6e42cd-dcef-4177-b4c6-2a0c979e3d82")}},
+			out: out{err: errors.ErrUUIDNeedsFormat},
+		},
+	}
+
+	for i, test := range tests {
+		err := test.in.filesystem.validateUUID()
+		if test.out.err != err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out.err, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var (
+	caFingerprintRegex = regexp.MustCompile(`^sha256-[0-9a-fA-F]{64}$`)
+)
+
+func (h HostCertificate) Key() string {
+	return h.CertificatePath
+}
+
+// KeyAlgorithm returns the type of key to generate, which defaults to rsa
+// for SCEP, since few SCEP servers accept other keys, and ecdsa otherwise.
+func (h HostCertificate) KeyAlgorithm() string {
+	if h.KeyType != nil {
+		return *h.KeyType
+	}
+	if h.SCEP.IsSet() {
+		return "rsa"
+	}
+	return "ecdsa"
+}
+
+func (h HostCertificate) Validate(c path.ContextPath) (r report.Report) {
+	r.AddOnError(c.Append("certificatePath"), validatePath(h.CertificatePath))
+	r.AddOnError(c.Append("keyPath"), validatePath(h.KeyPath))
+	if h.KeyPath == h.CertificatePath {
+		r.AddOnError(c.Append("keyPath"), errors.ErrHostCertSamePath)
+	}
+	if len(h.DNSNames) == 0 {
+		r.AddOnError(c.Append("dnsNames"), errors.ErrHostCertNoDNSNames)
+	}
+	for i, name := range h.DNSNames {
+		if !hostnameRegex.MatchString(name) || strings.HasSuffix(name, ".") {
+			r.AddOnError(c.Append("dnsNames", i), errors.ErrHostCertDNSNameInvalid)
+		}
+	}
+	switch h.KeyAlgorithm() {
+	case "ecdsa":
+		if h.SCEP.IsSet() {
+			r.AddOnError(c.Append("keyType"), errors.ErrHostCertSCEPKeyType)
+		}
+	case "rsa":
+	default:
+		r.AddOnError(c.Append("keyType"), errors.ErrHostCertKeyTypeInvalid)
+	}
+	if h.ACME.IsSet() == h.SCEP.IsSet() {
+		r.AddOnError(c, errors.ErrHostCertProtocolRequired)
+	}
+	return
+}
+
+func (a HostCertificateACME) IsSet() bool {
+	return a.Directory != nil || a.Email != nil
+}
+
+func (a HostCertificateACME) Validate(c path.ContextPath) (r report.Report) {
+	if !a.IsSet() {
+		return
+	}
+	if util.NilOrEmpty(a.Directory) {
+		r.AddOnError(c.Append("directory"), errors.ErrHostCertDirectoryRequired)
+	} else {
+		r.AddOnError(c.Append("directory"), validateHTTPURL(*a.Directory))
+	}
+	return
+}
+
+func (s HostCertificateSCEP) IsSet() bool {
+	return s.URL != nil || s.ChallengePassword != nil || s.CaFingerprint != nil
+}
+
+func (s HostCertificateSCEP) Validate(c path.ContextPath) (r report.Report) {
+	if !s.IsSet() {
+		return
+	}
+	if util.NilOrEmpty(s.URL) {
+		r.AddOnError(c.Append("url"), errors.ErrHostCertURLRequired)
+	} else {
+		r.AddOnError(c.Append("url"), validateHTTPURL(*s.URL))
+	}
+	if s.CaFingerprint == nil {
+		r.AddOnError(c.Append("caFingerprint"), errors.ErrHostCertFingerprintMissing)
+	} else if !caFingerprintRegex.MatchString(*s.CaFingerprint) {
+		r.AddOnError(c.Append("caFingerprint"), errors.ErrHostCertFingerprintInvalid)
+	}
+	return
+}
+
+func validateHTTPURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return errors.ErrInvalidUrl
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.ErrInvalidScheme
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestHostCertificateValidate(t *testing.T) {
+	fingerprint := "sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	acme := HostCertificateACME{Directory: util.StrToPtr("https://ca.example.com/acme/directory")}
+	tests := []struct {
+		in  HostCertificate
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: HostCertificate{
+				CertificatePath: "/etc/pki/tls/certs/host.crt",
+				KeyPath:         "/etc/pki/tls/private/host.key",
+				DNSNames:        []string{"node1.example.com"},
+				ACME:            acme,
+			},
+		},
+		{
+			in: HostCertificate{
+				CertificatePath: "/etc/pki/tls/certs/host.crt",
+				KeyPath:         "/etc/pki/tls/private/host.key",
+				DNSNames:        []string{"node1.example.com"},
+				SCEP: HostCertificateSCEP{
+					URL:               util.StrToPtr("http://scep.example.com/scep"),
+					ChallengePassword: util.StrToPtr("secret"),
+					CaFingerprint:     util.StrToPtr(fingerprint),
+				},
+			},
+		},
+		{
+			in: HostCertificate{
+				CertificatePath: "/etc/pki/tls/certs/host.crt",
+				KeyPath:         "/etc/pki/tls/private/host.key",
+				DNSNames:        []string{"node1.example.com"},
+			},
+			at:  path.New(""),
+			out: errors.ErrHostCertProtocolRequired,
+		},
+		{
+			in: HostCertificate{
+				CertificatePath: "/etc/pki/tls/certs/host.crt",
+				KeyPath:         "/etc/pki/tls/certs/host.crt",
+				DNSNames:        []string{"node1.example.com"},
+				ACME:            acme,
+			},
+			at:  path.New("", "keyPath"),
+			out: errors.ErrHostCertSamePath,
+		},
+		{
+			in: HostCertificate{
+				CertificatePath: "/etc/pki/tls/certs/host.crt",
+				KeyPath:         "/etc/pki/tls/private/host.key",
+				ACME:            acme,
+			},
+			at:  path.New("", "dnsNames"),
+			out: errors.ErrHostCertNoDNSNames,
+		},
+		{
+			in: HostCertificate{
+				CertificatePath: "/etc/pki/tls/certs/host.crt",
+				KeyPath:         "/etc/pki/tls/private/host.key",
+				DNSNames:        []string{"node1.example.com."},
+				ACME:            acme,
+			},
+			at:  path.New("", "dnsNames", 0),
+			out: errors.ErrHostCertDNSNameInvalid,
+		},
+		{
+			in: HostCertificate{
+				CertificatePath: "/etc/pki/tls/certs/host.crt",
+				KeyPath:         "/etc/pki/tls/private/host.key",
+				DNSNames:        []string{"node1.example.com"},
+				KeyType:         util.StrToPtr("ed25519"),
+				ACME:            acme,
+			},
+			at:  path.New("", "keyType"),
+			out: errors.ErrHostCertKeyTypeInvalid,
+		},
+		{
+			in: HostCertificate{
+				CertificatePath: "/etc/pki/tls/certs/host.crt",
+				KeyPath:         "/etc/pki/tls/private/host.key",
+				DNSNames:        []string{"node1.example.com"},
+				KeyType:         util.StrToPtr("ecdsa"),
+				SCEP: HostCertificateSCEP{
+					URL:           util.StrToPtr("http://scep.example.com/scep"),
+					CaFingerprint: util.StrToPtr(fingerprint),
+				},
+			},
+			at:  path.New("", "keyType"),
+			out: errors.ErrHostCertSCEPKeyType,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestHostCertificateACMEValidate(t *testing.T) {
+	tests := []struct {
+		in  HostCertificateACME
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: HostCertificateACME{},
+		},
+		{
+			in: HostCertificateACME{Directory: util.StrToPtr("https://ca.example.com/acme/directory"), Email: util.StrToPtr("ops@example.com")},
+		},
+		{
+			in:  HostCertificateACME{Email: util.StrToPtr("ops@example.com")},
+			at:  path.New("", "directory"),
+			out: errors.ErrHostCertDirectoryRequired,
+		},
+		{
+			in:  HostCertificateACME{Directory: util.StrToPtr("ftp://ca.example.com/")},
+			at:  path.New("", "directory"),
+			out: errors.ErrInvalidScheme,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestHostCertificateSCEPValidate(t *testing.T) {
+	fingerprint := "sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		in  HostCertificateSCEP
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: HostCertificateSCEP{},
+		},
+		{
+			in: HostCertificateSCEP{URL: util.StrToPtr("http://scep.example.com/scep"), CaFingerprint: util.StrToPtr(fingerprint)},
+		},
+		{
+			in:  HostCertificateSCEP{CaFingerprint: util.StrToPtr(fingerprint)},
+			at:  path.New("", "url"),
+			out: errors.ErrHostCertURLRequired,
+		},
+		{
+			in:  HostCertificateSCEP{URL: util.StrToPtr("http://scep.example.com/scep")},
+			at:  path.New("", "caFingerprint"),
+			out: errors.ErrHostCertFingerprintMissing,
+		},
+		{
+			in:  HostCertificateSCEP{URL: util.StrToPtr("http://scep.example.com/scep"), CaFingerprint: util.StrToPtr("md5-0123")},
+			at:  path.New("", "caFingerprint"),
+			out: errors.ErrHostCertFingerprintInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
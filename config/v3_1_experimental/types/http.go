@@ -0,0 +1,63 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func (h HTTPCredential) Key() string {
+	return strings.ToLower(h.Host)
+}
+
+func (h HTTPCredential) Validate(c path.ContextPath) (r report.Report) {
+	if u, err := url.Parse("//" + h.Host); err != nil || u.Host != h.Host || u.Hostname() == "" || u.User != nil {
+		r.AddOnError(c.Append("host"), errors.ErrHTTPCredentialHostInvalid)
+	}
+	switch {
+	case !util.NilOrEmpty(h.Token) && (h.Username != nil || h.Password != nil):
+		r.AddOnError(c, errors.ErrHTTPCredentialMultipleAuth)
+	case h.Password != nil && util.NilOrEmpty(h.Username):
+		r.AddOnError(c.Append("username"), errors.ErrHTTPCredentialUsernameRequired)
+	case util.NilOrEmpty(h.Token) && util.NilOrEmpty(h.Username):
+		r.AddOnError(c, errors.ErrHTTPCredentialAuthRequired)
+	}
+	return
+}
+
+// Matches returns true if the credential applies to requests to host,
+// which may include a port. A credential without a port applies to every
+// port of its host.
+func (h HTTPCredential) Matches(host string) bool {
+	cred, err := url.Parse("//" + h.Host)
+	if err != nil {
+		return false
+	}
+	req, err := url.Parse("//" + host)
+	if err != nil {
+		return false
+	}
+	if cred.Port() != "" {
+		return strings.EqualFold(cred.Host, req.Host)
+	}
+	return strings.EqualFold(cred.Hostname(), req.Hostname())
+}
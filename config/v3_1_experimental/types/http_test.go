@@ -0,0 +1,92 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestHTTPCredentialValidate(t *testing.T) {
+	tests := []struct {
+		in  HTTPCredential
+		out report.Report
+	}{
+		{
+			in: HTTPCredential{Host: "mirror.example.com", Username: util.StrToPtr("core"), Password: util.StrToPtr("s3cret")},
+		},
+		{
+			in: HTTPCredential{Host: "[2001:db8::1]:8080", Token: util.StrToPtr("t0ken")},
+		},
+		{
+			in: HTTPCredential{Host: "https://mirror.example.com", Token: util.StrToPtr("t0ken")},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrHTTPCredentialHostInvalid.Error(), Context: path.New("json", "host")},
+			}},
+		},
+		{
+			in: HTTPCredential{Host: "mirror.example.com"},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrHTTPCredentialAuthRequired.Error(), Context: path.New("json")},
+			}},
+		},
+		{
+			in: HTTPCredential{Host: "mirror.example.com", Username: util.StrToPtr("core"), Token: util.StrToPtr("t0ken")},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrHTTPCredentialMultipleAuth.Error(), Context: path.New("json")},
+			}},
+		},
+		{
+			in: HTTPCredential{Host: "mirror.example.com", Password: util.StrToPtr("s3cret")},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrHTTPCredentialUsernameRequired.Error(), Context: path.New("json", "username")},
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New("json"))
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
+func TestHTTPCredentialMatches(t *testing.T) {
+	tests := []struct {
+		cred string
+		host string
+		out  bool
+	}{
+		{"mirror.example.com", "mirror.example.com", true},
+		{"mirror.example.com", "Mirror.Example.com:8080", true},
+		{"mirror.example.com:8080", "mirror.example.com:8080", true},
+		{"mirror.example.com:8080", "mirror.example.com", false},
+		{"mirror.example.com", "evil.example.com", false},
+		{"[2001:db8::1]", "[2001:db8::1]:443", true},
+	}
+
+	for i, test := range tests {
+		if out := (HTTPCredential{Host: test.cred}).Matches(test.host); out != test.out {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, out)
+		}
+	}
+}
@@ -15,6 +15,9 @@
 package types
 
 import (
+	"reflect"
+	"strings"
+
 	"github.com/coreos/go-semver/semver"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
@@ -32,9 +35,61 @@ func (c ConfigReference) Key() string {
 
 func (cr ConfigReference) Validate(c path.ContextPath) (r report.Report) {
 	r.AddOnError(c.Append("source"), validateURLNilOK(cr.Source))
+	r.Merge(validateMirrors(c.Append("mirrors"), cr.Source, cr.Mirrors, true))
+	return
+}
+
+func (cc ConfigCondition) Validate(c path.ContextPath) (r report.Report) {
+	r.AddOnError(c.Append("diskPresent"), validatePathNilOK(cc.DiskPresent))
+	return
+}
+
+func (ic IgnitionConfig) Validate(c path.ContextPath) (r report.Report) {
+	if len(ic.Replace.ListPolicies) > 0 {
+		r.AddOnWarn(c.Append("replace", "listPolicies"), errors.ErrListPoliciesOnReplace)
+	}
+	return
+}
+
+func (lp ListPolicy) Key() string {
+	return lp.List
+}
+
+func (lp ListPolicy) Validate(c path.ContextPath) (r report.Report) {
+	if !isConfigList(lp.List) {
+		r.AddOnError(c.Append("list"), errors.ErrListPolicyNotList)
+	}
+	switch lp.Policy {
+	case "union", "replace":
+	default:
+		r.AddOnError(c.Append("policy"), errors.ErrInvalidListPolicy)
+	}
 	return
 }
 
+// isConfigList reports whether p is the dotted JSON path of a list in the
+// config which isn't within another list, e.g. "storage.files".
+func isConfigList(p string) bool {
+	t := reflect.TypeOf(Config{})
+	for _, name := range strings.Split(p, ".") {
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+		found := false
+		for i := 0; i < t.NumField(); i++ {
+			if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] == name {
+				t = t.Field(i).Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return t.Kind() == reflect.Slice
+}
+
 func (v Ignition) Semver() (*semver.Version, error) {
 	return semver.NewVersion(v.Version)
 }
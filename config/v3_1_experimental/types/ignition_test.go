@@ -0,0 +1,146 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestListPolicyValidate(t *testing.T) {
+	tests := []struct {
+		in  ListPolicy
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: ListPolicy{List: "storage.files", Policy: "replace"},
+		},
+		{
+			in: ListPolicy{List: "systemd.units", Policy: "union"},
+		},
+		{
+			in:  ListPolicy{List: "storage.files", Policy: "remove"},
+			at:  path.New("", "policy"),
+			out: errors.ErrInvalidListPolicy,
+		},
+		{
+			// not a list
+			in:  ListPolicy{List: "storage", Policy: "replace"},
+			at:  path.New("", "list"),
+			out: errors.ErrListPolicyNotList,
+		},
+		{
+			// lists within lists can't be replaced
+			in:  ListPolicy{List: "storage.files.append", Policy: "replace"},
+			at:  path.New("", "list"),
+			out: errors.ErrListPolicyNotList,
+		},
+		{
+			in:  ListPolicy{List: "storage.nope", Policy: "replace"},
+			at:  path.New("", "list"),
+			out: errors.ErrListPolicyNotList,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestIgnitionConfigValidate(t *testing.T) {
+	in := IgnitionConfig{
+		Replace: ConfigReference{
+			Source:       util.StrToPtr("https://example.com/config.ign"),
+			ListPolicies: []ListPolicy{{List: "storage.files", Policy: "replace"}},
+		},
+	}
+	expected := report.Report{}
+	expected.AddOnWarn(path.New("", "replace", "listPolicies"), errors.ErrListPoliciesOnReplace)
+	if r := in.Validate(path.New("")); !reflect.DeepEqual(expected, r) {
+		t.Errorf("bad report: want %v, got %v", expected, r)
+	}
+}
+
+func TestConfigConditionValidate(t *testing.T) {
+	tests := []struct {
+		in  ConfigCondition
+		out error
+	}{
+		{
+			in: ConfigCondition{Platform: util.StrToPtr("aws"), DiskPresent: util.StrToPtr("/dev/disk/by-id/nvme-foo")},
+		},
+		{
+			in:  ConfigCondition{DiskPresent: util.StrToPtr("dev/sda")},
+			out: errors.ErrPathRelative,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		expected := report.Report{}
+		expected.AddOnError(path.New("", "diskPresent"), test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestConfigReferenceValidate(t *testing.T) {
+	tests := []struct {
+		in  ConfigReference
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: ConfigReference{Source: util.StrToPtr("https://example.com/config.ign"), Mirrors: []string{"http://mirror.example.com/config.ign"}},
+		},
+		{
+			in:  ConfigReference{Mirrors: []string{"https://mirror.example.com/config.ign"}},
+			at:  path.New("", "mirrors"),
+			out: errors.ErrMirrorsWithoutSource,
+		},
+		{
+			in:  ConfigReference{Source: util.StrToPtr("tftp://example.com/config.ign"), Mirrors: []string{"https://mirror.example.com/config.ign"}},
+			at:  path.New("", "mirrors"),
+			out: errors.ErrMirrorScheme,
+		},
+		{
+			in:  ConfigReference{Source: util.StrToPtr("https://example.com/config.ign"), Mirrors: []string{"https://mirror.example.com/config.ign", "s3://bucket/config.ign"}},
+			at:  path.New("", "mirrors", 1),
+			out: errors.ErrMirrorScheme,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
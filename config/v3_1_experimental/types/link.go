@@ -0,0 +1,50 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func (l Link) Validate(c path.ContextPath) (r report.Report) {
+	r.Merge(l.Node.Validate(c))
+	r.AddOnError(c.Append("targetStyle"), l.validateTargetStyle())
+	if l.Hard == nil || !*l.Hard {
+		if l.Target == "/sysroot" || strings.HasPrefix(l.Target, "/sysroot/") {
+			r.AddOnWarn(c.Append("target"), errors.ErrLinkTargetInSysroot)
+		}
+	}
+	return
+}
+
+func (l Link) validateTargetStyle() error {
+	if l.TargetStyle == nil {
+		return nil
+	}
+	if l.Hard != nil && *l.Hard {
+		return errors.ErrTargetStyleWithHardLink
+	}
+	switch *l.TargetStyle {
+	case "literal", "absolute", "relative":
+		return nil
+	default:
+		return errors.ErrInvalidTargetStyle
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestLinkValidate(t *testing.T) {
+	tests := []struct {
+		in  Link
+		out report.Report
+	}{
+		{
+			in: Link{
+				Node:          Node{Path: "/foo"},
+				LinkEmbedded1: LinkEmbedded1{Target: "/bar"},
+			},
+		},
+		{
+			in: Link{
+				Node:          Node{Path: "/foo"},
+				LinkEmbedded1: LinkEmbedded1{Target: "/bar", TargetStyle: util.StrToPtr("relative")},
+			},
+		},
+		{
+			in: Link{
+				Node:          Node{Path: "/foo"},
+				LinkEmbedded1: LinkEmbedded1{Target: "/bar", TargetStyle: util.StrToPtr("sideways")},
+			},
+			out: func() (r report.Report) {
+				r.AddOnError(path.New("", "targetStyle"), errors.ErrInvalidTargetStyle)
+				return
+			}(),
+		},
+		{
+			in: Link{
+				Node:          Node{Path: "/foo"},
+				LinkEmbedded1: LinkEmbedded1{Target: "/bar", Hard: util.BoolToPtr(true), TargetStyle: util.StrToPtr("absolute")},
+			},
+			out: func() (r report.Report) {
+				r.AddOnError(path.New("", "targetStyle"), errors.ErrTargetStyleWithHardLink)
+				return
+			}(),
+		},
+		{
+			in: Link{
+				Node:          Node{Path: "/foo"},
+				LinkEmbedded1: LinkEmbedded1{Target: "/sysroot/bar"},
+			},
+			out: func() (r report.Report) {
+				r.AddOnWarn(path.New("", "target"), errors.ErrLinkTargetInSysroot)
+				return
+			}(),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New(""))
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
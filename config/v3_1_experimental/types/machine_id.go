@@ -0,0 +1,52 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"regexp"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var (
+	machineIDRegex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+func (m MachineID) Validate(c path.ContextPath) (r report.Report) {
+	if m.Policy == nil {
+		if m.Value != nil {
+			r.AddOnError(c.Append("value"), errors.ErrMachineIDValueUnused)
+		}
+		return
+	}
+	switch *m.Policy {
+	case "set":
+		if m.Value == nil {
+			r.AddOnError(c.Append("value"), errors.ErrMachineIDValueRequired)
+		} else if !machineIDRegex.MatchString(*m.Value) || *m.Value == "00000000000000000000000000000000" {
+			r.AddOnError(c.Append("value"), errors.ErrMachineIDValueInvalid)
+		}
+	case "firstboot", "platform":
+		if m.Value != nil {
+			r.AddOnError(c.Append("value"), errors.ErrMachineIDValueUnused)
+		}
+	default:
+		r.AddOnError(c.Append("policy"), errors.ErrMachineIDPolicyInvalid)
+	}
+	return
+}
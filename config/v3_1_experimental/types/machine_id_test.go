@@ -0,0 +1,86 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestMachineIDValidate(t *testing.T) {
+	tests := []struct {
+		in  MachineID
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: MachineID{},
+		},
+		{
+			in: MachineID{Policy: util.StrToPtr("set"), Value: util.StrToPtr("4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d")},
+		},
+		{
+			in: MachineID{Policy: util.StrToPtr("firstboot")},
+		},
+		{
+			in: MachineID{Policy: util.StrToPtr("platform")},
+		},
+		{
+			in:  MachineID{Policy: util.StrToPtr("random")},
+			at:  path.New("", "policy"),
+			out: errors.ErrMachineIDPolicyInvalid,
+		},
+		{
+			in:  MachineID{Policy: util.StrToPtr("set")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDValueRequired,
+		},
+		{
+			in:  MachineID{Policy: util.StrToPtr("set"), Value: util.StrToPtr("4A3B2C1D0E9F8A7B6C5D4E3F2A1B0C9D")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDValueInvalid,
+		},
+		{
+			in:  MachineID{Policy: util.StrToPtr("set"), Value: util.StrToPtr("00000000000000000000000000000000")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDValueInvalid,
+		},
+		{
+			in:  MachineID{Policy: util.StrToPtr("firstboot"), Value: util.StrToPtr("4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDValueUnused,
+		},
+		{
+			in:  MachineID{Value: util.StrToPtr("4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d")},
+			at:  path.New("", "value"),
+			out: errors.ErrMachineIDValueUnused,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -15,7 +15,12 @@
 package types
 
 import (
+	"strings"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
 func validateMode(m *int) error {
@@ -24,3 +29,125 @@ func validateMode(m *int) error {
 	}
 	return nil
 }
+
+// validateModePolicy validates a node's mode or symbolicMode and checks the
+// resulting mode against the permission policy: setuid and setgid modes
+// produce warnings, and world-writable modes are errors unless
+// allowWorldWritable is set. Sticky directories may be world-writable.
+func validateModePolicy(c path.ContextPath, mode *int, symbolic *string, allowWorldWritable *bool, dir bool) (r report.Report) {
+	at := c.Append("mode")
+	r.AddOnError(at, validateMode(mode))
+	if symbolic != nil {
+		at = c.Append("symbolicMode")
+		if mode != nil {
+			r.AddOnError(at, errors.ErrModeAndSymbolicMode)
+			return
+		}
+	}
+	m, err := resolveMode(mode, symbolic)
+	if err != nil {
+		r.AddOnError(at, err)
+		return
+	}
+	if m == nil {
+		return
+	}
+
+	if *m&06000 != 0 {
+		r.AddOnWarn(at, errors.ErrModeSetuid)
+	}
+	sticky := dir && *m&01000 != 0
+	if *m&02 != 0 && !sticky && (allowWorldWritable == nil || !*allowWorldWritable) {
+		r.AddOnError(at, errors.ErrModeWorldWritable)
+	}
+	return
+}
+
+// ResolvedMode returns the file's mode, from either mode or symbolicMode.
+func (f FileEmbedded1) ResolvedMode() (*int, error) {
+	return resolveMode(f.Mode, f.SymbolicMode)
+}
+
+// ResolvedMode returns the directory's mode, from either mode or
+// symbolicMode.
+func (d DirectoryEmbedded1) ResolvedMode() (*int, error) {
+	return resolveMode(d.Mode, d.SymbolicMode)
+}
+
+func resolveMode(mode *int, symbolic *string) (*int, error) {
+	if symbolic == nil {
+		return mode, nil
+	}
+	m, err := ParseSymbolicMode(*symbolic)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ParseSymbolicMode converts a symbolic mode like "u=rw,go=r" into a
+// numeric mode. Each comma-separated clause assigns permissions (any of
+// "rwxst") to users, groups, and/or others ("ugoa", or "a" if omitted).
+// Since there is no existing mode to modify, only the "=" operator is
+// supported; classes not mentioned in any clause get no permissions.
+func ParseSymbolicMode(s string) (int, error) {
+	mode := 0
+	for _, clause := range strings.Split(s, ",") {
+		i := strings.Index(clause, "=")
+		if i < 0 {
+			return 0, errors.ErrInvalidSymbolicMode
+		}
+		who, perms := clause[:i], clause[i+1:]
+		if who == "" {
+			who = "a"
+		}
+
+		// shift of each class's permission bits
+		var shifts []uint
+		for _, w := range who {
+			switch w {
+			case 'u':
+				shifts = append(shifts, 6)
+			case 'g':
+				shifts = append(shifts, 3)
+			case 'o':
+				shifts = append(shifts, 0)
+			case 'a':
+				shifts = append(shifts, 6, 3, 0)
+			default:
+				return 0, errors.ErrInvalidSymbolicMode
+			}
+		}
+
+		bits := 0
+		for _, p := range perms {
+			switch p {
+			case 'r':
+				bits |= 04
+			case 'w':
+				bits |= 02
+			case 'x':
+				bits |= 01
+			case 's', 't':
+				// handled below
+			default:
+				return 0, errors.ErrInvalidSymbolicMode
+			}
+		}
+		// s sets setuid or setgid for users or groups, t sets sticky for
+		// others
+		setid := strings.ContainsRune(perms, 's')
+		sticky := strings.ContainsRune(perms, 't')
+
+		for _, shift := range shifts {
+			// setuid is 04000, setgid is 02000, sticky is 01000
+			specialBit := 01000 << (shift / 3)
+			mode &^= 07<<shift | specialBit
+			mode |= bits << shift
+			if (shift != 0 && setid) || (shift == 0 && sticky) {
+				mode |= specialBit
+			}
+		}
+	}
+	return mode, nil
+}
@@ -20,6 +20,9 @@ import (
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
 func TestModeValidate(t *testing.T) {
@@ -60,3 +63,107 @@ func TestModeValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSymbolicMode(t *testing.T) {
+	tests := []struct {
+		in  string
+		out int
+		err error
+	}{
+		{"u=rw,go=r", 0644, nil},
+		{"u=rwx,g=rx,o=", 0750, nil},
+		{"a=r", 0444, nil},
+		{"=rx", 0555, nil},
+		{"a=rwx,o=", 0770, nil},
+		{"u=rwxs,go=rx", 04755, nil},
+		{"ug=rwxs", 06770, nil},
+		{"a=rwxt", 01777, nil},
+		{"u=rw,u=r", 0400, nil},
+		{"", 0, errors.ErrInvalidSymbolicMode},
+		{"u+x", 0, errors.ErrInvalidSymbolicMode},
+		{"z=r", 0, errors.ErrInvalidSymbolicMode},
+		{"u=q", 0, errors.ErrInvalidSymbolicMode},
+	}
+
+	for i, test := range tests {
+		out, err := ParseSymbolicMode(test.in)
+		if !reflect.DeepEqual(test.err, err) {
+			t.Errorf("#%d: bad err: want %v, got %v", i, test.err, err)
+		}
+		if out != test.out {
+			t.Errorf("#%d: bad mode: want %#o, got %#o", i, test.out, out)
+		}
+	}
+}
+
+func TestValidateModePolicy(t *testing.T) {
+	tests := []struct {
+		mode     *int
+		symbolic *string
+		allow    *bool
+		dir      bool
+		out      error
+		at       path.ContextPath
+		warn     bool
+	}{
+		{
+			mode: util.IntToPtr(0644),
+		},
+		{
+			symbolic: util.StrToPtr("u=rw,go=r"),
+		},
+		{
+			mode:     util.IntToPtr(0644),
+			symbolic: util.StrToPtr("u=rw,go=r"),
+			out:      errors.ErrModeAndSymbolicMode,
+			at:       path.New("", "symbolicMode"),
+		},
+		{
+			symbolic: util.StrToPtr("u+rw"),
+			out:      errors.ErrInvalidSymbolicMode,
+			at:       path.New("", "symbolicMode"),
+		},
+		{
+			mode: util.IntToPtr(0666),
+			out:  errors.ErrModeWorldWritable,
+			at:   path.New("", "mode"),
+		},
+		{
+			symbolic: util.StrToPtr("a=rw"),
+			out:      errors.ErrModeWorldWritable,
+			at:       path.New("", "symbolicMode"),
+		},
+		{
+			mode:  util.IntToPtr(0666),
+			allow: util.BoolToPtr(true),
+		},
+		{
+			mode: util.IntToPtr(01777),
+			dir:  true,
+		},
+		{
+			mode: util.IntToPtr(01777),
+			out:  errors.ErrModeWorldWritable,
+			at:   path.New("", "mode"),
+		},
+		{
+			mode: util.IntToPtr(04755),
+			out:  errors.ErrModeSetuid,
+			at:   path.New("", "mode"),
+			warn: true,
+		},
+	}
+
+	for i, test := range tests {
+		r := validateModePolicy(path.New(""), test.mode, test.symbolic, test.allow, test.dir)
+		expected := report.Report{}
+		if test.warn {
+			expected.AddOnWarn(test.at, test.out)
+		} else {
+			expected.AddOnError(test.at, test.out)
+		}
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
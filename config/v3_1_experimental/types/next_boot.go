@@ -0,0 +1,38 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+const (
+	// NextBootConfigPath is where the next boot's config is written, on
+	// the boot filesystem of the target.
+	NextBootConfigPath = "/boot/ignition/config.ign"
+	// FirstbootFlagPath is the flag which makes the next boot run Ignition.
+	FirstbootFlagPath = "/boot/ignition.firstboot"
+)
+
+func (n NextBoot) Validate(c path.ContextPath) (r report.Report) {
+	if n.Verification.Specified() && n.Source == nil {
+		r.AddOnError(c.Append("verification", "hash"), errors.ErrVerificationAndNilSource)
+	}
+	r.AddOnError(c.Append("source"), validateURLNilOK(n.Source))
+	return
+}
@@ -99,3 +99,44 @@ func TestNodeValidateGroup(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeValidateTypeConflict(t *testing.T) {
+	tests := []struct {
+		in  Node
+		out report.Report
+	}{
+		{
+			Node{Path: "/foo"},
+			report.Report{},
+		},
+		{
+			Node{Path: "/foo", TypeConflict: util.StrToPtr("replace")},
+			report.Report{},
+		},
+		{
+			Node{Path: "/foo", TypeConflict: util.StrToPtr("adopt"), Overwrite: util.BoolToPtr(false)},
+			report.Report{},
+		},
+		{
+			Node{Path: "/foo", TypeConflict: util.StrToPtr("ignore")},
+			func() (r report.Report) {
+				r.AddOnError(path.New("", "typeConflict"), errors.ErrInvalidTypeConflict)
+				return
+			}(),
+		},
+		{
+			Node{Path: "/foo", TypeConflict: util.StrToPtr("fail"), Overwrite: util.BoolToPtr(true)},
+			func() (r report.Report) {
+				r.AddOnWarn(path.New("", "typeConflict"), errors.ErrTypeConflictWithOverwrite)
+				return
+			}(),
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v got %v", i, test.out, r)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/coreos/ignition/v2/config/shared/errors"
+)
+
+func validateOnFailure(onFailure *string) error {
+	if onFailure == nil {
+		return nil
+	}
+	switch *onFailure {
+	case "warn", "fail":
+		return nil
+	default:
+		return errors.ErrInvalidOnFailure
+	}
+}
+
+// WarnsOnFailure reports whether a failure to create the file is logged
+// and ignored rather than failing the stage.
+func (f File) WarnsOnFailure() bool {
+	return f.OnFailure != nil && *f.OnFailure == "warn"
+}
+
+// WarnsOnFailure reports whether a failure to create the unit is logged
+// and ignored rather than failing the stage.
+func (u Unit) WarnsOnFailure() bool {
+	return u.OnFailure != nil && *u.OnFailure == "warn"
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"regexp"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var (
+	osPackageRegex = regexp.MustCompile(`^[A-Za-z0-9_+][A-Za-z0-9_.+:~^-]*$`)
+)
+
+// OSUpdateUnit is the unit applying the OS changes on first boot.
+const OSUpdateUnit = "ignition-os-update.service"
+
+func (o OS) Validate(c path.ContextPath) (r report.Report) {
+	if o.Image != nil && !containerImageRegex.MatchString(*o.Image) {
+		r.AddOnError(c.Append("image"), errors.ErrContainerImageInvalid)
+	}
+	for i, p := range o.Packages {
+		if !osPackageRegex.MatchString(p) {
+			r.AddOnError(c.Append("packages", i), errors.ErrOSPackageInvalid)
+		}
+	}
+	if o.Reboot != nil && !o.HasChanges() {
+		r.AddOnError(c.Append("reboot"), errors.ErrOSRebootUnused)
+	}
+	return
+}
+
+// HasChanges returns true if the section changes the OS.
+func (o OS) HasChanges() bool {
+	return o.Image != nil || len(o.Packages) > 0
+}
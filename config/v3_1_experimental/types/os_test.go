@@ -0,0 +1,74 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestOSValidate(t *testing.T) {
+	tests := []struct {
+		in  OS
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: OS{},
+		},
+		{
+			in: OS{
+				Image:    util.StrToPtr("quay.io/fedora/fedora-bootc:40"),
+				Packages: []string{"vim-enhanced", "kernel-devel-6.8.5-301.fc40", "gcc-c++"},
+				Reboot:   util.BoolToPtr(false),
+			},
+		},
+		{
+			in:  OS{Image: util.StrToPtr("ostree-unverified-registry:quay.io/fedora/fedora-bootc:40")},
+			at:  path.New("", "image"),
+			out: errors.ErrContainerImageInvalid,
+		},
+		{
+			in:  OS{Packages: []string{"--uninstall=kernel"}},
+			at:  path.New("", "packages", 0),
+			out: errors.ErrOSPackageInvalid,
+		},
+		{
+			in:  OS{Packages: []string{"vim tmux"}},
+			at:  path.New("", "packages", 0),
+			out: errors.ErrOSPackageInvalid,
+		},
+		{
+			in:  OS{Reboot: util.BoolToPtr(true)},
+			at:  path.New("", "reboot"),
+			out: errors.ErrOSRebootUnused,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
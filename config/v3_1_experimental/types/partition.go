@@ -36,8 +36,11 @@ var (
 func (p Partition) Key() string {
 	if p.Number != 0 {
 		return fmt.Sprintf("number:%d", p.Number)
-	} else {
+	} else if p.Label != nil {
 		return fmt.Sprintf("label:%s", *p.Label)
+	} else {
+		// invalid; reported by Validate
+		return ""
 	}
 }
 
@@ -50,12 +53,28 @@ func (p Partition) Validate(c path.ContextPath) (r report.Report) {
 		r.AddOnError(c, errors.ErrNeedLabelOrNumber)
 	}
 
+	if p.Assert != nil && *p.Assert {
+		r.AddOnError(c, p.validateAssert())
+	}
+
 	r.AddOnError(c.Append("label"), p.validateLabel())
 	r.AddOnError(c.Append("guid"), validateGUID(p.GUID))
 	r.AddOnError(c.Append("typeGuid"), validateGUID(p.TypeGUID))
 	return
 }
 
+// validateAssert checks that the partition can be verified without
+// modifying it.
+func (p Partition) validateAssert() error {
+	if p.Number == 0 {
+		return errors.ErrAssertNeedsNumber
+	}
+	if p.WipePartitionEntry != nil && *p.WipePartitionEntry {
+		return errors.ErrAssertWithWipeEntry
+	}
+	return nil
+}
+
 func (p Partition) validateLabel() error {
 	if p.Label == nil {
 		return nil
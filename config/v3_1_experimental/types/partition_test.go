@@ -92,3 +92,33 @@ func TestValidateGUID(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateAssert(t *testing.T) {
+	tests := []struct {
+		in  Partition
+		out error
+	}{
+		{
+			Partition{Number: 1},
+			nil,
+		},
+		{
+			Partition{Number: 1, WipePartitionEntry: util.BoolToPtr(false)},
+			nil,
+		},
+		{
+			Partition{Label: util.StrToPtr("root")},
+			errors.ErrAssertNeedsNumber,
+		},
+		{
+			Partition{Number: 1, WipePartitionEntry: util.BoolToPtr(true)},
+			errors.ErrAssertWithWipeEntry,
+		},
+	}
+	for i, test := range tests {
+		err := test.in.validateAssert()
+		if err != test.out {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out, err)
+		}
+	}
+}
@@ -14,10 +14,46 @@
 
 package types
 
+import (
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
 func (p PasswdUser) Key() string {
 	return p.Name
 }
 
+// Validate rejects fields which would redefine a user marked existing, since
+// only its group memberships and SSH keys are added to.
+func (p PasswdUser) Validate(c path.ContextPath) (r report.Report) {
+	if p.Existing == nil || !*p.Existing {
+		return
+	}
+	fields := []struct {
+		name string
+		set  bool
+	}{
+		{"passwordHash", p.PasswordHash != nil},
+		{"uid", p.UID != nil},
+		{"gecos", p.Gecos != nil},
+		{"homeDir", p.HomeDir != nil},
+		{"noCreateHome", p.NoCreateHome != nil},
+		{"primaryGroup", p.PrimaryGroup != nil},
+		{"noUserGroup", p.NoUserGroup != nil},
+		{"system", p.System != nil},
+		{"noLogInit", p.NoLogInit != nil},
+		{"shell", p.Shell != nil},
+	}
+	for _, f := range fields {
+		if f.set {
+			r.AddOnError(c.Append(f.name), errors.ErrExistingUserField)
+		}
+	}
+	return
+}
+
 func (g PasswdGroup) Key() string {
 	return g.Name
 }
@@ -0,0 +1,65 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestPasswdUserValidate(t *testing.T) {
+	tests := []struct {
+		in  PasswdUser
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: PasswdUser{Name: "core", UID: util.IntToPtr(1000), Shell: util.StrToPtr("/bin/bash")},
+		},
+		{
+			in: PasswdUser{
+				Name:              "core",
+				Existing:          util.BoolToPtr(true),
+				Groups:            []Group{"libvirt"},
+				SSHAuthorizedKeys: []SSHAuthorizedKey{"ssh-ed25519 AAAA"},
+			},
+		},
+		{
+			in:  PasswdUser{Name: "core", Existing: util.BoolToPtr(true), UID: util.IntToPtr(1000)},
+			at:  path.New("", "uid"),
+			out: errors.ErrExistingUserField,
+		},
+		{
+			in:  PasswdUser{Name: "core", Existing: util.BoolToPtr(true), Shell: util.StrToPtr("/bin/zsh")},
+			at:  path.New("", "shell"),
+			out: errors.ErrExistingUserField,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"path"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	cpath "github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// IsSet reports whether a command is run after the file is written.
+func (pc PostCommand) IsSet() bool {
+	return len(pc.Args) > 0 || pc.Timeout != nil
+}
+
+func (pc PostCommand) Validate(c cpath.ContextPath) (r report.Report) {
+	if !pc.IsSet() {
+		return
+	}
+	if len(pc.Args) == 0 {
+		r.AddOnError(c.Append("args"), errors.ErrPostCommandNoArgs)
+	} else if !path.IsAbs(pc.Args[0]) {
+		r.AddOnError(c.Append("args", 0), errors.ErrPostCommandRelative)
+	}
+	if pc.Timeout != nil && *pc.Timeout <= 0 {
+		r.AddOnError(c.Append("timeout"), errors.ErrPostCommandTimeout)
+	}
+	return
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestPostCommandValidate(t *testing.T) {
+	tests := []struct {
+		in  PostCommand
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: PostCommand{},
+		},
+		{
+			in: PostCommand{Args: []string{"/usr/bin/update-ca-trust"}},
+		},
+		{
+			in: PostCommand{Args: []string{"/usr/sbin/depmod", "-a"}, Timeout: util.IntToPtr(300)},
+		},
+		{
+			in:  PostCommand{Timeout: util.IntToPtr(10)},
+			at:  path.New("", "args"),
+			out: errors.ErrPostCommandNoArgs,
+		},
+		{
+			in:  PostCommand{Args: []string{"depmod"}},
+			at:  path.New("", "args", 0),
+			out: errors.ErrPostCommandRelative,
+		},
+		{
+			in:  PostCommand{Args: []string{"/usr/sbin/depmod"}, Timeout: util.IntToPtr(0)},
+			at:  path.New("", "timeout"),
+			out: errors.ErrPostCommandTimeout,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -15,6 +15,8 @@
 package types
 
 import (
+	"strings"
+
 	"github.com/coreos/ignition/v2/config/shared/errors"
 
 	"github.com/coreos/vcontext/path"
@@ -33,9 +35,40 @@ func (r Raid) IgnoreDuplicates() map[string]struct{} {
 
 func (ra Raid) Validate(c path.ContextPath) (r report.Report) {
 	r.AddOnError(c.Append("level"), ra.validateLevel())
+	r.AddOnError(c.Append("uuid"), validateRaidUUID(ra.UUID))
+	if ra.Assemble != nil && *ra.Assemble {
+		if ra.UUID == nil {
+			r.AddOnError(c.Append("uuid"), errors.ErrAssembleNeedsUUID)
+		}
+		if ra.Spares != nil {
+			r.AddOnWarn(c.Append("spares"), errors.ErrAssembleWithSpares)
+		}
+	}
 	return
 }
 
+// validateRaidUUID accepts the UUID formats mdadm does: 32 hex digits, with
+// any number of ':', '-', '.', or ' ' separators.
+func validateRaidUUID(uuid *string) error {
+	if uuid == nil {
+		return nil
+	}
+	digits := 0
+	for _, c := range *uuid {
+		switch {
+		case strings.ContainsRune(":-. ", c):
+		case strings.ContainsRune("0123456789abcdefABCDEF", c):
+			digits++
+		default:
+			return errors.ErrInvalidRaidUUID
+		}
+	}
+	if digits != 32 {
+		return errors.ErrInvalidRaidUUID
+	}
+	return nil
+}
+
 func (r Raid) validateLevel() error {
 	switch r.Level {
 	case "linear", "raid0", "0", "stripe":
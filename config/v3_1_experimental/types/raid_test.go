@@ -0,0 +1,64 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+)
+
+func TestValidateRaidUUID(t *testing.T) {
+	tests := []struct {
+		in  *string
+		out error
+	}{
+		{
+			nil,
+			nil,
+		},
+		{
+			util.StrToPtr("3aaa0122:29827cfa:5331ad66:ca767371"),
+			nil,
+		},
+		{
+			util.StrToPtr("3aaa0122-2982-7cfa-5331-ad66ca767371"),
+			nil,
+		},
+		{
+			util.StrToPtr("3AAA012229827CFA5331AD66CA767371"),
+			nil,
+		},
+		{
+			util.StrToPtr(""),
+			errors.ErrInvalidRaidUUID,
+		},
+		{
+			util.StrToPtr("3aaa0122:29827cfa:5331ad66"),
+			errors.ErrInvalidRaidUUID,
+		},
+		{
+			util.StrToPtr("3aaa0122:29827cfa:5331ad66:ca76737g"),
+			errors.ErrInvalidRaidUUID,
+		},
+	}
+	for i, test := range tests {
+		err := validateRaidUUID(test.in)
+		if err != test.out {
+			t.Errorf("#%d: wanted %v, got %v", i, test.out, err)
+		}
+	}
+}
@@ -8,17 +8,62 @@ type CaReference struct {
 }
 
 type Config struct {
-	Ignition Ignition `json:"ignition"`
-	Passwd   Passwd   `json:"passwd,omitempty"`
-	Storage  Storage  `json:"storage,omitempty"`
-	Systemd  Systemd  `json:"systemd,omitempty"`
+	Containers Containers     `json:"containers,omitempty"`
+	Ignition   Ignition       `json:"ignition"`
+	MachineID  MachineID      `json:"machineId,omitempty"`
+	NextBoot   NextBoot       `json:"nextBoot,omitempty"`
+	OS         OS             `json:"os,omitempty"`
+	Passwd     Passwd         `json:"passwd,omitempty"`
+	Security   SystemSecurity `json:"security,omitempty"`
+	Selinux    Selinux        `json:"selinux,omitempty"`
+	Storage    Storage        `json:"storage,omitempty"`
+	Systemd    Systemd        `json:"systemd,omitempty"`
+	Time       Time           `json:"time,omitempty"`
+}
+
+type ConfigCondition struct {
+	DiskPresent  *string `json:"diskPresent,omitempty"`
+	Platform     *string `json:"platform,omitempty"`
+	SmbiosVendor *string `json:"smbiosVendor,omitempty"`
 }
 
 type ConfigReference struct {
-	Source       *string      `json:"source"`
+	If           ConfigCondition `json:"if,omitempty"`
+	ListPolicies []ListPolicy    `json:"listPolicies,omitempty"`
+	Mirrors      []string        `json:"mirrors,omitempty"`
+	Source       *string         `json:"source"`
+	Verification Verification    `json:"verification,omitempty"`
+}
+
+type ContainerAuth struct {
+	Source       *string      `json:"source,omitempty"`
 	Verification Verification `json:"verification,omitempty"`
 }
 
+type ContainerEnvironment struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value,omitempty"`
+}
+
+type ContainerImage struct {
+	Name string `json:"name"`
+}
+
+type ContainerUnit struct {
+	Enabled     *bool                  `json:"enabled,omitempty"`
+	Environment []ContainerEnvironment `json:"environment,omitempty"`
+	Image       string                 `json:"image"`
+	Name        string                 `json:"name"`
+	Ports       []string               `json:"ports,omitempty"`
+	Volumes     []string               `json:"volumes,omitempty"`
+}
+
+type Containers struct {
+	Auth   ContainerAuth    `json:"auth,omitempty"`
+	Images []ContainerImage `json:"images,omitempty"`
+	Units  []ContainerUnit  `json:"units,omitempty"`
+}
+
 type Device string
 
 type Directory struct {
@@ -27,13 +72,18 @@ type Directory struct {
 }
 
 type DirectoryEmbedded1 struct {
-	Mode *int `json:"mode,omitempty"`
+	AllowWorldWritable *bool   `json:"allowWorldWritable,omitempty"`
+	Mode               *int    `json:"mode,omitempty"`
+	SymbolicMode       *string `json:"symbolicMode,omitempty"`
 }
 
 type Disk struct {
-	Device     string      `json:"device"`
-	Partitions []Partition `json:"partitions,omitempty"`
-	WipeTable  *bool       `json:"wipeTable,omitempty"`
+	AllocationStrategy *string     `json:"allocationStrategy,omitempty"`
+	Assert             *bool       `json:"assert,omitempty"`
+	Device             string      `json:"device"`
+	Partitions         []Partition `json:"partitions,omitempty"`
+	WaitTimeout        *int        `json:"waitTimeout,omitempty"`
+	WipeTable          *bool       `json:"wipeTable,omitempty"`
 }
 
 type Dropin struct {
@@ -41,6 +91,29 @@ type Dropin struct {
 	Name     string  `json:"name"`
 }
 
+type EfiArchive struct {
+	Compression  *string      `json:"compression,omitempty"`
+	Source       *string      `json:"source,omitempty"`
+	Verification Verification `json:"verification,omitempty"`
+}
+
+type EfiBootEntry struct {
+	Label  string `json:"label"`
+	Loader string `json:"loader"`
+}
+
+type EfiSystemPartition struct {
+	Archive     EfiArchive     `json:"archive,omitempty"`
+	BootEntries []EfiBootEntry `json:"bootEntries,omitempty"`
+	Path        *string        `json:"path,omitempty"`
+}
+
+type Encryption struct {
+	DataKey  *string `json:"dataKey,omitempty"`
+	Key      *string `json:"key,omitempty"`
+	Provider *string `json:"provider,omitempty"`
+}
+
 type File struct {
 	Node
 	FileEmbedded1
@@ -48,17 +121,38 @@ type File struct {
 
 type FileContents struct {
 	Compression  *string      `json:"compression,omitempty"`
+	Encryption   Encryption   `json:"encryption,omitempty"`
+	EndMarker    *string      `json:"endMarker,omitempty"`
+	Marker       *string      `json:"marker,omitempty"`
+	Merge        *string      `json:"merge,omitempty"`
+	Mirrors      []string     `json:"mirrors,omitempty"`
+	PatchFrom    *string      `json:"patchFrom,omitempty"`
 	Source       *string      `json:"source,omitempty"`
 	Verification Verification `json:"verification,omitempty"`
 }
 
+type FileEdit struct {
+	Absent *bool   `json:"absent,omitempty"`
+	Line   *string `json:"line,omitempty"`
+	Regex  string  `json:"regex"`
+}
+
 type FileEmbedded1 struct {
-	Append   []FileContents `json:"append,omitempty"`
-	Contents FileContents   `json:"contents,omitempty"`
-	Mode     *int           `json:"mode,omitempty"`
+	AllowWorldWritable *bool          `json:"allowWorldWritable,omitempty"`
+	Append             []FileContents `json:"append,omitempty"`
+	Atime              *string        `json:"atime,omitempty"`
+	Backup             *bool          `json:"backup,omitempty"`
+	Contents           FileContents   `json:"contents,omitempty"`
+	Edits              []FileEdit     `json:"edits,omitempty"`
+	Mode               *int           `json:"mode,omitempty"`
+	Mtime              *string        `json:"mtime,omitempty"`
+	OnFailure          *string        `json:"onFailure,omitempty"`
+	PostCommand        PostCommand    `json:"postCommand,omitempty"`
+	SymbolicMode       *string        `json:"symbolicMode,omitempty"`
 }
 
 type Filesystem struct {
+	Assert         *bool              `json:"assert,omitempty"`
 	Device         string             `json:"device"`
 	Format         *string            `json:"format,omitempty"`
 	Label          *string            `json:"label,omitempty"`
@@ -73,6 +167,39 @@ type FilesystemOption string
 
 type Group string
 
+type HTTP struct {
+	Credentials []HTTPCredential `json:"credentials,omitempty"`
+}
+
+type HTTPCredential struct {
+	Host     string  `json:"host"`
+	Password *string `json:"password,omitempty"`
+	Token    *string `json:"token,omitempty"`
+	Username *string `json:"username,omitempty"`
+}
+
+type HostCertificate struct {
+	ACME            HostCertificateACME `json:"acme,omitempty"`
+	CertificatePath string              `json:"certificatePath"`
+	DNSNames        []string            `json:"dnsNames,omitempty"`
+	Group           NodeGroup           `json:"group,omitempty"`
+	KeyPath         string              `json:"keyPath"`
+	KeyType         *string             `json:"keyType,omitempty"`
+	SCEP            HostCertificateSCEP `json:"scep,omitempty"`
+	User            NodeUser            `json:"user,omitempty"`
+}
+
+type HostCertificateACME struct {
+	Directory *string `json:"directory,omitempty"`
+	Email     *string `json:"email,omitempty"`
+}
+
+type HostCertificateSCEP struct {
+	CaFingerprint     *string `json:"caFingerprint,omitempty"`
+	ChallengePassword *string `json:"challengePassword,omitempty"`
+	URL               *string `json:"url,omitempty"`
+}
+
 type Ignition struct {
 	Config   IgnitionConfig `json:"config,omitempty"`
 	Proxy    Proxy          `json:"proxy,omitempty"`
@@ -92,19 +219,36 @@ type Link struct {
 }
 
 type LinkEmbedded1 struct {
-	Hard   *bool  `json:"hard,omitempty"`
-	Target string `json:"target"`
+	Hard        *bool   `json:"hard,omitempty"`
+	Target      string  `json:"target"`
+	TargetStyle *string `json:"targetStyle,omitempty"`
+}
+
+type ListPolicy struct {
+	List   string `json:"list"`
+	Policy string `json:"policy"`
+}
+
+type MachineID struct {
+	Policy *string `json:"policy,omitempty"`
+	Value  *string `json:"value,omitempty"`
 }
 
 type MountOption string
 
+type NextBoot struct {
+	Source       *string      `json:"source,omitempty"`
+	Verification Verification `json:"verification,omitempty"`
+}
+
 type NoProxyItem string
 
 type Node struct {
-	Group     NodeGroup `json:"group,omitempty"`
-	Overwrite *bool     `json:"overwrite,omitempty"`
-	Path      string    `json:"path"`
-	User      NodeUser  `json:"user,omitempty"`
+	Group        NodeGroup `json:"group,omitempty"`
+	Overwrite    *bool     `json:"overwrite,omitempty"`
+	Path         string    `json:"path"`
+	TypeConflict *string   `json:"typeConflict,omitempty"`
+	User         NodeUser  `json:"user,omitempty"`
 }
 
 type NodeGroup struct {
@@ -117,7 +261,14 @@ type NodeUser struct {
 	Name *string `json:"name,omitempty"`
 }
 
+type OS struct {
+	Image    *string  `json:"image,omitempty"`
+	Packages []string `json:"packages,omitempty"`
+	Reboot   *bool    `json:"reboot,omitempty"`
+}
+
 type Partition struct {
+	Assert             *bool   `json:"assert,omitempty"`
 	GUID               *string `json:"guid,omitempty"`
 	Label              *string `json:"label,omitempty"`
 	Number             int     `json:"number,omitempty"`
@@ -141,6 +292,7 @@ type PasswdGroup struct {
 }
 
 type PasswdUser struct {
+	Existing          *bool              `json:"existing,omitempty"`
 	Gecos             *string            `json:"gecos,omitempty"`
 	Groups            []Group            `json:"groups,omitempty"`
 	HomeDir           *string            `json:"homeDir,omitempty"`
@@ -156,6 +308,11 @@ type PasswdUser struct {
 	UID               *int               `json:"uid,omitempty"`
 }
 
+type PostCommand struct {
+	Args    []string `json:"args,omitempty"`
+	Timeout *int     `json:"timeout,omitempty"`
+}
+
 type Proxy struct {
 	HTTPProxy  *string       `json:"httpProxy,omitempty"`
 	HTTPSProxy *string       `json:"httpsProxy,omitempty"`
@@ -163,28 +320,75 @@ type Proxy struct {
 }
 
 type Raid struct {
-	Devices []Device     `json:"devices"`
-	Level   string       `json:"level"`
-	Name    string       `json:"name"`
-	Options []RaidOption `json:"options,omitempty"`
-	Spares  *int         `json:"spares,omitempty"`
+	Assemble *bool        `json:"assemble,omitempty"`
+	Devices  []Device     `json:"devices"`
+	Level    string       `json:"level"`
+	Name     string       `json:"name"`
+	Options  []RaidOption `json:"options,omitempty"`
+	Spares   *int         `json:"spares,omitempty"`
+	UUID     *string      `json:"uuid,omitempty"`
 }
 
 type RaidOption string
 
 type SSHAuthorizedKey string
 
+type SSHHostKey struct {
+	PublicKey    string       `json:"publicKey"`
+	Source       *string      `json:"source,omitempty"`
+	Type         string       `json:"type"`
+	Verification Verification `json:"verification,omitempty"`
+}
+
+type SSHHostKeys struct {
+	Keys  []SSHHostKey `json:"keys,omitempty"`
+	Types []string     `json:"types,omitempty"`
+}
+
 type Security struct {
-	TLS TLS `json:"tls,omitempty"`
+	HTTP      HTTP      `json:"http,omitempty"`
+	Signature Signature `json:"signature,omitempty"`
+	TLS       TLS       `json:"tls,omitempty"`
+	Vault     Vault     `json:"vault,omitempty"`
+}
+
+type Selinux struct {
+	Modules []SelinuxModule `json:"modules,omitempty"`
+}
+
+type SelinuxModule struct {
+	Compression  *string      `json:"compression,omitempty"`
+	Name         string       `json:"name"`
+	Source       *string      `json:"source,omitempty"`
+	Verification Verification `json:"verification,omitempty"`
+}
+
+type Signature struct {
+	Keys []string `json:"keys,omitempty"`
 }
 
 type Storage struct {
-	Directories []Directory  `json:"directories,omitempty"`
-	Disks       []Disk       `json:"disks,omitempty"`
-	Files       []File       `json:"files,omitempty"`
-	Filesystems []Filesystem `json:"filesystems,omitempty"`
-	Links       []Link       `json:"links,omitempty"`
-	Raid        []Raid       `json:"raid,omitempty"`
+	AtomicFiles        *bool              `json:"atomicFiles,omitempty"`
+	Audit              *bool              `json:"audit,omitempty"`
+	Checkpoint         *bool              `json:"checkpoint,omitempty"`
+	DeduplicateFiles   *bool              `json:"deduplicateFiles,omitempty"`
+	Directories        []Directory        `json:"directories,omitempty"`
+	Disks              []Disk             `json:"disks,omitempty"`
+	EfiSystemPartition EfiSystemPartition `json:"efiSystemPartition,omitempty"`
+	Files              []File             `json:"files,omitempty"`
+	Filesystems        []Filesystem       `json:"filesystems,omitempty"`
+	Links              []Link             `json:"links,omitempty"`
+	Manifest           *bool              `json:"manifest,omitempty"`
+	Prune              *bool              `json:"prune,omitempty"`
+	Raid               []Raid             `json:"raid,omitempty"`
+	Rollback           *bool              `json:"rollback,omitempty"`
+}
+
+type SystemSecurity struct {
+	Fips                *bool                `json:"fips,omitempty"`
+	HostCertificates    []HostCertificate    `json:"hostCertificates,omitempty"`
+	SSHHostKeys         SSHHostKeys          `json:"sshHostKeys,omitempty"`
+	TrustedCertificates []TrustedCertificate `json:"trustedCertificates,omitempty"`
 }
 
 type Systemd struct {
@@ -195,19 +399,57 @@ type TLS struct {
 	CertificateAuthorities []CaReference `json:"certificateAuthorities,omitempty"`
 }
 
+type Time struct {
+	Pools   []TimeSource `json:"pools,omitempty"`
+	Servers []TimeSource `json:"servers,omitempty"`
+}
+
+type TimeSource string
+
 type Timeouts struct {
 	HTTPResponseHeaders *int `json:"httpResponseHeaders,omitempty"`
 	HTTPTotal           *int `json:"httpTotal,omitempty"`
 }
 
+type TrustedCertificate struct {
+	Name         string       `json:"name"`
+	Source       *string      `json:"source,omitempty"`
+	Verification Verification `json:"verification,omitempty"`
+}
+
 type Unit struct {
-	Contents *string  `json:"contents,omitempty"`
-	Dropins  []Dropin `json:"dropins,omitempty"`
-	Enabled  *bool    `json:"enabled,omitempty"`
-	Mask     *bool    `json:"mask,omitempty"`
-	Name     string   `json:"name"`
+	Contents  *string  `json:"contents,omitempty"`
+	Dropins   []Dropin `json:"dropins,omitempty"`
+	Enabled   *bool    `json:"enabled,omitempty"`
+	Mask      *bool    `json:"mask,omitempty"`
+	Name      string   `json:"name"`
+	OnFailure *string  `json:"onFailure,omitempty"`
+}
+
+type Vault struct {
+	Address  *string       `json:"address,omitempty"`
+	AppRole  VaultAppRole  `json:"appRole,omitempty"`
+	Identity VaultIdentity `json:"identity,omitempty"`
+	Token    *string       `json:"token,omitempty"`
+}
+
+type VaultAppRole struct {
+	RoleID   *string `json:"roleId,omitempty"`
+	SecretID *string `json:"secretId,omitempty"`
+}
+
+type VaultIdentity struct {
+	Method *string `json:"method,omitempty"`
+	Role   *string `json:"role,omitempty"`
 }
 
 type Verification struct {
-	Hash *string `json:"hash,omitempty"`
+	Checksums VerificationChecksums `json:"checksums,omitempty"`
+	Hash      *string               `json:"hash,omitempty"`
+}
+
+type VerificationChecksums struct {
+	Name      *string `json:"name,omitempty"`
+	Signature *string `json:"signature,omitempty"`
+	Source    *string `json:"source,omitempty"`
 }
@@ -0,0 +1,56 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"regexp"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var (
+	selinuxModuleNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+func (m SelinuxModule) Key() string {
+	return m.Name
+}
+
+func (m SelinuxModule) Validate(c path.ContextPath) (r report.Report) {
+	if !selinuxModuleNameRegex.MatchString(m.Name) {
+		r.AddOnError(c.Append("name"), errors.ErrSelinuxModuleNameInvalid)
+	}
+	r.AddOnError(c.Append("compression"), m.validateCompression())
+	if m.Source == nil {
+		r.AddOnError(c.Append("source"), errors.ErrSelinuxModuleNoSource)
+	} else {
+		r.AddOnError(c.Append("source"), validateURL(*m.Source))
+	}
+	return
+}
+
+func (m SelinuxModule) validateCompression() error {
+	if m.Compression != nil {
+		switch *m.Compression {
+		case "", "gzip":
+		default:
+			return errors.ErrCompressionInvalid
+		}
+	}
+	return nil
+}
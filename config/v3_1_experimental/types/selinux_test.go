@@ -0,0 +1,66 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestSelinuxModuleValidate(t *testing.T) {
+	tests := []struct {
+		in  SelinuxModule
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: SelinuxModule{Name: "my-app_1", Source: util.StrToPtr("https://example.com/my-app.pp")},
+		},
+		{
+			in:  SelinuxModule{Name: "../my-app", Source: util.StrToPtr("https://example.com/my-app.pp")},
+			at:  path.New("", "name"),
+			out: errors.ErrSelinuxModuleNameInvalid,
+		},
+		{
+			in:  SelinuxModule{Name: "my-app"},
+			at:  path.New("", "source"),
+			out: errors.ErrSelinuxModuleNoSource,
+		},
+		{
+			in: SelinuxModule{
+				Name:        "my-app",
+				Source:      util.StrToPtr("https://example.com/my-app.pp.xz"),
+				Compression: util.StrToPtr("xz"),
+			},
+			at:  path.New("", "compression"),
+			out: errors.ErrCompressionInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+const testSignatureKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEZ8cYDPpisRSE9sA7Cm0aZVRuQXjo
+GpWBVlISB7+I/sYcctO0dmUopWmxAXo/mHW2KJAbD4stVSfXUboaIq6VGA==
+-----END PUBLIC KEY-----
+`
+
+func TestSignatureValidate(t *testing.T) {
+	tests := []struct {
+		in  Signature
+		out report.Report
+	}{
+		{
+			in: Signature{},
+		},
+		{
+			in: Signature{Keys: []string{testSignatureKey}},
+		},
+		{
+			in: Signature{Keys: []string{"-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nmDMEatBq6x==\n-----END PGP PUBLIC KEY BLOCK-----\n"}},
+		},
+		{
+			in: Signature{Keys: []string{testSignatureKey, "ssh-ed25519 AAAA"}},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrSignatureKeyInvalid.Error(), Context: path.New("json", "keys", 1)},
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New("json"))
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/coreos/vcontext/path"
+)
+
+// SourceReference is a URL which Ignition fetches, along with the
+// verification which applies to it and its path in the config.
+type SourceReference struct {
+	Source       *string
+	Verification Verification
+	Path         path.ContextPath
+}
+
+// Sources returns every URL in the config which Ignition fetches.
+func (cfg Config) Sources() (ret []SourceReference) {
+	for i, ref := range cfg.Ignition.Config.Merge {
+		ret = append(ret, SourceReference{ref.Source, ref.Verification, path.New("json", "ignition", "config", "merge", i, "source")})
+	}
+	replace := cfg.Ignition.Config.Replace
+	ret = append(ret, SourceReference{replace.Source, replace.Verification, path.New("json", "ignition", "config", "replace", "source")})
+	for i, ca := range cfg.Ignition.Security.TLS.CertificateAuthorities {
+		src := ca.Source
+		ret = append(ret, SourceReference{&src, ca.Verification, path.New("json", "ignition", "security", "tls", "certificateAuthorities", i, "source")})
+	}
+	for i, f := range cfg.Storage.Files {
+		ret = append(ret, SourceReference{f.Contents.Source, f.Contents.Verification, path.New("json", "storage", "files", i, "contents", "source")})
+		for j, a := range f.Append {
+			ret = append(ret, SourceReference{a.Source, a.Verification, path.New("json", "storage", "files", i, "append", j, "source")})
+		}
+	}
+	archive := cfg.Storage.EfiSystemPartition.Archive
+	ret = append(ret, SourceReference{archive.Source, archive.Verification, path.New("json", "storage", "efiSystemPartition", "archive", "source")})
+	return
+}
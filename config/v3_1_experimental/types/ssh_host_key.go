@@ -0,0 +1,84 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// sshHostKeyAlgorithms maps each host key type to the algorithm names its
+// public keys may have.
+var sshHostKeyAlgorithms = map[string][]string{
+	"rsa":     {"ssh-rsa"},
+	"ecdsa":   {"ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521"},
+	"ed25519": {"ssh-ed25519"},
+}
+
+func (k SSHHostKey) Key() string {
+	return k.Type
+}
+
+func (k SSHHostKey) Validate(c path.ContextPath) (r report.Report) {
+	algorithms, ok := sshHostKeyAlgorithms[k.Type]
+	if !ok {
+		r.AddOnError(c.Append("type"), errors.ErrSSHHostKeyTypeInvalid)
+	}
+	if k.Source == nil {
+		r.AddOnError(c.Append("source"), errors.ErrSSHHostKeyNoSource)
+	} else {
+		r.AddOnError(c.Append("source"), validateURL(*k.Source))
+	}
+	if ok && !hasAlgorithm(k.PublicKey, algorithms) {
+		r.AddOnError(c.Append("publicKey"), errors.ErrSSHHostKeyPublicKeyInvalid)
+	}
+	return
+}
+
+func hasAlgorithm(publicKey string, algorithms []string) bool {
+	fields := strings.Fields(publicKey)
+	if len(fields) < 2 || strings.ContainsAny(publicKey, "\r\n") {
+		return false
+	}
+	for _, algorithm := range algorithms {
+		if fields[0] == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+func (k SSHHostKeys) Validate(c path.ContextPath) (r report.Report) {
+	allowed := map[string]struct{}{}
+	for i, t := range k.Types {
+		if _, ok := sshHostKeyAlgorithms[t]; !ok {
+			r.AddOnError(c.Append("types", i), errors.ErrSSHHostKeyTypeInvalid)
+		}
+		allowed[t] = struct{}{}
+	}
+	if len(k.Types) == 0 {
+		return
+	}
+	for i, key := range k.Keys {
+		if _, ok := allowed[key.Type]; !ok {
+			r.AddOnError(c.Append("keys", i, "type"), errors.ErrSSHHostKeyTypeNotAllowed)
+		}
+	}
+	return
+}
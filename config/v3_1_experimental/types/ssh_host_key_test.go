@@ -0,0 +1,104 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestSSHHostKeyValidate(t *testing.T) {
+	tests := []struct {
+		in  SSHHostKey
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: SSHHostKey{Type: "ed25519", Source: util.StrToPtr("https://example.com/key"), PublicKey: "ssh-ed25519 AAAAC3Nza host"},
+		},
+		{
+			in: SSHHostKey{Type: "ecdsa", Source: util.StrToPtr("https://example.com/key"), PublicKey: "ecdsa-sha2-nistp384 AAAAE2Vj"},
+		},
+		{
+			in:  SSHHostKey{Type: "dsa", Source: util.StrToPtr("https://example.com/key"), PublicKey: "ssh-dss AAAAB3Nz"},
+			at:  path.New("", "type"),
+			out: errors.ErrSSHHostKeyTypeInvalid,
+		},
+		{
+			in:  SSHHostKey{Type: "rsa", PublicKey: "ssh-rsa AAAAB3Nz"},
+			at:  path.New("", "source"),
+			out: errors.ErrSSHHostKeyNoSource,
+		},
+		{
+			in:  SSHHostKey{Type: "rsa", Source: util.StrToPtr("https://example.com/key"), PublicKey: "ssh-ed25519 AAAAC3Nza"},
+			at:  path.New("", "publicKey"),
+			out: errors.ErrSSHHostKeyPublicKeyInvalid,
+		},
+		{
+			in:  SSHHostKey{Type: "rsa", Source: util.StrToPtr("https://example.com/key"), PublicKey: "ssh-rsa"},
+			at:  path.New("", "publicKey"),
+			out: errors.ErrSSHHostKeyPublicKeyInvalid,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
+
+func TestSSHHostKeysValidate(t *testing.T) {
+	tests := []struct {
+		in  SSHHostKeys
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: SSHHostKeys{Keys: []SSHHostKey{{Type: "rsa"}}},
+		},
+		{
+			in: SSHHostKeys{Keys: []SSHHostKey{{Type: "ed25519"}}, Types: []string{"ed25519", "ecdsa"}},
+		},
+		{
+			in:  SSHHostKeys{Types: []string{"ed25519", "dsa"}},
+			at:  path.New("", "types", 1),
+			out: errors.ErrSSHHostKeyTypeInvalid,
+		},
+		{
+			in:  SSHHostKeys{Keys: []SSHHostKey{{Type: "rsa"}}, Types: []string{"ed25519"}},
+			at:  path.New("", "keys", 0, "type"),
+			out: errors.ErrSSHHostKeyTypeNotAllowed,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
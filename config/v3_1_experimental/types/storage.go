@@ -15,12 +15,13 @@
 package types
 
 import (
-	"path/filepath"
+	"path"
 	"strings"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
 
-	"github.com/coreos/vcontext/path"
+	cpath "github.com/coreos/vcontext/path"
 	"github.com/coreos/vcontext/report"
 )
 
@@ -32,20 +33,30 @@ func (s Storage) MergedKeys() map[string]string {
 	}
 }
 
-func (s Storage) Validate(c path.ContextPath) (r report.Report) {
+func (s Storage) Validate(c cpath.ContextPath) (r report.Report) {
 	for i, d := range s.Directories {
 		for _, l := range s.Links {
 			if strings.HasPrefix(d.Path, l.Path+"/") {
 				r.AddOnError(c.Append("directories", i), errors.ErrDirectoryUsedSymlink)
 			}
 		}
+		for j, f := range s.Files {
+			if strings.HasPrefix(d.Path, f.Path+"/") {
+				r.AddOnError(c.Append("directories", i), conflictError(errors.ErrDirectoryUsedFile, c.Append("files", j)))
+			}
+		}
 	}
-	for i, f := range s.Files {
+	for i, f1 := range s.Files {
 		for _, l := range s.Links {
-			if strings.HasPrefix(f.Path, l.Path+"/") {
+			if strings.HasPrefix(f1.Path, l.Path+"/") {
 				r.AddOnError(c.Append("files", i), errors.ErrFileUsedSymlink)
 			}
 		}
+		for j, f2 := range s.Files {
+			if strings.HasPrefix(f1.Path, f2.Path+"/") {
+				r.AddOnError(c.Append("files", i), conflictError(errors.ErrFileUsedFile, c.Append("files", j)))
+			}
+		}
 	}
 	for i, l1 := range s.Links {
 		for _, l2 := range s.Links {
@@ -53,12 +64,17 @@ func (s Storage) Validate(c path.ContextPath) (r report.Report) {
 				r.AddOnError(c.Append("links", i), errors.ErrLinkUsedSymlink)
 			}
 		}
+		for j, f := range s.Files {
+			if strings.HasPrefix(l1.Path, f.Path+"/") {
+				r.AddOnError(c.Append("links", i), conflictError(errors.ErrLinkUsedFile, c.Append("files", j)))
+			}
+		}
 		if l1.Hard == nil || !*l1.Hard {
 			continue
 		}
-		target := filepath.Clean(l1.Target)
-		if !filepath.IsAbs(target) {
-			target = filepath.Join(l1.Path, l1.Target)
+		target := path.Clean(l1.Target)
+		if !path.IsAbs(target) {
+			target = path.Join(l1.Path, l1.Target)
 		}
 		for _, d := range s.Directories {
 			if target == d.Path {
@@ -66,5 +82,85 @@ func (s Storage) Validate(c path.ContextPath) (r report.Report) {
 			}
 		}
 	}
+	r.AddOnError(c.Append("efiSystemPartition", "path"), s.validateEfiFilesystem())
+	if s.Prune != nil && *s.Prune && (s.Manifest == nil || !*s.Manifest) {
+		r.AddOnError(c.Append("prune"), errors.ErrPruneWithoutManifest)
+	}
+	for i, fs := range s.Filesystems {
+		r.AddOnWarn(c.Append("filesystems", i, "device"), s.validateFilesystemDevice(fs.Device))
+	}
+	r.Merge(s.validateUnmountedFilesystems(c))
+	return
+}
+
+// validateFilesystemDevice warns if device refers to a partition label or
+// RAID array which is usually created by Ignition but isn't declared.
+func (s Storage) validateFilesystemDevice(device string) error {
+	device = path.Clean(device)
+	switch {
+	case strings.HasPrefix(device, "/dev/disk/by-partlabel/"):
+		for _, d := range s.Disks {
+			for _, p := range d.Partitions {
+				if p.Label != nil && device == "/dev/disk/by-partlabel/"+*p.Label {
+					return nil
+				}
+			}
+		}
+		return errors.ErrDeviceNotDeclared
+	case strings.HasPrefix(device, "/dev/md/"):
+		for _, raid := range s.Raid {
+			if device == "/dev/md/"+raid.Name {
+				return nil
+			}
+		}
+		return errors.ErrDeviceNotDeclared
+	}
+	return nil
+}
+
+// validateUnmountedFilesystems warns about nodes beneath the path of a
+// filesystem which isn't mounted, since they would be written to the
+// filesystem containing that path instead.
+func (s Storage) validateUnmountedFilesystems(c cpath.ContextPath) (r report.Report) {
+	for i, fs := range s.Filesystems {
+		if util.NilOrEmpty(fs.Path) || fs.Format == nil || *fs.Format != "swap" {
+			continue
+		}
+		prefix := path.Clean(*fs.Path) + "/"
+		for j, d := range s.Directories {
+			if strings.HasPrefix(d.Path, prefix) {
+				r.AddOnWarn(c.Append("directories", j), conflictError(errors.ErrNodeUnderUnmountedFs, c.Append("filesystems", i)))
+			}
+		}
+		for j, f := range s.Files {
+			if strings.HasPrefix(f.Path, prefix) {
+				r.AddOnWarn(c.Append("files", j), conflictError(errors.ErrNodeUnderUnmountedFs, c.Append("filesystems", i)))
+			}
+		}
+		for j, l := range s.Links {
+			if strings.HasPrefix(l.Path, prefix) {
+				r.AddOnWarn(c.Append("links", j), conflictError(errors.ErrNodeUnderUnmountedFs, c.Append("filesystems", i)))
+			}
+		}
+	}
 	return
 }
+
+// validateEfiFilesystem checks that the EFI System Partition is one of the
+// declared filesystems, so that it is mounted before it is populated.
+func (s Storage) validateEfiFilesystem() error {
+	esp := s.EfiSystemPartition.Path
+	if util.NilOrEmpty(esp) {
+		return nil
+	}
+	for _, fs := range s.Filesystems {
+		if fs.Path == nil || *fs.Path != *esp {
+			continue
+		}
+		if fs.Format == nil || *fs.Format != "vfat" {
+			return errors.ErrEfiFilesystemNotVfat
+		}
+		return nil
+	}
+	return errors.ErrEfiFilesystemUndefined
+}
@@ -137,6 +137,99 @@ func TestStorageValidate(t *testing.T) {
 			out: errors.ErrHardLinkToDirectory,
 			at:  path.New("", "links", 0),
 		},
+		{
+			in: Storage{
+				Filesystems: []Filesystem{
+					{
+						Device: "/dev/sda1",
+						Format: util.StrToPtr("vfat"),
+						Path:   util.StrToPtr("/boot/efi"),
+					},
+				},
+				EfiSystemPartition: EfiSystemPartition{
+					Path: util.StrToPtr("/boot/efi"),
+				},
+			},
+			out: nil,
+		},
+		{
+			in: Storage{
+				Filesystems: []Filesystem{
+					{
+						Device: "/dev/sda1",
+						Format: util.StrToPtr("ext4"),
+						Path:   util.StrToPtr("/boot/efi"),
+					},
+				},
+				EfiSystemPartition: EfiSystemPartition{
+					Path: util.StrToPtr("/boot/efi"),
+				},
+			},
+			out: errors.ErrEfiFilesystemNotVfat,
+			at:  path.New("", "efiSystemPartition", "path"),
+		},
+		{
+			in: Storage{
+				EfiSystemPartition: EfiSystemPartition{
+					Path: util.StrToPtr("/boot/efi"),
+				},
+			},
+			out: errors.ErrEfiFilesystemUndefined,
+			at:  path.New("", "efiSystemPartition", "path"),
+		},
+		{
+			in: Storage{
+				Files: []File{
+					{
+						Node: Node{Path: "/foo"},
+					},
+				},
+				Directories: []Directory{
+					{
+						Node: Node{Path: "/foo/bar"},
+					},
+				},
+			},
+			out: conflictError(errors.ErrDirectoryUsedFile, path.New("", "files", 0)),
+			at:  path.New("", "directories", 0),
+		},
+		{
+			in: Storage{
+				Files: []File{
+					{
+						Node: Node{Path: "/foo"},
+					},
+					{
+						Node: Node{Path: "/foo/bar"},
+					},
+				},
+			},
+			out: conflictError(errors.ErrFileUsedFile, path.New("", "files", 0)),
+			at:  path.New("", "files", 1),
+		},
+		{
+			in: Storage{
+				Files: []File{
+					{
+						Node: Node{Path: "/foo"},
+					},
+				},
+				Links: []Link{
+					{
+						Node: Node{Path: "/foo/bar"},
+					},
+				},
+			},
+			out: conflictError(errors.ErrLinkUsedFile, path.New("", "files", 0)),
+			at:  path.New("", "links", 0),
+		},
+		{
+			in: Storage{
+				Prune: util.BoolToPtr(true),
+			},
+			out: errors.ErrPruneWithoutManifest,
+			at:  path.New("", "prune"),
+		},
 	}
 
 	for i, test := range tests {
@@ -148,3 +241,76 @@ func TestStorageValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestStorageValidateFilesystemDevice(t *testing.T) {
+	storage := Storage{
+		Disks: []Disk{
+			{
+				Device:     "/dev/sda",
+				Partitions: []Partition{{Label: util.StrToPtr("data")}},
+			},
+		},
+		Raid: []Raid{{Name: "array"}},
+	}
+	tests := []struct {
+		in  string
+		out error
+	}{
+		{"/dev/sda1", nil},
+		{"/dev/disk/by-label/root", nil},
+		{"/dev/disk/by-partlabel/data", nil},
+		{"/dev/disk/by-partlabel/other", errors.ErrDeviceNotDeclared},
+		{"/dev/md/array", nil},
+		{"/dev/md//array", nil},
+		{"/dev/md/other", errors.ErrDeviceNotDeclared},
+	}
+
+	for i, test := range tests {
+		if err := storage.validateFilesystemDevice(test.in); err != test.out {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.out, err)
+		}
+	}
+}
+
+func TestStorageValidateUnmountedFilesystems(t *testing.T) {
+	tests := []struct {
+		in  Storage
+		out report.Report
+	}{
+		{
+			in: Storage{
+				Filesystems: []Filesystem{{Device: "/dev/sdb", Format: util.StrToPtr("xfs"), Path: util.StrToPtr("/var")}},
+				Files:       []File{{Node: Node{Path: "/var/foo"}}},
+			},
+		},
+		{
+			in: Storage{
+				Filesystems: []Filesystem{{Device: "/dev/sdb", Format: util.StrToPtr("swap"), Path: util.StrToPtr("/var")}},
+				Directories: []Directory{{Node: Node{Path: "/var/foo"}}},
+				Files:       []File{{Node: Node{Path: "/variable"}}},
+				Links:       []Link{{Node: Node{Path: "/var/bar"}}},
+			},
+			out: report.Report{
+				Entries: []report.Entry{
+					{
+						Kind:    report.Warn,
+						Message: conflictError(errors.ErrNodeUnderUnmountedFs, path.New("", "filesystems", 0)).Error(),
+						Context: path.New("", "directories", 0),
+					},
+					{
+						Kind:    report.Warn,
+						Message: conflictError(errors.ErrNodeUnderUnmountedFs, path.New("", "filesystems", 0)).Error(),
+						Context: path.New("", "links", 0),
+					},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.validateUnmountedFilesystems(path.New(""))
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
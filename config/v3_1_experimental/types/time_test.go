@@ -0,0 +1,52 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestTimeSourceValidate(t *testing.T) {
+	tests := []struct {
+		in  TimeSource
+		out error
+	}{
+		{"time.example.com", nil},
+		{"ntp1", nil},
+		{"2.fedora.pool.ntp.org.", nil},
+		{"192.0.2.1", nil},
+		{"2001:db8::1", nil},
+		{"", errors.ErrTimeSourceInvalid},
+		{"time.example.com iburst", errors.ErrTimeSourceInvalid},
+		{"-time.example.com", errors.ErrTimeSourceInvalid},
+		{"time..example.com", errors.ErrTimeSourceInvalid},
+		{"time.example.com\nserver evil.example.com", errors.ErrTimeSourceInvalid},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(path.ContextPath{}, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"regexp"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+var (
+	trustedCertNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+)
+
+func (tc TrustedCertificate) Key() string {
+	return tc.Name
+}
+
+func (tc TrustedCertificate) Validate(c path.ContextPath) (r report.Report) {
+	if !trustedCertNameRegex.MatchString(tc.Name) {
+		r.AddOnError(c.Append("name"), errors.ErrTrustedCertNameInvalid)
+	}
+	if tc.Source == nil {
+		r.AddOnError(c.Append("source"), errors.ErrTrustedCertNoSource)
+	} else {
+		r.AddOnError(c.Append("source"), validateURL(*tc.Source))
+	}
+	return
+}
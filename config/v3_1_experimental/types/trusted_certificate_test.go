@@ -0,0 +1,62 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestTrustedCertificateValidate(t *testing.T) {
+	tests := []struct {
+		in  TrustedCertificate
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: TrustedCertificate{Name: "corp-root.v2", Source: util.StrToPtr("https://example.com/root.pem")},
+		},
+		{
+			in:  TrustedCertificate{Name: "..", Source: util.StrToPtr("https://example.com/root.pem")},
+			at:  path.New("", "name"),
+			out: errors.ErrTrustedCertNameInvalid,
+		},
+		{
+			in:  TrustedCertificate{Name: "certs/root", Source: util.StrToPtr("https://example.com/root.pem")},
+			at:  path.New("", "name"),
+			out: errors.ErrTrustedCertNameInvalid,
+		},
+		{
+			in:  TrustedCertificate{Name: "corp-root"},
+			at:  path.New("", "source"),
+			out: errors.ErrTrustedCertNoSource,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
@@ -38,6 +38,7 @@ func (d Dropin) Key() string {
 
 func (u Unit) Validate(c cpath.ContextPath) (r report.Report) {
 	r.AddOnError(c.Append("name"), validateName(u.Name))
+	r.AddOnError(c.Append("onFailure"), validateOnFailure(u.OnFailure))
 	c = c.Append("contents")
 	opts, err := validateUnitContent(u.Contents)
 	r.AddOnError(c, err)
@@ -21,6 +21,10 @@ import (
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/scheme"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
 func validateURL(s string) error {
@@ -39,12 +43,26 @@ func validateURL(s string) error {
 			}
 		}
 		return nil
+	case "http+unix":
+		if _, _, ok := util.SplitUnixSocketURL(*u); !ok {
+			return errors.ErrInvalidUnixSocketURL
+		}
+		return nil
+	case "vault":
+		if u.Fragment == "" {
+			return errors.ErrVaultFieldRequired
+		}
+		return nil
 	case "data":
-		if _, err := dataurl.DecodeString(s); err != nil {
+		plain, _ := util.SplitZstdDataURL(s)
+		if _, err := dataurl.DecodeString(plain); err != nil {
 			return err
 		}
 		return nil
 	default:
+		if scheme.Get(u.Scheme) != nil {
+			return nil
+		}
 		return errors.ErrInvalidScheme
 	}
 }
@@ -55,3 +73,31 @@ func validateURLNilOK(s *string) error {
 	}
 	return validateURL(*s)
 }
+
+// validateMirrors checks the mirrors of source, which are fetched in its
+// place if it can't be. If httpOnly, they and source must be http(s) URLs.
+func validateMirrors(c path.ContextPath, source *string, mirrors []string, httpOnly bool) (r report.Report) {
+	if len(mirrors) == 0 {
+		return
+	}
+	if util.NilOrEmpty(source) {
+		r.AddOnError(c, errors.ErrMirrorsWithoutSource)
+		return
+	}
+	if httpOnly && !httpURL(*source) {
+		r.AddOnError(c, errors.ErrMirrorScheme)
+	}
+	for i, mirror := range mirrors {
+		if httpOnly && !httpURL(mirror) {
+			r.AddOnError(c.Append(i), errors.ErrMirrorScheme)
+		} else {
+			r.AddOnError(c.Append(i), validateURL(mirror))
+		}
+	}
+	return
+}
+
+func httpURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -15,13 +15,28 @@
 package types
 
 import (
+	"io"
+	"net/url"
 	"testing"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/scheme"
 )
 
+type consulFetcher struct{}
+
+func (consulFetcher) Name() string {
+	return "consul"
+}
+
+func (consulFetcher) Fetch(_ url.URL) (io.ReadCloser, error) {
+	return nil, nil
+}
+
 func TestURLValidate(t *testing.T) {
+	scheme.Register(consulFetcher{})
+
 	tests := []struct {
 		in  *string
 		out error
@@ -46,6 +61,14 @@ func TestURLValidate(t *testing.T) {
 			util.StrToPtr("tftp://example.com:69/foobar.txt"),
 			nil,
 		},
+		{
+			util.StrToPtr("http+unix:/run/provision.sock:/config"),
+			nil,
+		},
+		{
+			util.StrToPtr("http+unix:/run/provision.sock"),
+			errors.ErrInvalidUnixSocketURL,
+		},
 		{
 			util.StrToPtr("data:,example%20file%0A"),
 			nil,
@@ -54,6 +77,18 @@ func TestURLValidate(t *testing.T) {
 			util.StrToPtr("bad://"),
 			errors.ErrInvalidScheme,
 		},
+		{
+			util.StrToPtr("vault://secret/data/tls#cert"),
+			nil,
+		},
+		{
+			util.StrToPtr("vault://secret/data/tls"),
+			errors.ErrVaultFieldRequired,
+		},
+		{
+			util.StrToPtr("consul://kv/motd"),
+			nil,
+		},
 		{
 			util.StrToPtr("s3://bucket/key"),
 			nil,
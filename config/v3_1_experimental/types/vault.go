@@ -0,0 +1,90 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"net/url"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func (v Vault) Validate(c path.ContextPath) (r report.Report) {
+	methods := 0
+	if !util.NilOrEmpty(v.Token) {
+		methods++
+	}
+	if v.AppRole.IsSet() {
+		methods++
+	}
+	if v.Identity.IsSet() {
+		methods++
+	}
+
+	if util.NilOrEmpty(v.Address) {
+		if methods > 0 {
+			r.AddOnError(c.Append("address"), errors.ErrVaultAddressRequired)
+		}
+		return
+	}
+	if u, err := url.Parse(*v.Address); err != nil {
+		r.AddOnError(c.Append("address"), errors.ErrInvalidUrl)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		r.AddOnError(c.Append("address"), errors.ErrInvalidScheme)
+	} else if u.Scheme == "http" {
+		r.AddOnWarn(c.Append("address"), errors.ErrVaultInsecure)
+	}
+
+	switch {
+	case methods == 0:
+		r.AddOnError(c, errors.ErrVaultAuthRequired)
+	case methods > 1:
+		r.AddOnError(c, errors.ErrVaultMultipleAuth)
+	}
+	return
+}
+
+// IsSet returns true if any field of the AppRole credentials is set.
+func (a VaultAppRole) IsSet() bool {
+	return a.RoleID != nil || a.SecretID != nil
+}
+
+func (a VaultAppRole) Validate(c path.ContextPath) (r report.Report) {
+	if a.IsSet() && util.NilOrEmpty(a.RoleID) {
+		r.AddOnError(c.Append("roleId"), errors.ErrVaultRoleIDRequired)
+	}
+	return
+}
+
+// IsSet returns true if any field of the identity login is set.
+func (i VaultIdentity) IsSet() bool {
+	return i.Method != nil || i.Role != nil
+}
+
+func (i VaultIdentity) Validate(c path.ContextPath) (r report.Report) {
+	if !i.IsSet() {
+		return
+	}
+	if i.Method == nil || (*i.Method != "aws" && *i.Method != "gcp") {
+		r.AddOnError(c.Append("method"), errors.ErrVaultIdentityMethod)
+	}
+	if util.NilOrEmpty(i.Role) {
+		r.AddOnError(c.Append("role"), errors.ErrVaultRoleRequired)
+	}
+	return
+}
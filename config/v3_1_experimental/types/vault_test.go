@@ -0,0 +1,124 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+func TestVaultValidate(t *testing.T) {
+	tests := []struct {
+		in  Vault
+		out report.Report
+	}{
+		{
+			in: Vault{},
+		},
+		{
+			in: Vault{
+				Address: util.StrToPtr("https://vault.example.com:8200"),
+				Token:   util.StrToPtr("s.token"),
+			},
+		},
+		{
+			in: Vault{
+				Address:  util.StrToPtr("https://vault.example.com:8200"),
+				Identity: VaultIdentity{Method: util.StrToPtr("aws"), Role: util.StrToPtr("ignition")},
+			},
+		},
+		{
+			in: Vault{Token: util.StrToPtr("s.token")},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrVaultAddressRequired.Error(), Context: path.New("json", "address")},
+			}},
+		},
+		{
+			in: Vault{Address: util.StrToPtr("https://vault.example.com:8200")},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrVaultAuthRequired.Error(), Context: path.New("json")},
+			}},
+		},
+		{
+			in: Vault{
+				Address: util.StrToPtr("http://vault.example.com:8200"),
+				Token:   util.StrToPtr("s.token"),
+				AppRole: VaultAppRole{RoleID: util.StrToPtr("role")},
+			},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Warn, Message: errors.ErrVaultInsecure.Error(), Context: path.New("json", "address")},
+				{Kind: report.Error, Message: errors.ErrVaultMultipleAuth.Error(), Context: path.New("json")},
+			}},
+		},
+		{
+			in: Vault{
+				Address: util.StrToPtr("ftp://vault.example.com"),
+				Token:   util.StrToPtr("s.token"),
+			},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrInvalidScheme.Error(), Context: path.New("json", "address")},
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New("json"))
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
+
+func TestVaultAuthValidate(t *testing.T) {
+	tests := []struct {
+		in interface {
+			Validate(path.ContextPath) report.Report
+		}
+		out report.Report
+	}{
+		{
+			in: VaultAppRole{RoleID: util.StrToPtr("role"), SecretID: util.StrToPtr("secret")},
+		},
+		{
+			in: VaultAppRole{SecretID: util.StrToPtr("secret")},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrVaultRoleIDRequired.Error(), Context: path.New("json", "roleId")},
+			}},
+		},
+		{
+			in: VaultIdentity{Method: util.StrToPtr("gcp"), Role: util.StrToPtr("ignition")},
+		},
+		{
+			in: VaultIdentity{Method: util.StrToPtr("azure")},
+			out: report.Report{Entries: []report.Entry{
+				{Kind: report.Error, Message: errors.ErrVaultIdentityMethod.Error(), Context: path.New("json", "method")},
+				{Kind: report.Error, Message: errors.ErrVaultRoleRequired.Error(), Context: path.New("json", "role")},
+			}},
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.New("json"))
+		if !reflect.DeepEqual(test.out, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, test.out, r)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
 
 	"github.com/coreos/vcontext/path"
 	"github.com/coreos/vcontext/report"
@@ -40,12 +41,22 @@ func (v Verification) HashParts() (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
+// Specified reports whether v verifies anything, either with a hash or with
+// an entry of a checksums manifest.
+func (v Verification) Specified() bool {
+	return v.Hash != nil || v.Checksums.Source != nil
+}
+
 func (v Verification) Validate(c path.ContextPath) (r report.Report) {
-	c = c.Append("hash")
+	r.Merge(v.Checksums.validate(c.Append("checksums")))
 	if v.Hash == nil {
 		// The hash can be nil
 		return
 	}
+	c = c.Append("hash")
+	if v.Checksums.Source != nil {
+		r.AddOnError(c, errors.ErrHashWithChecksums)
+	}
 
 	function, sum, err := v.HashParts()
 	if err != nil {
@@ -54,6 +65,8 @@ func (v Verification) Validate(c path.ContextPath) (r report.Report) {
 	}
 	var hash crypto.Hash
 	switch function {
+	case "sha256":
+		hash = crypto.SHA256
 	case "sha512":
 		hash = crypto.SHA512
 	default:
@@ -67,3 +80,20 @@ func (v Verification) Validate(c path.ContextPath) (r report.Report) {
 
 	return
 }
+
+func (vc VerificationChecksums) validate(c path.ContextPath) (r report.Report) {
+	if vc.Source == nil {
+		if vc.Name != nil || vc.Signature != nil {
+			r.AddOnError(c.Append("source"), errors.ErrChecksumsNoSource)
+		}
+		return
+	}
+	r.AddOnError(c.Append("source"), validateURL(*vc.Source))
+	if util.NilOrEmpty(vc.Name) {
+		r.AddOnError(c.Append("name"), errors.ErrChecksumsNoName)
+	}
+	if vc.Signature != nil {
+		r.AddOnError(c.Append("signature"), validateURL(*vc.Signature))
+	}
+	return
+}
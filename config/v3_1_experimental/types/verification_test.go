@@ -54,6 +54,8 @@ func TestHashValidate(t *testing.T) {
 	h1 := "xor-abcdef"
 	h2 := "sha512-123"
 	h3 := "sha512-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	h4 := "sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	h5 := "sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
 
 	tests := []struct {
 		in  Verification
@@ -71,6 +73,14 @@ func TestHashValidate(t *testing.T) {
 			Verification{Hash: &h3},
 			nil,
 		},
+		{
+			Verification{Hash: &h4},
+			nil,
+		},
+		{
+			Verification{Hash: &h5},
+			errors.ErrHashWrongSize,
+		},
 	}
 
 	for i, test := range tests {
@@ -82,3 +92,44 @@ func TestHashValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestChecksumsValidate(t *testing.T) {
+	hash := "sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	source := "https://example.com/SHA256SUMS"
+	name := "image.raw"
+	signature := "https://example.com/SHA256SUMS.sig"
+
+	tests := []struct {
+		in  Verification
+		at  path.ContextPath
+		out error
+	}{
+		{
+			in: Verification{Checksums: VerificationChecksums{Source: &source, Name: &name, Signature: &signature}},
+		},
+		{
+			in:  Verification{Checksums: VerificationChecksums{Source: &source}},
+			at:  path.New("", "checksums", "name"),
+			out: errors.ErrChecksumsNoName,
+		},
+		{
+			in:  Verification{Checksums: VerificationChecksums{Name: &name}},
+			at:  path.New("", "checksums", "source"),
+			out: errors.ErrChecksumsNoSource,
+		},
+		{
+			in:  Verification{Checksums: VerificationChecksums{Source: &source, Name: &name}, Hash: &hash},
+			at:  path.New("", "hash"),
+			out: errors.ErrHashWithChecksums,
+		},
+	}
+
+	for i, test := range tests {
+		r := test.in.Validate(path.ContextPath{})
+		expected := report.Report{}
+		expected.AddOnError(test.at, test.out)
+		if !reflect.DeepEqual(expected, r) {
+			t.Errorf("#%d: bad report: want %v, got %v", i, expected, r)
+		}
+	}
+}
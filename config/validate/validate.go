@@ -100,9 +100,23 @@ func ValidateUnusedKeys(v reflect.Value, c path.ContextPath, root tree.Node) (r
 	return
 }
 
+// ownPath wraps f so that it is called with a copy of the context path.
+// ContextPath.Append may share its backing array with the paths of sibling
+// fields, so without the copy entries can end up pointing at another field.
+func ownPath(f validate.CustomValidator) validate.CustomValidator {
+	return func(v reflect.Value, c path.ContextPath) report.Report {
+		if len(c.Path) == 0 {
+			return f(v, c)
+		}
+		p := make([]interface{}, len(c.Path))
+		copy(p, c.Path)
+		return f(v, path.ContextPath{Path: p, Tag: c.Tag})
+	}
+}
+
 func ValidateWithContext(cfg interface{}, raw []byte) report.Report {
-	r := validate.Validate(cfg, "json")
-	r.Merge(validate.ValidateCustom(cfg, "json", ValidateDups))
+	r := validate.ValidateCustom(cfg, "json", ownPath(validate.DefaultValidator))
+	r.Merge(validate.ValidateCustom(cfg, "json", ownPath(ValidateDups)))
 	if raw == nil {
 		return r
 	}
@@ -111,8 +125,24 @@ func ValidateWithContext(cfg interface{}, raw []byte) report.Report {
 		unusedKeyCheck := func(v reflect.Value, c path.ContextPath) report.Report {
 			return ValidateUnusedKeys(v, c, cxt)
 		}
-		r.Merge(validate.ValidateCustom(cfg, "json", unusedKeyCheck))
+		r.Merge(validate.ValidateCustom(cfg, "json", ownPath(unusedKeyCheck)))
 		r.Correlate(cxt)
 	}
 	return r
 }
+
+// ValidateUnusedKeysWithContext only reports keys in raw which aren't fields
+// of cfg. It is used for legacy configs which are translated rather than
+// validated, so that settings which would be dropped aren't dropped silently.
+func ValidateUnusedKeysWithContext(cfg interface{}, raw []byte) (r report.Report) {
+	cxt, err := json.UnmarshalToContext(raw)
+	if err != nil {
+		return
+	}
+	unusedKeyCheck := func(v reflect.Value, c path.ContextPath) report.Report {
+		return ValidateUnusedKeys(v, c, cxt)
+	}
+	r = validate.ValidateCustom(cfg, "json", ownPath(unusedKeyCheck))
+	r.Correlate(cxt)
+	return
+}
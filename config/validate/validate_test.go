@@ -95,6 +95,37 @@ func (t test5) MergedKeys() map[string]string {
 	}
 }
 
+type testSiblings struct{}
+
+func (t testSiblings) Key() string {
+	return ""
+}
+
+func (t testSiblings) Validate(c path.ContextPath) (r report.Report) {
+	r.AddOnError(c.Append("a"), dummy)
+	r.AddOnError(c.Append("b"), dummy)
+	return
+}
+
+type test6 struct {
+	Inner struct {
+		Items []testSiblings `json:"items"`
+	} `json:"inner"`
+}
+
+func TestValidateWithContextPaths(t *testing.T) {
+	in := test6{}
+	in.Inner.Items = make([]testSiblings, 1)
+	var expected report.Report
+	expected.AddOnError(path.New("json", "inner", "items", 0, "a"), dummy)
+	expected.AddOnError(path.New("json", "inner", "items", 0, "b"), dummy)
+
+	r := ValidateWithContext(in, nil)
+	if !reflect.DeepEqual(expected, r) {
+		t.Errorf("bad report: want %+v got %+v", expected, r)
+	}
+}
+
 func TestValidateWithContext(t *testing.T) {
 	tests := []struct {
 		in    interface{}
@@ -0,0 +1,179 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package engine runs Ignition's stages from Go, so image build tools and
+// tests can embed Ignition instead of running the binary inside a fake
+// initramfs. The stages act on the filesystem below the given root and
+// behave exactly as they do when run by the ignition binary.
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coreos/ignition/v2/config"
+	"github.com/coreos/ignition/v2/internal/exec"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
+	_ "github.com/coreos/ignition/v2/internal/exec/stages/disks"
+	_ "github.com/coreos/ignition/v2/internal/exec/stages/fetch"
+	_ "github.com/coreos/ignition/v2/internal/exec/stages/fetch_offline"
+	_ "github.com/coreos/ignition/v2/internal/exec/stages/files"
+	_ "github.com/coreos/ignition/v2/internal/exec/stages/mount"
+	_ "github.com/coreos/ignition/v2/internal/exec/stages/umount"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/platform"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+// FetcherOptions configures how the engine fetches the config and the
+// resources it references. The zero value uses the same defaults as the
+// ignition binary, except that Limits is unlimited.
+type FetcherOptions struct {
+	// Timeout is the initial duration for which to wait for the config.
+	// If zero, the binary's default is used.
+	Timeout time.Duration
+
+	// MaxDataURLSize is the maximum size in bytes of the decoded contents
+	// of data URLs. If zero, the binary's default is used.
+	MaxDataURLSize int64
+
+	// MaxFetchMemory and MaxTotalFetchMemory are the maximum number of
+	// bytes of fetched contents one fetch, and all fetches in progress
+	// together, may hold in memory. If zero, the binary's defaults are
+	// used.
+	MaxFetchMemory      int64
+	MaxTotalFetchMemory int64
+
+	// AcceptYAML allows configs to be written in YAML as well as JSON.
+	AcceptYAML bool
+
+	// Limits bounds the size and complexity of the configs.
+	Limits config.Limits
+}
+
+// Engine runs stages for a single platform against a root filesystem.
+type Engine struct {
+	// ConfigCache is where the fetched config is cached between stages.
+	// If empty, Run caches the config in a temporary file which is
+	// removed when it returns, so every call to Run fetches the config
+	// again.
+	ConfigCache string
+
+	root     string
+	platform platform.Config
+	fetcher  FetcherOptions
+}
+
+// NewEngine returns an engine which fetches the config the way the named
+// platform does and applies it to the filesystem below root. The "file"
+// platform reads the config from the file named by $IGNITION_CONFIG_FILE.
+func NewEngine(root, platformName string, fetcher FetcherOptions) (*Engine, error) {
+	p, ok := platform.Get(platformName)
+	if !ok {
+		return nil, fmt.Errorf("unknown platform %q", platformName)
+	}
+	if fetcher.Timeout == 0 {
+		fetcher.Timeout = exec.DefaultFetchTimeout
+	}
+	if fetcher.MaxFetchMemory == 0 {
+		fetcher.MaxFetchMemory = resource.DefaultMaxFetchMemory
+	}
+	if fetcher.MaxTotalFetchMemory == 0 {
+		fetcher.MaxTotalFetchMemory = resource.DefaultMaxTotalFetchMemory
+	}
+	return &Engine{
+		root:     root,
+		platform: p,
+		fetcher:  fetcher,
+	}, nil
+}
+
+// Stages returns the names of the stages which can be run, such as "fetch",
+// "disks", and "files".
+func Stages() []string {
+	return stages.Names()
+}
+
+// Run runs the named stages in order, stopping at the first one which
+// fails. Unlike the ignition binary, it logs to stdout and doesn't report
+// the result to the platform. As in the binary, "fetch-offline" succeeds
+// without fetching anything which requires networking, and creates
+// /run/ignition/neednet if the config does.
+func (e *Engine) Run(stageNames ...string) error {
+	return e.RunContext(context.Background(), stageNames...)
+}
+
+// RunContext is like Run, but cancelling ctx aborts the running stage,
+// stopping its fetches and commands, and skips the rest.
+func (e *Engine) RunContext(ctx context.Context, stageNames ...string) error {
+	for _, name := range stageNames {
+		if stages.Get(name) == nil {
+			return fmt.Errorf("unknown stage %q", name)
+		}
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+
+	cache := e.ConfigCache
+	if cache == "" {
+		dir, err := ioutil.TempDir("", "ignition-engine")
+		if err != nil {
+			return fmt.Errorf("failed to create config cache: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		cache = filepath.Join(dir, "ignition.json")
+	}
+
+	for _, name := range stageNames {
+		fetcher, err := e.newFetcher(&logger)
+		if err != nil {
+			return err
+		}
+		fetcher.Offline = name == "fetch-offline"
+		engine := exec.Engine{
+			Root:           e.root,
+			FetchTimeout:   e.fetcher.Timeout,
+			Logger:         &logger,
+			ConfigCache:    cache,
+			PlatformConfig: e.platform,
+			Fetcher:        &fetcher,
+		}
+		if err := engine.Run(ctx, name); err != nil {
+			return fmt.Errorf("stage %q failed: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// newFetcher creates a fresh fetcher for each stage, as the ignition binary
+// does when it runs each stage in a separate process.
+func (e *Engine) newFetcher(logger *log.Logger) (resource.Fetcher, error) {
+	fetcher, err := e.platform.NewFetcherFunc()(logger)
+	if err != nil {
+		return resource.Fetcher{}, fmt.Errorf("failed to generate fetcher: %v", err)
+	}
+	fetcher.MaxDataURLSize = e.fetcher.MaxDataURLSize
+	fetcher.AcceptYAML = e.fetcher.AcceptYAML
+	fetcher.Limits = e.fetcher.Limits
+	fetcher.Memory = &resource.MemoryBudget{
+		PerFetch: e.fetcher.MaxFetchMemory,
+		Total:    e.fetcher.MaxTotalFetchMemory,
+	}
+	return fetcher, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewEngine(t *testing.T) {
+	if _, err := NewEngine("/", "nonexistent", FetcherOptions{}); err == nil {
+		t.Errorf("unknown platform was accepted")
+	}
+	e, err := NewEngine("/", "file", FetcherOptions{})
+	if err != nil {
+		t.Fatalf("file platform was rejected: %v", err)
+	}
+	if err := e.Run("fetch", "nonexistent"); err == nil || !strings.Contains(err.Error(), "nonexistent") {
+		t.Errorf("unknown stage was accepted: %v", err)
+	}
+}
+
+func TestRunFetch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-engine-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfgPath := filepath.Join(dir, "config.ign")
+	if err := ioutil.WriteFile(cfgPath, []byte(`{"ignition": {"version": "3.0.0"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("IGNITION_CONFIG_FILE", cfgPath)
+	defer os.Unsetenv("IGNITION_CONFIG_FILE")
+
+	e, err := NewEngine(dir, "file", FetcherOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ConfigCache = filepath.Join(dir, "cache.json")
+	if err := e.Run("fetch"); err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if _, err := os.Stat(e.ConfigCache); err != nil {
+		t.Errorf("config wasn't cached: %v", err)
+	}
+}
+
+func TestRunFetchOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-engine-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	needNetPath := filepath.Join(dir, "neednet")
+	os.Setenv("IGNITION_NEED_NET_PATH", needNetPath)
+	defer os.Unsetenv("IGNITION_NEED_NET_PATH")
+	cfgPath := filepath.Join(dir, "config.ign")
+	os.Setenv("IGNITION_CONFIG_FILE", cfgPath)
+	defer os.Unsetenv("IGNITION_CONFIG_FILE")
+
+	tests := []struct {
+		config  string
+		needNet bool
+	}{
+		{`{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "/etc/motd", "contents": {"source": "data:,hello"}}]}}`, false},
+		{`{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "/etc/motd", "contents": {"source": "https://example.com/motd"}}]}}`, true},
+		{`{"ignition": {"version": "3.0.0", "config": {"merge": [{"source": "https://example.com/overlay.ign"}]}}}`, true},
+	}
+
+	for i, test := range tests {
+		os.Remove(needNetPath)
+		if err := ioutil.WriteFile(cfgPath, []byte(test.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		e, err := NewEngine(dir, "file", FetcherOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Run("fetch-offline"); err != nil {
+			t.Errorf("#%d: fetch-offline failed: %v", i, err)
+			continue
+		}
+		if _, err := os.Stat(needNetPath); test.needNet != (err == nil) {
+			t.Errorf("#%d: expected networking to be required: %v, got %v", i, test.needNet, err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package enginetest provides a harness for testing configs end to end in
+// Go tests: it runs stages in-process against a temporary root, serves
+// remote contents from an httptest server, and attaches image files as loop
+// devices for the disks stage. Unlike the blackbox tests, it needs neither
+// the ignition binary nor, unless loop devices are used, root.
+//
+// A harness sets Ignition's environment variables while it runs stages, so
+// tests using harnesses mustn't run in parallel.
+package enginetest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coreos/ignition/v2/engine"
+)
+
+// Harness runs stages against a temporary root. Create one with New and
+// Close it when the test is done.
+type Harness struct {
+	// Root is the directory the stages act on, as the real root is
+	// /sysroot in the initramfs.
+	Root string
+	// Server serves the contents added with Serve.
+	Server *httptest.Server
+	// Fetcher configures the engine's fetches.
+	Fetcher engine.FetcherOptions
+	// Env holds extra environment variables, such as
+	// "IGNITION_GPT_BACKEND", to set while stages run. SELinux relabeling
+	// is disabled unless it sets "IGNITION_SELINUX_RELABEL".
+	Env map[string]string
+
+	t       testing.TB
+	dir     string
+	mu      sync.Mutex
+	served  map[string][]byte
+	devices []string
+}
+
+// New returns a harness with an empty root.
+func New(t testing.TB) *Harness {
+	dir, err := ioutil.TempDir("", "ignition-enginetest")
+	if err != nil {
+		t.Fatalf("creating harness directory: %v", err)
+	}
+	h := &Harness{
+		Root:   filepath.Join(dir, "root"),
+		Env:    map[string]string{},
+		t:      t,
+		dir:    dir,
+		served: map[string][]byte{},
+	}
+	for _, sub := range []string{h.Root, h.SystemConfigDir()} {
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("creating harness directory: %v", err)
+		}
+	}
+	h.Server = httptest.NewServer(http.HandlerFunc(h.serve))
+	return h
+}
+
+// Close stops the server, detaches the loop devices, and removes the root.
+func (h *Harness) Close() {
+	h.Server.Close()
+	for _, dev := range h.devices {
+		if out, err := exec.Command("losetup", "--detach", dev).CombinedOutput(); err != nil {
+			h.t.Errorf("detaching %s: %v: %s", dev, err, out)
+		}
+	}
+	os.RemoveAll(h.dir)
+}
+
+// Serve makes the server serve contents at path, and returns its URL.
+func (h *Harness) Serve(path string, contents []byte) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.served[path] = contents
+	return h.Server.URL + path
+}
+
+func (h *Harness) serve(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	contents, ok := h.served[r.URL.Path]
+	h.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(contents)
+}
+
+// SystemConfigDir returns the directory from which stages read the system
+// configs, such as base.ign.
+func (h *Harness) SystemConfigDir() string {
+	return filepath.Join(h.dir, "system")
+}
+
+// Path returns the location of path, which is absolute within the root, on
+// the host.
+func (h *Harness) Path(path string) string {
+	return filepath.Join(h.Root, path)
+}
+
+// ReadFile returns the contents of path within the root, failing the test
+// if it can't be read.
+func (h *Harness) ReadFile(path string) []byte {
+	contents, err := ioutil.ReadFile(h.Path(path))
+	if err != nil {
+		h.t.Fatalf("reading %s: %v", path, err)
+	}
+	return contents
+}
+
+// LoopDevice attaches an empty image file of sizeMiB MiB as a loop device,
+// with partition scanning, and returns the device's path for use in
+// configs. It skips the test unless it runs as root under systemd, through
+// which the disks stage waits for devices.
+func (h *Harness) LoopDevice(sizeMiB int) string {
+	if os.Geteuid() != 0 {
+		h.t.Skip("loop devices require root")
+	}
+	if _, err := os.Stat("/run/systemd/system"); err != nil {
+		h.t.Skip("the disks stage requires systemd")
+	}
+	image := filepath.Join(h.dir, fmt.Sprintf("disk%d.img", len(h.devices)))
+	f, err := os.Create(image)
+	if err == nil {
+		err = f.Truncate(int64(sizeMiB) * 1024 * 1024)
+		f.Close()
+	}
+	if err != nil {
+		h.t.Fatalf("creating disk image: %v", err)
+	}
+	out, err := exec.Command("losetup", "--find", "--show", "--partscan", image).Output()
+	if err != nil {
+		h.t.Skipf("attaching loop device: %v", err)
+	}
+	dev := strings.TrimSpace(string(out))
+	h.devices = append(h.devices, dev)
+	return dev
+}
+
+// Run runs the named stages, such as "fetch", "disks", and "files", with
+// config as the user config. The config is fetched afresh by the first
+// stage and cached for the rest, as in the initramfs.
+func (h *Harness) Run(config string, stageNames ...string) error {
+	configPath := filepath.Join(h.dir, "config.ign")
+	if err := ioutil.WriteFile(configPath, []byte(config), 0600); err != nil {
+		return err
+	}
+	cache := filepath.Join(h.dir, "cache.json")
+	if err := os.Remove(cache); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	env := map[string]string{
+		"IGNITION_CONFIG_FILE":       configPath,
+		"IGNITION_SYSTEM_CONFIG_DIR": h.SystemConfigDir(),
+		"IGNITION_ROLLBACK_DIR":      filepath.Join(h.dir, "rollback"),
+		"IGNITION_STAGING_DIR":       filepath.Join(h.dir, "staging"),
+		"IGNITION_NEED_NET_PATH":     filepath.Join(h.dir, "neednet"),
+		"IGNITION_SELINUX_RELABEL":   "false",
+	}
+	for name, value := range h.Env {
+		env[name] = value
+	}
+	defer setEnv(env)()
+
+	e, err := engine.NewEngine(h.Root, "file", h.Fetcher)
+	if err != nil {
+		return err
+	}
+	e.ConfigCache = cache
+	return e.Run(stageNames...)
+}
+
+// setEnv sets the environment variables in env, returning a function which
+// restores their old values.
+func setEnv(env map[string]string) func() {
+	type old struct {
+		value string
+		set   bool
+	}
+	olds := map[string]old{}
+	for name, value := range env {
+		v, set := os.LookupEnv(name)
+		olds[name] = old{v, set}
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name, o := range olds {
+			if o.set {
+				os.Setenv(name, o.value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+	}
+}
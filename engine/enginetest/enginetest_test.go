@@ -0,0 +1,99 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enginetest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	h := New(t)
+	defer h.Close()
+
+	motd := h.Serve("/motd", []byte("hello from the server\n"))
+	config := fmt.Sprintf(`{
+	  "ignition": {"version": "3.0.0"},
+	  "storage": {
+	    "files": [
+	      {"path": "/etc/motd", "contents": {"source": %q}},
+	      {"path": "/etc/hostname", "contents": {"source": "data:,node1"}}
+	    ]
+	  }
+	}`, motd)
+	if err := h.Run(config, "fetch", "files"); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(h.ReadFile("/etc/motd")); got != "hello from the server\n" {
+		t.Errorf("unexpected /etc/motd: %q", got)
+	}
+	if got := string(h.ReadFile("/etc/hostname")); got != "node1" {
+		t.Errorf("unexpected /etc/hostname: %q", got)
+	}
+
+	// a missing resource fails the stage
+	config = fmt.Sprintf(`{
+	  "ignition": {"version": "3.0.0"},
+	  "storage": {"files": [{"path": "/etc/missing", "contents": {"source": %q}}]}
+	}`, h.Server.URL+"/missing")
+	if err := h.Run(config, "fetch", "files"); err == nil {
+		t.Error("expected a missing resource to fail the files stage")
+	}
+}
+
+func TestSystemConfig(t *testing.T) {
+	h := New(t)
+	defer h.Close()
+
+	base := `{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "/etc/base", "contents": {"source": "data:,base"}}]}}`
+	if err := ioutil.WriteFile(filepath.Join(h.SystemConfigDir(), "base.ign"), []byte(base), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Run(`{"ignition": {"version": "3.0.0"}}`, "fetch", "files"); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(h.ReadFile("/etc/base")); got != "base" {
+		t.Errorf("unexpected /etc/base: %q", got)
+	}
+}
+
+func TestDisks(t *testing.T) {
+	h := New(t)
+	defer h.Close()
+	h.Env["IGNITION_GPT_BACKEND"] = "builtin"
+	h.Env["IGNITION_FS_PROBE_BACKEND"] = "builtin"
+
+	dev := h.LoopDevice(64)
+	config := fmt.Sprintf(`{
+	  "ignition": {"version": "3.0.0"},
+	  "storage": {
+	    "disks": [{
+	      "device": %q,
+	      "wipeTable": true,
+	      "partitions": [{"number": 1, "label": "data", "sizeMiB": 32}]
+	    }],
+	    "filesystems": [{"device": "%sp1", "format": "ext4", "label": "data"}]
+	  }
+	}`, dev, dev)
+	if err := h.Run(config, "fetch", "disks"); err != nil {
+		t.Fatal(err)
+	}
+	// running again reuses the partition and filesystem
+	if err := h.Run(config, "fetch", "disks"); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package checkpoint records the operations of a stage which have completed
+// on the provisioned system, so that if the machine resets partway through
+// the stage, the next attempt can skip them rather than redo them.
+package checkpoint
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/renameio"
+)
+
+// Disks is the operation of setting up the disks, which the disks stage
+// skips if a checkpoint records it.
+const Disks = "disks"
+
+type record struct {
+	// Config is the digest of the config the operations are from.
+	Config string   `json:"config"`
+	Done   []string `json:"done"`
+	// Relabel lists the paths to relabel once the stage completes.
+	Relabel []string `json:"relabel,omitempty"`
+}
+
+// Checkpoint is the record of the completed operations of a config.
+type Checkpoint struct {
+	path   string
+	record record
+	done   map[string]struct{}
+}
+
+// Digest returns the digest identifying the config cfg in checkpoints.
+func Digest(cfg interface{}) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha512.Sum512(data)
+	return "sha512-" + hex.EncodeToString(sum[:]), nil
+}
+
+// Open reads the checkpoint at path of the config with digest. If there's
+// no checkpoint, or it's of another config, the returned checkpoint has no
+// operations; it isn't written until an operation is recorded.
+func Open(path, digest string) (*Checkpoint, error) {
+	c := &Checkpoint{
+		path:   path,
+		record: record{Config: digest},
+		done:   map[string]struct{}{},
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %q: %v", path, err)
+	}
+	if r.Config != digest {
+		return c, nil
+	}
+	c.record = r
+	for _, op := range r.Done {
+		c.done[op] = struct{}{}
+	}
+	return c, nil
+}
+
+// Len returns the number of completed operations.
+func (c *Checkpoint) Len() int {
+	return len(c.record.Done)
+}
+
+// Done reports whether op has completed.
+func (c *Checkpoint) Done(op string) bool {
+	_, ok := c.done[op]
+	return ok
+}
+
+// Relabel returns the paths to relabel recorded with the operations.
+func (c *Checkpoint) Relabel() []string {
+	return c.record.Relabel
+}
+
+// Record records that op has completed, along with the paths to relabel
+// so far, and writes the checkpoint. Recording an operation again only
+// updates the paths.
+func (c *Checkpoint) Record(op string, relabel []string) error {
+	if !c.Done(op) {
+		c.done[op] = struct{}{}
+		c.record.Done = append(c.record.Done, op)
+	}
+	c.record.Relabel = relabel
+	return c.write()
+}
+
+// Reset forgets every operation, for when their results may have been
+// lost.
+func (c *Checkpoint) Reset() error {
+	c.done = map[string]struct{}{}
+	c.record = record{Config: c.record.Config}
+	return c.Remove()
+}
+
+// Remove removes the checkpoint, once the stage has completed.
+func (c *Checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (c *Checkpoint) write() error {
+	data, err := json.Marshal(c.record)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return err
+	}
+	// a reset mustn't leave a partially-written checkpoint
+	return renameio.WriteFile(c.path, data, 0600)
+}
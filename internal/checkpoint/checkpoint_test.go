@@ -0,0 +1,89 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-checkpoint-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	path := filepath.Join(td, "var", "lib", "ignition", "checkpoint.json")
+
+	c, err := Open(path, "sha512-a")
+	if err != nil {
+		t.Fatalf("failed to open checkpoint: %v", err)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("missing checkpoint has %d operations", c.Len())
+	}
+	if err := c.Record(Disks, nil); err != nil {
+		t.Fatalf("failed to record operation: %v", err)
+	}
+	if err := c.Record("file /etc/motd", []string{"/etc"}); err != nil {
+		t.Fatalf("failed to record operation: %v", err)
+	}
+	if err := c.Record("file /etc/motd", []string{"/etc", "/var"}); err != nil {
+		t.Fatalf("failed to record operation: %v", err)
+	}
+
+	tests := []struct {
+		digest  string
+		done    []string
+		relabel []string
+	}{
+		{"sha512-a", []string{Disks, "file /etc/motd"}, []string{"/etc", "/var"}},
+		// the checkpoint of another config is ignored
+		{"sha512-b", nil, nil},
+	}
+	for i, test := range tests {
+		c, err := Open(path, test.digest)
+		if err != nil {
+			t.Fatalf("#%d: failed to open checkpoint: %v", i, err)
+		}
+		if c.Len() != len(test.done) {
+			t.Errorf("#%d: expected %d operations, got %d", i, len(test.done), c.Len())
+		}
+		for _, op := range test.done {
+			if !c.Done(op) {
+				t.Errorf("#%d: operation %q isn't done", i, op)
+			}
+		}
+		if c.Done("file /etc/issue") {
+			t.Errorf("#%d: unrecorded operation is done", i)
+		}
+		if !reflect.DeepEqual(c.Relabel(), test.relabel) {
+			t.Errorf("#%d: expected relabeled paths %v, got %v", i, test.relabel, c.Relabel())
+		}
+	}
+
+	if err := c.Reset(); err != nil {
+		t.Fatalf("failed to reset checkpoint: %v", err)
+	}
+	if c.Len() != 0 || c.Done(Disks) {
+		t.Errorf("reset checkpoint has operations")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("reset checkpoint wasn't removed: %v", err)
+	}
+}
@@ -17,6 +17,8 @@ package distro
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // Distro-specific settings that can be overridden at link time with e.g.
@@ -31,6 +33,51 @@ var (
 	kernelCmdlinePath = "/proc/cmdline"
 	// initramfs directory containing distro-provided base config
 	systemConfigDir = "/usr/lib/ignition"
+	// directory holding the rollback snapshot of modified paths
+	rollbackDir = "/run/ignition/backup"
+	// directory holding fetched files whose own directory has no room for
+	// a temporary file
+	stagingDir = "/run/ignition/staging"
+	// flag the fetch-offline stage creates when the config requires
+	// networking, so the initramfs brings it up for the later stages
+	needNetPath = "/run/ignition/neednet"
+	// file in the target root recording the completed operations of the
+	// files stage, so an attempt interrupted by a reset can resume
+	checkpointPath = "/var/lib/ignition/checkpoint.json"
+	// flag the disks stage creates when it may change the disks, which
+	// invalidates the checkpoint of an earlier attempt
+	disksChangedPath = "/run/ignition/disks-changed"
+	// file in the target root listing the paths the files stage wrote,
+	// with digests of what was written
+	managedPathsPath = "/etc/ignition-managed.paths"
+	// directory in the target root from which the trust store takes
+	// additional CA certificates, and the directory the store is generated
+	// in
+	trustAnchorsDir = "/etc/pki/ca-trust/source/anchors"
+	trustStoreDir   = "/etc/pki/ca-trust/extracted"
+	// daemon keeping the target root's clock in sync, "chrony" or
+	// "timesyncd", and the configuration file of chrony
+	timeSyncDaemon = "chrony"
+	chronyConfPath = "/etc/chrony.conf"
+	// containers storage of the target root into which images are pulled,
+	// and its storage driver
+	containersStorageDir    = "/var/lib/containers/storage"
+	containersStorageDriver = "overlay"
+	// directory holding the leases of the initramfs's DHCP client, in the
+	// format of systemd-networkd or dhclient
+	dhcpLeaseDir = "/run/systemd/netif/leases"
+	// file containing the system vendor from the SMBIOS tables
+	smbiosVendorPath = "/sys/class/dmi/id/sys_vendor"
+	// file containing the system UUID from the SMBIOS tables
+	productUUIDPath = "/sys/class/dmi/id/product_uuid"
+	// file containing the system serial number from the SMBIOS tables
+	productSerialPath = "/sys/class/dmi/id/product_serial"
+	// machine ID of the running system
+	machineIDPath = "/etc/machine-id"
+	// TPM device through which the config is measured
+	tpmDevicePath = "/dev/tpmrm0"
+	// file logging the measurements Ignition made into the TPM
+	tpmEventLogPath = "/run/ignition/tpm-event-log.json"
 
 	// Helper programs
 	groupaddCmd = "groupadd"
@@ -42,6 +89,8 @@ var (
 	usermodCmd  = "usermod"
 	useraddCmd  = "useradd"
 	setfilesCmd = "setfiles"
+	semoduleCmd = "semodule"
+	zstdCmd     = "zstd"
 
 	// Filesystem tools
 	btrfsMkfsCmd = "mkfs.btrfs"
@@ -50,6 +99,29 @@ var (
 	vfatMkfsCmd  = "mkfs.vfat"
 	xfsMkfsCmd   = "mkfs.xfs"
 
+	// Trust store programs; the update command regenerates the store from
+	// its anchors, and is run in the target root with chroot
+	chrootCmd      = "chroot"
+	updateTrustCmd = "update-ca-trust"
+
+	// FIPS programs, run in the target root with chroot
+	updateCryptoPoliciesCmd = "update-crypto-policies"
+	grubbyCmd               = "grubby"
+
+	// EFI programs
+	efibootmgrCmd = "efibootmgr"
+
+	// Container programs
+	skopeoCmd = "skopeo"
+
+	// Certificate enrollment programs
+	sscepCmd = "sscep"
+
+	// OS update programs, run in the booted system by the unit Ignition
+	// writes for os
+	bootcCmd     = "bootc"
+	rpmOstreeCmd = "rpm-ostree"
+
 	//zVM programs
 	vmurCmd      = "vmur"
 	chccwdevCmd  = "chccwdev"
@@ -63,6 +135,46 @@ var (
 	// ".ssh/authorized_keys.d/ignition" ("true"), or to
 	// ".ssh/authorized_keys" ("false").
 	writeAuthorizedKeysFragment = "true"
+
+	// Config sources, tried in order until one provides the user config:
+	// "cmdline" (ignition.config.url), "dhcp" (ignition.config.dhcp.option),
+	// "system" (user.ign in the system config dir), and "platform"
+	configSources = "cmdline,dhcp,system,platform"
+
+	// diskConcurrency is the number of disks which are partitioned, and
+	// on which filesystems are created, at once
+	diskConcurrency = "8"
+
+	// gptBackend is how partition tables are written: "sgdisk" runs
+	// sgdisk, and "builtin" edits them in-process
+	gptBackend = "sgdisk"
+
+	// fsProbeBackend is how existing filesystems are identified: "blkid"
+	// uses libblkid, and "builtin" reads their superblocks in-process
+	fsProbeBackend = "blkid"
+
+	// faults lists failures to inject for testing; see the fault package
+	faults = ""
+
+	// dnsServers and dnsSearch are the comma-separated DNS servers and
+	// search domains with which fetches resolve names, instead of those of
+	// the system, unless the kernel command line sets them; see the
+	// resource package
+	dnsServers = ""
+	dnsSearch  = ""
+
+	// caches are the comma-separated URLs of the caches through which
+	// verified files are fetched, unless the kernel command line sets them
+	caches = ""
+
+	// userAgent is the User-Agent of HTTP requests, or "" for
+	// "Ignition/<version>"
+	userAgent = ""
+
+	// identityHeaders lists the identifiers of the machine sent with HTTP
+	// requests, so servers can tell machines apart: "uuid" and "serial"
+	// from the SMBIOS tables, and "machine-id"
+	identityHeaders = ""
 )
 
 func DiskByIDDir() string       { return diskByIDDir }
@@ -71,6 +183,27 @@ func DiskByPartUUIDDir() string { return diskByPartUUIDDir }
 
 func KernelCmdlinePath() string { return kernelCmdlinePath }
 func SystemConfigDir() string   { return fromEnv("SYSTEM_CONFIG_DIR", systemConfigDir) }
+func RollbackDir() string       { return fromEnv("ROLLBACK_DIR", rollbackDir) }
+func StagingDir() string        { return fromEnv("STAGING_DIR", stagingDir) }
+func NeedNetPath() string       { return fromEnv("NEED_NET_PATH", needNetPath) }
+func CheckpointPath() string    { return fromEnv("CHECKPOINT_PATH", checkpointPath) }
+func DisksChangedPath() string  { return fromEnv("DISKS_CHANGED_PATH", disksChangedPath) }
+func ManagedPathsPath() string  { return fromEnv("MANAGED_PATHS_PATH", managedPathsPath) }
+func DHCPLeaseDir() string      { return fromEnv("DHCP_LEASE_DIR", dhcpLeaseDir) }
+func ConfigSources() []string   { return strings.Split(fromEnv("CONFIG_SOURCES", configSources), ",") }
+func SmbiosVendorPath() string  { return fromEnv("SMBIOS_VENDOR_PATH", smbiosVendorPath) }
+func ProductUUIDPath() string   { return fromEnv("PRODUCT_UUID_PATH", productUUIDPath) }
+func ProductSerialPath() string { return fromEnv("PRODUCT_SERIAL_PATH", productSerialPath) }
+func MachineIDPath() string     { return fromEnv("MACHINE_ID_PATH", machineIDPath) }
+func TrustAnchorsDir() string   { return trustAnchorsDir }
+func TrustStoreDir() string     { return trustStoreDir }
+func TimeSyncDaemon() string    { return fromEnv("TIME_SYNC_DAEMON", timeSyncDaemon) }
+func ChronyConfPath() string    { return chronyConfPath }
+func TPMDevicePath() string     { return fromEnv("TPM_DEVICE_PATH", tpmDevicePath) }
+func TPMEventLogPath() string   { return fromEnv("TPM_EVENT_LOG_PATH", tpmEventLogPath) }
+
+func ContainersStorageDir() string    { return containersStorageDir }
+func ContainersStorageDriver() string { return containersStorageDriver }
 
 func GroupaddCmd() string { return groupaddCmd }
 func MdadmCmd() string    { return mdadmCmd }
@@ -81,6 +214,8 @@ func UdevadmCmd() string  { return udevadmCmd }
 func UsermodCmd() string  { return usermodCmd }
 func UseraddCmd() string  { return useraddCmd }
 func SetfilesCmd() string { return setfilesCmd }
+func SemoduleCmd() string { return semoduleCmd }
+func ZstdCmd() string     { return zstdCmd }
 
 func BtrfsMkfsCmd() string { return btrfsMkfsCmd }
 func Ext4MkfsCmd() string  { return ext4MkfsCmd }
@@ -88,15 +223,47 @@ func SwapMkfsCmd() string  { return swapMkfsCmd }
 func VfatMkfsCmd() string  { return vfatMkfsCmd }
 func XfsMkfsCmd() string   { return xfsMkfsCmd }
 
+func ChrootCmd() string      { return chrootCmd }
+func UpdateTrustCmd() string { return updateTrustCmd }
+
+func UpdateCryptoPoliciesCmd() string { return updateCryptoPoliciesCmd }
+func GrubbyCmd() string               { return grubbyCmd }
+
+func EfibootmgrCmd() string { return efibootmgrCmd }
+
+func SkopeoCmd() string { return skopeoCmd }
+
+func SscepCmd() string { return sscepCmd }
+
+func BootcCmd() string     { return bootcCmd }
+func RpmOstreeCmd() string { return rpmOstreeCmd }
+
 func VmurCmd() string      { return vmurCmd }
 func ChccwdevCmd() string  { return chccwdevCmd }
 func CioIgnoreCmd() string { return cioIgnoreCmd }
 
-func SelinuxRelabel() bool  { return bakedStringToBool(selinuxRelabel) && !BlackboxTesting() }
+func SelinuxRelabel() bool {
+	return bakedStringToBool(fromEnv("SELINUX_RELABEL", selinuxRelabel)) && !BlackboxTesting()
+}
 func BlackboxTesting() bool { return bakedStringToBool(blackboxTesting) }
 func WriteAuthorizedKeysFragment() bool {
 	return bakedStringToBool(fromEnv("WRITE_AUTHORIZED_KEYS_FRAGMENT", writeAuthorizedKeysFragment))
 }
+func DiskConcurrency() int   { return bakedStringToInt(fromEnv("DISK_CONCURRENCY", diskConcurrency)) }
+func GPTBackend() string     { return fromEnv("GPT_BACKEND", gptBackend) }
+func FSProbeBackend() string { return fromEnv("FS_PROBE_BACKEND", fsProbeBackend) }
+func Faults() string         { return fromEnv("FAULTS", faults) }
+func UserAgent() string      { return fromEnv("USER_AGENT", userAgent) }
+func DNSServers() string     { return fromEnv("DNS_SERVERS", dnsServers) }
+func DNSSearch() string      { return fromEnv("DNS_SEARCH", dnsSearch) }
+func Caches() string         { return fromEnv("CACHES", caches) }
+
+func IdentityHeaders() []string {
+	if ids := fromEnv("IDENTITY_HEADERS", identityHeaders); ids != "" {
+		return strings.Split(ids, ",")
+	}
+	return nil
+}
 
 func fromEnv(nameSuffix, defaultValue string) string {
 	value := os.Getenv("IGNITION_" + nameSuffix)
@@ -117,3 +284,11 @@ func bakedStringToBool(s string) bool {
 		panic(fmt.Sprintf("value '%s' cannot be interpreted as a boolean", s))
 	}
 }
+
+func bakedStringToInt(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		panic(fmt.Sprintf("value '%s' cannot be interpreted as a positive integer", s))
+	}
+	return n
+}
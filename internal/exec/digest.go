@@ -0,0 +1,60 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/google/renameio"
+)
+
+// configDigest returns the digest identifying cfg in the digest file.
+func configDigest(cfg types.Config) (string, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha512.Sum512(b)
+	return "sha512-" + hex.EncodeToString(sum[:]), nil
+}
+
+// appliedDigest returns the digest of the config the stage last applied,
+// which is recorded in e.DigestFile, or "" if none is recorded.
+func (e Engine) appliedDigest() (string, error) {
+	b, err := ioutil.ReadFile(e.DigestFile)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// recordDigest records digest in e.DigestFile once the stage has applied
+// its config.
+func (e Engine) recordDigest(digest string) error {
+	if err := os.MkdirAll(filepath.Dir(e.DigestFile), 0755); err != nil {
+		return err
+	}
+	return renameio.WriteFile(e.DigestFile, []byte(digest+"\n"), 0644)
+}
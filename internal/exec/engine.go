@@ -15,6 +15,7 @@
 package exec
 
 import (
+	"context"
 	"crypto/sha512"
 	"encoding/hex"
 	"encoding/json"
@@ -22,19 +23,26 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/coreos/ignition/v2/config"
 	"github.com/coreos/ignition/v2/config/shared/errors"
 	latest "github.com/coreos/ignition/v2/config/v3_1_experimental"
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec/stages"
+	"github.com/coreos/ignition/v2/internal/failure"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/platform"
 	"github.com/coreos/ignition/v2/internal/providers"
 	"github.com/coreos/ignition/v2/internal/providers/cmdline"
+	"github.com/coreos/ignition/v2/internal/providers/dhcp"
 	"github.com/coreos/ignition/v2/internal/providers/system"
 	"github.com/coreos/ignition/v2/internal/resource"
+	"github.com/coreos/ignition/v2/internal/signature"
+	"github.com/coreos/ignition/v2/internal/tpm"
 	"github.com/coreos/ignition/v2/internal/util"
 
 	"github.com/coreos/vcontext/report"
@@ -54,11 +62,28 @@ type Engine struct {
 	Root           string
 	PlatformConfig platform.Config
 	Fetcher        *resource.Fetcher
+	// TPMPCR is the PCR into which the fetched config is measured, or 0
+	// to not measure it.
+	TPMPCR int
+	// PrintPlan makes Run print the changes the stage would make to
+	// stdout, instead of running it.
+	PrintPlan bool
+	// DigestFile, if set, is where the digest of the config is recorded
+	// once the stage has run successfully.
+	DigestFile string
+	// Reconcile makes Run skip the stage if the digest of the config is
+	// the one recorded in DigestFile.
+	Reconcile bool
+
+	// baseTimeouts are the timeouts of the system base config, which
+	// apply to fetches unless the config sets its own
+	baseTimeouts types.Timeouts
 }
 
 // Run executes the stage of the given name. It returns true if the stage
-// successfully ran and false if there were any errors.
-func (e Engine) Run(stageName string) error {
+// successfully ran and false if there were any errors. Cancelling ctx aborts
+// the stage.
+func (e Engine) Run(ctx context.Context, stageName string) error {
 	if e.Fetcher == nil || e.Logger == nil {
 		fmt.Fprintf(os.Stderr, "engine incorrectly configured\n")
 		return errors.ErrEngineConfiguration
@@ -67,16 +92,38 @@ func (e Engine) Run(stageName string) error {
 		Ignition: types.Ignition{Version: types.MaxVersion.String()},
 	}
 
-	systemBaseConfig, r, err := system.FetchBaseConfig(e.Logger)
+	systemBaseConfig, r, err := system.FetchBaseConfig(e.Fetcher, e.PlatformConfig.Name())
 	e.logReport(r)
 	if err != nil && err != providers.ErrNoProvider {
 		e.Logger.Crit("failed to acquire system base config: %v", err)
 		return err
 	}
+	e.baseTimeouts = systemBaseConfig.Ignition.Timeouts
+
+	// only keys baked into the system, or vouched for by the kernel command
+	// line, may sign the configs which are fetched
+	fingerprints, err := cmdline.SignatureFingerprints(e.Logger)
+	if err != nil {
+		e.Logger.Crit("failed to read signature fingerprints: %v", err)
+		return err
+	}
+	e.Fetcher.Signature = signature.Policy{
+		Keys:         systemBaseConfig.Ignition.Security.Signature.Keys,
+		Fingerprints: fingerprints,
+	}
+
+	if e.PlatformConfig.Offline() {
+		// fetches which require networking fail instead of being attempted
+		e.Fetcher.Offline = true
+	}
 
 	cfg, err := e.acquireConfig()
 	if err == errors.ErrEmpty {
 		e.Logger.Info("%v: ignoring user-provided config", err)
+	} else if err == resource.ErrNeedNet && e.PlatformConfig.Offline() {
+		return e.refuseNet()
+	} else if err == resource.ErrNeedNet && e.Fetcher.Offline {
+		return e.signalNeedNet()
 	} else if err != nil {
 		e.Logger.Crit("failed to acquire config: %v", err)
 		return err
@@ -86,7 +133,50 @@ func (e Engine) Run(stageName string) error {
 	defer e.Logger.PopPrefix()
 
 	fullConfig := latest.Merge(baseConfig, latest.Merge(systemBaseConfig, cfg))
-	if err = stages.Get(stageName).Create(e.Logger, e.Root, *e.Fetcher).Run(fullConfig); err != nil {
+	if e.PlatformConfig.Offline() {
+		needsNet, err := resource.ConfigNeedsNet(fullConfig)
+		if err != nil {
+			e.Logger.Crit("failed to check whether config requires networking: %v", err)
+			return err
+		}
+		if needsNet {
+			return e.refuseNet()
+		}
+	}
+	stage := stages.Get(stageName).Create(e.Logger, e.Root, *e.Fetcher)
+	if r, ok := stage.(stages.Reconciler); ok {
+		r.SetReconcile(e.Reconcile)
+	}
+	if e.PrintPlan {
+		return printPlan(stage, fullConfig)
+	}
+	var digest string
+	if e.DigestFile != "" {
+		if digest, err = configDigest(fullConfig); err != nil {
+			e.Logger.Crit("failed to compute config digest: %v", err)
+			return err
+		}
+		if e.Reconcile {
+			applied, err := e.appliedDigest()
+			if err != nil {
+				e.Logger.Crit("failed to read applied config digest: %v", err)
+				return err
+			}
+			if applied == digest {
+				e.Logger.Info("config is unchanged since it was applied; not running stage")
+				return nil
+			}
+		}
+	}
+	// the config may have taken a while to fetch
+	if err := ctx.Err(); err != nil {
+		e.Logger.Crit("not running stage: %v", err)
+		return err
+	}
+	err = stage.Run(ctx, fullConfig)
+	if err == resource.ErrNeedNet && e.Fetcher.Offline {
+		return e.signalNeedNet()
+	} else if err != nil {
 		// e.Logger could be nil
 		fmt.Fprintf(os.Stderr, "%s failed", stageName)
 		tmp, jsonerr := json.MarshalIndent(fullConfig, "", "  ")
@@ -98,10 +188,58 @@ func (e Engine) Run(stageName string) error {
 		}
 		return err
 	}
+	if e.DigestFile != "" {
+		if err := e.recordDigest(digest); err != nil {
+			e.Logger.Crit("failed to record config digest: %v", err)
+			return err
+		}
+	}
 	e.Logger.Info("%s passed", stageName)
 	return nil
 }
 
+// signalNeedNet creates the flag telling the initramfs that the config
+// requires networking, once an offline stage found that it does. The later
+// stages then fetch the config again with networking.
+func (e Engine) signalNeedNet() error {
+	e.Logger.Info("config requires networking")
+	path := distro.NeedNetPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		e.Logger.Crit("failed to create directory for %q: %v", path, err)
+		return err
+	}
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		e.Logger.Crit("failed to signal that networking is required: %v", err)
+		return err
+	}
+	return nil
+}
+
+// refuseNet fails the stage on a platform which forbids networking, once
+// the config was found to require it.
+func (e Engine) refuseNet() error {
+	err := failure.Wrap(failure.ErrConfigInvalid, fmt.Errorf("config requires networking, which platform %q forbids", e.PlatformConfig.Name()))
+	e.Logger.Crit("%v", err)
+	return err
+}
+
+// printPlan prints the changes stage would make for config to stdout, one
+// per line.
+func printPlan(stage stages.Stage, config types.Config) error {
+	planner, ok := stage.(stages.Planner)
+	if !ok {
+		return fmt.Errorf("stage %q can't print a plan", stage.Name())
+	}
+	plan, err := planner.Plan(config)
+	if err != nil {
+		return err
+	}
+	for _, line := range plan {
+		fmt.Println(line)
+	}
+	return nil
+}
+
 // acquireConfig returns the configuration, first checking a local cache
 // before attempting to fetch it from the provider.
 func (e *Engine) acquireConfig() (cfg types.Config, err error) {
@@ -114,7 +252,7 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 		}
 		// Create an http client and fetcher with the timeouts from the cached
 		// config
-		err = e.Fetcher.UpdateHttpTimeoutsAndCAs(cfg.Ignition.Timeouts, cfg.Ignition.Security.TLS.CertificateAuthorities, cfg.Ignition.Proxy)
+		err = e.updateFetcher(cfg.Ignition)
 		if err != nil {
 			e.Logger.Crit("failed to update timeouts and CAs for fetcher: %v", err)
 			return
@@ -123,10 +261,15 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 	}
 
 	// Create a new http client and fetcher with the timeouts set via the flags,
-	// since we don't have a config with timeout values we can use
+	// since we don't have a config with timeout values we can use, unless
+	// the system base config sets them
 	timeout := int(e.FetchTimeout.Seconds())
+	timeouts := e.baseTimeouts
+	if timeouts.HTTPTotal == nil {
+		timeouts.HTTPTotal = &timeout
+	}
 	emptyProxy := types.Proxy{}
-	err = e.Fetcher.UpdateHttpTimeoutsAndCAs(types.Timeouts{HTTPTotal: &timeout}, nil, emptyProxy)
+	err = e.Fetcher.UpdateHttpTimeoutsAndCAs(timeouts, nil, emptyProxy)
 	if err != nil {
 		e.Logger.Crit("failed to update timeouts and CAs for fetcher: %v", err)
 		return
@@ -141,7 +284,7 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 
 	// Update the http client to use the timeouts and CAs from the newly fetched
 	// config
-	err = e.Fetcher.UpdateHttpTimeoutsAndCAs(cfg.Ignition.Timeouts, cfg.Ignition.Security.TLS.CertificateAuthorities, cfg.Ignition.Proxy)
+	err = e.updateFetcher(cfg.Ignition)
 	if err != nil {
 		e.Logger.Crit("failed to update timeouts and CAs for fetcher: %v", err)
 		return
@@ -154,9 +297,12 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 	}
 
 	rpt := validate.Validate(cfg, "json")
+	// merging can push the config over the limits even if no single
+	// config exceeds them
+	rpt.Merge(e.Fetcher.Limits.Check(cfg))
 	e.logReport(rpt)
 	if rpt.IsFatal() {
-		err = errors.ErrInvalid
+		err = failure.Wrap(failure.ErrConfigInvalid, errors.ErrInvalid)
 		e.Logger.Crit("merging configs resulted in an invalid config")
 		return
 	}
@@ -167,6 +313,17 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 		e.Logger.Crit("failed to marshal cached config: %v", err)
 		return
 	}
+	// Measure the config before any stage can act on it. Only freshly
+	// fetched configs are measured, so each boot measures it once.
+	if e.TPMPCR != 0 {
+		err = tpm.Measure(e.TPMPCR, b, "ignition config")
+		if err == tpm.ErrNoTPM {
+			e.Logger.Warning("not measuring config: %v", err)
+		} else if err != nil {
+			e.Logger.Crit("failed to measure config: %v", err)
+			return
+		}
+	}
 	if err = renameio.WriteFile(e.ConfigCache, b, 0640); err != nil {
 		e.Logger.Crit("failed to write cached config: %v", err)
 		return
@@ -175,39 +332,65 @@ func (e *Engine) acquireConfig() (cfg types.Config, err error) {
 	return
 }
 
-// fetchProviderConfig returns the externally-provided configuration. It first
-// checks to see if the command-line option is present. If so, it uses that
-// source for the configuration. If the command-line option is not present, it
-// checks for a user config in the system config dir. If that is also missing,
-// it checks the config engine's provider. An error is returned if the provider
-// is unavailable. This will also render the config (see renderConfig) before
-// returning.
+// fetchProviderConfig returns the externally-provided configuration. It tries
+// the config sources in the order distro.ConfigSources gives, by default the
+// command-line option, the DHCP option named on the command line, a user
+// config in the system config dir, and the config engine's provider, and
+// uses the first source which provides a config. An error is returned if
+// that source fails, and errors.ErrEmpty if none provides a config. This
+// will also render the config (see renderConfig) before returning.
 func (e *Engine) fetchProviderConfig() (types.Config, error) {
-	fetchers := []providers.FuncFetchConfig{
-		cmdline.FetchConfig,
-		system.FetchConfig,
-		e.PlatformConfig.FetchFunc(),
+	sources := map[string]providers.FuncFetchConfig{
+		"cmdline":  cmdline.FetchConfig,
+		"dhcp":     dhcp.FetchConfig,
+		"system":   system.FetchConfig,
+		"platform": e.PlatformConfig.FetchFunc(),
+	}
+
+	order := distro.ConfigSources()
+	for _, name := range order {
+		if _, ok := sources[name]; !ok {
+			return types.Config{}, fmt.Errorf("unknown config source %q", name)
+		}
 	}
 
 	var cfg types.Config
 	var r report.Report
-	var err error
-	for _, fetcher := range fetchers {
-		cfg, r, err = fetcher(e.Fetcher)
-		if err != providers.ErrNoProvider {
-			// successful, or failed on another error
-			break
+	err := providers.ErrNoProvider
+	for i, name := range order {
+		cfg, r, err = sources[name](e.Fetcher)
+		if err == providers.ErrNoProvider {
+			e.Logger.Info("config source %q provided no config", name)
+			continue
+		}
+		// successful, or failed on another error
+		if err == nil {
+			e.Logger.Info("using the config from source %q", name)
+		}
+		if rest := order[i+1:]; len(rest) > 0 {
+			e.Logger.Info("not trying config sources after %q: %s", name, strings.Join(rest, ", "))
 		}
+		break
+	}
+	if err == providers.ErrNoProvider {
+		e.Logger.Info("no config source provided a config")
+		return types.Config{}, errors.ErrEmpty
 	}
 
 	e.logReport(r)
-	if err != nil {
+	if err == errors.ErrEmpty || err == resource.ErrNeedNet {
 		return types.Config{}, err
+	} else if err != nil {
+		// errors parsing or verifying the config are already classified
+		return types.Config{}, failure.Wrap(failure.ErrFetchFailed, err)
+	}
+	if len(cfg.Ignition.Security.Signature.Keys) > 0 {
+		e.Logger.Warning("ignoring ignition.security.signature: it's only honored in the system base config")
 	}
 
 	// Replace the HTTP client in the fetcher to be configured with the
 	// timeouts of the config
-	err = e.Fetcher.UpdateHttpTimeoutsAndCAs(cfg.Ignition.Timeouts, cfg.Ignition.Security.TLS.CertificateAuthorities, cfg.Ignition.Proxy)
+	err = e.updateFetcher(cfg.Ignition)
 	if err != nil {
 		return types.Config{}, err
 	}
@@ -221,26 +404,42 @@ func (e *Engine) fetchProviderConfig() (types.Config, error) {
 // "ignition.config.append" is set, each of the referenced configs will be
 // evaluated and appended to the provided config. If neither option is set, the
 // provided config will be returned unmodified. An updated fetcher will be
-// returned with any new timeouts set.
+// returned with any new timeouts set. References whose "if" conditions don't
+// hold on this machine are skipped.
 func (e *Engine) renderConfig(cfg types.Config) (types.Config, error) {
 	if cfgRef := cfg.Ignition.Config.Replace; cfgRef.Source != nil {
-		newCfg, err := e.fetchReferencedConfig(cfgRef)
+		holds, err := e.conditionHolds(cfgRef.If)
 		if err != nil {
 			return types.Config{}, err
 		}
-
-		// Replace the HTTP client in the fetcher to be configured with the
-		// timeouts of the new config
-		err = e.Fetcher.UpdateHttpTimeoutsAndCAs(newCfg.Ignition.Timeouts, newCfg.Ignition.Security.TLS.CertificateAuthorities, newCfg.Ignition.Proxy)
-		if err != nil {
-			return types.Config{}, err
+		if holds {
+			newCfg, err := e.fetchReferencedConfig(cfgRef)
+			if err != nil {
+				return types.Config{}, err
+			}
+
+			// Replace the HTTP client in the fetcher to be configured with the
+			// timeouts of the new config
+			err = e.updateFetcher(newCfg.Ignition)
+			if err != nil {
+				return types.Config{}, err
+			}
+
+			return e.renderConfig(newCfg)
 		}
-
-		return e.renderConfig(newCfg)
+		e.Logger.Info("skipping ignition.config.replace: its conditions don't hold")
 	}
 
 	appendedCfg := cfg
-	for _, cfgRef := range cfg.Ignition.Config.Merge {
+	for i, cfgRef := range cfg.Ignition.Config.Merge {
+		holds, err := e.conditionHolds(cfgRef.If)
+		if err != nil {
+			return types.Config{}, err
+		}
+		if !holds {
+			e.Logger.Info("skipping ignition.config.merge.%d: its conditions don't hold", i)
+			continue
+		}
 		newCfg, err := e.fetchReferencedConfig(cfgRef)
 		if err != nil {
 			return types.Config{}, err
@@ -250,7 +449,7 @@ func (e *Engine) renderConfig(cfg types.Config) (types.Config, error) {
 		// been rendered, so we can use the new config's timeouts and CAs when
 		// fetching more configs.
 		cfgForFetcherSettings := latest.Merge(appendedCfg, newCfg)
-		err = e.Fetcher.UpdateHttpTimeoutsAndCAs(cfgForFetcherSettings.Ignition.Timeouts, cfgForFetcherSettings.Ignition.Security.TLS.CertificateAuthorities, cfgForFetcherSettings.Ignition.Proxy)
+		err = e.updateFetcher(cfgForFetcherSettings.Ignition)
 		if err != nil {
 			return types.Config{}, err
 		}
@@ -260,11 +459,39 @@ func (e *Engine) renderConfig(cfg types.Config) (types.Config, error) {
 			return types.Config{}, err
 		}
 
-		appendedCfg = latest.Merge(appendedCfg, newCfg)
+		appendedCfg = latest.MergeWithPolicies(appendedCfg, newCfg, cfgRef.ListPolicies)
 	}
 	return appendedCfg, nil
 }
 
+// conditionHolds reports whether every condition set in cond holds on this
+// machine.
+func (e *Engine) conditionHolds(cond types.ConfigCondition) (bool, error) {
+	if cond.Platform != nil && *cond.Platform != e.PlatformConfig.Name() {
+		return false, nil
+	}
+	if cond.SmbiosVendor != nil {
+		vendor, err := ioutil.ReadFile(distro.SmbiosVendorPath())
+		if os.IsNotExist(err) {
+			// machines without SMBIOS tables have no vendor
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("failed to read SMBIOS vendor: %v", err)
+		}
+		if strings.TrimSpace(string(vendor)) != *cond.SmbiosVendor {
+			return false, nil
+		}
+	}
+	if cond.DiskPresent != nil {
+		if _, err := os.Stat(*cond.DiskPresent); os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, fmt.Errorf("failed to check for disk %q: %v", *cond.DiskPresent, err)
+		}
+	}
+	return true, nil
+}
+
 // fetchReferencedConfig fetches and parses the requested config.
 // cfgRef.Source must not ve nil
 func (e *Engine) fetchReferencedConfig(cfgRef types.ConfigReference) (types.Config, error) {
@@ -272,10 +499,16 @@ func (e *Engine) fetchReferencedConfig(cfgRef types.ConfigReference) (types.Conf
 	if err != nil {
 		return types.Config{}, err
 	}
-	rawCfg, err := e.Fetcher.FetchToBuffer(*u, resource.FetchOptions{})
+	mirrors, err := resource.ParseMirrors(cfgRef.Mirrors)
 	if err != nil {
 		return types.Config{}, err
 	}
+	rawCfg, err := e.Fetcher.FetchToBuffer(*u, resource.FetchOptions{Mirrors: mirrors})
+	if err == resource.ErrNeedNet {
+		return types.Config{}, err
+	} else if err != nil {
+		return types.Config{}, failure.Wrap(failure.ErrFetchFailed, err)
+	}
 
 	hash := sha512.Sum512(rawCfg)
 	if u.Scheme != "data" {
@@ -285,19 +518,61 @@ func (e *Engine) fetchReferencedConfig(cfgRef types.ConfigReference) (types.Conf
 		e.Logger.Debug("fetched referenced config from data url with SHA512: %s", hex.EncodeToString(hash[:]))
 	}
 
-	if err := util.AssertValid(cfgRef.Verification, rawCfg); err != nil {
+	verification, err := e.Fetcher.ResolveVerification(cfgRef.Verification)
+	if err != nil {
 		return types.Config{}, err
 	}
+	if err := util.AssertValid(verification, rawCfg); err != nil {
+		return types.Config{}, err
+	}
+	// configs pinned by their hash, or by a signed checksums manifest, are
+	// trusted as much as the config which referenced them, so only need a
+	// signature if they aren't
+	if cfgRef.Verification.Hash != nil || cfgRef.Verification.Checksums.Signature != nil {
+		rawCfg = signature.Unwrap(rawCfg)
+	} else if rawCfg, err = e.Fetcher.Signature.Verify(rawCfg); err != nil {
+		return types.Config{}, failure.Wrap(failure.ErrVerificationFailed, err)
+	}
 
-	cfg, r, err := config.Parse(rawCfg)
+	if err := e.Fetcher.Limits.CheckSize(rawCfg); err != nil {
+		return types.Config{}, failure.Wrap(failure.ErrConfigInvalid, err)
+	}
+	parse := config.Parse
+	if e.Fetcher.AcceptYAML {
+		parse = config.ParseYAML
+	}
+	cfg, r, err := parse(rawCfg)
+	if err == nil {
+		r.Merge(e.Fetcher.Limits.Check(cfg))
+		if r.IsFatal() {
+			err = errors.ErrInvalid
+		}
+	}
 	e.logReport(r)
-	if err != nil {
+	if err == errors.ErrEmpty {
 		return types.Config{}, err
+	} else if err != nil {
+		return types.Config{}, failure.Wrap(failure.ErrConfigInvalid, err)
 	}
 
 	return cfg, nil
 }
 
+// updateFetcher configures the fetcher with the timeouts, CAs, proxy, HTTP
+// credentials, and Vault server of ign.
+func (e *Engine) updateFetcher(ign types.Ignition) error {
+	e.Fetcher.HTTPCredentials = ign.Security.HTTP.Credentials
+	e.Fetcher.Vault = ign.Security.Vault
+	timeouts := ign.Timeouts
+	if timeouts.HTTPResponseHeaders == nil {
+		timeouts.HTTPResponseHeaders = e.baseTimeouts.HTTPResponseHeaders
+	}
+	if timeouts.HTTPTotal == nil {
+		timeouts.HTTPTotal = e.baseTimeouts.HTTPTotal
+	}
+	return e.Fetcher.UpdateHttpTimeoutsAndCAs(timeouts, ign.Security.TLS.CertificateAuthorities, ign.Proxy)
+}
+
 func (e Engine) logReport(r report.Report) {
 	for _, entry := range r.Entries {
 		switch entry.Kind {
@@ -0,0 +1,237 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/failure"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/platform"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestConditionHolds(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-condition-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	vendor := filepath.Join(td, "sys_vendor")
+	if err := ioutil.WriteFile(vendor, []byte("Dell Inc.\n"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	os.Setenv("IGNITION_SMBIOS_VENDOR_PATH", vendor)
+	defer os.Unsetenv("IGNITION_SMBIOS_VENDOR_PATH")
+
+	tests := []struct {
+		in  types.ConfigCondition
+		out bool
+	}{
+		{
+			in:  types.ConfigCondition{},
+			out: true,
+		},
+		{
+			in:  types.ConfigCondition{Platform: util.StrToPtr("file")},
+			out: true,
+		},
+		{
+			in:  types.ConfigCondition{Platform: util.StrToPtr("aws")},
+			out: false,
+		},
+		{
+			in:  types.ConfigCondition{SmbiosVendor: util.StrToPtr("Dell Inc.")},
+			out: true,
+		},
+		{
+			in:  types.ConfigCondition{SmbiosVendor: util.StrToPtr("HPE")},
+			out: false,
+		},
+		{
+			in:  types.ConfigCondition{DiskPresent: util.StrToPtr(vendor)},
+			out: true,
+		},
+		{
+			in:  types.ConfigCondition{DiskPresent: util.StrToPtr(filepath.Join(td, "sdz"))},
+			out: false,
+		},
+		{
+			// every condition must hold
+			in: types.ConfigCondition{
+				Platform:     util.StrToPtr("file"),
+				SmbiosVendor: util.StrToPtr("HPE"),
+			},
+			out: false,
+		},
+	}
+
+	e := Engine{PlatformConfig: platform.MustGet("file")}
+	for i, test := range tests {
+		holds, err := e.conditionHolds(test.in)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+		} else if holds != test.out {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, holds)
+		}
+	}
+
+	os.Setenv("IGNITION_SMBIOS_VENDOR_PATH", filepath.Join(td, "missing"))
+	if holds, err := e.conditionHolds(types.ConfigCondition{SmbiosVendor: util.StrToPtr("Dell Inc.")}); err != nil || holds {
+		t.Errorf("missing SMBIOS vendor: expected false, got %v, %v", holds, err)
+	}
+}
+
+func TestFetchProviderConfigSources(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-sources-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	systemDir := filepath.Join(td, "system")
+	if err := os.Mkdir(systemDir, 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(systemDir, "user.ign"), []byte(`{"ignition": {"version": "3.1.0-experimental"}, "passwd": {"users": [{"name": "system"}]}}`), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	platformFile := filepath.Join(td, "config.ign")
+	if err := ioutil.WriteFile(platformFile, []byte(`{"ignition": {"version": "3.1.0-experimental"}, "passwd": {"users": [{"name": "platform"}]}}`), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	os.Setenv("IGNITION_SYSTEM_CONFIG_DIR", systemDir)
+	defer os.Unsetenv("IGNITION_SYSTEM_CONFIG_DIR")
+	os.Setenv("IGNITION_CONFIG_FILE", platformFile)
+	defer os.Unsetenv("IGNITION_CONFIG_FILE")
+	defer os.Unsetenv("IGNITION_CONFIG_SOURCES")
+
+	tests := []struct {
+		sources string
+		user    string
+		err     error
+	}{
+		{"system,platform", "system", nil},
+		{"platform,system", "platform", nil},
+		// the kernel command line has no ignition.config.url
+		{"cmdline,platform", "platform", nil},
+		{"cmdline", "", errors.ErrEmpty},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	for i, test := range tests {
+		os.Setenv("IGNITION_CONFIG_SOURCES", test.sources)
+		e := Engine{
+			Logger:         &logger,
+			Fetcher:        &resource.Fetcher{Logger: &logger},
+			PlatformConfig: platform.MustGet("file"),
+		}
+		cfg, err := e.fetchProviderConfig()
+		if err != test.err {
+			t.Errorf("#%d: expected error %v, got %v", i, test.err, err)
+		} else if err == nil && (len(cfg.Passwd.Users) != 1 || cfg.Passwd.Users[0].Name != test.user) {
+			t.Errorf("#%d: expected the config of %q, got %+v", i, test.user, cfg.Passwd.Users)
+		}
+	}
+
+	os.Setenv("IGNITION_CONFIG_SOURCES", "system,metadata")
+	e := Engine{Logger: &logger, Fetcher: &resource.Fetcher{Logger: &logger}, PlatformConfig: platform.MustGet("file")}
+	if _, err := e.fetchProviderConfig(); err == nil {
+		t.Errorf("unknown config source was accepted")
+	}
+}
+
+func TestDigestFile(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-digest-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	e := Engine{DigestFile: filepath.Join(td, "state", "files.digest")}
+	if applied, err := e.appliedDigest(); err != nil || applied != "" {
+		t.Fatalf("expected no applied digest, got %q, %v", applied, err)
+	}
+
+	a := types.Config{Ignition: types.Ignition{Version: types.MaxVersion.String()}}
+	b := a
+	b.Storage.Files = []types.File{{Node: types.Node{Path: "/etc/motd"}}}
+	digestA, err := configDigest(a)
+	if err != nil {
+		t.Fatalf("digest error: %v", err)
+	}
+	digestB, err := configDigest(b)
+	if err != nil {
+		t.Fatalf("digest error: %v", err)
+	}
+	if digestA == digestB {
+		t.Errorf("different configs have the same digest %q", digestA)
+	}
+
+	if err := e.recordDigest(digestA); err != nil {
+		t.Fatalf("record error: %v", err)
+	}
+	if applied, err := e.appliedDigest(); err != nil || applied != digestA {
+		t.Errorf("expected applied digest %q, got %q, %v", digestA, applied, err)
+	}
+}
+
+func TestOfflinePlatformRefusesNet(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-offline-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	os.Setenv("IGNITION_SYSTEM_CONFIG_DIR", td)
+	defer os.Unsetenv("IGNITION_SYSTEM_CONFIG_DIR")
+	os.Setenv("IGNITION_CONFIG_SOURCES", "system")
+	defer os.Unsetenv("IGNITION_CONFIG_SOURCES")
+
+	tests := []string{
+		// a file needing networking
+		`{"ignition": {"version": "3.1.0-experimental"}, "storage": {"files": [{"path": "/etc/motd", "contents": {"source": "https://example.com/motd"}}]}}`,
+		// a config needing networking to fetch
+		`{"ignition": {"version": "3.1.0-experimental", "config": {"merge": [{"source": "https://example.com/config.ign"}]}}}`,
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	for i, test := range tests {
+		if err := ioutil.WriteFile(filepath.Join(td, "user.ign"), []byte(test), 0644); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		e := Engine{
+			ConfigCache:    filepath.Join(td, fmt.Sprintf("cache-%d.json", i)),
+			Logger:         &logger,
+			Fetcher:        &resource.Fetcher{Logger: &logger},
+			PlatformConfig: platform.MustGet("metal-offline"),
+		}
+		err := e.Run(context.Background(), "files")
+		if failure.Classify(err) != failure.ErrConfigInvalid {
+			t.Errorf("#%d: expected the config to be refused as invalid, got %v", i, err)
+		}
+	}
+}
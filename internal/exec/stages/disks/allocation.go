@@ -0,0 +1,164 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+)
+
+const (
+	allocationFirstFit   = "first-fit"
+	allocationAppend     = "append"
+	allocationExactStart = "exact-start"
+)
+
+// extent is a range of sectors in use on a disk. An extent with a size of 0
+// is unbounded, i.e. it fills whatever free block it starts in, so nothing
+// can be placed after it.
+type extent struct {
+	start int
+	size  int
+}
+
+func (e extent) end() int {
+	return e.start + e.size
+}
+
+// needsStart returns whether part will be created without a known starting
+// sector.
+func needsStart(part types.Partition) bool {
+	return partitionShouldExist(part) && (part.StartMiB == nil || *part.StartMiB == 0)
+}
+
+// allocatePartitions picks a starting sector for each partition in parts
+// that will be created without one, according to strategy. parts must
+// already be converted to sectors and have the start and size of existing
+// partitions filled in. kept lists the existing partitions which are not
+// mentioned in the config. Partitions are placed in order, so each one sees
+// the space taken by the ones before it. It returns a map from the index of
+// each placed partition in parts to its starting sector.
+func allocatePartitions(strategy string, parts []types.Partition, kept []util.PartitionInfo, sectorSize int) (map[int]int, error) {
+	result := map[int]int{}
+	if strategy == "" {
+		// leave it to sgdisk, which uses the largest free block
+		return result, nil
+	}
+
+	align := 1024 * 1024 / sectorSize
+	used := []extent{}
+	for _, info := range kept {
+		used = append(used, extent{start: info.StartSector, size: info.SizeInSectors})
+	}
+	for _, part := range parts {
+		if partitionShouldExist(part) && !needsStart(part) {
+			used = append(used, extent{start: *part.StartMiB, size: sizeOf(part)})
+		}
+	}
+
+	for i, part := range parts {
+		if !needsStart(part) {
+			continue
+		}
+		var start int
+		var err error
+		switch strategy {
+		case allocationExactStart:
+			err = fmt.Errorf("partition %d has no start and allocationStrategy is %s", part.Number, strategy)
+		case allocationAppend:
+			start, err = appendStart(used, align)
+		case allocationFirstFit:
+			start, err = firstFitStart(used, sizeOf(part), align)
+		default:
+			err = fmt.Errorf("unknown allocationStrategy %q", strategy)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[i] = start
+		used = append(used, extent{start: start, size: sizeOf(part)})
+	}
+	return result, nil
+}
+
+// sizeOf returns the size of part in sectors, or 0 if it fills its block.
+func sizeOf(part types.Partition) int {
+	if part.SizeMiB == nil {
+		return 0
+	}
+	return *part.SizeMiB
+}
+
+func alignUp(sector, align int) int {
+	return (sector + align - 1) / align * align
+}
+
+// sortExtents sorts used by starting sector and returns an error if any
+// extent other than the last is unbounded, since the free space after it
+// can't be known.
+func sortExtents(used []extent) error {
+	sort.Slice(used, func(i, j int) bool { return used[i].start < used[j].start })
+	for i, e := range used {
+		if e.size == 0 && i != len(used)-1 {
+			return fmt.Errorf("cannot allocate around a partition at sector %d which fills its free block", e.start)
+		}
+	}
+	return nil
+}
+
+// appendStart returns the first aligned sector after every partition in used.
+func appendStart(used []extent, align int) (int, error) {
+	if err := sortExtents(used); err != nil {
+		return 0, err
+	}
+	end := align
+	for _, e := range used {
+		if e.size == 0 {
+			return 0, fmt.Errorf("cannot append after the partition at sector %d which fills its free block", e.start)
+		}
+		if e.end() > end {
+			end = e.end()
+		}
+	}
+	return alignUp(end, align), nil
+}
+
+// firstFitStart returns the lowest aligned sector at which a partition of
+// size sectors fits between the partitions in used. Partitions which fill
+// their block (size 0) are always appended.
+func firstFitStart(used []extent, size, align int) (int, error) {
+	if size == 0 {
+		return appendStart(used, align)
+	}
+	if err := sortExtents(used); err != nil {
+		return 0, err
+	}
+	cursor := align
+	for _, e := range used {
+		if start := alignUp(cursor, align); start+size <= e.start {
+			return start, nil
+		}
+		if e.size == 0 {
+			return 0, fmt.Errorf("no free block of %d sectors before the partition at sector %d which fills its free block", size, e.start)
+		}
+		if e.end() > cursor {
+			cursor = e.end()
+		}
+	}
+	return alignUp(cursor, align), nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"reflect"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+)
+
+func TestAllocatePartitions(t *testing.T) {
+	// sizes and starts are in 512 byte sectors, so alignment is 2048
+	tests := []struct {
+		strategy string
+		parts    []types.Partition
+		kept     []util.PartitionInfo
+		out      map[int]int
+		err      bool
+	}{
+		// no strategy leaves everything to sgdisk
+		{
+			strategy: "",
+			parts:    []types.Partition{{Number: 1, SizeMiB: cutil.IntToPtr(2048)}},
+			out:      map[int]int{},
+		},
+		// first-fit fills the gap before an existing partition
+		{
+			strategy: allocationFirstFit,
+			parts:    []types.Partition{{Number: 2, SizeMiB: cutil.IntToPtr(4096)}},
+			kept:     []util.PartitionInfo{{Number: 1, StartSector: 8192, SizeInSectors: 2048}},
+			out:      map[int]int{0: 2048},
+		},
+		// first-fit skips gaps which are too small
+		{
+			strategy: allocationFirstFit,
+			parts:    []types.Partition{{Number: 2, SizeMiB: cutil.IntToPtr(8192)}},
+			kept:     []util.PartitionInfo{{Number: 1, StartSector: 8192, SizeInSectors: 2048}},
+			out:      map[int]int{0: 10240},
+		},
+		// first-fit sees partitions placed before it and explicit starts
+		{
+			strategy: allocationFirstFit,
+			parts: []types.Partition{
+				{Number: 1, SizeMiB: cutil.IntToPtr(2048)},
+				{Number: 2, SizeMiB: cutil.IntToPtr(2048)},
+				{Number: 3, StartMiB: cutil.IntToPtr(6144), SizeMiB: cutil.IntToPtr(2048)},
+			},
+			out: map[int]int{0: 2048, 1: 4096},
+		},
+		// partitions which fill their block are appended under first-fit
+		{
+			strategy: allocationFirstFit,
+			parts:    []types.Partition{{Number: 2, SizeMiB: cutil.IntToPtr(0)}},
+			kept:     []util.PartitionInfo{{Number: 1, StartSector: 8192, SizeInSectors: 2048}},
+			out:      map[int]int{0: 10240},
+		},
+		// append goes after the last partition, aligned
+		{
+			strategy: allocationAppend,
+			parts: []types.Partition{
+				{Number: 2, SizeMiB: cutil.IntToPtr(2048)},
+				{Number: 3, StartMiB: cutil.IntToPtr(0)},
+			},
+			kept: []util.PartitionInfo{{Number: 1, StartSector: 8192, SizeInSectors: 1000}},
+			out:  map[int]int{0: 10240, 1: 12288},
+		},
+		// nothing can be appended after a partition which fills its block
+		{
+			strategy: allocationAppend,
+			parts: []types.Partition{
+				{Number: 1, StartMiB: cutil.IntToPtr(2048)},
+				{Number: 2, SizeMiB: cutil.IntToPtr(2048)},
+			},
+			err: true,
+		},
+		// deleted partitions don't take up space
+		{
+			strategy: allocationAppend,
+			parts: []types.Partition{
+				{Number: 1, ShouldExist: cutil.BoolToPtr(false)},
+				{Number: 2, SizeMiB: cutil.IntToPtr(2048)},
+			},
+			out: map[int]int{1: 2048},
+		},
+		// exact-start requires every created partition to have a start
+		{
+			strategy: allocationExactStart,
+			parts:    []types.Partition{{Number: 1, StartMiB: cutil.IntToPtr(2048)}},
+			out:      map[int]int{},
+		},
+		{
+			strategy: allocationExactStart,
+			parts:    []types.Partition{{Number: 1, SizeMiB: cutil.IntToPtr(2048)}},
+			err:      true,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := allocatePartitions(test.strategy, test.parts, test.kept, 512)
+		if test.err {
+			if err == nil {
+				t.Errorf("#%d: expected error, got %v", i, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: want %v, got %v", i, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/checkpoint"
+	"github.com/coreos/ignition/v2/internal/distro"
+
+	"golang.org/x/sys/unix"
+)
+
+// disksCheckpointed reports whether the checkpoint an earlier attempt with
+// config left in the root records that the disks were set up. If the root
+// isn't mounted yet and config creates its filesystem, the filesystem is
+// mounted read-only to read the checkpoint.
+func (s stage) disksCheckpointed(config types.Config) (bool, error) {
+	digest, err := checkpoint.Digest(config)
+	if err != nil {
+		return false, err
+	}
+	root := s.DestDir
+	if _, err := os.Stat(filepath.Join(root, distro.CheckpointPath())); os.IsNotExist(err) {
+		fs := rootFilesystem(config)
+		if fs == nil {
+			return false, nil
+		}
+		mnt, err := s.mountReadOnly(*fs)
+		if err != nil {
+			// there's no filesystem yet on the first attempt
+			s.Logger.Debug("not reading checkpoint from %q: %v", fs.Device, err)
+			return false, nil
+		}
+		defer func() {
+			if err := unix.Unmount(mnt, 0); err != nil {
+				s.Logger.Warning("failed to unmount %q: %v", mnt, err)
+				return
+			}
+			os.Remove(mnt)
+		}()
+		root = mnt
+	}
+	cp, err := checkpoint.Open(filepath.Join(root, distro.CheckpointPath()), digest)
+	if err != nil {
+		return false, err
+	}
+	return cp.Done(checkpoint.Disks), nil
+}
+
+// rootFilesystem returns the filesystem config mounts at the root, if any.
+func rootFilesystem(config types.Config) *types.Filesystem {
+	for _, fs := range config.Storage.Filesystems {
+		if fs.Path != nil && filepath.Clean(*fs.Path) == "/" {
+			return &fs
+		}
+	}
+	return nil
+}
+
+// mountReadOnly mounts fs read-only at a new temporary directory, which it
+// returns.
+func (s stage) mountReadOnly(fs types.Filesystem) (string, error) {
+	if _, err := os.Stat(fs.Device); err != nil {
+		return "", err
+	}
+	dir, err := ioutil.TempDir("", "ignition-checkpoint-")
+	if err != nil {
+		return "", err
+	}
+	args := []string{"-o", "ro"}
+	if fs.Format != nil {
+		args = append(args, "-t", *fs.Format)
+	}
+	args = append(args, fs.Device, dir)
+	if _, err := s.Logger.LogCmd(
+		exec.CommandContext(s.ctx, distro.MountCmd(), args...),
+		"mounting %q read-only to read the checkpoint", fs.Device,
+	); err != nil {
+		os.Remove(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// markDisksChanged flags that the disks may change, so that the files stage
+// doesn't resume from a checkpoint whose writes may have been wiped.
+func markDisksChanged() error {
+	path := distro.DisksChangedPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, nil, 0644)
+}
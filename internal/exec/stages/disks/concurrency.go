@@ -0,0 +1,95 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// forEachConcurrently calls f with each index below n on up to limit
+// goroutines at once, and returns the errors of the calls which failed,
+// combined in order. Every call is made even if some fail.
+func forEachConcurrently(n, limit int, f func(i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+	work := make(chan int, n)
+	for i := 0; i < n; i++ {
+		work <- i
+	}
+	close(work)
+
+	results := make([]error, n)
+	done := make(chan struct{})
+	workers := limit
+	if n < workers {
+		workers = n
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range work {
+				results[i] = f(i)
+			}
+			done <- struct{}{}
+		}()
+	}
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+
+	var errs []string
+	for _, err := range results {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// forks returns n copies of s, each logging through its own fork of s's
+// logger, for use by the goroutines of forEachConcurrently.
+func (s stage) forks(n int) []stage {
+	forks := make([]stage, n)
+	for i := range forks {
+		forks[i] = s
+		forks[i].Logger = s.Logger.Fork()
+		forks[i].Fetcher.Logger = forks[i].Logger
+	}
+	return forks
+}
+
+// parentDisk returns the whole disk holding the block device dev, or dev
+// with its links resolved if it isn't a partition, e.g. a RAID array.
+func parentDisk(dev string) string {
+	resolved, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return dev
+	}
+	sysfs, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", filepath.Base(resolved)))
+	if err != nil {
+		return resolved
+	}
+	if _, err := os.Stat(filepath.Join(sysfs, "partition")); err != nil {
+		return resolved
+	}
+	// the partition's sysfs directory lives under the disk's
+	return filepath.Join("/dev", filepath.Base(filepath.Dir(sysfs)))
+}
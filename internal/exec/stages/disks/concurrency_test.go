@@ -0,0 +1,107 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disks
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestForEachConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	called := make([]bool, 10)
+	err := forEachConcurrently(len(called), 3, func(i int) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		called[i] = true
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if i%4 == 1 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	})
+
+	for i, ok := range called {
+		if !ok {
+			t.Errorf("item %d was never processed", i)
+		}
+	}
+	if peak > 3 {
+		t.Errorf("%d calls ran at once, more than the limit of 3", peak)
+	}
+	if err == nil || err.Error() != "failed 1\nfailed 5\nfailed 9" {
+		t.Errorf("bad combined error: %v", err)
+	}
+
+	if err := forEachConcurrently(0, 3, func(int) error { return fmt.Errorf("called") }); err != nil {
+		t.Errorf("no items: %v", err)
+	}
+}
+
+// TestPartitionDisksConcurrently partitions two disks at once, so that
+// running it with -race catches state shared between their goroutines.
+func TestPartitionDisksConcurrently(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-disks-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	var disks []types.Disk
+	for _, name := range []string{"disk0", "disk1"} {
+		path := filepath.Join(td, name)
+		if err := ioutil.WriteFile(path, make([]byte, 1<<20), 0644); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		disks = append(disks, types.Disk{Device: path})
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	logger.PushPrefix("createPartitions")
+	s := stage{
+		Util: util.Util{DestDir: td, Logger: &logger, Fetcher: resource.Fetcher{Logger: &logger}},
+		ctx:  context.Background(),
+	}
+	// the disks' aliases don't exist, so partitioning fails once their
+	// partition tables are read, after logging from both goroutines
+	err = s.partitionDisks(disks, false)
+	if err == nil || len(strings.Split(err.Error(), "\n")) != 2 {
+		t.Errorf("expected both disks to fail, got %v", err)
+	}
+	logger.PopPrefix()
+}
@@ -19,13 +19,17 @@
 package disks
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"time"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/failure"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/resource"
 	"github.com/coreos/ignition/v2/internal/systemd"
@@ -57,6 +61,9 @@ func (creator) Name() string {
 
 type stage struct {
 	util.Util
+	// ctx is cancelled when the run should be aborted, which kills the
+	// commands the stage runs
+	ctx context.Context
 
 	client *resource.HttpClient
 }
@@ -65,7 +72,9 @@ func (stage) Name() string {
 	return name
 }
 
-func (s stage) Run(config types.Config) error {
+func (s stage) Run(ctx context.Context, config types.Config) (err error) {
+	s.ctx = ctx
+
 	// Interacting with disks/partitions/raids/filesystems in general can cause
 	// udev races. If we do not need to  do anything, we also do not need to
 	// do the udevadm settle and can just return here.
@@ -75,16 +84,36 @@ func (s stage) Run(config types.Config) error {
 		return nil
 	}
 
+	if config.Storage.Checkpoint != nil && *config.Storage.Checkpoint {
+		if done, err := s.disksCheckpointed(config); err != nil {
+			s.Logger.Warning("failed to read checkpoint: %v", err)
+		} else if done {
+			s.Logger.Info("skipping disks: set up by an earlier attempt")
+			return nil
+		}
+		if err := markDisksChanged(); err != nil {
+			return fmt.Errorf("failed to invalidate checkpoint: %v", err)
+		}
+	}
+
+	// arrays left running by an aborted stage would make the retry fail to
+	// create them, since their members are in use
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			s.stopRaids(config)
+		}
+	}()
+
 	if err := s.createPartitions(config); err != nil {
-		return fmt.Errorf("create partitions failed: %v", err)
+		return failure.Errorf("create partitions failed: %v", err)
 	}
 
 	if err := s.createRaids(config); err != nil {
-		return fmt.Errorf("failed to create raids: %v", err)
+		return failure.Errorf("failed to create raids: %v", err)
 	}
 
 	if err := s.createFilesystems(config); err != nil {
-		return fmt.Errorf("failed to create filesystems: %v", err)
+		return failure.Errorf("failed to create filesystems: %v", err)
 	}
 
 	// udevd registers an IN_CLOSE_WRITE inotify watch on block device
@@ -112,7 +141,7 @@ func (s stage) Run(config types.Config) error {
 	// Additionally, partitioning (and possibly creating raid) suffers
 	// the same problem. To be safe, always settle.
 	if _, err := s.Logger.LogCmd(
-		exec.Command(distro.UdevadmCmd(), "settle"),
+		exec.CommandContext(s.ctx, distro.UdevadmCmd(), "settle"),
 		"waiting for udev to settle",
 	); err != nil {
 		return fmt.Errorf("udevadm settle failed: %v", err)
@@ -121,6 +150,63 @@ func (s stage) Run(config types.Config) error {
 	return nil
 }
 
+// waitForDisks waits for every disk in disks which specifies a waitTimeout to
+// appear, giving up once that disk's timeout has elapsed. The timeouts are
+// all measured from the time waitForDisks is called.
+func (s stage) waitForDisks(disks []types.Disk) error {
+	start := time.Now()
+	for _, disk := range disks {
+		if disk.WaitTimeout == nil || *disk.WaitTimeout == 0 {
+			continue
+		}
+		deadline := start.Add(time.Duration(*disk.WaitTimeout) * time.Second)
+		if err := s.waitForDevice(disk.Device, deadline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForDevice polls for dev to exist until deadline, waiting for udev to
+// settle between checks so slow-attaching devices get a chance to show up.
+func (s stage) waitForDevice(dev string, deadline time.Time) error {
+	for attempt := 1; ; attempt++ {
+		if _, err := os.Stat(dev); err == nil {
+			if attempt > 1 {
+				s.Logger.Info("device %q appeared after %d checks", dev, attempt)
+			}
+			return nil
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to stat %q: %v", dev, err)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return failure.Wrap(failure.ErrDeviceMissing, fmt.Errorf("timed out waiting for device %q", dev))
+		}
+		s.Logger.Info("device %q does not exist yet, waiting up to %s", dev, remaining.Round(time.Second))
+
+		// udevadm settle returns as soon as the device exists; a failure
+		// here just means the queue didn't drain in time, so keep polling.
+		timeout := int(remaining.Seconds())
+		if timeout < 1 {
+			timeout = 1
+		}
+		if _, err := s.Logger.LogCmd(
+			exec.CommandContext(s.ctx, distro.UdevadmCmd(), "settle", "--exit-if-exists="+dev, fmt.Sprintf("--timeout=%d", timeout)),
+			"waiting for udev to settle",
+		); err != nil {
+			s.Logger.Debug("udevadm settle failed while waiting for %q: %v", dev, err)
+		}
+
+		// settle returns immediately if the udev queue is empty, so don't
+		// spin while the device hasn't been attached yet.
+		if _, err := os.Stat(dev); os.IsNotExist(err) {
+			time.Sleep(time.Second)
+		}
+	}
+}
+
 // waitOnDevices waits for the devices enumerated in devs as a logged operation
 // using ctxt for the logging and systemd unit identity.
 func (s stage) waitOnDevices(devs []string, ctxt string) error {
@@ -128,7 +214,7 @@ func (s stage) waitOnDevices(devs []string, ctxt string) error {
 		func() error { return systemd.WaitOnDevices(devs, ctxt) },
 		"waiting for devices %v", devs,
 	); err != nil {
-		return fmt.Errorf("failed to wait on %s devs: %v", ctxt, err)
+		return failure.Wrap(failure.ErrDeviceMissing, fmt.Errorf("failed to wait on %s devs: %v", ctxt, err))
 	}
 
 	return nil
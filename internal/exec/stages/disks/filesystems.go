@@ -22,12 +22,12 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
-	"runtime"
 	"strings"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/fsprobe"
 )
 
 var (
@@ -54,37 +54,31 @@ func (s stage) createFilesystems(config types.Config) error {
 		return err
 	}
 
-	// Create filesystems concurrently up to GOMAXPROCS
-	concurrency := runtime.GOMAXPROCS(-1)
-	work := make(chan types.Filesystem, len(fss))
-	results := make(chan error)
-
-	for i := 0; i < concurrency; i++ {
-		go func() {
-			for fs := range work {
-				results <- s.createFilesystem(fs)
-			}
-		}()
-	}
-
+	// Create filesystems on different disks concurrently, and those on
+	// the same disk one at a time, in order, so they don't compete for it
+	var disks []string
+	byDisk := map[string][]types.Filesystem{}
 	for _, fs := range fss {
-		work <- fs
-	}
-	close(work)
-
-	// Return combined errors
-	var errs []string
-	for range fss {
-		if err := <-results; err != nil {
-			errs = append(errs, err.Error())
+		disk := parentDisk(util.DeviceAlias(string(fs.Device)))
+		if _, ok := byDisk[disk]; !ok {
+			disks = append(disks, disk)
 		}
+		byDisk[disk] = append(byDisk[disk], fs)
 	}
 
-	if len(errs) > 0 {
-		return errors.New(strings.Join(errs, "\n"))
-	}
-
-	return nil
+	forks := s.forks(len(disks))
+	return forEachConcurrently(len(disks), distro.DiskConcurrency(), func(i int) error {
+		var errs []string
+		for _, fs := range byDisk[disks[i]] {
+			if err := forks[i].createFilesystem(fs); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, "\n"))
+		}
+		return nil
+	})
 }
 
 func (s stage) createFilesystem(fs types.Filesystem) error {
@@ -96,12 +90,19 @@ func (s stage) createFilesystem(fs types.Filesystem) error {
 		return err
 	}
 
+	if fs.Assert != nil && *fs.Assert {
+		if !filesystemMatches(info, fs) {
+			s.Logger.Err("filesystem at %q is asserted but is not of the correct type, label, or UUID (found %s, %q, %s)", fs.Device, info.format, info.label, info.uuid)
+			return ErrBadFilesystem
+		}
+		s.Logger.Info("filesystem at %q matches its assertion", fs.Device)
+		return nil
+	}
+
 	if fs.WipeFilesystem == nil || !*fs.WipeFilesystem {
 		// If the filesystem isn't forcefully being created, then we need
 		// to check if it is of the correct type or that no filesystem exists.
-		if info.format == *fs.Format &&
-			(fs.Label == nil || info.label == *fs.Label) &&
-			(fs.UUID == nil || canonicalizeFilesystemUUID(info.format, info.uuid) == canonicalizeFilesystemUUID(*fs.Format, *fs.UUID)) {
+		if filesystemMatches(info, fs) {
 			s.Logger.Info("filesystem at %q is already correctly formatted. Skipping mkfs...", fs.Device)
 			return nil
 		} else if info.format != "" {
@@ -166,7 +167,7 @@ func (s stage) createFilesystem(fs types.Filesystem) error {
 	devAlias := util.DeviceAlias(string(fs.Device))
 	args = append(args, devAlias)
 	if _, err := s.Logger.LogCmd(
-		exec.Command(mkfs, args...),
+		exec.CommandContext(s.ctx, mkfs, args...),
 		"creating %q filesystem on %q",
 		*fs.Format, devAlias,
 	); err != nil {
@@ -176,6 +177,14 @@ func (s stage) createFilesystem(fs types.Filesystem) error {
 	return nil
 }
 
+// filesystemMatches returns whether the existing filesystem described by info
+// has the format, label, and UUID specified by fs.
+func filesystemMatches(info filesystemInfo, fs types.Filesystem) bool {
+	return info.format == *fs.Format &&
+		(fs.Label == nil || info.label == *fs.Label) &&
+		(fs.UUID == nil || canonicalizeFilesystemUUID(info.format, info.uuid) == canonicalizeFilesystemUUID(*fs.Format, *fs.UUID))
+}
+
 // golang--
 func translateOptionSliceToStringSlice(opts []types.FilesystemOption) []string {
 	newOpts := make([]string, len(opts))
@@ -196,15 +205,7 @@ func (s stage) readFilesystemInfo(fs types.Filesystem) (filesystemInfo, error) {
 	err := s.Logger.LogOp(
 		func() error {
 			var err error
-			res.format, err = util.FilesystemType(fs.Device)
-			if err != nil {
-				return err
-			}
-			res.uuid, err = util.FilesystemUUID(fs.Device)
-			if err != nil {
-				return err
-			}
-			res.label, err = util.FilesystemLabel(fs.Device)
+			res, err = probeFilesystem(fs.Device)
 			if err != nil {
 				return err
 			}
@@ -217,6 +218,29 @@ func (s stage) readFilesystemInfo(fs types.Filesystem) (filesystemInfo, error) {
 	return res, err
 }
 
+// probeFilesystem identifies the filesystem on device with the backend
+// distro.FSProbeBackend selects.
+func probeFilesystem(device string) (filesystemInfo, error) {
+	res := filesystemInfo{}
+	switch backend := distro.FSProbeBackend(); backend {
+	case "blkid":
+		var err error
+		if res.format, err = util.FilesystemType(device); err != nil {
+			return res, err
+		}
+		if res.uuid, err = util.FilesystemUUID(device); err != nil {
+			return res, err
+		}
+		res.label, err = util.FilesystemLabel(device)
+		return res, err
+	case "builtin":
+		info, err := fsprobe.Probe(device)
+		return filesystemInfo{format: info.Type, uuid: info.UUID, label: info.Label}, err
+	default:
+		return res, fmt.Errorf("unknown filesystem probe backend %q", backend)
+	}
+}
+
 // canonicalizeFilesystemUUID does the minimum amount of canonicalization
 // required to make two valid equivalent UUIDs compare equal, but doesn't
 // attempt to fully validate the UUID.
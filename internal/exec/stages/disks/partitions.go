@@ -21,20 +21,48 @@ package disks
 import (
 	"errors"
 	"fmt"
+	"os"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/gpt"
 	"github.com/coreos/ignition/v2/internal/sgdisk"
+
+	"golang.org/x/sys/unix"
 )
 
 var (
 	ErrBadSgdiskOutput = errors.New("sgdisk had unexpected output")
 )
 
+// partitioner is an operation on a partition table, by either of the
+// backends distro.GPTBackend selects between.
+type partitioner interface {
+	CreatePartition(p types.Partition)
+	DeletePartition(num int)
+	Info(num int)
+	WipeTable(wipe bool)
+	Pretend() (string, error)
+	Commit() error
+}
+
+// beginPartitioning begins an operation on the partition table of dev.
+func (s stage) beginPartitioning(dev string) (partitioner, error) {
+	switch backend := distro.GPTBackend(); backend {
+	case "sgdisk":
+		return sgdisk.Begin(s.ctx, s.Logger, dev), nil
+	case "builtin":
+		return gpt.Begin(s.Logger, dev), nil
+	default:
+		return nil, fmt.Errorf("unknown GPT backend %q", backend)
+	}
+}
+
 // createPartitions creates the partitions described in config.Storage.Disks.
 func (s stage) createPartitions(config types.Config) error {
 	if len(config.Storage.Disks) == 0 {
@@ -48,22 +76,28 @@ func (s stage) createPartitions(config types.Config) error {
 		devs = append(devs, string(disk.Device))
 	}
 
+	if err := s.waitForDisks(config.Storage.Disks); err != nil {
+		return err
+	}
+
 	if err := s.waitOnDevicesAndCreateAliases(devs, "disks"); err != nil {
 		return err
 	}
 
-	for _, dev := range config.Storage.Disks {
-		devAlias := util.DeviceAlias(string(dev.Device))
+	return s.partitionDisks(config.Storage.Disks, config.Storage.Audit != nil && *config.Storage.Audit)
+}
 
-		err := s.Logger.LogOp(func() error {
-			return s.partitionDisk(dev, devAlias)
+// partitionDisks partitions each of disks. Disks are independent, so
+// several are partitioned at once, each logging through its own fork of the
+// logger.
+func (s stage) partitionDisks(disks []types.Disk, audit bool) error {
+	forks := s.forks(len(disks))
+	return forEachConcurrently(len(disks), distro.DiskConcurrency(), func(i int) error {
+		devAlias := util.DeviceAlias(string(disks[i].Device))
+		return forks[i].Logger.LogOp(func() error {
+			return forks[i].partitionDisk(disks[i], devAlias, audit)
 		}, "partitioning %q", devAlias)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	})
 }
 
 // partitionMatches determines if the existing partition matches the spec given. See doc/operator notes for what
@@ -92,6 +126,36 @@ func partitionMatches(existing util.PartitionInfo, spec types.Partition) error {
 	return nil
 }
 
+// assertPartition returns an error if an asserted partition does not already
+// look the way it was specified. Asserted partitions are never modified.
+func assertPartition(number int, exists, shouldExist bool, matchErr error) error {
+	switch {
+	case !exists && shouldExist:
+		return fmt.Errorf("partition %d is asserted but does not exist", number)
+	case exists && !shouldExist:
+		return fmt.Errorf("partition %d is asserted as nonexistent but exists", number)
+	case exists && matchErr != nil:
+		return fmt.Errorf("partition %d is asserted but didn't match: %v", number, matchErr)
+	}
+	return nil
+}
+
+// assertNoUndeclaredPartitions returns an error if diskInfo has partitions
+// which aren't among parts. It's used for asserted disks, where the declared
+// partitions must describe the whole table.
+func assertNoUndeclaredPartitions(diskInfo util.DiskInfo, parts []types.Partition) error {
+	declared := map[int]bool{}
+	for _, part := range parts {
+		declared[part.Number] = true
+	}
+	for _, info := range diskInfo.Partitions {
+		if !declared[info.Number] {
+			return fmt.Errorf("disk is asserted but has undeclared partition %d", info.Number)
+		}
+	}
+	return nil
+}
+
 // partitionShouldBeInspected returns if the partition has zeroes that need to be resolved to sectors.
 func partitionShouldBeInspected(part types.Partition) bool {
 	if part.Number == 0 {
@@ -112,7 +176,11 @@ func convertMiBToSectors(mib *int, sectorSize int) {
 // everything specified were to be (re)created.
 // It also converts everything to sectors so the StartMiB/SizeMiB will NOT be in MiB after this call
 func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, diskInfo util.DiskInfo) ([]types.Partition, error) {
-	op := sgdisk.Begin(s.Logger, devAlias)
+	op, err := s.beginPartitioning(devAlias)
+	if err != nil {
+		return nil, err
+	}
+	parts := []types.Partition{}
 	for _, part := range dev.Partitions {
 		convertMiBToSectors(part.SizeMiB, diskInfo.LogicalSectorSize)
 		convertMiBToSectors(part.StartMiB, diskInfo.LogicalSectorSize)
@@ -128,6 +196,33 @@ func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, diskInfo uti
 				part.SizeMiB = &info.SizeInSectors
 			}
 		}
+		parts = append(parts, part)
+	}
+
+	// existing partitions which aren't in the config stay where they are
+	kept := []util.PartitionInfo{}
+	for _, info := range diskInfo.Partitions {
+		specified := false
+		for _, part := range dev.Partitions {
+			specified = specified || part.Number == info.Number
+		}
+		if !specified {
+			kept = append(kept, info)
+		}
+	}
+	strategy := ""
+	if dev.AllocationStrategy != nil {
+		strategy = *dev.AllocationStrategy
+	}
+	starts, err := allocatePartitions(strategy, parts, kept, diskInfo.LogicalSectorSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, part := range parts {
+		if start, ok := starts[i]; ok {
+			part.StartMiB = &start
+		}
 		if partitionShouldExist(part) {
 			// Clear the label. sgdisk doesn't escape control characters. This makes parsing easier
 			part.Label = nil
@@ -155,7 +250,10 @@ func (s stage) getRealStartAndSize(dev types.Disk, devAlias string, diskInfo uti
 	}
 
 	result := []types.Partition{}
-	for _, part := range dev.Partitions {
+	for i, part := range dev.Partitions {
+		if start, ok := starts[i]; ok {
+			part.StartMiB = &start
+		}
 		if dims, ok := realDimensions[part.Number]; ok {
 			if part.StartMiB != nil {
 				part.StartMiB = &dims.start
@@ -295,10 +393,15 @@ func (p PartitionList) Swap(i, j int) {
 	p[i], p[j] = p[j], p[i]
 }
 
-// partitionDisk partitions devAlias according to the spec given by dev
-func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
+// partitionDisk partitions devAlias according to the spec given by dev,
+// then re-reads the partition table from the disk to check it if audit is
+// set.
+func (s stage) partitionDisk(dev types.Disk, devAlias string, audit bool) error {
 	if dev.WipeTable != nil && *dev.WipeTable {
-		op := sgdisk.Begin(s.Logger, devAlias)
+		op, err := s.beginPartitioning(devAlias)
+		if err != nil {
+			return err
+		}
 		s.Logger.Info("wiping partition table requested on %q", devAlias)
 		op.WipeTable(true)
 		op.Commit()
@@ -307,7 +410,10 @@ func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 	// Ensure all partitions with number 0 are last
 	sort.Stable(PartitionList(dev.Partitions))
 
-	op := sgdisk.Begin(s.Logger, devAlias)
+	op, err := s.beginPartitioning(devAlias)
+	if err != nil {
+		return err
+	}
 
 	diskInfo, err := s.getPartitionMap(devAlias)
 	if err != nil {
@@ -322,6 +428,12 @@ func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 		return err
 	}
 
+	if dev.Assert != nil && *dev.Assert {
+		if err := assertNoUndeclaredPartitions(diskInfo, dev.Partitions); err != nil {
+			return err
+		}
+	}
+
 	for _, part := range resolvedPartitions {
 		shouldExist := partitionShouldExist(part)
 		info, exists := diskInfo.GetPartition(part.Number)
@@ -332,6 +444,14 @@ func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 		matches := exists && matchErr == nil
 		wipeEntry := part.WipePartitionEntry != nil && *part.WipePartitionEntry
 
+		if (dev.Assert != nil && *dev.Assert) || (part.Assert != nil && *part.Assert) {
+			if err := assertPartition(part.Number, exists, shouldExist, matchErr); err != nil {
+				return err
+			}
+			s.Logger.Info("partition %d matches its assertion", part.Number)
+			continue
+		}
+
 		// This is a translation of the matrix in the operator notes.
 		switch {
 		case !exists && !shouldExist:
@@ -359,5 +479,48 @@ func (s stage) partitionDisk(dev types.Disk, devAlias string) error {
 	if err := op.Commit(); err != nil {
 		return fmt.Errorf("commit failure: %v", err)
 	}
+	if audit {
+		return s.auditPartitions(devAlias, resolvedPartitions)
+	}
 	return nil
 }
+
+// auditPartitions checks that the partition table of devAlias, as read back
+// from the disk, has each of parts as specified. Partitions without a number
+// are skipped since there's no telling which they became.
+func (s stage) auditPartitions(devAlias string, parts []types.Partition) error {
+	if err := flushBuffers(devAlias); err != nil {
+		return fmt.Errorf("flushing buffers of %q: %v", devAlias, err)
+	}
+	diskInfo, err := s.getPartitionMap(devAlias)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		if part.Number == 0 {
+			continue
+		}
+		info, exists := diskInfo.GetPartition(part.Number)
+		switch {
+		case exists != partitionShouldExist(part):
+			return fmt.Errorf("audit failed: partition %d should exist: %t, but exists: %t", part.Number, partitionShouldExist(part), exists)
+		case exists:
+			if err := partitionMatches(info, part); err != nil {
+				return fmt.Errorf("audit failed: partition %d: %v", part.Number, err)
+			}
+		}
+	}
+	s.Logger.Info("partition table of %q matches its specification", devAlias)
+	return nil
+}
+
+// flushBuffers drops the kernel's cached blocks of dev, so the next read
+// comes from the disk itself.
+func flushBuffers(dev string) error {
+	f, err := os.Open(dev)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return unix.IoctlSetInt(int(f.Fd()), unix.BLKFLSBUF, 0)
+}
@@ -21,7 +21,9 @@ package disks
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
@@ -47,6 +49,13 @@ func (s stage) createRaids(config types.Config) error {
 	}
 
 	for _, md := range config.Storage.Raid {
+		if md.Assemble != nil && *md.Assemble {
+			if err := s.assembleRaid(md); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if md.Spares == nil {
 			zero := 0
 			md.Spares = &zero
@@ -60,6 +69,10 @@ func (s stage) createRaids(config types.Config) error {
 			"--raid-devices", fmt.Sprintf("%d", len(md.Devices)-*md.Spares),
 		}
 
+		if md.UUID != nil {
+			args = append(args, "--uuid", *md.UUID)
+		}
+
 		if *md.Spares > 0 {
 			args = append(args, "--spare-devices", fmt.Sprintf("%d", *md.Spares))
 		}
@@ -73,7 +86,7 @@ func (s stage) createRaids(config types.Config) error {
 		}
 
 		if _, err := s.Logger.LogCmd(
-			exec.Command(distro.MdadmCmd(), args...),
+			exec.CommandContext(s.ctx, distro.MdadmCmd(), args...),
 			"creating %q", md.Name,
 		); err != nil {
 			return fmt.Errorf("mdadm failed: %v", err)
@@ -82,3 +95,67 @@ func (s stage) createRaids(config types.Config) error {
 
 	return nil
 }
+
+// stopRaids stops the arrays config creates which are running, after the
+// stage is aborted. Arrays config assembles are left running, since they
+// existed before the stage.
+func (s stage) stopRaids(config types.Config) {
+	for _, md := range config.Storage.Raid {
+		if md.Assemble != nil && *md.Assemble {
+			continue
+		}
+		dev := raidDevice(md.Name)
+		if _, err := os.Stat(dev); err != nil {
+			continue
+		}
+		// not s.ctx, which is cancelled
+		if _, err := s.Logger.LogCmd(
+			exec.Command(distro.MdadmCmd(), "--stop", dev),
+			"stopping %q", md.Name,
+		); err != nil {
+			s.Logger.Warning("failed to stop %q; a retry may fail to create it", md.Name)
+		}
+	}
+}
+
+// raidDevice returns the path of the md device mdadm creates for name.
+func raidDevice(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join("/dev/md", name)
+}
+
+// assembleRaid assembles the existing array md from its member devices,
+// checking that it has the specified UUID, and waits for the md device to
+// appear.
+func (s stage) assembleRaid(md types.Raid) error {
+	dev := raidDevice(md.Name)
+	if _, err := os.Stat(dev); err == nil {
+		// udev may have assembled it incrementally already
+		s.Logger.Info("raid array %q is already assembled", md.Name)
+	} else {
+		args := []string{
+			"--assemble", md.Name,
+			"--run",
+			"--uuid", *md.UUID,
+		}
+
+		for _, o := range md.Options {
+			args = append(args, string(o))
+		}
+
+		for _, dev := range md.Devices {
+			args = append(args, util.DeviceAlias(string(dev)))
+		}
+
+		if _, err := s.Logger.LogCmd(
+			exec.CommandContext(s.ctx, distro.MdadmCmd(), args...),
+			"assembling %q", md.Name,
+		); err != nil {
+			return fmt.Errorf("mdadm failed: %v", err)
+		}
+	}
+
+	return s.waitOnDevices([]string{dev}, "raids")
+}
@@ -19,6 +19,8 @@
 package disks
 
 import (
+	"context"
+
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
@@ -57,7 +59,7 @@ func (stage) Name() string {
 	return name
 }
 
-func (s stage) Run(_ types.Config) error {
+func (s stage) Run(_ context.Context, _ types.Config) error {
 	// Nothing - all we do is fetch and allow anything else in the initramfs to run
 	s.Logger.Info("fetch complete")
 	return nil
@@ -0,0 +1,75 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The fetch-offline stage fetches the config without networking. If the
+// config, or anything it references, requires networking, the stage
+// signals it and the fetch stage fetches the config again once networking
+// is up. Boots with configs which don't need it never wait for it.
+
+package fetch_offline
+
+import (
+	"context"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/stages"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+const (
+	name = "fetch-offline"
+)
+
+func init() {
+	stages.Register(creator{})
+}
+
+type creator struct{}
+
+func (creator) Create(logger *log.Logger, root string, _ resource.Fetcher) stages.Stage {
+	return &stage{
+		Util: util.Util{
+			DestDir: root,
+			Logger:  logger,
+		},
+	}
+}
+
+func (creator) Name() string {
+	return name
+}
+
+type stage struct {
+	util.Util
+}
+
+func (stage) Name() string {
+	return name
+}
+
+// Run returns resource.ErrNeedNet if a later stage needs networking to
+// apply the config, which the engine turns into the signal.
+func (s stage) Run(_ context.Context, cfg types.Config) error {
+	needsNet, err := resource.ConfigNeedsNet(cfg)
+	if err != nil {
+		return err
+	}
+	if needsNet {
+		return resource.ErrNeedNet
+	}
+	s.Logger.Info("fetch-offline complete: config doesn't require networking")
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/failure"
+	"github.com/coreos/ignition/v2/internal/rollback"
+)
+
+// stageFiles fetches and verifies the contents of every file in the config
+// into a staging directory before anything is written, so that a failed
+// fetch is caught before the target is touched, rather than rolled back
+// by undo. The staging directory lives in DestDir rather than the
+// initramfs so that large contents don't have to fit in memory. The
+// returned function removes the staging directory.
+func (s *stage) stageFiles(config types.Config) (func(), error) {
+	s.Logger.PushPrefix("stageFiles")
+	defer s.Logger.PopPrefix()
+
+	// an attempt killed before it could clean up leaves its staging
+	// directory behind, taking up room on the root filesystem
+	stale, err := filepath.Glob(filepath.Join(s.DestDir, ".ignition-staged-*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range stale {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := ioutil.TempDir(s.DestDir, ".ignition-staged-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			s.Logger.Warning("failed to remove staging directory %q: %v", dir, err)
+		}
+	}
+
+	s.Staged = map[string]string{}
+	for _, f := range config.Storage.Files {
+		// optional files don't fail the stage, so there's nothing to gain
+		// from fetching them early
+		if f.WarnsOnFailure() {
+			continue
+		}
+		if done, err := s.fileCheckpointed(f); err != nil {
+			cleanup()
+			return nil, err
+		} else if done {
+			continue
+		}
+		ops, err := s.PrepareFetches(s.Logger, f)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("failed to resolve file %q: %v", f.Path, err)
+		}
+		for _, op := range ops {
+			if err := s.Logger.LogOp(
+				func() error {
+					return s.StageFetch(s.ctx, op, dir)
+				}, "staging contents of %q", f.Path,
+			); err != nil {
+				cleanup()
+				return nil, failure.Errorf("failed to fetch contents of %q: %v", f.Path, err)
+			}
+		}
+	}
+	return cleanup, nil
+}
+
+// undo restores the snapshot in dir after the stage failed partway through,
+// so that the target is left as it was before the stage ran.
+func (s *stage) undo(dir string) {
+	s.Logger.PushPrefix("undo")
+	defer s.Logger.PopPrefix()
+
+	if err := rollback.Restore(s.Logger, dir, s.DestDir); err != nil {
+		s.Logger.Crit("failed to undo changes: %v", err)
+		return
+	}
+	// the operations recorded as completed were undone too
+	if err := s.removeCheckpoint(); err != nil {
+		s.Logger.Warning("failed to remove checkpoint: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestStageFilesRemovesStale(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-atomic-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	// left behind by a killed attempt
+	stale := filepath.Join(td, ".ignition-staged-123")
+	if err := os.MkdirAll(stale, 0700); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(stale, "staged456"), []byte("contents"), 0600); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	s := stage{Util: util.Util{DestDir: td, Logger: &logger}}
+	cleanup, err := s.stageFiles(types.Config{})
+	if err != nil {
+		t.Fatalf("failed to stage files: %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale staging directory wasn't removed: %v", err)
+	}
+	cleanup()
+	if dirs, _ := filepath.Glob(filepath.Join(td, ".ignition-staged-*")); len(dirs) != 0 {
+		t.Errorf("staging directories weren't removed: %v", dirs)
+	}
+}
+
+func TestAtomicFilesUndo(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-atomic-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	if err := os.MkdirAll(filepath.Join(td, "etc"), 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "etc/existing"), []byte("old"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	// a file can't be created under a regular file
+	if err := os.MkdirAll(filepath.Join(td, "var"), 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "var/blocker"), nil, 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	before := treeState(t, td)
+
+	logger := log.New(true)
+	defer logger.Close()
+	s := stage{
+		Util: util.Util{
+			DestDir: td,
+			Logger:  &logger,
+			Fetcher: resource.Fetcher{Logger: &logger},
+		},
+	}
+	config := types.Config{
+		Storage: types.Storage{
+			AtomicFiles: cutil.BoolToPtr(true),
+			Files: []types.File{
+				{
+					Node: types.Node{Path: "/etc/existing", Overwrite: cutil.BoolToPtr(true)},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.FileContents{Source: cutil.StrToPtr("data:,new")},
+					},
+				},
+				{
+					Node: types.Node{Path: "/var/blocker/child"},
+					FileEmbedded1: types.FileEmbedded1{
+						Contents: types.FileContents{Source: cutil.StrToPtr("data:,child")},
+					},
+				},
+			},
+			Directories: []types.Directory{{Node: types.Node{Path: "/new/dir"}}},
+			Links: []types.Link{
+				{
+					Node:          types.Node{Path: "/etc/link"},
+					LinkEmbedded1: types.LinkEmbedded1{Target: "/etc/existing"},
+				},
+			},
+		},
+	}
+	if err := s.Run(context.Background(), config); err == nil {
+		t.Fatalf("expected the files stage to fail")
+	}
+
+	if after := treeState(t, td); !reflect.DeepEqual(before, after) {
+		t.Errorf("tree changed:\nbefore: %v\nafter:  %v", before, after)
+	}
+}
+
+// treeState returns the mode and contents or link target of every node
+// under root.
+func treeState(t *testing.T, root string) map[string]string {
+	state := map[string]string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		contents := ""
+		switch {
+		case info.Mode().IsRegular():
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			contents = string(data)
+		case info.Mode()&os.ModeSymlink != 0:
+			if contents, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		state[path[len(root):]] = fmt.Sprintf("%v %q", info.Mode(), contents)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk error: %v", err)
+	}
+	return state
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"golang.org/x/sys/unix"
+)
+
+// auditFiles re-reads every file in config.Storage.Files from the disk, if
+// config.Storage.Audit is set, and fails if its contents differ from what
+// was written.
+func (s *stage) auditFiles(config types.Config) error {
+	if config.Storage.Audit == nil || !*config.Storage.Audit {
+		return nil
+	}
+
+	s.Logger.PushPrefix("auditFiles")
+	defer s.Logger.PopPrefix()
+
+	for _, f := range config.Storage.Files {
+		path, err := s.JoinPath(f.Path)
+		if err != nil {
+			return err
+		}
+		// optional files may not have been created
+		if _, err := os.Lstat(path); os.IsNotExist(err) && f.WarnsOnFailure() {
+			continue
+		}
+		if err := s.Logger.LogOp(
+			func() error { return auditFile(path) },
+			"auditing %q", f.Path,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditFile compares the contents of the file at path as written, which are
+// still in the page cache, with its contents on disk.
+func auditFile(path string) error {
+	// reading shouldn't change the access time the config may have set
+	f, err := os.OpenFile(path, os.O_RDONLY|unix.O_NOATIME, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	written, err := hashFile(f)
+	if err != nil {
+		return err
+	}
+	// write the file back and evict it from the page cache, so it's read
+	// from the disk the second time
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED); err != nil {
+		return fmt.Errorf("evicting from page cache: %v", err)
+	}
+	onDisk, err := hashFile(f)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(written, onDisk) {
+		return fmt.Errorf("contents on disk differ from the contents written")
+	}
+	return nil
+}
+
+func hashFile(f *os.File) ([]byte, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
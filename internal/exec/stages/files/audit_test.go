@@ -0,0 +1,41 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuditFile(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-audit-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "file")
+	if err := ioutil.WriteFile(path, []byte("contents\n"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := auditFile(path); err != nil {
+		t.Errorf("audit of intact file failed: %v", err)
+	}
+	if err := auditFile(filepath.Join(td, "missing")); err == nil {
+		t.Errorf("audit of missing file succeeded")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"os"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/checkpoint"
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+// openCheckpoint opens the checkpoint of config in the root, if
+// config.Storage.Checkpoint is set, so that operations completed by an
+// earlier attempt are skipped. The paths the earlier attempt was to
+// relabel are relabeled.
+func (s *stage) openCheckpoint(config types.Config) error {
+	if config.Storage.Checkpoint == nil || !*config.Storage.Checkpoint {
+		return nil
+	}
+	digest, err := checkpoint.Digest(config)
+	if err != nil {
+		return err
+	}
+	path, err := s.JoinPath(distro.CheckpointPath())
+	if err != nil {
+		return err
+	}
+	cp, err := checkpoint.Open(path, digest)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(distro.DisksChangedPath()); err == nil && cp.Len() > 0 {
+		s.Logger.Info("disks may have changed since the checkpoint was recorded; not resuming")
+		if err := cp.Reset(); err != nil {
+			return err
+		}
+	} else if cp.Len() > 0 {
+		s.Logger.Info("resuming from checkpoint with %d operations completed", cp.Len())
+		s.relabel(cp.Relabel()...)
+	}
+	s.checkpoint = cp
+	// the files stage only runs once the disks are set up
+	return s.recordCheckpoint(checkpoint.Disks)
+}
+
+// checkpointed reports whether op was completed by an earlier attempt.
+func (s *stage) checkpointed(op string) bool {
+	if s.checkpoint == nil || !s.checkpoint.Done(op) {
+		return false
+	}
+	s.Logger.Info("skipping %s: completed by an earlier attempt", op)
+	return true
+}
+
+// fileCheckpointed reports whether f was written by an earlier attempt,
+// without logging it, since the file is skipped again when it's created.
+func (s *stage) fileCheckpointed(f types.File) (bool, error) {
+	if s.checkpoint == nil {
+		return false, nil
+	}
+	path, err := s.JoinPath(f.Path)
+	if err != nil {
+		return false, err
+	}
+	f.Path = path
+	op, err := s.describeEntry(fileEntry(f))
+	if err != nil {
+		return false, err
+	}
+	return s.checkpoint.Done(op), nil
+}
+
+// recordCheckpoint records that op has completed, if checkpointing.
+func (s *stage) recordCheckpoint(op string) error {
+	if s.checkpoint == nil {
+		return nil
+	}
+	return s.checkpoint.Record(op, s.toRelabel)
+}
+
+// removeCheckpoint removes the checkpoint once the stage has completed.
+func (s *stage) removeCheckpoint() error {
+	if s.checkpoint == nil {
+		return nil
+	}
+	return s.checkpoint.Remove()
+}
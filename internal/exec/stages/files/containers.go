@@ -0,0 +1,165 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+// pullContainerImages copies the images in config.Containers.Images from
+// their registries into the containers storage of the target root, so
+// workloads using them start without pulling them on first boot.
+func (s *stage) pullContainerImages(config types.Config) error {
+	if len(config.Containers.Images) == 0 {
+		return nil
+	}
+
+	s.Logger.PushPrefix("pullContainerImages")
+	defer s.Logger.PopPrefix()
+
+	tmpDir, err := ioutil.TempDir("", "ignition-containers")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	authFile := ""
+	if auth := config.Containers.Auth; auth.Source != nil {
+		// the registry credentials never touch the target root
+		f := types.File{
+			Node: types.Node{Path: filepath.Join(tmpDir, "auth.json")},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.FileContents{
+					Source:       auth.Source,
+					Verification: auth.Verification,
+				},
+			},
+		}
+		fetchOps, err := s.PrepareFetches(s.Logger, f)
+		if err != nil {
+			return err
+		}
+		for _, op := range fetchOps {
+			if err := s.Logger.LogOp(
+				func() error { return s.PerformFetch(s.ctx, op) },
+				"fetching registry credentials",
+			); err != nil {
+				return err
+			}
+		}
+		authFile = f.Path
+	}
+
+	if distro.BlackboxTesting() {
+		s.Logger.Info("skipping pulling of container images during blackbox testing")
+		return nil
+	}
+	storageDir, err := s.JoinPath(distro.ContainersStorageDir())
+	if err != nil {
+		return err
+	}
+	// the run root only holds locks and is discarded with tmpDir
+	runRoot := filepath.Join(tmpDir, "run")
+	for _, image := range config.Containers.Images {
+		if _, err := s.Logger.LogCmd(
+			exec.CommandContext(s.ctx, distro.SkopeoCmd(), skopeoCopyArgs(image.Name, authFile, storageDir, runRoot)...),
+			"pulling container image %q", image.Name,
+		); err != nil {
+			return fmt.Errorf("%s failed to pull %q: %v", distro.SkopeoCmd(), image.Name, err)
+		}
+	}
+	s.relabel(distro.ContainersStorageDir())
+	return nil
+}
+
+// skopeoCopyArgs returns the arguments to skopeo copying image from its
+// registry into the containers storage at storageDir.
+func skopeoCopyArgs(image, authFile, storageDir, runRoot string) []string {
+	args := []string{"copy"}
+	if authFile != "" {
+		args = append(args, "--authfile", authFile)
+	}
+	return append(args,
+		"docker://"+image,
+		fmt.Sprintf("containers-storage:[%s@%s+%s]%s", distro.ContainersStorageDriver(), storageDir, runRoot, image),
+	)
+}
+
+// writeContainerUnits writes a quadlet file for each unit in
+// config.Containers.Units, from which podman's systemd generator creates a
+// service running the container on boot.
+func (s *stage) writeContainerUnits(config types.Config) error {
+	if len(config.Containers.Units) == 0 {
+		return nil
+	}
+
+	s.Logger.PushPrefix("writeContainerUnits")
+	defer s.Logger.PopPrefix()
+
+	for _, unit := range config.Containers.Units {
+		path := unit.Path()
+		if err := s.Logger.LogOp(
+			func() error { return s.writeFile(path, []byte(renderQuadlet(unit)), 0644) },
+			"writing container unit %q", path,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderQuadlet returns the contents of the quadlet file describing unit.
+func renderQuadlet(unit types.ContainerUnit) string {
+	var b strings.Builder
+	b.WriteString("# Generated by Ignition\n")
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s container\n", escapeSpecifiers(unit.Name))
+	b.WriteString("\n[Container]\n")
+	fmt.Fprintf(&b, "ContainerName=%s\n", escapeSpecifiers(unit.Name))
+	fmt.Fprintf(&b, "Image=%s\n", escapeSpecifiers(unit.Image))
+	for _, env := range unit.Environment {
+		value := ""
+		if env.Value != nil {
+			value = *env.Value
+		}
+		// quote the assignment, since the value may contain spaces
+		quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(env.Name + "=" + value)
+		fmt.Fprintf(&b, "Environment=\"%s\"\n", escapeSpecifiers(quoted))
+	}
+	for _, port := range unit.Ports {
+		fmt.Fprintf(&b, "PublishPort=%s\n", port)
+	}
+	for _, volume := range unit.Volumes {
+		fmt.Fprintf(&b, "Volume=%s\n", escapeSpecifiers(volume))
+	}
+	if unit.Enabled == nil || *unit.Enabled {
+		b.WriteString("\n[Install]\n")
+		b.WriteString("WantedBy=multi-user.target default.target\n")
+	}
+	return b.String()
+}
+
+// escapeSpecifiers keeps systemd from expanding % in value.
+func escapeSpecifiers(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"reflect"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+func TestSkopeoCopyArgs(t *testing.T) {
+	tests := []struct {
+		image    string
+		authFile string
+		out      []string
+	}{
+		{
+			image: "quay.io/example/app:latest",
+			out: []string{
+				"copy",
+				"docker://quay.io/example/app:latest",
+				"containers-storage:[overlay@/sysroot/var/lib/containers/storage+/tmp/run]quay.io/example/app:latest",
+			},
+		},
+		{
+			image:    "registry.example.com:5000/app",
+			authFile: "/tmp/auth.json",
+			out: []string{
+				"copy",
+				"--authfile", "/tmp/auth.json",
+				"docker://registry.example.com:5000/app",
+				"containers-storage:[overlay@/sysroot/var/lib/containers/storage+/tmp/run]registry.example.com:5000/app",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		args := skopeoCopyArgs(test.image, test.authFile, "/sysroot/var/lib/containers/storage", "/tmp/run")
+		if !reflect.DeepEqual(args, test.out) {
+			t.Errorf("#%d: bad args: want %q, got %q", i, test.out, args)
+		}
+	}
+}
+
+func TestRenderQuadlet(t *testing.T) {
+	tests := []struct {
+		in  types.ContainerUnit
+		out string
+	}{
+		{
+			in: types.ContainerUnit{
+				Name:  "web",
+				Image: "quay.io/example/app:latest",
+				Environment: []types.ContainerEnvironment{
+					{Name: "GREETING", Value: cutil.StrToPtr(`say "hi" 100%`)},
+					{Name: "EMPTY"},
+				},
+				Ports:   []string{"8080:80"},
+				Volumes: []string{"/srv/web:/var/www:ro,Z"},
+			},
+			out: "# Generated by Ignition\n" +
+				"[Unit]\n" +
+				"Description=web container\n" +
+				"\n[Container]\n" +
+				"ContainerName=web\n" +
+				"Image=quay.io/example/app:latest\n" +
+				`Environment="GREETING=say \"hi\" 100%%"` + "\n" +
+				`Environment="EMPTY="` + "\n" +
+				"PublishPort=8080:80\n" +
+				"Volume=/srv/web:/var/www:ro,Z\n" +
+				"\n[Install]\n" +
+				"WantedBy=multi-user.target default.target\n",
+		},
+		{
+			in: types.ContainerUnit{
+				Name:    "idle",
+				Image:   "busybox",
+				Enabled: cutil.BoolToPtr(false),
+			},
+			out: "# Generated by Ignition\n" +
+				"[Unit]\n" +
+				"Description=idle container\n" +
+				"\n[Container]\n" +
+				"ContainerName=idle\n" +
+				"Image=busybox\n",
+		},
+	}
+
+	for i, test := range tests {
+		if out := renderQuadlet(test.in); out != test.out {
+			t.Errorf("#%d: bad quadlet:\nwant %q\ngot  %q", i, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"crypto/sha512"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+// dedupKey identifies files which can share an inode: same contents, on the
+// same filesystem, with the same owner and mode.
+type dedupKey struct {
+	digest [sha512.Size]byte
+	dev    uint64
+	uid    uint32
+	gid    uint32
+	mode   uint32
+}
+
+// deduplicate replaces the file at path with a hard link to a previously
+// written file with identical contents and metadata, if there is one.
+// Empty files are left alone, as are files which will be relabeled, since
+// linked files share a label.
+func (s *stage) deduplicate(path string) error {
+	if s.deduplicated == nil || s.relabeled(path) {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || !info.Mode().IsRegular() || info.Size() == 0 {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hasher := sha512.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return err
+	}
+	key := dedupKey{
+		dev:  uint64(st.Dev),
+		uid:  st.Uid,
+		gid:  st.Gid,
+		mode: st.Mode,
+	}
+	copy(key.digest[:], hasher.Sum(nil))
+
+	existing, ok := s.deduplicated[key]
+	if !ok {
+		s.deduplicated[key] = path
+		return nil
+	}
+	existingInfo, err := os.Lstat(existing)
+	if err != nil || !sameMetadata(existingInfo, key) {
+		// the earlier file is gone or was changed; use this one from now on
+		s.deduplicated[key] = path
+		return nil
+	}
+	if os.SameFile(info, existingInfo) || s.relabeled(existing) {
+		return nil
+	}
+
+	// link next to the file and rename over it so the path is never missing
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".ign-dedup")
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(existing, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.Logger.Info("replaced %q with a hard link to identical file %q", path, existing)
+	return nil
+}
+
+// sameMetadata returns whether the file described by info still has the
+// owner and mode recorded in key.
+func sameMetadata(info os.FileInfo, key dedupKey) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && st.Uid == key.uid && st.Gid == key.gid && st.Mode == key.mode
+}
+
+// relabeled returns whether path is to be relabeled, by itself or along
+// with a directory containing it.
+func (s *stage) relabeled(path string) bool {
+	for _, p := range s.toRelabel {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// changedInPlace returns whether f is edited, appended to, merged into, or
+// patched once it exists. Reapplying the config would then change the file
+// in place, and with it every file linked to it.
+func changedInPlace(f types.File) bool {
+	return len(f.Edits) > 0 || len(f.Append) > 0 || f.Contents.Merge != nil ||
+		(f.Contents.PatchFrom != nil && *f.Contents.PatchFrom == f.Path)
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestDeduplicate(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-dedup-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	files := []struct {
+		name     string
+		contents string
+		mode     os.FileMode
+	}{
+		{"a", "script", 0755},
+		{"b", "script", 0755},
+		{"c", "other", 0755},
+		{"d", "script", 0644},
+		{"e", "", 0644},
+		{"f", "", 0644},
+	}
+	for _, f := range files {
+		path := filepath.Join(td, f.name)
+		if err := ioutil.WriteFile(path, []byte(f.contents), f.mode); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		if err := os.Chmod(path, f.mode); err != nil {
+			t.Fatalf("chmod error: %v", err)
+		}
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	s := stage{
+		Util:         util.Util{DestDir: td, Logger: &logger},
+		deduplicated: map[dedupKey]string{},
+	}
+	for _, f := range files {
+		if err := s.deduplicate(filepath.Join(td, f.name)); err != nil {
+			t.Fatalf("failed to deduplicate %s: %v", f.name, err)
+		}
+	}
+
+	same := func(a, b string) bool {
+		ai, err := os.Stat(filepath.Join(td, a))
+		if err != nil {
+			t.Fatalf("stat error: %v", err)
+		}
+		bi, err := os.Stat(filepath.Join(td, b))
+		if err != nil {
+			t.Fatalf("stat error: %v", err)
+		}
+		return os.SameFile(ai, bi)
+	}
+	if !same("a", "b") {
+		t.Errorf("identical files weren't linked")
+	}
+	if same("a", "c") {
+		t.Errorf("different files were linked")
+	}
+	if same("a", "d") {
+		t.Errorf("files with different modes were linked")
+	}
+	if same("e", "f") {
+		t.Errorf("empty files were linked")
+	}
+	if contents, err := ioutil.ReadFile(filepath.Join(td, "b")); err != nil || string(contents) != "script" {
+		t.Errorf("bad contents after linking: %q, %v", string(contents), err)
+	}
+}
+
+func TestDeduplicateChangedInPlace(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-dedup-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	logger := log.New(true)
+	defer logger.Close()
+	s := stage{
+		Util: util.Util{
+			DestDir: td,
+			Logger:  &logger,
+			Fetcher: resource.Fetcher{Logger: &logger},
+		},
+		ctx:          context.Background(),
+		deduplicated: map[dedupKey]string{},
+	}
+
+	file := func(name, source string) types.File {
+		f := types.File{
+			Node: types.Node{Path: filepath.Join(td, name)},
+		}
+		f.Contents.Source = cutil.StrToPtr(source)
+		return f
+	}
+	// b ends up with the same contents as a, but is appended to, so a
+	// reapplied config would append to a too if they were linked
+	a := file("a", "data:,script")
+	b := file("b", "data:,scri")
+	b.Append = []types.FileContents{{Source: cutil.StrToPtr("data:,pt")}}
+	if err := s.createEntries([]filesystemEntry{fileEntry(a), fileEntry(b)}); err != nil {
+		t.Fatalf("create error: %v", err)
+	}
+	// c would be linked to a, but is relabeled, and linked files share
+	// a label
+	c := file("c", "data:,script")
+	s.toRelabel = []string{}
+	if err := s.createEntries([]filesystemEntry{fileEntry(c)}); err != nil {
+		t.Fatalf("create error: %v", err)
+	}
+	for _, name := range []string{"b", "c"} {
+		ai, err := os.Stat(a.Path)
+		if err != nil {
+			t.Fatalf("stat error: %v", err)
+		}
+		info, err := os.Stat(filepath.Join(td, name))
+		if err != nil {
+			t.Fatalf("stat error: %v", err)
+		}
+		if os.SameFile(ai, info) {
+			t.Errorf("%s was linked to a", name)
+		}
+	}
+
+	f, err := os.OpenFile(b.Path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	if _, err := f.Write([]byte("\n")); err != nil {
+		t.Fatalf("append error: %v", err)
+	}
+	f.Close()
+	if contents, err := ioutil.ReadFile(a.Path); err != nil || string(contents) != "script" {
+		t.Errorf("appending to b changed a: %q, %v", string(contents), err)
+	}
+}
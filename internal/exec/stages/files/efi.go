@@ -0,0 +1,248 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+)
+
+// populateEfiSystemPartition extracts the archive described in
+// config.Storage.EfiSystemPartition into the mounted ESP and registers its
+// boot entries with the firmware.
+func (s *stage) populateEfiSystemPartition(config types.Config) error {
+	esp := config.Storage.EfiSystemPartition
+	if esp.Path == nil {
+		return nil
+	}
+
+	s.Logger.PushPrefix("populateEfiSystemPartition")
+	defer s.Logger.PopPrefix()
+
+	root, err := s.JoinPath(*esp.Path)
+	if err != nil {
+		return err
+	}
+
+	if esp.Archive.Source != nil {
+		if err := s.extractEfiArchive(root, esp.Archive); err != nil {
+			return fmt.Errorf("failed to populate %q: %v", *esp.Path, err)
+		}
+	}
+
+	if len(esp.BootEntries) == 0 {
+		return nil
+	}
+	if distro.BlackboxTesting() {
+		s.Logger.Info("skipping registration of EFI boot entries during blackbox testing")
+		return nil
+	}
+
+	var device string
+	for _, fs := range config.Storage.Filesystems {
+		if fs.Path != nil && *fs.Path == *esp.Path {
+			device = fs.Device
+		}
+	}
+	// the config has been validated, so the filesystem must be defined
+	disk, number, err := partitionOf(device)
+	if err != nil {
+		return fmt.Errorf("failed to find partition of %q: %v", device, err)
+	}
+
+	existing, err := efiBootEntryLabels()
+	if err != nil {
+		return fmt.Errorf("failed to list EFI boot entries: %v", err)
+	}
+	for _, entry := range esp.BootEntries {
+		if existing[entry.Label] {
+			s.Logger.Info("EFI boot entry %q already exists, skipping", entry.Label)
+			continue
+		}
+		loader := strings.Replace(entry.Loader, "/", `\`, -1)
+		if _, err := s.Logger.LogCmd(
+			exec.CommandContext(s.ctx, distro.EfibootmgrCmd(), "--create", "--disk", disk, "--part", strconv.Itoa(number),
+				"--label", entry.Label, "--loader", loader),
+			"registering EFI boot entry %q", entry.Label,
+		); err != nil {
+			return fmt.Errorf("efibootmgr failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// extractEfiArchive fetches and verifies the tar archive described by
+// archive, then extracts it into root.
+func (s *stage) extractEfiArchive(root string, archive types.EfiArchive) error {
+	tmpDir, err := ioutil.TempDir("", "ignition-esp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// fetch through the usual file machinery so the archive is verified
+	// before anything is extracted
+	f := types.File{
+		Node: types.Node{Path: filepath.Join(tmpDir, "archive.tar")},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.FileContents{
+				Compression:  archive.Compression,
+				Source:       archive.Source,
+				Verification: archive.Verification,
+			},
+		},
+	}
+	fetchOps, err := s.PrepareFetches(s.Logger, f)
+	if err != nil {
+		return err
+	}
+	for _, op := range fetchOps {
+		if err := s.Logger.LogOp(
+			func() error { return s.PerformFetch(s.ctx, op) },
+			"fetching EFI system partition archive %q", *archive.Source,
+		); err != nil {
+			return err
+		}
+	}
+
+	return s.Logger.LogOp(
+		func() error { return extractTar(f.Path, root) },
+		"extracting EFI system partition archive into %q", root,
+	)
+}
+
+// extractTar extracts the regular files and directories of the tar archive at
+// path into root. The ESP is FAT, so other entry types are rejected.
+func extractTar(path, root string) error {
+	archive, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// rooting the name before cleaning it keeps ".." from escaping root
+		target := filepath.Join(root, filepath.Clean("/"+hdr.Name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, util.DefaultDirectoryPermissions); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := util.MkdirForFile(target); err != nil {
+				return err
+			}
+			if err := writeTarEntry(tr, target); err != nil {
+				return err
+			}
+			// keep the archive's times rather than the extraction time
+			if err := util.SetTimestamps(target, nil, &hdr.ModTime); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported entry type for %q in archive", hdr.Name)
+		}
+	}
+}
+
+func writeTarEntry(tr *tar.Reader, target string) error {
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, util.DefaultFilePermissions)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, tr); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// partitionOf returns the disk containing the partition dev and its
+// partition number, as efibootmgr expects them.
+func partitionOf(dev string) (string, int, error) {
+	dev, err := filepath.EvalSymlinks(dev)
+	if err != nil {
+		return "", 0, err
+	}
+	sysfs, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", filepath.Base(dev)))
+	if err != nil {
+		return "", 0, err
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(sysfs, "partition"))
+	if os.IsNotExist(err) {
+		return "", 0, fmt.Errorf("%q is not a partition", dev)
+	} else if err != nil {
+		return "", 0, err
+	}
+	number, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return "", 0, err
+	}
+	// the partition's sysfs directory lives under the disk's
+	return filepath.Join("/dev", filepath.Base(filepath.Dir(sysfs))), number, nil
+}
+
+// efiBootEntryLabels returns the set of labels of the existing EFI boot
+// entries, so that running Ignition again doesn't register duplicates.
+func efiBootEntryLabels() (map[string]bool, error) {
+	out, err := exec.Command(distro.EfibootmgrCmd()).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseEfiBootEntryLabels(out), nil
+}
+
+// parseEfiBootEntryLabels parses efibootmgr output lines of the form
+// "Boot0001* Fedora" or "Boot0001* Fedora\tHD(...)".
+func parseEfiBootEntryLabels(out []byte) map[string]bool {
+	labels := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < len("Boot0000") || !strings.HasPrefix(line, "Boot") {
+			continue
+		}
+		if _, err := strconv.ParseUint(line[4:8], 16, 16); err != nil {
+			// BootCurrent, BootOrder, etc.
+			continue
+		}
+		rest := strings.TrimPrefix(line[8:], "*")
+		label := strings.SplitN(rest, "\t", 2)[0]
+		labels[strings.TrimSpace(label)] = true
+	}
+	return labels
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractTar(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-efi-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	archive := filepath.Join(td, "esp.tar")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatalf("create error: %v", err)
+	}
+	tw := tar.NewWriter(f)
+	mtime := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	entries := []struct {
+		hdr      tar.Header
+		contents string
+	}{
+		{tar.Header{Name: "EFI/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		{tar.Header{Name: "EFI/BOOT/BOOTX64.EFI", Typeflag: tar.TypeReg, Mode: 0644, Size: 4, ModTime: mtime}, "shim"},
+		{tar.Header{Name: "../../escape", Typeflag: tar.TypeReg, Mode: 0644, Size: 4}, "grub"},
+	}
+	for _, e := range entries {
+		if err := tw.WriteHeader(&e.hdr); err != nil {
+			t.Fatalf("tar header error: %v", err)
+		}
+		if _, err := tw.Write([]byte(e.contents)); err != nil {
+			t.Fatalf("tar write error: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close error: %v", err)
+	}
+	f.Close()
+
+	root := filepath.Join(td, "esp")
+	if err := extractTar(archive, root); err != nil {
+		t.Fatalf("extract error: %v", err)
+	}
+	for path, contents := range map[string]string{
+		"EFI/BOOT/BOOTX64.EFI": "shim",
+		"escape":               "grub",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Errorf("read error: %v", err)
+		} else if string(data) != contents {
+			t.Errorf("%s: expected %q, got %q", path, contents, data)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(root, "EFI/BOOT/BOOTX64.EFI")); err != nil {
+		t.Errorf("stat error: %v", err)
+	} else if !info.ModTime().Equal(mtime) {
+		t.Errorf("bad mtime: expected %v, got %v", mtime, info.ModTime())
+	}
+}
+
+func TestParseEfiBootEntryLabels(t *testing.T) {
+	out := []byte(`BootCurrent: 0001
+Timeout: 0 seconds
+BootOrder: 0001,0000
+Boot0000* UEFI Misc Device	PciRoot(0x0)/Pci(0x3,0x0)
+Boot0001* Fedora
+Boot0002  Disabled Entry
+`)
+	expected := map[string]bool{
+		"UEFI Misc Device": true,
+		"Fedora":           true,
+		"Disabled Entry":   true,
+	}
+	if labels := parseEfiBootEntryLabels(out); !reflect.DeepEqual(expected, labels) {
+		t.Errorf("expected %v, got %v", expected, labels)
+	}
+}
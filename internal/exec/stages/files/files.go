@@ -15,16 +15,22 @@
 package files
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/checkpoint"
 	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec/stages"
 	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/failure"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/resource"
+	"github.com/coreos/ignition/v2/internal/rollback"
 )
 
 const (
@@ -57,34 +63,163 @@ func (creator) Name() string {
 
 type stage struct {
 	util.Util
+	// ctx is cancelled when the run should be aborted, which interrupts
+	// fetches and the commands the stage runs
+	ctx       context.Context
 	toRelabel []string
+	// snapshot records paths before they're modified, if rollback is
+	// enabled
+	snapshot *rollback.Snapshot
+	// deduplicated maps the contents and metadata of written files to the
+	// first path holding them, if deduplication is enabled
+	deduplicated map[dedupKey]string
+	// checkpoint records the completed operations, if checkpointing is
+	// enabled
+	checkpoint *checkpoint.Checkpoint
+	// reconcile is set when the config is being reapplied in reconcile
+	// mode, which is the only time managed paths are pruned
+	reconcile bool
 }
 
 func (stage) Name() string {
 	return name
 }
 
-func (s stage) Run(config types.Config) error {
+func (s *stage) SetReconcile(reconcile bool) {
+	s.reconcile = reconcile
+}
+
+func (s stage) Run(ctx context.Context, config types.Config) error {
+	s.ctx = ctx
+
 	if err := s.checkRelabeling(); err != nil {
 		return fmt.Errorf("failed to check if SELinux labeling required: %v", err)
 	}
 
+	atomic := config.Storage.AtomicFiles != nil && *config.Storage.AtomicFiles
+	keepSnapshot := config.Storage.Rollback != nil && *config.Storage.Rollback
+	snapshotDir := distro.RollbackDir()
+	if atomic && !keepSnapshot {
+		// the snapshot is only needed until the stage finishes, and
+		// mustn't replace one kept for `ignition rollback`
+		dir, err := ioutil.TempDir("", "ignition-atomic-")
+		if err != nil {
+			return fmt.Errorf("failed to create rollback snapshot: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		snapshotDir = dir
+	}
+	if atomic || keepSnapshot {
+		snapshot, err := rollback.New(snapshotDir, s.DestDir)
+		if err != nil {
+			return fmt.Errorf("failed to create rollback snapshot: %v", err)
+		}
+		s.snapshot = snapshot
+	}
+
+	if err := s.openCheckpoint(config); err != nil {
+		return fmt.Errorf("failed to open checkpoint: %v", err)
+	}
+
+	if config.Storage.DeduplicateFiles != nil && *config.Storage.DeduplicateFiles {
+		s.deduplicated = map[dedupKey]string{}
+	}
+
+	if atomic {
+		cleanup, err := s.stageFiles(config)
+		if err != nil {
+			return failure.Errorf("failed to stage files: %v", err)
+		}
+		defer cleanup()
+	}
+
+	if err := s.apply(config); err != nil {
+		if atomic {
+			s.undo(snapshotDir)
+		}
+		return err
+	}
+	return nil
+}
+
+// apply makes the changes described by config to the target root.
+func (s *stage) apply(config types.Config) error {
+	if err := s.populateEfiSystemPartition(config); err != nil {
+		return fmt.Errorf("failed to populate EFI system partition: %v", err)
+	}
+
 	if err := s.createPasswd(config); err != nil {
 		return fmt.Errorf("failed to create users/groups: %v", err)
 	}
 
 	if err := s.createFilesystemsEntries(config); err != nil {
-		return fmt.Errorf("failed to create files: %v", err)
+		return failure.Errorf("failed to create files: %v", err)
 	}
 
 	if err := s.createUnits(config); err != nil {
-		return fmt.Errorf("failed to create units: %v", err)
+		return failure.Errorf("failed to create units: %v", err)
+	}
+
+	if err := s.writeContainerUnits(config); err != nil {
+		return fmt.Errorf("failed to write container units: %v", err)
+	}
+
+	if err := s.writeOSUpdate(config); err != nil {
+		return fmt.Errorf("failed to write OS update unit: %v", err)
+	}
+
+	if err := s.writeMachineID(config); err != nil {
+		return fmt.Errorf("failed to write machine ID: %v", err)
+	}
+
+	if err := s.configureTime(config); err != nil {
+		return fmt.Errorf("failed to configure time synchronization: %v", err)
+	}
+
+	if err := s.enableFips(config); err != nil {
+		return fmt.Errorf("failed to enable FIPS mode: %v", err)
+	}
+
+	if err := s.installTrustedCertificates(config); err != nil {
+		return failure.Errorf("failed to install trusted certificates: %v", err)
+	}
+
+	if err := s.installSSHHostKeys(config); err != nil {
+		return failure.Errorf("failed to install SSH host keys: %v", err)
+	}
+
+	if err := s.enrollHostCertificates(config); err != nil {
+		return fmt.Errorf("failed to enroll host certificates: %v", err)
+	}
+
+	if err := s.installSelinuxModules(config); err != nil {
+		return failure.Errorf("failed to install SELinux modules: %v", err)
+	}
+
+	if err := s.pullContainerImages(config); err != nil {
+		return failure.Errorf("failed to pull container images: %v", err)
+	}
+
+	if err := s.writeNextBootConfig(config); err != nil {
+		return fmt.Errorf("failed to write next boot's config: %v", err)
+	}
+
+	if err := s.writeManifest(config); err != nil {
+		return fmt.Errorf("failed to write manifest of managed paths: %v", err)
 	}
 
 	if err := s.relabelFiles(); err != nil {
 		return fmt.Errorf("failed to handle relabeling: %v", err)
 	}
 
+	if err := s.auditFiles(config); err != nil {
+		return fmt.Errorf("failed to audit files: %v", err)
+	}
+
+	if err := s.removeCheckpoint(); err != nil {
+		return fmt.Errorf("failed to remove checkpoint: %v", err)
+	}
+
 	return nil
 }
 
@@ -116,6 +251,52 @@ func (s *stage) relabel(paths ...string) {
 	}
 }
 
+// snapshotPaths records paths in the target root in the rollback snapshot,
+// if there is one, before they're modified.
+func (s *stage) snapshotPaths(paths ...string) error {
+	if s.snapshot == nil {
+		return nil
+	}
+	for _, path := range paths {
+		fullPath, err := s.JoinPath(path)
+		if err != nil {
+			return err
+		}
+		if err := s.snapshot.Save(fullPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes contents to path in the target root with mode, which
+// is set before anything is written.
+func (s *stage) writeFile(path string, contents []byte, mode os.FileMode) error {
+	fullPath, err := s.JoinPath(path)
+	if err != nil {
+		return err
+	}
+	if s.snapshot != nil {
+		if err := s.snapshot.Save(fullPath); err != nil {
+			return err
+		}
+	}
+	f, err := s.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// an existing file keeps its mode, so change it before writing
+	if err := f.Chmod(mode); err != nil {
+		return err
+	}
+	if _, err := f.Write(contents); err != nil {
+		return err
+	}
+	s.relabel(path)
+	return f.Close()
+}
+
 // relabelFiles relabels all the files that were marked for relabeling using
 // the libselinux APIs.
 func (s *stage) relabelFiles() error {
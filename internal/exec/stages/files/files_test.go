@@ -15,12 +15,20 @@
 package files
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
 
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/exec/util"
 	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+	"github.com/coreos/ignition/v2/internal/rollback"
 )
 
 type pathWrapper string
@@ -29,7 +37,7 @@ func (pw pathWrapper) getPath() string {
 	return string(pw)
 }
 
-func (pw pathWrapper) create(l *log.Logger, u util.Util) error {
+func (pw pathWrapper) create(_ context.Context, l *log.Logger, u util.Util) error {
 	return nil
 }
 
@@ -75,3 +83,179 @@ func TestEntrySort(t *testing.T) {
 		}
 	}
 }
+
+func TestHardLinkOrdering(t *testing.T) {
+	type in struct {
+		files    []string
+		links    map[string]string
+		existing []string
+	}
+	type out struct {
+		paths []string
+		err   bool
+	}
+
+	tests := []struct {
+		in  in
+		out out
+	}{
+		{
+			// target is deeper than the link
+			in: in{
+				files: []string{"/a/b/c/target"},
+				links: map[string]string{"/link": "/a/b/c/target"},
+			},
+			out: out{paths: []string{"/a/b/c/target", "/link"}},
+		},
+		{
+			// chain of hard links
+			in: in{
+				files: []string{"/a/b/target"},
+				links: map[string]string{"/link1": "/link2/x", "/link2/x": "/a/b/target"},
+			},
+			out: out{paths: []string{"/a/b/target", "/link2/x", "/link1"}},
+		},
+		{
+			// target exists, but isn't in the config
+			in: in{
+				links:    map[string]string{"/link": "/existing"},
+				existing: []string{"/existing"},
+			},
+			out: out{paths: []string{"/link"}},
+		},
+		{
+			// dangling hard link
+			in: in{
+				links: map[string]string{"/link": "/missing"},
+			},
+			out: out{err: true},
+		},
+		{
+			// cycle
+			in: in{
+				links: map[string]string{"/link1": "/link2", "/link2": "/link1"},
+			},
+			out: out{err: true},
+		},
+	}
+
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ignition-files-test")
+		if err != nil {
+			t.Fatalf("#%d: failed to create tempdir: %v", i, err)
+		}
+		defer os.RemoveAll(root)
+		for _, path := range test.in.existing {
+			if err := ioutil.WriteFile(filepath.Join(root, path), nil, 0644); err != nil {
+				t.Fatalf("#%d: failed to create %s: %v", i, path, err)
+			}
+		}
+
+		config := types.Config{}
+		for _, path := range test.in.files {
+			config.Storage.Files = append(config.Storage.Files, types.File{Node: types.Node{Path: path}})
+		}
+		linkPaths := []string{}
+		for path := range test.in.links {
+			linkPaths = append(linkPaths, path)
+		}
+		sort.Strings(linkPaths)
+		for _, path := range linkPaths {
+			config.Storage.Links = append(config.Storage.Links, types.Link{
+				Node: types.Node{Path: path},
+				LinkEmbedded1: types.LinkEmbedded1{
+					Target: test.in.links[path],
+					Hard:   cutil.BoolToPtr(true),
+				},
+			})
+		}
+
+		s := stage{Util: util.Util{DestDir: root}}
+		entries, err := s.getOrderedCreationList(config)
+		if test.out.err {
+			if err == nil {
+				t.Errorf("#%d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		paths := make([]string, len(entries))
+		for j, e := range entries {
+			paths[j] = e.node().Path[len(root):]
+		}
+		if !reflect.DeepEqual(test.out.paths, paths) {
+			t.Errorf("#%d: bad order: want %v, got %v", i, test.out.paths, paths)
+		}
+	}
+}
+
+func TestRollbackUnits(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-files-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	root := filepath.Join(td, "root")
+	snapshotDir := filepath.Join(td, "snapshot")
+
+	units := filepath.Join(root, util.SystemdUnitsPath())
+	if err := os.MkdirAll(units, 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(units, "old.service"), []byte("old"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	snapshot, err := rollback.New(snapshotDir, root)
+	if err != nil {
+		t.Fatalf("snapshot error: %v", err)
+	}
+	s := stage{
+		Util: util.Util{
+			DestDir: root,
+			Logger:  &logger,
+			Fetcher: resource.Fetcher{Logger: &logger},
+		},
+		ctx:      context.Background(),
+		snapshot: snapshot,
+	}
+	config := types.Config{
+		Systemd: types.Systemd{
+			Units: []types.Unit{
+				{Name: "old.service", Contents: cutil.StrToPtr("new")},
+				{
+					Name:     "new.service",
+					Contents: cutil.StrToPtr("new"),
+					Enabled:  cutil.BoolToPtr(true),
+					Dropins:  []types.Dropin{{Name: "10-new.conf", Contents: cutil.StrToPtr("new")}},
+				},
+				{Name: "masked.service", Mask: cutil.BoolToPtr(true)},
+			},
+		},
+	}
+	if err := s.createUnits(config); err != nil {
+		t.Fatalf("createUnits error: %v", err)
+	}
+	if err := rollback.Restore(&logger, snapshotDir, root); err != nil {
+		t.Fatalf("restore error: %v", err)
+	}
+
+	if contents, err := ioutil.ReadFile(filepath.Join(units, "old.service")); err != nil || string(contents) != "old" {
+		t.Errorf("old.service wasn't restored: %q, %v", string(contents), err)
+	}
+	for _, path := range []string{
+		filepath.Join(units, "new.service"),
+		filepath.Join(units, "new.service.d"),
+		filepath.Join(units, "masked.service"),
+		filepath.Join(root, util.PresetPath),
+	} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s wasn't removed: %v", path, err)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package files
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -22,7 +23,9 @@ import (
 	"strings"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/failure"
 	"github.com/coreos/ignition/v2/internal/log"
 )
 
@@ -37,7 +40,7 @@ func (s *stage) createFilesystemsEntries(config types.Config) error {
 	}
 
 	if err := s.createEntries(entries); err != nil {
-		return fmt.Errorf("failed to create files: %v", err)
+		return failure.Errorf("failed to create files: %v", err)
 	}
 
 	return nil
@@ -47,7 +50,7 @@ func (s *stage) createFilesystemsEntries(config types.Config) error {
 type filesystemEntry interface {
 	// create creates the entry if specified. It assumes that if overwrite=true then any existing
 	// files at the path will have been deleted.
-	create(l *log.Logger, u util.Util) error
+	create(ctx context.Context, l *log.Logger, u util.Util) error
 	node() types.Node
 }
 
@@ -57,7 +60,7 @@ func (tmp fileEntry) node() types.Node {
 	return types.File(tmp).Node
 }
 
-func (tmp fileEntry) create(l *log.Logger, u util.Util) error {
+func (tmp fileEntry) create(ctx context.Context, l *log.Logger, u util.Util) error {
 	f := types.File(tmp)
 
 	empty := "" // golang--
@@ -75,8 +78,11 @@ func (tmp fileEntry) create(l *log.Logger, u util.Util) error {
 	// Cases where there is file there
 	case !regular:
 		return fmt.Errorf("error creating file %q: A non regular file exists there already and overwrite is false", f.Path)
-	case f.Contents.Source != nil:
+	case f.Contents.Source != nil && f.Contents.Merge == nil && (f.Contents.PatchFrom == nil || *f.Contents.PatchFrom != f.Path):
 		return fmt.Errorf("error creating file %q: A file exists there already and overwrite is false", f.Path)
+	case f.Contents.Source != nil:
+		// merge into or patch the existing file
+		break
 	case regular && f.Contents.Source == nil:
 		break
 	default:
@@ -95,15 +101,40 @@ func (tmp fileEntry) create(l *log.Logger, u util.Util) error {
 		}
 		if err := l.LogOp(
 			func() error {
-				return u.PerformFetch(op)
+				return u.PerformFetch(ctx, op)
 			}, msg, f.Path,
 		); err != nil {
-			return fmt.Errorf("failed to create file %q: %v", op.Node.Path, err)
+			return failure.Errorf("failed to create file %q: %v", op.Node.Path, err)
 		}
 	}
-	if err := u.SetPermissions(f.Mode, f.Node); err != nil {
+	if len(f.Edits) > 0 {
+		if err := l.LogOp(
+			func() error {
+				return u.EditFile(f)
+			}, "editing file %q", f.Path,
+		); err != nil {
+			return fmt.Errorf("failed to edit file %q: %v", f.Path, err)
+		}
+	}
+	mode, err := f.ResolvedMode()
+	if err != nil {
+		return fmt.Errorf("invalid mode for %s: %v", f.Path, err)
+	}
+	if err := u.SetPermissions(mode, f.Node); err != nil {
 		return fmt.Errorf("error setting file permissions for %s: %v", f.Path, err)
 	}
+	// last, so that appends and edits don't bump the times again
+	atime, mtime := f.Timestamps()
+	if err := util.SetTimestamps(f.Path, atime, mtime); err != nil {
+		return err
+	}
+	if f.PostCommand.IsSet() {
+		if distro.BlackboxTesting() {
+			l.Info("skipping post command of %q during blackbox testing", f.Path)
+		} else if err := u.RunPostCommand(ctx, f.Path, f.PostCommand); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -113,13 +144,13 @@ func (tmp dirEntry) node() types.Node {
 	return types.Directory(tmp).Node
 }
 
-func (tmp dirEntry) create(l *log.Logger, u util.Util) error {
+func (tmp dirEntry) create(ctx context.Context, l *log.Logger, u util.Util) error {
 	d := types.Directory(tmp)
 	st, err := os.Lstat(d.Path)
 	switch {
 	case os.IsNotExist(err):
 		// use default perms, we'll fix it later
-		if err := os.MkdirAll(d.Path, util.DefaultDirectoryPermissions); err != nil {
+		if err := u.MkdirAll(d.Path); err != nil {
 			return fmt.Errorf("Failed to create directory %s: %v", d.Path, err)
 		}
 	case err != nil:
@@ -128,7 +159,11 @@ func (tmp dirEntry) create(l *log.Logger, u util.Util) error {
 		return fmt.Errorf("error creating directory %s: A non-directory already exists and overwrite is false", d.Path)
 	}
 
-	if err := u.SetPermissions(d.Mode, d.Node); err != nil {
+	mode, err := d.ResolvedMode()
+	if err != nil {
+		return fmt.Errorf("invalid mode for %s: %v", d.Path, err)
+	}
+	if err := u.SetPermissions(mode, d.Node); err != nil {
 		return fmt.Errorf("error setting directory permissions for %s: %v", d.Path, err)
 	}
 	return nil
@@ -140,7 +175,7 @@ func (tmp linkEntry) node() types.Node {
 	return types.Link(tmp).Node
 }
 
-func (tmp linkEntry) create(l *log.Logger, u util.Util) error {
+func (tmp linkEntry) create(ctx context.Context, l *log.Logger, u util.Util) error {
 	s := types.Link(tmp)
 	hard := s.Hard != nil && *s.Hard
 	st, err := os.Lstat(s.Path)
@@ -167,10 +202,14 @@ func (tmp linkEntry) create(l *log.Logger, u util.Util) error {
 	case !hard:
 		// if the existing file is a symlink, check that its target is correct
 		if st.Mode()&os.ModeSymlink != 0 {
+			wanted, err := u.SymlinkTarget(s)
+			if err != nil {
+				return fmt.Errorf("error resolving target of symlink %s: %v", s.Path, err)
+			}
 			if target, err := os.Readlink(s.Path); err != nil {
 				return fmt.Errorf("error reading link at %s: %v", s.Path, err)
-			} else if filepath.Clean(target) != filepath.Clean(s.Target) {
-				return fmt.Errorf("error creating symlink %s: a symlink exists at that path but points to %s, not %s and overwrite is false", s.Path, target, s.Target)
+			} else if filepath.Clean(target) != filepath.Clean(wanted) {
+				return fmt.Errorf("error creating symlink %s: a symlink exists at that path but points to %s, not %s and overwrite is false", s.Path, target, wanted)
 			} else {
 				l.Info("Symlink %s to %s already exists, doing nothing", s.Path, s.Target)
 				return nil
@@ -192,7 +231,10 @@ func (tmp linkEntry) create(l *log.Logger, u util.Util) error {
 
 // getOrderedCreationList resolves all symlinks in the node paths and sets the path to be
 // prepended by the sysroot. It orders the list from shallowest (e.g. /a) to deepeset
-// (e.g. /a/b/c/d/e).
+// (e.g. /a/b/c/d/e), and entries of the same depth by their resolved paths in byte
+// order, so the order doesn't depend on the order of the config or on the kind of
+// entry. The exception is that hard links are always ordered after their targets if
+// the target is also created by the config.
 func (s stage) getOrderedCreationList(config types.Config) ([]filesystemEntry, error) {
 	entries := []filesystemEntry{}
 	// Map from paths in the config to where they resolve for duplicate checking
@@ -222,6 +264,13 @@ func (s stage) getOrderedCreationList(config types.Config) ([]filesystemEntry, e
 		}
 		paths[path] = f.Path
 		f.Path = path
+		if f.Contents.PatchFrom != nil {
+			patchFrom, err := s.JoinPath(*f.Contents.PatchFrom)
+			if err != nil {
+				return nil, err
+			}
+			f.Contents.PatchFrom = &patchFrom
+		}
 		entries = append(entries, fileEntry(f))
 	}
 
@@ -238,9 +287,156 @@ func (s stage) getOrderedCreationList(config types.Config) ([]filesystemEntry, e
 		l.Path = path
 		entries = append(entries, linkEntry(l))
 	}
-	sort.Slice(entries, func(i, j int) bool { return util.Depth(entries[i].node().Path) < util.Depth(entries[j].node().Path) })
+	// resolved paths are unique, so this is a total order
+	sort.Slice(entries, func(i, j int) bool {
+		pi, pj := entries[i].node().Path, entries[j].node().Path
+		if di, dj := util.Depth(pi), util.Depth(pj); di != dj {
+			return di < dj
+		}
+		return pi < pj
+	})
 
-	return entries, nil
+	return s.orderHardLinks(entries, paths)
+}
+
+// orderHardLinks moves hard links after the entries they target, since a hard link
+// cannot be created before its target exists. paths is the set of (resolved) paths
+// created by the config. Hard links whose targets are neither created by the config
+// nor already present on disk result in an error.
+func (s stage) orderHardLinks(entries []filesystemEntry, paths map[string]string) ([]filesystemEntry, error) {
+	ordered := make([]filesystemEntry, 0, len(entries))
+	created := map[string]struct{}{}
+	// map from resolved target path to the hard links waiting on it
+	waiting := map[string][]filesystemEntry{}
+
+	var emit func(e filesystemEntry)
+	emit = func(e filesystemEntry) {
+		ordered = append(ordered, e)
+		path := e.node().Path
+		created[path] = struct{}{}
+		deps := waiting[path]
+		delete(waiting, path)
+		for _, dep := range deps {
+			emit(dep)
+		}
+	}
+
+	for _, e := range entries {
+		l, ok := e.(linkEntry)
+		if !ok || l.Hard == nil || !*l.Hard {
+			emit(e)
+			continue
+		}
+		target, err := s.JoinPath(l.Target)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving target path of hard link %s: %v", l.Path, err)
+		}
+		if _, managed := paths[target]; !managed {
+			if _, err := os.Lstat(target); os.IsNotExist(err) {
+				return nil, fmt.Errorf("hard link %s points to %s, which does not exist and is not created by the config", paths[l.Path], l.Target)
+			} else if err != nil {
+				return nil, fmt.Errorf("stat() failed on hard link target %s: %v", target, err)
+			}
+			emit(e)
+			continue
+		}
+		if _, ok := created[target]; ok {
+			emit(e)
+			continue
+		}
+		waiting[target] = append(waiting[target], e)
+	}
+
+	// anything left waiting is part of a cycle of hard links
+	for _, e := range entries {
+		if _, ok := created[e.node().Path]; !ok {
+			return nil, fmt.Errorf("hard link %s is part of a cycle of hard links", paths[e.node().Path])
+		}
+	}
+	return ordered, nil
+}
+
+// conflictsWith returns a description of the type of the node described by st
+// if it conflicts with the type of e, or "" if it does not.
+func conflictsWith(e filesystemEntry, st os.FileInfo) string {
+	mode := st.Mode()
+	var conflicts bool
+	switch t := e.(type) {
+	case fileEntry:
+		conflicts = !mode.IsRegular()
+	case dirEntry:
+		conflicts = !mode.IsDir()
+	case linkEntry:
+		if t.Hard != nil && *t.Hard {
+			conflicts = mode.IsDir()
+		} else {
+			conflicts = mode&os.ModeSymlink == 0
+		}
+	}
+	if !conflicts {
+		return ""
+	}
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "directory"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	default:
+		return "non-regular file"
+	}
+}
+
+// resolveTypeConflict applies the entry's typeConflict policy if a node of a
+// different type already exists at its path and overwrite is false. It returns
+// true if the existing node was adopted and the entry should not be created.
+func (s *stage) resolveTypeConflict(e filesystemEntry) (bool, error) {
+	n := e.node()
+	if n.Overwrite != nil && *n.Overwrite {
+		return false, nil
+	}
+	st, err := os.Lstat(n.Path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("stat() failed on %s: %v", n.Path, err)
+	}
+	existing := conflictsWith(e, st)
+	if existing == "" {
+		return false, nil
+	}
+
+	policy := "fail"
+	if n.TypeConflict != nil {
+		policy = *n.TypeConflict
+	}
+	switch policy {
+	case "replace":
+		s.Logger.Info("replacing existing %s at %q", existing, n.Path)
+		return false, os.RemoveAll(n.Path)
+	case "adopt":
+		s.Logger.Info("adopting existing %s at %q", existing, n.Path)
+		return true, nil
+	default:
+		return false, fmt.Errorf("a %s already exists at %s and overwrite is false", existing, n.Path)
+	}
+}
+
+// saveForRollback records the path of e in the rollback snapshot, if there
+// is one. Existing directories which aren't being overwritten are skipped
+// since only their metadata will change.
+func (s *stage) saveForRollback(e filesystemEntry) error {
+	if s.snapshot == nil {
+		return nil
+	}
+	n := e.node()
+	if _, ok := e.(dirEntry); ok && (n.Overwrite == nil || !*n.Overwrite) {
+		if st, err := os.Lstat(n.Path); err == nil && st.IsDir() {
+			return nil
+		}
+	}
+	return s.snapshot.Save(n.Path)
 }
 
 func (s *stage) removePathOnOverwrite(e filesystemEntry) error {
@@ -276,14 +472,42 @@ func (s *stage) createEntries(entries []filesystemEntry) error {
 			panic(fmt.Sprintf("Entry path %s isn't under prefix %s", path, s.DestDir))
 		}
 
+		op, err := s.describeEntry(e)
+		if err != nil {
+			return err
+		}
+		if s.checkpointed(op) {
+			continue
+		}
+
 		if err := s.relabelDirsForFile(path); err != nil {
 			return fmt.Errorf("error relabeling paths for %s: %v", path, err)
 		}
+		if err := s.saveForRollback(e); err != nil {
+			return fmt.Errorf("error saving %s for rollback: %v", path, err)
+		}
 		if err := s.removePathOnOverwrite(e); err != nil {
 			return fmt.Errorf("error removing existing file %s: %v", path, err)
 		}
-		if err := e.create(s.Logger, s.Util); err != nil {
+		if adopted, err := s.resolveTypeConflict(e); err != nil {
 			return fmt.Errorf("error creating %s: %v", path, err)
+		} else if adopted {
+			continue
+		}
+		if err := e.create(s.ctx, s.Logger, s.Util); err != nil {
+			if f, ok := e.(fileEntry); ok && types.File(f).WarnsOnFailure() {
+				s.Logger.Warning("not creating optional file %s: %v", path, err)
+				continue
+			}
+			return failure.Errorf("error creating %s: %v", path, err)
+		}
+		if f, ok := e.(fileEntry); ok && f.Contents.Source != nil && !changedInPlace(types.File(f)) {
+			if err := s.deduplicate(path); err != nil {
+				return fmt.Errorf("error deduplicating %s: %v", path, err)
+			}
+		}
+		if err := s.recordCheckpoint(op); err != nil {
+			return fmt.Errorf("error recording checkpoint: %v", err)
 		}
 	}
 	return nil
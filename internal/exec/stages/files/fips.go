@@ -0,0 +1,90 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+)
+
+const (
+	cryptoPolicyPath   = "/etc/crypto-policies/config"
+	fipsDracutConfPath = "/etc/dracut.conf.d/40-fips.conf"
+)
+
+// enableFips puts the target root into FIPS mode if config.Security.Fips is
+// set: it selects the FIPS crypto policy, adds the fips module to future
+// initramfs images, and adds fips=1 to the kernel command line of the
+// installed kernels. The config validation ensures storage doesn't also
+// write these files.
+func (s *stage) enableFips(config types.Config) error {
+	if config.Security.Fips == nil || !*config.Security.Fips {
+		return nil
+	}
+
+	s.Logger.PushPrefix("enableFips")
+	defer s.Logger.PopPrefix()
+
+	files := []struct {
+		path     string
+		contents string
+	}{
+		{cryptoPolicyPath, "FIPS\n"},
+		{fipsDracutConfPath, "add_dracutmodules+=\" fips \"\n"},
+	}
+	for _, f := range files {
+		path, err := s.JoinPath(f.path)
+		if err != nil {
+			return err
+		}
+		if s.snapshot != nil {
+			if err := s.snapshot.Save(path); err != nil {
+				return err
+			}
+		}
+		if err := util.MkdirForFile(path); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(f.contents), util.DefaultFilePermissions); err != nil {
+			return err
+		}
+		s.relabel(f.path)
+	}
+
+	if distro.BlackboxTesting() {
+		s.Logger.Info("skipping crypto policy and kernel argument updates during blackbox testing")
+		return nil
+	}
+	// apply the policy to each crypto back-end's configuration
+	if _, err := s.Logger.LogCmd(
+		exec.CommandContext(s.ctx, distro.ChrootCmd(), s.DestDir, distro.UpdateCryptoPoliciesCmd(), "--no-reload"),
+		"applying the FIPS crypto policy",
+	); err != nil {
+		return fmt.Errorf("%s failed: %v", distro.UpdateCryptoPoliciesCmd(), err)
+	}
+	s.relabel("/etc/crypto-policies")
+	if _, err := s.Logger.LogCmd(
+		exec.CommandContext(s.ctx, distro.ChrootCmd(), s.DestDir, distro.GrubbyCmd(), "--update-kernel=ALL", "--args=fips=1"),
+		"adding fips=1 to the kernel command line",
+	); err != nil {
+		return fmt.Errorf("%s failed: %v", distro.GrubbyCmd(), err)
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+var (
+	oidChallengePassword       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 7}
+	oidSHA256WithRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+)
+
+// enrollHostCertificates obtains each certificate in
+// config.Security.HostCertificates from its CA, with a key generated for
+// it, and writes the key and certificate chain to the target root.
+func (s *stage) enrollHostCertificates(config types.Config) error {
+	if len(config.Security.HostCertificates) == 0 {
+		return nil
+	}
+
+	s.Logger.PushPrefix("enrollHostCertificates")
+	defer s.Logger.PopPrefix()
+
+	for _, h := range config.Security.HostCertificates {
+		if err := s.enrollHostCertificate(h); err != nil {
+			return fmt.Errorf("certificate %q: %v", h.CertificatePath, err)
+		}
+	}
+	return nil
+}
+
+func (s *stage) enrollHostCertificate(h types.HostCertificate) error {
+	key, keyPEM, err := generateHostKey(h.KeyAlgorithm())
+	if err != nil {
+		return err
+	}
+	challenge := ""
+	if h.SCEP.ChallengePassword != nil {
+		challenge = *h.SCEP.ChallengePassword
+	}
+	csr, err := hostCertificateRequest(h.DNSNames, key, challenge)
+	if err != nil {
+		return err
+	}
+
+	var chain []byte
+	if h.ACME.IsSet() {
+		email := ""
+		if h.ACME.Email != nil {
+			email = *h.ACME.Email
+		}
+		err = s.Logger.LogOp(func() error {
+			chain, err = s.Fetcher.ObtainACMECertificate(s.ctx, *h.ACME.Directory, email, csr)
+			return err
+		}, "obtaining certificate for %s from %q", strings.Join(h.DNSNames, ", "), *h.ACME.Directory)
+	} else {
+		if distro.BlackboxTesting() {
+			s.Logger.Info("skipping SCEP enrollment during blackbox testing")
+			return nil
+		}
+		chain, err = s.enrollSCEP(h.SCEP, keyPEM, csr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkCertificateKey(chain, key); err != nil {
+		return err
+	}
+
+	// the key is written first, so the certificate never exists without it
+	files := []struct {
+		path     string
+		contents []byte
+		mode     os.FileMode
+	}{
+		{h.KeyPath, keyPEM, 0600},
+		{h.CertificatePath, chain, 0644},
+	}
+	for _, f := range files {
+		if err := s.writeFile(f.path, f.contents, f.mode); err != nil {
+			return err
+		}
+		fullPath, err := s.JoinPath(f.path)
+		if err != nil {
+			return err
+		}
+		if err := s.SetPermissions(nil, types.Node{Path: fullPath, User: h.User, Group: h.Group}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enrollSCEP obtains a certificate for the request csr, whose key is
+// keyPEM, from the SCEP server in scep with sscep, after checking the
+// server's CA certificate against the configured fingerprint.
+func (s *stage) enrollSCEP(scep types.HostCertificateSCEP, keyPEM, csr []byte) ([]byte, error) {
+	tmpDir, err := ioutil.TempDir("", "ignition-scep")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	caPath := filepath.Join(tmpDir, "ca.crt")
+	keyPath := filepath.Join(tmpDir, "key.pem")
+	csrPath := filepath.Join(tmpDir, "csr.pem")
+	certPath := filepath.Join(tmpDir, "cert.pem")
+	if err := ioutil.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(csrPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), 0600); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Logger.LogCmd(
+		exec.CommandContext(s.ctx, distro.SscepCmd(), "getca", "-u", *scep.URL, "-c", caPath),
+		"fetching SCEP CA certificate from %q", *scep.URL,
+	); err != nil {
+		return nil, fmt.Errorf("%s getca failed: %v", distro.SscepCmd(), err)
+	}
+	if err := checkCAFingerprint(caPath, *scep.CaFingerprint); err != nil {
+		return nil, err
+	}
+	if _, err := s.Logger.LogCmd(
+		exec.CommandContext(s.ctx, distro.SscepCmd(), "enroll", "-u", *scep.URL, "-c", caPath, "-k", keyPath, "-r", csrPath, "-l", certPath),
+		"enrolling with SCEP server %q", *scep.URL,
+	); err != nil {
+		return nil, fmt.Errorf("%s enroll failed: %v", distro.SscepCmd(), err)
+	}
+	return ioutil.ReadFile(certPath)
+}
+
+// checkCAFingerprint returns an error unless the PEM-encoded certificate at
+// path has the fingerprint, in the form sha256-<hex>.
+func checkCAFingerprint(path, fingerprint string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("SCEP server returned several CA certificates, which isn't supported")
+	} else if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("no PEM-encoded CA certificate found")
+	}
+	digest := sha256.Sum256(block.Bytes)
+	if !strings.EqualFold("sha256-"+hex.EncodeToString(digest[:]), fingerprint) {
+		return fmt.Errorf("SCEP CA certificate has fingerprint sha256-%x, not %s", digest, fingerprint)
+	}
+	return nil
+}
+
+// checkCertificateKey returns an error unless the first certificate in the
+// PEM-encoded chain is for key.
+func checkCertificateKey(chain []byte, key crypto.Signer) error {
+	block, _ := pem.Decode(chain)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("CA returned no PEM-encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return err
+	}
+	want, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+	got, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("CA returned a certificate for a different key")
+	}
+	return nil
+}
+
+// generateHostKey generates a key of keyType and returns it and its PEM
+// encoding.
+func generateHostKey(keyType string) (crypto.Signer, []byte, error) {
+	switch keyType {
+	case "ecdsa":
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	case "rsa":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+// tbsCertificateRequest is the signed part of a certificate request, with
+// the parts Ignition doesn't change left encoded.
+type tbsCertificateRequest struct {
+	Version       int
+	Subject       asn1.RawValue
+	PublicKey     asn1.RawValue
+	RawAttributes []asn1.RawValue `asn1:"tag:0"`
+}
+
+type certificateRequest struct {
+	TBS                tbsCertificateRequest
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+// hostCertificateRequest returns a DER-encoded certificate request for the
+// DNS names, with the first as its common name, signed with key. A non-empty
+// challenge is added as the challengePassword attribute, which Go can't
+// encode, so the request is then re-signed; this requires an RSA key.
+func hostCertificateRequest(names []string, key crypto.Signer, challenge string) ([]byte, error) {
+	template := x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: names[0]},
+		DNSNames: names,
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &template, key)
+	if err != nil || challenge == "" {
+		return der, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("a challenge password requires an RSA key")
+	}
+
+	parsed, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, err
+	}
+	var tbs tbsCertificateRequest
+	if _, err := asn1.Unmarshal(parsed.RawTBSCertificateRequest, &tbs); err != nil {
+		return nil, err
+	}
+	value, err := asn1.MarshalWithParams(challenge, "utf8")
+	if err != nil {
+		return nil, err
+	}
+	attribute, err := asn1.Marshal(struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.RawValue `asn1:"set"`
+	}{oidChallengePassword, []asn1.RawValue{{FullBytes: value}}})
+	if err != nil {
+		return nil, err
+	}
+	tbs.RawAttributes = append(tbs.RawAttributes, asn1.RawValue{FullBytes: attribute})
+	tbsDER, err := asn1.Marshal(tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(tbsDER)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(certificateRequest{
+		TBS:                tbs,
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSAEncryption, Parameters: asn1.NullRawValue},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHostCertificateRequest(t *testing.T) {
+	for _, keyType := range []string{"ecdsa", "rsa"} {
+		key, keyPEM, err := generateHostKey(keyType)
+		if err != nil {
+			t.Fatalf("%s: generateHostKey failed: %v", keyType, err)
+		}
+		if block, _ := pem.Decode(keyPEM); block == nil {
+			t.Fatalf("%s: key isn't PEM-encoded", keyType)
+		}
+
+		challenges := []string{""}
+		if keyType == "rsa" {
+			challenges = append(challenges, "s3cret")
+		}
+		for _, challenge := range challenges {
+			der, err := hostCertificateRequest([]string{"node1.example.com", "node1"}, key, challenge)
+			if err != nil {
+				t.Fatalf("%s: hostCertificateRequest failed: %v", keyType, err)
+			}
+			csr, err := x509.ParseCertificateRequest(der)
+			if err != nil {
+				t.Fatalf("%s: bad request: %v", keyType, err)
+			}
+			if err := csr.CheckSignature(); err != nil {
+				t.Errorf("%s: bad signature: %v", keyType, err)
+			}
+			if csr.Subject.CommonName != "node1.example.com" || !reflect.DeepEqual(csr.DNSNames, []string{"node1.example.com", "node1"}) {
+				t.Errorf("%s: bad names: %q %v", keyType, csr.Subject.CommonName, csr.DNSNames)
+			}
+			if got := challengePassword(t, csr.RawTBSCertificateRequest); got != challenge {
+				t.Errorf("%s: expected challenge password %q, got %q", keyType, challenge, got)
+			}
+		}
+	}
+
+	key, _, err := generateHostKey("ecdsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hostCertificateRequest([]string{"node1"}, key, "s3cret"); err == nil {
+		t.Errorf("challenge password with an ECDSA key succeeded")
+	}
+}
+
+// challengePassword returns the challengePassword attribute of the encoded
+// request info, or "" if there is none.
+func challengePassword(t *testing.T, raw []byte) string {
+	var tbs tbsCertificateRequest
+	if _, err := asn1.Unmarshal(raw, &tbs); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range tbs.RawAttributes {
+		var attribute struct {
+			Type   asn1.ObjectIdentifier
+			Values []string `asn1:"set,utf8"`
+		}
+		if _, err := asn1.Unmarshal(a.FullBytes, &attribute); err != nil {
+			continue
+		}
+		if attribute.Type.Equal(oidChallengePassword) && len(attribute.Values) == 1 {
+			return attribute.Values[0]
+		}
+	}
+	return ""
+}
+
+func TestCheckHostCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ign-hostcert-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _, err := generateHostKey("ecdsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "node1"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	if err := checkCertificateKey(certPEM, key); err != nil {
+		t.Errorf("checkCertificateKey failed: %v", err)
+	}
+	other, _, err := generateHostKey("ecdsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCertificateKey(certPEM, other); err == nil {
+		t.Errorf("checkCertificateKey succeeded with a different key")
+	}
+
+	path := filepath.Join(dir, "ca.crt")
+	if err := ioutil.WriteFile(path, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkCAFingerprint(path, fmt.Sprintf("sha256-%X", sha256.Sum256(der))); err != nil {
+		t.Errorf("checkCAFingerprint failed: %v", err)
+	}
+	if err := checkCAFingerprint(path, "sha256-"+fmt.Sprintf("%064x", 0)); err == nil {
+		t.Errorf("checkCAFingerprint succeeded with a different fingerprint")
+	}
+	if err := checkCAFingerprint(filepath.Join(dir, "missing.crt"), "sha256-"); err == nil {
+		t.Errorf("checkCAFingerprint succeeded without a certificate")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+const machineIDPath = "/etc/machine-id"
+
+// writeMachineID writes /etc/machine-id in the target root according to
+// config.MachineID: the given value for "set", an empty file for
+// "firstboot", so systemd generates an ID and runs its first-boot units,
+// and an ID derived from the SMBIOS system UUID for "platform", so every
+// boot of the same instance gets the same ID. The config validation ensures
+// storage doesn't also write the file.
+func (s *stage) writeMachineID(config types.Config) error {
+	if config.MachineID.Policy == nil {
+		return nil
+	}
+
+	s.Logger.PushPrefix("writeMachineID")
+	defer s.Logger.PopPrefix()
+
+	var id string
+	switch *config.MachineID.Policy {
+	case "set":
+		id = *config.MachineID.Value
+	case "firstboot":
+		id = ""
+	case "platform":
+		uuid, err := ioutil.ReadFile(distro.ProductUUIDPath())
+		if err != nil {
+			return fmt.Errorf("reading system UUID: %v", err)
+		}
+		if id, err = platformMachineID(string(uuid)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown machine ID policy %q", *config.MachineID.Policy)
+	}
+
+	contents := []byte{}
+	if id != "" {
+		contents = []byte(id + "\n")
+	}
+	return s.Logger.LogOp(
+		func() error { return s.writeFile(machineIDPath, contents, 0444) },
+		"writing %q machine ID to %q", *config.MachineID.Policy, machineIDPath,
+	)
+}
+
+// platformMachineID derives a machine ID from the system UUID uuid, hashing
+// it so the ID doesn't reveal the UUID, and formatting the result as a
+// version 4 UUID, as systemd does for the IDs it generates.
+func platformMachineID(uuid string) (string, error) {
+	uuid = strings.ToLower(strings.TrimSpace(uuid))
+	stripped := []byte(strings.ReplaceAll(uuid, "-", ""))
+	if len(stripped) == 0 ||
+		len(bytes.Trim(stripped, "0")) == 0 ||
+		len(bytes.Trim(stripped, "f")) == 0 {
+		// firmware without a real UUID reports one of these placeholders,
+		// which would give every such machine the same ID
+		return "", fmt.Errorf("system UUID %q is not unique to this machine", uuid)
+	}
+	sum := sha256.Sum256([]byte("ignition-machine-id:" + uuid))
+	id := sum[:16]
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestWriteMachineID(t *testing.T) {
+	root, err := ioutil.TempDir("", "ign-machine-id-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	uuidPath := filepath.Join(root, "product_uuid")
+	if err := ioutil.WriteFile(uuidPath, []byte("4C4C4544-0042-3510-8056-B4C04F4E3332\n"), 0444); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("IGNITION_PRODUCT_UUID_PATH", uuidPath)
+	defer os.Unsetenv("IGNITION_PRODUCT_UUID_PATH")
+	platformID, err := platformMachineID("4c4c4544-0042-3510-8056-b4c04f4e3332")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.New(true)
+	s := stage{Util: util.Util{DestDir: root, Logger: &logger}}
+	tests := []struct {
+		in  types.MachineID
+		out string
+	}{
+		{types.MachineID{Policy: cutil.StrToPtr("set"), Value: cutil.StrToPtr("4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d")}, "4a3b2c1d0e9f8a7b6c5d4e3f2a1b0c9d\n"},
+		{types.MachineID{Policy: cutil.StrToPtr("firstboot")}, ""},
+		{types.MachineID{Policy: cutil.StrToPtr("platform")}, platformID + "\n"},
+	}
+	for i, test := range tests {
+		if err := s.writeMachineID(types.Config{MachineID: test.in}); err != nil {
+			t.Errorf("#%d: writeMachineID failed: %v", i, err)
+			continue
+		}
+		path := filepath.Join(root, "etc/machine-id")
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if string(contents) != test.out {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out, contents)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0444 {
+			t.Errorf("#%d: bad mode: %v %v", i, info.Mode(), err)
+		}
+		os.Remove(path)
+	}
+}
+
+func TestPlatformMachineID(t *testing.T) {
+	id, err := platformMachineID("4C4C4544-0042-3510-8056-B4C04F4E3332\n")
+	if err != nil {
+		t.Fatalf("platformMachineID failed: %v", err)
+	}
+	if len(id) != 32 || id[12] != '4' {
+		t.Errorf("bad machine ID %q", id)
+	}
+	if other, _ := platformMachineID("4c4c4544-0042-3510-8056-b4c04f4e3332"); other != id {
+		t.Errorf("machine ID depends on the case of the UUID: %q != %q", other, id)
+	}
+
+	for _, uuid := range []string{"", "00000000-0000-0000-0000-000000000000", "FFFFFFFF-FFFF-FFFF-FFFF-FFFFFFFFFFFF"} {
+		if _, err := platformMachineID(uuid); err == nil {
+			t.Errorf("platformMachineID accepted placeholder UUID %q", uuid)
+		}
+	}
+}
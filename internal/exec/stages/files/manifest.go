@@ -0,0 +1,136 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/manifest"
+)
+
+// writeManifest records the paths written from config in the manifest of
+// managed paths, if config.Storage.Manifest is set. If config.Storage.Prune
+// is also set and the config is being reapplied in reconcile mode, the files
+// and links recorded when a config was applied before, which config no
+// longer has, are removed unless they've been changed since.
+func (s *stage) writeManifest(config types.Config) error {
+	if config.Storage.Manifest == nil || !*config.Storage.Manifest {
+		return nil
+	}
+
+	s.Logger.PushPrefix("writeManifest")
+	defer s.Logger.PopPrefix()
+
+	path, err := s.JoinPath(distro.ManagedPathsPath())
+	if err != nil {
+		return err
+	}
+
+	var entries []manifest.Entry
+	managed := map[string]bool{}
+	for _, p := range managedPaths(config) {
+		// optional files and units may not have been written
+		e, exists, err := manifest.Describe(s.DestDir, p)
+		if err != nil {
+			return err
+		}
+		if exists && !managed[p] {
+			entries = append(entries, e)
+			managed[p] = true
+		}
+	}
+
+	if config.Storage.Prune != nil && *config.Storage.Prune && s.reconcile {
+		previous, err := manifest.Read(path)
+		if err != nil {
+			return err
+		}
+		for _, e := range previous {
+			if !managed[e.Path] && e.Kind != manifest.Directory {
+				if err := s.prune(e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := s.Logger.LogOp(
+		func() error { return manifest.Write(path, entries) },
+		"writing manifest of %d managed paths", len(entries),
+	); err != nil {
+		return err
+	}
+	s.relabel(distro.ManagedPathsPath())
+	return nil
+}
+
+// managedPaths returns the paths in the root which config writes, in the
+// order they're written.
+func managedPaths(config types.Config) []string {
+	var paths []string
+	for _, d := range config.Storage.Directories {
+		paths = append(paths, d.Path)
+	}
+	for _, f := range config.Storage.Files {
+		paths = append(paths, f.Path)
+	}
+	for _, l := range config.Storage.Links {
+		paths = append(paths, l.Path)
+	}
+	for _, u := range config.Systemd.Units {
+		if u.Contents != nil || (u.Mask != nil && *u.Mask) {
+			paths = append(paths, filepath.Join("/", util.SystemdUnitsPath(), u.Name))
+		}
+		for _, d := range u.Dropins {
+			if d.Contents != nil {
+				paths = append(paths, filepath.Join("/", util.SystemdDropinsPath(u.Name), d.Name))
+			}
+		}
+	}
+	return paths
+}
+
+// prune removes the file or link at the path of e, which the config no
+// longer has, unless it has been changed since it was written.
+func (s *stage) prune(e manifest.Entry) error {
+	current, exists, err := manifest.Describe(s.DestDir, e.Path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	if current != e {
+		s.Logger.Warning("not removing %q, which is no longer in the config: it was changed since it was written", e.Path)
+		return nil
+	}
+	path, err := s.JoinPath(e.Path)
+	if err != nil {
+		return err
+	}
+	if s.snapshot != nil {
+		if err := s.snapshot.Save(path); err != nil {
+			return err
+		}
+	}
+	return s.Logger.LogOp(
+		func() error { return os.Remove(path) },
+		"removing %s %q, which is no longer in the config", e.Kind, e.Path,
+	)
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/manifest"
+)
+
+func TestWriteManifestPrune(t *testing.T) {
+	tests := []struct {
+		reconcile bool
+		removed   bool
+	}{
+		{false, false},
+		{true, true},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	config := types.Config{
+		Storage: types.Storage{
+			Manifest: cutil.BoolToPtr(true),
+			Prune:    cutil.BoolToPtr(true),
+		},
+	}
+
+	for i, test := range tests {
+		td, err := ioutil.TempDir("", "ign-manifest-test")
+		if err != nil {
+			t.Fatalf("#%d: tempdir error: %v", i, err)
+		}
+		defer os.RemoveAll(td)
+
+		old := filepath.Join(td, "foo")
+		if err := ioutil.WriteFile(old, []byte("old"), 0644); err != nil {
+			t.Fatalf("#%d: write error: %v", i, err)
+		}
+		e, _, err := manifest.Describe(td, "/foo")
+		if err != nil {
+			t.Fatalf("#%d: describe error: %v", i, err)
+		}
+		path := filepath.Join(td, distro.ManagedPathsPath())
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("#%d: mkdir error: %v", i, err)
+		}
+		if err := manifest.Write(path, []manifest.Entry{e}); err != nil {
+			t.Fatalf("#%d: manifest write error: %v", i, err)
+		}
+
+		s := stage{
+			Util:      util.Util{DestDir: td, Logger: &logger},
+			reconcile: test.reconcile,
+		}
+		if err := s.writeManifest(config); err != nil {
+			t.Fatalf("#%d: writeManifest error: %v", i, err)
+		}
+		_, err = os.Lstat(old)
+		if removed := os.IsNotExist(err); removed != test.removed {
+			t.Errorf("#%d: expected removed %v, got %v (%v)", i, test.removed, removed, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	putil "github.com/coreos/ignition/v2/internal/providers/util"
+)
+
+// writeNextBootConfig fetches the config in config.NextBoot and writes it to
+// the boot filesystem of the target, along with the flag which makes the
+// next boot run Ignition with it. The config is checked as the next boot
+// would check it, including its signature, so a bad config fails this boot
+// instead of the next.
+func (s *stage) writeNextBootConfig(config types.Config) error {
+	if config.NextBoot.Source == nil {
+		return nil
+	}
+
+	s.Logger.PushPrefix("writeNextBootConfig")
+	defer s.Logger.PopPrefix()
+
+	tmpDir, err := ioutil.TempDir("", "ignition-next-boot")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	f := types.File{
+		Node: types.Node{Path: filepath.Join(tmpDir, "config.ign")},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.FileContents{
+				Source:       config.NextBoot.Source,
+				Verification: config.NextBoot.Verification,
+			},
+		},
+	}
+	fetchOps, err := s.PrepareFetches(s.Logger, f)
+	if err != nil {
+		return err
+	}
+	for _, op := range fetchOps {
+		if err := s.Logger.LogOp(
+			func() error { return s.PerformFetch(s.ctx, op) },
+			"fetching next boot's config",
+		); err != nil {
+			return err
+		}
+	}
+	rawConfig, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+	if _, r, err := putil.ParseConfig(&s.Fetcher, rawConfig); err != nil {
+		return fmt.Errorf("invalid config: %v\n%s", err, r)
+	}
+
+	// the config may hold secrets, and the flag is only written once the
+	// config is in place
+	if err := s.writeFile(types.NextBootConfigPath, rawConfig, 0600); err != nil {
+		return err
+	}
+	return s.writeFile(types.FirstbootFlagPath, nil, 0644)
+}
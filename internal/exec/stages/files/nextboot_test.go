@@ -0,0 +1,71 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestWriteNextBootConfig(t *testing.T) {
+	tests := []struct {
+		in    string
+		valid bool
+	}{
+		{`{"ignition": {"version": "3.0.0"}}`, true},
+		{`{"ignition": {"version": "3.0.0"}, "storage": {"files": [{"path": "relative"}]}}`, false},
+		{`{"ignition": {"version": "4.0.0"}}`, false},
+	}
+
+	logger := log.New(true)
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "ign-next-boot-test")
+		if err != nil {
+			t.Fatalf("tempdir error: %v", err)
+		}
+		defer os.RemoveAll(root)
+
+		s := stage{Util: util.Util{DestDir: root, Logger: &logger, Fetcher: resource.Fetcher{Logger: &logger}}, ctx: context.Background()}
+		config := types.Config{
+			NextBoot: types.NextBoot{Source: cutil.StrToPtr("data:," + url.PathEscape(test.in))},
+		}
+		err = s.writeNextBootConfig(config)
+		if test.valid && err != nil {
+			t.Errorf("#%d: writeNextBootConfig failed: %v", i, err)
+			continue
+		} else if !test.valid && err == nil {
+			t.Errorf("#%d: writeNextBootConfig succeeded with an invalid config", i)
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(root, types.NextBootConfigPath))
+		if test.valid && string(contents) != test.in {
+			t.Errorf("#%d: bad config: want %q, got %q (%v)", i, test.in, contents, err)
+		}
+		_, err = os.Stat(filepath.Join(root, types.FirstbootFlagPath))
+		if test.valid != (err == nil) {
+			t.Errorf("#%d: expected flag to exist: %v, got %v", i, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+)
+
+// osUpdateStampPath marks that the OS changes were applied, so the unit
+// doesn't apply them again after the reboot.
+const osUpdateStampPath = "/var/lib/ignition/os-update.done"
+
+// writeOSUpdate writes and enables a unit which, on first boot, switches the
+// system to the image in config.OS and layers its packages, then reboots
+// into the result. The changes can't be made from the initramfs, since the
+// update tools need the booted system.
+func (s *stage) writeOSUpdate(config types.Config) error {
+	if !config.OS.HasChanges() {
+		return nil
+	}
+
+	s.Logger.PushPrefix("writeOSUpdate")
+	defer s.Logger.PopPrefix()
+
+	path := filepath.Join("/", util.SystemdUnitsPath(), types.OSUpdateUnit)
+	if err := s.Logger.LogOp(
+		func() error { return s.writeFile(path, []byte(renderOSUpdateUnit(config.OS)), 0644) },
+		"writing OS update unit %q", path,
+	); err != nil {
+		return err
+	}
+	if err := s.Logger.LogOp(
+		func() error { return s.EnableUnit(types.Unit{Name: types.OSUpdateUnit}) },
+		"enabling unit %q", types.OSUpdateUnit,
+	); err != nil {
+		return err
+	}
+	s.relabel(util.PresetPath)
+	return nil
+}
+
+// renderOSUpdateUnit returns the contents of the unit applying the changes
+// in o.
+func renderOSUpdateUnit(o types.OS) string {
+	var b strings.Builder
+	b.WriteString("# Generated by Ignition\n")
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Apply OS Changes Requested by Ignition\n")
+	b.WriteString("Wants=network-online.target\n")
+	b.WriteString("After=network-online.target\n")
+	fmt.Fprintf(&b, "ConditionPathExists=!%s\n", osUpdateStampPath)
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=oneshot\n")
+	b.WriteString("RemainAfterExit=yes\n")
+	b.WriteString("StateDirectory=ignition\n")
+	if o.Image != nil {
+		fmt.Fprintf(&b, "ExecStart=%s switch %s\n", distro.BootcCmd(), *o.Image)
+	}
+	if len(o.Packages) > 0 {
+		fmt.Fprintf(&b, "ExecStart=%s install --idempotent --allow-inactive %s\n", distro.RpmOstreeCmd(), strings.Join(o.Packages, " "))
+	}
+	// the stamp is written before rebooting, so a failed reboot doesn't
+	// apply the changes twice; a failed update leaves no stamp and is
+	// retried on the next boot
+	fmt.Fprintf(&b, "ExecStart=touch %s\n", osUpdateStampPath)
+	if o.Reboot == nil || *o.Reboot {
+		b.WriteString("ExecStart=systemctl --no-block reboot\n")
+	}
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
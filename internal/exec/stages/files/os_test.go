@@ -0,0 +1,71 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+func TestRenderOSUpdateUnit(t *testing.T) {
+	header := "# Generated by Ignition\n" +
+		"[Unit]\n" +
+		"Description=Apply OS Changes Requested by Ignition\n" +
+		"Wants=network-online.target\n" +
+		"After=network-online.target\n" +
+		"ConditionPathExists=!/var/lib/ignition/os-update.done\n" +
+		"\n[Service]\n" +
+		"Type=oneshot\n" +
+		"RemainAfterExit=yes\n" +
+		"StateDirectory=ignition\n"
+	install := "\n[Install]\n" +
+		"WantedBy=multi-user.target\n"
+
+	tests := []struct {
+		in  types.OS
+		out string
+	}{
+		{
+			in: types.OS{
+				Image:    cutil.StrToPtr("quay.io/fedora/fedora-bootc:40"),
+				Packages: []string{"vim-enhanced", "tmux"},
+			},
+			out: header +
+				"ExecStart=bootc switch quay.io/fedora/fedora-bootc:40\n" +
+				"ExecStart=rpm-ostree install --idempotent --allow-inactive vim-enhanced tmux\n" +
+				"ExecStart=touch /var/lib/ignition/os-update.done\n" +
+				"ExecStart=systemctl --no-block reboot\n" +
+				install,
+		},
+		{
+			in: types.OS{
+				Packages: []string{"tmux"},
+				Reboot:   cutil.BoolToPtr(false),
+			},
+			out: header +
+				"ExecStart=rpm-ostree install --idempotent --allow-inactive tmux\n" +
+				"ExecStart=touch /var/lib/ignition/os-update.done\n" +
+				install,
+		},
+	}
+
+	for i, test := range tests {
+		if out := renderOSUpdateUnit(test.in); out != test.out {
+			t.Errorf("#%d: bad unit:\nwant %q\ngot  %q", i, test.out, out)
+		}
+	}
+}
@@ -39,8 +39,24 @@ func (s *stage) expandGlobList(globs ...string) ([]string, error) {
 	return ret, nil
 }
 
+// passwdFiles are the files the commands creating users and groups modify.
+var passwdFiles = []string{
+	"/etc/passwd",
+	"/etc/group",
+	"/etc/shadow",
+	"/etc/gshadow",
+	"/etc/subuid",
+	"/etc/subgid",
+}
+
 // createPasswd creates the users and groups as described in config.Passwd.
 func (s *stage) createPasswd(config types.Config) error {
+	if len(config.Passwd.Groups) != 0 || len(config.Passwd.Users) != 0 {
+		if err := s.snapshotPaths(passwdFiles...); err != nil {
+			return fmt.Errorf("failed to save users and groups for rollback: %v", err)
+		}
+	}
+
 	if err := s.createGroups(config); err != nil {
 		return fmt.Errorf("failed to create groups: %v", err)
 	}
@@ -86,12 +102,16 @@ func (s stage) createUsers(config types.Config) error {
 	defer s.Logger.PopPrefix()
 
 	for _, u := range config.Passwd.Users {
-		if err := s.EnsureUser(u); err != nil {
+		op := fmt.Sprintf("user %s", u.Name)
+		if s.checkpointed(op) {
+			continue
+		}
+		if err := s.EnsureUser(s.ctx, u); err != nil {
 			return fmt.Errorf("failed to create user %q: %v",
 				u.Name, err)
 		}
 
-		if err := s.SetPasswordHash(u); err != nil {
+		if err := s.SetPasswordHash(s.ctx, u); err != nil {
 			return fmt.Errorf("failed to set password for %q: %v",
 				u.Name, err)
 		}
@@ -100,6 +120,9 @@ func (s stage) createUsers(config types.Config) error {
 			return fmt.Errorf("failed to add keys to user %q: %v",
 				u.Name, err)
 		}
+		if err := s.recordCheckpoint(op); err != nil {
+			return fmt.Errorf("error recording checkpoint: %v", err)
+		}
 	}
 
 	return nil
@@ -114,10 +137,17 @@ func (s stage) createGroups(config types.Config) error {
 	defer s.Logger.PopPrefix()
 
 	for _, g := range config.Passwd.Groups {
-		if err := s.CreateGroup(g); err != nil {
+		op := fmt.Sprintf("group %s", g.Name)
+		if s.checkpointed(op) {
+			continue
+		}
+		if err := s.CreateGroup(s.ctx, g); err != nil {
 			return fmt.Errorf("failed to create group %q: %v",
 				g.Name, err)
 		}
+		if err := s.recordCheckpoint(op); err != nil {
+			return fmt.Errorf("error recording checkpoint: %v", err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,65 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+// Plan returns the files, directories, and links in config.Storage in the
+// order the stage creates them, one per line, with their paths resolved
+// within the root but relative to it, e.g. "link /etc/localtime -> ...".
+func (s stage) Plan(config types.Config) ([]string, error) {
+	entries, err := s.getOrderedCreationList(config)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make([]string, 0, len(entries))
+	for _, e := range entries {
+		op, err := s.describeEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, op)
+	}
+	return plan, nil
+}
+
+// describeEntry describes the creation of e as a line of the plan, which
+// also identifies it in checkpoints.
+func (s stage) describeEntry(e filesystemEntry) (string, error) {
+	rel, err := filepath.Rel(s.DestDir, e.node().Path)
+	if err != nil {
+		return "", err
+	}
+	path := "/" + rel
+	switch e := e.(type) {
+	case dirEntry:
+		return fmt.Sprintf("directory %s", path), nil
+	case fileEntry:
+		return fmt.Sprintf("file %s", path), nil
+	case linkEntry:
+		kind := "link"
+		if e.Hard != nil && *e.Hard {
+			kind = "hardlink"
+		}
+		return fmt.Sprintf("%s %s -> %s", kind, path, e.Target), nil
+	}
+	panic(fmt.Sprintf("unknown entry type %T", e))
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+)
+
+func TestPlan(t *testing.T) {
+	root, err := ioutil.TempDir("", "ignition-plan-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	node := func(path string) types.Node {
+		return types.Node{Path: path}
+	}
+	config := types.Config{
+		Storage: types.Storage{
+			Files: []types.File{
+				{Node: node("/etc/zz")},
+				{Node: node("/etc/app/config")},
+				{Node: node("/etc/aa")},
+			},
+			Directories: []types.Directory{
+				{Node: node("/etc/app")},
+				{Node: node("/etc")},
+			},
+			Links: []types.Link{
+				{Node: node("/etc/hard"), LinkEmbedded1: types.LinkEmbedded1{Target: "/etc/app/config", Hard: cutil.BoolToPtr(true)}},
+				{Node: node("/etc/mm"), LinkEmbedded1: types.LinkEmbedded1{Target: "aa"}},
+			},
+		},
+	}
+	expected := []string{
+		"directory /etc",
+		"file /etc/aa",
+		"directory /etc/app",
+		"link /etc/mm -> aa",
+		"file /etc/zz",
+		"file /etc/app/config",
+		"hardlink /etc/hard -> /etc/app/config",
+	}
+
+	s := stage{Util: util.Util{DestDir: root}}
+	plan, err := s.Plan(config)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Errorf("bad plan:\nwant %q\ngot  %q", expected, plan)
+	}
+
+	// the order of the config doesn't matter
+	files := config.Storage.Files
+	files[0], files[2] = files[2], files[0]
+	dirs := config.Storage.Directories
+	dirs[0], dirs[1] = dirs[1], dirs[0]
+	if plan, err := s.Plan(config); err != nil || !reflect.DeepEqual(plan, expected) {
+		t.Errorf("plan depends on the order of the config: %q, %v", plan, err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/failure"
+)
+
+// installSelinuxModules fetches the policy modules in config.Selinux.Modules
+// and installs them into the policy store of the target root, so the policy
+// the system boots with already includes them.
+func (s *stage) installSelinuxModules(config types.Config) error {
+	if len(config.Selinux.Modules) == 0 {
+		return nil
+	}
+
+	s.Logger.PushPrefix("installSelinuxModules")
+	defer s.Logger.PopPrefix()
+
+	tmpDir, err := ioutil.TempDir("", "ignition-selinux")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	args := []string{"--path", s.DestDir, "--noreload"}
+	for _, module := range config.Selinux.Modules {
+		// fetch through the usual file machinery so each module is
+		// verified before anything is installed
+		f := types.File{
+			Node: types.Node{Path: filepath.Join(tmpDir, module.Name+".pp")},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.FileContents{
+					Compression:  module.Compression,
+					Source:       module.Source,
+					Verification: module.Verification,
+				},
+			},
+		}
+		fetchOps, err := s.PrepareFetches(s.Logger, f)
+		if err != nil {
+			return err
+		}
+		for _, op := range fetchOps {
+			if err := s.Logger.LogOp(
+				func() error { return s.PerformFetch(s.ctx, op) },
+				"fetching SELinux module %q", module.Name,
+			); err != nil {
+				return failure.Errorf("failed to fetch module %q: %v", module.Name, err)
+			}
+		}
+		args = append(args, "--install", f.Path)
+	}
+
+	if distro.BlackboxTesting() {
+		s.Logger.Info("skipping installation of SELinux modules during blackbox testing")
+		return nil
+	}
+	// install every module in one transaction, so the policy is only
+	// rebuilt once and modules may depend on each other
+	if _, err := s.Logger.LogCmd(
+		exec.CommandContext(s.ctx, distro.SemoduleCmd(), args...),
+		"installing %d SELinux modules", len(config.Selinux.Modules),
+	); err != nil {
+		return fmt.Errorf("semodule failed: %v", err)
+	}
+	// the rebuilt policy and the module store were written without labels
+	s.relabel("/etc/selinux", "/var/lib/selinux")
+	return nil
+}
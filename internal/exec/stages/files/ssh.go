@@ -0,0 +1,133 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/failure"
+)
+
+const sshdHostKeysDropinPath = "/etc/ssh/sshd_config.d/40-ignition-host-keys.conf"
+
+// sshHostKeyTypes lists every host key type sshd generates by default.
+var sshHostKeyTypes = []string{"rsa", "ecdsa", "ed25519"}
+
+func sshHostKeyPath(keyType string) string {
+	return fmt.Sprintf("/etc/ssh/ssh_host_%s_key", keyType)
+}
+
+// installSSHHostKeys writes the host keys in config.Security.SSHHostKeys to
+// the target root, and, if its types are specified, configures sshd to use
+// only keys of those types and masks the units generating the others.
+func (s *stage) installSSHHostKeys(config types.Config) error {
+	hostKeys := config.Security.SSHHostKeys
+	if len(hostKeys.Keys) == 0 && len(hostKeys.Types) == 0 {
+		return nil
+	}
+
+	s.Logger.PushPrefix("installSSHHostKeys")
+	defer s.Logger.PopPrefix()
+
+	for _, key := range hostKeys.Keys {
+		if err := s.Logger.LogOp(
+			func() error { return s.installSSHHostKey(key) },
+			"installing %s SSH host key", key.Type,
+		); err != nil {
+			return err
+		}
+	}
+
+	if len(hostKeys.Types) == 0 {
+		return nil
+	}
+	dropin := "# Generated by Ignition from security.sshHostKeys.types\n"
+	for _, t := range hostKeys.Types {
+		dropin += fmt.Sprintf("HostKey %s\n", sshHostKeyPath(t))
+	}
+	if err := s.writeFile(sshdHostKeysDropinPath, []byte(dropin), 0600); err != nil {
+		return err
+	}
+
+	allowed := map[string]bool{}
+	for _, t := range hostKeys.Types {
+		allowed[t] = true
+	}
+	for _, t := range sshHostKeyTypes {
+		if allowed[t] {
+			continue
+		}
+		// the generator units of Fedora and RHEL; elsewhere, sshd only
+		// ignores keys it isn't configured to use
+		unit := types.Unit{Name: fmt.Sprintf("sshd-keygen@%s.service", t)}
+		relabelpath := ""
+		if err := s.Logger.LogOp(
+			func() error {
+				var err error
+				relabelpath, err = s.MaskUnit(unit)
+				return err
+			},
+			"masking unit %q", unit.Name,
+		); err != nil {
+			return err
+		}
+		s.relabel(relabelpath)
+	}
+	return nil
+}
+
+// installSSHHostKey fetches the private key to a directory only root can
+// read, so it's never written to the target with a permissive mode, then
+// writes it and its public key into place.
+func (s *stage) installSSHHostKey(key types.SSHHostKey) error {
+	tmpDir, err := ioutil.TempDir("", "ignition-ssh")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	f := types.File{
+		Node: types.Node{Path: filepath.Join(tmpDir, "key")},
+		FileEmbedded1: types.FileEmbedded1{
+			Contents: types.FileContents{
+				Source:       key.Source,
+				Verification: key.Verification,
+			},
+		},
+	}
+	fetchOps, err := s.PrepareFetches(s.Logger, f)
+	if err != nil {
+		return err
+	}
+	for _, op := range fetchOps {
+		if err := s.PerformFetch(s.ctx, op); err != nil {
+			return failure.Errorf("failed to fetch %s host key: %v", key.Type, err)
+		}
+	}
+	private, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+
+	keyPath := sshHostKeyPath(key.Type)
+	if err := s.writeFile(keyPath, private, 0600); err != nil {
+		return err
+	}
+	return s.writeFile(keyPath+".pub", []byte(key.PublicKey+"\n"), 0644)
+}
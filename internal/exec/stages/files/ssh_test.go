@@ -0,0 +1,93 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestInstallSSHHostKeys(t *testing.T) {
+	root, err := ioutil.TempDir("", "ign-ssh-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	logger := log.New(true)
+	s := stage{
+		Util: util.Util{
+			DestDir: root,
+			Logger:  &logger,
+			Fetcher: resource.Fetcher{Logger: &logger},
+		},
+		ctx: context.Background(),
+	}
+	var config types.Config
+	config.Security.SSHHostKeys = types.SSHHostKeys{
+		Keys: []types.SSHHostKey{{
+			Type:      "ed25519",
+			Source:    cutil.StrToPtr("data:,private"),
+			PublicKey: "ssh-ed25519 AAAAC3Nza host",
+		}},
+		Types: []string{"ed25519"},
+	}
+	if err := s.installSSHHostKeys(config); err != nil {
+		t.Fatalf("installSSHHostKeys failed: %v", err)
+	}
+
+	for path, expected := range map[string]struct {
+		contents string
+		mode     os.FileMode
+	}{
+		"etc/ssh/ssh_host_ed25519_key":                     {"private", 0600},
+		"etc/ssh/ssh_host_ed25519_key.pub":                 {"ssh-ed25519 AAAAC3Nza host\n", 0644},
+		"etc/ssh/sshd_config.d/40-ignition-host-keys.conf": {"# Generated by Ignition from security.sshHostKeys.types\nHostKey /etc/ssh/ssh_host_ed25519_key\n", 0600},
+	} {
+		path = filepath.Join(root, path)
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("read error: %v", err)
+			continue
+		}
+		if string(contents) != expected.contents {
+			t.Errorf("%s: bad contents: want %q, got %q", path, expected.contents, contents)
+		}
+		if info, err := os.Stat(path); err != nil {
+			t.Errorf("stat error: %v", err)
+		} else if info.Mode().Perm() != expected.mode {
+			t.Errorf("%s: bad mode: want %v, got %v", path, expected.mode, info.Mode().Perm())
+		}
+	}
+
+	for _, unit := range []string{"sshd-keygen@rsa.service", "sshd-keygen@ecdsa.service"} {
+		target, err := os.Readlink(filepath.Join(root, "etc/systemd/system", unit))
+		if err != nil || target != "/dev/null" {
+			t.Errorf("%s not masked: %v", unit, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(root, "etc/systemd/system/sshd-keygen@ed25519.service")); !os.IsNotExist(err) {
+		t.Errorf("allowed key generator was masked")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+const timesyncdDropinPath = "/etc/systemd/timesyncd.conf.d/40-ignition.conf"
+
+// configureTime points the time daemon of the target root at the servers and
+// pools in config.Time. chrony's configuration keeps everything but its
+// existing time sources, while timesyncd gets a dropin overriding its NTP
+// servers.
+func (s *stage) configureTime(config types.Config) error {
+	if len(config.Time.Servers) == 0 && len(config.Time.Pools) == 0 {
+		return nil
+	}
+
+	s.Logger.PushPrefix("configureTime")
+	defer s.Logger.PopPrefix()
+
+	switch distro.TimeSyncDaemon() {
+	case "chrony":
+		path := distro.ChronyConfPath()
+		existing, err := s.readTargetFile(path)
+		if err != nil {
+			return err
+		}
+		contents := renderChronyConf(existing, config.Time)
+		return s.Logger.LogOp(
+			func() error { return s.writeFile(path, []byte(contents), 0644) },
+			"writing chrony configuration %q", path,
+		)
+	case "timesyncd":
+		var sources []string
+		for _, source := range config.Time.Servers {
+			sources = append(sources, string(source))
+		}
+		for _, source := range config.Time.Pools {
+			sources = append(sources, string(source))
+		}
+		contents := fmt.Sprintf("# Generated by Ignition\n[Time]\nNTP=%s\n", strings.Join(sources, " "))
+		return s.Logger.LogOp(
+			func() error { return s.writeFile(timesyncdDropinPath, []byte(contents), 0644) },
+			"writing timesyncd dropin %q", timesyncdDropinPath,
+		)
+	default:
+		return fmt.Errorf("unsupported time synchronization daemon %q", distro.TimeSyncDaemon())
+	}
+}
+
+// readTargetFile returns the contents of the file at path in the target
+// root, or nothing if it doesn't exist.
+func (s *stage) readTargetFile(path string) (string, error) {
+	fullPath, err := s.JoinPath(path)
+	if err != nil {
+		return "", err
+	}
+	f, err := s.OpenFile(fullPath, os.O_RDONLY, 0)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	contents, err := ioutil.ReadAll(f)
+	return string(contents), err
+}
+
+// renderChronyConf returns the chrony configuration existing with its
+// server and pool directives replaced by the sources in t, so the
+// distribution's other settings, such as the drift file, are kept.
+func renderChronyConf(existing string, t types.Time) string {
+	var b strings.Builder
+	b.WriteString("# Time sources configured by Ignition\n")
+	for _, server := range t.Servers {
+		fmt.Fprintf(&b, "server %s iburst\n", server)
+	}
+	for _, pool := range t.Pools {
+		fmt.Fprintf(&b, "pool %s iburst\n", pool)
+	}
+	for _, line := range strings.SplitAfter(existing, "\n") {
+		if line == "" {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) > 0 && (fields[0] == "server" || fields[0] == "pool") {
+			continue
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
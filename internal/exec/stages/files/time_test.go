@@ -0,0 +1,71 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestRenderChronyConf(t *testing.T) {
+	existing := "# Use public servers from the pool.ntp.org project.\npool 2.fedora.pool.ntp.org iburst\nserver 192.0.2.1\ndriftfile /var/lib/chrony/drift\nmakestep 1.0 3"
+	config := types.Time{
+		Servers: []types.TimeSource{"ntp1.example.com"},
+		Pools:   []types.TimeSource{"pool.example.com"},
+	}
+	expected := "# Time sources configured by Ignition\n" +
+		"server ntp1.example.com iburst\n" +
+		"pool pool.example.com iburst\n" +
+		"# Use public servers from the pool.ntp.org project.\n" +
+		"driftfile /var/lib/chrony/drift\n" +
+		"makestep 1.0 3\n"
+	if out := renderChronyConf(existing, config); out != expected {
+		t.Errorf("bad configuration:\nwant %q\ngot  %q", expected, out)
+	}
+}
+
+func TestConfigureTimeTimesyncd(t *testing.T) {
+	root, err := ioutil.TempDir("", "ign-time-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(root)
+	os.Setenv("IGNITION_TIME_SYNC_DAEMON", "timesyncd")
+	defer os.Unsetenv("IGNITION_TIME_SYNC_DAEMON")
+
+	logger := log.New(true)
+	s := stage{Util: util.Util{DestDir: root, Logger: &logger}}
+	var config types.Config
+	config.Time.Servers = []types.TimeSource{"ntp1.example.com", "192.0.2.1"}
+	config.Time.Pools = []types.TimeSource{"pool.example.com"}
+	if err := s.configureTime(config); err != nil {
+		t.Fatalf("configureTime failed: %v", err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(root, timesyncdDropinPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# Generated by Ignition\n[Time]\nNTP=ntp1.example.com 192.0.2.1 pool.example.com\n"
+	if string(contents) != expected {
+		t.Errorf("bad dropin: want %q, got %q", expected, contents)
+	}
+}
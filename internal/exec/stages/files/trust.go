@@ -0,0 +1,118 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+// installTrustedCertificates writes the certificates in
+// config.Security.TrustedCertificates into the trust anchors directory of
+// the target root and regenerates its trust store.
+func (s *stage) installTrustedCertificates(config types.Config) error {
+	if len(config.Security.TrustedCertificates) == 0 {
+		return nil
+	}
+
+	s.Logger.PushPrefix("installTrustedCertificates")
+	defer s.Logger.PopPrefix()
+
+	for _, cert := range config.Security.TrustedCertificates {
+		path, err := s.JoinPath(distro.TrustAnchorsDir(), cert.Name+".crt")
+		if err != nil {
+			return err
+		}
+		if s.snapshot != nil {
+			if err := s.snapshot.Save(path); err != nil {
+				return err
+			}
+		}
+		f := types.File{
+			Node: types.Node{Path: path},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.FileContents{
+					Source:       cert.Source,
+					Verification: cert.Verification,
+				},
+			},
+		}
+		fetchOps, err := s.PrepareFetches(s.Logger, f)
+		if err != nil {
+			return err
+		}
+		for _, op := range fetchOps {
+			if err := s.Logger.LogOp(
+				func() error { return s.PerformFetch(s.ctx, op) },
+				"writing trusted certificate %q to %q", cert.Name, path,
+			); err != nil {
+				return err
+			}
+		}
+		if err := checkCertificates(path); err != nil {
+			return fmt.Errorf("trusted certificate %q: %v", cert.Name, err)
+		}
+		s.relabel(filepath.Join(distro.TrustAnchorsDir(), cert.Name+".crt"))
+	}
+
+	if distro.BlackboxTesting() {
+		s.Logger.Info("skipping trust store update during blackbox testing")
+		return nil
+	}
+	if _, err := s.Logger.LogCmd(
+		exec.CommandContext(s.ctx, distro.ChrootCmd(), s.DestDir, distro.UpdateTrustCmd()),
+		"updating trust store",
+	); err != nil {
+		return fmt.Errorf("%s failed: %v", distro.UpdateTrustCmd(), err)
+	}
+	s.relabel(distro.TrustStoreDir())
+	return nil
+}
+
+// checkCertificates returns an error unless the file at path is a series of
+// one or more PEM-encoded X.509 certificates, since the trust store would
+// otherwise silently skip it.
+func checkCertificates(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	found := false
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("no PEM-encoded certificates found")
+	}
+	return nil
+}
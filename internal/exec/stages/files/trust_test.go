@@ -0,0 +1,72 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testCertificate = `-----BEGIN CERTIFICATE-----
+MIIBdDCCARugAwIBAgIUUm7m7EEzH/GoaUboZAg8w4Yt3+MwCgYIKoZIzj0EAwIw
+DzENMAsGA1UEAwwEdGVzdDAgFw0yNjEwMTUwMjU2MTNaGA8yMTI2MDkyMTAyNTYx
+M1owDzENMAsGA1UEAwwEdGVzdDBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABKA6
+h6oenqvdy5Mj2OPbRoa/Wo4WDhKkwTkw/NZF2Nrwvnrn8e1V2WgM+6K08eTigyMY
+JqCQoPOompcM82rX05OjUzBRMB0GA1UdDgQWBBTpvHuiwu6L/zh3joC4SNhLwoTk
+1zAfBgNVHSMEGDAWgBTpvHuiwu6L/zh3joC4SNhLwoTk1zAPBgNVHRMBAf8EBTAD
+AQH/MAoGCCqGSM49BAMCA0cAMEQCIBlTuaOI2CIpf6bVmuk0oq1L8hfMEz+NP7ar
+C/ujrYcwAiAfgP4fqA6ZMgi8CZXqVWchuYhcTOhiL96koelTeTwfwA==
+-----END CERTIFICATE-----
+`
+
+func TestCheckCertificates(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-trust-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	tests := []struct {
+		in    string
+		valid bool
+	}{
+		{
+			in:    testCertificate,
+			valid: true,
+		},
+		{
+			in:    testCertificate + testCertificate,
+			valid: true,
+		},
+		{
+			in: "",
+		},
+		{
+			in: "-----BEGIN PUBLIC KEY-----\nMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE\n-----END PUBLIC KEY-----\n",
+		},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(td, "cert.pem")
+		if err := ioutil.WriteFile(path, []byte(test.in), 0644); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		if err := checkCertificates(path); (err == nil) != test.valid {
+			t.Errorf("#%d: bad result: want valid %v, got error %v", i, test.valid, err)
+		}
+	}
+}
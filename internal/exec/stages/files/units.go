@@ -28,47 +28,70 @@ import (
 func (s *stage) createUnits(config types.Config) error {
 	enabledOneUnit := false
 	for _, unit := range config.Systemd.Units {
-		if err := s.writeSystemdUnit(unit, false); err != nil {
-			return err
+		if err := s.createUnit(unit); err != nil {
+			if !unit.WarnsOnFailure() {
+				return err
+			}
+			s.Logger.Warning("not creating optional unit %q: %v", unit.Name, err)
 		}
 		if unit.Enabled != nil {
-			if *unit.Enabled {
-				if err := s.Logger.LogOp(
-					func() error { return s.EnableUnit(unit) },
-					"enabling unit %q", unit.Name,
-				); err != nil {
-					return err
-				}
-			} else {
-				if err := s.Logger.LogOp(
-					func() error { return s.DisableUnit(unit) },
-					"disabling unit %q", unit.Name,
-				); err != nil {
-					return err
-				}
-			}
 			enabledOneUnit = true
 		}
-		if unit.Mask != nil && *unit.Mask {
-			relabelpath := ""
+	}
+	// and relabel the preset file itself if we enabled/disabled something
+	if enabledOneUnit {
+		s.relabel(util.PresetPath)
+	}
+	return nil
+}
+
+// createUnit writes, enables or disables, and masks unit as it specifies.
+func (s *stage) createUnit(unit types.Unit) error {
+	op := fmt.Sprintf("unit %s", unit.Name)
+	if s.checkpointed(op) {
+		return nil
+	}
+	if err := s.writeSystemdUnit(unit, false); err != nil {
+		return err
+	}
+	if unit.Enabled != nil {
+		if err := s.snapshotPaths(util.PresetPath); err != nil {
+			return err
+		}
+		if *unit.Enabled {
 			if err := s.Logger.LogOp(
-				func() error {
-					var err error
-					relabelpath, err = s.MaskUnit(unit)
-					return err
-				},
-				"masking unit %q", unit.Name,
+				func() error { return s.EnableUnit(unit) },
+				"enabling unit %q", unit.Name,
+			); err != nil {
+				return err
+			}
+		} else {
+			if err := s.Logger.LogOp(
+				func() error { return s.DisableUnit(unit) },
+				"disabling unit %q", unit.Name,
 			); err != nil {
 				return err
 			}
-			s.relabel(relabelpath)
 		}
 	}
-	// and relabel the preset file itself if we enabled/disabled something
-	if enabledOneUnit {
-		s.relabel(util.PresetPath)
+	if unit.Mask != nil && *unit.Mask {
+		if err := s.snapshotPaths(filepath.Join(util.SystemdUnitsPath(), unit.Name)); err != nil {
+			return err
+		}
+		relabelpath := ""
+		if err := s.Logger.LogOp(
+			func() error {
+				var err error
+				relabelpath, err = s.MaskUnit(unit)
+				return err
+			},
+			"masking unit %q", unit.Name,
+		); err != nil {
+			return err
+		}
+		s.relabel(relabelpath)
 	}
-	return nil
+	return s.recordCheckpoint(op)
 }
 
 // writeSystemdUnit creates the specified unit and any dropins for that unit.
@@ -100,9 +123,12 @@ func (s *stage) writeSystemdUnit(unit types.Unit, runtime bool) error {
 					panic(fmt.Sprintf("Dropin path %s isn't under prefix %s", f.Node.Path, s.DestDir))
 				}
 				relabelPath = f.Node.Path[len(s.DestDir):]
+				if err := s.snapshotPaths(relabelPath); err != nil {
+					return err
+				}
 			}
 			if err := s.Logger.LogOp(
-				func() error { return u.PerformFetch(f) },
+				func() error { return u.PerformFetch(s.ctx, f) },
 				"writing systemd drop-in %q at %q", dropin.Name, f.Node.Path,
 			); err != nil {
 				return err
@@ -129,9 +155,12 @@ func (s *stage) writeSystemdUnit(unit types.Unit, runtime bool) error {
 				panic(fmt.Sprintf("Unit path %s isn't under prefix %s", f.Node.Path, s.DestDir))
 			}
 			relabelPath = f.Node.Path[len(s.DestDir):]
+			if err := s.snapshotPaths(relabelPath); err != nil {
+				return err
+			}
 		}
 		if err := s.Logger.LogOp(
-			func() error { return u.PerformFetch(f) },
+			func() error { return u.PerformFetch(s.ctx, f) },
 			"writing unit %q at %q", unit.Name, f.Node.Path,
 		); err != nil {
 			return err
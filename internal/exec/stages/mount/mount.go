@@ -19,6 +19,7 @@
 package disks
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -59,13 +60,18 @@ func (creator) Name() string {
 
 type stage struct {
 	util.Util
+	// ctx is cancelled when the run should be aborted, which kills the
+	// commands the stage runs
+	ctx context.Context
 }
 
 func (stage) Name() string {
 	return name
 }
 
-func (s stage) Run(config types.Config) error {
+func (s stage) Run(ctx context.Context, config types.Config) error {
+	s.ctx = ctx
+
 	fss := []types.Filesystem{}
 	for _, fs := range config.Storage.Filesystems {
 		if fs.Path != nil && *fs.Path != "" {
@@ -135,7 +141,7 @@ func (s stage) mountFs(fs types.Filesystem) error {
 	}
 
 	args := translateOptionSliceToString(fs.MountOptions, ",")
-	cmd := exec.Command(distro.MountCmd(), "-o", args, "-t", *fs.Format, fs.Device, path)
+	cmd := exec.CommandContext(s.ctx, distro.MountCmd(), "-o", args, "-t", *fs.Format, fs.Device, path)
 	if _, err := s.Logger.LogCmd(cmd,
 		"mounting %q at %q with type %q and options %q", fs.Device, path, *fs.Format, args,
 	); err != nil {
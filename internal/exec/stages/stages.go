@@ -15,6 +15,8 @@
 package stages
 
 import (
+	"context"
+
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/registry"
@@ -22,11 +24,25 @@ import (
 )
 
 // Stage is responsible for actually executing a stage of the configuration.
+// Run should return promptly once ctx is cancelled, aborting the fetches and
+// commands in progress.
 type Stage interface {
-	Run(config types.Config) error
+	Run(ctx context.Context, config types.Config) error
 	Name() string
 }
 
+// Planner is implemented by stages which can describe the changes they would
+// make, in the order they would make them, without making them.
+type Planner interface {
+	Plan(config types.Config) ([]string, error)
+}
+
+// Reconciler is implemented by stages which act differently when a config
+// is reapplied in reconcile mode.
+type Reconciler interface {
+	SetReconcile(reconcile bool)
+}
+
 // StageCreator is responsible for instantiating a particular stage given a
 // logger and root path under the root partition.
 type StageCreator interface {
@@ -19,6 +19,7 @@
 package disks
 
 import (
+	"context"
 	"sort"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
@@ -61,7 +62,7 @@ func (stage) Name() string {
 	return name
 }
 
-func (s stage) Run(config types.Config) error {
+func (s stage) Run(_ context.Context, config types.Config) error {
 	fss := []types.Filesystem{}
 	for _, fs := range config.Storage.Filesystems {
 		if fs.Path != nil && *fs.Path != "" {
@@ -0,0 +1,105 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const dirFlags = unix.O_PATH | unix.O_DIRECTORY | unix.O_NOFOLLOW | unix.O_CLOEXEC
+
+// openParent opens the directory containing path and returns it with the
+// last element of path, for use with the *at syscalls. Below u.DestDir,
+// each directory is opened relative to the one before it without following
+// symlinks, so once JoinPath has resolved a path, changes to the tree can't
+// redirect operations on it outside u.DestDir. Missing directories are
+// created if create is set. The caller must close the returned descriptor.
+func (u Util) openParent(path string, create bool) (int, string, error) {
+	rel, err := filepath.Rel(u.DestDir, path)
+	if u.DestDir == "" || err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		// not in the destination root, e.g. a temporary directory
+		dir := filepath.Dir(path)
+		if create {
+			if err := os.MkdirAll(dir, DefaultDirectoryPermissions); err != nil {
+				return -1, "", err
+			}
+		}
+		fd, err := unix.Open(dir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return -1, "", &os.PathError{Op: "open", Path: dir, Err: err}
+		}
+		return fd, filepath.Base(path), nil
+	}
+
+	fd, err := unix.Open(u.DestDir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, "", &os.PathError{Op: "open", Path: u.DestDir, Err: err}
+	}
+	dir := u.DestDir
+	for _, component := range strings.Split(filepath.Dir(rel), "/") {
+		if component == "." {
+			continue
+		}
+		dir = filepath.Join(dir, component)
+		next, err := unix.Openat(fd, component, dirFlags, 0)
+		if err == unix.ENOENT && create {
+			err = unix.Mkdirat(fd, component, uint32(DefaultDirectoryPermissions))
+			if err == nil || err == unix.EEXIST {
+				next, err = unix.Openat(fd, component, dirFlags, 0)
+			}
+		}
+		unix.Close(fd)
+		if err != nil {
+			// ENOTDIR here includes symlinks, which JoinPath would have
+			// resolved unless the tree changed since
+			return -1, "", &os.PathError{Op: "open", Path: dir, Err: err}
+		}
+		fd = next
+	}
+	return fd, filepath.Base(rel), nil
+}
+
+// MkdirAll is os.MkdirAll for paths under u.DestDir, without following
+// symlinks.
+func (u Util) MkdirAll(path string) error {
+	dirfd, name, err := u.openParent(path, true)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+	if err := unix.Mkdirat(dirfd, name, uint32(DefaultDirectoryPermissions)); err != nil && err != unix.EEXIST {
+		return &os.PathError{Op: "mkdir", Path: path, Err: err}
+	}
+	return nil
+}
+
+// OpenFile is os.OpenFile for paths under u.DestDir. It creates missing
+// parent directories and never follows symlinks.
+func (u Util) OpenFile(path string, flag int, perm os.FileMode) (*os.File, error) {
+	dirfd, name, err := u.openParent(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(dirfd)
+	fd, err := unix.Openat(dirfd, name, flag|unix.O_NOFOLLOW|unix.O_CLOEXEC, uint32(perm.Perm()))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(fd), path), nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestAtOperations(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-at-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	root := filepath.Join(td, "root")
+	outside := filepath.Join(td, "outside")
+	for _, dir := range []string{root, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("mkdir error: %v", err)
+		}
+	}
+
+	logger := log.New(true)
+	u := Util{DestDir: root, Logger: &logger}
+
+	// missing directories are created
+	if err := u.MkdirAll(filepath.Join(root, "etc", "foo")); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(root, "etc", "foo")); err != nil || !info.IsDir() {
+		t.Fatalf("directory not created: %v", err)
+	}
+
+	f, err := u.OpenFile(filepath.Join(root, "etc", "foo", "bar"), os.O_WRONLY|os.O_CREATE, DefaultFilePermissions)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.Close()
+
+	link := types.Link{
+		Node:          types.Node{Path: filepath.Join(root, "etc", "link")},
+		LinkEmbedded1: types.LinkEmbedded1{Target: "/etc/foo/bar", Hard: util.BoolToPtr(true)},
+	}
+	if err := u.WriteLink(link); err != nil {
+		t.Fatalf("WriteLink failed: %v", err)
+	}
+	st1, _ := os.Stat(filepath.Join(root, "etc", "link"))
+	st2, _ := os.Stat(filepath.Join(root, "etc", "foo", "bar"))
+	if !os.SameFile(st1, st2) {
+		t.Errorf("hard link doesn't point to its target")
+	}
+
+	// a directory swapped for a symlink after the path was resolved must
+	// not be followed
+	if err := os.RemoveAll(filepath.Join(root, "etc", "foo")); err != nil {
+		t.Fatalf("remove error: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "etc", "foo")); err != nil {
+		t.Fatalf("symlink error: %v", err)
+	}
+	if _, err := u.OpenFile(filepath.Join(root, "etc", "foo", "bar"), os.O_WRONLY|os.O_CREATE, DefaultFilePermissions); err == nil {
+		t.Errorf("OpenFile followed a symlink out of the root")
+	}
+	mode := 0600
+	if err := u.SetPermissions(&mode, types.Node{Path: filepath.Join(root, "etc", "foo")}); err == nil {
+		t.Errorf("SetPermissions followed a symlink out of the root")
+	}
+	if entries, _ := ioutil.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("files were created outside the root")
+	}
+	if info, _ := os.Stat(outside); info.Mode().Perm() != 0755 {
+		t.Errorf("mode of directory outside the root changed to %v", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+// BackupSuffix is appended to the path of a file to form the path of the
+// copy saved before its edits are applied.
+const BackupSuffix = ".bak"
+
+// EditFile applies the line edits of f to the file at f.Path, which must
+// already exist. The file is rewritten in place only if the edits change
+// it, preserving its owner and mode. If f.Backup is set, the original is
+// first saved next to it with BackupSuffix appended.
+func (u Util) EditFile(f types.File) error {
+	if len(f.Edits) == 0 {
+		return nil
+	}
+	info, err := os.Lstat(f.Path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("can only edit files: %q", f.Path)
+	}
+	original, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+	edited, err := applyEdits(original, f.Edits)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(original, edited) {
+		u.Info("edits to %q are already applied", f.Path)
+		return nil
+	}
+
+	uid, gid, _ := getFileOwnerAndMode(f.Path)
+	mode := info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	if f.Backup != nil && *f.Backup {
+		if err := writeFileAtomic(f.Path+BackupSuffix, original, mode, uid, gid); err != nil {
+			return fmt.Errorf("backing up %q: %v", f.Path, err)
+		}
+	}
+	return writeFileAtomic(f.Path, edited, mode, uid, gid)
+}
+
+// applyEdits returns contents with edits applied in order. Each edit
+// either removes every line matching its regex or replaces every matching
+// line with its line, appending the line if nothing matched and the line is
+// not already present.
+func applyEdits(contents []byte, edits []types.FileEdit) ([]byte, error) {
+	text := string(contents)
+	trailingNewline := text == "" || strings.HasSuffix(text, "\n")
+	var lines []string
+	if text != "" {
+		lines = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	}
+
+	for _, edit := range edits {
+		re, err := regexp.Compile(edit.Regex)
+		if err != nil {
+			return nil, err
+		}
+		absent := edit.Absent != nil && *edit.Absent
+
+		var result []string
+		matched, present := false, false
+		for _, line := range lines {
+			switch {
+			case !re.MatchString(line):
+				if edit.Line != nil && line == *edit.Line {
+					present = true
+				}
+				result = append(result, line)
+			case absent:
+				break
+			default:
+				matched = true
+				result = append(result, *edit.Line)
+			}
+		}
+		if !absent && !matched && !present {
+			result = append(result, *edit.Line)
+			// the file ends with the line we just added
+			trailingNewline = true
+		}
+		lines = result
+	}
+
+	if len(lines) == 0 {
+		return []byte{}, nil
+	}
+	text = strings.Join(lines, "\n")
+	if trailingNewline {
+		text += "\n"
+	}
+	return []byte(text), nil
+}
+
+// writeFileAtomic replaces the file at path with data by renaming a
+// temporary file from the same directory over it.
+func writeFileAtomic(path string, data []byte, mode os.FileMode, uid, gid int) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+
+	// sometimes the following line will fail (the file might be renamed),
+	// but that's ok (we wanted to keep the file in that case).
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	// chown clears the setuid and setgid bits, so it goes first
+	if err := tmp.Chown(uid, gid); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestApplyEdits(t *testing.T) {
+	line := func(regex, line string) types.FileEdit {
+		return types.FileEdit{Regex: regex, Line: &line}
+	}
+	absent := func(regex string) types.FileEdit {
+		t := true
+		return types.FileEdit{Regex: regex, Absent: &t}
+	}
+
+	tests := []struct {
+		in    string
+		edits []types.FileEdit
+		out   string
+	}{
+		// replace a matching line
+		{
+			"a=1\nGRUB_TIMEOUT=5\nb=2\n",
+			[]types.FileEdit{line("^GRUB_TIMEOUT=", "GRUB_TIMEOUT=1")},
+			"a=1\nGRUB_TIMEOUT=1\nb=2\n",
+		},
+		// append when nothing matches
+		{
+			"a=1\n",
+			[]types.FileEdit{line("^b=", "b=2")},
+			"a=1\nb=2\n",
+		},
+		// append to a file without a trailing newline
+		{
+			"a=1",
+			[]types.FileEdit{line("^b=", "b=2")},
+			"a=1\nb=2\n",
+		},
+		// append to an empty file
+		{
+			"",
+			[]types.FileEdit{line("^b=", "b=2")},
+			"b=2\n",
+		},
+		// don't append a line that's already present
+		{
+			"a=1\nb=2\n",
+			[]types.FileEdit{line("^c=", "b=2")},
+			"a=1\nb=2\n",
+		},
+		// remove every matching line
+		{
+			"# x\na=1\n# y\n",
+			[]types.FileEdit{absent("^#")},
+			"a=1\n",
+		},
+		// remove everything
+		{
+			"a=1\n",
+			[]types.FileEdit{absent("")},
+			"",
+		},
+		// absent lines that aren't there change nothing
+		{
+			"a=1",
+			[]types.FileEdit{absent("^b=")},
+			"a=1",
+		},
+		// edits apply in order
+		{
+			"a=1\n",
+			[]types.FileEdit{line("^a=", "a=2"), absent("^a=1$"), line("^a=2$", "a=3")},
+			"a=3\n",
+		},
+	}
+
+	for i, test := range tests {
+		out, err := applyEdits([]byte(test.in), test.edits)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("#%d: bad output: want %q, got %q", i, test.out, string(out))
+		}
+	}
+}
+
+func TestEditFileKeepsMode(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-edit-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{DestDir: td, Logger: &logger}
+
+	tests := []os.FileMode{
+		0755 | os.ModeSetuid,
+		0750 | os.ModeSetgid,
+		0644 | os.ModeSticky,
+	}
+	for i, mode := range tests {
+		path := filepath.Join(td, "file")
+		if err := ioutil.WriteFile(path, []byte("a=1\n"), 0600); err != nil {
+			t.Fatalf("#%d: write error: %v", i, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("#%d: chmod error: %v", i, err)
+		}
+		f := types.File{Node: types.Node{Path: path}}
+		f.Backup = util.BoolToPtr(true)
+		f.Edits = []types.FileEdit{{Regex: "^a=", Line: util.StrToPtr("a=2")}}
+		if err := u.EditFile(f); err != nil {
+			t.Fatalf("#%d: edit error: %v", i, err)
+		}
+		for _, p := range []string{path, path + BackupSuffix} {
+			info, err := os.Stat(p)
+			if err != nil {
+				t.Fatalf("#%d: stat error: %v", i, err)
+			}
+			if info.Mode() != mode {
+				t.Errorf("#%d: %s: expected mode %v, got %v", i, filepath.Base(p), mode, info.Mode())
+			}
+		}
+		os.Remove(path + BackupSuffix)
+	}
+}
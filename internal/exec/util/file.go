@@ -15,6 +15,8 @@
 package util
 
 import (
+	"bufio"
+	"context"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -24,8 +26,10 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/resource"
 	"github.com/coreos/ignition/v2/internal/util"
@@ -44,6 +48,16 @@ type FetchOp struct {
 	FetchOptions resource.FetchOptions
 	Append       bool
 	Node         types.Node
+
+	// Marker and EndMarker are lines written before and after appended
+	// contents. If Marker is already present in the file, the append is
+	// skipped.
+	Marker    string
+	EndMarker string
+
+	// Merge is the format of the fetched contents, if they are to be
+	// merged into the existing file rather than replacing it.
+	Merge string
 }
 
 // newHashedReader returns a new ReadCloser that also writes to the provided hash.
@@ -64,6 +78,10 @@ func newFetchOp(l *log.Logger, node types.Node, contents types.FileContents) (Fe
 	if err != nil {
 		return FetchOp{}, err
 	}
+	mirrors, err := resource.ParseMirrors(contents.Mirrors)
+	if err != nil {
+		return FetchOp{}, err
+	}
 
 	hasher, err := util.GetHasher(contents.Verification)
 	if err != nil {
@@ -85,6 +103,22 @@ func newFetchOp(l *log.Logger, node types.Node, contents types.FileContents) (Fe
 	if contents.Compression != nil {
 		compression = *contents.Compression
 	}
+	marker := ""
+	if contents.Marker != nil {
+		marker = *contents.Marker
+	}
+	endMarker := ""
+	if contents.EndMarker != nil {
+		endMarker = *contents.EndMarker
+	}
+	merge := ""
+	if contents.Merge != nil {
+		merge = *contents.Merge
+	}
+	patchFrom := ""
+	if contents.PatchFrom != nil {
+		patchFrom = *contents.PatchFrom
+	}
 
 	return FetchOp{
 		Hash: hasher,
@@ -93,11 +127,29 @@ func newFetchOp(l *log.Logger, node types.Node, contents types.FileContents) (Fe
 		FetchOptions: resource.FetchOptions{
 			Hash:        hasher,
 			Compression: compression,
+			Encryption:  contents.Encryption,
 			ExpectedSum: expectedSum,
+			Mirrors:     mirrors,
+			PatchFrom:   patchFrom,
 		},
+		Marker:    marker,
+		EndMarker: endMarker,
+		Merge:     merge,
 	}, nil
 }
 
+// prepareFetch is newFetchOp for contents whose hash may be in a checksums
+// manifest, which is fetched first.
+func (u Util) prepareFetch(l *log.Logger, node types.Node, contents types.FileContents) (FetchOp, error) {
+	verification, err := u.Fetcher.ResolveVerification(contents.Verification)
+	if err != nil {
+		l.Crit("Error finding the hash of file %q: %v", node.Path, err)
+		return FetchOp{}, err
+	}
+	contents.Verification = verification
+	return newFetchOp(l, node, contents)
+}
+
 // PrepareFetches converts a given logger, http client, and types.File into a
 // FetchOp. This includes operations such as parsing the source URL, generating
 // a hasher, and performing user/group name lookups. If an error is encountered,
@@ -106,7 +158,7 @@ func (u Util) PrepareFetches(l *log.Logger, f types.File) ([]FetchOp, error) {
 	ops := []FetchOp{}
 
 	if f.Contents.Source != nil {
-		if base, err := newFetchOp(l, f.Node, f.Contents); err != nil {
+		if base, err := u.prepareFetch(l, f.Node, f.Contents); err != nil {
 			return nil, err
 		} else {
 			ops = append(ops, base)
@@ -114,7 +166,7 @@ func (u Util) PrepareFetches(l *log.Logger, f types.File) ([]FetchOp, error) {
 	}
 
 	for _, appendee := range f.Append {
-		if op, err := newFetchOp(l, f.Node, appendee); err != nil {
+		if op, err := u.prepareFetch(l, f.Node, appendee); err != nil {
 			return nil, err
 		} else {
 			op.Append = true
@@ -128,20 +180,34 @@ func (u Util) PrepareFetches(l *log.Logger, f types.File) ([]FetchOp, error) {
 func (u Util) WriteLink(s types.Link) error {
 	path := s.Path
 
-	if err := MkdirForFile(path); err != nil {
+	dirfd, name, err := u.openParent(path, true)
+	if err != nil {
 		return fmt.Errorf("Could not create leading directories: %v", err)
 	}
+	defer unix.Close(dirfd)
 
 	if s.Hard != nil && *s.Hard {
 		targetPath, err := u.JoinPath(s.Target)
 		if err != nil {
 			return err
 		}
-		return os.Link(targetPath, path)
+		targetDirfd, targetName, err := u.openParent(targetPath, false)
+		if err != nil {
+			return err
+		}
+		defer unix.Close(targetDirfd)
+		if err := unix.Linkat(targetDirfd, targetName, dirfd, name, 0); err != nil {
+			return &os.LinkError{Op: "link", Old: targetPath, New: path, Err: err}
+		}
+		return nil
 	}
 
-	if err := os.Symlink(s.Target, path); err != nil {
-		return fmt.Errorf("Could not create symlink: %v", err)
+	target, err := u.SymlinkTarget(s)
+	if err != nil {
+		return fmt.Errorf("Could not resolve symlink target: %v", err)
+	}
+	if err := unix.Symlinkat(target, dirfd, name); err != nil {
+		return fmt.Errorf("Could not create symlink: %v", &os.LinkError{Op: "symlink", Old: target, New: path, Err: err})
 	}
 
 	if err := u.SetPermissions(nil, s.Node); err != nil {
@@ -150,36 +216,127 @@ func (u Util) WriteLink(s types.Link) error {
 	return nil
 }
 
+// SymlinkTarget returns the target that should be written for the symlink s,
+// normalized according to s.TargetStyle. "literal" (the default) returns the
+// target unmodified. "absolute" and "relative" resolve the target against
+// u.DestDir and return it as an absolute path within the destination root or
+// as a path relative to the directory containing the link, respectively.
+// s.Path is expected to already be prefixed with u.DestDir.
+func (u Util) SymlinkTarget(s types.Link) (string, error) {
+	style := "literal"
+	if s.TargetStyle != nil {
+		style = *s.TargetStyle
+	}
+	if style == "literal" {
+		return s.Target, nil
+	}
+
+	linkDir, err := filepath.Rel(u.DestDir, filepath.Dir(s.Path))
+	if err != nil {
+		return "", err
+	}
+	target := s.Target
+	if !filepath.IsAbs(target) {
+		target = filepath.Join("/", linkDir, target)
+	}
+	resolved, err := u.JoinPath(target)
+	if err != nil {
+		return "", err
+	}
+
+	switch style {
+	case "absolute":
+		rel, err := filepath.Rel(u.DestDir, resolved)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join("/", rel), nil
+	case "relative":
+		return filepath.Rel(filepath.Dir(s.Path), resolved)
+	default:
+		return "", fmt.Errorf("unknown target style %q", style)
+	}
+}
+
 func (u Util) SetPermissions(mode *int, node types.Node) error {
+	dirfd, name, err := u.openParent(node.Path, false)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(dirfd)
+
 	if mode != nil {
-		mode := os.FileMode(*mode)
-		if err := os.Chmod(node.Path, mode); err != nil {
+		// fchmodat can't refuse to follow symlinks, so go through a
+		// descriptor of the node itself
+		fd, err := unix.Openat(dirfd, name, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+		if err == nil {
+			err = unix.Fchmod(fd, uint32(os.FileMode(*mode).Perm()))
+			unix.Close(fd)
+		}
+		if err != nil {
 			return fmt.Errorf("failed to change mode of %s: %v", node.Path, err)
 		}
 	}
 
-	defaultUid, defaultGid, _ := getFileOwnerAndMode(node.Path)
+	defaultUid, defaultGid := 0, 0
+	var st unix.Stat_t
+	if err := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err == nil {
+		defaultUid, defaultGid = int(st.Uid), int(st.Gid)
+	}
 	uid, gid, err := u.ResolveNodeUidAndGid(node, defaultUid, defaultGid)
 	if err != nil {
 		return fmt.Errorf("failed to determine correct uid and gid for %s: %v", node.Path, err)
 	}
-	if err := os.Lchown(node.Path, uid, gid); err != nil {
+	if err := unix.Fchownat(dirfd, name, uid, gid, unix.AT_SYMLINK_NOFOLLOW); err != nil {
 		return fmt.Errorf("failed to change ownership of %s: %v", node.Path, err)
 	}
 	return nil
 }
 
+// SetTimestamps sets the access and modification times of the node at path
+// to atime and mtime, leaving either unchanged if it is nil.
+func SetTimestamps(path string, atime, mtime *time.Time) error {
+	if atime == nil && mtime == nil {
+		return nil
+	}
+	timespec := func(t *time.Time) unix.Timespec {
+		if t == nil {
+			return unix.Timespec{Nsec: unix.UTIME_OMIT}
+		}
+		return unix.NsecToTimespec(t.UnixNano())
+	}
+	ts := []unix.Timespec{timespec(atime), timespec(mtime)}
+	if err := unix.UtimesNanoAt(unix.AT_FDCWD, path, ts, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return fmt.Errorf("failed to set timestamps of %s: %v", path, err)
+	}
+	return nil
+}
+
 // PerformFetch performs a fetch operation generated by PrepareFetch, retrieving
-// the file and writing it to disk. Any encountered errors are returned.
-func (u Util) PerformFetch(f FetchOp) error {
+// the file and writing it to disk. Any encountered errors are returned. If ctx
+// is cancelled, the fetch is aborted and the temporary file removed, leaving
+// the path untouched.
+func (u Util) PerformFetch(ctx context.Context, f FetchOp) error {
 	path := f.Node.Path
 
-	if err := MkdirForFile(path); err != nil {
+	dirfd, name, err := u.openParent(path, true)
+	if err != nil {
 		return err
 	}
+	defer unix.Close(dirfd)
 
-	// Create a temporary file in the same directory to ensure it's on the same filesystem
-	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp")
+	if f.Append && f.Marker != "" {
+		found, err := fileContainsLine(path, f.Marker)
+		if err != nil {
+			return err
+		}
+		if found {
+			u.Info("marker %q already present in %q, skipping append", f.Marker, path)
+			return nil
+		}
+	}
+
+	tmp, err := u.createTempFile(path)
 	if err != nil {
 		return err
 	}
@@ -194,7 +351,7 @@ func (u Util) PerformFetch(f FetchOp) error {
 	// but that's ok (we wanted to keep the file in that case).
 	defer os.Remove(tmp.Name())
 
-	err = u.Fetcher.Fetch(f.Url, tmp, f.FetchOptions)
+	err = u.fetch(ctx, f, tmp)
 	if err != nil {
 		u.Crit("Error fetching file %q: %v", path, err)
 		return err
@@ -202,41 +359,161 @@ func (u Util) PerformFetch(f FetchOp) error {
 
 	if f.Append {
 		// Make sure that we're appending to a file
-		finfo, err := os.Lstat(path)
+		var st unix.Stat_t
+		err := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW)
 		switch {
-		case os.IsNotExist(err):
+		case err == unix.ENOENT:
 			// No problem, we'll create it.
 			break
 		case err != nil:
-			return err
+			return &os.PathError{Op: "lstat", Path: path, Err: err}
 		default:
-			if !finfo.Mode().IsRegular() {
+			if st.Mode&unix.S_IFMT != unix.S_IFREG {
 				return fmt.Errorf("can only append to files: %q", path)
 			}
 		}
 
 		// Open with the default permissions, we'll chown/chmod it later
-		targetFile, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, DefaultFilePermissions)
+		fd, err := unix.Openat(dirfd, name, unix.O_RDWR|unix.O_APPEND|unix.O_CREAT|unix.O_NOFOLLOW|unix.O_CLOEXEC, uint32(DefaultFilePermissions))
 		if err != nil {
-			return err
+			return &os.PathError{Op: "open", Path: path, Err: err}
 		}
+		targetFile := os.NewFile(uintptr(fd), path)
 		defer targetFile.Close()
 
+		if f.Marker != "" {
+			if err := ensureTrailingNewline(targetFile); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(targetFile, f.Marker+"\n"); err != nil {
+				return err
+			}
+		}
 		if _, err = tmp.Seek(0, os.SEEK_SET); err != nil {
 			return err
 		}
 		if _, err = io.Copy(targetFile, tmp); err != nil {
 			return err
 		}
+		if f.EndMarker != "" {
+			if err := ensureTrailingNewline(targetFile); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(targetFile, f.EndMarker+"\n"); err != nil {
+				return err
+			}
+		}
+	} else if f.Merge != "" {
+		if err := mergeIntoFile(path, tmp, f.Merge); err != nil {
+			return fmt.Errorf("merging %s into %q: %v", f.Merge, path, err)
+		}
 	} else {
-		if err = os.Rename(tmp.Name(), path); err != nil {
-			return err
+		if err = renameOrCopy(tmp, dirfd, name); err != nil {
+			return fmt.Errorf("moving contents into %q: %v", path, err)
 		}
 	}
 
 	return nil
 }
 
+// createTempFile creates a temporary file for the contents of path. It is
+// created in the same directory when possible, so it can be renamed over
+// path, and otherwise in the staging directory, e.g. when that filesystem is
+// full or read-only.
+func (u Util) createTempFile(path string) (*os.File, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp")
+	if err == nil {
+		return tmp, nil
+	}
+	dir := distro.StagingDir()
+	if mkErr := os.MkdirAll(dir, 0700); mkErr != nil {
+		return nil, err
+	}
+	tmp, stagingErr := ioutil.TempFile(dir, "tmp")
+	if stagingErr != nil {
+		// the original error is the interesting one
+		return nil, err
+	}
+	u.Info("couldn't create temporary file for %q (%v), staging it in %q", path, err, dir)
+	return tmp, nil
+}
+
+// renameOrCopy moves tmp to name in the directory dirfd. If they are on
+// different filesystems, the contents of tmp are copied there and synced
+// instead, which isn't atomic.
+func renameOrCopy(tmp *os.File, dirfd int, name string) error {
+	err := unix.Renameat(unix.AT_FDCWD, tmp.Name(), dirfd, name)
+	if err != unix.EXDEV {
+		return err
+	}
+
+	// replace rather than follow whatever is there, as rename would
+	var st unix.Stat_t
+	if err := unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err == nil && st.Mode&unix.S_IFMT != unix.S_IFREG {
+		if err := unix.Unlinkat(dirfd, name, 0); err != nil {
+			return err
+		}
+	}
+	fd, err := unix.Openat(dirfd, name, unix.O_WRONLY|unix.O_CREAT|unix.O_TRUNC|unix.O_NOFOLLOW|unix.O_CLOEXEC, uint32(DefaultFilePermissions))
+	if err != nil {
+		return err
+	}
+	target := os.NewFile(uintptr(fd), name)
+	defer target.Close()
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, tmp); err != nil {
+		return err
+	}
+	if err := target.Sync(); err != nil {
+		return err
+	}
+	return os.Remove(tmp.Name())
+}
+
+// fileContainsLine returns whether the file at path contains a line exactly
+// matching line. A nonexistent file contains no lines.
+func fileContainsLine(path, line string) (bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		if scanner.Text() == line {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// ensureTrailingNewline appends a newline to f if it is nonempty and does
+// not already end with one. f must be opened for reading and appending.
+func ensureTrailingNewline(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
 // MkdirForFile helper creates the directory components of path.
 func MkdirForFile(path string) error {
 	return os.MkdirAll(filepath.Dir(path), DefaultDirectoryPermissions)
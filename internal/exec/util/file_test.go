@@ -0,0 +1,210 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSymlinkTarget(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-symlink-target-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	// /usr/lib64 -> lib on the destination root
+	if err := os.MkdirAll(filepath.Join(td, "usr", "lib"), 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	if err := os.Symlink("lib", filepath.Join(td, "usr", "lib64")); err != nil {
+		t.Fatalf("symlink error: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		target string
+		style  *string
+		out    string
+	}{
+		{"/etc/foo", "/usr/lib64/foo", nil, "/usr/lib64/foo"},
+		{"/etc/foo", "/usr/lib64/foo", util.StrToPtr("literal"), "/usr/lib64/foo"},
+		{"/etc/foo", "/usr/lib64/foo", util.StrToPtr("absolute"), "/usr/lib/foo"},
+		{"/etc/foo", "../usr/lib64/foo", util.StrToPtr("absolute"), "/usr/lib/foo"},
+		{"/etc/foo", "/usr/lib64/foo", util.StrToPtr("relative"), "../usr/lib/foo"},
+		{"/usr/bin/foo", "/usr/bin/bar", util.StrToPtr("relative"), "bar"},
+	}
+
+	u := Util{DestDir: td}
+	for i, test := range tests {
+		out, err := u.SymlinkTarget(types.Link{
+			Node: types.Node{Path: filepath.Join(td, test.path)},
+			LinkEmbedded1: types.LinkEmbedded1{
+				Target:      test.target,
+				TargetStyle: test.style,
+			},
+		})
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("#%d: bad target: want %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestAppendMarkers(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-append-marker-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "file")
+	if err := ioutil.WriteFile(path, []byte("existing"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{
+		DestDir: td,
+		Logger:  &logger,
+		Fetcher: resource.Fetcher{Logger: &logger},
+	}
+
+	src, _ := url.Parse("data:,appended")
+	op := FetchOp{
+		Url:       *src,
+		Append:    true,
+		Node:      types.Node{Path: path},
+		Marker:    "# BEGIN ignition",
+		EndMarker: "# END ignition",
+	}
+	// the second append should be skipped since the marker already exists
+	for i := 0; i < 2; i++ {
+		if err := u.PerformFetch(context.Background(), op); err != nil {
+			t.Fatalf("append #%d failed: %v", i, err)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	expected := "existing\n# BEGIN ignition\nappended\n# END ignition\n"
+	if string(contents) != expected {
+		t.Errorf("bad contents: want %q, got %q", expected, string(contents))
+	}
+}
+
+func TestCreateTempFileStaging(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-staging-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	staging := filepath.Join(td, "staging")
+	os.Setenv("IGNITION_STAGING_DIR", staging)
+	defer os.Unsetenv("IGNITION_STAGING_DIR")
+
+	logger := log.New(true)
+	u := Util{DestDir: td, Logger: &logger}
+
+	// the directory of the target doesn't exist, so the temporary file
+	// can't be created beside it
+	tmp, err := u.createTempFile(filepath.Join(td, "missing", "file"))
+	if err != nil {
+		t.Fatalf("createTempFile failed: %v", err)
+	}
+	defer tmp.Close()
+	if filepath.Dir(tmp.Name()) != staging {
+		t.Errorf("temporary file %q not in staging directory %q", tmp.Name(), staging)
+	}
+
+	if _, err := tmp.WriteString("staged"); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	path := filepath.Join(td, "file")
+	dirfd, name, err := u.openParent(path, false)
+	if err != nil {
+		t.Fatalf("openParent failed: %v", err)
+	}
+	defer unix.Close(dirfd)
+	if err := renameOrCopy(tmp, dirfd, name); err != nil {
+		t.Fatalf("renameOrCopy failed: %v", err)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if string(contents) != "staged" {
+		t.Errorf("bad contents: want %q, got %q", "staged", string(contents))
+	}
+	if _, err := os.Stat(tmp.Name()); !os.IsNotExist(err) {
+		t.Errorf("temporary file %q still exists", tmp.Name())
+	}
+}
+
+func TestSetTimestamps(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-timestamps-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "file")
+	if err := ioutil.WriteFile(path, []byte("contents"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat error: %v", err)
+	}
+
+	// only the modification time is changed
+	mtime := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	if err := SetTimestamps(path, nil, &mtime); err != nil {
+		t.Fatalf("SetTimestamps failed: %v", err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat error: %v", err)
+	}
+	if !after.ModTime().Equal(mtime) {
+		t.Errorf("bad mtime: want %v, got %v", mtime, after.ModTime())
+	}
+	beforeAtime := before.Sys().(*syscall.Stat_t).Atim
+	afterAtime := after.Sys().(*syscall.Stat_t).Atim
+	if beforeAtime != afterAtime {
+		t.Errorf("atime changed: was %v, now %v", beforeAtime, afterAtime)
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// mergeIntoFile merges the contents of tmp, in the given format, into the
+// file at path and renames tmp over it. The existing file's owner and
+// mode are preserved. If no file exists at path, tmp is used as is.
+func mergeIntoFile(path string, tmp *os.File, format string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return os.Rename(tmp.Name(), path)
+	} else if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("can only merge into files")
+	}
+
+	existing, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	update, err := ioutil.ReadAll(tmp)
+	if err != nil {
+		return err
+	}
+	merged, err := mergeContents(format, existing, update)
+	if err != nil {
+		return err
+	}
+
+	if err := tmp.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := tmp.WriteAt(merged, 0); err != nil {
+		return err
+	}
+	uid, gid, _ := getFileOwnerAndMode(path)
+	// chown clears the setuid and setgid bits, so it goes first
+	if err := tmp.Chown(uid, gid); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// mergeContents merges update into existing, both in the given format.
+func mergeContents(format string, existing, update []byte) ([]byte, error) {
+	switch format {
+	case "json":
+		return mergeJSON(existing, update)
+	case "ini":
+		return mergeINI(existing, update, false)
+	case "toml":
+		return mergeINI(existing, update, true)
+	default:
+		return nil, fmt.Errorf("unknown merge format %q", format)
+	}
+}
+
+// mergeJSON deep-merges the update object into the existing one. Objects
+// are merged key by key; any other value in update replaces the existing
+// one. The result is re-serialized with sorted keys.
+func mergeJSON(existing, update []byte) ([]byte, error) {
+	var base, overlay interface{}
+	if len(bytes.TrimSpace(existing)) == 0 {
+		base = map[string]interface{}{}
+	} else if err := decodeJSON(existing, &base); err != nil {
+		return nil, fmt.Errorf("parsing existing file: %v", err)
+	}
+	if err := decodeJSON(update, &overlay); err != nil {
+		return nil, fmt.Errorf("parsing contents: %v", err)
+	}
+	out, err := json.MarshalIndent(mergeJSONValues(base, overlay), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// don't round-trip numbers through float64
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func mergeJSONValues(base, overlay interface{}) interface{} {
+	baseMap, ok := base.(map[string]interface{})
+	if !ok {
+		return overlay
+	}
+	overlayMap, ok := overlay.(map[string]interface{})
+	if !ok {
+		return overlay
+	}
+	for k, v := range overlayMap {
+		baseMap[k] = mergeJSONValues(baseMap[k], v)
+	}
+	return baseMap
+}
+
+// iniSection is a section of an INI or TOML file. The section with an
+// empty name holds the lines before the first section header.
+type iniSection struct {
+	name string
+	// lines including the header, if any
+	lines []string
+}
+
+// mergeINI merges the keys of update into existing, section by section.
+// Keys already present in a section are replaced in place, new keys are
+// added after the section's last key, and new sections are appended.
+// Comments and formatting in existing are preserved. If toml is set, only
+// the subset of TOML consisting of tables and single-line key/value pairs
+// is accepted.
+func mergeINI(existing, update []byte, toml bool) ([]byte, error) {
+	base, err := parseINI(existing, toml)
+	if err != nil {
+		return nil, fmt.Errorf("parsing existing file: %v", err)
+	}
+	overlay, err := parseINI(update, toml)
+	if err != nil {
+		return nil, fmt.Errorf("parsing contents: %v", err)
+	}
+
+	for _, sec := range overlay {
+		var target *iniSection
+		for i := range base {
+			if base[i].name == sec.name {
+				target = &base[i]
+				break
+			}
+		}
+		if target == nil {
+			if last := &base[len(base)-1]; len(last.lines) > 0 && strings.TrimSpace(last.lines[len(last.lines)-1]) != "" {
+				last.lines = append(last.lines, "")
+			}
+			base = append(base, iniSection{name: sec.name, lines: []string{sec.lines[0]}})
+			target = &base[len(base)-1]
+		}
+		for _, line := range sec.lines {
+			key, ok := iniKey(line)
+			if !ok {
+				continue
+			}
+			target.setKey(key, line)
+		}
+	}
+
+	var out []string
+	for _, sec := range base {
+		out = append(out, sec.lines...)
+	}
+	if len(out) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(strings.Join(out, "\n") + "\n"), nil
+}
+
+// setKey replaces the line setting key, or inserts line after the last key
+// in the section if there is none.
+func (s *iniSection) setKey(key, line string) {
+	insert := len(s.lines)
+	if s.name != "" {
+		// after the header
+		insert = 1
+	}
+	for i, l := range s.lines {
+		k, ok := iniKey(l)
+		if !ok {
+			continue
+		}
+		if k == key {
+			s.lines[i] = line
+			return
+		}
+		insert = i + 1
+	}
+	s.lines = append(s.lines, "")
+	copy(s.lines[insert+1:], s.lines[insert:])
+	s.lines[insert] = line
+}
+
+func parseINI(data []byte, toml bool) ([]iniSection, error) {
+	sections := []iniSection{{}}
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return sections, nil
+	}
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, ";"):
+		case toml && strings.HasPrefix(trimmed, "[["):
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", i+1)
+		case strings.HasPrefix(trimmed, "["):
+			end := strings.Index(trimmed, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section header", i+1)
+			}
+			name := strings.TrimSpace(trimmed[1:end])
+			sections = append(sections, iniSection{name: name})
+		case !strings.Contains(trimmed, "="):
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		case toml:
+			if err := checkTOMLValue(trimmed[strings.Index(trimmed, "=")+1:]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+		}
+		sec := &sections[len(sections)-1]
+		sec.lines = append(sec.lines, line)
+	}
+	return sections, nil
+}
+
+// iniKey returns the key set by line, if any.
+func iniKey(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "[") {
+		return "", false
+	}
+	i := strings.Index(trimmed, "=")
+	if i < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[:i]), true
+}
+
+// checkTOMLValue rejects values which may continue onto following lines.
+func checkTOMLValue(value string) error {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''") {
+		return fmt.Errorf("multi-line strings are not supported")
+	}
+	if strings.HasPrefix(value, "[") {
+		depth := 0
+		var quote rune
+		escaped := false
+	scan:
+		for _, r := range value {
+			switch {
+			case escaped:
+				escaped = false
+			case quote == '"' && r == '\\':
+				escaped = true
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '"' || r == '\'':
+				quote = r
+			case r == '#':
+				break scan
+			case r == '[':
+				depth++
+			case r == ']':
+				depth--
+			}
+		}
+		if depth != 0 {
+			return fmt.Errorf("multi-line arrays are not supported")
+		}
+	}
+	return nil
+}
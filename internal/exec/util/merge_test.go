@@ -0,0 +1,147 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeContents(t *testing.T) {
+	tests := []struct {
+		format   string
+		existing string
+		update   string
+		out      string
+	}{
+		// json: deep merge
+		{
+			"json",
+			`{"a": {"b": 1, "c": [1, 2]}, "d": "x"}`,
+			`{"a": {"c": [3], "e": 12345678901234567890}}`,
+			"{\n  \"a\": {\n    \"b\": 1,\n    \"c\": [\n      3\n    ],\n    \"e\": 12345678901234567890\n  },\n  \"d\": \"x\"\n}\n",
+		},
+		// json: empty existing file
+		{
+			"json",
+			"",
+			`{"a": 1}`,
+			"{\n  \"a\": 1\n}\n",
+		},
+		// ini: replace, add key, add section, keep comments
+		{
+			"ini",
+			"# global\nx=1\n\n[engine]\n; comment\ncgroup_manager = \"cgroupfs\"\n\n[network]\nnetwork_backend = \"cni\"\n",
+			"[engine]\ncgroup_manager = \"systemd\"\nevents_logger = \"journald\"\n[machine]\ncpus = 2\n",
+			"# global\nx=1\n\n[engine]\n; comment\ncgroup_manager = \"systemd\"\nevents_logger = \"journald\"\n\n[network]\nnetwork_backend = \"cni\"\n\n[machine]\ncpus = 2\n",
+		},
+		// ini: global keys
+		{
+			"ini",
+			"a=1\n[s]\nb=2\n",
+			"a=3\nc=4\n",
+			"a=3\nc=4\n[s]\nb=2\n",
+		},
+		// toml: arrays on one line are fine
+		{
+			"toml",
+			"[registries.search]\nregistries = ['docker.io']\n",
+			"[registries.search]\nregistries = ['quay.io', 'docker.io'] # [\n",
+			"[registries.search]\nregistries = ['quay.io', 'docker.io'] # [\n",
+		},
+		// toml: empty existing file
+		{
+			"toml",
+			"",
+			"[a]\nb = 1\n",
+			"[a]\nb = 1\n",
+		},
+	}
+
+	for i, test := range tests {
+		out, err := mergeContents(test.format, []byte(test.existing), []byte(test.update))
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("#%d: bad output: want %q, got %q", i, test.out, string(out))
+		}
+	}
+}
+
+func TestMergeContentsInvalid(t *testing.T) {
+	tests := []struct {
+		format string
+		update string
+	}{
+		{"json", "{"},
+		{"ini", "[section\n"},
+		{"ini", "novalue\n"},
+		{"toml", "[[servers]]\nname = 'a'\n"},
+		{"toml", "a = \"\"\"\nb\n\"\"\"\n"},
+		{"toml", "a = [\n  1,\n]\n"},
+	}
+
+	for i, test := range tests {
+		if _, err := mergeContents(test.format, nil, []byte(test.update)); err == nil {
+			t.Errorf("#%d: expected error", i)
+		}
+	}
+}
+
+func TestMergeIntoFileKeepsMode(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-merge-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	tests := []os.FileMode{
+		0755 | os.ModeSetuid,
+		0750 | os.ModeSetgid,
+		0644 | os.ModeSticky,
+	}
+	for i, mode := range tests {
+		path := filepath.Join(td, "file.json")
+		if err := ioutil.WriteFile(path, []byte(`{"a": 1}`), 0600); err != nil {
+			t.Fatalf("#%d: write error: %v", i, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("#%d: chmod error: %v", i, err)
+		}
+		tmp, err := ioutil.TempFile(td, "tmp")
+		if err != nil {
+			t.Fatalf("#%d: tempfile error: %v", i, err)
+		}
+		if _, err := tmp.Write([]byte(`{"b": 2}`)); err != nil {
+			t.Fatalf("#%d: write error: %v", i, err)
+		}
+		err = mergeIntoFile(path, tmp, "json")
+		tmp.Close()
+		if err != nil {
+			t.Fatalf("#%d: merge error: %v", i, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("#%d: stat error: %v", i, err)
+		}
+		if info.Mode() != mode {
+			t.Errorf("#%d: expected mode %v, got %v", i, mode, info.Mode())
+		}
+	}
+}
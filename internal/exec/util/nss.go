@@ -0,0 +1,187 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// userLookup looks up the user in u.DestDir. The target's own passwd files
+// are read directly, so users created by Ignition are found, and the
+// initramfs's NSS configuration plays no part. Only if the user isn't there
+// and the target's nsswitch.conf lists other sources is NSS consulted.
+func (u Util) userLookup(name string) (*user.User, error) {
+	fields, err := u.filesLookup("passwd", name)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		sources := u.otherNssSources("passwd")
+		if len(sources) == 0 {
+			return nil, user.UnknownUserError(fmt.Sprintf("user %q not found", name))
+		}
+		usr, err := u.nssUserLookup(name)
+		if _, ok := err.(user.UnknownUserError); ok {
+			u.Info("user %q not found in the target's passwd files or through NSS sources %s, which may not be available during provisioning", name, strings.Join(sources, ", "))
+		} else if err != nil {
+			return nil, fmt.Errorf("user %q isn't in the target's passwd files, and looking it up through NSS sources %s failed: %v", name, strings.Join(sources, ", "), err)
+		}
+		return usr, err
+	}
+	if len(fields) < 6 {
+		return nil, fmt.Errorf("malformed passwd entry for user %q", name)
+	}
+
+	homedir, err := u.JoinPath(fields[5])
+	if err != nil {
+		return nil, err
+	}
+	return &user.User{
+		Name:    fields[0],
+		Uid:     fields[2],
+		Gid:     fields[3],
+		HomeDir: homedir,
+	}, nil
+}
+
+// groupLookup looks up the group in u.DestDir, like userLookup.
+func (u Util) groupLookup(name string) (*user.Group, error) {
+	fields, err := u.filesLookup("group", name)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		sources := u.otherNssSources("group")
+		if len(sources) == 0 {
+			return nil, user.UnknownGroupError(fmt.Sprintf("group %q not found", name))
+		}
+		grp, err := u.nssGroupLookup(name)
+		if _, ok := err.(user.UnknownGroupError); ok {
+			u.Info("group %q not found in the target's group files or through NSS sources %s, which may not be available during provisioning", name, strings.Join(sources, ", "))
+		} else if err != nil {
+			return nil, fmt.Errorf("group %q isn't in the target's group files, and looking it up through NSS sources %s failed: %v", name, strings.Join(sources, ", "), err)
+		}
+		return grp, err
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("malformed group entry for group %q", name)
+	}
+
+	return &user.Group{
+		Name: fields[0],
+		Gid:  fields[2],
+	}, nil
+}
+
+// filesLookup returns the fields of the entry for name in the target's
+// database db, i.e. /etc/passwd or /etc/group, and also in the copy under
+// /usr/lib if the target uses nss-altfiles. It returns nil if there is no
+// such entry.
+func (u Util) filesLookup(db, name string) ([]string, error) {
+	paths := []string{filepath.Join("/etc", db)}
+	for _, source := range u.nssSources(db) {
+		if source == "altfiles" {
+			paths = append(paths, filepath.Join("/usr/lib", db))
+		}
+	}
+
+	for _, path := range paths {
+		f, err := os.Open(filepath.Join(u.DestDir, path))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		fields, err := findEntry(f, name)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", path, err)
+		}
+		if fields != nil {
+			return fields, nil
+		}
+	}
+	return nil, nil
+}
+
+// findEntry returns the fields of the first line of a colon-separated
+// database whose first field is name.
+func findEntry(f *os.File, name string) ([]string, error) {
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// skip comments and NIS compat entries
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if fields[0] == name {
+			return fields, nil
+		}
+	}
+	return nil, scanner.Err()
+}
+
+// nssSources returns the sources of the database db listed in the target's
+// nsswitch.conf, or "files", glibc's default, if it doesn't list any.
+func (u Util) nssSources(db string) []string {
+	f, err := os.Open(filepath.Join(u.DestDir, "/etc/nsswitch.conf"))
+	if err != nil {
+		return []string{"files"}
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != db {
+			continue
+		}
+		sources := []string{}
+		for _, source := range strings.Fields(parts[1]) {
+			// skip actions like [NOTFOUND=return]
+			if !strings.HasPrefix(source, "[") {
+				sources = append(sources, source)
+			}
+		}
+		if len(sources) > 0 {
+			return sources
+		}
+	}
+	return []string{"files"}
+}
+
+// otherNssSources returns the sources of db in the target's nsswitch.conf
+// which filesLookup doesn't read itself.
+func (u Util) otherNssSources(db string) []string {
+	others := []string{}
+	for _, source := range u.nssSources(db) {
+		switch source {
+		case "files", "altfiles", "compat":
+		default:
+			others = append(others, source)
+		}
+	}
+	return others
+}
@@ -15,6 +15,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"os/user"
@@ -44,12 +45,18 @@ func appendIfStringSet(args []string, arg string, str *string) []string {
 
 // EnsureUser ensures that the user exists as described. If the user does not
 // yet exist, they will be created, otherwise the existing user will be
-// modified.
-func (u Util) EnsureUser(c types.PasswdUser) error {
+// modified. The commands run are killed if ctx is cancelled.
+func (u Util) EnsureUser(ctx context.Context, c types.PasswdUser) error {
 	exists, err := u.CheckIfUserExists(c)
 	if err != nil {
 		return err
 	}
+	if c.Existing != nil && *c.Existing {
+		if !exists {
+			return fmt.Errorf("user %q is marked existing but doesn't exist", c.Name)
+		}
+		return u.addUserToGroups(ctx, c)
+	}
 	args := []string{"--root", u.DestDir}
 
 	var cmd string
@@ -103,11 +110,24 @@ func (u Util) EnsureUser(c types.PasswdUser) error {
 
 	args = append(args, c.Name)
 
-	_, err = u.LogCmd(exec.Command(cmd, args...),
+	_, err = u.LogCmd(exec.CommandContext(ctx, cmd, args...),
 		"creating or modifying user %q", c.Name)
 	return err
 }
 
+// addUserToGroups adds the existing user c to c.Groups, keeping the groups
+// it's already a member of.
+func (u Util) addUserToGroups(ctx context.Context, c types.PasswdUser) error {
+	if len(c.Groups) == 0 {
+		return nil
+	}
+	groups := strings.Join(translateV2_1PasswdUserGroupSliceToStringSlice(c.Groups), ",")
+	args := []string{"--root", u.DestDir, "--append", "--groups", groups, c.Name}
+	_, err := u.LogCmd(exec.CommandContext(ctx, distro.UsermodCmd(), args...),
+		"adding user %q to groups %s", c.Name, groups)
+	return err
+}
+
 // CheckIfUserExists will return Info log when user is empty
 func (u Util) CheckIfUserExists(c types.PasswdUser) (bool, error) {
 	_, err := u.userLookup(c.Name)
@@ -195,7 +215,7 @@ func translateV2_1SSHAuthorizedKeySliceToStringSlice(keys []types.SSHAuthorizedK
 }
 
 // SetPasswordHash sets the password hash of the specified user.
-func (u Util) SetPasswordHash(c types.PasswdUser) error {
+func (u Util) SetPasswordHash(ctx context.Context, c types.PasswdUser) error {
 	if c.PasswordHash == nil {
 		return nil
 	}
@@ -212,13 +232,13 @@ func (u Util) SetPasswordHash(c types.PasswdUser) error {
 
 	args = append(args, c.Name)
 
-	_, err := u.LogCmd(exec.Command(distro.UsermodCmd(), args...),
+	_, err := u.LogCmd(exec.CommandContext(ctx, distro.UsermodCmd(), args...),
 		"setting password for %q", c.Name)
 	return err
 }
 
 // CreateGroup creates the group as described.
-func (u Util) CreateGroup(g types.PasswdGroup) error {
+func (u Util) CreateGroup(ctx context.Context, g types.PasswdGroup) error {
 	args := []string{"--root", u.DestDir}
 
 	if g.Gid != nil {
@@ -236,7 +256,7 @@ func (u Util) CreateGroup(g types.PasswdGroup) error {
 
 	args = append(args, g.Name)
 
-	_, err := u.LogCmd(exec.Command(distro.GroupaddCmd(), args...),
+	_, err := u.LogCmd(exec.CommandContext(ctx, distro.GroupaddCmd(), args...),
 		"adding group %q", g.Name)
 	return err
 }
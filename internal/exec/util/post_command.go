@@ -0,0 +1,65 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+// defaultPostCommandTimeout bounds post commands which don't set a timeout.
+const defaultPostCommandTimeout = 60 * time.Second
+
+// postCommandPath is the only environment post commands get, so that they
+// don't depend on Ignition's.
+const postCommandPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// RunPostCommand runs the post command of the file at path in the target
+// root, killing it if it runs longer than its timeout.
+func (u Util) RunPostCommand(ctx context.Context, path string, pc types.PostCommand) error {
+	timeout := defaultPostCommandTimeout
+	if pc.Timeout != nil {
+		timeout = time.Duration(*pc.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := u.LogCmd(u.postCommand(ctx, pc), "running post command of %q", path)
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("post command of %q timed out after %v", path, timeout)
+	} else if err != nil {
+		return fmt.Errorf("post command of %q failed: %v", path, err)
+	}
+	return nil
+}
+
+// postCommand returns the command running pc chrooted into the target, with
+// a network namespace of its own, which has nothing but a loopback device
+// which is down, and a PID namespace of its own, so that killing it when it
+// times out kills everything it started too.
+func (u Util) postCommand(ctx context.Context, pc types.PostCommand) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, distro.ChrootCmd(), append([]string{u.DestDir}, pc.Args...)...)
+	cmd.Env = []string{postCommandPath}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNET | syscall.CLONE_NEWPID,
+	}
+	return cmd
+}
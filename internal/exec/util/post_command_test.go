@@ -0,0 +1,41 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+func TestPostCommand(t *testing.T) {
+	u := Util{DestDir: "/sysroot"}
+	cmd := u.postCommand(context.Background(), types.PostCommand{Args: []string{"/usr/sbin/depmod", "-a"}})
+
+	if expected := []string{distro.ChrootCmd(), "/sysroot", "/usr/sbin/depmod", "-a"}; !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("bad args: want %q, got %q", expected, cmd.Args)
+	}
+	// nothing is inherited from Ignition's environment
+	if expected := []string{postCommandPath}; !reflect.DeepEqual(cmd.Env, expected) {
+		t.Errorf("bad environment: want %q, got %q", expected, cmd.Env)
+	}
+	if flags := cmd.SysProcAttr.Cloneflags; flags&syscall.CLONE_NEWNET == 0 || flags&syscall.CLONE_NEWPID == 0 {
+		t.Errorf("command doesn't get its own network and PID namespaces: flags %#x", flags)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// maxSymlinks matches the kernel's limit on symlinks followed while
+	// resolving a single path
+	maxSymlinks = 40
+
+	resolveNoMagiclinks = 0x02
+	resolveInRoot       = 0x10
+)
+
+// openHow is struct open_how from linux/openat2.h.
+type openHow struct {
+	flags   uint64
+	mode    uint64
+	resolve uint64
+}
+
+// resolveInRoot resolves the symlinks in the directory formed by components
+// as if u.DestDir were the root directory, and returns the resulting path
+// relative to u.DestDir. The kernel resolves the path with openat2 when it
+// supports it and the directory exists; otherwise it is resolved in
+// userspace.
+func (u Util) resolveInRoot(components []string) (string, error) {
+	if len(components) == 0 {
+		return "/", nil
+	}
+	if realpath, err := u.resolveWithOpenat2(filepath.Join(components...)); err == nil {
+		return realpath, nil
+	}
+	return u.resolveInUserspace(components)
+}
+
+// resolveWithOpenat2 opens path beneath u.DestDir with RESOLVE_IN_ROOT and
+// returns where it ended up.
+func (u Util) resolveWithOpenat2(path string) (string, error) {
+	root, err := unix.Open(u.DestDir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return "", err
+	}
+	defer unix.Close(root)
+
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+	how := openHow{
+		flags:   unix.O_PATH | unix.O_DIRECTORY | unix.O_CLOEXEC,
+		resolve: resolveInRoot | resolveNoMagiclinks,
+	}
+	fd, _, errno := unix.Syscall6(sysOpenat2, uintptr(root), uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&how)), unsafe.Sizeof(how), 0, 0)
+	if errno != 0 {
+		return "", errno
+	}
+	defer unix.Close(int(fd))
+
+	// compare the paths the kernel reports for both, so that symlinks in
+	// u.DestDir itself don't matter
+	rootPath, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", root))
+	if err != nil {
+		return "", err
+	}
+	resolved, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(rootPath, resolved)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", errEscapedMountpoint
+	}
+	return filepath.Join("/", rel), nil
+}
+
+// resolveInUserspace is resolveInRoot for kernels without openat2 and for
+// directories which don't exist yet. Nonexistent components are taken as
+// they are, since they will be created as directories.
+func (u Util) resolveInUserspace(components []string) (string, error) {
+	realpath := "/"
+	links := 0
+	for len(components) > 0 {
+		component := components[0]
+		components = components[1:]
+
+		// realpath never contains symlinks, so ".." can be resolved
+		// lexically, and never goes above the root
+		tmp := filepath.Join(realpath, component)
+		s, err := os.Lstat(filepath.Join(u.DestDir, tmp))
+		if os.IsNotExist(err) {
+			realpath = tmp
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		if s.Mode()&os.ModeSymlink == 0 {
+			realpath = tmp
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", errTooManySymlinks
+		}
+		symlinkPath, err := os.Readlink(filepath.Join(u.DestDir, tmp))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(symlinkPath) {
+			realpath = "/"
+		}
+		// the target may itself contain symlinks, so resolve it
+		// component by component too
+		components = append(SplitPath(symlinkPath), components...)
+	}
+	return realpath, nil
+}
@@ -0,0 +1,22 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !mips,!mipsle,!mips64,!mips64le
+
+package util
+
+// openat2(2) isn't in the vendored x/sys yet. Architectures using the unified
+// syscall table, which is all of those Go supports except MIPS, share its
+// number.
+const sysOpenat2 = 437
@@ -0,0 +1,92 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJoinPath(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-join-path-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	if err := os.MkdirAll(filepath.Join(td, "etc", "ssh"), 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	links := map[string]string{
+		"abs":      "/etc",
+		"rel":      "../../../../etc",
+		"chain":    "/abs/ssh",
+		"indirect": "/hop",
+		"hop":      "/", // must not refer to the host's root
+		"loop":     "/loop/x",
+		"last":     "/etc",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(td, name)); err != nil {
+			t.Fatalf("symlink error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		in  string
+		out string
+		err error
+	}{
+		{"/etc/motd", "/etc/motd", nil},
+		{"/abs/motd", "/etc/motd", nil},
+		{"/rel/motd", "/etc/motd", nil},
+		{"/chain/sshd_config", "/etc/ssh/sshd_config", nil},
+		{"/indirect/etc/motd", "/etc/motd", nil},
+		{"/missing/dir/file", "/missing/dir/file", nil},
+		{"/last", "/last", nil},
+		{"/..", "/", nil},
+		{"/loop/file", "", errTooManySymlinks},
+	}
+
+	u := Util{DestDir: td}
+	for i, test := range tests {
+		out, err := u.JoinPath(test.in)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if test.err == nil && out != filepath.Join(td, test.out) {
+			t.Errorf("#%d: bad path: want %q, got %q", i, filepath.Join(td, test.out), out)
+		}
+
+	}
+
+	// the fallback must agree with openat2 wherever both work
+	for _, dir := range []string{"/abs", "/rel", "/chain", "/indirect/etc/ssh"} {
+		kernel, err := u.resolveWithOpenat2(dir)
+		if err != nil {
+			t.Logf("openat2 unavailable: %v", err)
+			break
+		}
+		userspace, err := u.resolveInUserspace(SplitPath(dir))
+		if err != nil {
+			t.Errorf("%s: userspace resolution failed: %v", dir, err)
+		} else if kernel != userspace {
+			t.Errorf("%s: openat2 resolved to %q, userspace to %q", dir, kernel, userspace)
+		}
+	}
+}
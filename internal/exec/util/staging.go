@@ -0,0 +1,80 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/coreos/ignition/v2/internal/failure"
+)
+
+// StageFetch fetches and verifies the contents described by op into a new
+// file in dir and records it in u.Staged, so that a later PerformFetch of
+// the same contents copies the staged file instead of fetching it again.
+func (u Util) StageFetch(ctx context.Context, op FetchOp, dir string) error {
+	key := stagingKey(op)
+	if _, ok := u.Staged[key]; ok {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(dir, "staged")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+
+	if err := u.Fetcher.Fetch(ctx, op.Url, tmp, op.FetchOptions); err != nil {
+		os.Remove(tmp.Name())
+		return failure.Wrap(failure.ErrFetchFailed, err)
+	}
+	u.Staged[key] = tmp.Name()
+	return nil
+}
+
+// fetch writes the contents described by op to dest, from the staged copy if
+// there is one.
+func (u Util) fetch(ctx context.Context, op FetchOp, dest *os.File) error {
+	staged, ok := u.Staged[stagingKey(op)]
+	if !ok {
+		return failure.Wrap(failure.ErrFetchFailed, u.Fetcher.Fetch(ctx, op.Url, dest, op.FetchOptions))
+	}
+
+	src, err := os.Open(staged)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(dest, src)
+	return err
+}
+
+// stagingKey identifies the fetched contents of op. Ops with the same source,
+// encryption, compression, and expected hash produce the same contents.
+func stagingKey(op FetchOp) string {
+	key := op.Url.String() + "\x00" + op.FetchOptions.Compression + "\x00" + hex.EncodeToString(op.FetchOptions.ExpectedSum)
+	// the encrypted data key determines the key the contents are sealed with
+	if dataKey := op.FetchOptions.Encryption.DataKey; dataKey != nil {
+		key += "\x00" + *dataKey
+	}
+	// and the result of a patch depends on the file it patches
+	if op.FetchOptions.PatchFrom != "" {
+		key += "\x00" + op.FetchOptions.PatchFrom
+	}
+	return key
+}
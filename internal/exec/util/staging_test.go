@@ -0,0 +1,82 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestStageFetch(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-stage-fetch-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := Util{
+		DestDir: td,
+		Logger:  &logger,
+		Fetcher: resource.Fetcher{Logger: &logger},
+		Staged:  map[string]string{},
+	}
+
+	src, _ := url.Parse("data:,staged")
+	op := FetchOp{
+		Url:  *src,
+		Node: types.Node{Path: filepath.Join(td, "file")},
+	}
+	if err := u.StageFetch(context.Background(), op, td); err != nil {
+		t.Fatalf("staging failed: %v", err)
+	}
+	staged, ok := u.Staged[stagingKey(op)]
+	if !ok {
+		t.Fatalf("contents weren't staged")
+	}
+
+	// PerformFetch must copy the staged file rather than fetching again
+	if err := ioutil.WriteFile(staged, []byte("from staging"), 0600); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := u.PerformFetch(context.Background(), op); err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	contents, err := ioutil.ReadFile(op.Node.Path)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if string(contents) != "from staging" {
+		t.Errorf("bad contents: want %q, got %q", "from staging", string(contents))
+	}
+
+	// failed fetches leave nothing behind
+	bad, _ := url.Parse("data:;base64,!!!")
+	if err := u.StageFetch(context.Background(), FetchOp{Url: *bad}, td); err == nil {
+		t.Errorf("staging invalid contents succeeded")
+	}
+	if len(u.Staged) != 1 {
+		t.Errorf("failed fetch was staged: %v", u.Staged)
+	}
+}
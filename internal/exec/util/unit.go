@@ -24,6 +24,7 @@ import (
 	"github.com/coreos/ignition/v2/internal/distro"
 
 	"github.com/vincent-petithory/dataurl"
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -97,14 +98,16 @@ func (ut Util) MaskUnit(unit types.Unit) (string, error) {
 		return "", err
 	}
 
-	if err := MkdirForFile(path); err != nil {
+	dirfd, name, err := ut.openParent(path, true)
+	if err != nil {
 		return "", err
 	}
+	defer unix.Close(dirfd)
 	if err := os.RemoveAll(path); err != nil {
 		return "", err
 	}
-	if err := os.Symlink("/dev/null", path); err != nil {
-		return "", err
+	if err := unix.Symlinkat("/dev/null", dirfd, name); err != nil {
+		return "", &os.LinkError{Op: "symlink", Old: "/dev/null", New: path, Err: err}
 	}
 	// not the same as the path above, since this lacks the sysroot prefix
 	return filepath.Join("/", SystemdUnitsPath(), unit.Name), nil
@@ -155,10 +158,7 @@ func (ut Util) appendLineToPreset(data string) error {
 		return err
 	}
 
-	if err := MkdirForFile(path); err != nil {
-		return err
-	}
-	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, DefaultPresetPermissions)
+	file, err := ut.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, DefaultPresetPermissions)
 	if err != nil {
 		return err
 	}
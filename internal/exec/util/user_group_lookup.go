@@ -27,8 +27,8 @@ import (
 	"os/user"
 )
 
-// userLookup looks up the user in u.DestDir.
-func (u Util) userLookup(name string) (*user.User, error) {
+// nssUserLookup looks up the user in u.DestDir through NSS, in a chroot.
+func (u Util) nssUserLookup(name string) (*user.User, error) {
 	res := &C.lookup_res_t{}
 
 	if ret, err := C.user_lookup(C.CString(u.DestDir),
@@ -57,8 +57,8 @@ func (u Util) userLookup(name string) (*user.User, error) {
 	return usr, nil
 }
 
-// groupLookup looks up the group in u.DestDir.
-func (u Util) groupLookup(name string) (*user.Group, error) {
+// nssGroupLookup looks up the group in u.DestDir through NSS, in a chroot.
+func (u Util) nssGroupLookup(name string) (*user.Group, error) {
 	res := &C.lookup_res_t{}
 
 	if ret, err := C.group_lookup(C.CString(u.DestDir),
@@ -19,6 +19,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/coreos/ignition/v2/internal/log"
@@ -129,3 +130,87 @@ func TestGroupLookup(t *testing.T) {
 		t.Fatalf("unexpected gid: %q", grp.Gid)
 	}
 }
+
+func TestFilesLookup(t *testing.T) {
+	td, err := tempBase()
+	if err != nil {
+		t.Fatalf("temp base error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	// users and groups shipped in /usr/lib are only found with altfiles
+	if err := os.MkdirAll(filepath.Join(td, "usr/lib"), 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "usr/lib/passwd"), []byte("# system users\ncore:x:1000:1000::/var/home/core:/bin/bash\n"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "usr/lib/group"), []byte("core:x:1000:\n"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	u := &Util{
+		DestDir: td,
+		Logger:  &logger,
+	}
+
+	if _, err := u.userLookup("core"); err == nil {
+		t.Errorf("found user in /usr/lib/passwd without altfiles")
+	} else if _, ok := err.(user.UnknownUserError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	nsp := filepath.Join(td, "etc/nsswitch.conf")
+	if err := ioutil.WriteFile(nsp, []byte("passwd: files altfiles [NOTFOUND=return]\ngroup:  files altfiles # comment\n"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	usr, err := u.userLookup("core")
+	if err != nil {
+		t.Fatalf("lookup error: %v", err)
+	}
+	if usr.Uid != "1000" || usr.HomeDir != filepath.Join(td, "var/home/core") {
+		t.Errorf("unexpected user: %+v", usr)
+	}
+	if grp, err := u.groupLookup("core"); err != nil {
+		t.Errorf("lookup error: %v", err)
+	} else if grp.Gid != "1000" {
+		t.Errorf("unexpected gid: %q", grp.Gid)
+	}
+
+	// entries in /etc take precedence
+	if usr, err := u.userLookup("foo"); err != nil {
+		t.Errorf("lookup error: %v", err)
+	} else if usr.Uid != "44" {
+		t.Errorf("unexpected uid: %q", usr.Uid)
+	}
+}
+
+func TestNssSources(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-nss-sources-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	u := Util{DestDir: td}
+
+	// no nsswitch.conf means files
+	if sources := u.otherNssSources("passwd"); len(sources) != 0 {
+		t.Errorf("unexpected sources without nsswitch.conf: %v", sources)
+	}
+
+	if err := os.MkdirAll(filepath.Join(td, "etc"), 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	conf := "# passwd: ldap\npasswd: files sss systemd\ngroup: compat\n"
+	if err := ioutil.WriteFile(filepath.Join(td, "etc/nsswitch.conf"), []byte(conf), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if sources := u.otherNssSources("passwd"); !reflect.DeepEqual(sources, []string{"sss", "systemd"}) {
+		t.Errorf("unexpected passwd sources: %v", sources)
+	}
+	if sources := u.otherNssSources("group"); len(sources) != 0 {
+		t.Errorf("unexpected group sources: %v", sources)
+	}
+}
@@ -16,7 +16,6 @@ package util
 
 import (
 	"errors"
-	"os"
 	"path/filepath"
 
 	"github.com/coreos/ignition/v2/internal/log"
@@ -25,6 +24,7 @@ import (
 
 var (
 	errEscapedMountpoint = errors.New("Symlink traversal resulted in path outside of filesystem")
+	errTooManySymlinks   = errors.New("too many levels of symbolic links")
 )
 
 // Util encapsulates logging and destdir indirection for the util methods.
@@ -32,6 +32,10 @@ type Util struct {
 	DestDir string // directory prefix to use in applying fs paths.
 	Fetcher resource.Fetcher
 	*log.Logger
+
+	// Staged maps contents fetched ahead of time by StageFetch to the
+	// files holding them. PerformFetch uses the staged copies when present.
+	Staged map[string]string
 }
 
 // SplitPath splits /a/b/c/d into [a, b, c, d]
@@ -52,7 +56,8 @@ func wantsToEscape(p string) bool {
 
 // JoinPath returns a path into the context ala filepath.Join(d, args)
 // It resolves symlinks as if they were rooted at u.DestDir. This means
-// that the resulting path will always be under u.DestDir.
+// that the resulting path will always be under u.DestDir, even if symlinks
+// already present there point outside it.
 // The last element of the path is never followed.
 func (u Util) JoinPath(path ...string) (string, error) {
 	components := []string{}
@@ -61,32 +66,15 @@ func (u Util) JoinPath(path ...string) (string, error) {
 	}
 	last := components[len(components)-1]
 	components = components[:len(components)-1]
-
-	realpath := "/"
-	for _, component := range components {
-		tmp := filepath.Join(realpath, component)
-		s, err := os.Lstat(filepath.Join(u.DestDir, tmp))
-		if os.IsNotExist(err) {
-			realpath = tmp
-			continue
-		} else if err != nil {
-			return "", err
-		}
-
-		if s.Mode()&os.ModeSymlink == 0 {
-			realpath = tmp
-			continue
-		}
-
-		symlinkPath, err := os.Readlink(filepath.Join(u.DestDir, tmp))
-		if err != nil {
-			return "", err
-		}
-		if filepath.IsAbs(symlinkPath) {
-			realpath = "/"
-		}
-		realpath = filepath.Join(realpath, symlinkPath)
+	if last == "." || last == ".." {
+		// not a name, so there's nothing to avoid following
+		components = append(components, last)
+		last = ""
 	}
 
+	realpath, err := u.resolveInRoot(components)
+	if err != nil {
+		return "", err
+	}
 	return filepath.Join(u.DestDir, realpath, last), nil
 }
@@ -0,0 +1,117 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package failure classifies the errors which make Ignition fail, so that
+// its exit status tells the units running it and provisioning tools what
+// kind of failure occurred. An error is classified where it's caused, with
+// Wrap or by implementing Classified, and keeps its class as it's returned
+// up the stack if it's wrapped with Errorf rather than fmt.Errorf.
+package failure
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The classes of failure. An error of one of these classes is either one of
+// them or, more usually, an Error whose Class is one of them.
+var (
+	ErrConfigInvalid      = errors.New("config is invalid")
+	ErrFetchFailed        = errors.New("fetch failed")
+	ErrVerificationFailed = errors.New("verification failed")
+	ErrDeviceMissing      = errors.New("device is missing")
+)
+
+// Exit statuses. 2 and 3 are used for invalid flags and a failure to set up
+// fetching.
+const (
+	ExitOther              = 1
+	ExitConfigInvalid      = 4
+	ExitFetchFailed        = 5
+	ExitVerificationFailed = 6
+	ExitDeviceMissing      = 7
+)
+
+var exitCodes = map[error]int{
+	ErrConfigInvalid:      ExitConfigInvalid,
+	ErrFetchFailed:        ExitFetchFailed,
+	ErrVerificationFailed: ExitVerificationFailed,
+	ErrDeviceMissing:      ExitDeviceMissing,
+}
+
+// Classified is implemented by errors which know their class, such as the
+// error for a hash mismatch.
+type Classified interface {
+	Class() error
+}
+
+// Error is an error of a known class.
+type Error struct {
+	// Class is one of the classes of failure
+	Class error
+	Err   error
+}
+
+func (e Error) Error() string {
+	return e.Err.Error()
+}
+
+// Classify returns the class of err, or nil if it has none.
+func Classify(err error) error {
+	if _, ok := exitCodes[err]; ok {
+		return err
+	}
+	switch e := err.(type) {
+	case Error:
+		return e.Class
+	case *Error:
+		return e.Class
+	case Classified:
+		return e.Class()
+	}
+	return nil
+}
+
+// Wrap returns err as an error of class, unless it's nil or already has a
+// class, which is kept since it's more specific; a hash mismatch found
+// while fetching is a verification failure rather than a fetch failure.
+func Wrap(class, err error) error {
+	if err == nil || Classify(err) != nil {
+		return err
+	}
+	return Error{Class: class, Err: err}
+}
+
+// Errorf formats an error as fmt.Errorf does. The error has the class of the
+// first of a which is a classified error, if any.
+func Errorf(format string, a ...interface{}) error {
+	err := fmt.Errorf(format, a...)
+	for _, arg := range a {
+		if e, ok := arg.(error); ok {
+			if class := Classify(e); class != nil {
+				return Error{Class: class, Err: err}
+			}
+		}
+	}
+	return err
+}
+
+// ExitCode returns the status with which Ignition exits when it fails with
+// err.
+func ExitCode(err error) int {
+	if code, ok := exitCodes[Classify(err)]; ok {
+		return code
+	}
+	return ExitOther
+}
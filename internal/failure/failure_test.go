@@ -0,0 +1,85 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failure
+
+import (
+	"errors"
+	"testing"
+)
+
+type mismatch struct{}
+
+func (mismatch) Error() string { return "hash mismatch" }
+func (mismatch) Class() error  { return ErrVerificationFailed }
+
+func TestExitCode(t *testing.T) {
+	cause := errors.New("connection refused")
+	tests := []struct {
+		in      error
+		code    int
+		message string
+	}{
+		{
+			in:      cause,
+			code:    ExitOther,
+			message: "connection refused",
+		},
+		{
+			in:      ErrConfigInvalid,
+			code:    ExitConfigInvalid,
+			message: "config is invalid",
+		},
+		{
+			in:      Wrap(ErrFetchFailed, cause),
+			code:    ExitFetchFailed,
+			message: "connection refused",
+		},
+		{
+			// the class is kept through Errorf
+			in:      Errorf("failed to create files: %v", Errorf("failed to create file %q: %v", "/etc/motd", Wrap(ErrFetchFailed, cause))),
+			code:    ExitFetchFailed,
+			message: `failed to create files: failed to create file "/etc/motd": connection refused`,
+		},
+		{
+			// but not through fmt.Errorf
+			in:      Errorf("failed to create files: %v", errors.New(Wrap(ErrFetchFailed, cause).Error())),
+			code:    ExitOther,
+			message: "failed to create files: connection refused",
+		},
+		{
+			// an error knowing its class keeps it when wrapped again
+			in:      Errorf("failed to create files: %v", Wrap(ErrFetchFailed, mismatch{})),
+			code:    ExitVerificationFailed,
+			message: "failed to create files: hash mismatch",
+		},
+		{
+			in:      Wrap(ErrDeviceMissing, Wrap(ErrFetchFailed, cause)),
+			code:    ExitFetchFailed,
+			message: "connection refused",
+		},
+	}
+
+	for i, test := range tests {
+		if code := ExitCode(test.in); code != test.code {
+			t.Errorf("#%d: expected exit code %d, got %d", i, test.code, code)
+		}
+		if test.in.Error() != test.message {
+			t.Errorf("#%d: expected message %q, got %q", i, test.message, test.in.Error())
+		}
+	}
+	if Wrap(ErrFetchFailed, nil) != nil {
+		t.Error("wrapping nil returned an error")
+	}
+}
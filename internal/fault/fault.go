@@ -0,0 +1,155 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fault injects failures into HTTP requests and file writes, as
+// directed by $IGNITION_FAULTS, so that tests can exercise Ignition's retry
+// and cleanup paths. The variable is a comma-separated list of faults of the
+// form kind=N, each of which makes the Nth event of its kind fail, counting
+// from 1 in each process, or since the variable last changed. For example,
+// "fetch-fail=1,fetch-fail=2" makes the first two HTTP requests fail.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+const (
+	// FetchFail makes the Nth HTTP request attempt fail before it's
+	// sent, as if the server were unreachable, so it's retried.
+	FetchFail = "fetch-fail"
+	// ShortRead makes the response to the Nth HTTP request attempt end
+	// after its first byte, as if the connection were dropped.
+	ShortRead = "short-read"
+	// NoSpace makes writing the contents of the Nth file fetched fail
+	// with ENOSPC.
+	NoSpace = "enospc"
+)
+
+var (
+	ErrInjected = errors.New("injected fault")
+
+	mu sync.Mutex
+	// spec is the list of faults loaded into faults, which holds the
+	// numbers of the events which fail by kind
+	spec   string
+	faults map[string]map[int]bool
+	// requests and files count HTTP request attempts and files fetched
+	requests int
+	files    int
+)
+
+// parse parses a list of faults in the form of $IGNITION_FAULTS.
+func parse(spec string) (map[string]map[int]bool, error) {
+	parsed := map[string]map[int]bool{}
+	for _, fault := range strings.Split(spec, ",") {
+		if fault == "" {
+			continue
+		}
+		parts := strings.SplitN(fault, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("fault %q isn't of the form kind=N", fault)
+		}
+		kind := parts[0]
+		if kind != FetchFail && kind != ShortRead && kind != NoSpace {
+			return nil, fmt.Errorf("unknown fault %q", kind)
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("fault %q doesn't name a positive event number", fault)
+		}
+		if parsed[kind] == nil {
+			parsed[kind] = map[int]bool{}
+		}
+		parsed[kind][n] = true
+	}
+	return parsed, nil
+}
+
+// load loads the faults from the environment, restarting the counts if
+// they changed. mu must be held. An invalid list is fatal, since the test
+// relying on it would otherwise pass without the faults it meant to inject.
+func load() {
+	current := distro.Faults()
+	if faults != nil && current == spec {
+		return
+	}
+	parsed, err := parse(current)
+	if err != nil {
+		panic(fmt.Sprintf("invalid IGNITION_FAULTS: %v", err))
+	}
+	spec, faults = current, parsed
+	requests, files = 0, 0
+}
+
+// HTTPRequest counts an HTTP request attempt. It returns ErrInjected if
+// the attempt is to fail, and otherwise whether its response is to be cut
+// short with ShortBody.
+func HTTPRequest() (bool, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	requests++
+	if faults[FetchFail][requests] {
+		return false, ErrInjected
+	}
+	return faults[ShortRead][requests], nil
+}
+
+// ShortBody returns body cut short after its first byte.
+func ShortBody(body io.ReadCloser) io.ReadCloser {
+	return &shortBody{ReadCloser: body}
+}
+
+type shortBody struct {
+	io.ReadCloser
+	read bool
+}
+
+func (b *shortBody) Read(p []byte) (int, error) {
+	if b.read || len(p) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b.read = true
+	return b.ReadCloser.Read(p[:1])
+}
+
+// FileWriter counts a file fetched into f, returning the writer to fetch
+// it through: f itself, or a writer which fails with ENOSPC.
+func FileWriter(f *os.File) io.Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	load()
+	files++
+	if faults[NoSpace][files] {
+		return noSpaceWriter{f.Name()}
+	}
+	return f
+}
+
+type noSpaceWriter struct {
+	name string
+}
+
+func (w noSpaceWriter) Write(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: w.name, Err: syscall.ENOSPC}
+}
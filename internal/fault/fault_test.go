@@ -0,0 +1,74 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fault
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in    string
+		out   map[string]map[int]bool
+		valid bool
+	}{
+		{"", map[string]map[int]bool{}, true},
+		{"fetch-fail=1,fetch-fail=3,enospc=2", map[string]map[int]bool{FetchFail: {1: true, 3: true}, NoSpace: {2: true}}, true},
+		{"short-read", nil, false},
+		{"short-read=0", nil, false},
+		{"power-cut=1", nil, false},
+	}
+
+	for i, test := range tests {
+		out, err := parse(test.in)
+		if (err == nil) != test.valid {
+			t.Errorf("#%d: expected valid: %t, got %v", i, test.valid, err)
+			continue
+		}
+		if len(out) != len(test.out) {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, out)
+			continue
+		}
+		for kind, events := range test.out {
+			for n := range events {
+				if !out[kind][n] {
+					t.Errorf("#%d: expected %s=%d, got %v", i, kind, n, out)
+				}
+			}
+		}
+	}
+}
+
+func TestHTTPRequest(t *testing.T) {
+	os.Setenv("IGNITION_FAULTS", "fetch-fail=2,short-read=3")
+	defer os.Unsetenv("IGNITION_FAULTS")
+
+	expected := []struct {
+		short bool
+		err   error
+	}{
+		{false, nil},
+		{false, ErrInjected},
+		{true, nil},
+		{false, nil},
+	}
+	for i, e := range expected {
+		short, err := HTTPRequest()
+		if short != e.short || err != e.err {
+			t.Errorf("request %d: expected %t, %v, got %t, %v", i+1, e.short, e.err, short, err)
+		}
+	}
+}
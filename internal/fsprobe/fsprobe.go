@@ -0,0 +1,345 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fsprobe identifies filesystems and other signatures on block
+// devices by reading their superblocks, as an alternative to libblkid. It
+// reports types, UUIDs, and labels the way blkid does.
+package fsprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// raidMagic starts md RAID superblocks.
+const raidMagic = 0xa92b4efc
+
+// Info describes the signature found on a device. A device without a
+// recognized signature has an empty Type.
+type Info struct {
+	Type  string
+	UUID  string
+	Label string
+}
+
+// prober recognizes one signature on a device of size bytes, returning
+// whether it was found.
+type prober func(r io.ReaderAt, size int64, info *Info) (bool, error)
+
+// probers are tried in order, the first match winning. Containers such as
+// RAID members and LUKS volumes come first, since they may also look like
+// the filesystem they were made from, and FAT, whose signature is the
+// weakest, comes last. Signatures Ignition can't create are still
+// recognized so that their devices aren't mistaken for empty ones.
+var probers = []prober{
+	probeRAID,
+	probeLUKS,
+	probeLVM,
+	probeXFS,
+	probeBtrfs,
+	probeExt,
+	probeSwap,
+	probeISO9660,
+	probeSquashfs,
+	probeVFAT,
+}
+
+// Probe returns the signature found on device.
+func Probe(device string) (Info, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to open %q: %v", device, err)
+	}
+	defer f.Close()
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to find the size of %q: %v", device, err)
+	}
+	info, err := probe(f, size)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to probe %q: %v", device, err)
+	}
+	return info, nil
+}
+
+func probe(r io.ReaderAt, size int64) (Info, error) {
+	for _, p := range probers {
+		var info Info
+		found, err := p(r, size, &info)
+		if err != nil {
+			return Info{}, err
+		}
+		if found {
+			return info, nil
+		}
+	}
+	return Info{}, nil
+}
+
+// read returns n bytes at off, or nil if the device ends first.
+func read(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, off); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// formatUUID formats 16 bytes as a UUID, e.g.
+// "6a4e2f3c-9b1d-4c57-8e0f-1a2b3c4d5e6f".
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// cString returns b up to its first NUL byte.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func probeRAID(r io.ReaderAt, size int64, info *Info) (bool, error) {
+	// version 1.1 superblocks are at the start of the device, 1.2
+	// superblocks 4 KiB from it, and 1.0 superblocks 8 KiB from the end,
+	// rounded down to 4 KiB
+	offsets := []int64{0, 4096}
+	if size >= 8192 {
+		offsets = append(offsets, (size-8192)&^4095)
+	}
+	for _, off := range offsets {
+		sb, err := read(r, off, 64)
+		if err != nil || sb == nil {
+			return false, err
+		}
+		if binary.LittleEndian.Uint32(sb[0:4]) == raidMagic && binary.LittleEndian.Uint32(sb[4:8]) == 1 {
+			info.Type = "linux_raid_member"
+			info.UUID = formatUUID(sb[16:32])
+			info.Label = cString(sb[32:64])
+			return true, nil
+		}
+	}
+
+	// version 0.90 superblocks are 64 KiB from the end, rounded down to
+	// 64 KiB, and have no name
+	if size < 2*65536 {
+		return false, nil
+	}
+	sb, err := read(r, size&^65535-65536, 64)
+	if err != nil || sb == nil {
+		return false, err
+	}
+	if binary.LittleEndian.Uint32(sb[0:4]) == raidMagic && binary.LittleEndian.Uint32(sb[4:8]) == 0 {
+		// the first word of the UUID is apart from the other three
+		uuid := append(append([]byte{}, sb[20:24]...), sb[52:64]...)
+		info.Type = "linux_raid_member"
+		info.UUID = formatUUID(uuid)
+		return true, nil
+	}
+	return false, nil
+}
+
+func probeLUKS(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	hdr, err := read(r, 0, 512)
+	if err != nil || hdr == nil {
+		return false, err
+	}
+	if !bytes.Equal(hdr[0:6], []byte("LUKS\xba\xbe")) {
+		return false, nil
+	}
+	info.Type = "crypto_LUKS"
+	info.UUID = cString(hdr[168:208])
+	if binary.BigEndian.Uint16(hdr[6:8]) == 2 {
+		info.Label = cString(hdr[24:72])
+	}
+	return true, nil
+}
+
+func probeLVM(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	// the label is in one of the first four sectors
+	for sector := int64(0); sector < 4; sector++ {
+		label, err := read(r, sector*512, 512)
+		if err != nil || label == nil {
+			return false, err
+		}
+		if string(label[0:8]) != "LABELONE" || string(label[24:32]) != "LVM2 001" {
+			continue
+		}
+		// the PV header, which starts with the UUID, follows the label
+		off := binary.LittleEndian.Uint32(label[20:24])
+		if off > 512-32 {
+			return false, nil
+		}
+		id := label[off : off+32]
+		info.Type = "LVM2_member"
+		info.UUID = fmt.Sprintf("%s-%s-%s-%s-%s-%s-%s", id[0:6], id[6:10], id[10:14], id[14:18], id[18:22], id[22:26], id[26:32])
+		return true, nil
+	}
+	return false, nil
+}
+
+func probeXFS(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	sb, err := read(r, 0, 120)
+	if err != nil || sb == nil {
+		return false, err
+	}
+	if string(sb[0:4]) != "XFSB" {
+		return false, nil
+	}
+	info.Type = "xfs"
+	info.UUID = formatUUID(sb[32:48])
+	info.Label = cString(sb[108:120])
+	return true, nil
+}
+
+func probeBtrfs(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	sb, err := read(r, 64*1024, 0x12b+256)
+	if err != nil || sb == nil {
+		return false, err
+	}
+	if string(sb[64:72]) != "_BHRfS_M" {
+		return false, nil
+	}
+	info.Type = "btrfs"
+	info.UUID = formatUUID(sb[32:48])
+	info.Label = cString(sb[0x12b:])
+	return true, nil
+}
+
+const (
+	extCompatHasJournal = 0x4
+
+	// features ext3 supports; any others make a filesystem ext4
+	extIncompatExt3  = 0x2 | 0x4 | 0x10
+	extROCompatExt3  = 0x1 | 0x2 | 0x4
+	extIncompatJDev  = 0x8
+	extSuperblockOff = 1024
+)
+
+func probeExt(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	sb, err := read(r, extSuperblockOff, 136)
+	if err != nil || sb == nil {
+		return false, err
+	}
+	le := binary.LittleEndian
+	if le.Uint16(sb[56:58]) != 0xef53 {
+		return false, nil
+	}
+	compat := le.Uint32(sb[92:96])
+	incompat := le.Uint32(sb[96:100])
+	roCompat := le.Uint32(sb[100:104])
+	switch {
+	case incompat&extIncompatJDev != 0:
+		info.Type = "jbd"
+	case incompat&^extIncompatExt3 != 0 || roCompat&^extROCompatExt3 != 0:
+		info.Type = "ext4"
+	case compat&extCompatHasJournal != 0:
+		info.Type = "ext3"
+	default:
+		info.Type = "ext2"
+	}
+	info.UUID = formatUUID(sb[104:120])
+	info.Label = cString(sb[120:136])
+	return true, nil
+}
+
+func probeSwap(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	// the signature ends the first page, whose size depends on the
+	// architecture which made it
+	for _, pageSize := range []int64{4096, 8192, 16384, 65536} {
+		sig, err := read(r, pageSize-10, 10)
+		if err != nil || sig == nil {
+			return false, err
+		}
+		if string(sig) != "SWAPSPACE2" && string(sig) != "SWAP-SPACE" {
+			continue
+		}
+		info.Type = "swap"
+		if string(sig) == "SWAPSPACE2" {
+			hdr, err := read(r, 1024, 44)
+			if err != nil || hdr == nil {
+				return false, err
+			}
+			info.UUID = formatUUID(hdr[12:28])
+			info.Label = cString(hdr[28:44])
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func probeISO9660(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	desc, err := read(r, 32768, 72)
+	if err != nil || desc == nil {
+		return false, err
+	}
+	if string(desc[1:6]) != "CD001" {
+		return false, nil
+	}
+	info.Type = "iso9660"
+	info.Label = strings.TrimRight(string(desc[40:72]), " ")
+	return true, nil
+}
+
+func probeSquashfs(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	sb, err := read(r, 0, 4)
+	if err != nil || sb == nil {
+		return false, err
+	}
+	if string(sb) != "hsqs" {
+		return false, nil
+	}
+	info.Type = "squashfs"
+	return true, nil
+}
+
+func probeVFAT(r io.ReaderAt, _ int64, info *Info) (bool, error) {
+	bs, err := read(r, 0, 512)
+	if err != nil || bs == nil {
+		return false, err
+	}
+	if binary.LittleEndian.Uint16(bs[510:512]) != 0xaa55 {
+		return false, nil
+	}
+	// FAT32 keeps its extended boot record further in than FAT12/16
+	var ebr []byte
+	switch {
+	case string(bs[82:87]) == "FAT32":
+		ebr = bs[64:90]
+	case string(bs[54:59]) == "FAT12" || string(bs[54:59]) == "FAT16" || string(bs[54:62]) == "FAT     ":
+		ebr = bs[36:62]
+	default:
+		return false, nil
+	}
+	// a plausible bytes per sector and FAT count rule out MBRs which
+	// happen to contain the string
+	sectorSize := binary.LittleEndian.Uint16(bs[11:13])
+	if sectorSize < 512 || sectorSize > 4096 || sectorSize&(sectorSize-1) != 0 || bs[16] == 0 {
+		return false, nil
+	}
+	info.Type = "vfat"
+	id := ebr[3:7]
+	info.UUID = fmt.Sprintf("%02X%02X-%02X%02X", id[3], id[2], id[1], id[0])
+	if label := strings.TrimRight(string(ebr[7:18]), " "); label != "NO NAME" {
+		info.Label = label
+	}
+	return true, nil
+}
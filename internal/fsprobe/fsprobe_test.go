@@ -0,0 +1,201 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+var testUUID = []byte{0x6a, 0x4e, 0x2f, 0x3c, 0x9b, 0x1d, 0x4c, 0x57, 0x8e, 0x0f, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e, 0x6f}
+
+const testUUIDString = "6a4e2f3c-9b1d-4c57-8e0f-1a2b3c4d5e6f"
+
+// image returns a 1 MiB image with each of the given writes applied.
+func image(writes map[int][]byte) []byte {
+	img := make([]byte, 1024*1024)
+	for off, data := range writes {
+		copy(img[off:], data)
+	}
+	return img
+}
+
+func le16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, v)
+	return b
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+func fatBootSector(fat32 bool) []byte {
+	bs := make([]byte, 512)
+	copy(bs[11:], le16(512))
+	bs[16] = 2
+	ebr := 36
+	if fat32 {
+		ebr = 64
+		copy(bs[82:], "FAT32   ")
+	} else {
+		copy(bs[54:], "FAT16   ")
+	}
+	copy(bs[ebr+3:], le32(0xa1b2c3d4))
+	copy(bs[ebr+7:], "EFI-SYSTEM ")
+	copy(bs[510:], le16(0xaa55))
+	return bs
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		img  []byte
+		out  Info
+	}{
+		{
+			name: "empty",
+			img:  image(nil),
+		},
+		{
+			name: "ext4",
+			img: image(map[int][]byte{
+				1024 + 56:  le16(0xef53),
+				1024 + 92:  le32(0x4),   // has_journal
+				1024 + 96:  le32(0x2c2), // filetype, extents, 64bit, flex_bg
+				1024 + 104: testUUID,
+				1024 + 120: []byte("root"),
+			}),
+			out: Info{"ext4", testUUIDString, "root"},
+		},
+		{
+			name: "ext3",
+			img: image(map[int][]byte{
+				1024 + 56:  le16(0xef53),
+				1024 + 92:  le32(0x4),
+				1024 + 96:  le32(0x2),
+				1024 + 104: testUUID,
+			}),
+			out: Info{"ext3", testUUIDString, ""},
+		},
+		{
+			name: "xfs",
+			img: image(map[int][]byte{
+				0:   []byte("XFSB"),
+				32:  testUUID,
+				108: []byte("var"),
+			}),
+			out: Info{"xfs", testUUIDString, "var"},
+		},
+		{
+			name: "btrfs",
+			img: image(map[int][]byte{
+				65536 + 32:    testUUID,
+				65536 + 64:    []byte("_BHRfS_M"),
+				65536 + 0x12b: []byte("data"),
+			}),
+			out: Info{"btrfs", testUUIDString, "data"},
+		},
+		{
+			name: "swap",
+			img: image(map[int][]byte{
+				1024 + 12: testUUID,
+				1024 + 28: []byte("swap0"),
+				4096 - 10: []byte("SWAPSPACE2"),
+			}),
+			out: Info{"swap", testUUIDString, "swap0"},
+		},
+		{
+			name: "fat32",
+			img:  image(map[int][]byte{0: fatBootSector(true)}),
+			out:  Info{"vfat", "A1B2-C3D4", "EFI-SYSTEM"},
+		},
+		{
+			name: "fat16",
+			img:  image(map[int][]byte{0: fatBootSector(false)}),
+			out:  Info{"vfat", "A1B2-C3D4", "EFI-SYSTEM"},
+		},
+		{
+			// an MBR has the boot sector signature, but isn't a FAT
+			name: "mbr",
+			img:  image(map[int][]byte{510: le16(0xaa55)}),
+		},
+		{
+			// a RAID member whose array holds an xfs filesystem
+			name: "raid",
+			img: image(map[int][]byte{
+				0:         []byte("XFSB"),
+				4096:      le32(0xa92b4efc),
+				4096 + 4:  le32(1),
+				4096 + 16: testUUID,
+				4096 + 32: []byte("host:md0"),
+			}),
+			out: Info{"linux_raid_member", testUUIDString, "host:md0"},
+		},
+		{
+			// a RAID1 member with version 1.0 metadata, at the end, looks
+			// like the filesystem inside it from the start
+			name: "raid1.0",
+			img: image(map[int][]byte{
+				0:            []byte("XFSB"),
+				1040384:      le32(0xa92b4efc),
+				1040384 + 4:  le32(1),
+				1040384 + 16: testUUID,
+				1040384 + 32: []byte("host:boot"),
+			}),
+			out: Info{"linux_raid_member", testUUIDString, "host:boot"},
+		},
+		{
+			name: "raid0.90",
+			img: image(map[int][]byte{
+				0:           []byte("XFSB"),
+				983040:      le32(0xa92b4efc),
+				983040 + 4:  le32(0),
+				983040 + 8:  le32(90),
+				983040 + 20: testUUID[0:4],
+				983040 + 52: testUUID[4:16],
+			}),
+			out: Info{"linux_raid_member", testUUIDString, ""},
+		},
+		{
+			name: "luks2",
+			img: image(map[int][]byte{
+				0:   []byte("LUKS\xba\xbe\x00\x02"),
+				24:  []byte("crypt"),
+				168: []byte(testUUIDString),
+			}),
+			out: Info{"crypto_LUKS", testUUIDString, "crypt"},
+		},
+		{
+			// a device too small for most superblocks
+			name: "tiny",
+			img:  []byte("XFSB"),
+		},
+	}
+
+	for _, test := range tests {
+		info, err := probe(bytes.NewReader(test.img), int64(len(test.img)))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if info != test.out {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.out, info)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpt
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+const imageSectors = 64 * 2048
+
+func strp(s string) *string { return &s }
+func intp(i int) *int       { return &i }
+
+// newImage returns the path of an empty 64 MiB image file in dir.
+func newImage(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "disk.img")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, imageSectors*512); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readImage(t *testing.T, path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return Read(f, 512, imageSectors)
+}
+
+func TestGUID(t *testing.T) {
+	in := "0FC63DAF-8483-4772-8E79-3D69D8477DE4"
+	g, err := ParseGUID(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g[0] != 0xaf || g[3] != 0x0f || g[4] != 0x83 || g[8] != 0x8e {
+		t.Errorf("GUID %s has the wrong byte order: % x", in, g[:])
+	}
+	if g.String() != in {
+		t.Errorf("expected %s, got %s", in, g)
+	}
+	if _, err := ParseGUID("not-a-guid"); err == nil {
+		t.Error("expected an error parsing an invalid GUID")
+	}
+}
+
+func TestOperation(t *testing.T) {
+	logger := log.New(true)
+	dir, err := ioutil.TempDir("", "ignition-gpt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := newImage(t, dir)
+	espType := "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"
+	rootGUID := "2A8F3A1B-6F44-4C3B-9D4A-54A3D1C0B0A9"
+
+	op := Begin(&logger, path)
+	op.CreatePartition(types.Partition{Number: 1, StartMiB: intp(0), SizeMiB: intp(10 * 2048), Label: strp("EFI-SYSTEM"), TypeGUID: &espType})
+	op.CreatePartition(types.Partition{Number: 2, GUID: &rootGUID})
+	if err := op.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := readImage(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	esp, root := table.Entries[0], table.Entries[1]
+	if esp.FirstLBA != 2048 || esp.LastLBA != 22527 || esp.Name != "EFI-SYSTEM" || esp.TypeGUID.String() != espType {
+		t.Errorf("unexpected partition 1: %+v", esp)
+	}
+	if root.FirstLBA != 22528 || root.LastLBA != table.LastUsable || root.GUID.String() != rootGUID || root.TypeGUID != LinuxFilesystem {
+		t.Errorf("unexpected partition 2: %+v", root)
+	}
+
+	// shrinking partition 2 leaves it where it was
+	op = Begin(&logger, path)
+	op.DeletePartition(2)
+	op.CreatePartition(types.Partition{Number: 2, SizeMiB: intp(2048)})
+	op.Info(2)
+	out, err := op.Pretend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "First sector: 22528 (") || !strings.Contains(out, "Last sector: 24575 (") {
+		t.Errorf("unexpected pretend output:\n%s", out)
+	}
+
+	// partitions can't overlap
+	op = Begin(&logger, path)
+	op.CreatePartition(types.Partition{Number: 3, StartMiB: intp(4096), SizeMiB: intp(2048)})
+	if err := op.Commit(); err == nil || !strings.Contains(err.Error(), "could not create partition 3") {
+		t.Errorf("expected an overlapping partition to fail, got %v", err)
+	}
+
+	// the backup is used if the primary header is damaged
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte("garbage!"), 512); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if table, err = readImage(t, path); err != nil {
+		t.Fatalf("reading the backup table: %v", err)
+	}
+	if table.Entries[0].Name != "EFI-SYSTEM" {
+		t.Errorf("unexpected partition 1 in the backup table: %+v", table.Entries[0])
+	}
+
+	op = Begin(&logger, path)
+	op.WipeTable(true)
+	if err := op.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readImage(t, path); err != ErrNoTable {
+		t.Errorf("expected no table after wiping, got %v", err)
+	}
+}
+
+func TestReadHeaderEntryCount(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-gpt-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	tests := []struct {
+		count uint32
+		err   error
+	}{
+		{numEntries, nil},
+		// more entries than fit before the first usable sector
+		{numEntries + 16, ErrNoTable},
+		{maxEntries + 1, ErrNoTable},
+		{0xffffffff, ErrNoTable},
+	}
+
+	for i, test := range tests {
+		path := newImage(t, td)
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		table, err := New(512, imageSectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := table.Write(f); err != nil {
+			t.Fatal(err)
+		}
+
+		hdr := make([]byte, 512)
+		if _, err := f.ReadAt(hdr, 512); err != nil {
+			t.Fatal(err)
+		}
+		le := binary.LittleEndian
+		le.PutUint32(hdr[80:84], test.count)
+		// keep the entries' checksum valid where they fit on the disk, so
+		// only the count can get the table refused
+		if test.count <= maxEntries+1 {
+			raw := make([]byte, int(test.count)*entrySize)
+			if _, err := f.ReadAt(raw, 2*512); err != nil {
+				t.Fatal(err)
+			}
+			le.PutUint32(hdr[88:92], crc32.ChecksumIEEE(raw))
+		}
+		le.PutUint32(hdr[16:20], 0)
+		le.PutUint32(hdr[16:20], crc32.ChecksumIEEE(hdr[:headerSize]))
+		if _, err := f.WriteAt(hdr, 512); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := readHeader(f, 512, imageSectors, 1); err != test.err {
+			t.Errorf("#%d: expected %v, got %v", i, test.err, err)
+		}
+		f.Close()
+	}
+}
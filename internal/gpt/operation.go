@@ -0,0 +1,324 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+
+	"golang.org/x/sys/unix"
+)
+
+// Operation is a set of changes to the partition table of a disk. It has the
+// same methods, and the same semantics, as sgdisk.Operation: starts and
+// sizes are in sectors, a start of 0 is the first aligned sector of the
+// largest free block, and a size of 0 fills the free block.
+type Operation struct {
+	logger    *log.Logger
+	dev       string
+	wipe      bool
+	parts     []types.Partition
+	deletions []int
+	infos     []int
+}
+
+// Begin begins an operation on dev, which may be a block device or an image
+// file.
+func Begin(logger *log.Logger, dev string) *Operation {
+	return &Operation{logger: logger, dev: dev}
+}
+
+// CreatePartition adds the supplied partition to the list of partitions to be created as part of an operation.
+func (op *Operation) CreatePartition(p types.Partition) {
+	op.parts = append(op.parts, p)
+}
+
+func (op *Operation) DeletePartition(num int) {
+	op.deletions = append(op.deletions, num)
+}
+
+func (op *Operation) Info(num int) {
+	op.infos = append(op.infos, num)
+}
+
+// WipeTable toggles if the table is to be wiped first when commiting this operation.
+func (op *Operation) WipeTable(wipe bool) {
+	op.wipe = wipe
+}
+
+// Pretend computes the table Commit would write, without writing it, and
+// returns the partitions requested with Info in the format of sgdisk --info.
+func (op *Operation) Pretend() (string, error) {
+	f, err := os.Open(op.dev)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	t, err := op.apply(f)
+	if err != nil {
+		return "", fmt.Errorf("Failed to pretend to create partitions: %v", err)
+	}
+
+	var out strings.Builder
+	for _, num := range op.infos {
+		if num < 1 || num > len(t.Entries) || !t.Entries[num-1].Used() {
+			fmt.Fprintf(&out, "Partition #%d does not exist.\n", num)
+			continue
+		}
+		e := t.Entries[num-1]
+		ss := uint64(t.SectorSize)
+		fmt.Fprintf(&out, "Partition GUID code: %s\n", e.TypeGUID)
+		fmt.Fprintf(&out, "Partition unique GUID: %s\n", e.GUID)
+		fmt.Fprintf(&out, "First sector: %d (at %d bytes)\n", e.FirstLBA, e.FirstLBA*ss)
+		fmt.Fprintf(&out, "Last sector: %d (at %d bytes)\n", e.LastLBA, e.LastLBA*ss)
+		fmt.Fprintf(&out, "Partition size: %d sectors (%d bytes)\n", e.LastLBA-e.FirstLBA+1, (e.LastLBA-e.FirstLBA+1)*ss)
+		fmt.Fprintf(&out, "Attribute flags: %016x\n", e.Attributes)
+		fmt.Fprintf(&out, "Partition name: '%s'\n\n", e.Name)
+	}
+	return out.String(), nil
+}
+
+// Commit commits an partitioning operation, then asks the kernel to re-read
+// the partition table.
+func (op *Operation) Commit() error {
+	if !op.wipe && len(op.deletions) == 0 && len(op.parts) == 0 {
+		return nil
+	}
+	err := op.logger.LogOp(op.commit, "deleting %d partitions and creating %d partitions on %q", len(op.deletions), len(op.parts), op.dev)
+	if err != nil {
+		return fmt.Errorf("create partitions failed: %v", err)
+	}
+	return nil
+}
+
+func (op *Operation) commit() error {
+	f, err := os.OpenFile(op.dev, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if op.wipe && len(op.deletions) == 0 && len(op.parts) == 0 {
+		sectorSize, sectors, err := geometry(f)
+		if err != nil {
+			return err
+		}
+		if err := Zap(f, sectorSize, sectors); err != nil {
+			return err
+		}
+	} else {
+		t, err := op.apply(f)
+		if err != nil {
+			return err
+		}
+		if err := t.Write(f); err != nil {
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return rereadTable(f)
+}
+
+// apply returns the table of the disk f with the operation applied.
+func (op *Operation) apply(f *os.File) (*Table, error) {
+	sectorSize, sectors, err := geometry(f)
+	if err != nil {
+		return nil, err
+	}
+	var t *Table
+	if !op.wipe {
+		t, err = Read(f, sectorSize, sectors)
+	}
+	if op.wipe || err == ErrNoTable {
+		t, err = New(sectorSize, sectors)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, num := range op.deletions {
+		if num < 1 || num > len(t.Entries) || !t.Entries[num-1].Used() {
+			return nil, fmt.Errorf("partition %d does not exist", num)
+		}
+		t.Entries[num-1] = Entry{}
+	}
+	for _, p := range op.parts {
+		if err := t.create(p); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// create adds p to the table.
+func (t *Table) create(p types.Partition) error {
+	num := p.Number
+	if num == 0 {
+		for i, e := range t.Entries {
+			if !e.Used() {
+				num = i + 1
+				break
+			}
+		}
+		if num == 0 {
+			return fmt.Errorf("no free partition entries")
+		}
+	}
+	if num < 1 || num > len(t.Entries) {
+		return fmt.Errorf("partition number %d is out of range", num)
+	}
+	if t.Entries[num-1].Used() {
+		return fmt.Errorf("partition %d already exists", num)
+	}
+
+	var start, size uint64
+	if p.StartMiB != nil {
+		start = uint64(*p.StartMiB)
+	}
+	if p.SizeMiB != nil {
+		size = uint64(*p.SizeMiB)
+	}
+	blocks := t.freeBlocks()
+	var block extent
+	found := false
+	if start == 0 {
+		// the largest free block, as with sgdisk
+		for _, b := range blocks {
+			if !found || b.last-b.first > block.last-block.first {
+				block, found = b, true
+			}
+		}
+		if found {
+			align := uint64(1024 * 1024 / t.SectorSize)
+			if align == 0 {
+				align = 1
+			}
+			start = (block.first + align - 1) / align * align
+		}
+	} else {
+		for _, b := range blocks {
+			if start >= b.first && start <= b.last {
+				block, found = b, true
+			}
+		}
+	}
+	end := block.last
+	if size != 0 {
+		end = start + size - 1
+	}
+	if !found || start > block.last || end > block.last {
+		return fmt.Errorf("could not create partition %d from %d of size %d", num, start, size)
+	}
+
+	e := Entry{
+		TypeGUID: LinuxFilesystem,
+		FirstLBA: start,
+		LastLBA:  end,
+	}
+	var err error
+	if p.TypeGUID != nil && *p.TypeGUID != "" {
+		if e.TypeGUID, err = ParseGUID(*p.TypeGUID); err != nil {
+			return err
+		}
+	}
+	if p.GUID != nil && *p.GUID != "" {
+		e.GUID, err = ParseGUID(*p.GUID)
+	} else {
+		e.GUID, err = RandomGUID()
+	}
+	if err != nil {
+		return err
+	}
+	if p.Label != nil {
+		e.Name = *p.Label
+	}
+	t.Entries[num-1] = e
+	return nil
+}
+
+// extent is a range of sectors, inclusive of both ends.
+type extent struct {
+	first uint64
+	last  uint64
+}
+
+// freeBlocks returns the ranges of usable sectors no partition covers.
+func (t *Table) freeBlocks() []extent {
+	used := []extent{}
+	for _, e := range t.Entries {
+		if e.Used() {
+			used = append(used, extent{e.FirstLBA, e.LastLBA})
+		}
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].first < used[j].first })
+
+	free := []extent{}
+	next := t.FirstUsable
+	for _, u := range used {
+		if u.first > next {
+			free = append(free, extent{next, u.first - 1})
+		}
+		if u.last+1 > next {
+			next = u.last + 1
+		}
+	}
+	if next <= t.LastUsable {
+		free = append(free, extent{next, t.LastUsable})
+	}
+	return free
+}
+
+// geometry returns the logical sector size and the number of sectors of
+// the disk f. Image files have 512 byte sectors.
+func geometry(f *os.File) (int, uint64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	sectorSize := 512
+	if info.Mode()&os.ModeDevice != 0 {
+		if sectorSize, err = unix.IoctlGetInt(int(f.Fd()), unix.BLKSSZGET); err != nil {
+			return 0, 0, os.NewSyscallError("ioctl BLKSSZGET", err)
+		}
+	}
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sectorSize, uint64(size) / uint64(sectorSize), nil
+}
+
+// rereadTable asks the kernel to re-read the partition table of f, if it's
+// a block device.
+func rereadTable(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeDevice == 0 {
+		return nil
+	}
+	if err := unix.IoctlSetInt(int(f.Fd()), unix.BLKRRPART, 0); err != nil {
+		return os.NewSyscallError("ioctl BLKRRPART", err)
+	}
+	return nil
+}
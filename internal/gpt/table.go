@@ -0,0 +1,378 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gpt reads and writes GUID partition tables in-process, as an
+// alternative to running sgdisk.
+package gpt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	headerSize = 92
+	entrySize  = 128
+	numEntries = 128
+	// maxEntries bounds the entries read from a header, so that a corrupt
+	// one can't make reading it allocate gigabytes
+	maxEntries   = 16384
+	nameUnits    = 36
+	mbrSignature = 0xaa55
+	protectiveOS = 0xee
+)
+
+var (
+	ErrNoTable         = errors.New("no valid GPT found")
+	ErrMBRPartitions   = errors.New("disk has an MBR partition table; wipe the table to replace it with a GPT")
+	ErrTooSmall        = errors.New("disk is too small for a GPT")
+	ErrUnsupportedSize = errors.New("unsupported GPT partition entry size")
+
+	signature = []byte("EFI PART")
+
+	// LinuxFilesystem is the type GUID of partitions created without one,
+	// as with sgdisk.
+	LinuxFilesystem = MustParseGUID("0FC63DAF-8483-4772-8E79-3D69D8477DE4")
+)
+
+// GUID is a GUID in its on-disk form, whose first three fields are little
+// endian.
+type GUID [16]byte
+
+// ParseGUID parses the string form of a GUID, e.g.
+// "0FC63DAF-8483-4772-8E79-3D69D8477DE4".
+func ParseGUID(s string) (GUID, error) {
+	var g GUID
+	raw, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(raw) != 16 || len(s) != 36 {
+		return g, fmt.Errorf("invalid GUID %q", s)
+	}
+	copy(g[:], raw)
+	// the first three fields are stored little endian
+	g[0], g[1], g[2], g[3] = g[3], g[2], g[1], g[0]
+	g[4], g[5] = g[5], g[4]
+	g[6], g[7] = g[7], g[6]
+	return g, nil
+}
+
+// MustParseGUID is like ParseGUID, but panics if s is invalid.
+func MustParseGUID(s string) GUID {
+	g, err := ParseGUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// RandomGUID returns a random (version 4) GUID.
+func RandomGUID() (GUID, error) {
+	var g GUID
+	if _, err := io.ReadFull(rand.Reader, g[:]); err != nil {
+		return g, err
+	}
+	// the version is in the high bits of the third field
+	g[7] = g[7]&0x0f | 0x40
+	g[8] = g[8]&0x3f | 0x80
+	return g, nil
+}
+
+func (g GUID) String() string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+		binary.LittleEndian.Uint32(g[0:4]),
+		binary.LittleEndian.Uint16(g[4:6]),
+		binary.LittleEndian.Uint16(g[6:8]),
+		g[8:10], g[10:16])
+}
+
+// Entry is a partition entry. Unused entries have a zero TypeGUID.
+type Entry struct {
+	TypeGUID   GUID
+	GUID       GUID
+	FirstLBA   uint64
+	LastLBA    uint64
+	Attributes uint64
+	Name       string
+}
+
+// Used returns whether e describes a partition.
+func (e Entry) Used() bool {
+	return e.TypeGUID != GUID{}
+}
+
+// Table is a GUID partition table of a disk with Sectors sectors of
+// SectorSize bytes.
+type Table struct {
+	SectorSize  int
+	Sectors     uint64
+	DiskGUID    GUID
+	FirstUsable uint64
+	LastUsable  uint64
+	// Entries are the partition entries; partition n is Entries[n-1].
+	Entries []Entry
+
+	// bootCode is the boot code of the MBR, which is kept when the
+	// protective MBR is written
+	bootCode []byte
+}
+
+// entrySectors returns the number of sectors the partition entries of a
+// table take up.
+func entrySectors(sectorSize int, entries int) uint64 {
+	return uint64((entries*entrySize + sectorSize - 1) / sectorSize)
+}
+
+// New returns an empty table for a disk with the given geometry.
+func New(sectorSize int, sectors uint64) (*Table, error) {
+	reserved := 2*entrySectors(sectorSize, numEntries) + 3
+	if sectors <= reserved {
+		return nil, ErrTooSmall
+	}
+	diskGUID, err := RandomGUID()
+	if err != nil {
+		return nil, err
+	}
+	return &Table{
+		SectorSize:  sectorSize,
+		Sectors:     sectors,
+		DiskGUID:    diskGUID,
+		FirstUsable: 2 + entrySectors(sectorSize, numEntries),
+		LastUsable:  sectors - 2 - entrySectors(sectorSize, numEntries),
+		Entries:     make([]Entry, numEntries),
+	}, nil
+}
+
+// Read reads the table of the disk r with the given geometry, from the
+// primary header or, if that's damaged, from the backup. If the disk has
+// no GPT it returns ErrNoTable, or ErrMBRPartitions if it has MBR
+// partitions which would be lost.
+func Read(r io.ReaderAt, sectorSize int, sectors uint64) (*Table, error) {
+	mbr := make([]byte, sectorSize)
+	if _, err := r.ReadAt(mbr, 0); err != nil {
+		return nil, err
+	}
+	t, err := readHeader(r, sectorSize, sectors, 1)
+	if err == ErrNoTable {
+		t, err = readHeader(r, sectorSize, sectors, sectors-1)
+	}
+	if err == ErrNoTable && hasMBRPartitions(mbr) {
+		return nil, ErrMBRPartitions
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.bootCode = mbr[:440]
+	return t, nil
+}
+
+// readHeader reads the table from the header at lba, returning ErrNoTable
+// if it isn't a valid header.
+func readHeader(r io.ReaderAt, sectorSize int, sectors uint64, lba uint64) (*Table, error) {
+	hdr := make([]byte, sectorSize)
+	if _, err := r.ReadAt(hdr, int64(lba)*int64(sectorSize)); err != nil {
+		return nil, err
+	}
+	le := binary.LittleEndian
+	size := le.Uint32(hdr[12:16])
+	if !bytes.Equal(hdr[0:8], signature) || size < headerSize || int(size) > sectorSize {
+		return nil, ErrNoTable
+	}
+	sum := le.Uint32(hdr[16:20])
+	le.PutUint32(hdr[16:20], 0)
+	if crc32.ChecksumIEEE(hdr[:size]) != sum || le.Uint64(hdr[24:32]) != lba {
+		return nil, ErrNoTable
+	}
+
+	if le.Uint32(hdr[84:88]) != entrySize {
+		return nil, ErrUnsupportedSize
+	}
+	if le.Uint32(hdr[80:84]) > maxEntries {
+		return nil, ErrNoTable
+	}
+	count := int(le.Uint32(hdr[80:84]))
+	// the entries lie between the primary header and the first usable
+	// sector, or between the last usable sector and the backup header
+	entries := le.Uint64(hdr[72:80])
+	end := entries + entrySectors(sectorSize, count)
+	first, last := le.Uint64(hdr[40:48]), le.Uint64(hdr[48:56])
+	if end < entries || !(entries > lba && end <= first) && !(entries > last && end <= lba) {
+		return nil, ErrNoTable
+	}
+	raw := make([]byte, count*entrySize)
+	if _, err := r.ReadAt(raw, int64(entries)*int64(sectorSize)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(raw) != le.Uint32(hdr[88:92]) {
+		return nil, ErrNoTable
+	}
+
+	t := &Table{
+		SectorSize:  sectorSize,
+		Sectors:     sectors,
+		FirstUsable: first,
+		LastUsable:  last,
+		Entries:     make([]Entry, count),
+	}
+	copy(t.DiskGUID[:], hdr[56:72])
+	for i := range t.Entries {
+		t.Entries[i] = decodeEntry(raw[i*entrySize : (i+1)*entrySize])
+	}
+	// the backup is always written to the end of the disk, so a disk which
+	// grew since the table was written keeps its usable range
+	if t.LastUsable > sectors-2-entrySectors(sectorSize, count) {
+		return nil, fmt.Errorf("GPT describes a disk larger than the disk")
+	}
+	return t, nil
+}
+
+// hasMBRPartitions returns whether mbr has partitions other than a GPT
+// protective partition.
+func hasMBRPartitions(mbr []byte) bool {
+	if binary.LittleEndian.Uint16(mbr[510:512]) != mbrSignature {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		entry := mbr[446+16*i : 446+16*(i+1)]
+		if entry[4] != 0 && entry[4] != protectiveOS {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeEntry(raw []byte) Entry {
+	le := binary.LittleEndian
+	var e Entry
+	copy(e.TypeGUID[:], raw[0:16])
+	copy(e.GUID[:], raw[16:32])
+	e.FirstLBA = le.Uint64(raw[32:40])
+	e.LastLBA = le.Uint64(raw[40:48])
+	e.Attributes = le.Uint64(raw[48:56])
+	units := make([]uint16, 0, nameUnits)
+	for i := 0; i < nameUnits; i++ {
+		u := le.Uint16(raw[56+2*i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	e.Name = string(utf16.Decode(units))
+	return e
+}
+
+func encodeEntry(e Entry, raw []byte) error {
+	le := binary.LittleEndian
+	copy(raw[0:16], e.TypeGUID[:])
+	copy(raw[16:32], e.GUID[:])
+	le.PutUint64(raw[32:40], e.FirstLBA)
+	le.PutUint64(raw[40:48], e.LastLBA)
+	le.PutUint64(raw[48:56], e.Attributes)
+	units := utf16.Encode([]rune(e.Name))
+	if len(units) > nameUnits {
+		return fmt.Errorf("partition name %q is longer than %d UTF-16 code units", e.Name, nameUnits)
+	}
+	for i, u := range units {
+		le.PutUint16(raw[56+2*i:], u)
+	}
+	return nil
+}
+
+// Write writes the table to the disk w: a protective MBR, keeping the boot
+// code of the old one, and the primary and backup headers and entries.
+func (t *Table) Write(w io.WriterAt) error {
+	le := binary.LittleEndian
+	ss := int64(t.SectorSize)
+	count := len(t.Entries)
+	raw := make([]byte, int(entrySectors(t.SectorSize, count))*t.SectorSize)
+	for i, e := range t.Entries {
+		if e.Used() {
+			if err := encodeEntry(e, raw[i*entrySize:(i+1)*entrySize]); err != nil {
+				return err
+			}
+		}
+	}
+	entriesSum := crc32.ChecksumIEEE(raw[:count*entrySize])
+
+	last := t.Sectors - 1
+	backupEntries := last - entrySectors(t.SectorSize, count)
+	header := func(lba, alternate, entries uint64) []byte {
+		hdr := make([]byte, t.SectorSize)
+		copy(hdr[0:8], signature)
+		le.PutUint32(hdr[8:12], 0x00010000)
+		le.PutUint32(hdr[12:16], headerSize)
+		le.PutUint64(hdr[24:32], lba)
+		le.PutUint64(hdr[32:40], alternate)
+		le.PutUint64(hdr[40:48], t.FirstUsable)
+		le.PutUint64(hdr[48:56], t.LastUsable)
+		copy(hdr[56:72], t.DiskGUID[:])
+		le.PutUint64(hdr[72:80], entries)
+		le.PutUint32(hdr[80:84], uint32(count))
+		le.PutUint32(hdr[84:88], entrySize)
+		le.PutUint32(hdr[88:92], entriesSum)
+		le.PutUint32(hdr[16:20], crc32.ChecksumIEEE(hdr[:headerSize]))
+		return hdr
+	}
+
+	mbr := make([]byte, t.SectorSize)
+	copy(mbr, t.bootCode)
+	part := mbr[446:462]
+	part[1], part[2], part[3] = 0x00, 0x02, 0x00
+	part[4] = protectiveOS
+	part[5], part[6], part[7] = 0xff, 0xff, 0xff
+	le.PutUint32(part[8:12], 1)
+	size := last
+	if size > 0xffffffff {
+		size = 0xffffffff
+	}
+	le.PutUint32(part[12:16], uint32(size))
+	le.PutUint16(mbr[510:512], mbrSignature)
+
+	writes := []struct {
+		lba  uint64
+		data []byte
+	}{
+		{0, mbr},
+		{2, raw},
+		{1, header(1, last, 2)},
+		{backupEntries, raw},
+		{last, header(last, 1, backupEntries)},
+	}
+	for _, wr := range writes {
+		if _, err := w.WriteAt(wr.data, int64(wr.lba)*ss); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Zap destroys the GPT and MBR of the disk w, as with sgdisk --zap-all.
+func Zap(w io.WriterAt, sectorSize int, sectors uint64) error {
+	reserved := int64(2+entrySectors(sectorSize, numEntries)) * int64(sectorSize)
+	if int64(sectors)*int64(sectorSize) < 2*reserved {
+		return ErrTooSmall
+	}
+	zeros := make([]byte, reserved)
+	if _, err := w.WriteAt(zeros, 0); err != nil {
+		return err
+	}
+	_, err := w.WriteAt(zeros[:reserved-int64(sectorSize)], int64(sectors)*int64(sectorSize)-reserved+int64(sectorSize))
+	return err
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var (
+	ErrDeviceBusy = errors.New("device is busy")
+	ErrNoSpace    = errors.New("not enough space")
+	ErrNoDevice   = errors.New("device does not exist")
+)
+
+// causes maps messages which tools such as mkfs, sgdisk, and mdadm print
+// to stderr to the failures they indicate. Messages are matched without
+// regard to case.
+var causes = []struct {
+	message string
+	cause   error
+}{
+	{"device or resource busy", ErrDeviceBusy},
+	{"is in use", ErrDeviceBusy},
+	{"is mounted", ErrDeviceBusy},
+	{"contains a mounted filesystem", ErrDeviceBusy},
+	{"no space left on device", ErrNoSpace},
+	{"not enough space", ErrNoSpace},
+	{"not large enough", ErrNoSpace},
+	{"could not create partition", ErrNoSpace},
+	{"no such device", ErrNoDevice},
+	{"does not exist", ErrNoDevice},
+}
+
+// CmdError is the error of a command which couldn't be run or exited
+// unsuccessfully, with the output it captured.
+type CmdError struct {
+	// Cmd is the quoted command line.
+	Cmd string
+	// Code is the exit code, or -1 if the command didn't exit.
+	Code   int
+	Stdout []byte
+	Stderr []byte
+	// Err is the error from running the command.
+	Err error
+	// Cause is ErrDeviceBusy, ErrNoSpace, or ErrNoDevice if the output
+	// shows the command failed for that reason, and nil otherwise.
+	Cause error
+}
+
+func (e *CmdError) Error() string {
+	msg := fmt.Sprintf("%v: Cmd: %s Stdout: %q Stderr: %q", e.Err, e.Cmd, e.Stdout, e.Stderr)
+	if e.Cause != nil {
+		return e.Cause.Error() + ": " + msg
+	}
+	return msg
+}
+
+// CmdCause returns the Cause of err if it's a *CmdError, and nil otherwise.
+func CmdCause(err error) error {
+	if cmdErr, ok := err.(*CmdError); ok {
+		return cmdErr.Cause
+	}
+	return nil
+}
+
+// RunCmd runs cmd, capturing its output, and returns its stdout. If it
+// fails, the error is a *CmdError.
+func RunCmd(cmd *exec.Cmd) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		cmdErr := &CmdError{
+			Cmd:    QuotedCmd(cmd),
+			Code:   -1,
+			Stdout: stdout.Bytes(),
+			Stderr: stderr.Bytes(),
+			Err:    err,
+			Cause:  classify(stderr.Bytes()),
+		}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			cmdErr.Code = exitErr.ExitCode()
+		}
+		return stdout.Bytes(), cmdErr
+	}
+	return stdout.Bytes(), nil
+}
+
+// classify returns the cause of the failure described by stderr, if known.
+func classify(stderr []byte) error {
+	lower := strings.ToLower(string(stderr))
+	for _, c := range causes {
+		if strings.Contains(lower, c.message) {
+			return c.cause
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestRunCmd(t *testing.T) {
+	tests := []struct {
+		script string
+		stdout string
+		code   int
+		cause  error
+	}{
+		{"echo ok", "ok\n", 0, nil},
+		{"echo partial; exit 3", "partial\n", 3, nil},
+		{"echo 'mkfs.xfs: cannot open /dev/vdb1: Device or resource busy' >&2; exit 1", "", 1, ErrDeviceBusy},
+		{"echo 'Could not create partition 2 from 34 to 2047' >&2; exit 4", "", 4, ErrNoSpace},
+		{"echo 'The file /dev/vdz does not exist and no size was specified.' >&2; exit 1", "", 1, ErrNoDevice},
+	}
+
+	for i, test := range tests {
+		stdout, err := RunCmd(exec.Command("sh", "-c", test.script))
+		if string(stdout) != test.stdout {
+			t.Errorf("#%d: bad stdout: want %q, got %q", i, test.stdout, stdout)
+		}
+		if test.code == 0 {
+			if err != nil {
+				t.Errorf("#%d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		cmdErr, ok := err.(*CmdError)
+		if !ok {
+			t.Errorf("#%d: expected a *CmdError, got %#v", i, err)
+			continue
+		}
+		if cmdErr.Code != test.code {
+			t.Errorf("#%d: bad exit code: want %d, got %d", i, test.code, cmdErr.Code)
+		}
+		if CmdCause(err) != test.cause {
+			t.Errorf("#%d: bad cause: want %v, got %v", i, test.cause, CmdCause(err))
+		}
+	}
+
+	_, err := RunCmd(exec.Command("/nonexistent/mkfs"))
+	if cmdErr, ok := err.(*CmdError); !ok || cmdErr.Code != -1 {
+		t.Errorf("running a missing command: expected a *CmdError with code -1, got %#v", err)
+	}
+}
@@ -15,11 +15,11 @@
 package log
 
 import (
-	"bytes"
 	"fmt"
 	"log/syslog"
 	"os/exec"
 	"strings"
+	"sync/atomic"
 )
 
 type LoggerOps interface {
@@ -34,11 +34,15 @@ type LoggerOps interface {
 	Close() error
 }
 
-// Logger implements a variadic flavor of log/syslog.Writer
+// Logger implements a variadic flavor of log/syslog.Writer.
+// A Logger must not be used from several goroutines at once; give each its
+// own with Fork.
 type Logger struct {
-	ops           LoggerOps
-	prefixStack   []string
-	opSequenceNum int
+	ops         LoggerOps
+	prefixStack []string
+	// opSequenceNum is shared with the Logger's forks, so that operations
+	// are numbered uniquely across them
+	opSequenceNum *uint32
 }
 
 // New creates a new logger.
@@ -120,6 +124,17 @@ func (l *Logger) PopPrefix() {
 	l.prefixStack = l.prefixStack[:len(l.prefixStack)-1]
 }
 
+// Fork returns a copy of l with its own prefix stack, starting with l's
+// prefixes, for use by another goroutine while l is still in use.
+func (l *Logger) Fork() *Logger {
+	if l.opSequenceNum == nil {
+		l.opSequenceNum = new(uint32)
+	}
+	fork := *l
+	fork.prefixStack = append([]string{}, l.prefixStack...)
+	return &fork
+}
+
 // QuotedCmd returns a concatenated, quoted form of cmd's cmdline
 func QuotedCmd(cmd *exec.Cmd) string {
 	if len(cmd.Args) == 0 {
@@ -136,23 +151,16 @@ func QuotedCmd(cmd *exec.Cmd) string {
 
 // LogCmd runs and logs the supplied cmd as an operation with distinct start/finish/fail log messages uniformly combined with the supplied format string.
 // The exact command path and arguments being executed are also logged for debugging assistance.
+// If the command fails, the error is a *CmdError, whose message includes the command line and its output.
 func (l *Logger) LogCmd(cmd *exec.Cmd, format string, a ...interface{}) (int, error) {
 	code := -1
 	f := func() error {
-		cmdLine := QuotedCmd(cmd)
-		l.Debug("executing: %s", cmdLine)
-
-		stdout := &bytes.Buffer{}
-		stderr := &bytes.Buffer{}
-		cmd.Stdout = stdout
-		cmd.Stderr = stderr
-		if err := cmd.Run(); err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				code = exitErr.ExitCode()
-			}
-			return fmt.Errorf("%v: Cmd: %s Stdout: %q Stderr: %q", err, cmdLine, stdout.Bytes(), stderr.Bytes())
+		l.Debug("executing: %s", QuotedCmd(cmd))
+		_, err := RunCmd(cmd)
+		if cmdErr, ok := err.(*CmdError); ok {
+			code = cmdErr.Code
 		}
-		return nil
+		return err
 	}
 	err := l.LogOp(f, format, a...)
 	return code, err
@@ -160,8 +168,10 @@ func (l *Logger) LogCmd(cmd *exec.Cmd, format string, a ...interface{}) (int, er
 
 // LogOp calls and logs the supplied function as an operation with distinct start/finish/fail log messages uniformly combined with the supplied format string.
 func (l *Logger) LogOp(op func() error, format string, a ...interface{}) error {
-	l.opSequenceNum++
-	l.PushPrefix("op(%x)", l.opSequenceNum)
+	if l.opSequenceNum == nil {
+		l.opSequenceNum = new(uint32)
+	}
+	l.PushPrefix("op(%x)", atomic.AddUint32(l.opSequenceNum, 1))
 	defer l.PopPrefix()
 
 	l.logStart(format, a...)
@@ -0,0 +1,73 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// discard is a LoggerOps which drops every message.
+type discard struct{}
+
+func (discard) Emerg(string) error   { return nil }
+func (discard) Alert(string) error   { return nil }
+func (discard) Crit(string) error    { return nil }
+func (discard) Err(string) error     { return nil }
+func (discard) Warning(string) error { return nil }
+func (discard) Notice(string) error  { return nil }
+func (discard) Info(string) error    { return nil }
+func (discard) Debug(string) error   { return nil }
+func (discard) Close() error         { return nil }
+
+func TestFork(t *testing.T) {
+	logger := Logger{ops: discard{}}
+	logger.PushPrefix("stage")
+
+	var wg sync.WaitGroup
+	prefixes := make([]string, 8)
+	for i := range prefixes {
+		fork := logger.Fork()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				fork.LogOp(func() error {
+					prefixes[i] = fork.sprintf("")
+					return nil
+				}, "op %d", j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if prefix := logger.sprintf(""); prefix != "stage: " {
+		t.Errorf("parent's prefix changed to %q", prefix)
+	}
+	seen := map[string]bool{}
+	for i, prefix := range prefixes {
+		if seen[prefix] {
+			t.Errorf("#%d: operation number in %q reused", i, prefix)
+		}
+		seen[prefix] = true
+	}
+	logger.LogOp(func() error {
+		if prefix, want := logger.sprintf(""), fmt.Sprintf("stage: op(%x): ", 8*100+1); prefix != want {
+			t.Errorf("expected prefix %q, got %q", want, prefix)
+		}
+		return nil
+	}, "last op")
+}
@@ -15,43 +15,86 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/coreos/ignition/v2/config"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
 	"github.com/coreos/ignition/v2/internal/exec"
 	"github.com/coreos/ignition/v2/internal/exec/stages"
 	_ "github.com/coreos/ignition/v2/internal/exec/stages/disks"
 	_ "github.com/coreos/ignition/v2/internal/exec/stages/fetch"
+	_ "github.com/coreos/ignition/v2/internal/exec/stages/fetch_offline"
 	_ "github.com/coreos/ignition/v2/internal/exec/stages/files"
 	_ "github.com/coreos/ignition/v2/internal/exec/stages/mount"
 	_ "github.com/coreos/ignition/v2/internal/exec/stages/umount"
+	"github.com/coreos/ignition/v2/internal/failure"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/platform"
+	"github.com/coreos/ignition/v2/internal/resource"
+	"github.com/coreos/ignition/v2/internal/rollback"
+	"github.com/coreos/ignition/v2/internal/serve"
 	"github.com/coreos/ignition/v2/internal/version"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rollback" {
+		rollbackMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serveMain(os.Args[2:])
+		return
+	}
+
 	flags := struct {
-		clearCache   bool
-		configCache  string
-		fetchTimeout time.Duration
-		platform     platform.Name
-		root         string
-		stage        stages.Name
-		version      bool
-		logToStdout  bool
+		clearCache     bool
+		configCache    string
+		dataURLMaxSize int64
+		memory         resource.MemoryBudget
+		fetchTimeout   time.Duration
+		platform       platform.Name
+		root           string
+		stage          stages.Name
+		version        bool
+		logToStdout    bool
+		acceptYAML     bool
+		limits         config.Limits
+		tpmPCR         int
+		printPlan      bool
+		timeout        time.Duration
+		digestFile     string
+		reconcile      bool
 	}{}
 
 	flag.BoolVar(&flags.clearCache, "clear-cache", false, "clear any cached config")
 	flag.StringVar(&flags.configCache, "config-cache", "/run/ignition.json", "where to cache the config")
+	flag.Int64Var(&flags.dataURLMaxSize, "data-url-max-size", resource.DefaultMaxDataURLSize, "maximum size in bytes of the decoded contents of data URLs")
+	flag.Int64Var(&flags.memory.PerFetch, "max-fetch-memory", resource.DefaultMaxFetchMemory, "maximum size in bytes of the fetched contents one fetch may hold in memory, or 0 for no limit")
+	flag.Int64Var(&flags.memory.Total, "max-total-fetch-memory", resource.DefaultMaxTotalFetchMemory, "maximum size in bytes of the fetched contents all fetches may hold in memory together, or 0 for no limit")
 	flag.DurationVar(&flags.fetchTimeout, "fetch-timeout", exec.DefaultFetchTimeout, "initial duration for which to wait for config")
 	flag.Var(&flags.platform, "platform", fmt.Sprintf("current platform. %v", platform.Names()))
 	flag.StringVar(&flags.root, "root", "/", "root of the filesystem")
 	flag.Var(&flags.stage, "stage", fmt.Sprintf("execution stage. %v", stages.Names()))
 	flag.BoolVar(&flags.version, "version", false, "print the version and exit")
 	flag.BoolVar(&flags.logToStdout, "log-to-stdout", false, "log to stdout instead of the system log when set")
+	flag.BoolVar(&flags.acceptYAML, "accept-yaml", false, "accept configs written in YAML as well as JSON")
+	flag.IntVar(&flags.limits.MaxConfigBytes, "max-config-size", config.DefaultLimits.MaxConfigBytes, "maximum size in bytes of each config, or 0 for no limit")
+	flag.IntVar(&flags.limits.MaxNodes, "max-nodes", config.DefaultLimits.MaxNodes, "maximum number of files, directories, and links in the config, or 0 for no limit")
+	flag.IntVar(&flags.limits.MaxInlineBytes, "max-inline-size", config.DefaultLimits.MaxInlineBytes, "maximum length in bytes of data URLs in the config, or 0 for no limit")
+	flag.IntVar(&flags.tpmPCR, "tpm-pcr", 0, "PCR into which to measure the fetched config, or 0 to not measure it")
+	flag.BoolVar(&flags.printPlan, "print-plan", false, "print the changes the stage would make to the filesystem, in order, instead of making them")
+	flag.DurationVar(&flags.timeout, "timeout", 0, "duration after which to abort the stage, or 0 to never abort it")
+	flag.StringVar(&flags.digestFile, "digest-file", "", "file in which to record the digest of the config once the stage has run")
+	flag.BoolVar(&flags.reconcile, "reconcile", false, "don't run the stage if the config's digest is the one recorded in the digest file")
 
 	flag.Parse()
 
@@ -70,6 +113,11 @@ func main() {
 		os.Exit(2)
 	}
 
+	if flags.reconcile && flags.digestFile == "" {
+		fmt.Fprint(os.Stderr, "'--reconcile' requires '--digest-file'\n")
+		os.Exit(2)
+	}
+
 	logger := log.New(flags.logToStdout)
 	defer logger.Close()
 
@@ -88,6 +136,17 @@ func main() {
 		logger.Crit("failed to generate fetcher: %s", err)
 		os.Exit(3)
 	}
+	fetcher.MaxDataURLSize = flags.dataURLMaxSize
+	fetcher.Memory = &flags.memory
+	fetcher.AcceptYAML = flags.acceptYAML
+	fetcher.Limits = flags.limits
+	// the fetch-offline stage never uses the network, and instead signals
+	// whether the later stages need it
+	fetcher.Offline = flags.stage == "fetch-offline"
+	if fetcher.Caches, err = resource.KernelCaches(); err != nil {
+		logger.Crit("failed to read caches: %s", err)
+		os.Exit(3)
+	}
 	engine := exec.Engine{
 		Root:           flags.root,
 		FetchTimeout:   flags.fetchTimeout,
@@ -95,15 +154,130 @@ func main() {
 		ConfigCache:    flags.configCache,
 		PlatformConfig: platformConfig,
 		Fetcher:        &fetcher,
+		TPMPCR:         flags.tpmPCR,
+		PrintPlan:      flags.printPlan,
+		DigestFile:     flags.digestFile,
+		Reconcile:      flags.reconcile,
 	}
 
-	err = engine.Run(flags.stage.String())
-	if statusErr := engine.PlatformConfig.Status(flags.stage.String(), *engine.Fetcher, err); statusErr != nil {
-		logger.Err("POST Status error: %v", statusErr.Error())
+	ctx, cancel := abortContext(flags.timeout)
+	defer cancel()
+	err = engine.Run(ctx, flags.stage.String())
+	if ctx.Err() != nil {
+		logger.Err("stage aborted: %v", ctx.Err())
+	}
+	// reporting the status requires networking, and a plan isn't a run
+	if !fetcher.Offline && !flags.printPlan {
+		if statusErr := engine.PlatformConfig.Status(flags.stage.String(), *engine.Fetcher, err); statusErr != nil {
+			logger.Err("POST Status error: %v", statusErr.Error())
+		}
 	}
 	if err != nil {
 		logger.Crit("Ignition failed: %v", err.Error())
-		os.Exit(1)
+		os.Exit(failure.ExitCode(err))
 	}
 	logger.Info("Ignition finished successfully")
 }
+
+// abortContext returns a context which is cancelled on SIGTERM or SIGINT,
+// or once timeout has passed unless it's 0, so the stage can stop its
+// fetches and commands and clean up after itself rather than being killed
+// midway.
+func abortContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout != 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+// rollbackMain restores the paths saved by the files stage when the config
+// enables storage.rollback.
+func rollbackMain(args []string) {
+	flags := struct {
+		root        string
+		logToStdout bool
+	}{}
+
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	fs.StringVar(&flags.root, "root", "/", "root of the filesystem")
+	fs.BoolVar(&flags.logToStdout, "log-to-stdout", false, "log to stdout instead of the system log when set")
+	fs.Parse(args)
+
+	logger := log.New(flags.logToStdout)
+	defer logger.Close()
+
+	if err := rollback.Restore(&logger, distro.RollbackDir(), flags.root); err != nil {
+		logger.Crit("Ignition rollback failed: %v", err)
+		os.Exit(1)
+	}
+	logger.Info("Ignition rollback finished successfully")
+}
+
+// serveMain serves the configs in a directory to the machines they match.
+func serveMain(args []string) {
+	flags := struct {
+		listen      string
+		dir         string
+		cacheDir    string
+		cacheSrcs   string
+		cacheObjMax int64
+		cacheMax    int64
+		logToStdout bool
+	}{}
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&flags.listen, "listen", ":8080", "address on which to listen")
+	fs.StringVar(&flags.dir, "dir", ".", "directory holding the configs to serve")
+	fs.StringVar(&flags.cacheDir, "cache-dir", "", "directory in which to cache verified objects for machines, if set")
+	fs.StringVar(&flags.cacheSrcs, "cache-sources", "", "comma-separated host names and S3 buckets which the cache may fetch objects from")
+	fs.Int64Var(&flags.cacheObjMax, "cache-max-object-size", 16<<30, "maximum size in bytes of an object in the cache, or 0 for no limit")
+	fs.Int64Var(&flags.cacheMax, "cache-max-size", 128<<30, "maximum total size in bytes of the objects in the cache, or 0 for no limit")
+	fs.BoolVar(&flags.logToStdout, "log-to-stdout", false, "log to stdout instead of the system log when set")
+	fs.Parse(args)
+
+	if flags.cacheDir != "" && flags.cacheSrcs == "" {
+		fmt.Fprint(os.Stderr, "'--cache-dir' requires '--cache-sources'\n")
+		os.Exit(2)
+	}
+
+	logger := log.New(flags.logToStdout)
+	defer logger.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle(serve.ConfigPath, serve.Server{Dir: flags.dir, Logger: &logger})
+	logger.Info("serving configs from %q at http://%s%s", flags.dir, flags.listen, serve.ConfigPath)
+	if flags.cacheDir != "" {
+		fetcher := resource.Fetcher{Logger: &logger}
+		if err := fetcher.UpdateHttpTimeoutsAndCAs(types.Timeouts{}, nil, types.Proxy{}); err != nil {
+			logger.Crit("failed to set up fetching: %v", err)
+			os.Exit(3)
+		}
+		mux.Handle(serve.CachePath, &serve.Cache{
+			Dir:           flags.cacheDir,
+			Logger:        &logger,
+			Fetcher:       &fetcher,
+			Sources:       strings.Split(flags.cacheSrcs, ","),
+			MaxObjectSize: flags.cacheObjMax,
+			MaxSize:       flags.cacheMax,
+		})
+		logger.Info("caching objects in %q at http://%s%s", flags.cacheDir, flags.listen, serve.CachePath)
+	}
+	if err := http.ListenAndServe(flags.listen, mux); err != nil {
+		logger.Crit("Ignition serve failed: %v", err)
+		os.Exit(1)
+	}
+}
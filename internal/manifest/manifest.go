@@ -0,0 +1,138 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package manifest records the paths Ignition has written to the provisioned
+// system, with digests of what it wrote, so that tooling can tell the paths
+// Ignition manages from local changes, and paths dropped from the config can
+// be removed when it's applied again.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/renameio"
+)
+
+// The kinds of path.
+const (
+	File      = "file"
+	Directory = "directory"
+	Link      = "link"
+)
+
+const header = "# paths written by Ignition: kind, digest, path -- DO NOT EDIT\n"
+
+// Entry is a path as Ignition wrote it.
+type Entry struct {
+	Kind string
+	// Digest is the SHA-256 digest of the contents of a file or the target
+	// of a link, or "-" for a directory.
+	Digest string
+	// Path is the path in the provisioned system.
+	Path string
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("%s %s %s", e.Kind, e.Digest, e.Path)
+}
+
+// Describe returns the entry for what is at path in the root, which is
+// false if nothing is there.
+func Describe(root, path string) (Entry, bool, error) {
+	full := filepath.Join(root, path)
+	st, err := os.Lstat(full)
+	if os.IsNotExist(err) {
+		return Entry{}, false, nil
+	} else if err != nil {
+		return Entry{}, false, err
+	}
+	e := Entry{Path: path}
+	switch {
+	case st.Mode().IsRegular():
+		e.Kind = File
+		f, err := os.Open(full)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		defer f.Close()
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, f); err != nil {
+			return Entry{}, false, err
+		}
+		e.Digest = "sha256-" + hex.EncodeToString(hasher.Sum(nil))
+	case st.Mode()&os.ModeSymlink != 0:
+		e.Kind = Link
+		target, err := os.Readlink(full)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		sum := sha256.Sum256([]byte(target))
+		e.Digest = "sha256-" + hex.EncodeToString(sum[:])
+	case st.IsDir():
+		e.Kind = Directory
+		e.Digest = "-"
+	default:
+		return Entry{}, false, fmt.Errorf("%s is neither a file, a directory, nor a link", full)
+	}
+	return e, true, nil
+}
+
+// Read returns the entries of the manifest at path, which are none if it
+// doesn't exist.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// the path is last since it may hold spaces
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed manifest line %q", line)
+		}
+		entries = append(entries, Entry{Kind: fields[0], Digest: fields[1], Path: fields[2]})
+	}
+	return entries, scanner.Err()
+}
+
+// Write replaces the manifest at path with entries.
+func Write(path string, entries []Entry) error {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	for _, e := range entries {
+		fmt.Fprintln(&buf, e)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return renameio.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-manifest-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	if err := os.MkdirAll(filepath.Join(td, "etc", "my dir"), 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(td, "etc", "my dir", "motd"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := os.Symlink("/dev/null", filepath.Join(td, "etc", "masked.service")); err != nil {
+		t.Fatalf("symlink error: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		exists bool
+		out    Entry
+	}{
+		{"/etc/my dir", true, Entry{Directory, "-", "/etc/my dir"}},
+		{"/etc/my dir/motd", true, Entry{File, "sha256-2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", "/etc/my dir/motd"}},
+		{"/etc/masked.service", true, Entry{Link, "sha256-fd5d32feb2d3562582258990ecfca9b88376957e512d5caac72ad89fc78d2df4", "/etc/masked.service"}},
+		{"/etc/missing", false, Entry{}},
+	}
+	var entries []Entry
+	for i, test := range tests {
+		out, exists, err := Describe(td, test.path)
+		if err != nil {
+			t.Errorf("#%d: describe error: %v", i, err)
+			continue
+		}
+		if exists != test.exists || out != test.out {
+			t.Errorf("#%d: expected %v (%t), got %v (%t)", i, test.out, test.exists, out, exists)
+		}
+		if exists {
+			entries = append(entries, out)
+		}
+	}
+
+	path := filepath.Join(td, "etc", "ignition-managed.paths")
+	if read, err := Read(path); err != nil || read != nil {
+		t.Fatalf("expected no entries in missing manifest, got %v, %v", read, err)
+	}
+	if err := Write(path, entries); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	read, err := Read(path)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if !reflect.DeepEqual(entries, read) {
+		t.Errorf("expected %v, got %v", entries, read)
+	}
+}
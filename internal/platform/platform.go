@@ -19,23 +19,8 @@ import (
 
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/providers"
-	"github.com/coreos/ignition/v2/internal/providers/aliyun"
-	"github.com/coreos/ignition/v2/internal/providers/aws"
-	"github.com/coreos/ignition/v2/internal/providers/azure"
-	"github.com/coreos/ignition/v2/internal/providers/cloudstack"
-	"github.com/coreos/ignition/v2/internal/providers/digitalocean"
-	"github.com/coreos/ignition/v2/internal/providers/exoscale"
 	"github.com/coreos/ignition/v2/internal/providers/file"
-	"github.com/coreos/ignition/v2/internal/providers/gcp"
-	"github.com/coreos/ignition/v2/internal/providers/ibmcloud"
 	"github.com/coreos/ignition/v2/internal/providers/noop"
-	"github.com/coreos/ignition/v2/internal/providers/openstack"
-	"github.com/coreos/ignition/v2/internal/providers/packet"
-	"github.com/coreos/ignition/v2/internal/providers/qemu"
-	"github.com/coreos/ignition/v2/internal/providers/virtualbox"
-	"github.com/coreos/ignition/v2/internal/providers/vmware"
-	"github.com/coreos/ignition/v2/internal/providers/vultr"
-	"github.com/coreos/ignition/v2/internal/providers/zvm"
 	"github.com/coreos/ignition/v2/internal/registry"
 	"github.com/coreos/ignition/v2/internal/resource"
 )
@@ -46,12 +31,20 @@ type Config struct {
 	fetch      providers.FuncFetchConfig
 	newFetcher providers.FuncNewFetcher
 	status     providers.FuncPostStatus
+	// offline platforms forbid networking, so configs may only have
+	// sources which are fetched without it
+	offline bool
 }
 
 func (c Config) Name() string {
 	return c.name
 }
 
+// Offline reports whether the platform forbids networking.
+func (c Config) Offline() bool {
+	return c.offline
+}
+
 func (c Config) FetchFunc() providers.FuncFetchConfig {
 	return c.fetch
 }
@@ -78,79 +71,18 @@ func (c Config) Status(stageName string, f resource.Fetcher, statusErr error) er
 var configs = registry.Create("platform configs")
 
 func init() {
-	configs.Register(Config{
-		name:  "aliyun",
-		fetch: aliyun.FetchConfig,
-	})
-	configs.Register(Config{
-		name:       "aws",
-		fetch:      aws.FetchConfig,
-		newFetcher: aws.NewFetcher,
-	})
-	configs.Register(Config{
-		name:  "azure",
-		fetch: azure.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "brightbox",
-		fetch: openstack.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "cloudstack",
-		fetch: cloudstack.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "digitalocean",
-		fetch: digitalocean.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "exoscale",
-		fetch: exoscale.FetchConfig,
-	})
 	configs.Register(Config{
 		name:  "file",
 		fetch: file.FetchConfig,
 	})
-	configs.Register(Config{
-		name:  "gcp",
-		fetch: gcp.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "ibmcloud",
-		fetch: ibmcloud.FetchConfig,
-	})
 	configs.Register(Config{
 		name:  "metal",
 		fetch: noop.FetchConfig,
 	})
 	configs.Register(Config{
-		name:  "openstack",
-		fetch: openstack.FetchConfig,
-	})
-	configs.Register(Config{
-		name:   "packet",
-		fetch:  packet.FetchConfig,
-		status: packet.PostStatus,
-	})
-	configs.Register(Config{
-		name:  "qemu",
-		fetch: qemu.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "virtualbox",
-		fetch: virtualbox.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "vmware",
-		fetch: vmware.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "vultr",
-		fetch: vultr.FetchConfig,
-	})
-	configs.Register(Config{
-		name:  "zvm",
-		fetch: zvm.FetchConfig,
+		name:    "metal-offline",
+		fetch:   noop.FetchConfig,
+		offline: true,
 	})
 }
 
@@ -53,7 +53,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	}
 	f.S3RegionHint = regionHint
 
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f, data)
 }
 
 func NewFetcher(l *log.Logger) (resource.Fetcher, error) {
@@ -83,7 +83,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return types.Config{}, report.Report{}, fmt.Errorf("failed to read config: %v", err)
 	}
 
-	return util.ParseConfig(logger, rawConfig)
+	return util.ParseConfig(f, rawConfig)
 }
 
 func waitForCdrom(logger *log.Logger, devicePath string) {
@@ -84,7 +84,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		f.Logger.Info("neither config drive nor metadata service were available in time. Continuing without a config...")
 	}
 
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f, data)
 }
 
 func fileExists(path string) bool {
@@ -198,6 +198,10 @@ func fetchConfigFromMetadataService(f *resource.Fetcher) ([]byte, error) {
 		return nil, err
 	}
 
+	// an IPv6 address must be bracketed in the URL
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		addr = "[" + addr + "]"
+	}
 	metadataServiceUrl := url.URL{
 		Scheme: "http",
 		Host:   addr,
@@ -13,7 +13,10 @@
 // limitations under the License.
 
 // The cmdline provider fetches a remote configuration from the URL specified
-// in the kernel boot option "ignition.config.url".
+// in the kernel boot option "ignition.config.url", in which placeholders
+// such as ${mac} are replaced with identifiers of the machine. It also
+// reads the fingerprints of the keys trusted to sign configs from the
+// repeatable option "ignition.config.signature.fingerprint".
 
 package cmdline
 
@@ -33,7 +36,8 @@ import (
 )
 
 const (
-	cmdlineUrlFlag = "ignition.config.url"
+	cmdlineUrlFlag         = "ignition.config.url"
+	cmdlineFingerprintFlag = "ignition.config.signature.fingerprint"
 )
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
@@ -51,7 +55,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return types.Config{}, report.Report{}, err
 	}
 
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f, data)
 }
 
 func readCmdline(logger *log.Logger) (*url.URL, error) {
@@ -68,6 +72,12 @@ func readCmdline(logger *log.Logger) (*url.URL, error) {
 		return nil, nil
 	}
 
+	if rawUrl, err = expandURL(rawUrl, placeholders); err != nil {
+		logger.Err("failed to expand url: %v", err)
+		return nil, err
+	}
+	logger.Debug("expanded url to %q", rawUrl)
+
 	url, err := url.Parse(rawUrl)
 	if err != nil {
 		logger.Err("failed to parse url: %v", err)
@@ -93,3 +103,28 @@ func parseCmdline(cmdline []byte) (url string) {
 
 	return
 }
+
+// SignatureFingerprints returns the fingerprints of the keys which the kernel
+// command line trusts to sign configs.
+func SignatureFingerprints(logger *log.Logger) ([]string, error) {
+	args, err := ioutil.ReadFile(distro.KernelCmdlinePath())
+	if err != nil {
+		logger.Err("couldn't read cmdline: %v", err)
+		return nil, err
+	}
+
+	fingerprints := parseFingerprints(args)
+	logger.Debug("parsed signature fingerprints from cmdline: %q", fingerprints)
+	return fingerprints, nil
+}
+
+func parseFingerprints(cmdline []byte) (fingerprints []string) {
+	for _, arg := range strings.Split(string(cmdline), " ") {
+		parts := strings.SplitN(strings.TrimSpace(arg), "=", 2)
+		if parts[0] == cmdlineFingerprintFlag && len(parts) == 2 && parts[1] != "" {
+			fingerprints = append(fingerprints, strings.ToLower(parts[1]))
+		}
+	}
+
+	return
+}
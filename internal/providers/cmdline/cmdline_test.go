@@ -0,0 +1,95 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFingerprints(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{
+			in: "BOOT_IMAGE=/vmlinuz ignition.config.url=http://example.com/config.ign",
+		},
+		{
+			in:  "ignition.config.signature.fingerprint=sha256:AB12 quiet\n",
+			out: []string{"sha256:ab12"},
+		},
+		{
+			in:  "ignition.config.signature.fingerprint=sha256:01 ignition.config.signature.fingerprint= ignition.config.signature.fingerprint=sha256:02",
+			out: []string{"sha256:01", "sha256:02"},
+		},
+	}
+
+	for i, test := range tests {
+		out := parseFingerprints([]byte(test.in))
+		if !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: bad fingerprints: want %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestExpandURL(t *testing.T) {
+	values := map[string]func() (string, error){
+		"mac":    func() (string, error) { return "52-54-00-12-34-56", nil },
+		"serial": func() (string, error) { return "ABC 123/4", nil },
+		"uuid":   func() (string, error) { return "", errors.New("no uuid") },
+	}
+	tests := []struct {
+		in  string
+		out string
+		err bool
+	}{
+		{
+			in:  "http://example.com/config.ign",
+			out: "http://example.com/config.ign",
+		},
+		{
+			in:  "http://example.com/mac/${mac}.ign",
+			out: "http://example.com/mac/52-54-00-12-34-56.ign",
+		},
+		{
+			// values are escaped
+			in:  "http://example.com/config.ign?mac=${mac}&serial=${serial}",
+			out: "http://example.com/config.ign?mac=52-54-00-12-34-56&serial=ABC%20123%2F4",
+		},
+		{
+			in:  "http://example.com/uuid/${uuid}.ign",
+			err: true,
+		},
+		{
+			in:  "http://example.com/${hostname}.ign",
+			err: true,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := expandURL(test.in, values)
+		if test.err {
+			if err == nil {
+				t.Errorf("#%d: expected an error, got %q", i, out)
+			}
+			continue
+		}
+		if err != nil || out != test.out {
+			t.Errorf("#%d: expected %q, got %q, %v", i, test.out, out, err)
+		}
+	}
+}
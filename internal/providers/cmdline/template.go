@@ -0,0 +1,93 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdline
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+var placeholderRegexp = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// placeholders maps the placeholders a config URL can hold to functions
+// returning their values.
+var placeholders = map[string]func() (string, error){
+	"mac":    primaryMAC,
+	"serial": func() (string, error) { return readIdentifier(distro.ProductSerialPath()) },
+	"uuid": func() (string, error) {
+		uuid, err := readIdentifier(distro.ProductUUIDPath())
+		return strings.ToLower(uuid), err
+	},
+}
+
+// expandURL substitutes the placeholders in rawURL, such as ${mac}, with
+// their values, escaped for use anywhere in a URL.
+func expandURL(rawURL string, values map[string]func() (string, error)) (string, error) {
+	var err error
+	expanded := placeholderRegexp.ReplaceAllStringFunc(rawURL, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-1]
+		value, ok := values[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("unknown placeholder %q in config URL", placeholder)
+			}
+			return placeholder
+		}
+		v, verr := value()
+		if verr != nil {
+			if err == nil {
+				err = fmt.Errorf("expanding %s in config URL: %v", placeholder, verr)
+			}
+			return placeholder
+		}
+		return strings.Replace(url.QueryEscape(v), "+", "%20", -1)
+	})
+	return expanded, err
+}
+
+// primaryMAC returns the MAC address of the first network interface which
+// has one, other than loopback, with dashes rather than colons.
+func primaryMAC() (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 && len(iface.HardwareAddr) > 0 {
+			return strings.Replace(iface.HardwareAddr.String(), ":", "-", -1), nil
+		}
+	}
+	return "", fmt.Errorf("no network interface has a MAC address")
+}
+
+// readIdentifier returns the identifier of the machine in the file at path,
+// failing if it's empty.
+func readIdentifier(path string) (string, error) {
+	value, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(value))
+	if id == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return id, nil
+}
@@ -0,0 +1,267 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The dhcp provider reads the config, or the URL of the config, from the
+// site-specific DHCP option named by the kernel boot option
+// "ignition.config.dhcp.option", or the DHCPv6 option named by
+// "ignition.config.dhcp6.option", in the leases the initramfs's DHCP client
+// saved. Leases of systemd-networkd and of dhclient are understood.
+
+package dhcp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/providers"
+	"github.com/coreos/ignition/v2/internal/providers/util"
+	"github.com/coreos/ignition/v2/internal/resource"
+
+	"github.com/coreos/vcontext/report"
+)
+
+const (
+	cmdlineOptionFlag  = "ignition.config.dhcp.option"
+	cmdlineOption6Flag = "ignition.config.dhcp6.option"
+
+	// the site-specific options, the only ones DHCP clients save without
+	// interpreting them
+	minOption = 224
+	maxOption = 254
+	// DHCPv6 has no site-specific options (RFC 8415), so any option the
+	// client doesn't interpret may be used
+	minOption6 = 1
+	maxOption6 = 65535
+)
+
+// option is a DHCP option, of DHCPv4 or of DHCPv6.
+type option struct {
+	code int
+	v6   bool
+}
+
+func (o option) String() string {
+	if o.v6 {
+		return fmt.Sprintf("DHCPv6 option %d", o.code)
+	}
+	return fmt.Sprintf("option %d", o.code)
+}
+
+func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
+	options, err := readCmdline(f.Logger)
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	if len(options) == 0 {
+		return types.Config{}, report.Report{}, providers.ErrNoProvider
+	}
+	if f.Offline {
+		// there are no leases without networking
+		return types.Config{}, report.Report{}, resource.ErrNeedNet
+	}
+
+	var value []byte
+	for _, option := range options {
+		value, err = findOption(f.Logger, distro.DHCPLeaseDir(), option)
+		if err != nil {
+			return types.Config{}, report.Report{}, err
+		}
+		if value != nil {
+			break
+		}
+		f.Logger.Info("no DHCP lease has %s", option)
+	}
+	if value == nil {
+		return types.Config{}, report.Report{}, providers.ErrNoProvider
+	}
+
+	// a small config may be inlined into the option itself
+	if bytes.HasPrefix(value, []byte("{")) {
+		return util.ParseConfig(f, value)
+	}
+	u, err := url.Parse(string(value))
+	if err != nil {
+		f.Logger.Err("failed to parse url: %v", err)
+		return types.Config{}, report.Report{}, err
+	}
+	data, err := f.FetchToBuffer(*u, resource.FetchOptions{})
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+	return util.ParseConfig(f, data)
+}
+
+// readCmdline returns the DHCP options named on the kernel command line,
+// the DHCPv4 one first.
+func readCmdline(logger *log.Logger) ([]option, error) {
+	args, err := ioutil.ReadFile(distro.KernelCmdlinePath())
+	if err != nil {
+		logger.Err("couldn't read cmdline: %v", err)
+		return nil, err
+	}
+	options, err := parseCmdline(args)
+	if err != nil {
+		logger.Err("%v", err)
+		return nil, err
+	}
+	return options, nil
+}
+
+func parseCmdline(cmdline []byte) ([]option, error) {
+	var v4, v6 option
+	for _, arg := range strings.Split(string(cmdline), " ") {
+		parts := strings.SplitN(strings.TrimSpace(arg), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case cmdlineOptionFlag:
+			code, err := strconv.Atoi(parts[1])
+			if err != nil || code < minOption || code > maxOption {
+				return nil, fmt.Errorf("invalid %s: option must be a number from %d to %d", cmdlineOptionFlag, minOption, maxOption)
+			}
+			v4 = option{code: code}
+		case cmdlineOption6Flag:
+			code, err := strconv.Atoi(parts[1])
+			if err != nil || code < minOption6 || code > maxOption6 {
+				return nil, fmt.Errorf("invalid %s: option must be a number from %d to %d", cmdlineOption6Flag, minOption6, maxOption6)
+			}
+			v6 = option{code: code, v6: true}
+		}
+	}
+
+	var options []option
+	for _, o := range []option{v4, v6} {
+		if o.code != 0 {
+			options = append(options, o)
+		}
+	}
+	return options, nil
+}
+
+// findOption returns the value of option in the first lease in dir which
+// has it, in the order of the leases' file names, or nil if none has it.
+func findOption(logger *log.Logger, dir string, option option) ([]byte, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		lease, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		value, err := leaseOption(lease, option)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse lease %q: %v", path, err)
+		}
+		if value != nil {
+			logger.Info("using %s of DHCP lease %q", option, path)
+			return value, nil
+		}
+	}
+	return nil, nil
+}
+
+// leaseOption returns the value of option in a lease saved by
+// systemd-networkd, as OPTION_<n>=<hex>, or by dhclient, as
+// "option unknown-<n> <value>;" or, for DHCPv6,
+// "option dhcp6.unknown-<n> <value>;", or nil if the lease doesn't have it.
+// systemd-networkd doesn't save DHCPv6 leases. dhclient appends each renewed
+// lease to the file, so the last value wins. Trailing NUL bytes, which some
+// servers send, are removed.
+func leaseOption(lease []byte, option option) (value []byte, err error) {
+	networkdKey := fmt.Sprintf("OPTION_%d=", option.code)
+	dhclientKey := fmt.Sprintf("option unknown-%d ", option.code)
+	if option.v6 {
+		networkdKey = ""
+		dhclientKey = fmt.Sprintf("option dhcp6.unknown-%d ", option.code)
+	}
+	for _, line := range strings.Split(string(lease), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case networkdKey != "" && strings.HasPrefix(line, networkdKey):
+			value, err = hex.DecodeString(strings.TrimPrefix(line, networkdKey))
+		case strings.HasPrefix(line, dhclientKey):
+			value, err = parseDhclientValue(strings.TrimSuffix(strings.TrimPrefix(line, dhclientKey), ";"))
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value != nil {
+		value = bytes.TrimRight(value, "\x00")
+	}
+	return value, nil
+}
+
+// parseDhclientValue decodes an option value as dhclient writes it: a
+// quoted string with C-style escapes if it's printable, and colon-separated
+// hexadecimal bytes otherwise.
+func parseDhclientValue(s string) ([]byte, error) {
+	if strings.HasPrefix(s, `"`) {
+		if !strings.HasSuffix(s, `"`) || len(s) < 2 {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		s = s[1 : len(s)-1]
+		value := []byte{}
+		for i := 0; i < len(s); i++ {
+			if s[i] != '\\' {
+				value = append(value, s[i])
+				continue
+			}
+			if i+3 < len(s) {
+				if b, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+					value = append(value, byte(b))
+					i += 3
+					continue
+				}
+			}
+			if i+1 == len(s) {
+				return nil, fmt.Errorf("trailing backslash in %q", s)
+			}
+			value = append(value, s[i+1])
+			i++
+		}
+		return value, nil
+	}
+
+	value := []byte{}
+	for _, part := range strings.Split(s, ":") {
+		b, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte %q", part)
+		}
+		value = append(value, byte(b))
+	}
+	return value, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dhcp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCmdline(t *testing.T) {
+	tests := []struct {
+		in    string
+		out   []option
+		valid bool
+	}{
+		{"BOOT_IMAGE=/vmlinuz quiet", nil, true},
+		{"ignition.config.dhcp.option=224 quiet\n", []option{{code: 224}}, true},
+		{"ignition.config.dhcp6.option=65000", []option{{code: 65000, v6: true}}, true},
+		{"ignition.config.dhcp6.option=65000 ignition.config.dhcp.option=224", []option{{code: 224}, {code: 65000, v6: true}}, true},
+		{"ignition.config.dhcp.option=67", nil, false},
+		{"ignition.config.dhcp.option=url", nil, false},
+		{"ignition.config.dhcp6.option=65536", nil, false},
+	}
+
+	for i, test := range tests {
+		out, err := parseCmdline([]byte(test.in))
+		if test.valid != (err == nil) {
+			t.Errorf("#%d: expected valid: %v, got %v", i, test.valid, err)
+		} else if !reflect.DeepEqual(out, test.out) {
+			t.Errorf("#%d: expected options %v, got %v", i, test.out, out)
+		}
+	}
+}
+
+func TestLeaseOption(t *testing.T) {
+	tests := []struct {
+		option option
+		in     string
+		out    string
+	}{
+		{
+			// systemd-networkd
+			option: option{code: 224},
+			in:     "# This is private data. Do not parse.\nADDRESS=192.0.2.10\nOPTION_224=68747470733a2f2f6578616d706c652e636f6d2f612e69676e00\n",
+			out:    "https://example.com/a.ign",
+		},
+		{
+			option: option{code: 224},
+			in:     "ADDRESS=192.0.2.10\nOPTION_225=7b7d\n",
+			out:    "",
+		},
+		{
+			// dhclient, with a renewed lease appended
+			option: option{code: 224},
+			in: "lease {\n  interface \"eth0\";\n  option unknown-224 \"https://example.com/old.ign\";\n}\n" +
+				"lease {\n  interface \"eth0\";\n  option unknown-224 \"https://example.com/\\141.ign\\\"\";\n}\n",
+			out: "https://example.com/a.ign\"",
+		},
+		{
+			option: option{code: 224},
+			in:     "lease {\n  option unknown-224 7b:7d:0;\n}\n",
+			out:    "{}",
+		},
+		{
+			// dhclient -6
+			option: option{code: 65000, v6: true},
+			in:     "lease6 {\n  interface \"eth0\";\n  option dhcp6.unknown-65000 \"http://[2001:db8::1]/a.ign\";\n}\n",
+			out:    "http://[2001:db8::1]/a.ign",
+		},
+		{
+			// a DHCPv4 option of the same code
+			option: option{code: 224, v6: true},
+			in:     "OPTION_224=7b7d\nlease {\n  option unknown-224 7b:7d;\n}\n",
+			out:    "",
+		},
+	}
+
+	for i, test := range tests {
+		out, err := leaseOption([]byte(test.in), test.option)
+		if err != nil {
+			t.Errorf("#%d: leaseOption failed: %v", i, err)
+		} else if string(out) != test.out {
+			t.Errorf("#%d: expected %q, got %q", i, test.out, out)
+		}
+	}
+
+	if _, err := leaseOption([]byte("option unknown-224 \"unterminated;\n"), option{code: 224}); err == nil {
+		t.Errorf("unterminated string was accepted")
+	}
+}
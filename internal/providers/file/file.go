@@ -15,10 +15,13 @@
 package file
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/providers/util"
 	"github.com/coreos/ignition/v2/internal/resource"
 
@@ -28,8 +31,14 @@ import (
 const (
 	cfgFilenameEnvVar = "IGNITION_CONFIG_FILE"
 	defaultFilename   = "config.ign"
+	// how long to wait for the config file to appear, as a duration such
+	// as "30s", for configs generated after the machine is started
+	cfgTimeoutEnvVar = "IGNITION_CONFIG_FILE_TIMEOUT"
 )
 
+// pollInterval is how often to check whether the config file has appeared.
+var pollInterval = time.Second
+
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	filename := os.Getenv(cfgFilenameEnvVar)
 	if filename == "" {
@@ -38,10 +47,35 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	}
 	f.Logger.Info("using config file at %q", filename)
 
+	if timeout := os.Getenv(cfgTimeoutEnvVar); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return types.Config{}, report.Report{}, fmt.Errorf("invalid %s %q: %v", cfgTimeoutEnvVar, timeout, err)
+		}
+		if err := waitForFile(f.Logger, filename, d); err != nil {
+			f.Logger.Err("couldn't read config %q: %v", filename, err)
+			return types.Config{}, report.Report{}, err
+		}
+	}
+
 	rawConfig, err := ioutil.ReadFile(filename)
 	if err != nil {
 		f.Logger.Err("couldn't read config %q: %v", filename, err)
 		return types.Config{}, report.Report{}, err
 	}
-	return util.ParseConfig(f.Logger, rawConfig)
+	return util.ParseConfig(f, rawConfig)
+}
+
+// waitForFile polls for filename to exist for up to timeout, returning
+// the error from the last attempt to find it if it doesn't.
+func waitForFile(logger *log.Logger, filename string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := os.Stat(filename)
+		if !os.IsNotExist(err) || !time.Now().Before(deadline) {
+			return err
+		}
+		logger.Debug("config file %q not found. Waiting...", filename)
+		time.Sleep(pollInterval)
+	}
 }
@@ -0,0 +1,53 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestWaitForFile(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-file-provider-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	logger := log.New(true)
+	defer logger.Close()
+	pollInterval = 10 * time.Millisecond
+
+	path := filepath.Join(td, "config.ign")
+	if err := waitForFile(&logger, path, 50*time.Millisecond); !os.IsNotExist(err) {
+		t.Errorf("expected missing file to time out, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- waitForFile(&logger, path, time.Minute)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	if err := ioutil.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("expected file to be found, got %v", err)
+	}
+}
@@ -73,7 +73,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		f.Logger.Info("cidata drive was not available in time. Continuing without a config...")
 	}
 
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f, data)
 }
 
 func fileExists(path string) bool {
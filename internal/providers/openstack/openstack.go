@@ -49,6 +49,13 @@ var (
 		Host:   "169.254.169.254",
 		Path:   "openstack/latest/user_data",
 	}
+	// the metadata service's address on IPv6-only networks, which is
+	// link-local and so must be tried on each interface
+	metadataServiceUrlIPv6 = url.URL{
+		Scheme: "http",
+		Host:   "[fe80::a9fe:a9fe]",
+		Path:   "openstack/latest/user_data",
+	}
 )
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
@@ -80,16 +87,33 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return fetchConfigFromDevice(f.Logger, ctx, filepath.Join(distro.DiskByLabelDir(), "CONFIG-2"))
 	})
 
-	go dispatch("metadata service", func() ([]byte, error) {
-		return fetchConfigFromMetadataService(f)
-	})
+	// offline, only a config drive can provide the config
+	if !f.Offline {
+		go dispatch("metadata service", func() ([]byte, error) {
+			return fetchConfigFromMetadataService(f, metadataServiceUrl)
+		})
+
+		urls, err := util.LinkLocalURLs(metadataServiceUrlIPv6)
+		if err != nil {
+			f.Logger.Warning("not trying the metadata service over IPv6: %v", err)
+		}
+		for _, u := range urls {
+			u := u
+			go dispatch(fmt.Sprintf("metadata service (%s)", u.Host), func() ([]byte, error) {
+				return fetchConfigFromMetadataService(f, u)
+			})
+		}
+	}
 
 	<-ctx.Done()
-	if ctx.Err() == context.DeadlineExceeded {
+	if ctx.Err() == context.DeadlineExceeded && f.Offline {
+		f.Logger.Info("no config drive was available in time; the metadata service requires networking")
+		return types.Config{}, report.Report{}, resource.ErrNeedNet
+	} else if ctx.Err() == context.DeadlineExceeded {
 		f.Logger.Info("neither config drive nor metadata service were available in time. Continuing without a config...")
 	}
 
-	return util.ParseConfig(f.Logger, data)
+	return util.ParseConfig(f, data)
 }
 
 func fileExists(path string) bool {
@@ -130,7 +154,7 @@ func fetchConfigFromDevice(logger *log.Logger, ctx context.Context, path string)
 	return ioutil.ReadFile(filepath.Join(mnt, configDriveUserdataPath))
 }
 
-func fetchConfigFromMetadataService(f *resource.Fetcher) ([]byte, error) {
-	res, err := f.FetchToBuffer(metadataServiceUrl, resource.FetchOptions{})
+func fetchConfigFromMetadataService(f *resource.Fetcher, u url.URL) ([]byte, error) {
+	res, err := f.FetchToBuffer(u, resource.FetchOptions{})
 	return res, err
 }
@@ -13,24 +13,40 @@
 // limitations under the License.
 
 // The QEMU provider fetches a local configuration from the firmware config
-// interface (opt/com.coreos/config).
+// interface (opt/com.coreos/config), and its detached signature, if any,
+// from opt/com.coreos/config.sig.
 
 package qemu
 
 import (
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/providers/util"
 	"github.com/coreos/ignition/v2/internal/resource"
+	"github.com/coreos/ignition/v2/internal/signature"
 
 	"github.com/coreos/vcontext/report"
 )
 
 const (
-	firmwareConfigPath = "/sys/firmware/qemu_fw_cfg/by_name/opt/com.coreos/config/raw"
+	firmwareConfigDir    = "/sys/firmware/qemu_fw_cfg/by_name/opt/com.coreos/config"
+	firmwareSignatureDir = "/sys/firmware/qemu_fw_cfg/by_name/opt/com.coreos/config.sig"
+
+	// entries are read in chunks of this size, logging the progress of
+	// large ones, since reading fw_cfg is slow
+	chunkSize = 1024 * 1024
+	// more than any RSA or ECDSA signature
+	maxSignatureBytes = 4096
 )
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
@@ -39,13 +55,65 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return types.Config{}, report.Report{}, err
 	}
 
-	data, err := ioutil.ReadFile(firmwareConfigPath)
+	data, err := readEntry(f.Logger, firmwareConfigDir, f.Limits.MaxConfigBytes)
 	if os.IsNotExist(err) {
 		f.Logger.Info("QEMU firmware config was not found. Ignoring...")
+		return util.ParseConfig(f, nil)
 	} else if err != nil {
 		f.Logger.Err("couldn't read QEMU firmware config: %v", err)
 		return types.Config{}, report.Report{}, err
 	}
 
-	return util.ParseConfig(f.Logger, data)
+	sig, err := readEntry(f.Logger, firmwareSignatureDir, maxSignatureBytes)
+	if err == nil {
+		f.Logger.Info("using the detached signature of the QEMU firmware config")
+		if data, err = signature.Wrap(data, sig); err != nil {
+			return types.Config{}, report.Report{}, err
+		}
+	} else if !os.IsNotExist(err) {
+		f.Logger.Err("couldn't read QEMU firmware config signature: %v", err)
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(f, data)
+}
+
+// readEntry reads the fw_cfg entry whose sysfs directory is dir, failing
+// before reading it if it's larger than max bytes, unless max is 0. The
+// error satisfies os.IsNotExist if there is no such entry.
+func readEntry(logger *log.Logger, dir string, max int) ([]byte, error) {
+	rawSize, err := ioutil.ReadFile(filepath.Join(dir, "size"))
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(string(rawSize)))
+	if err != nil {
+		return nil, fmt.Errorf("parsing size of %q: %v", dir, err)
+	}
+	if max > 0 && size > max {
+		return nil, fmt.Errorf("%v: %q is %d bytes, more than the limit of %d bytes", errors.ErrConfigTooLarge, dir, size, max)
+	}
+
+	raw, err := os.Open(filepath.Join(dir, "raw"))
+	if err != nil {
+		return nil, err
+	}
+	defer raw.Close()
+
+	data := make([]byte, size)
+	for read := 0; read < size; {
+		end := read + chunkSize
+		if end > size {
+			end = size
+		}
+		n, err := io.ReadFull(raw, data[read:end])
+		read += n
+		if err != nil {
+			return nil, fmt.Errorf("reading %q: got %d of %d bytes: %v", dir, read, size, err)
+		}
+		if size > chunkSize {
+			logger.Info("read %d of %d bytes of %q", read, size, dir)
+		}
+	}
+	return data, nil
 }
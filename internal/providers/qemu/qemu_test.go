@@ -0,0 +1,65 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package qemu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func writeEntry(t *testing.T, dir string, size int, contents []byte) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "size"), []byte(strconv.Itoa(size)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "raw"), contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-fw-cfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logger := log.New(true)
+	defer logger.Close()
+
+	large := bytes.Repeat([]byte("x"), 2*chunkSize+1)
+	writeEntry(t, filepath.Join(dir, "large"), len(large), large)
+	writeEntry(t, filepath.Join(dir, "truncated"), 10, []byte("short"))
+
+	if data, err := readEntry(&logger, filepath.Join(dir, "large"), 0); err != nil || !bytes.Equal(data, large) {
+		t.Errorf("failed to read large entry: %v", err)
+	}
+	if _, err := readEntry(&logger, filepath.Join(dir, "large"), chunkSize); err == nil {
+		t.Errorf("entry over the limit was read")
+	}
+	if _, err := readEntry(&logger, filepath.Join(dir, "truncated"), 0); err == nil {
+		t.Errorf("truncated entry was read")
+	}
+	if _, err := readEntry(&logger, filepath.Join(dir, "missing"), 0); !os.IsNotExist(err) {
+		t.Errorf("bad error for missing entry: %v", err)
+	}
+}
@@ -19,9 +19,9 @@ import (
 	"os"
 	"path/filepath"
 
+	latest "github.com/coreos/ignition/v2/config/v3_1_experimental"
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/distro"
-	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/providers"
 	"github.com/coreos/ignition/v2/internal/providers/util"
 	"github.com/coreos/ignition/v2/internal/resource"
@@ -32,17 +32,37 @@ import (
 const (
 	baseFilename = "base.ign"
 	userFilename = "user.ign"
+	// directory holding a base config for each platform, named after it
+	platformBaseDir = "base.platform.d"
 )
 
-func FetchBaseConfig(logger *log.Logger) (types.Config, report.Report, error) {
-	return fetchConfig(logger, baseFilename)
+// FetchBaseConfig returns the system base config, with the base config of
+// the platform merged over it, or providers.ErrNoProvider if there's
+// neither.
+func FetchBaseConfig(f *resource.Fetcher, platform string) (types.Config, report.Report, error) {
+	base, r, err := fetchConfig(f, baseFilename)
+	if err != nil && err != providers.ErrNoProvider {
+		return types.Config{}, r, err
+	}
+	platformBase, platformReport, platformErr := fetchConfig(f, filepath.Join(platformBaseDir, platform+".ign"))
+	r.Merge(platformReport)
+	switch {
+	case platformErr == providers.ErrNoProvider:
+		return base, r, err
+	case platformErr != nil:
+		return types.Config{}, r, platformErr
+	case err == providers.ErrNoProvider:
+		return platformBase, r, nil
+	}
+	return latest.Merge(base, platformBase), r, nil
 }
 
 func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
-	return fetchConfig(f.Logger, userFilename)
+	return fetchConfig(f, userFilename)
 }
 
-func fetchConfig(logger *log.Logger, filename string) (types.Config, report.Report, error) {
+func fetchConfig(f *resource.Fetcher, filename string) (types.Config, report.Report, error) {
+	logger := f.Logger
 	path := filepath.Join(distro.SystemConfigDir(), filename)
 	logger.Info("reading system config file %q", path)
 
@@ -54,5 +74,6 @@ func fetchConfig(logger *log.Logger, filename string) (types.Config, report.Repo
 		logger.Err("couldn't read config %q: %v", path, err)
 		return types.Config{}, report.Report{}, err
 	}
-	return util.ParseConfig(logger, rawConfig)
+	// the system config dir is part of the image, so it's trusted
+	return util.ParseUnsignedConfig(f, rawConfig)
 }
@@ -0,0 +1,72 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/providers"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+func TestFetchBaseConfig(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-system-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	os.Setenv("IGNITION_SYSTEM_CONFIG_DIR", td)
+	defer os.Unsetenv("IGNITION_SYSTEM_CONFIG_DIR")
+	if err := os.Mkdir(filepath.Join(td, platformBaseDir), 0755); err != nil {
+		t.Fatalf("mkdir error: %v", err)
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := &resource.Fetcher{Logger: &logger}
+	write := func(name, config string) {
+		if err := ioutil.WriteFile(filepath.Join(td, name), []byte(config), 0644); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+
+	if _, _, err := FetchBaseConfig(f, "aws"); err != providers.ErrNoProvider {
+		t.Errorf("expected no config, got %v", err)
+	}
+
+	write("base.platform.d/aws.ign", `{"ignition": {"version": "3.1.0-experimental", "timeouts": {"httpTotal": 30}}, "passwd": {"users": [{"name": "aws"}]}}`)
+	cfg, _, err := FetchBaseConfig(f, "aws")
+	if err != nil || len(cfg.Passwd.Users) != 1 || *cfg.Ignition.Timeouts.HTTPTotal != 30 {
+		t.Errorf("expected the aws base config, got %+v, %v", cfg, err)
+	}
+
+	// the platform's base config is merged over the base config
+	write("base.ign", `{"ignition": {"version": "3.1.0-experimental", "timeouts": {"httpTotal": 10, "httpResponseHeaders": 5}}, "passwd": {"users": [{"name": "core"}]}}`)
+	cfg, _, err = FetchBaseConfig(f, "aws")
+	if err != nil {
+		t.Fatalf("fetch error: %v", err)
+	}
+	if len(cfg.Passwd.Users) != 2 || *cfg.Ignition.Timeouts.HTTPTotal != 30 || *cfg.Ignition.Timeouts.HTTPResponseHeaders != 5 {
+		t.Errorf("expected the merged base configs, got %+v", cfg)
+	}
+	cfg, _, err = FetchBaseConfig(f, "gcp")
+	if err != nil || len(cfg.Passwd.Users) != 1 || *cfg.Ignition.Timeouts.HTTPTotal != 10 {
+		t.Errorf("expected only the base config, got %+v, %v", cfg, err)
+	}
+}
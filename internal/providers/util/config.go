@@ -19,15 +19,47 @@ import (
 	"encoding/hex"
 
 	"github.com/coreos/ignition/v2/config"
+	"github.com/coreos/ignition/v2/config/shared/errors"
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
-	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/failure"
+	"github.com/coreos/ignition/v2/internal/resource"
 
 	"github.com/coreos/vcontext/report"
 )
 
-func ParseConfig(logger *log.Logger, rawConfig []byte) (types.Config, report.Report, error) {
+// ParseConfig verifies the signature of rawConfig, if f requires one, and
+// parses the config within.
+func ParseConfig(f *resource.Fetcher, rawConfig []byte) (types.Config, report.Report, error) {
+	rawConfig, err := f.Signature.Verify(rawConfig)
+	if err != nil {
+		return types.Config{}, report.Report{}, failure.Wrap(failure.ErrVerificationFailed, err)
+	}
+	return ParseUnsignedConfig(f, rawConfig)
+}
+
+// ParseUnsignedConfig parses rawConfig without checking its signature, for
+// configs which are trusted because they're part of the system.
+func ParseUnsignedConfig(f *resource.Fetcher, rawConfig []byte) (types.Config, report.Report, error) {
 	hash := sha512.Sum512(rawConfig)
-	logger.Debug("parsing config with SHA512: %s", hex.EncodeToString(hash[:]))
+	f.Logger.Debug("parsing config with SHA512: %s", hex.EncodeToString(hash[:]))
 
-	return config.Parse(rawConfig)
+	if err := f.Limits.CheckSize(rawConfig); err != nil {
+		return types.Config{}, report.Report{}, failure.Wrap(failure.ErrConfigInvalid, err)
+	}
+	parse := config.Parse
+	if f.AcceptYAML {
+		parse = config.ParseYAML
+	}
+	cfg, r, err := parse(rawConfig)
+	if err == errors.ErrEmpty {
+		// not a failure; Ignition ignores empty configs
+		return types.Config{}, r, err
+	} else if err != nil {
+		return types.Config{}, r, failure.Wrap(failure.ErrConfigInvalid, err)
+	}
+	r.Merge(f.Limits.Check(cfg))
+	if r.IsFatal() {
+		return types.Config{}, r, failure.Wrap(failure.ErrConfigInvalid, errors.ErrInvalid)
+	}
+	return cfg, r, nil
 }
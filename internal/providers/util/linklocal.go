@@ -0,0 +1,62 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net"
+	"net/url"
+)
+
+// LinkLocalURLs returns a copy of u, whose host must be an IPv6 link-local
+// address, for each interface which could reach it. Link-local addresses
+// are only meaningful on one link, so the copies name the interface in the
+// address's zone, e.g. http://[fe80::a9fe:a9fe%eth0]/.
+func LinkLocalURLs(u url.URL) ([]url.URL, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var zones []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() == nil && ipnet.IP.IsLinkLocalUnicast() {
+				zones = append(zones, iface.Name)
+				break
+			}
+		}
+	}
+	return zonedURLs(u, zones), nil
+}
+
+// zonedURLs returns a copy of u in each of zones.
+func zonedURLs(u url.URL, zones []string) []url.URL {
+	var urls []url.URL
+	for _, zone := range zones {
+		zoned := u
+		zoned.Host = "[" + u.Hostname() + "%" + zone + "]"
+		if port := u.Port(); port != "" {
+			zoned.Host += ":" + port
+		}
+		urls = append(urls, zoned)
+	}
+	return urls
+}
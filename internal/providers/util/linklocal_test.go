@@ -0,0 +1,59 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestZonedURLs(t *testing.T) {
+	tests := []struct {
+		in  string
+		out []string
+	}{
+		{
+			in:  "http://[fe80::a9fe:a9fe]/openstack/latest/user_data",
+			out: []string{"http://[fe80::a9fe:a9fe%25eth0]/openstack/latest/user_data", "http://[fe80::a9fe:a9fe%25ens3]/openstack/latest/user_data"},
+		},
+		{
+			in:  "http://[fe80::1]:8080/config.ign",
+			out: []string{"http://[fe80::1%25eth0]:8080/config.ign", "http://[fe80::1%25ens3]:8080/config.ign"},
+		},
+	}
+
+	zones := []string{"eth0", "ens3"}
+	for i, test := range tests {
+		u, err := url.Parse(test.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls := zonedURLs(*u, zones)
+		if len(urls) != len(test.out) {
+			t.Errorf("#%d: expected %d URLs, got %d", i, len(test.out), len(urls))
+			continue
+		}
+		for j, zoned := range urls {
+			if zoned.String() != test.out[j] {
+				t.Errorf("#%d: expected %q, got %q", i, test.out[j], zoned.String())
+			}
+			// the zone must survive the round trip through the HTTP client
+			parsed, err := url.Parse(zoned.String())
+			if err != nil || parsed.Hostname() != u.Hostname()+"%"+zones[j] {
+				t.Errorf("#%d: zone lost when parsing %q: %v", i, zoned.String(), err)
+			}
+		}
+	}
+}
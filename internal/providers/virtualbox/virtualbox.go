@@ -47,5 +47,5 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 		return types.Config{}, report.Report{}, err
 	}
 	trimmedConfig := bytes.TrimRight(rawConfig, "\x00")
-	return util.ParseConfig(f.Logger, trimmedConfig)
+	return util.ParseConfig(f, trimmedConfig)
 }
@@ -48,7 +48,7 @@ func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
 	}
 
 	f.Logger.Debug("config successfully fetched")
-	return util.ParseConfig(f.Logger, decodedData)
+	return util.ParseConfig(f, decodedData)
 }
 
 func fetchRawConfig(f *resource.Fetcher) (config, error) {
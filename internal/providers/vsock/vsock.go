@@ -0,0 +1,52 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The vsock provider fetches the config via HTTP from an agent on the host,
+// over a VM socket, for VMs which deliberately have no network metadata
+// service, such as Firecracker, Cloud Hypervisor, and confidential VMs.
+
+package vsock
+
+import (
+	"os/exec"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/providers/util"
+	"github.com/coreos/ignition/v2/internal/resource"
+
+	"github.com/coreos/vcontext/report"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// the port on which the host's agent serves the config
+	agentPort  = 4680
+	configPath = "/config"
+)
+
+func FetchConfig(f *resource.Fetcher) (types.Config, report.Report, error) {
+	_, err := f.Logger.LogCmd(exec.Command("modprobe", "vmw_vsock_virtio_transport"), "loading virtio VM socket module")
+	if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	data, err := f.FetchFromVsock(unix.VMADDR_CID_HOST, agentPort, configPath, resource.FetchOptions{})
+	if err == resource.ErrNotFound {
+		f.Logger.Info("host agent has no config. Ignoring...")
+	} else if err != nil {
+		return types.Config{}, report.Report{}, err
+	}
+
+	return util.ParseConfig(f, data)
+}
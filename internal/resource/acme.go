@@ -0,0 +1,433 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrACMEChallengeUnsupported = errors.New("ACME server offered no http-01 challenge")
+
+	// how often and for how long to poll for the server to validate
+	// challenges and issue the certificate
+	acmePollInterval = time.Second
+	acmePollTimeout  = 2 * time.Minute
+)
+
+const (
+	acmeBadNonce         = "urn:ietf:params:acme:error:badNonce"
+	acmeChallengePrefix  = "/.well-known/acme-challenge/"
+	acmeJOSEContentType  = "application/jose+json"
+	acmePEMChainMimeType = "application/pem-certificate-chain"
+)
+
+// acmeDirectory is the subset of an ACME server's directory which Ignition
+// uses.
+type acmeDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeOrder struct {
+	Status         string       `json:"status"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate"`
+	Error          *acmeProblem `json:"error"`
+}
+
+type acmeChallenge struct {
+	Type   string       `json:"type"`
+	URL    string       `json:"url"`
+	Token  string       `json:"token"`
+	Status string       `json:"status"`
+	Error  *acmeProblem `json:"error"`
+}
+
+type acmeAuthorization struct {
+	Status     string          `json:"status"`
+	Identifier acmeIdentifier  `json:"identifier"`
+	Challenges []acmeChallenge `json:"challenges"`
+}
+
+// acmeResponse is a response of the ACME server whose body was read.
+type acmeResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// acmeClient obtains certificates from an ACME server (RFC 8555) with a
+// new account, proving control of the names with http-01 challenges.
+type acmeClient struct {
+	// client's requests are cancelled along with the caller's context
+	client HttpClient
+	key    *ecdsa.PrivateKey
+	dir    acmeDirectory
+	kid    string
+	nonce  string
+	// listen opens the socket on which http-01 challenges are answered
+	listen func() (net.Listener, error)
+}
+
+// ObtainACMECertificate obtains a certificate for the DNS names in the
+// DER-encoded certificate request csr from the ACME server with the
+// directory URL directory, registering an account with the contact email if
+// it isn't empty. It returns the PEM-encoded certificate chain. Cancelling
+// ctx aborts the requests and the wait for validation.
+func (f *Fetcher) ObtainACMECertificate(ctx context.Context, directory, email string, csr []byte) ([]byte, error) {
+	if f.client == nil {
+		if err := f.newHttpClient(); err != nil {
+			return nil, err
+		}
+	}
+	c, err := newACMEClient(ctx, f.client)
+	if err != nil {
+		return nil, err
+	}
+	return c.obtain(directory, email, csr)
+}
+
+func newACMEClient(ctx context.Context, client *HttpClient) (*acmeClient, error) {
+	// the account is only used for this certificate, so it needn't
+	// outlive the process
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &acmeClient{
+		client: client.withContext(ctx),
+		key:    key,
+		listen: func() (net.Listener, error) { return net.Listen("tcp", ":80") },
+	}, nil
+}
+
+func (c *acmeClient) obtain(directory, email string, csr []byte) ([]byte, error) {
+	req, err := x509.ParseCertificateRequest(csr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.request("GET", directory, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(resp.body, &c.dir); err != nil {
+		return nil, fmt.Errorf("decoding ACME directory: %v", err)
+	}
+
+	account := map[string]interface{}{"termsOfServiceAgreed": true}
+	if email != "" {
+		account["contact"] = []string{"mailto:" + email}
+	}
+	if resp, err = c.post(c.dir.NewAccount, account, nil); err != nil {
+		return nil, fmt.Errorf("creating ACME account: %v", err)
+	}
+	if c.kid = resp.header.Get("Location"); c.kid == "" {
+		return nil, fmt.Errorf("ACME server returned no account URL")
+	}
+
+	var identifiers []acmeIdentifier
+	for _, name := range req.DNSNames {
+		identifiers = append(identifiers, acmeIdentifier{Type: "dns", Value: name})
+	}
+	if resp, err = c.post(c.dir.NewOrder, map[string]interface{}{"identifiers": identifiers}, nil); err != nil {
+		return nil, fmt.Errorf("creating ACME order: %v", err)
+	}
+	orderURL := resp.header.Get("Location")
+	var order acmeOrder
+	if err := json.Unmarshal(resp.body, &order); err != nil {
+		return nil, fmt.Errorf("decoding ACME order: %v", err)
+	}
+
+	if err := c.authorize(order.Authorizations); err != nil {
+		return nil, err
+	}
+
+	if _, err = c.post(order.Finalize, map[string]string{"csr": base64.RawURLEncoding.EncodeToString(csr)}, nil); err != nil {
+		return nil, fmt.Errorf("finalizing ACME order: %v", err)
+	}
+	if err := c.poll(orderURL, &order, func() (string, *acmeProblem) { return order.Status, order.Error }); err != nil {
+		return nil, fmt.Errorf("ACME order: %v", err)
+	}
+
+	resp, err = c.post(order.Certificate, nil, http.Header{"Accept": {acmePEMChainMimeType}})
+	if err != nil {
+		return nil, fmt.Errorf("downloading certificate: %v", err)
+	}
+	return resp.body, nil
+}
+
+// authorize answers the http-01 challenge of each pending authorization and
+// waits for the server to validate it.
+func (c *acmeClient) authorize(urls []string) error {
+	responses := map[string]string{}
+	var pending []string
+	var challenges []acmeChallenge
+	for _, u := range urls {
+		var authz acmeAuthorization
+		resp, err := c.post(u, nil, nil)
+		if err != nil {
+			return fmt.Errorf("fetching ACME authorization: %v", err)
+		}
+		if err := json.Unmarshal(resp.body, &authz); err != nil {
+			return fmt.Errorf("decoding ACME authorization: %v", err)
+		}
+		if authz.Status == "valid" {
+			continue
+		}
+		challenge, err := findHTTP01(authz.Challenges)
+		if err != nil {
+			return fmt.Errorf("%s: %v", authz.Identifier.Value, err)
+		}
+		responses[challenge.Token] = challenge.Token + "." + c.thumbprint()
+		pending = append(pending, u)
+		challenges = append(challenges, challenge)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ln, err := c.listen()
+	if err != nil {
+		return fmt.Errorf("listening for http-01 challenges: %v", err)
+	}
+	server := http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[strings.TrimPrefix(r.URL.Path, acmeChallengePrefix)]
+		if !ok || !strings.HasPrefix(r.URL.Path, acmeChallengePrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte(response))
+	})}
+	go server.Serve(ln)
+	defer server.Close()
+
+	for i, u := range pending {
+		if _, err := c.post(challenges[i].URL, struct{}{}, nil); err != nil {
+			return fmt.Errorf("responding to ACME challenge: %v", err)
+		}
+		var authz acmeAuthorization
+		err := c.poll(u, &authz, func() (string, *acmeProblem) {
+			for _, ch := range authz.Challenges {
+				if ch.URL == challenges[i].URL && ch.Error != nil {
+					return authz.Status, ch.Error
+				}
+			}
+			return authz.Status, nil
+		})
+		if err != nil {
+			return fmt.Errorf("ACME authorization of %s: %v", authz.Identifier.Value, err)
+		}
+	}
+	return nil
+}
+
+func findHTTP01(challenges []acmeChallenge) (acmeChallenge, error) {
+	for _, ch := range challenges {
+		if ch.Type == "http-01" {
+			return ch, nil
+		}
+	}
+	return acmeChallenge{}, ErrACMEChallengeUnsupported
+}
+
+// poll fetches the resource at url into v until status returns a final
+// state, returning an error unless it's valid.
+func (c *acmeClient) poll(url string, v interface{}, status func() (string, *acmeProblem)) error {
+	deadline := time.Now().Add(acmePollTimeout)
+	for {
+		resp, err := c.post(url, nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(resp.body, v); err != nil {
+			return err
+		}
+		switch s, problem := status(); s {
+		case "valid":
+			return nil
+		case "pending", "processing", "ready":
+		default:
+			if problem != nil {
+				return fmt.Errorf("status %s: %s", s, problem.Detail)
+			}
+			return fmt.Errorf("status %s", s)
+		}
+		if time.Now().After(deadline) {
+			return ErrTimeout
+		}
+		select {
+		case <-time.After(acmePollInterval):
+		case <-c.client.ctx.Done():
+			return c.client.ctx.Err()
+		}
+	}
+}
+
+// post sends payload, encoded as JSON, to url in a JWS signed with the
+// account key. A nil payload makes a POST-as-GET request.
+func (c *acmeClient) post(url string, payload interface{}, header http.Header) (*acmeResponse, error) {
+	data := []byte{}
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Type", acmeJOSEContentType)
+
+	// the server may reject a nonce, in which case it sends a new one
+	for attempt := 0; ; attempt++ {
+		if c.nonce == "" {
+			if _, err := c.request("HEAD", c.dir.NewNonce, nil, nil); err != nil {
+				return nil, err
+			}
+			if c.nonce == "" {
+				return nil, fmt.Errorf("ACME server returned no nonce")
+			}
+		}
+		body, err := c.sign(url, data)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.request("POST", url, header, body)
+		if err, ok := err.(*acmeError); ok && err.Type == acmeBadNonce && attempt == 0 {
+			continue
+		}
+		return resp, err
+	}
+}
+
+type acmeError struct {
+	status int
+	acmeProblem
+}
+
+func (e *acmeError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("ACME server returned %q", http.StatusText(e.status))
+	}
+	return fmt.Sprintf("ACME server returned %q: %s", http.StatusText(e.status), e.Detail)
+}
+
+// request sends a request to the ACME server, keeping the nonce it returns,
+// and returns an *acmeError if it fails.
+func (c *acmeClient) request(method, url string, header http.Header, body []byte) (*acmeResponse, error) {
+	resp, cancel, err := c.client.do(method, url, header, body)
+	if cancel != nil {
+		defer cancel()
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.nonce = resp.Header.Get("Replay-Nonce")
+	if resp.StatusCode >= 300 {
+		e := &acmeError{status: resp.StatusCode}
+		json.Unmarshal(data, &e.acmeProblem)
+		return nil, e
+	}
+	return &acmeResponse{status: resp.StatusCode, header: resp.Header, body: data}, nil
+}
+
+// sign returns the flattened JWS of payload for url, identifying the account
+// by its URL once it exists and by its key before.
+func (c *acmeClient) sign(url string, payload []byte) ([]byte, error) {
+	protected := map[string]interface{}{
+		"alg":   "ES256",
+		"nonce": c.nonce,
+		"url":   url,
+	}
+	if c.kid != "" {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = c.jwk()
+	}
+	// each nonce may only be used once
+	c.nonce = ""
+	header, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(encodedHeader + "." + encodedPayload))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := append(padBigInt(r, 32), padBigInt(s, 32)...)
+	return json.Marshal(map[string]string{
+		"protected": encodedHeader,
+		"payload":   encodedPayload,
+		"signature": base64.RawURLEncoding.EncodeToString(signature),
+	})
+}
+
+// jwk returns the JSON Web Key of the account's public key, with its members
+// in the lexicographic order its thumbprint requires.
+func (c *acmeClient) jwk() json.RawMessage {
+	return json.RawMessage(fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":%s,"y":%s}`,
+		strconv.Quote(base64.RawURLEncoding.EncodeToString(padBigInt(c.key.X, 32))),
+		strconv.Quote(base64.RawURLEncoding.EncodeToString(padBigInt(c.key.Y, 32)))))
+}
+
+// thumbprint returns the JWK thumbprint (RFC 7638) of the account key.
+func (c *acmeClient) thumbprint() string {
+	digest := sha256.Sum256(c.jwk())
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+func padBigInt(i *big.Int, size int) []byte {
+	b := i.Bytes()
+	return append(bytes.Repeat([]byte{0}, size-len(b)), b...)
+}
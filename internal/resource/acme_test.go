@@ -0,0 +1,322 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+// fakeACME is an ACME server which verifies the requests' signatures and
+// validates http-01 challenges against the client's responder.
+type fakeACME struct {
+	t         *testing.T
+	server    *httptest.Server
+	responder string
+	caKey     *ecdsa.PrivateKey
+	caCert    *x509.Certificate
+
+	mu        sync.Mutex
+	nonce     int
+	nonces    map[string]bool
+	badNonces int
+	jwk       json.RawMessage
+	key       *ecdsa.PublicKey
+	validated bool
+	cert      []byte
+}
+
+func newFakeACME(t *testing.T, responder string) *fakeACME {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(der)
+	f := &fakeACME{t: t, responder: responder, caKey: caKey, caCert: caCert, nonces: map[string]bool{}, badNonces: 1}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *fakeACME) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nonce++
+	nonce := fmt.Sprintf("nonce-%d", f.nonce)
+	f.nonces[nonce] = true
+	w.Header().Set("Replay-Nonce", nonce)
+
+	u := f.server.URL
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/directory":
+		fmt.Fprintf(w, `{"newNonce": "%s/nonce", "newAccount": "%s/account", "newOrder": "%s/order"}`, u, u, u)
+		return
+	case r.Method == "HEAD" && r.URL.Path == "/nonce":
+		return
+	case r.Method != "POST":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := f.verify(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"type": "%s", "detail": %q}`, err.Error(), err.Error())
+		return
+	}
+	order := fmt.Sprintf(`{"status": "%%s", "authorizations": ["%s/authz"], "finalize": "%s/finalize", "certificate": "%s/cert"}`, u, u, u)
+	switch r.URL.Path {
+	case "/account":
+		w.Header().Set("Location", u+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status": "valid"}`))
+	case "/order":
+		if f.badNonces > 0 {
+			// make the client retry with the new nonce
+			f.badNonces--
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"type": "%s"}`, acmeBadNonce)
+			return
+		}
+		var req struct {
+			Identifiers []acmeIdentifier `json:"identifiers"`
+		}
+		json.Unmarshal(payload, &req)
+		if len(req.Identifiers) != 1 || req.Identifiers[0].Value != "node1.example.com" {
+			f.t.Errorf("bad identifiers: %+v", req.Identifiers)
+		}
+		w.Header().Set("Location", u+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, order, "pending")
+	case "/authz":
+		status := "pending"
+		if f.validated {
+			status = "valid"
+		}
+		fmt.Fprintf(w, `{"status": "%s", "identifier": {"type": "dns", "value": "node1.example.com"}, "challenges": [{"type": "dns-01", "url": "%s/dns", "token": "dns"}, {"type": "http-01", "url": "%s/challenge", "token": "token1"}]}`, status, u, u)
+	case "/challenge":
+		resp, err := http.Get("http://" + f.responder + acmeChallengePrefix + "token1")
+		if err != nil {
+			f.t.Errorf("fetching challenge response: %v", err)
+			return
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		digest := sha256.Sum256(f.jwk)
+		if expected := "token1." + base64.RawURLEncoding.EncodeToString(digest[:]); string(body) != expected {
+			f.t.Errorf("bad key authorization: want %q, got %q", expected, body)
+		} else {
+			f.validated = true
+		}
+		w.Write([]byte(`{"status": "processing"}`))
+	case "/finalize":
+		var req struct {
+			CSR string `json:"csr"`
+		}
+		json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil || !f.validated {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"type": "urn:ietf:params:acme:error:orderNotReady"}`))
+			return
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		cert, _ := x509.CreateCertificate(rand.Reader, template, f.caCert, csr.PublicKey, f.caKey)
+		f.cert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+		fmt.Fprintf(w, order, "processing")
+	case "/order/1":
+		if f.cert != nil {
+			fmt.Fprintf(w, order, "valid")
+		} else {
+			fmt.Fprintf(w, order, "processing")
+		}
+	case "/cert":
+		if r.Header.Get("Accept") != acmePEMChainMimeType {
+			f.t.Errorf("bad Accept header %q", r.Header.Get("Accept"))
+		}
+		w.Write(f.cert)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// verify checks the JWS of r and returns its payload.
+func (f *fakeACME) verify(r *http.Request) ([]byte, error) {
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		return nil, err
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	var protected struct {
+		Alg   string          `json:"alg"`
+		Nonce string          `json:"nonce"`
+		URL   string          `json:"url"`
+		KID   string          `json:"kid"`
+		JWK   json.RawMessage `json:"jwk"`
+	}
+	if err := json.Unmarshal(header, &protected); err != nil {
+		return nil, err
+	}
+	if !f.nonces[protected.Nonce] {
+		return nil, fmt.Errorf(acmeBadNonce)
+	}
+	delete(f.nonces, protected.Nonce)
+	if protected.URL != f.server.URL+r.URL.Path || protected.Alg != "ES256" {
+		return nil, fmt.Errorf("bad url or alg")
+	}
+	if r.URL.Path == "/account" {
+		var jwk struct {
+			X string `json:"x"`
+			Y string `json:"y"`
+		}
+		json.Unmarshal(protected.JWK, &jwk)
+		x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, _ := base64.RawURLEncoding.DecodeString(jwk.Y)
+		f.jwk = protected.JWK
+		f.key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	} else if protected.KID != f.server.URL+"/account/1" {
+		return nil, fmt.Errorf("bad kid %q", protected.KID)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if len(signature) != 64 || !ecdsa.Verify(f.key, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return nil, fmt.Errorf("bad signature")
+	}
+	return base64.RawURLEncoding.DecodeString(jws.Payload)
+}
+
+func TestObtainACMECertificate(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme := newFakeACME(t, ln.Addr().String())
+	defer acme.server.Close()
+	acmePollInterval = 10 * time.Millisecond
+	defer func() { acmePollInterval = time.Second }()
+
+	logger := log.New(true)
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	c, err := newACMEClient(context.Background(), f.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.listen = func() (net.Listener, error) { return ln, nil }
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"node1.example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := c.obtain(acme.server.URL+"/directory", "ops@example.com", csr)
+	if err != nil {
+		t.Fatalf("obtaining certificate failed: %v", err)
+	}
+	block, _ := pem.Decode(chain)
+	if block == nil {
+		t.Fatalf("bad certificate chain %q", chain)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "node1.example.com" {
+		t.Errorf("bad certificate names %v", cert.DNSNames)
+	}
+	if acme.badNonces != 0 {
+		t.Errorf("client didn't retry with a new nonce")
+	}
+}
+
+func TestObtainACMECertificateCancelled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme := newFakeACME(t, ln.Addr().String())
+	defer acme.server.Close()
+
+	logger := log.New(true)
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c, err := newACMEClient(ctx, f.client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.listen = func() (net.Listener, error) { return ln, nil }
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"node1.example.com"}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.obtain(acme.server.URL+"/directory", "ops@example.com", csr); err == nil {
+		t.Fatalf("obtaining certificate succeeded despite the cancelled context")
+	}
+}
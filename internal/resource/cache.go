@@ -0,0 +1,108 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+const (
+	cmdlineCacheFlag = "ignition.cache"
+
+	// CacheObjectsPath is the path under a cache's URL at which it serves
+	// objects.
+	CacheObjectsPath = "/objects/"
+)
+
+// CacheObjectPath returns the path, under a cache's URL, of the object
+// whose sha512 sum is sum.
+func CacheObjectPath(sum []byte) string {
+	return CacheObjectsPath + "sha512-" + hex.EncodeToString(sum)
+}
+
+// ParseCacheObjectPath returns the sha512 sum of the object at path, which
+// is under a cache's URL, if it names one.
+func ParseCacheObjectPath(path string) ([]byte, bool) {
+	name := strings.TrimPrefix(path, CacheObjectsPath+"sha512-")
+	if name == path {
+		return nil, false
+	}
+	sum, err := hex.DecodeString(name)
+	// the name must be canonical, so each object has one path
+	if err != nil || len(sum) != sha512.Size || hex.EncodeToString(sum) != name {
+		return nil, false
+	}
+	return sum, true
+}
+
+// CacheableScheme returns whether objects with URLs of scheme can be fetched
+// through a cache. Caches can't reach local resources, nor log in to Vault.
+func CacheableScheme(scheme string) bool {
+	switch scheme {
+	case "http", "https", "s3", "tftp":
+		return true
+	default:
+		return false
+	}
+}
+
+// KernelCaches returns the caches set with ignition.cache on the kernel
+// command line, as comma-separated URLs, or the distribution's default.
+func KernelCaches() ([]url.URL, error) {
+	caches := distro.Caches()
+	args, err := ioutil.ReadFile(distro.KernelCmdlinePath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, arg := range strings.Fields(string(args)) {
+		if parts := strings.SplitN(arg, "=", 2); len(parts) == 2 && parts[0] == cmdlineCacheFlag {
+			caches = parts[1]
+		}
+	}
+	var urls []url.URL
+	for _, cache := range splitList(caches) {
+		u, err := url.Parse(cache)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, *u)
+	}
+	return urls, nil
+}
+
+// cacheURLs returns the URLs at which f.Caches serve the object at u, if
+// the cache can verify it: its expected sum must be that of the object as
+// fetched, without decryption, decompression, or patching, and a sha512
+// sum, since caches index objects by those.
+func (f *Fetcher) cacheURLs(u url.URL, opts FetchOptions) []url.URL {
+	if len(f.Caches) == 0 || !CacheableScheme(u.Scheme) || opts.Hash == nil || len(opts.ExpectedSum) != sha512.Size ||
+		opts.Compression != "" || opts.Encryption.IsSet() || opts.PatchFrom != "" {
+		return nil
+	}
+	var urls []url.URL
+	for _, cache := range f.Caches {
+		cache.Path = strings.TrimSuffix(cache.Path, "/") + CacheObjectPath(opts.ExpectedSum)
+		cache.RawQuery = url.Values{"source": []string{u.String()}}.Encode()
+		urls = append(urls, cache)
+	}
+	return urls
+}
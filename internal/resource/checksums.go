@@ -0,0 +1,144 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/failure"
+)
+
+var (
+	ErrChecksumMissing   = errors.New("checksums manifest has no entry for the name")
+	ErrChecksumAmbiguous = errors.New("checksums manifest has conflicting entries for the name")
+)
+
+// ResolveVerification returns v with the hash found in its checksums
+// manifest, fetching the manifest and checking its signature if it has one,
+// or v unchanged if it has no manifest. The manifest may be written by
+// sha256sum or sha512sum, with or without --tag.
+func (f *Fetcher) ResolveVerification(v types.Verification) (types.Verification, error) {
+	sums := v.Checksums
+	if sums.Source == nil {
+		return v, nil
+	}
+	manifest, err := f.fetchManifest(*sums.Source)
+	if err != nil {
+		return types.Verification{}, err
+	}
+	if sums.Signature != nil {
+		sig, err := f.fetchManifest(*sums.Signature)
+		if err != nil {
+			return types.Verification{}, err
+		}
+		if err := f.Signature.VerifyDetached(manifest, sig); err != nil {
+			return types.Verification{}, failure.Wrap(failure.ErrVerificationFailed, fmt.Errorf("verifying checksums manifest %s: %v", *sums.Source, err))
+		}
+	}
+	hash, err := findChecksum(manifest, *sums.Name)
+	if err != nil {
+		return types.Verification{}, failure.Wrap(failure.ErrVerificationFailed, fmt.Errorf("%v: %s in %s", err, *sums.Name, *sums.Source))
+	}
+	f.Logger.Debug("found %s in checksums manifest %s: %s", *sums.Name, *sums.Source, hash)
+	return types.Verification{Hash: &hash}, nil
+}
+
+func (f *Fetcher) fetchManifest(source string) ([]byte, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.FetchToBuffer(*u, FetchOptions{})
+	if err == ErrNeedNet {
+		return nil, err
+	} else if err != nil {
+		return nil, failure.Wrap(failure.ErrFetchFailed, fmt.Errorf("fetching %s: %v", source, err))
+	}
+	return data, nil
+}
+
+// findChecksum returns the "<function>-<sum>" hash of the entry for name
+// in manifest.
+func findChecksum(manifest []byte, name string) (string, error) {
+	found := ""
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		hash, entry, ok := parseChecksumLine(strings.TrimSuffix(scanner.Text(), "\r"))
+		if !ok || (entry != name && entry != "./"+name) {
+			continue
+		}
+		if found != "" && found != hash {
+			return "", ErrChecksumAmbiguous
+		}
+		found = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", ErrChecksumMissing
+	}
+	return found, nil
+}
+
+// checksumSizes are the sizes of the sums of the hash functions manifests
+// may use.
+var checksumSizes = map[string]int{
+	"sha256": sha256.Size,
+	"sha512": sha512.Size,
+}
+
+// parseChecksumLine parses a line of the form "<sum>  <name>" or
+// "<sum> *<name>", whose hash function is known from the length of the sum,
+// or "SHA256 (<name>) = <sum>". Other lines, including comments, are
+// skipped.
+func parseChecksumLine(line string) (hash, name string, ok bool) {
+	var function, sum string
+	if i := strings.Index(line, " ("); i > 0 && strings.ToUpper(line[:i]) == line[:i] {
+		j := strings.LastIndex(line, ") = ")
+		if j < i {
+			return "", "", false
+		}
+		function, name, sum = strings.ToLower(line[:i]), line[i+2:j], line[j+4:]
+	} else {
+		i := strings.Index(line, " ")
+		if i < 0 || len(line) < i+3 || (line[i+1] != ' ' && line[i+1] != '*') {
+			return "", "", false
+		}
+		sum, name = line[:i], line[i+2:]
+		for f, size := range checksumSizes {
+			if len(sum) == hex.EncodedLen(size) {
+				function = f
+			}
+		}
+	}
+	size, known := checksumSizes[function]
+	if !known || len(sum) != hex.EncodedLen(size) {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", "", false
+	}
+	return function + "-" + strings.ToLower(sum), name, true
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/failure"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/signature"
+)
+
+func TestFindChecksum(t *testing.T) {
+	sum256 := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	sum512 := "ee26b0dd4af7e749aa1a8ee3c10ae9923f618980772e473f8819a5d4940e0db27ac185f8a0e1d5f84f88bc887fd67b143732c304cc5fa9ad8e6f57f50028a8ff"
+	manifest := "# release checksums\n" +
+		sum256 + "  image.raw\n" +
+		sum256 + " *./binary.img\r\n" +
+		"SHA512 (tagged.iso) = " + sum512 + "\n" +
+		"0123  short.img\n" +
+		sum256 + "  twice.img\n" +
+		sum512 + "  twice.img\n"
+
+	tests := []struct {
+		name string
+		out  string
+		err  error
+	}{
+		{
+			name: "image.raw",
+			out:  "sha256-" + sum256,
+		},
+		{
+			name: "binary.img",
+			out:  "sha256-" + sum256,
+		},
+		{
+			name: "tagged.iso",
+			out:  "sha512-" + sum512,
+		},
+		{
+			// only whole names match
+			name: "image",
+			err:  ErrChecksumMissing,
+		},
+		{
+			name: "short.img",
+			err:  ErrChecksumMissing,
+		},
+		{
+			name: "twice.img",
+			err:  ErrChecksumAmbiguous,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := findChecksum([]byte(manifest), test.name)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("#%d: bad hash: want %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestResolveVerification(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := signature.Policy{Keys: []string{string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))}}
+
+	contents := sha256.Sum256([]byte("hello"))
+	sum := hex.EncodeToString(contents[:])
+	manifest := sum + "  hello.txt\n"
+	digest := sha256.Sum256([]byte(manifest))
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/SHA256SUMS":
+			w.Write([]byte(manifest))
+		case "/SHA256SUMS.sig":
+			w.Write(sig)
+		case "/other.sig":
+			w.Write(sig[:len(sig)-1])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger, Signature: policy}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := "sha512-" + sum
+	tests := []struct {
+		in    types.Verification
+		out   *string
+		class error
+	}{
+		{
+			in:  types.Verification{Hash: &hash},
+			out: &hash,
+		},
+		{
+			in: types.Verification{Checksums: types.VerificationChecksums{
+				Source: util.StrToPtr(server.URL + "/SHA256SUMS"),
+				Name:   util.StrToPtr("hello.txt"),
+			}},
+			out: util.StrToPtr("sha256-" + sum),
+		},
+		{
+			in: types.Verification{Checksums: types.VerificationChecksums{
+				Source:    util.StrToPtr(server.URL + "/SHA256SUMS"),
+				Name:      util.StrToPtr("hello.txt"),
+				Signature: util.StrToPtr(server.URL + "/SHA256SUMS.sig"),
+			}},
+			out: util.StrToPtr("sha256-" + sum),
+		},
+		{
+			in: types.Verification{Checksums: types.VerificationChecksums{
+				Source:    util.StrToPtr(server.URL + "/SHA256SUMS"),
+				Name:      util.StrToPtr("hello.txt"),
+				Signature: util.StrToPtr(server.URL + "/other.sig"),
+			}},
+			class: failure.ErrVerificationFailed,
+		},
+		{
+			in: types.Verification{Checksums: types.VerificationChecksums{
+				Source: util.StrToPtr(server.URL + "/SHA256SUMS"),
+				Name:   util.StrToPtr("goodbye.txt"),
+			}},
+			class: failure.ErrVerificationFailed,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := f.ResolveVerification(test.in)
+		if class := failure.Classify(err); err != nil && class != test.class {
+			t.Errorf("#%d: bad error: want class %v, got %v", i, test.class, err)
+			continue
+		}
+		if err == nil && test.out == nil {
+			t.Errorf("#%d: expected an error", i)
+		} else if err == nil && (out.Hash == nil || *out.Hash != *test.out) {
+			t.Errorf("#%d: bad hash: want %q, got %v", i, *test.out, out.Hash)
+		}
+	}
+}
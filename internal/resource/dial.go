@@ -0,0 +1,131 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// connectionAttemptDelay is how long a connection attempt has before the
+// next address is tried alongside it, as in Happy Eyeballs (RFC 8305).
+var connectionAttemptDelay = 250 * time.Millisecond
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialHost connects to port on host, racing its addresses: each address is
+// tried connectionAttemptDelay after the one before, or as soon as it fails,
+// and the first connection made wins. A dead address only delays the
+// connection rather than holding it until it times out.
+func (d *dnsDialer) dialHost(ctx context.Context, network, host, port string) (net.Conn, error) {
+	if net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+	}
+	resolver := d.dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ips, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := interleaveFamilies(ips, network)
+	if len(addrs) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(addrs))
+	start := func(addr net.IPAddr) {
+		go func() {
+			conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+			results <- dialResult{conn, err}
+		}()
+	}
+
+	var firstErr error
+	var delay <-chan time.Time
+	next, pending := 0, 0
+	for {
+		if (delay == nil || pending == 0) && next < len(addrs) {
+			start(addrs[next])
+			next++
+			pending++
+			delay = nil
+			if next < len(addrs) {
+				delay = time.After(connectionAttemptDelay)
+			}
+		}
+		if pending == 0 {
+			return nil, firstErr
+		}
+		select {
+		case <-delay:
+			delay = nil
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// close the connections of attempts which succeed
+				// before they see the cancellation
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if res := <-results; res.conn != nil {
+							res.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			// start the next attempt now
+			delay = nil
+		}
+	}
+}
+
+// interleaveFamilies returns the addresses of ips which network can reach,
+// alternating between IPv6 and IPv4 from the family of the first, so that
+// a broken family only costs one attempt at a time.
+func interleaveFamilies(ips []net.IPAddr, network string) []net.IPAddr {
+	var first, second []net.IPAddr
+	for _, ip := range ips {
+		v4 := ip.IP.To4() != nil
+		if (network == "tcp4" && !v4) || (network == "tcp6" && v4) {
+			continue
+		}
+		if len(first) == 0 || (first[0].IP.To4() != nil) == v4 {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+	var addrs []net.IPAddr
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			addrs = append(addrs, first[i])
+		}
+		if i < len(second) {
+			addrs = append(addrs, second[i])
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestInterleaveFamilies(t *testing.T) {
+	addrs := func(ips ...string) (out []net.IPAddr) {
+		for _, ip := range ips {
+			out = append(out, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		return
+	}
+	tests := []struct {
+		in      []net.IPAddr
+		network string
+		out     []net.IPAddr
+	}{
+		{
+			in:      addrs("2001:db8::1", "2001:db8::2", "192.0.2.1", "192.0.2.2", "192.0.2.3"),
+			network: "tcp",
+			out:     addrs("2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2", "192.0.2.3"),
+		},
+		{
+			in:      addrs("192.0.2.1", "2001:db8::1", "2001:db8::2"),
+			network: "tcp",
+			out:     addrs("192.0.2.1", "2001:db8::1", "2001:db8::2"),
+		},
+		{
+			in:      addrs("2001:db8::1", "192.0.2.1"),
+			network: "tcp4",
+			out:     addrs("192.0.2.1"),
+		},
+		{
+			in:      addrs("192.0.2.1"),
+			network: "tcp6",
+		},
+	}
+	for i, test := range tests {
+		if out := interleaveFamilies(test.in, test.network); !reflect.DeepEqual(test.out, out) {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+const (
+	cmdlineDNSServersFlag = "ignition.dns.servers"
+	cmdlineDNSSearchFlag  = "ignition.dns.search"
+
+	dnsMessageType = "application/dns-message"
+	// the largest DNS message
+	maxDNSMessage = 65535
+)
+
+// dnsDialer dials connections for fetches, resolving names with the DNS
+// servers and search domains set for Ignition instead of those of the
+// system, which may be incomplete in the initramfs.
+type dnsDialer struct {
+	dialer *net.Dialer
+	search []string
+}
+
+// newDNSDialer returns the dialer for fetches, which resolves names with
+// the DNS servers and search domains from the kernel command line, or the
+// distribution's defaults, if any.
+func newDNSDialer() (*dnsDialer, error) {
+	d := &dnsDialer{
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Resolver: &net.Resolver{
+				PreferGo: true,
+			},
+		},
+	}
+	servers, search, err := dnsSettings()
+	if err != nil {
+		return nil, err
+	}
+	d.search = search
+	if len(servers) == 0 {
+		return d, nil
+	}
+
+	var dials []func(ctx context.Context, network string) (net.Conn, error)
+	for _, server := range servers {
+		dial, err := dnsServerDial(server)
+		if err != nil {
+			return nil, err
+		}
+		dials = append(dials, dial)
+	}
+	// each attempt of a query goes to the next server, so retries skip
+	// servers which don't respond
+	var next uint32
+	d.dialer.Resolver.Dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		i := atomic.AddUint32(&next, 1) - 1
+		return dials[int(i)%len(dials)](ctx, network)
+	}
+	return d, nil
+}
+
+// dnsSettings returns the DNS servers and search domains set on the kernel
+// command line, or the distribution's defaults for those which aren't.
+func dnsSettings() (servers, search []string, err error) {
+	serversArg, searchArg := distro.DNSServers(), distro.DNSSearch()
+	args, err := ioutil.ReadFile(distro.KernelCmdlinePath())
+	if os.IsNotExist(err) {
+		return splitList(serversArg), splitList(searchArg), nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	for _, arg := range strings.Fields(string(args)) {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case cmdlineDNSServersFlag:
+			serversArg = parts[1]
+		case cmdlineDNSSearchFlag:
+			searchArg = parts[1]
+		}
+	}
+	return splitList(serversArg), splitList(searchArg), nil
+}
+
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+// dnsServerDial returns the function connecting to server, which is an IP
+// address with an optional port for plain DNS, tls://<address>[#<name>]
+// for DNS over TLS, where name is the name in the server's certificate if
+// not its address, or the https URL of a DNS over HTTPS endpoint.
+func dnsServerDial(server string) (func(ctx context.Context, network string) (net.Conn, error), error) {
+	switch {
+	case strings.HasPrefix(server, "https://"):
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNS server %q: %v", server, err)
+		}
+		// the endpoint's name, if it isn't an address, is resolved by the
+		// system; its certificate guards against a spoofed answer
+		client := &http.Client{
+			Transport: &http.Transport{
+				DialContext:         (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			Timeout: 30 * time.Second,
+		}
+		return func(ctx context.Context, _ string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: client, url: u.String()}, nil
+		}, nil
+	case strings.HasPrefix(server, "tls://"):
+		address := strings.TrimPrefix(server, "tls://")
+		name := ""
+		if i := strings.Index(address, "#"); i >= 0 {
+			address, name = address[:i], address[i+1:]
+		}
+		address, err := serverAddress(address, "853")
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNS server %q: %v", server, err)
+		}
+		if name == "" {
+			name, _, _ = net.SplitHostPort(address)
+		}
+		return func(ctx context.Context, _ string) (net.Conn, error) {
+			// the resolver frames queries for a stream, as DNS over TLS
+			// requires, since the connection isn't a net.PacketConn
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", address)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, &tls.Config{ServerName: name})
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}, nil
+	default:
+		address, err := serverAddress(server, "53")
+		if err != nil {
+			return nil, fmt.Errorf("invalid DNS server %q: %v", server, err)
+		}
+		return func(ctx context.Context, network string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		}, nil
+	}
+}
+
+// serverAddress returns the address of a DNS server given as an IP address
+// with an optional port, which defaults to port.
+func serverAddress(server, port string) (string, error) {
+	if ip := net.ParseIP(strings.Trim(server, "[]")); ip != nil {
+		return net.JoinHostPort(ip.String(), port), nil
+	}
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("%q isn't an IP address", host)
+	}
+	return server, nil
+}
+
+// DialContext connects to address, qualifying a host name without dots
+// with each search domain in turn before trying it alone.
+func (d *dnsDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+	if len(d.search) == 0 || strings.Contains(host, ".") || net.ParseIP(host) != nil {
+		return d.dialHost(ctx, network, host, port)
+	}
+	for _, domain := range d.search {
+		fqdn := host + "." + strings.TrimSuffix(domain, ".") + "."
+		if conn, err := d.dialHost(ctx, network, fqdn, port); err == nil {
+			return conn, nil
+		}
+	}
+	return d.dialHost(ctx, network, host, port)
+}
+
+// dohConn carries DNS queries over HTTPS (RFC 8484). The resolver writes
+// each query framed for a stream, with its length first, and reads the
+// response framed the same way.
+type dohConn struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	query  bytes.Buffer
+	reply  bytes.Buffer
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.query.Write(b)
+	for c.query.Len() >= 2 {
+		length := int(binary.BigEndian.Uint16(c.query.Bytes()))
+		if c.query.Len() < 2+length {
+			break
+		}
+		c.query.Next(2)
+		if err := c.roundTrip(c.query.Next(length)); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (c *dohConn) roundTrip(query []byte) error {
+	req, err := http.NewRequest("POST", c.url, bytes.NewReader(query))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(c.ctx)
+	req.Header.Set("Content-Type", dnsMessageType)
+	req.Header.Set("Accept", dnsMessageType)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DNS over HTTPS query failed: %s", resp.Status)
+	}
+	reply, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDNSMessage+1))
+	if err != nil {
+		return err
+	}
+	if len(reply) > maxDNSMessage {
+		return fmt.Errorf("DNS over HTTPS reply is too large")
+	}
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(reply)))
+	c.reply.Write(length[:])
+	c.reply.Write(reply)
+	return nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.reply.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.reply.Read(b)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr{} }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr{} }
+func (c *dohConn) SetDeadline(t time.Time) error      { return nil }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type dohAddr struct{}
+
+func (dohAddr) Network() string { return "https" }
+func (dohAddr) String() string  { return "dns-over-https" }
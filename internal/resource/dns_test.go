@@ -0,0 +1,145 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// dnsHandler answers DNS over HTTPS queries for the A record of name with
+// 127.0.0.1, and every other query with no records or NXDOMAIN.
+func dnsHandler(t *testing.T, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := ioutil.ReadAll(r.Body)
+		if err != nil || r.Header.Get("Content-Type") != dnsMessageType || len(query) < 12 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		// the question follows the header, and ends after the name's
+		// labels and the type and class
+		end := 12
+		var labels []byte
+		for query[end] != 0 {
+			n := int(query[end])
+			labels = append(labels, query[end+1:end+1+n]...)
+			labels = append(labels, '.')
+			end += 1 + n
+		}
+		qtype := binary.BigEndian.Uint16(query[end+1:])
+		end += 5
+
+		reply := append([]byte{}, query[:12]...)
+		binary.BigEndian.PutUint16(reply[2:], 0x8180)
+		binary.BigEndian.PutUint16(reply[4:], 1)
+		binary.BigEndian.PutUint16(reply[6:], 0)
+		binary.BigEndian.PutUint16(reply[8:], 0)
+		binary.BigEndian.PutUint16(reply[10:], 0)
+		reply = append(reply, query[12:end]...)
+		if string(labels) != name {
+			// NXDOMAIN
+			reply[3] |= 3
+		} else if qtype == 1 {
+			binary.BigEndian.PutUint16(reply[6:], 1)
+			reply = append(reply, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+		}
+		w.Header().Set("Content-Type", dnsMessageType)
+		w.Write(reply)
+	}
+}
+
+func TestDNSOverHTTPS(t *testing.T) {
+	server := httptest.NewServer(dnsHandler(t, "node.example.com."))
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	d := dnsDialer{
+		dialer: &net.Dialer{
+			Resolver: &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return &dohConn{ctx: ctx, client: server.Client(), url: server.URL}, nil
+				},
+			},
+		},
+		search: []string{"example.org", "example.com"},
+	}
+	addrs, err := d.dialer.Resolver.LookupHost(context.Background(), "node.example.com")
+	if err != nil || len(addrs) != 1 || addrs[0] != "127.0.0.1" {
+		t.Fatalf("expected node.example.com to resolve to 127.0.0.1, got %v, %v", addrs, err)
+	}
+	// the short name is qualified with the search domains
+	conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("node", port))
+	if err != nil {
+		t.Fatalf("expected to connect to node, got %v", err)
+	}
+	conn.Close()
+	if _, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("other", port)); err == nil {
+		t.Errorf("connected to a name which doesn't resolve")
+	}
+}
+
+func TestDNSDialerResolver(t *testing.T) {
+	d, err := newDNSDialer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without servers of its own, the dialer still resolves names in Go
+	// rather than through NSS
+	if r := d.dialer.Resolver; r == nil || !r.PreferGo {
+		t.Errorf("dialer doesn't prefer the Go resolver: %+v", r)
+	}
+}
+
+func TestDNSServerAddress(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+		err bool
+	}{
+		{in: "192.0.2.1", out: "192.0.2.1:53"},
+		{in: "192.0.2.1:5353", out: "192.0.2.1:5353"},
+		{in: "2001:db8::1", out: "[2001:db8::1]:53"},
+		{in: "[2001:db8::1]:5353", out: "[2001:db8::1]:5353"},
+		{in: "dns.example.com", err: true},
+		{in: "dns.example.com:53", err: true},
+	}
+	for i, test := range tests {
+		out, err := serverAddress(test.in, "53")
+		if (err != nil) != test.err || out != test.out {
+			t.Errorf("#%d: expected %q (error %t), got %q, %v", i, test.out, test.err, out, err)
+		}
+	}
+}
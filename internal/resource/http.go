@@ -15,21 +15,26 @@
 package resource
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/earlyrand"
+	"github.com/coreos/ignition/v2/internal/fault"
 	"github.com/coreos/ignition/v2/internal/log"
 	"github.com/coreos/ignition/v2/internal/util"
 	"github.com/coreos/ignition/v2/internal/version"
@@ -42,6 +47,8 @@ import (
 const (
 	initialBackoff = 100 * time.Millisecond
 	maxBackoff     = 5 * time.Second
+	// the longest a server can make us wait with Retry-After
+	maxRetryAfter = 5 * time.Minute
 
 	defaultHttpResponseHeaderTimeout = 10
 	defaultHttpTotalTimeout          = 0
@@ -50,17 +57,41 @@ const (
 var (
 	ErrTimeout         = errors.New("unable to fetch resource in time")
 	ErrPEMDecodeFailed = errors.New("unable to decode PEM block")
+
+	// errUnavailable is returned when a request's attempts run out while
+	// it could still be retried.
+	errUnavailable = errors.New("resource unavailable")
 )
 
+// retryLaterError is returned instead of errUnavailable when the server
+// asked, with Retry-After, to be retried after delay.
+type retryLaterError struct {
+	delay time.Duration
+}
+
+func (e retryLaterError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", errUnavailable, e.delay)
+}
+
 // HttpClient is a simple wrapper around the Go HTTP client that standardizes
 // the process and logging of fetching payloads.
 type HttpClient struct {
 	client  *http.Client
 	logger  *log.Logger
 	timeout time.Duration
+	// ctx is the parent of every request's context, so cancelling it
+	// aborts requests and their retries. If nil, context.Background() is
+	// used.
+	ctx context.Context
 
 	transport *http.Transport
 	cas       map[types.CaReference][]byte
+	// header identifies Ignition, and optionally the machine, in every
+	// request
+	header http.Header
+	// attempts, if not zero, bounds the attempts of each request, so that
+	// fetches with mirrors can move on to the next
+	attempts int
 }
 
 func (f *Fetcher) UpdateHttpTimeoutsAndCAs(timeouts types.Timeouts, cas []types.CaReference, proxy types.Proxy) error {
@@ -138,7 +169,12 @@ func (f *Fetcher) getCABlob(ca types.CaReference) ([]byte, error) {
 		f.Logger.Crit("Unable to parse CA URL: %s", err)
 		return nil, err
 	}
-	hasher, err := util.GetHasher(ca.Verification)
+	verification, err := f.ResolveVerification(ca.Verification)
+	if err != nil {
+		f.Logger.Crit("Unable to find the hash of the CA: %s", err)
+		return nil, err
+	}
+	hasher, err := util.GetHasher(verification)
 	if err != nil {
 		f.Logger.Crit("Unable to get hasher: %s", err)
 		return nil, err
@@ -148,7 +184,7 @@ func (f *Fetcher) getCABlob(ca types.CaReference) ([]byte, error) {
 	if hasher != nil {
 		// explicitly ignoring the error here because the config should already
 		// be validated by this point
-		_, expectedSumString, _ := util.HashParts(ca.Verification)
+		_, expectedSumString, _ := util.HashParts(verification)
 		expectedSum, err = hex.DecodeString(expectedSumString)
 		if err != nil {
 			f.Logger.Crit("Error parsing verification string %q: %v", expectedSumString, err)
@@ -190,20 +226,19 @@ func defaultHTTPClient() (*http.Client, error) {
 		return nil, err
 	}
 
+	dialer, err := newDNSDialer()
+	if err != nil {
+		return nil, err
+	}
+
 	tlsConfig := tls.Config{
 		Rand: urand,
 	}
 	transport := http.Transport{
 		ResponseHeaderTimeout: time.Duration(defaultHttpResponseHeaderTimeout) * time.Second,
-		Dial: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-			Resolver: &net.Resolver{
-				PreferGo: true,
-			},
-		}).Dial,
-		TLSClientConfig:     &tlsConfig,
-		TLSHandshakeTimeout: 10 * time.Second,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       &tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
 	}
 	client := http.Client{
 		Transport: &transport,
@@ -211,14 +246,33 @@ func defaultHTTPClient() (*http.Client, error) {
 	return &client, nil
 }
 
+// ensureHttpClient populates the fetcher with the default HTTP client, and
+// a logger, if it hasn't been given them; this is necessary if not spawned
+// through kola (e.g. Packet Dashboard).
+func (f *Fetcher) ensureHttpClient() error {
+	if f.client != nil {
+		return nil
+	}
+	if f.Logger == nil {
+		logger := log.New(true)
+		f.Logger = &logger
+	}
+	return f.newHttpClient()
+}
+
 // newHttpClient populates the fetcher with the default HTTP client.
 func (f *Fetcher) newHttpClient() error {
 	defaultClient, err := defaultHTTPClient()
 	if err != nil {
 		return err
 	}
+	header, err := identityHeader(f.Logger)
+	if err != nil {
+		return err
+	}
 
 	f.client = &HttpClient{
+		header:    header,
 		client:    defaultClient,
 		logger:    f.Logger,
 		timeout:   time.Duration(defaultHttpTotalTimeout) * time.Second,
@@ -228,17 +282,61 @@ func (f *Fetcher) newHttpClient() error {
 	return nil
 }
 
+// withDialer returns a copy of c which makes every connection with dial,
+// whatever host the request's URL names.
+func (c HttpClient) withDialer(dial func(context.Context) (net.Conn, error)) HttpClient {
+	transport := &http.Transport{
+		ResponseHeaderTimeout: c.transport.ResponseHeaderTimeout,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx)
+		},
+	}
+	c.client = &http.Client{
+		Transport: transport,
+	}
+	c.transport = transport
+	return c
+}
+
+// withContext returns a copy of c whose requests are cancelled along with
+// ctx.
+func (c HttpClient) withContext(ctx context.Context) HttpClient {
+	c.ctx = ctx
+	return c
+}
+
 // getReaderWithHeader performs an HTTP GET on the provided URL with the
 // provided request header and returns the response body Reader, HTTP status
 // code, a cancel function for the result's context, and error (if any). By
 // default, User-Agent is added to the header but this can be overridden.
 func (c HttpClient) getReaderWithHeader(url string, header http.Header) (io.ReadCloser, int, context.CancelFunc, error) {
-	req, err := http.NewRequest("GET", url, nil)
+	return c.doWithHeader("GET", url, header, nil)
+}
+
+// doWithHeader is like getReaderWithHeader, but uses the given method and
+// sends body, if it isn't nil, with every attempt.
+func (c HttpClient) doWithHeader(method, url string, header http.Header, body []byte) (io.ReadCloser, int, context.CancelFunc, error) {
+	resp, cancelFn, err := c.do(method, url, header, body)
 	if err != nil {
-		return nil, 0, nil, err
+		return nil, 0, cancelFn, err
 	}
+	return resp.Body, resp.StatusCode, cancelFn, nil
+}
 
-	req.Header.Set("User-Agent", "Ignition/"+version.Raw)
+// do is like doWithHeader, but returns the whole response, for protocols
+// which need its header.
+func (c HttpClient) do(method, url string, header http.Header, body []byte) (*http.Response, context.CancelFunc, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "Ignition/"+version.Raw)
+	}
 
 	for key, values := range header {
 		req.Header.Del(key)
@@ -247,41 +345,117 @@ func (c HttpClient) getReaderWithHeader(url string, header http.Header) (io.Read
 		}
 	}
 
-	ctx, cancelFn := context.WithCancel(context.Background())
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancelFn := context.WithCancel(parent)
 	if c.timeout != 0 {
 		cancelFn()
-		ctx, cancelFn = context.WithTimeout(context.Background(), c.timeout)
+		ctx, cancelFn = context.WithTimeout(parent, c.timeout)
 	}
 
+	// a server which isn't ready yet may tag its reply with an ETag, so it
+	// can answer later attempts with a cheap 304 until the reply changes;
+	// only do so if the caller isn't making a conditional request itself
+	conditional := method == "GET" && req.Header.Get("If-None-Match") == ""
+
 	duration := initialBackoff
 	for attempt := 1; ; attempt++ {
-		c.logger.Info("GET %s: attempt #%d", url, attempt)
-		resp, err := c.client.Do(req.WithContext(ctx))
-
+		c.logger.Info("%s %s: attempt #%d", method, url, attempt)
+		if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		short, err := fault.HTTPRequest()
+		var resp *http.Response
 		if err == nil {
-			c.logger.Info("GET result: %s", http.StatusText(resp.StatusCode))
-			if resp.StatusCode < 500 {
-				return resp.Body, resp.StatusCode, cancelFn, nil
-			}
-			resp.Body.Close()
-		} else {
-			c.logger.Info("GET error: %v", err)
+			resp, err = c.client.Do(req.WithContext(ctx))
+		}
+		if err == nil && short {
+			resp.Body = fault.ShortBody(resp.Body)
 		}
 
 		duration = duration * 2
 		if duration > maxBackoff {
 			duration = maxBackoff
 		}
+		wait := duration
+		retryLater := false
+
+		if err == nil {
+			c.logger.Info("%s result: %s", method, http.StatusText(resp.StatusCode))
+			notModified := conditional && resp.StatusCode == http.StatusNotModified && req.Header.Get("If-None-Match") != ""
+			if !retryStatus(resp.StatusCode) && !notModified {
+				return resp, cancelFn, nil
+			}
+			if delay, ok := retryAfter(resp.Header, time.Now()); ok {
+				c.logger.Info("server asked to retry after %v", delay)
+				wait = delay
+				retryLater = true
+			}
+			if conditional && !notModified {
+				if etag := resp.Header.Get("ETag"); etag != "" {
+					req.Header.Set("If-None-Match", etag)
+				} else {
+					req.Header.Del("If-None-Match")
+				}
+			}
+			resp.Body.Close()
+		} else {
+			c.logger.Info("%s error: %v", method, err)
+		}
+		if c.attempts != 0 && attempt >= c.attempts {
+			if retryLater {
+				return nil, cancelFn, retryLaterError{delay: wait}
+			}
+			return nil, cancelFn, errUnavailable
+		}
 
 		// Wait before next attempt or exit if we timeout while waiting
 		select {
-		case <-time.After(duration):
+		case <-time.After(wait):
 		case <-ctx.Done():
-			return nil, 0, cancelFn, ErrTimeout
+			// only our own deadline is a timeout; the caller's
+			// cancellation is reported as is
+			if err := parent.Err(); err != nil {
+				return nil, cancelFn, err
+			}
+			return nil, cancelFn, ErrTimeout
 		}
 	}
 }
 
+// retryStatus returns whether a response with the given status code should
+// be retried: server errors, and rate limiting.
+func retryStatus(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests
+}
+
+// retryAfter returns the delay requested by the Retry-After header, which
+// is either a number of seconds or an HTTP date, capped at maxRetryAfter.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	var delay time.Duration
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = date.Sub(now)
+	} else {
+		return 0, false
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxRetryAfter {
+		delay = maxRetryAfter
+	}
+	return delay, true
+}
+
 func proxyFuncFromIgnitionConfig(proxy types.Proxy) func(*url.URL) (*url.URL, error) {
 	noProxy := translateNoProxySliceToString(proxy.NoProxy)
 
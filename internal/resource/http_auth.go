@@ -0,0 +1,241 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/earlyrand"
+)
+
+var (
+	ErrAuthUnsupported = errors.New("server requested an unsupported HTTP authentication scheme")
+)
+
+// challenge is an authentication challenge from a WWW-Authenticate header.
+type challenge struct {
+	scheme string
+	params map[string]string
+}
+
+// credentialFor returns the credential in f.HTTPCredentials which applies
+// to u, or nil if none does. URLs with their own user information keep
+// using it.
+func (f *Fetcher) credentialFor(u url.URL) *types.HTTPCredential {
+	if u.User != nil {
+		return nil
+	}
+	for i, cred := range f.HTTPCredentials {
+		if cred.Matches(u.Host) {
+			return &f.HTTPCredentials[i]
+		}
+	}
+	return nil
+}
+
+// getWithCredential is like getReaderWithHeader, but authenticates with
+// cred unless it's nil. A token is sent with the first request, as a
+// bearer token. A username and password are only sent in answer to the
+// server's Basic or Digest challenge, so they're never sent to servers
+// which don't ask for them, nor in the clear to those using Digest.
+func (c HttpClient) getWithCredential(u url.URL, header http.Header, cred *types.HTTPCredential) (io.ReadCloser, int, context.CancelFunc, error) {
+	if cred == nil {
+		return c.getReaderWithHeader(u.String(), header)
+	}
+	if cred.Token != nil && *cred.Token != "" {
+		header.Set("Authorization", "Bearer "+*cred.Token)
+		return c.getReaderWithHeader(u.String(), header)
+	}
+
+	resp, cancelFn, err := c.do("GET", u.String(), header, nil)
+	if err != nil {
+		return nil, 0, cancelFn, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp.Body, resp.StatusCode, cancelFn, nil
+	}
+	resp.Body.Close()
+	cancelFn()
+
+	password := ""
+	if cred.Password != nil {
+		password = *cred.Password
+	}
+	authorization, err := answerChallenge(parseChallenges(resp.Header[http.CanonicalHeaderKey("WWW-Authenticate")]), "GET", u, *cred.Username, password)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	header.Set("Authorization", authorization)
+	return c.getReaderWithHeader(u.String(), header)
+}
+
+// answerChallenge returns the Authorization header answering the
+// strongest of challenges which is supported: Digest, then Basic.
+func answerChallenge(challenges []challenge, method string, u url.URL, username, password string) (string, error) {
+	for _, ch := range challenges {
+		if ch.scheme != "digest" {
+			continue
+		}
+		if authorization, err := digestAuthorization(ch, method, u, username, password); err == nil {
+			return authorization, nil
+		}
+	}
+	for _, ch := range challenges {
+		if ch.scheme == "basic" {
+			return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+		}
+	}
+	return "", ErrAuthUnsupported
+}
+
+// digestAuthorization answers a Digest challenge, as in RFC 7616, with the
+// "auth" quality of protection if the server offers it.
+func digestAuthorization(ch challenge, method string, u url.URL, username, password string) (string, error) {
+	algorithm := ch.params["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", ErrAuthUnsupported
+	}
+	h := func(parts ...string) string {
+		hasher := newHash()
+		io.WriteString(hasher, strings.Join(parts, ":"))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	urand, err := earlyrand.UrandomReader()
+	if err != nil {
+		return "", err
+	}
+	nonceBytes := make([]byte, 16)
+	if _, err := io.ReadFull(urand, nonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(nonceBytes)
+	const nc = "00000001"
+
+	realm, nonce, uri := ch.params["realm"], ch.params["nonce"], u.RequestURI()
+	ha1 := h(username, realm, password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1, nonce, cnonce)
+	}
+	ha2 := h(method, uri)
+
+	fields := []string{
+		fmt.Sprintf("username=%s", quote(username)),
+		fmt.Sprintf("realm=%s", quote(realm)),
+		fmt.Sprintf("nonce=%s", quote(nonce)),
+		fmt.Sprintf("uri=%s", quote(uri)),
+		fmt.Sprintf("algorithm=%s", algorithm),
+	}
+	if qop, ok := ch.params["qop"]; ok {
+		auth := false
+		for _, q := range strings.Split(qop, ",") {
+			auth = auth || strings.TrimSpace(q) == "auth"
+		}
+		if !auth {
+			return "", ErrAuthUnsupported
+		}
+		fields = append(fields,
+			fmt.Sprintf("response=%s", quote(h(ha1, nonce, nc, cnonce, "auth", ha2))),
+			"qop=auth",
+			"nc="+nc,
+			fmt.Sprintf("cnonce=%s", quote(cnonce)))
+	} else {
+		fields = append(fields, fmt.Sprintf("response=%s", quote(h(ha1, nonce, ha2))))
+	}
+	if opaque, ok := ch.params["opaque"]; ok {
+		fields = append(fields, fmt.Sprintf("opaque=%s", quote(opaque)))
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// parseChallenges parses the challenges in WWW-Authenticate header values,
+// each of which may hold several. Schemes and parameter names are
+// lowercased.
+func parseChallenges(values []string) (challenges []challenge) {
+	for _, s := range values {
+		for {
+			s = strings.TrimLeft(s, " ,")
+			if s == "" {
+				break
+			}
+			end := strings.IndexAny(s, " =,")
+			if end < 0 {
+				end = len(s)
+			}
+			token := strings.ToLower(s[:end])
+			s = strings.TrimLeft(s[end:], " ")
+			if !strings.HasPrefix(s, "=") || len(challenges) == 0 {
+				challenges = append(challenges, challenge{scheme: token, params: map[string]string{}})
+				continue
+			}
+			var value string
+			value, s = parseParamValue(strings.TrimLeft(s[1:], " "))
+			challenges[len(challenges)-1].params[token] = value
+		}
+	}
+	return
+}
+
+// parseParamValue returns the quoted string or token at the start of s,
+// and the rest of s.
+func parseParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, " ,")
+		if end < 0 {
+			end = len(s)
+		}
+		return s[:end], s[end:]
+	}
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				value.WriteByte(s[i])
+			}
+		case '"':
+			return value.String(), s[i+1:]
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return value.String(), ""
+}
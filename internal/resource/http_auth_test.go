@@ -0,0 +1,129 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestParseChallenges(t *testing.T) {
+	in := []string{
+		`Digest realm="mirror", qop="auth,auth-int", nonce="abc\"d", algorithm=SHA-256, Basic realm=mirror`,
+		`Bearer`,
+	}
+	out := []challenge{
+		{"digest", map[string]string{"realm": "mirror", "qop": "auth,auth-int", "nonce": `abc"d`, "algorithm": "SHA-256"}},
+		{"basic", map[string]string{"realm": "mirror"}},
+		{"bearer", map[string]string{}},
+	}
+	if got := parseChallenges(in); !reflect.DeepEqual(out, got) {
+		t.Errorf("bad challenges:\nwant %v\ngot  %v", out, got)
+	}
+}
+
+// checkDigest verifies the Digest authorization of r as a server would.
+func checkDigest(r *http.Request, newHash func() hash.Hash, username, password, nonce string) bool {
+	challenges := parseChallenges([]string{r.Header.Get("Authorization")})
+	if len(challenges) != 1 || challenges[0].scheme != "digest" {
+		return false
+	}
+	p := challenges[0].params
+	h := func(parts ...string) string {
+		hasher := newHash()
+		io.WriteString(hasher, strings.Join(parts, ":"))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+	ha1 := h(username, p["realm"], password)
+	ha2 := h(r.Method, r.URL.RequestURI())
+	return p["username"] == username && p["nonce"] == nonce && p["uri"] == r.URL.RequestURI() &&
+		p["response"] == h(ha1, nonce, p["nc"], p["cnonce"], p["qop"], ha2)
+}
+
+func TestFetchWithCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorized := false
+		switch r.URL.Path {
+		case "/basic":
+			username, password, ok := r.BasicAuth()
+			authorized = ok && username == "core" && password == "s3cret"
+			w.Header().Set("WWW-Authenticate", `Basic realm="mirror"`)
+		case "/digest":
+			authorized = checkDigest(r, md5.New, "core", "s3cret", "n0nce")
+			w.Header().Set("WWW-Authenticate", `Digest realm="mirror", qop="auth", nonce="n0nce", opaque="0paque"`)
+		case "/digest-sha256":
+			authorized = checkDigest(r, sha256.New, "core", "s3cret", "n0nce")
+			w.Header().Add("WWW-Authenticate", `Basic realm="mirror"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="mirror", qop="auth", nonce="n0nce", algorithm=SHA-256`)
+		case "/token":
+			authorized = r.Header.Get("Authorization") == "Bearer t0ken"
+		}
+		if !authorized {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "hello")
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	password := types.HTTPCredential{Host: serverURL.Hostname(), Username: util.StrToPtr("core"), Password: util.StrToPtr("s3cret")}
+	token := types.HTTPCredential{Host: serverURL.Host, Token: util.StrToPtr("t0ken")}
+	tests := []struct {
+		path  string
+		cred  *types.HTTPCredential
+		valid bool
+	}{
+		{"/basic", &password, true},
+		{"/digest", &password, true},
+		{"/digest-sha256", &password, true},
+		{"/token", &token, true},
+		{"/basic", nil, false},
+		{"/token", &password, false},
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	for i, test := range tests {
+		f := Fetcher{Logger: &logger}
+		if test.cred != nil {
+			f.HTTPCredentials = []types.HTTPCredential{*test.cred}
+		}
+		if err := f.newHttpClient(); err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(server.URL + test.path)
+		data, err := f.FetchToBuffer(*u, FetchOptions{})
+		if test.valid && (err != nil || string(data) != "hello") {
+			t.Errorf("#%d: fetch failed: %v", i, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("#%d: fetch succeeded without the right credentials", i)
+		}
+	}
+}
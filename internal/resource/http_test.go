@@ -0,0 +1,345 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"context"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/util"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{" 0 ", 0, true},
+		{"86400", maxRetryAfter, true},
+		{"Sun, 01 Mar 2020 12:00:30 GMT", 30 * time.Second, true},
+		{"Sun, 01 Mar 2020 11:00:00 GMT", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+	}
+
+	for i, test := range tests {
+		header := http.Header{}
+		if test.value != "" {
+			header.Set("Retry-After", test.value)
+		}
+		delay, ok := retryAfter(header, now)
+		if delay != test.delay || ok != test.ok {
+			t.Errorf("#%d: expected %v, %v, got %v, %v", i, test.delay, test.ok, delay, ok)
+		}
+	}
+}
+
+func TestFetchRetries(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("If-None-Match"))
+		switch len(requests) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.Header().Set("ETag", `"rendering"`)
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 3:
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", `"ready"`)
+			fmt.Fprint(w, "hello")
+		}
+	}))
+	defer server.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	data, err := f.FetchToBuffer(*u, FetchOptions{})
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	expected := []string{"", "", `"rendering"`, `"rendering"`}
+	if fmt.Sprint(requests) != fmt.Sprint(expected) {
+		t.Errorf("expected If-None-Match headers %q, got %q", expected, requests)
+	}
+}
+
+func TestFetchMirrors(t *testing.T) {
+	var requests []string
+	handler := func(name string, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, name)
+			w.WriteHeader(status)
+			if status == http.StatusOK {
+				fmt.Fprint(w, "hello")
+			}
+		}
+	}
+	// the source can't be reached, the first mirror is failing, and the
+	// second has the object after it's been asked once
+	dead := httptest.NewServer(handler("dead", http.StatusOK))
+	dead.Close()
+	failing := httptest.NewServer(handler("failing", http.StatusServiceUnavailable))
+	defer failing.Close()
+	attempts := 0
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			handler("slow", http.StatusServiceUnavailable)(w, r)
+		} else {
+			handler("slow", http.StatusOK)(w, r)
+		}
+	}))
+	defer slow.Close()
+	missing := httptest.NewServer(handler("missing", http.StatusNotFound))
+	defer missing.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	source, _ := url.Parse(dead.URL)
+	mirrors, err := ParseMirrors([]string{missing.URL, failing.URL, slow.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := f.FetchToBuffer(*source, FetchOptions{Mirrors: mirrors})
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	// a mirror which answers isn't asked again
+	expected := []string{"missing", "failing", "slow", "failing", "slow"}
+	if fmt.Sprint(requests) != fmt.Sprint(expected) {
+		t.Errorf("expected requests %q, got %q", expected, requests)
+	}
+
+	// if no URL can be reached, the fetch times out
+	f.client.timeout = 500 * time.Millisecond
+	mirrors, _ = ParseMirrors([]string{failing.URL})
+	if _, err := f.FetchToBuffer(*source, FetchOptions{Mirrors: mirrors}); err != ErrTimeout {
+		t.Errorf("expected %v, got %v", ErrTimeout, err)
+	}
+}
+
+func TestFetchMirrorsVerified(t *testing.T) {
+	stale := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "stale")
+	}))
+	defer stale.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha512.Sum512([]byte("hello"))
+	source, _ := url.Parse(stale.URL)
+	tests := []struct {
+		mirrors  []string
+		out      string
+		mismatch bool
+	}{
+		// every URL is verified against the same hash, and what a URL
+		// which fails verification wrote is discarded
+		{[]string{stale.URL + "/mirror", "data:,hello"}, "hello", false},
+		{[]string{"data:,stale", "data:,hello"}, "hello", false},
+		{[]string{stale.URL + "/mirror"}, "", true},
+	}
+	for i, test := range tests {
+		mirrors, err := ParseMirrors(test.mirrors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest, err := ioutil.TempFile("", "ignition-mirrors-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.Fetch(context.Background(), *source, dest, FetchOptions{
+			Hash:        sha512.New(),
+			ExpectedSum: sum[:],
+			Mirrors:     mirrors,
+		})
+		dest.Close()
+		out, _ := ioutil.ReadFile(dest.Name())
+		os.Remove(dest.Name())
+		if test.mismatch {
+			if _, ok := err.(util.ErrHashMismatch); !ok {
+				t.Errorf("#%d: expected a hash mismatch, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil || string(out) != test.out {
+			t.Errorf("#%d: expected %q, got %q, %v", i, test.out, out, err)
+		}
+	}
+}
+
+func TestFetchCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// never finish the response until the test is over
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "partial")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	dest, err := ioutil.TempFile("", "ignition-cancel-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dest.Name())
+	defer dest.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	u, _ := url.Parse(server.URL)
+	if err := f.Fetch(ctx, *u, dest, FetchOptions{}); err == nil {
+		t.Fatal("fetch succeeded after its context was cancelled")
+	}
+	if ctx.Err() == nil {
+		t.Fatal("fetch returned before its context was cancelled")
+	}
+
+	// a fetch with a context which is already done doesn't start
+	if err := f.Fetch(ctx, *u, dest, FetchOptions{}); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestFetchFaults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer server.Close()
+	defer os.Unsetenv("IGNITION_FAULTS")
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	dest, err := ioutil.TempFile("", "ignition-fault-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(dest.Name())
+	defer dest.Close()
+
+	// a failed attempt is retried
+	os.Setenv("IGNITION_FAULTS", "fetch-fail=1")
+	if data, err := f.FetchToBuffer(*u, FetchOptions{}); err != nil || string(data) != "hello" {
+		t.Errorf("fetch wasn't retried: %q, %v", data, err)
+	}
+
+	// but a response which is cut short fails the fetch
+	os.Setenv("IGNITION_FAULTS", "short-read=1")
+	if _, err := f.FetchToBuffer(*u, FetchOptions{}); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	os.Setenv("IGNITION_FAULTS", "enospc=1")
+	err = f.Fetch(context.Background(), *u, dest, FetchOptions{})
+	if perr, ok := err.(*os.PathError); !ok || perr.Err != syscall.ENOSPC {
+		t.Errorf("expected ENOSPC, got %v", err)
+	}
+}
+
+func TestFetchIdentityHeaders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		fmt.Fprint(w, "hello")
+	}))
+	defer server.Close()
+
+	uuid, err := ioutil.TempFile("", "ignition-uuid-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(uuid.Name())
+	fmt.Fprintln(uuid, "4c4c4544-0038-3910-8044-b4c04f4a4d32")
+	uuid.Close()
+
+	os.Setenv("IGNITION_USER_AGENT", "Ignition-Appliance/1.0")
+	defer os.Unsetenv("IGNITION_USER_AGENT")
+	os.Setenv("IGNITION_IDENTITY_HEADERS", "uuid,serial")
+	defer os.Unsetenv("IGNITION_IDENTITY_HEADERS")
+	os.Setenv("IGNITION_PRODUCT_UUID_PATH", uuid.Name())
+	defer os.Unsetenv("IGNITION_PRODUCT_UUID_PATH")
+	// the machine has no serial number
+	os.Setenv("IGNITION_PRODUCT_SERIAL_PATH", uuid.Name()+".missing")
+	defer os.Unsetenv("IGNITION_PRODUCT_SERIAL_PATH")
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	if _, err := f.FetchToBuffer(*u, FetchOptions{}); err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if agent := header.Get("User-Agent"); agent != "Ignition-Appliance/1.0" {
+		t.Errorf("expected the configured User-Agent, got %q", agent)
+	}
+	if id := header.Get("X-Ignition-System-UUID"); id != "4c4c4544-0038-3910-8044-b4c04f4a4d32" {
+		t.Errorf("expected the system UUID, got %q", id)
+	}
+	if _, ok := header["X-Ignition-System-Serial"]; ok {
+		t.Errorf("sent a missing serial number")
+	}
+
+	os.Setenv("IGNITION_IDENTITY_HEADERS", "hostname")
+	if err := f.newHttpClient(); err == nil {
+		t.Errorf("unknown identity header was accepted")
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+// identifiers maps the identifiers distro.IdentityHeaders can list to the
+// header sending each and the file it's read from.
+var identifiers = map[string]struct {
+	header string
+	path   func() string
+}{
+	"uuid":       {"X-Ignition-System-UUID", distro.ProductUUIDPath},
+	"serial":     {"X-Ignition-System-Serial", distro.ProductSerialPath},
+	"machine-id": {"X-Ignition-Machine-ID", distro.MachineIDPath},
+}
+
+// identityHeader returns the header identifying Ignition, with the
+// configured User-Agent, and the machine, with the identifiers listed by
+// distro.IdentityHeaders. Identifiers the machine doesn't have are skipped.
+func identityHeader(logger *log.Logger) (http.Header, error) {
+	header := http.Header{}
+	if agent := distro.UserAgent(); agent != "" {
+		header.Set("User-Agent", agent)
+	}
+	for _, id := range distro.IdentityHeaders() {
+		id = strings.TrimSpace(id)
+		identifier, ok := identifiers[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown identity header %q", id)
+		}
+		value, err := ioutil.ReadFile(identifier.path())
+		if os.IsNotExist(err) {
+			logger.Info("not sending %s: %v", identifier.header, err)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", id, err)
+		}
+		if v := strings.TrimSpace(string(value)); v != "" {
+			header.Set(identifier.header, v)
+		}
+	}
+	return header, nil
+}
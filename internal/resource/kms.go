@@ -0,0 +1,204 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+var (
+	gcpTokenURL = url.URL{
+		Scheme: "http",
+		Host:   "metadata.google.internal",
+		Path:   "computeMetadata/v1/instance/service-accounts/default/token",
+	}
+	gcpKMSEndpoint = "https://cloudkms.googleapis.com/v1/"
+)
+
+// decrypt reads all of src, which must be a 12 byte nonce followed by the
+// contents sealed with AES-256-GCM, and opens it with the data key in e
+// after decrypting that with the cloud's KMS. The contents must be held in
+// memory to be authenticated, so they're reserved in r, and opened in place.
+// Cancelling ctx aborts the requests to the KMS.
+func (f *Fetcher) decrypt(ctx context.Context, src io.Reader, e types.Encryption, r *reservation) ([]byte, error) {
+	sealed, err := readAll(src, r)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := base64.StdEncoding.DecodeString(*e.DataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	switch *e.Provider {
+	case "aws":
+		key, err = f.decryptKeyAWS(ctx, *e.Key, encryptedKey)
+	case "gcp":
+		key, err = f.decryptKeyGCP(ctx, *e.Key, encryptedKey)
+	default:
+		err = fmt.Errorf("unsupported encryption provider %q", *e.Provider)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decrypting data key: %v", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting contents: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting contents: %v", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("decrypting contents: too short to be encrypted")
+	}
+	ciphertext := sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(ciphertext[:0], sealed[:gcm.NonceSize()], ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting contents: %v", err)
+	}
+	return plaintext, nil
+}
+
+// decryptKeyAWS decrypts ciphertext with the AWS KMS key named by the ARN
+// keyARN, using the credentials of the instance's IAM role.
+func (f *Fetcher) decryptKeyAWS(ctx context.Context, keyARN string, ciphertext []byte) ([]byte, error) {
+	// arn:partition:kms:region:account:key/id
+	region := strings.Split(keyARN, ":")[3]
+	endpoint, err := endpoints.DefaultResolver().EndpointFor("kms", region)
+	if err != nil {
+		return nil, err
+	}
+	if f.AWSSession == nil {
+		if f.AWSSession, err = session.NewSession(); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"CiphertextBlob": base64.StdEncoding.EncodeToString(ciphertext),
+		"KeyId":          keyARN,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// sign a request to copy the headers from, since the HTTP client
+	// creates its own requests
+	req, err := http.NewRequest("POST", endpoint.URL+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	signer := v4.NewSigner(f.AWSSession.Config.Credentials)
+	if _, err := signer.Sign(req, bytes.NewReader(body), "kms", region, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Plaintext []byte
+		Message   string
+	}
+	status, err := f.postJSON(ctx, endpoint.URL+"/", req.Header, body, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("AWS KMS returned %q: %s", http.StatusText(status), resp.Message)
+	}
+	return resp.Plaintext, nil
+}
+
+// decryptKeyGCP decrypts ciphertext with the Cloud KMS CryptoKey named by
+// keyName, using the token of the instance's default service account.
+func (f *Fetcher) decryptKeyGCP(ctx context.Context, keyName string, ciphertext []byte) ([]byte, error) {
+	header := make(http.Header)
+	header.Set("Metadata-Flavor", "Google")
+	data, err := f.fetchToBuffer(ctx, gcpTokenURL, FetchOptions{Headers: header})
+	if err != nil {
+		return nil, fmt.Errorf("fetching service account token: %v", err)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("parsing service account token: %v", err)
+	}
+
+	body, err := json.Marshal(map[string][]byte{"ciphertext": ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	header = make(http.Header)
+	header.Set("Authorization", "Bearer "+token.AccessToken)
+	header.Set("Content-Type", "application/json")
+	var resp struct {
+		Plaintext []byte `json:"plaintext"`
+		Error     struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	status, err := f.postJSON(ctx, gcpKMSEndpoint+keyName+":decrypt", header, body, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("Cloud KMS returned %q: %s", http.StatusText(status), resp.Error.Message)
+	}
+	return resp.Plaintext, nil
+}
+
+// postJSON POSTs body to u, decodes the JSON response into resp, and
+// returns the response's status. Error responses are decoded too, so callers
+// can report the server's explanation.
+func (f *Fetcher) postJSON(ctx context.Context, u string, header http.Header, body []byte, resp interface{}) (int, error) {
+	if f.client == nil {
+		if err := f.newHttpClient(); err != nil {
+			return 0, err
+		}
+	}
+	reader, status, cancel, err := f.client.withContext(ctx).doWithHeader("POST", u, header, body)
+	if cancel != nil {
+		defer cancel()
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(resp); err != nil && status == http.StatusOK {
+		return 0, err
+	}
+	return status, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func seal(t *testing.T, key, nonce, plaintext []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil)
+}
+
+func TestDecryptGCP(t *testing.T) {
+	const keyName = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+	dataKey := bytes.Repeat([]byte{7}, 32)
+	wrappedKey := []byte("wrapped")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"access_token": "token"}`))
+		case "/v1/" + keyName + ":decrypt":
+			var req struct {
+				Ciphertext []byte `json:"ciphertext"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if r.Header.Get("Authorization") != "Bearer token" || !bytes.Equal(req.Ciphertext, wrappedKey) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": {"message": "Decryption failed"}}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string][]byte{"plaintext": dataKey})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	oldTokenURL, oldEndpoint := gcpTokenURL, gcpKMSEndpoint
+	gcpTokenURL = url.URL{Scheme: "http", Host: serverURL.Host, Path: "/token"}
+	gcpKMSEndpoint = server.URL + "/v1/"
+	defer func() {
+		gcpTokenURL, gcpKMSEndpoint = oldTokenURL, oldEndpoint
+	}()
+
+	logger := log.New(true)
+	defer logger.Close()
+	nonce := bytes.Repeat([]byte{1}, 12)
+	sealed := seal(t, dataKey, nonce, []byte("secret"))
+	source := url.URL{Scheme: "data", Opaque: ";base64," + base64.StdEncoding.EncodeToString(sealed)}
+
+	tests := []struct {
+		dataKey []byte
+		source  url.URL
+		out     string
+		err     bool
+	}{
+		{
+			dataKey: wrappedKey,
+			source:  source,
+			out:     "secret",
+		},
+		{
+			dataKey: []byte("wrong"),
+			source:  source,
+			err:     true,
+		},
+		{
+			// contents sealed with another key
+			dataKey: wrappedKey,
+			source:  url.URL{Scheme: "data", Opaque: ";base64," + base64.StdEncoding.EncodeToString(seal(t, bytes.Repeat([]byte{8}, 32), nonce, []byte("secret")))},
+			err:     true,
+		},
+		{
+			dataKey: wrappedKey,
+			source:  url.URL{Scheme: "data", Opaque: ",short"},
+			err:     true,
+		},
+	}
+
+	for i, test := range tests {
+		f := Fetcher{Logger: &logger}
+		opts := FetchOptions{
+			Encryption: types.Encryption{
+				Provider: util.StrToPtr("gcp"),
+				Key:      util.StrToPtr(keyName),
+				DataKey:  util.StrToPtr(base64.StdEncoding.EncodeToString(test.dataKey)),
+			},
+		}
+		out, err := f.FetchToBuffer(test.source, opts)
+		if (err != nil) != test.err {
+			t.Errorf("#%d: bad error: %v", i, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out, string(out))
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	// DefaultMaxFetchMemory is the default maximum number of bytes one
+	// fetch may hold in memory.
+	DefaultMaxFetchMemory = 128 * 1024 * 1024
+
+	// DefaultMaxTotalFetchMemory is the default maximum number of bytes
+	// all fetches in progress may hold in memory together.
+	DefaultMaxTotalFetchMemory = 256 * 1024 * 1024
+
+	// copyBufferSize is the size of the buffer through which contents
+	// are streamed to their destination.
+	copyBufferSize = 32 * 1024
+)
+
+var (
+	ErrMemoryBudget = errors.New("resource is too large to hold in memory (see --max-fetch-memory and --max-total-fetch-memory)")
+
+	copyBuffers = sync.Pool{
+		New: func() interface{} {
+			return make([]byte, copyBufferSize)
+		},
+	}
+)
+
+// MemoryBudget bounds the memory used by contents which can't be streamed
+// to their destination: resources fetched into buffers, such as configs,
+// and encrypted contents, which must be read in full to be authenticated.
+// Everything else is streamed through a buffer of fixed size. A nil budget
+// is unlimited.
+type MemoryBudget struct {
+	// PerFetch is the maximum number of bytes one fetch may hold, or 0
+	// for no limit.
+	PerFetch int64
+	// Total is the maximum number of bytes all fetches in progress may
+	// hold together, or 0 for no limit.
+	Total int64
+
+	mu   sync.Mutex
+	used int64
+}
+
+// reservation is the memory held by one fetch.
+type reservation struct {
+	budget *MemoryBudget
+	held   int64
+}
+
+// reserve starts a reservation against b, which must be released once the
+// fetch is done.
+func (b *MemoryBudget) reserve() *reservation {
+	return &reservation{budget: b}
+}
+
+// grow adds n bytes to the reservation, failing with ErrMemoryBudget if
+// that exceeds either limit.
+func (r *reservation) grow(n int64) error {
+	b := r.budget
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.PerFetch > 0 && r.held+n > b.PerFetch {
+		return ErrMemoryBudget
+	}
+	if b.Total > 0 && b.used+n > b.Total {
+		return ErrMemoryBudget
+	}
+	b.used += n
+	r.held += n
+	return nil
+}
+
+// release returns the reservation's memory to the budget.
+func (r *reservation) release() {
+	b := r.budget
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.used -= r.held
+	b.mu.Unlock()
+	r.held = 0
+}
+
+// budgetedBuffer is a bytes.Buffer which reserves the memory it grows by.
+// The buffer isn't embedded, so copies can't bypass Write with ReadFrom.
+type budgetedBuffer struct {
+	buf bytes.Buffer
+	r   *reservation
+}
+
+func (b *budgetedBuffer) Write(p []byte) (int, error) {
+	if err := b.r.grow(int64(len(p))); err != nil {
+		return 0, err
+	}
+	return b.buf.Write(p)
+}
+
+func (b *budgetedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// readAll is ioutil.ReadAll, reserving the memory read into.
+func readAll(src io.Reader, r *reservation) ([]byte, error) {
+	buf := budgetedBuffer{r: r}
+	if _, err := copyBuffered(&buf, src); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// copyBuffered is io.Copy, but shares its buffers between copies rather
+// than allocating one for each.
+func copyBuffered(dest io.Writer, src io.Reader) (int64, error) {
+	buf := copyBuffers.Get().([]byte)
+	defer copyBuffers.Put(buf)
+	return io.CopyBuffer(dest, src, buf)
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	b := &MemoryBudget{PerFetch: 10, Total: 15}
+	r1 := b.reserve()
+	if err := r1.grow(10); err != nil {
+		t.Fatalf("growing within the budget failed: %v", err)
+	}
+	if err := r1.grow(1); err != ErrMemoryBudget {
+		t.Errorf("growing past the per-fetch limit: want %v, got %v", ErrMemoryBudget, err)
+	}
+	r2 := b.reserve()
+	if err := r2.grow(6); err != ErrMemoryBudget {
+		t.Errorf("growing past the total limit: want %v, got %v", ErrMemoryBudget, err)
+	}
+	r1.release()
+	if err := r2.grow(6); err != nil {
+		t.Errorf("growing after a release failed: %v", err)
+	}
+	r2.release()
+	if b.used != 0 {
+		t.Errorf("%d bytes still used after releasing everything", b.used)
+	}
+
+	// a nil budget is unlimited
+	var unlimited *MemoryBudget
+	if err := unlimited.reserve().grow(1 << 40); err != nil {
+		t.Errorf("nil budget: %v", err)
+	}
+}
+
+func TestFetchToBufferMemoryBudget(t *testing.T) {
+	logger := log.New(true)
+	defer logger.Close()
+
+	contents := bytes.Repeat([]byte("a"), 1000)
+	u := url.URL{Scheme: "data", Opaque: ";base64," + base64.StdEncoding.EncodeToString(contents)}
+
+	budget := &MemoryBudget{PerFetch: 1000}
+	f := Fetcher{Logger: &logger, Memory: budget}
+	out, err := f.FetchToBuffer(u, FetchOptions{})
+	if err != nil {
+		t.Fatalf("fetch within the budget failed: %v", err)
+	}
+	if !bytes.Equal(out, contents) {
+		t.Errorf("bad contents: want %d bytes, got %d", len(contents), len(out))
+	}
+	if budget.used != 0 {
+		t.Errorf("%d bytes still used after the fetch", budget.used)
+	}
+
+	budget.PerFetch = 999
+	if _, err := f.FetchToBuffer(u, FetchOptions{}); err != ErrMemoryBudget {
+		t.Errorf("bad error: want %v, got %v", ErrMemoryBudget, err)
+	}
+	if budget.used != 0 {
+		t.Errorf("%d bytes still used after the failed fetch", budget.used)
+	}
+}
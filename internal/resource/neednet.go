@@ -0,0 +1,74 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"net/url"
+	"reflect"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+// ConfigNeedsNet returns true if a stage needs networking to apply cfg, to
+// fetch a source, pull container images, or enroll certificates.
+// The ignition section isn't checked, since the configs and CAs it
+// references were fetched along with cfg.
+func ConfigNeedsNet(cfg types.Config) (bool, error) {
+	if len(cfg.Containers.Images) > 0 || len(cfg.Security.HostCertificates) > 0 {
+		return true, nil
+	}
+	cfg.Ignition = types.Ignition{}
+	return valueNeedsNet(reflect.ValueOf(cfg))
+}
+
+// valueNeedsNet returns true if a field named Source anywhere within v holds
+// a URL whose fetching requires networking.
+func valueNeedsNet(v reflect.Value) (bool, error) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return false, nil
+		}
+		return valueNeedsNet(v.Elem())
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if needsNet, err := valueNeedsNet(v.Index(i)); err != nil || needsNet {
+				return needsNet, err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if v.Type().Field(i).Name == "Source" {
+				field = reflect.Indirect(field)
+				if field.Kind() != reflect.String {
+					continue
+				}
+				u, err := url.Parse(field.String())
+				if err != nil {
+					return false, err
+				}
+				if NeedsNet(*u) {
+					return true, nil
+				}
+				continue
+			}
+			if needsNet, err := valueNeedsNet(field); err != nil || needsNet {
+				return needsNet, err
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+func TestConfigNeedsNet(t *testing.T) {
+	file := func(source string) types.File {
+		return types.File{
+			Node: types.Node{Path: "/etc/motd"},
+			FileEmbedded1: types.FileEmbedded1{
+				Contents: types.FileContents{Source: util.StrToPtr(source)},
+			},
+		}
+	}
+
+	tests := []struct {
+		in  types.Config
+		out bool
+	}{
+		{types.Config{}, false},
+		{
+			in: types.Config{
+				Storage: types.Storage{Files: []types.File{file("data:,hello")}},
+			},
+			out: false,
+		},
+		{
+			in: types.Config{
+				Storage: types.Storage{Files: []types.File{file("data:,hello"), file("https://example.com/motd")}},
+			},
+			out: true,
+		},
+		{
+			in: types.Config{
+				Storage: types.Storage{Files: []types.File{{
+					Node: types.Node{Path: "/etc/motd"},
+					FileEmbedded1: types.FileEmbedded1{
+						Append: []types.FileContents{{Source: util.StrToPtr("tftp://example.com/motd")}},
+					},
+				}}},
+			},
+			out: true,
+		},
+		{
+			in: types.Config{
+				Security: types.SystemSecurity{
+					TrustedCertificates: []types.TrustedCertificate{{Name: "ca", Source: util.StrToPtr("s3://bucket/ca.crt")}},
+				},
+			},
+			out: true,
+		},
+		{
+			in: types.Config{
+				Containers: types.Containers{Images: []types.ContainerImage{{Name: "quay.io/example/app"}}},
+			},
+			out: true,
+		},
+		{
+			// configs and CAs in the ignition section were already fetched
+			in: types.Config{
+				Ignition: types.Ignition{
+					Config: types.IgnitionConfig{
+						Merge: []types.ConfigReference{{Source: util.StrToPtr("https://example.com/overlay.ign")}},
+					},
+				},
+			},
+			out: false,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := ConfigNeedsNet(test.in)
+		if err != nil {
+			t.Errorf("#%d: ConfigNeedsNet failed: %v", i, err)
+		} else if out != test.out {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, out)
+		}
+	}
+}
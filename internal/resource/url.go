@@ -18,20 +18,33 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/coreos/ignition/v2/config"
 	configErrors "github.com/coreos/ignition/v2/config/shared/errors"
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/fault"
 	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/signature"
 	"github.com/coreos/ignition/v2/internal/util"
+	"github.com/coreos/ignition/v2/scheme"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -43,12 +56,24 @@ import (
 	"github.com/vincent-petithory/dataurl"
 )
 
+const (
+	// DefaultMaxDataURLSize is the default maximum size of the contents of
+	// a data URL.
+	DefaultMaxDataURLSize = 128 * 1024 * 1024
+)
+
 var (
 	ErrSchemeUnsupported      = errors.New("unsupported source scheme")
 	ErrPathNotAbsolute        = errors.New("path is not absolute")
 	ErrNotFound               = errors.New("resource not found")
 	ErrFailed                 = errors.New("failed to fetch resource")
 	ErrCompressionUnsupported = errors.New("compression is not supported with that scheme")
+	ErrDataURLTooLarge        = errors.New("data URL contents exceed the maximum size (see --data-url-max-size)")
+	ErrTooLarge               = errors.New("resource exceeds the maximum size")
+	ErrVaultNotConfigured     = errors.New("vault URL used without ignition.security.vault")
+	ErrVaultFieldNotFound     = errors.New("field not found in vault secret")
+	ErrEncryptionUnsupported  = errors.New("encryption is not supported with that scheme")
+	ErrNeedNet                = errors.New("resource requires networking")
 
 	// ConfigHeaders are the HTTP headers that should be used when the Ignition
 	// config is being fetched
@@ -76,6 +101,47 @@ type Fetcher struct {
 	// The region where the AWS machine trying to fetch is.
 	// This is used as a hint to fetch the S3 bucket from the right partition and region.
 	S3RegionHint string
+
+	// MaxDataURLSize is the maximum size in bytes of the decoded (and
+	// decompressed) contents of a data URL. If zero,
+	// DefaultMaxDataURLSize is used.
+	MaxDataURLSize int64
+
+	// AcceptYAML allows configs to be written in YAML as well as JSON.
+	AcceptYAML bool
+
+	// Limits bounds the size and complexity of the configs which are
+	// parsed. Zero fields are unlimited.
+	Limits config.Limits
+
+	// Vault is the Vault server from which vault:// URLs are fetched, and
+	// how to log in to it.
+	Vault types.Vault
+
+	// vaultToken caches the token from logging in to Vault.
+	vaultToken string
+
+	// Signature is the set of keys trusted to sign fetched configs. If it
+	// has any keys, configs must be signed by one of them.
+	Signature signature.Policy
+
+	// HTTPCredentials are the credentials with which http(s) resources
+	// are fetched from their hosts.
+	HTTPCredentials []types.HTTPCredential
+
+	// Offline makes fetches which require networking fail with
+	// ErrNeedNet instead of being attempted.
+	Offline bool
+
+	// Memory bounds the memory used to hold fetched contents. It's a
+	// pointer so that copies of the fetcher share the total. If nil,
+	// memory is unlimited.
+	Memory *MemoryBudget
+
+	// Caches are pull-through caches, such as "ignition serve -cache-dir",
+	// from which files with an expected sum are fetched before their
+	// source; see CacheObjectPath.
+	Caches []url.URL
 }
 
 type FetchOptions struct {
@@ -94,31 +160,89 @@ type FetchOptions struct {
 	// Compression specifies the type of compression to use when decompressing
 	// the fetched object. If left empty, no decompression will be used.
 	Compression string
+
+	// Encryption specifies how to decrypt the fetched object, which happens
+	// before it is decompressed. If unset, no decryption will be used.
+	Encryption types.Encryption
+
+	// Mirrors are http(s) URLs serving the same object, which are tried in
+	// turn if the object can't be fetched from an http(s) URL.
+	Mirrors []url.URL
+
+	// PatchFrom, if set, is the path of the file the fetched object is a
+	// zstd patch from. The result of applying it is written to the
+	// destination and verified. It's only supported by Fetch.
+	PatchFrom string
+
+	// MaxSize, if not zero, bounds the size of the fetched object. Fetches
+	// of larger objects fail with ErrTooLarge. It's only supported by Fetch.
+	MaxSize int64
+
+	// attempts, if not zero, bounds the attempts made at each URL.
+	attempts int
+
+	// caches is the number of URLs, starting with the first, which are
+	// caches. A cache asking to be retried later is waited for, rather
+	// than passed over for the next URL.
+	caches int
+
+	// reset, if not nil, discards what was written to the destination, so
+	// that a mirror can be fetched after a failure partway through.
+	reset func() error
+}
+
+// restart discards what a failed attempt wrote, so that another URL can be
+// fetched.
+func (opts FetchOptions) restart() error {
+	if opts.Hash != nil {
+		opts.Hash.Reset()
+	}
+	return opts.reset()
 }
 
 // FetchToBuffer will fetch the given url into a temporrary file, and then read
 // in the contents of the file and delete it. It will return the downloaded
 // contents, or an error if one was encountered.
 func (f *Fetcher) FetchToBuffer(u url.URL, opts FetchOptions) ([]byte, error) {
+	return f.fetchToBuffer(context.Background(), u, opts)
+}
+
+// fetchToBuffer is FetchToBuffer, aborting transfers like Fetch if ctx is
+// cancelled.
+func (f *Fetcher) fetchToBuffer(ctx context.Context, u url.URL, opts FetchOptions) ([]byte, error) {
+	if f.Offline && NeedsNet(u) {
+		return nil, ErrNeedNet
+	}
+	// the caller owns the contents once they're returned, so they only
+	// count against the total while the fetch is in progress
+	r := f.Memory.reserve()
+	defer r.release()
 	var err error
-	dest := new(bytes.Buffer)
+	dest := &budgetedBuffer{r: r}
 	switch u.Scheme {
-	case "http", "https":
-		err = f.fetchFromHTTP(u, dest, opts)
+	case "http", "https", "http+unix":
+		err = f.fetchFromHTTP(ctx, u, dest, opts)
 	case "tftp":
-		err = f.fetchFromTFTP(u, dest, opts)
+		err = f.fetchFromTFTP(ctx, u, dest, opts)
 	case "data":
-		err = f.fetchFromDataURL(u, dest, opts)
+		err = f.fetchFromDataURL(ctx, u, dest, opts)
+	case "vault":
+		err = f.fetchFromVault(ctx, u, dest, opts)
 	case "s3":
 		buf := &s3buf{
 			WriteAtBuffer: aws.NewWriteAtBuffer([]byte{}),
+			r:             r,
 		}
-		err = f.fetchFromS3(u, buf, opts)
+		err = f.fetchFromS3(ctx, u, buf, opts)
 		return buf.Bytes(), err
 	case "":
 		return nil, nil
 	default:
-		return nil, ErrSchemeUnsupported
+		sf := scheme.Get(u.Scheme)
+		if sf == nil {
+			return nil, ErrSchemeUnsupported
+		}
+		err = f.fetchFromScheme(ctx, sf, u, dest, opts)
 	}
 	return dest.Bytes(), err
 }
@@ -130,6 +254,24 @@ type s3buf struct {
 	*aws.WriteAtBuffer
 	// only safe to call read/seek after finishing writing. Not safe for parallel use
 	reader io.ReadSeeker
+
+	// r holds the memory of the buffer, which is size bytes long so far
+	r    *reservation
+	mu   sync.Mutex
+	size int64
+}
+
+func (s *s3buf) WriteAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	if end := off + int64(len(p)); end > s.size {
+		if err := s.r.grow(end - s.size); err != nil {
+			s.mu.Unlock()
+			return 0, err
+		}
+		s.size = end
+	}
+	s.mu.Unlock()
+	return s.WriteAtBuffer.WriteAt(p, off)
 }
 
 func (s *s3buf) Read(p []byte) (int, error) {
@@ -156,30 +298,108 @@ func (s *s3buf) Seek(offset int64, whence int) (int64, error) {
 // at the beginning. Since some url schemes (ex: s3) use chunked downloads and
 // fetch chunks out of order, Fetch's behavior when dest is not an empty file is
 // undefined.
-func (f *Fetcher) Fetch(u url.URL, dest *os.File, opts FetchOptions) error {
+//
+// Cancelling ctx aborts HTTP(S), S3, and Vault transfers and the
+// decompression of data URLs. TFTP and registered schemes can't be
+// interrupted, so they only fail if ctx is already done.
+//
+// Objects with an expected sum are fetched through f.Caches, if any, before
+// u and its mirrors are tried.
+func (f *Fetcher) Fetch(ctx context.Context, u url.URL, dest *os.File, opts FetchOptions) error {
+	if f.Offline && NeedsNet(u) {
+		return ErrNeedNet
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if opts.PatchFrom != "" {
+		return f.fetchPatch(ctx, u, dest, opts)
+	}
+	if caches := f.cacheURLs(u, opts); len(caches) > 0 {
+		opts.Mirrors = append(append(caches[1:], u), opts.Mirrors...)
+		opts.caches = len(caches)
+		u = caches[0]
+	}
+	return f.fetchFile(ctx, u, dest, opts)
+}
+
+// fetchFile is Fetch, without the caches.
+func (f *Fetcher) fetchFile(ctx context.Context, u url.URL, dest *os.File, opts FetchOptions) error {
+	if len(opts.Mirrors) > 0 {
+		opts.reset = func() error {
+			if err := dest.Truncate(0); err != nil {
+				return err
+			}
+			_, err := dest.Seek(0, io.SeekStart)
+			return err
+		}
+		if !httpURLs(append([]url.URL{u}, opts.Mirrors...)) {
+			return f.fetchInTurn(ctx, u, dest, opts)
+		}
+	}
+	w := fault.FileWriter(dest)
+	var target s3target = dest
+	if opts.MaxSize > 0 {
+		lw := &sizeLimitedWriter{w: w, n: opts.MaxSize}
+		w = lw
+		target = sizeLimitedTarget{dest, opts.MaxSize}
+		if opts.reset != nil {
+			reset := opts.reset
+			opts.reset = func() error {
+				lw.n = opts.MaxSize
+				return reset()
+			}
+		}
+	}
 	switch u.Scheme {
-	case "http", "https":
-		return f.fetchFromHTTP(u, dest, opts)
+	case "http", "https", "http+unix":
+		return f.fetchFromHTTP(ctx, u, w, opts)
 	case "tftp":
-		return f.fetchFromTFTP(u, dest, opts)
+		return f.fetchFromTFTP(ctx, u, w, opts)
 	case "data":
-		return f.fetchFromDataURL(u, dest, opts)
+		return f.fetchFromDataURL(ctx, u, w, opts)
+	case "vault":
+		return f.fetchFromVault(ctx, u, w, opts)
 	case "s3":
-		return f.fetchFromS3(u, dest, opts)
+		return f.fetchFromS3(ctx, u, target, opts)
 	case "":
 		return nil
 	default:
-		return ErrSchemeUnsupported
+		sf := scheme.Get(u.Scheme)
+		if sf == nil {
+			return ErrSchemeUnsupported
+		}
+		return f.fetchFromScheme(ctx, sf, u, w, opts)
+	}
+}
+
+// NeedsNet returns true if fetching u requires networking. Only data URLs,
+// http+unix URLs, and empty URLs are fetched without it; registered schemes
+// are assumed to require it.
+func NeedsNet(u url.URL) bool {
+	switch u.Scheme {
+	case "data", "http+unix", "":
+		return false
+	default:
+		return true
+	}
+}
+
+// fetchFromScheme fetches a resource from u with the fetcher registered for
+// its scheme into dest, returning an error if one is encountered.
+func (f *Fetcher) fetchFromScheme(ctx context.Context, sf scheme.Fetcher, u url.URL, dest io.Writer, opts FetchOptions) error {
+	src, err := sf.Fetch(u)
+	if err != nil {
+		return err
 	}
+	defer src.Close()
+	return f.decompressCopyHashAndVerify(ctx, dest, src, opts)
 }
 
 // FetchFromTFTP fetches a resource from u via TFTP into dest, returning an
 // error if one is encountered.
-func (f *Fetcher) fetchFromTFTP(u url.URL, dest io.Writer, opts FetchOptions) error {
-	if !strings.ContainsRune(u.Host, ':') {
-		u.Host = u.Host + ":69"
-	}
-	c, err := tftp.NewClient(u.Host)
+func (f *Fetcher) fetchFromTFTP(ctx context.Context, u url.URL, dest io.Writer, opts FetchOptions) error {
+	c, err := tftp.NewClient(tftpAddress(u))
 	if err != nil {
 		return err
 	}
@@ -218,7 +438,7 @@ func (f *Fetcher) fetchFromTFTP(u url.URL, dest io.Writer, opts FetchOptions) er
 		err = pWriter.Close()
 		doneChan <- err
 	}()
-	err = f.decompressCopyHashAndVerify(dest, pReader, opts)
+	err = f.decompressCopyHashAndVerify(ctx, dest, pReader, opts)
 	if err != nil {
 		return checkForDoneChanErr(err)
 	}
@@ -235,17 +455,49 @@ func (f *Fetcher) fetchFromTFTP(u url.URL, dest io.Writer, opts FetchOptions) er
 	return nil
 }
 
+// tftpAddress returns the address of the TFTP server of u, which is on port
+// 69 unless u names another. IPv6 literals keep their brackets and zone.
+func tftpAddress(u url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "69"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
 // FetchFromHTTP fetches a resource from u via HTTP(S) into dest, returning an
-// error if one is encountered.
-func (f *Fetcher) fetchFromHTTP(u url.URL, dest io.Writer, opts FetchOptions) error {
-	// for the case when "config is not valid"
-	// this if necessary if not spawned through kola (e.g. Packet Dashboard)
-	if f.client == nil {
-		logger := log.New(true)
-		f.Logger = &logger
-		if err := f.newHttpClient(); err != nil {
-			return err
+// error if one is encountered. http+unix URLs are fetched via HTTP over the
+// unix socket they name.
+func (f *Fetcher) fetchFromHTTP(ctx context.Context, u url.URL, dest io.Writer, opts FetchOptions) error {
+	if u.Scheme != "http+unix" {
+		if len(opts.Mirrors) > 0 {
+			return f.fetchFromMirrors(ctx, u, dest, opts)
 		}
+		return f.fetchOverHTTP(ctx, u, nil, f.credentialFor(u), dest, opts)
+	}
+	socket, path, ok := cutil.SplitUnixSocketURL(u)
+	if !ok {
+		return configErrors.ErrInvalidUnixSocketURL
+	}
+	target := url.URL{
+		Scheme:   "http",
+		Host:     "localhost",
+		Path:     path,
+		RawQuery: u.RawQuery,
+	}
+	dial := func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	return f.fetchOverHTTP(ctx, target, dial, nil, dest, opts)
+}
+
+// fetchOverHTTP fetches u via HTTP(S) into dest, authenticating with cred
+// unless it's nil, until ctx is cancelled. If dial isn't nil, it makes every
+// connection instead of dialing the host of u.
+func (f *Fetcher) fetchOverHTTP(ctx context.Context, u url.URL, dial func(context.Context) (net.Conn, error), cred *types.HTTPCredential, dest io.Writer, opts FetchOptions) error {
+	if err := f.ensureHttpClient(); err != nil {
+		return err
 	}
 
 	// TODO use .Clone() when we have a new enough golang
@@ -262,9 +514,17 @@ func (f *Fetcher) fetchFromHTTP(u url.URL, dest io.Writer, opts FetchOptions) er
 		}
 	}
 
-	dataReader, status, ctxCancel, err := f.client.getReaderWithHeader(u.String(), headers)
+	client := f.client.withContext(ctx)
+	if opts.attempts != 0 {
+		client.attempts = opts.attempts
+	}
+	if dial != nil {
+		client = client.withDialer(dial)
+	}
+
+	dataReader, status, ctxCancel, err := client.getWithCredential(u, headers, cred)
 	if ctxCancel != nil {
-		// whatever context getReaderWithHeader created for the request should
+		// whatever context getWithCredential created for the request should
 		// be cancelled once we're done reading the response
 		defer ctxCancel()
 	}
@@ -282,21 +542,351 @@ func (f *Fetcher) fetchFromHTTP(u url.URL, dest io.Writer, opts FetchOptions) er
 		return ErrFailed
 	}
 
-	return f.decompressCopyHashAndVerify(dest, dataReader, opts)
+	return f.decompressCopyHashAndVerify(ctx, dest, dataReader, opts)
+}
+
+// ParseMirrors parses the mirror URLs of a source, for FetchOptions.
+func ParseMirrors(mirrors []string) ([]url.URL, error) {
+	var urls []url.URL
+	for _, mirror := range mirrors {
+		u, err := url.Parse(mirror)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, *u)
+	}
+	return urls, nil
+}
+
+// httpURLs returns whether all of urls are http(s) URLs.
+func httpURLs(urls []url.URL) bool {
+	for _, u := range urls {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchInTurn fetches u into dest or, if that fails, each of opts.Mirrors
+// in turn, discarding what a failed fetch wrote. It's used when some of the
+// URLs aren't http(s), so they can't be tried in rounds.
+func (f *Fetcher) fetchInTurn(ctx context.Context, u url.URL, dest *os.File, opts FetchOptions) error {
+	urls := append([]url.URL{u}, opts.Mirrors...)
+	opts.Mirrors = nil
+	var err error
+	for i, u := range urls {
+		if i > 0 {
+			if err := opts.restart(); err != nil {
+				return err
+			}
+		}
+		if f.Offline && NeedsNet(u) {
+			err = ErrNeedNet
+			continue
+		}
+		if err = f.fetchFile(ctx, u, dest, opts); err == nil || ctx.Err() != nil {
+			return err
+		}
+		f.Logger.Info("fetching %s failed: %v", describeURL(u), err)
+	}
+	return err
+}
+
+// describeURL returns u for logging, unless it's a data URL, which might
+// contain secrets.
+func describeURL(u url.URL) string {
+	if u.Scheme == "data" {
+		return "data URL"
+	}
+	return u.String()
+}
+
+// fetchFromMirrors fetches u, or if it can't be reached, the first of
+// opts.Mirrors which can. Each round tries every URL once, in order, before
+// backing off, so a dead server only delays the fetch by one attempt. A URL
+// which answers, with anything but a server error, isn't tried again. Once
+// any of the object has been written, the fetch only moves on if opts can
+// discard it. A cache which asks to be retried later, since it's fetching
+// the object itself, is retried after the delay it asks for instead.
+func (f *Fetcher) fetchFromMirrors(ctx context.Context, u url.URL, dest io.Writer, opts FetchOptions) error {
+	if err := f.ensureHttpClient(); err != nil {
+		return err
+	}
+	parent := ctx
+	if f.client.timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.client.timeout)
+		defer cancel()
+	}
+	opts.attempts = 1
+	w := &countingWriter{w: dest}
+	urls := append([]url.URL{u}, opts.Mirrors...)
+	caches := map[string]bool{}
+	for _, u := range urls[:opts.caches] {
+		caches[u.String()] = true
+	}
+	duration := initialBackoff
+	var err error
+	for {
+		var unavailable []url.URL
+		for _, u := range urls {
+			for {
+				err = f.fetchOverHTTP(ctx, u, nil, f.credentialFor(u), w, opts)
+				later, ok := err.(retryLaterError)
+				if !ok || !caches[u.String()] {
+					break
+				}
+				// the cache is fetching the object itself
+				f.Logger.Info("cache %s asked to retry after %v", u.String(), later.delay)
+				select {
+				case <-time.After(later.delay):
+				case <-ctx.Done():
+					return f.mirrorsResult(parent, ctx, ctx.Err())
+				}
+			}
+			if err == nil || ctx.Err() != nil || (w.n > 0 && opts.reset == nil) {
+				return f.mirrorsResult(parent, ctx, err)
+			}
+			if w.n > 0 {
+				if err := opts.restart(); err != nil {
+					return err
+				}
+				w.n = 0
+			}
+			if _, ok := err.(retryLaterError); ok || err == errUnavailable {
+				unavailable = append(unavailable, u)
+			}
+			f.Logger.Info("fetching %s failed: %v", u.String(), err)
+		}
+		if len(unavailable) == 0 {
+			return err
+		}
+		urls = unavailable
+
+		duration = duration * 2
+		if duration > maxBackoff {
+			duration = maxBackoff
+		}
+		select {
+		case <-time.After(duration):
+		case <-ctx.Done():
+			return f.mirrorsResult(parent, ctx, ctx.Err())
+		}
+	}
+}
+
+// mirrorsResult reports err from fetching with mirrors within ctx, whose
+// deadline is a timeout unless parent, the caller's context, is done.
+func (f *Fetcher) mirrorsResult(parent, ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	if perr := parent.Err(); perr != nil {
+		return perr
+	}
+	return ErrTimeout
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // FetchFromDataURL writes the data stored in the dataurl u into dest, returning
-// an error if one is encountered.
-func (f *Fetcher) fetchFromDataURL(u url.URL, dest io.Writer, opts FetchOptions) error {
-	if opts.Compression != "" {
+// an error if one is encountered. Base64 contents are decoded as they're
+// written rather than all at once.
+func (f *Fetcher) fetchFromDataURL(ctx context.Context, u url.URL, dest io.Writer, opts FetchOptions) error {
+	plain, zstd := cutil.SplitZstdDataURL(u.String())
+	// gzip contents are decompressed here, which would be before they're
+	// decrypted, and zstd ones can't be compressed twice
+	if opts.Compression != "" && (zstd || opts.Encryption.IsSet()) {
 		return ErrCompressionUnsupported
 	}
-	url, err := dataurl.DecodeString(u.String())
+	src, err := dataURLReader(plain)
 	if err != nil {
 		return err
 	}
 
-	return f.decompressCopyHashAndVerify(dest, bytes.NewBuffer(url.Data), opts)
+	max := f.MaxDataURLSize
+	if max == 0 {
+		max = DefaultMaxDataURLSize
+	}
+	if zstd {
+		return f.decompressZstdDataURL(ctx, dest, src, max, opts)
+	}
+	if opts.Compression != "" {
+		// the limit applies to the decompressed contents
+		decompressor, err := f.uncompress(src, opts)
+		if err != nil {
+			return err
+		}
+		defer decompressor.Close()
+		src = decompressor
+		opts.Compression = ""
+	}
+
+	err = f.decompressCopyHashAndVerify(ctx, dest, &sizeLimitedReader{r: src, n: max}, opts)
+	if err == ErrDataURLTooLarge {
+		f.Logger.Crit("data URL contents are more than the maximum of %d bytes", max)
+	}
+	return err
+}
+
+// dataURLReader returns a reader of the decoded contents of the data URL s.
+// Base64 contents are decoded as they're read; percent-encoded ones, which
+// are rarely large, are decoded up front.
+func dataURLReader(s string) (io.Reader, error) {
+	comma := strings.IndexByte(s, ',')
+	if comma < 0 || !strings.HasSuffix(s[:comma], ";base64") {
+		url, err := dataurl.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(url.Data), nil
+	}
+	// check the media type and parameters without decoding the contents
+	if _, err := dataurl.DecodeString(s[:comma+1]); err != nil {
+		return nil, err
+	}
+	return base64.NewDecoder(base64.StdEncoding, strings.NewReader(s[comma+1:])), nil
+}
+
+// decompressZstdDataURL decompresses data with the zstd command into dest,
+// failing if the output exceeds max bytes. The command is killed if ctx is
+// cancelled.
+func (f *Fetcher) decompressZstdDataURL(ctx context.Context, dest io.Writer, data io.Reader, max int64, opts FetchOptions) error {
+	cmd := exec.CommandContext(ctx, distro.ZstdCmd(), "--decompress", "--stdout")
+	cmd.Stdin = data
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run %s: %v", distro.ZstdCmd(), err)
+	}
+
+	copyErr := f.decompressCopyHashAndVerify(ctx, dest, &sizeLimitedReader{r: stdout, n: max}, opts)
+	if _, mismatch := copyErr.(util.ErrHashMismatch); copyErr != nil && !mismatch {
+		// zstd may be blocked writing output nobody will read
+		cmd.Process.Kill()
+		cmd.Wait()
+		if copyErr == ErrDataURLTooLarge {
+			f.Logger.Crit("decompressed data URL contents are more than the maximum of %d bytes", max)
+		}
+		return copyErr
+	}
+	// a hash mismatch may be caused by truncated output, so report zstd
+	// failures first
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to decompress zstd data URL: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return copyErr
+}
+
+// fetchPatch fetches the zstd patch at u, or its mirrors, and writes the
+// result of applying it to opts.PatchFrom into dest. The patch is kept in a
+// temporary file beside dest while it's applied, since zstd reads it in
+// one pass but it may be large.
+func (f *Fetcher) fetchPatch(ctx context.Context, u url.URL, dest *os.File, opts FetchOptions) error {
+	if _, err := os.Stat(opts.PatchFrom); err != nil {
+		return fmt.Errorf("reading the file to patch: %v", err)
+	}
+	patch, err := ioutil.TempFile(filepath.Dir(dest.Name()), ".ignition-patch")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(patch.Name())
+	defer patch.Close()
+	if err := f.Fetch(ctx, u, patch, FetchOptions{Headers: opts.Headers, Mirrors: opts.Mirrors}); err != nil {
+		return err
+	}
+	if _, err := patch.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// the window must be as large as the file patched from
+	cmd := exec.CommandContext(ctx, distro.ZstdCmd(), "--decompress", "--stdout", "--long=31", "--patch-from="+opts.PatchFrom)
+	cmd.Stdin = patch
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run %s: %v", distro.ZstdCmd(), err)
+	}
+	copyErr := f.decompressCopyHashAndVerify(ctx, fault.FileWriter(dest), stdout, FetchOptions{Hash: opts.Hash, ExpectedSum: opts.ExpectedSum})
+	if _, mismatch := copyErr.(util.ErrHashMismatch); copyErr != nil && !mismatch {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return copyErr
+	}
+	// a hash mismatch may be caused by truncated output, so report zstd
+	// failures first
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to apply zstd patch: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return copyErr
+}
+
+// sizeLimitedReader returns ErrDataURLTooLarge once more than n bytes have
+// been read from r.
+type sizeLimitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrDataURLTooLarge
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrDataURLTooLarge
+	}
+	return n, err
+}
+
+// sizeLimitedWriter returns ErrTooLarge rather than write more than n bytes
+// to w.
+type sizeLimitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (l *sizeLimitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.n {
+		return 0, ErrTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// sizeLimitedTarget returns ErrTooLarge rather than write past n bytes into
+// the file, for the parts of S3 objects, which are written out of order.
+type sizeLimitedTarget struct {
+	*os.File
+	n int64
+}
+
+func (l sizeLimitedTarget) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > l.n {
+		return 0, ErrTooLarge
+	}
+	return l.File.WriteAt(p, off)
 }
 
 type s3target interface {
@@ -307,12 +897,15 @@ type s3target interface {
 // FetchFromS3 gets data from an S3 bucket as described by u and writes it into
 // dest, returning an error if one is encountered. It will attempt to acquire
 // IAM credentials from the EC2 metadata service, and if this fails will attempt
-// to fetch the object with anonymous credentials.
-func (f *Fetcher) fetchFromS3(u url.URL, dest s3target, opts FetchOptions) error {
+// to fetch the object with anonymous credentials. The download is aborted if
+// ctx is cancelled.
+func (f *Fetcher) fetchFromS3(ctx context.Context, u url.URL, dest s3target, opts FetchOptions) error {
 	if opts.Compression != "" {
 		return ErrCompressionUnsupported
 	}
-	ctx := context.Background()
+	if opts.Encryption.IsSet() {
+		return ErrEncryptionUnsupported
+	}
 	if f.client != nil && f.client.timeout != 0 {
 		var cancelFn context.CancelFunc
 		ctx, cancelFn = context.WithTimeout(ctx, f.client.timeout)
@@ -415,11 +1008,21 @@ func (f *Fetcher) uncompress(r io.Reader, opts FetchOptions) (io.ReadCloser, err
 	}
 }
 
-// decompressCopyHashAndVerify will decompress src if necessary, copy src into
-// dest until src returns an io.EOF while also calculating a hash if one is set,
-// and will return an error if there's any problems with any of this or if the
-// hash doesn't match the expected hash in the opts.
-func (f *Fetcher) decompressCopyHashAndVerify(dest io.Writer, src io.Reader, opts FetchOptions) error {
+// decompressCopyHashAndVerify will decrypt and decompress src if necessary,
+// copy src into dest until src returns an io.EOF while also calculating a hash
+// if one is set, and will return an error if there's any problems with any of
+// this or if the hash doesn't match the expected hash in the opts. Cancelling
+// ctx aborts decrypting the data key with the KMS.
+func (f *Fetcher) decompressCopyHashAndVerify(ctx context.Context, dest io.Writer, src io.Reader, opts FetchOptions) error {
+	if opts.Encryption.IsSet() {
+		r := f.Memory.reserve()
+		defer r.release()
+		plaintext, err := f.decrypt(ctx, src, opts.Encryption, r)
+		if err != nil {
+			return err
+		}
+		src = bytes.NewReader(plaintext)
+	}
 	decompressor, err := f.uncompress(src, opts)
 	if err != nil {
 		return err
@@ -429,7 +1032,7 @@ func (f *Fetcher) decompressCopyHashAndVerify(dest io.Writer, src io.Reader, opt
 		opts.Hash.Reset()
 		dest = io.MultiWriter(dest, opts.Hash)
 	}
-	_, err = io.Copy(dest, decompressor)
+	_, err = copyBuffered(dest, decompressor)
 	if err != nil {
 		return err
 	}
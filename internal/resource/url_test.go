@@ -0,0 +1,371 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/util"
+	"github.com/coreos/ignition/v2/scheme"
+)
+
+func TestFetchDataURLSizeLimit(t *testing.T) {
+	logger := log.New(true)
+	defer logger.Close()
+
+	tests := []struct {
+		url string
+		max int64
+		out string
+		err error
+	}{
+		{"data:,hello", 0, "hello", nil},
+		{"data:,hello", 5, "hello", nil},
+		{"data:,hello", 4, "", ErrDataURLTooLarge},
+		{"data:;base64,aGVsbG8=", 5, "hello", nil},
+		{"data:;base64,aGVsbG8=", 4, "", ErrDataURLTooLarge},
+	}
+
+	for i, test := range tests {
+		f := Fetcher{Logger: &logger, MaxDataURLSize: test.max}
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+		out, err := f.FetchToBuffer(*u, FetchOptions{})
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out, string(out))
+		}
+	}
+}
+
+func TestFetchGzipDataURL(t *testing.T) {
+	logger := log.New(true)
+	defer logger.Close()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte("hello"))
+	w.Close()
+	gzipped := "data:;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	tests := []struct {
+		url string
+		max int64
+		out string
+		err error
+	}{
+		{gzipped, 0, "hello", nil},
+		{gzipped, 5, "hello", nil},
+		// the limit applies to the decompressed contents
+		{gzipped, 4, "", ErrDataURLTooLarge},
+		{"data:;zstd;base64,KLUv/QRYKQAAaGVsbG9mAMaF", 0, "", ErrCompressionUnsupported},
+	}
+
+	for i, test := range tests {
+		f := Fetcher{Logger: &logger, MaxDataURLSize: test.max}
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+		out, err := f.FetchToBuffer(*u, FetchOptions{Compression: "gzip"})
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out, string(out))
+		}
+	}
+}
+
+func TestFetchZstdDataURL(t *testing.T) {
+	if _, err := exec.LookPath(distro.ZstdCmd()); err != nil {
+		t.Skipf("%s not available", distro.ZstdCmd())
+	}
+	logger := log.New(true)
+	defer logger.Close()
+
+	cmd := exec.Command(distro.ZstdCmd(), "--compress", "--stdout")
+	cmd.Stdin = bytes.NewReader(bytes.Repeat([]byte("a"), 1000))
+	compressed, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("compression failed: %v", err)
+	}
+	u := url.URL{Scheme: "data", Opaque: ";zstd;base64," + base64.StdEncoding.EncodeToString(compressed)}
+
+	f := Fetcher{Logger: &logger}
+	out, err := f.FetchToBuffer(u, FetchOptions{})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if len(out) != 1000 {
+		t.Errorf("bad length: want 1000, got %d", len(out))
+	}
+
+	// compressed contents are small, but decompress past the limit
+	f.MaxDataURLSize = 999
+	if _, err := f.FetchToBuffer(u, FetchOptions{}); err != ErrDataURLTooLarge {
+		t.Errorf("bad error: want %v, got %v", ErrDataURLTooLarge, err)
+	}
+}
+
+func TestFetchPatch(t *testing.T) {
+	if _, err := exec.LookPath(distro.ZstdCmd()); err != nil {
+		t.Skipf("%s not available", distro.ZstdCmd())
+	}
+	logger := log.New(true)
+	defer logger.Close()
+	td, err := ioutil.TempDir("", "ign-patch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(td)
+
+	base := filepath.Join(td, "image-v1")
+	updated := filepath.Join(td, "image-v2")
+	contents := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	if err := ioutil.WriteFile(base, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	copy(contents[1000:], "updated")
+	if err := ioutil.WriteFile(updated, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	patch, err := exec.Command(distro.ZstdCmd(), "--stdout", "--patch-from="+base, updated).Output()
+	if err != nil {
+		t.Fatalf("creating patch failed: %v", err)
+	}
+	u := url.URL{Scheme: "data", Opaque: ";base64," + base64.StdEncoding.EncodeToString(patch)}
+	sum := sha512.Sum512(contents)
+
+	tests := []struct {
+		base string
+		sum  []byte
+		ok   bool
+	}{
+		{base, sum[:], true},
+		// the result is verified, not the patch
+		{base, make([]byte, sha512.Size), false},
+		{filepath.Join(td, "missing"), sum[:], false},
+	}
+	f := Fetcher{Logger: &logger}
+	for i, test := range tests {
+		dest, err := ioutil.TempFile(td, "dest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = f.Fetch(context.Background(), u, dest, FetchOptions{
+			Hash:        sha512.New(),
+			ExpectedSum: test.sum,
+			PatchFrom:   test.base,
+		})
+		dest.Close()
+		if (err == nil) != test.ok {
+			t.Errorf("#%d: expected success %t, got %v", i, test.ok, err)
+			continue
+		}
+		if out, _ := ioutil.ReadFile(dest.Name()); test.ok && !bytes.Equal(out, contents) {
+			t.Errorf("#%d: bad contents after patching", i)
+		}
+	}
+}
+
+type echoFetcher struct{}
+
+func (echoFetcher) Name() string {
+	return "echo"
+}
+
+func (echoFetcher) Fetch(u url.URL) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(u.Opaque)), nil
+}
+
+func TestFetchRegisteredScheme(t *testing.T) {
+	scheme.Register(echoFetcher{})
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger}
+
+	out, err := f.FetchToBuffer(url.URL{Scheme: "echo", Opaque: "hello"}, FetchOptions{})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if string(out) != "hello" {
+		t.Errorf("bad contents: want %q, got %q", "hello", string(out))
+	}
+
+	sum := sha512.Sum512([]byte("hello"))
+	opts := FetchOptions{Hash: sha512.New(), ExpectedSum: sum[:]}
+	_, err = f.FetchToBuffer(url.URL{Scheme: "echo", Opaque: "goodbye"}, opts)
+	if _, ok := err.(util.ErrHashMismatch); !ok {
+		t.Errorf("bad error: want hash mismatch, got %v", err)
+	}
+
+	if _, err := f.FetchToBuffer(url.URL{Scheme: "unregistered", Opaque: "hello"}, FetchOptions{}); err != ErrSchemeUnsupported {
+		t.Errorf("bad error: want %v, got %v", ErrSchemeUnsupported, err)
+	}
+}
+
+func TestFetchOffline(t *testing.T) {
+	logger := log.New(true)
+	defer logger.Close()
+
+	tests := []struct {
+		url string
+		err error
+	}{
+		{"data:,hello", nil},
+		{"", nil},
+		{"http://example.com/config.ign", ErrNeedNet},
+		{"s3://bucket/config.ign", ErrNeedNet},
+		{"vault://secret/config", ErrNeedNet},
+	}
+
+	f := Fetcher{Logger: &logger, Offline: true}
+	for i, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.FetchToBuffer(*u, FetchOptions{}); err != test.err {
+			t.Errorf("#%d: expected error %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestTFTPAddress(t *testing.T) {
+	tests := []struct {
+		url     string
+		address string
+	}{
+		{"tftp://192.0.2.1/config.ign", "192.0.2.1:69"},
+		{"tftp://192.0.2.1:6969/config.ign", "192.0.2.1:6969"},
+		{"tftp://[2001:db8::1]/config.ign", "[2001:db8::1]:69"},
+		{"tftp://[2001:db8::1]:6969/config.ign", "[2001:db8::1]:6969"},
+		{"tftp://[fe80::1%25eth0]/config.ign", "[fe80::1%eth0]:69"},
+	}
+
+	for i, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address := tftpAddress(*u); address != test.address {
+			t.Errorf("#%d: expected %q, got %q", i, test.address, address)
+		}
+	}
+}
+
+func TestFetchIPv6Literal(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	server.Listener.Close()
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	f := Fetcher{Logger: &logger}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(server.URL + "/config.ign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(u.Host, "[::1]:") {
+		t.Fatalf("unexpected server address %q", u.Host)
+	}
+	data, err := f.FetchToBuffer(*u, FetchOptions{})
+	if err != nil || string(data) != "hello" {
+		t.Errorf("fetch failed: %v", err)
+	}
+}
+
+func TestFetchUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignition-unix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "provision.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/config" || r.URL.Query().Get("role") != "worker" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "hello")
+	}))
+	server.Listener.Close()
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	// fetched while offline, since the socket is local
+	f := Fetcher{Logger: &logger, Offline: true}
+	if err := f.newHttpClient(); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("http+unix:" + socket + ":/config?role=worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := f.FetchToBuffer(*u, FetchOptions{})
+	if err != nil || string(data) != "hello" {
+		t.Errorf("fetch failed: %v", err)
+	}
+
+	u, err = url.Parse("http+unix:" + socket + ":/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.FetchToBuffer(*u, FetchOptions{}); err != ErrNotFound {
+		t.Errorf("bad error: want %v, got %v", ErrNotFound, err)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+var (
+	gcpIdentityURL = url.URL{
+		Scheme: "http",
+		Host:   "metadata.google.internal",
+		Path:   "computeMetadata/v1/instance/service-accounts/default/identity",
+	}
+)
+
+// vaultResponse is the subset of Vault's API responses which Ignition uses.
+type vaultResponse struct {
+	Data map[string]interface{} `json:"data"`
+	Auth struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// fetchFromVault writes the field named by the fragment of u, from the secret
+// at the rest of u, into dest, returning an error if one is encountered. The
+// secret is read from the Vault server in f.Vault, after logging in to it
+// if necessary. The requests are aborted if ctx is cancelled.
+func (f *Fetcher) fetchFromVault(ctx context.Context, u url.URL, dest io.Writer, opts FetchOptions) error {
+	if cutil.NilOrEmpty(f.Vault.Address) {
+		return ErrVaultNotConfigured
+	}
+	token, err := f.vaultLogin(ctx)
+	if err != nil {
+		return err
+	}
+
+	header := make(http.Header)
+	header.Set("X-Vault-Token", token)
+	var resp vaultResponse
+	if err := f.vaultRequest(ctx, "GET", strings.Trim(u.Host+u.Path, "/"), header, nil, &resp); err != nil {
+		return err
+	}
+
+	data := resp.Data
+	// KV version 2 secrets nest their data beside their metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok && data["metadata"] != nil {
+		data = inner
+	}
+	value, ok := data[u.Fragment]
+	if !ok {
+		return ErrVaultFieldNotFound
+	}
+	var contents string
+	if s, ok := value.(string); ok {
+		contents = s
+	} else {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		contents = string(b)
+	}
+	return f.decompressCopyHashAndVerify(ctx, dest, strings.NewReader(contents), opts)
+}
+
+// vaultLogin returns the token with which to read secrets, logging in to
+// Vault with the configured AppRole or platform identity the first time it
+// is needed.
+func (f *Fetcher) vaultLogin(ctx context.Context) (string, error) {
+	if !cutil.NilOrEmpty(f.Vault.Token) {
+		return *f.Vault.Token, nil
+	}
+	if f.vaultToken != "" {
+		return f.vaultToken, nil
+	}
+
+	var method string
+	body := map[string]string{}
+	switch {
+	case f.Vault.AppRole.IsSet():
+		method = "approle"
+		body["role_id"] = *f.Vault.AppRole.RoleID
+		if f.Vault.AppRole.SecretID != nil {
+			body["secret_id"] = *f.Vault.AppRole.SecretID
+		}
+	case f.Vault.Identity.IsSet():
+		method = *f.Vault.Identity.Method
+		body["role"] = *f.Vault.Identity.Role
+		if err := f.addVaultIdentity(method, body); err != nil {
+			return "", err
+		}
+	default:
+		return "", ErrVaultNotConfigured
+	}
+
+	var resp vaultResponse
+	if err := f.vaultRequest(ctx, "POST", "auth/"+method+"/login", nil, body, &resp); err != nil {
+		return "", fmt.Errorf("logging in to vault with %s: %v", method, err)
+	}
+	f.vaultToken = resp.Auth.ClientToken
+	return f.vaultToken, nil
+}
+
+// addVaultIdentity adds the signed identity of this machine, as expected by
+// Vault's login endpoint for the method, to body.
+func (f *Fetcher) addVaultIdentity(method string, body map[string]string) error {
+	switch method {
+	case "aws":
+		if f.AWSSession == nil {
+			var err error
+			if f.AWSSession, err = session.NewSession(); err != nil {
+				return err
+			}
+		}
+		pkcs7, err := ec2metadata.New(f.AWSSession).GetDynamicData("instance-identity/pkcs7")
+		if err != nil {
+			return fmt.Errorf("fetching instance identity: %v", err)
+		}
+		body["pkcs7"] = strings.Replace(pkcs7, "\n", "", -1)
+	case "gcp":
+		u := gcpIdentityURL
+		u.RawQuery = url.Values{
+			"audience": {"http://vault/" + body["role"]},
+			"format":   {"full"},
+		}.Encode()
+		header := make(http.Header)
+		header.Set("Metadata-Flavor", "Google")
+		jwt, err := f.FetchToBuffer(u, FetchOptions{Headers: header})
+		if err != nil {
+			return fmt.Errorf("fetching instance identity: %v", err)
+		}
+		body["jwt"] = string(jwt)
+	default:
+		return fmt.Errorf("unsupported vault identity method %q", method)
+	}
+	return nil
+}
+
+// vaultRequest sends a request for the API path p to the Vault server,
+// with body encoded as JSON if it isn't nil, and decodes the response into
+// resp.
+func (f *Fetcher) vaultRequest(ctx context.Context, method, p string, header http.Header, body interface{}, resp *vaultResponse) error {
+	if f.client == nil {
+		if err := f.newHttpClient(); err != nil {
+			return err
+		}
+	}
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	u := strings.TrimSuffix(*f.Vault.Address, "/") + "/v1/" + p
+	reader, status, cancel, err := f.client.withContext(ctx).doWithHeader(method, u, header, data)
+	if cancel != nil {
+		defer cancel()
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(resp); err != nil && status == http.StatusOK {
+		return err
+	}
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("vault returned %q: %s", http.StatusText(status), strings.Join(resp.Errors, "; "))
+	}
+}
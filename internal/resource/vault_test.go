@@ -0,0 +1,131 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func vaultServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/v1/auth/approle/login" {
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["role_id"] != "role" || body["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors": ["invalid role or secret ID"]}`))
+				return
+			}
+			w.Write([]byte(`{"auth": {"client_token": "approle-token"}}`))
+			return
+		}
+		if token := r.Header.Get("X-Vault-Token"); token != "token" && token != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/tls":
+			w.Write([]byte(`{"data": {"data": {"cert": "PEM"}, "metadata": {"version": 1}}}`))
+		case "/v1/kv/db":
+			w.Write([]byte(`{"data": {"password": "hunter2", "port": 5432}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+}
+
+func TestFetchFromVault(t *testing.T) {
+	server := vaultServer()
+	defer server.Close()
+	logger := log.New(true)
+	defer logger.Close()
+
+	tests := []struct {
+		vault types.Vault
+		url   string
+		out   string
+		err   error
+	}{
+		{
+			vault: types.Vault{Address: &server.URL, Token: util.StrToPtr("token")},
+			url:   "vault://secret/data/tls#cert",
+			out:   "PEM",
+		},
+		{
+			vault: types.Vault{Address: &server.URL, Token: util.StrToPtr("token")},
+			url:   "vault://kv/db#port",
+			out:   "5432",
+		},
+		{
+			vault: types.Vault{
+				Address: &server.URL,
+				AppRole: types.VaultAppRole{RoleID: util.StrToPtr("role"), SecretID: util.StrToPtr("secret")},
+			},
+			url: "vault://kv/db#password",
+			out: "hunter2",
+		},
+		{
+			vault: types.Vault{Address: &server.URL, Token: util.StrToPtr("token")},
+			url:   "vault://kv/db#user",
+			err:   ErrVaultFieldNotFound,
+		},
+		{
+			vault: types.Vault{Address: &server.URL, Token: util.StrToPtr("token")},
+			url:   "vault://kv/missing#password",
+			err:   ErrNotFound,
+		},
+		{
+			url: "vault://kv/db#password",
+			err: ErrVaultNotConfigured,
+		},
+	}
+
+	for i, test := range tests {
+		f := Fetcher{Logger: &logger, Vault: test.vault}
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatalf("#%d: bad url: %v", i, err)
+		}
+		out, err := f.FetchToBuffer(*u, FetchOptions{})
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: bad contents: want %q, got %q", i, test.out, string(out))
+		}
+	}
+
+	// bad credentials are reported with Vault's explanation
+	f := Fetcher{
+		Logger: &logger,
+		Vault: types.Vault{
+			Address: &server.URL,
+			AppRole: types.VaultAppRole{RoleID: util.StrToPtr("role"), SecretID: util.StrToPtr("wrong")},
+		},
+	}
+	if _, err := f.FetchToBuffer(url.URL{Scheme: "vault", Host: "kv", Path: "/db", Fragment: "password"}, FetchOptions{}); err == nil {
+		t.Errorf("login with bad credentials succeeded")
+	}
+}
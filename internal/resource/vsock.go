@@ -0,0 +1,101 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// FetchFromVsock fetches path via HTTP from the server listening on port of
+// the VM socket context cid, e.g. an agent on the host, and returns its
+// contents. The request is retried like those of http URLs.
+func (f *Fetcher) FetchFromVsock(cid, port uint32, path string, opts FetchOptions) ([]byte, error) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   "vsock",
+		Path:   path,
+	}
+	dial := func(context.Context) (net.Conn, error) {
+		return dialVsock(cid, port)
+	}
+	r := f.Memory.reserve()
+	defer r.release()
+	dest := &budgetedBuffer{r: r}
+	err := f.fetchOverHTTP(context.Background(), u, dial, nil, dest, opts)
+	return dest.Bytes(), err
+}
+
+// dialVsock connects to port of the VM socket context cid. The net package
+// can't wrap AF_VSOCK sockets, so the connection is a file with addresses.
+func dialVsock(cid, port uint32) (net.Conn, error) {
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := unix.Connect(fd, &unix.SockaddrVM{CID: cid, Port: port}); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("connect", err)
+	}
+	// a non-blocking file uses the runtime's poller, so closing it
+	// interrupts reads when the request is cancelled
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+	local := vsockAddr{}
+	if sa, err := unix.Getsockname(fd); err == nil {
+		if vm, ok := sa.(*unix.SockaddrVM); ok {
+			local = vsockAddr{vm.CID, vm.Port}
+		}
+	}
+	return vsockConn{
+		File:   os.NewFile(uintptr(fd), fmt.Sprintf("vsock:%d:%d", cid, port)),
+		local:  local,
+		remote: vsockAddr{cid, port},
+	}, nil
+}
+
+type vsockAddr struct {
+	cid  uint32
+	port uint32
+}
+
+func (a vsockAddr) Network() string {
+	return "vsock"
+}
+
+func (a vsockAddr) String() string {
+	return fmt.Sprintf("%d:%d", a.cid, a.port)
+}
+
+type vsockConn struct {
+	*os.File
+	local  vsockAddr
+	remote vsockAddr
+}
+
+func (c vsockConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c vsockConn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -0,0 +1,250 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rollback saves the paths the files stage is about to modify so
+// that the changes can be undone later with `ignition rollback`.
+package rollback
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/coreos/ignition/v2/internal/exec/util"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+const manifestName = "manifest.json"
+
+type action string
+
+const (
+	// actionCreated entries didn't exist and are removed on rollback.
+	actionCreated action = "created"
+	// actionSaved entries existed and are restored from their copy.
+	actionSaved action = "saved"
+)
+
+type entry struct {
+	// Path is relative to the root.
+	Path   string `json:"path"`
+	Action action `json:"action"`
+	// Copy is the saved copy, relative to the snapshot directory.
+	Copy string `json:"copy,omitempty"`
+}
+
+type manifest struct {
+	Entries []entry `json:"entries"`
+}
+
+// Snapshot records the state of paths under a root before they are
+// modified.
+type Snapshot struct {
+	dir      string
+	root     string
+	manifest manifest
+	seen     map[string]struct{}
+}
+
+// New creates an empty snapshot in dir of paths under root, replacing any
+// previous snapshot.
+func New(dir, root string) (*Snapshot, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &Snapshot{
+		dir:  dir,
+		root: root,
+		seen: map[string]struct{}{},
+	}
+	return s, s.writeManifest()
+}
+
+// Save records the state of path, an absolute path under the snapshot's
+// root, before it's modified. An existing node is copied into the
+// snapshot, recursively for directories. Otherwise the first missing
+// component of path is recorded so rollback can remove it. Only the first
+// call for a path has any effect.
+func (s *Snapshot) Save(path string) error {
+	rel, err := s.relative(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.seen[rel]; ok {
+		return nil
+	}
+	s.seen[rel] = struct{}{}
+
+	e := entry{Path: rel}
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		missing, err := util.FindFirstMissingDirForFile(path)
+		if err != nil {
+			return err
+		}
+		if e.Path, err = s.relative(missing); err != nil {
+			return err
+		}
+		e.Action = actionCreated
+	} else if err != nil {
+		return err
+	} else {
+		e.Action = actionSaved
+		e.Copy = filepath.Join("saved", strconv.Itoa(len(s.manifest.Entries)))
+		if err := os.MkdirAll(filepath.Join(s.dir, "saved"), 0700); err != nil {
+			return err
+		}
+		if err := copyNode(path, filepath.Join(s.dir, e.Copy)); err != nil {
+			return fmt.Errorf("saving %q: %v", path, err)
+		}
+	}
+
+	s.manifest.Entries = append(s.manifest.Entries, e)
+	// keep the manifest current in case the stage fails partway through
+	return s.writeManifest()
+}
+
+func (s *Snapshot) relative(path string) (string, error) {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%q is not under %q", path, s.root)
+	}
+	return rel, nil
+}
+
+func (s *Snapshot) writeManifest() error {
+	data, err := json.Marshal(s.manifest)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.dir, manifestName), data, 0600)
+}
+
+// Restore undoes the changes recorded by the snapshot in dir to the paths
+// under root, most recent first, then removes the snapshot.
+func Restore(logger *log.Logger, dir, root string) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, manifestName))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no snapshot found in %q", dir)
+	} else if err != nil {
+		return err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("parsing snapshot manifest: %v", err)
+	}
+
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		e := m.Entries[i]
+		path := filepath.Join(root, e.Path)
+		switch e.Action {
+		case actionCreated:
+			if err := logger.LogOp(
+				func() error {
+					return os.RemoveAll(path)
+				}, "removing %q", path,
+			); err != nil {
+				return err
+			}
+		case actionSaved:
+			if err := logger.LogOp(
+				func() error {
+					if err := os.RemoveAll(path); err != nil {
+						return err
+					}
+					return copyNode(filepath.Join(dir, e.Copy), path)
+				}, "restoring %q", path,
+			); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown snapshot action %q for %q", e.Action, e.Path)
+		}
+	}
+	return os.RemoveAll(dir)
+}
+
+// copyNode recursively copies the node at src to dst, preserving ownership
+// and permissions. Symlinks are copied, not followed.
+func copyNode(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+		case info.IsDir():
+			if err := os.Mkdir(target, 0700); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := copyFile(path, target); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("cannot copy %q: unsupported file type", path)
+		}
+
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			if err := os.Lchown(target, int(st.Uid), int(st.Gid)); err != nil {
+				return err
+			}
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return os.Chmod(target, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rollback
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-rollback-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+	root := filepath.Join(td, "root")
+	dir := filepath.Join(td, "snapshot")
+
+	write := func(path, contents string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir error: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0640); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+	file := filepath.Join(root, "etc", "file")
+	link := filepath.Join(root, "etc", "link")
+	tree := filepath.Join(root, "etc", "tree")
+	created := filepath.Join(root, "etc", "new", "sub", "file")
+	write(file, "original")
+	write(filepath.Join(tree, "a"), "a")
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatalf("symlink error: %v", err)
+	}
+
+	s, err := New(dir, root)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	for _, p := range []string{file, link, tree, created} {
+		if err := s.Save(p); err != nil {
+			t.Fatalf("failed to save %q: %v", p, err)
+		}
+	}
+	// saving again must not overwrite the original copy
+	write(file, "modified")
+	if err := s.Save(file); err != nil {
+		t.Fatalf("failed to save %q again: %v", file, err)
+	}
+	if err := os.Remove(link); err != nil {
+		t.Fatalf("remove error: %v", err)
+	}
+	if err := os.RemoveAll(tree); err != nil {
+		t.Fatalf("remove error: %v", err)
+	}
+	write(tree, "not a directory")
+	write(created, "new")
+
+	logger := log.New(true)
+	defer logger.Close()
+	if err := Restore(&logger, dir, root); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	if contents, err := ioutil.ReadFile(file); err != nil || string(contents) != "original" {
+		t.Errorf("file wasn't restored: %q, %v", string(contents), err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("file mode wasn't restored: %v, %v", info.Mode(), err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != "file" {
+		t.Errorf("link wasn't restored: %q, %v", target, err)
+	}
+	if contents, err := ioutil.ReadFile(filepath.Join(tree, "a")); err != nil || string(contents) != "a" {
+		t.Errorf("tree wasn't restored: %q, %v", string(contents), err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "etc", "new")); !os.IsNotExist(err) {
+		t.Errorf("created directory wasn't removed: %v", err)
+	}
+	if _, err := os.Lstat(dir); !os.IsNotExist(err) {
+		t.Errorf("snapshot wasn't removed: %v", err)
+	}
+}
+
+func TestSaveOutsideRoot(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-rollback-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	s, err := New(filepath.Join(td, "snapshot"), filepath.Join(td, "root"))
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	if err := s.Save(filepath.Join(td, "elsewhere")); err == nil {
+		t.Errorf("saving a path outside the root succeeded")
+	}
+}
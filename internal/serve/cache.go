@@ -0,0 +1,290 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+)
+
+// CachePath is the path under which the cache serves objects.
+const CachePath = resource.CacheObjectsPath
+
+var (
+	// fillWait is how long a request for an object which isn't cached
+	// yet waits for it to be fetched, well within machines' default
+	// response header timeout, before it's asked to retry.
+	fillWait = 2 * time.Second
+	// fillRetryAfter is the delay machines are asked to wait before
+	// retrying while an object is being fetched.
+	fillRetryAfter = 5 * time.Second
+	// fillErrorTTL is how long the failure to fetch an object is
+	// remembered, so the machines waiting for it learn of it rather than
+	// fetching it again.
+	fillErrorTTL = time.Minute
+)
+
+// Cache is a pull-through cache of verified objects, which spares the
+// server holding large files from fetches by many machines at once. A
+// machine asks for an object by its sha512 sum, at the path returned by
+// resource.CacheObjectPath, with its source URL in the source query
+// parameter. Objects are fetched from their source once, however many
+// machines ask for them meanwhile, verified, and kept in Dir. Machines
+// asking for an object while it's being fetched are answered with 503
+// Service Unavailable and Retry-After, rather than kept waiting past their
+// response header timeout. An object is
+// only ever served if it has the sum asked for. Fetcher must have its HTTP
+// client set up, e.g. with UpdateHttpTimeoutsAndCAs.
+type Cache struct {
+	Dir     string
+	Logger  *log.Logger
+	Fetcher *resource.Fetcher
+	// Sources are the host names of the http, https, and tftp sources and
+	// the buckets of the s3 sources which objects may be fetched from.
+	// Requests naming other sources are refused, so that machines can't
+	// use the cache to reach other hosts on the server's network.
+	Sources []string
+	// MaxObjectSize, if not zero, bounds the size of each object.
+	MaxObjectSize int64
+	// MaxSize, if not zero, bounds the total size of the objects in Dir.
+	// The objects served least recently are removed to make room.
+	MaxSize int64
+
+	mu sync.Mutex
+	// fills are the objects being fetched, by name
+	fills map[string]*fill
+	// evictMu serializes the removal of objects to stay under MaxSize
+	evictMu sync.Mutex
+}
+
+// fill is the fetch of an object, which is done once err is set and done
+// is closed.
+type fill struct {
+	done chan struct{}
+	err  error
+}
+
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sum, ok := resource.ParseCacheObjectPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	name := "sha512-" + hex.EncodeToString(sum)
+	path := filepath.Join(c.Dir, name)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		source, err := url.Parse(r.URL.Query().Get("source"))
+		if err != nil || !resource.CacheableScheme(source.Scheme) {
+			http.Error(w, "missing or unsupported source", http.StatusBadRequest)
+			return
+		}
+		if !c.allowed(*source) {
+			c.Logger.Warning("refusing to fetch %s for %s: source not allowed", source.String(), r.RemoteAddr)
+			http.Error(w, "source not allowed", http.StatusForbidden)
+			return
+		}
+		fl := c.fill(name, *source, sum)
+		select {
+		case <-fl.done:
+		case <-time.After(fillWait):
+			w.Header().Set("Retry-After", strconv.Itoa(int(fillRetryAfter/time.Second)))
+			http.Error(w, "the object is being fetched", http.StatusServiceUnavailable)
+			return
+		}
+		if fl.err != nil {
+			// not a server error, which machines would retry, since
+			// they can fetch the object from its source themselves
+			c.Logger.Err("fetching %s for %s: %v", source.String(), r.RemoteAddr, fl.err)
+			http.Error(w, "fetching the object failed", http.StatusNotFound)
+			return
+		}
+	} else if err != nil {
+		c.Logger.Err("reading %s: %v", path, err)
+		http.Error(w, "reading the object failed", http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		// removed to make room for another since it was fetched
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		c.Logger.Err("reading %s: %v", path, err)
+		http.Error(w, "reading the object failed", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	// the modification time records when the object was last served, so
+	// the objects served least recently are removed first
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		c.Logger.Warning("marking %s as used: %v", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		c.Logger.Err("reading %s: %v", path, err)
+		http.Error(w, "reading the object failed", http.StatusInternalServerError)
+		return
+	}
+	c.Logger.Info("serving %s to %s", name, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// fill starts fetching the object named name from source into Dir, unless
+// it's already being fetched or has just failed to be, and returns that
+// fetch.
+func (c *Cache) fill(name string, source url.URL, sum []byte) *fill {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fills == nil {
+		c.fills = map[string]*fill{}
+	}
+	if fl, ok := c.fills[name]; ok {
+		return fl
+	}
+	fl := &fill{done: make(chan struct{})}
+	// the fetch may have finished since the caller looked for the object
+	if _, err := os.Stat(filepath.Join(c.Dir, name)); err == nil {
+		close(fl.done)
+		return fl
+	}
+	c.fills[name] = fl
+	go func() {
+		fl.err = c.fetch(name, source, sum)
+		close(fl.done)
+		forget := func() {
+			c.mu.Lock()
+			delete(c.fills, name)
+			c.mu.Unlock()
+		}
+		if fl.err != nil {
+			time.AfterFunc(fillErrorTTL, forget)
+		} else {
+			forget()
+		}
+	}()
+	return fl
+}
+
+// fetch fetches the object named name from source into Dir, if it has the
+// sha512 sum sum. The fetch isn't cancelled with the request which started
+// it, since others may be waiting for it. It uses a copy of c.Fetcher, since
+// fetchers set themselves up as they fetch and others may run at once.
+func (c *Cache) fetch(name string, source url.URL, sum []byte) error {
+	fetcher := *c.Fetcher
+	c.Logger.Info("fetching %s from %s", name, source.String())
+	tmp, err := ioutil.TempFile(c.Dir, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := fetcher.Fetch(context.Background(), source, tmp, resource.FetchOptions{
+		Hash:        sha512.New(),
+		ExpectedSum: sum,
+		MaxSize:     c.MaxObjectSize,
+	}); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.Dir, name)); err != nil {
+		return err
+	}
+	// the object is cached either way, so failing to make room for it
+	// shouldn't fail the machines waiting for it
+	if err := c.evict(name); err != nil {
+		c.Logger.Err("making room for %s: %v", name, err)
+	}
+	return nil
+}
+
+// allowed returns whether objects may be fetched from source.
+func (c *Cache) allowed(source url.URL) bool {
+	for _, s := range c.Sources {
+		if strings.EqualFold(s, source.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// evict removes the objects served least recently, other than the object
+// named keep, until the objects in Dir take up at most MaxSize.
+func (c *Cache) evict(keep string) error {
+	if c.MaxSize == 0 {
+		return nil
+	}
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+
+	infos, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	var objects []os.FileInfo
+	var total int64
+	for _, info := range infos {
+		// skip the objects still being fetched
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		objects = append(objects, info)
+		total += info.Size()
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ModTime().Before(objects[j].ModTime())
+	})
+	for _, info := range objects {
+		if total <= c.MaxSize {
+			break
+		}
+		if info.Name() == keep {
+			continue
+		}
+		c.Logger.Info("removing %s to make room in the cache", info.Name())
+		if err := os.Remove(filepath.Join(c.Dir, info.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= info.Size()
+	}
+	return nil
+}
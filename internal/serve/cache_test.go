@@ -0,0 +1,310 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cutil "github.com/coreos/ignition/v2/config/util"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+	"github.com/coreos/ignition/v2/internal/resource"
+	"github.com/coreos/ignition/v2/internal/util"
+)
+
+func TestCache(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-cache-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	var hits int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		// give the machines time to ask for the object together
+		time.Sleep(100 * time.Millisecond)
+		if r.URL.Path == "/stale.raw" {
+			fmt.Fprint(w, "stale")
+		} else {
+			fmt.Fprint(w, "image")
+		}
+	}))
+	defer origin.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	newFetcher := func() resource.Fetcher {
+		f := resource.Fetcher{Logger: &logger}
+		if err := f.UpdateHttpTimeoutsAndCAs(types.Timeouts{}, nil, types.Proxy{}); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	cacheFetcher := newFetcher()
+	cache := httptest.NewServer(&Cache{Dir: td, Logger: &logger, Fetcher: &cacheFetcher, Sources: []string{"127.0.0.1"}})
+	defer cache.Close()
+	cacheURL, _ := url.Parse(cache.URL)
+
+	fetch := func(source, contents string) (string, error) {
+		f := newFetcher()
+		f.Caches = []url.URL{*cacheURL}
+		u, _ := url.Parse(origin.URL + source)
+		sum := sha512.Sum512([]byte(contents))
+		dest, err := ioutil.TempFile("", "ign-cache-test")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(dest.Name())
+		defer dest.Close()
+		if err := f.Fetch(context.Background(), *u, dest, resource.FetchOptions{Hash: sha512.New(), ExpectedSum: sum[:]}); err != nil {
+			return "", err
+		}
+		out, err := ioutil.ReadFile(dest.Name())
+		return string(out), err
+	}
+
+	// machines asking at once share one fetch from the source
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := fetch("/image.raw", "image")
+			if err == nil && out != "image" {
+				err = fmt.Errorf("fetched %q", out)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("fetch through the cache failed: %v", err)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("expected the source to be fetched once, got %d", hits)
+	}
+	// and later ones are served from the cache
+	if _, err := fetch("/image.raw", "image"); err != nil || hits != 1 {
+		t.Errorf("expected the object to be served from the cache, got %d fetches, %v", hits, err)
+	}
+
+	// an object which fails verification isn't cached, and the machine
+	// fetches it from the source itself
+	if _, err := fetch("/stale.raw", "fresh"); err == nil {
+		t.Error("fetched an object which failed verification")
+	} else if _, ok := err.(util.ErrHashMismatch); !ok {
+		t.Errorf("expected a hash mismatch, got %v", err)
+	}
+	if hits != 3 {
+		t.Errorf("expected the cache and the machine to fetch the source, got %d fetches", hits-1)
+	}
+
+	// the cache only serves the objects it's asked for by sum
+	for _, path := range []string{"/objects/sha512-00", "/objects/sha512-" + strings.Repeat("A", 128), "/config.ign"} {
+		resp, err := http.Get(cache.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusNotFound, resp.StatusCode)
+		}
+	}
+}
+
+func TestCacheLimits(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-cache-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	var hits int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		fmt.Fprint(w, strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	defer origin.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	fetcher := resource.Fetcher{Logger: &logger}
+	if err := fetcher.UpdateHttpTimeoutsAndCAs(types.Timeouts{}, nil, types.Proxy{}); err != nil {
+		t.Fatal(err)
+	}
+	cache := httptest.NewServer(&Cache{
+		Dir:           td,
+		Logger:        &logger,
+		Fetcher:       &fetcher,
+		Sources:       []string{"127.0.0.1"},
+		MaxObjectSize: 8,
+		MaxSize:       12,
+	})
+	defer cache.Close()
+
+	get := func(source, contents string) int {
+		sum := sha512.Sum512([]byte(contents))
+		u := cache.URL + resource.CacheObjectPath(sum[:]) + "?source=" + url.QueryEscape(source)
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	cached := func(contents string) bool {
+		sum := sha512.Sum512([]byte(contents))
+		_, err := os.Stat(filepath.Join(td, "sha512-"+hex.EncodeToString(sum[:])))
+		return err == nil
+	}
+
+	tests := []struct {
+		source   string
+		contents string
+		status   int
+		fetched  bool
+	}{
+		// sources which aren't allowed are never fetched
+		{"http://169.254.169.254/secret", "secret", http.StatusForbidden, false},
+		{"s3://other-bucket/secret", "secret", http.StatusForbidden, false},
+		// objects larger than MaxObjectSize aren't cached
+		{origin.URL + "/oversized", "oversized", http.StatusNotFound, true},
+		{origin.URL + "/first", "first", http.StatusOK, true},
+		{origin.URL + "/second", "second", http.StatusOK, true},
+		// the first object was served least recently, so it makes room
+		{origin.URL + "/third", "third", http.StatusOK, true},
+	}
+	for i, test := range tests {
+		before := atomic.LoadInt32(&hits)
+		if status := get(test.source, test.contents); status != test.status {
+			t.Errorf("#%d: expected status %d, got %d", i, test.status, status)
+		}
+		if fetched := atomic.LoadInt32(&hits) != before; fetched != test.fetched {
+			t.Errorf("#%d: expected fetched %v, got %v", i, test.fetched, fetched)
+		}
+	}
+
+	for _, contents := range []string{"oversized", "first"} {
+		if cached(contents) {
+			t.Errorf("expected %q not to be cached", contents)
+		}
+	}
+	for _, contents := range []string{"second", "third"} {
+		if !cached(contents) {
+			t.Errorf("expected %q to be cached", contents)
+		}
+	}
+}
+
+func TestCacheSlowSource(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-cache-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	defer func(wait, retryAfter time.Duration) {
+		fillWait, fillRetryAfter = wait, retryAfter
+	}(fillWait, fillRetryAfter)
+	fillWait = 100 * time.Millisecond
+	fillRetryAfter = time.Second
+
+	var hits int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		// slower than the machines' response header timeout
+		time.Sleep(1500 * time.Millisecond)
+		fmt.Fprint(w, "image")
+	}))
+	defer origin.Close()
+
+	logger := log.New(true)
+	defer logger.Close()
+	cacheFetcher := resource.Fetcher{Logger: &logger}
+	if err := cacheFetcher.UpdateHttpTimeoutsAndCAs(types.Timeouts{}, nil, types.Proxy{}); err != nil {
+		t.Fatal(err)
+	}
+	cache := httptest.NewServer(&Cache{Dir: td, Logger: &logger, Fetcher: &cacheFetcher, Sources: []string{"127.0.0.1"}})
+	defer cache.Close()
+	cacheURL, _ := url.Parse(cache.URL)
+
+	sum := sha512.Sum512([]byte("image"))
+	fetch := func() error {
+		f := resource.Fetcher{Logger: &logger, Caches: []url.URL{*cacheURL}}
+		if err := f.UpdateHttpTimeoutsAndCAs(types.Timeouts{HTTPResponseHeaders: cutil.IntToPtr(1)}, nil, types.Proxy{}); err != nil {
+			return err
+		}
+		u, _ := url.Parse(origin.URL + "/image.raw")
+		dest, err := ioutil.TempFile("", "ign-cache-test")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(dest.Name())
+		defer dest.Close()
+		return f.Fetch(context.Background(), *u, dest, resource.FetchOptions{Hash: sha512.New(), ExpectedSum: sum[:]})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- fetch()
+		}()
+	}
+
+	// while the object is being fetched, the cache asks machines to retry
+	time.Sleep(500 * time.Millisecond)
+	resp, err := http.Get(cache.URL + resource.CacheObjectPath(sum[:]) + "?source=" + url.QueryEscape(origin.URL+"/image.raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("expected 503 with Retry-After 1, got %d with %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("fetch through the cache failed: %v", err)
+		}
+	}
+	// the machines waited for the cache rather than going to the source
+	if hits != 1 {
+		t.Errorf("expected the source to be fetched once, got %d", hits)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package serve implements a small provisioning server for bare metal, which
+// serves each machine the config assembled from the config files in a
+// directory which match it.
+package serve
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ign "github.com/coreos/ignition/v2/config"
+	latest "github.com/coreos/ignition/v2/config/v3_1_experimental"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+// ConfigPath is the path at which machines fetch their config.
+const ConfigPath = "/config.ign"
+
+// Server serves the configs in Dir. default.ign applies to every machine,
+// and configs in the ip, mac, and serial subdirectories, named after the
+// address, MAC address, or serial number of a machine with an .ign suffix,
+// apply to that machine. The configs which match a machine are merged in
+// that order, so the most specific wins. Configs are read on each request,
+// so changes take effect immediately.
+type Server struct {
+	Dir    string
+	Logger *log.Logger
+}
+
+func (s Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != ConfigPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg, matched, err := s.assemble(s.matches(r))
+	if err != nil {
+		s.Logger.Err("assembling config for %s: %v", r.RemoteAddr, err)
+		http.Error(w, "invalid config", http.StatusInternalServerError)
+		return
+	}
+	if len(matched) == 0 {
+		s.Logger.Info("no config for %s", r.RemoteAddr)
+		http.NotFound(w, r)
+		return
+	}
+	out, err := json.Marshal(cfg)
+	if err != nil {
+		s.Logger.Err("marshaling config for %s: %v", r.RemoteAddr, err)
+		http.Error(w, "invalid config", http.StatusInternalServerError)
+		return
+	}
+	s.Logger.Info("serving %s the config from %s", r.RemoteAddr, strings.Join(matched, ", "))
+	w.Header().Set("Content-Type", "application/vnd.coreos.ignition+json")
+	w.Write(out)
+}
+
+// matches returns the paths, relative to s.Dir, of the configs which may
+// match the machine making r, in the order they're merged. The MAC address
+// and serial number are taken from the mac and serial query parameters,
+// e.g. from an iPXE script's ${net0/mac}, or the serial number from the
+// X-Ignition-System-Serial header Ignition can send.
+func (s Server) matches(r *http.Request) []string {
+	paths := []string{"default.ign"}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			paths = append(paths, filepath.Join("ip", ip.String()+".ign"))
+		}
+	}
+	if mac, err := net.ParseMAC(r.URL.Query().Get("mac")); err == nil {
+		paths = append(paths, filepath.Join("mac", strings.Replace(mac.String(), ":", "-", -1)+".ign"))
+	}
+	serial := r.URL.Query().Get("serial")
+	if serial == "" {
+		serial = r.Header.Get("X-Ignition-System-Serial")
+	}
+	// the serial number mustn't name a path outside the directory
+	if serial != "" && !strings.ContainsAny(serial, `/\`) && !strings.HasPrefix(serial, ".") {
+		paths = append(paths, filepath.Join("serial", serial+".ign"))
+	}
+	return paths
+}
+
+// assemble merges the configs at paths which exist, returning the merged
+// config and the paths which existed.
+func (s Server) assemble(paths []string) (types.Config, []string, error) {
+	var cfg types.Config
+	var matched []string
+	for _, path := range paths {
+		raw, err := ioutil.ReadFile(filepath.Join(s.Dir, path))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return types.Config{}, nil, err
+		}
+		child, rpt, err := ign.Parse(raw)
+		if err != nil {
+			s.Logger.Err("%s: %v\n%s", path, err, rpt.String())
+			return types.Config{}, nil, err
+		}
+		if matched == nil {
+			cfg = child
+		} else {
+			cfg = latest.Merge(cfg, child)
+		}
+		matched = append(matched, path)
+	}
+	return cfg, matched, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/log"
+)
+
+func TestServer(t *testing.T) {
+	td, err := ioutil.TempDir("", "ign-serve-test")
+	if err != nil {
+		t.Fatalf("tempdir error: %v", err)
+	}
+	defer os.RemoveAll(td)
+
+	configs := map[string]string{
+		"default.ign":                  `{"ignition": {"version": "3.0.0"}, "passwd": {"users": [{"name": "core"}]}}`,
+		"ip/192.0.2.10.ign":            `{"ignition": {"version": "3.0.0"}, "passwd": {"users": [{"name": "ip"}]}}`,
+		"mac/52-54-00-12-34-56.ign":    `{"ignition": {"version": "3.1.0-experimental"}, "passwd": {"users": [{"name": "mac"}]}}`,
+		"serial/ABC123.ign":            `{"ignition": {"version": "3.0.0"}, "passwd": {"users": [{"name": "serial"}]}}`,
+		"serial/broken.ign":            `{"ignition": {"version": "3.0.0"`,
+		"mac/52-54-00-00-00-01.ign.in": `{}`,
+	}
+	for path, config := range configs {
+		path = filepath.Join(td, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir error: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+
+	logger := log.New(true)
+	defer logger.Close()
+	server := Server{Dir: td, Logger: &logger}
+
+	tests := []struct {
+		remote string
+		url    string
+		serial string
+		status int
+		users  []string
+	}{
+		{"198.51.100.1:4000", "/config.ign", "", http.StatusOK, []string{"core"}},
+		{"192.0.2.10:4000", "/config.ign?mac=52:54:00:12:34:56", "", http.StatusOK, []string{"core", "ip", "mac"}},
+		{"198.51.100.1:4000", "/config.ign?mac=52-54-00-12-34-56", "ABC123", http.StatusOK, []string{"core", "mac", "serial"}},
+		{"198.51.100.1:4000", "/config.ign?serial=ABC123", "", http.StatusOK, []string{"core", "serial"}},
+		{"198.51.100.1:4000", "/config.ign?serial=broken", "", http.StatusInternalServerError, nil},
+		// serial numbers can't escape the directory
+		{"198.51.100.1:4000", "/config.ign?serial=../default", "", http.StatusOK, []string{"core"}},
+		{"198.51.100.1:4000", "/other", "", http.StatusNotFound, nil},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", test.url, nil)
+		req.RemoteAddr = test.remote
+		if test.serial != "" {
+			req.Header.Set("X-Ignition-System-Serial", test.serial)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("#%d: expected status %d, got %d", i, test.status, w.Code)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var cfg types.Config
+		if err := json.Unmarshal(w.Body.Bytes(), &cfg); err != nil {
+			t.Errorf("#%d: invalid config served: %v", i, err)
+			continue
+		}
+		var users []string
+		for _, u := range cfg.Passwd.Users {
+			users = append(users, u.Name)
+		}
+		if !reflect.DeepEqual(test.users, users) {
+			t.Errorf("#%d: expected users %v, got %v", i, test.users, users)
+		}
+	}
+
+	// without a default config, unknown machines get nothing
+	os.Remove(filepath.Join(td, "default.ign"))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/config.ign", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d without a matching config, got %d", http.StatusNotFound, w.Code)
+	}
+}
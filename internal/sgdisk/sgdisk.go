@@ -15,8 +15,8 @@
 package sgdisk
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os/exec"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
@@ -25,6 +25,7 @@ import (
 )
 
 type Operation struct {
+	ctx       context.Context
 	logger    *log.Logger
 	dev       string
 	wipe      bool
@@ -33,9 +34,9 @@ type Operation struct {
 	infos     []int
 }
 
-// Begin begins an sgdisk operation
-func Begin(logger *log.Logger, dev string) *Operation {
-	return &Operation{logger: logger, dev: dev}
+// Begin begins an sgdisk operation. sgdisk is killed if ctx is cancelled.
+func Begin(ctx context.Context, logger *log.Logger, dev string) *Operation {
+	return &Operation{ctx: ctx, logger: logger, dev: dev}
 }
 
 // CreatePartition adds the supplied partition to the list of partitions to be created as part of an operation.
@@ -67,31 +68,10 @@ func (op *Operation) Pretend() (string, error) {
 	opts = append(opts, op.buildOptions()...)
 	op.logger.Info("running sgdisk with options: %v", opts)
 
-	cmd := exec.Command(distro.SgdiskCmd(), opts...)
-	stdout, err := cmd.StdoutPipe()
+	cmd := exec.CommandContext(op.ctx, distro.SgdiskCmd(), opts...)
+	output, err := log.RunCmd(cmd)
 	if err != nil {
-		return "", err
-	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return "", err
-	}
-
-	if err := cmd.Start(); err != nil {
-		return "", err
-	}
-	output, err := ioutil.ReadAll(stdout)
-	if err != nil {
-		return "", err
-	}
-
-	errors, err := ioutil.ReadAll(stderr)
-	if err != nil {
-		return "", err
-	}
-
-	if err := cmd.Wait(); err != nil {
-		return "", fmt.Errorf("Failed to pretend to create partitions. Err: %v. Stderr: %v", err, string(errors))
+		return "", fmt.Errorf("Failed to pretend to create partitions: %v", err)
 	}
 
 	return string(output), nil
@@ -104,7 +84,7 @@ func (op *Operation) Commit() error {
 		return nil
 	}
 	op.logger.Info("running sgdisk with options: %v", opts)
-	cmd := exec.Command(distro.SgdiskCmd(), opts...)
+	cmd := exec.CommandContext(op.ctx, distro.SgdiskCmd(), opts...)
 	if _, err := op.logger.LogCmd(cmd, "deleting %d partitions and creating %d partitions on %q", len(op.deletions), len(op.parts), op.dev); err != nil {
 		return fmt.Errorf("create partitions failed: %v", err)
 	}
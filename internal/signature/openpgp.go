@@ -0,0 +1,382 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// OpenPGP packet tags, signature types, and algorithms, from RFC 4880.
+const (
+	pgpTagSignature    = 2
+	pgpTagPublicKey    = 6
+	pgpTagPublicSubkey = 14
+
+	pgpSigBinary = 0x00
+	pgpSigText   = 0x01
+
+	pgpRSA         = 1
+	pgpRSASignOnly = 3
+	pgpECDSA       = 19
+	pgpEdDSA       = 22
+)
+
+// pgpCurves are the curves of the ECDSA keys accepted, by OID.
+var pgpCurves = map[string]elliptic.Curve{
+	"\x2a\x86\x48\xce\x3d\x03\x01\x07": elliptic.P256(),
+	"\x2b\x81\x04\x00\x22":             elliptic.P384(),
+	"\x2b\x81\x04\x00\x23":             elliptic.P521(),
+}
+
+// pgpOIDEd25519 is the OID of the only curve accepted for EdDSA keys.
+const pgpOIDEd25519 = "\x2b\x06\x01\x04\x01\xda\x47\x0f\x01"
+
+// pgpEd25519Key is an Ed25519 public key from an OpenPGP key block. It's
+// kept as bytes, since crypto/ed25519 needs Go 1.13.
+type pgpEd25519Key []byte
+
+// pgpHashes are the hash algorithms accepted in OpenPGP signatures. MD5 and
+// SHA-1 aren't.
+var pgpHashes = map[byte]crypto.Hash{
+	8:  crypto.SHA256,
+	9:  crypto.SHA384,
+	10: crypto.SHA512,
+	11: crypto.SHA224,
+}
+
+// pgpSignature is a version 4 OpenPGP signature, as made by `gpg
+// --detach-sign`.
+type pgpSignature struct {
+	sigType byte
+	pubAlgo byte
+	hash    crypto.Hash
+	// hashed is the start of the packet, up to the end of the hashed
+	// subpackets, which the signature covers along with the data
+	hashed []byte
+	left16 []byte
+	mpis   [][]byte
+}
+
+// parsePGPSignature parses sig as a binary or ASCII-armored OpenPGP
+// signature. It returns false if sig isn't one, and an error if it is one
+// which can't be verified.
+func parsePGPSignature(sig []byte) (pgpSignature, bool, error) {
+	if armored, ok := dearmor(sig, "PGP SIGNATURE"); ok {
+		sig = armored
+	}
+	tag, body, ok := pgpPacket(sig)
+	if !ok || tag != pgpTagSignature || len(body) < 6 || body[0] != 4 {
+		return pgpSignature{}, false, nil
+	}
+	s := pgpSignature{
+		sigType: body[1],
+		pubAlgo: body[2],
+	}
+	switch s.pubAlgo {
+	case pgpRSA, pgpRSASignOnly, pgpECDSA:
+	case pgpEdDSA:
+		if !ed25519Supported {
+			return pgpSignature{}, true, errors.New("EdDSA signatures need Ignition to be built with Go 1.13 or later")
+		}
+	default:
+		return pgpSignature{}, true, fmt.Errorf("unsupported OpenPGP public-key algorithm %d", s.pubAlgo)
+	}
+	if s.hash, ok = pgpHashes[body[3]]; !ok {
+		return pgpSignature{}, true, fmt.Errorf("unsupported OpenPGP hash algorithm %d", body[3])
+	}
+	if s.sigType != pgpSigBinary && s.sigType != pgpSigText {
+		return pgpSignature{}, true, fmt.Errorf("unsupported OpenPGP signature type %#x", s.sigType)
+	}
+
+	n := 6 + int(binary.BigEndian.Uint16(body[4:6]))
+	if len(body) < n+2 {
+		return pgpSignature{}, false, nil
+	}
+	s.hashed = body[:n]
+	n += 2 + int(binary.BigEndian.Uint16(body[n:n+2]))
+	if len(body) < n+2 {
+		return pgpSignature{}, false, nil
+	}
+	s.left16 = body[n : n+2]
+	rest := body[n+2:]
+	for len(rest) > 0 {
+		var mpi []byte
+		if mpi, rest, ok = pgpMPI(rest); !ok {
+			return pgpSignature{}, false, nil
+		}
+		s.mpis = append(s.mpis, mpi)
+	}
+	return s, true, nil
+}
+
+// verify checks that s is a signature of data by pub.
+func (s pgpSignature) verify(pub crypto.PublicKey, data []byte) bool {
+	if s.sigType == pgpSigText {
+		// text signatures cover the data with CRLF line endings
+		data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+		data = bytes.Replace(data, []byte("\n"), []byte("\r\n"), -1)
+	}
+	h := s.hash.New()
+	h.Write(data)
+	h.Write(s.hashed)
+	trailer := []byte{4, 0xff, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(trailer[2:], uint32(len(s.hashed)))
+	h.Write(trailer)
+	digest := h.Sum(nil)
+	if !bytes.Equal(digest[:2], s.left16) {
+		return false
+	}
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if (s.pubAlgo != pgpRSA && s.pubAlgo != pgpRSASignOnly) || len(s.mpis) != 1 || len(s.mpis[0]) > pub.Size() {
+			return false
+		}
+		// MPIs drop leading zeros, which PKCS #1 v1.5 signatures keep
+		sig := make([]byte, pub.Size())
+		copy(sig[len(sig)-len(s.mpis[0]):], s.mpis[0])
+		return rsa.VerifyPKCS1v15(pub, s.hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		if s.pubAlgo != pgpECDSA || len(s.mpis) != 2 {
+			return false
+		}
+		r := new(big.Int).SetBytes(s.mpis[0])
+		ss := new(big.Int).SetBytes(s.mpis[1])
+		return ecdsa.Verify(pub, digest, r, ss)
+	case pgpEd25519Key:
+		if s.pubAlgo != pgpEdDSA || len(s.mpis) != 2 || len(s.mpis[0]) > 32 || len(s.mpis[1]) > 32 {
+			return false
+		}
+		// R and S are MPIs too, so they drop leading zeros
+		sig := make([]byte, 64)
+		copy(sig[32-len(s.mpis[0]):32], s.mpis[0])
+		copy(sig[64-len(s.mpis[1]):], s.mpis[1])
+		return verifyEd25519(pub, digest, sig)
+	}
+	return false
+}
+
+// parsePGPKeys returns the primary key and subkeys of the binary OpenPGP
+// public key block data, as exported by `gpg --export`. Keys which can't
+// sign, such as ECDH subkeys, are skipped, and so are the user IDs and the
+// signatures binding the subkeys to the primary key, since the whole block
+// is trusted.
+func parsePGPKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for len(data) > 0 {
+		tag, body, rest, ok := nextPGPPacket(data)
+		if !ok {
+			return nil, errors.New("malformed OpenPGP public key block")
+		}
+		data = rest
+		if tag != pgpTagPublicKey && tag != pgpTagPublicSubkey {
+			continue
+		}
+		pub, err := parsePGPKey(body)
+		if err != nil {
+			return nil, err
+		}
+		if pub != nil {
+			keys = append(keys, pub)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA, ECDSA, or EdDSA keys found in OpenPGP public key block")
+	}
+	return keys, nil
+}
+
+// parsePGPKey parses the body of a version 4 OpenPGP public key or subkey
+// packet. It returns nil if the key's algorithm can't sign.
+func parsePGPKey(body []byte) (crypto.PublicKey, error) {
+	if len(body) < 6 || body[0] != 4 {
+		return nil, errors.New("unsupported OpenPGP public key version")
+	}
+	algo, rest := body[5], body[6:]
+	switch algo {
+	case pgpRSA, pgpRSASignOnly:
+		n, rest, ok := pgpMPI(rest)
+		if !ok {
+			return nil, errors.New("malformed OpenPGP RSA key")
+		}
+		e, _, ok := pgpMPI(rest)
+		if !ok || len(e) > 4 {
+			return nil, errors.New("malformed OpenPGP RSA key")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case pgpECDSA:
+		oid, rest, ok := pgpOID(rest)
+		if !ok {
+			return nil, errors.New("malformed OpenPGP ECDSA key")
+		}
+		curve, ok := pgpCurves[string(oid)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported OpenPGP ECDSA curve %x", oid)
+		}
+		point, _, ok := pgpMPI(rest)
+		if !ok {
+			return nil, errors.New("malformed OpenPGP ECDSA key")
+		}
+		x, y := elliptic.Unmarshal(curve, point)
+		if x == nil {
+			return nil, errors.New("malformed OpenPGP ECDSA key")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case pgpEdDSA:
+		oid, rest, ok := pgpOID(rest)
+		if !ok {
+			return nil, errors.New("malformed OpenPGP EdDSA key")
+		}
+		if string(oid) != pgpOIDEd25519 {
+			return nil, fmt.Errorf("unsupported OpenPGP EdDSA curve %x", oid)
+		}
+		if !ed25519Supported {
+			return nil, errors.New("EdDSA keys need Ignition to be built with Go 1.13 or later")
+		}
+		// the point is prefixed with 0x40 to mark its native encoding
+		point, _, ok := pgpMPI(rest)
+		if !ok || len(point) != 33 || point[0] != 0x40 {
+			return nil, errors.New("malformed OpenPGP EdDSA key")
+		}
+		return pgpEd25519Key(point[1:]), nil
+	}
+	return nil, nil
+}
+
+// pgpMPI returns the first multiprecision integer in data, and the rest of
+// data.
+func pgpMPI(data []byte) ([]byte, []byte, bool) {
+	if len(data) < 2 {
+		return nil, nil, false
+	}
+	size := (int(binary.BigEndian.Uint16(data[:2])) + 7) / 8
+	if len(data) < 2+size {
+		return nil, nil, false
+	}
+	return data[2 : 2+size], data[2+size:], true
+}
+
+// pgpOID returns the length-prefixed curve OID at the start of data, and
+// the rest of data.
+func pgpOID(data []byte) ([]byte, []byte, bool) {
+	if len(data) < 1 || len(data) < 1+int(data[0]) {
+		return nil, nil, false
+	}
+	return data[1 : 1+int(data[0])], data[1+int(data[0]):], true
+}
+
+// pgpPacket returns the tag and body of the single OpenPGP packet in data.
+func pgpPacket(data []byte) (byte, []byte, bool) {
+	tag, body, rest, ok := nextPGPPacket(data)
+	if !ok || len(rest) != 0 {
+		return 0, nil, false
+	}
+	return tag, body, true
+}
+
+// nextPGPPacket returns the tag and body of the first OpenPGP packet in
+// data, and the rest of data.
+func nextPGPPacket(data []byte) (byte, []byte, []byte, bool) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, nil, nil, false
+	}
+	var tag byte
+	var size, header int
+	if data[0]&0x40 != 0 {
+		// new format
+		tag = data[0] & 0x3f
+		switch l := int(data[1]); {
+		case l < 192:
+			size, header = l, 2
+		case l < 224:
+			if len(data) < 3 {
+				return 0, nil, nil, false
+			}
+			size, header = (l-192)<<8+int(data[2])+192, 3
+		case l == 255:
+			if len(data) < 6 {
+				return 0, nil, nil, false
+			}
+			size, header = int(binary.BigEndian.Uint32(data[2:6])), 6
+		default:
+			// partial body lengths aren't used for signatures or keys
+			return 0, nil, nil, false
+		}
+	} else {
+		// old format
+		tag = (data[0] >> 2) & 0xf
+		switch data[0] & 3 {
+		case 0:
+			size, header = int(data[1]), 2
+		case 1:
+			if len(data) < 3 {
+				return 0, nil, nil, false
+			}
+			size, header = int(binary.BigEndian.Uint16(data[1:3])), 3
+		case 2:
+			if len(data) < 5 {
+				return 0, nil, nil, false
+			}
+			size, header = int(binary.BigEndian.Uint32(data[1:5])), 5
+		default:
+			return 0, nil, nil, false
+		}
+	}
+	if size < 0 || header+size > len(data) {
+		return 0, nil, nil, false
+	}
+	return tag, data[header : header+size], data[header+size:], true
+}
+
+// dearmor returns the binary contents of data, if it's an ASCII-armored
+// OpenPGP block of the given type, such as "PGP SIGNATURE". The armor's
+// checksum isn't checked, since corrupted contents fail to verify anyway.
+func dearmor(data []byte, blockType string) ([]byte, bool) {
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) < 3 || string(bytes.TrimSpace(lines[0])) != "-----BEGIN "+blockType+"-----" {
+		return nil, false
+	}
+	// skip the armor headers, which end with a blank line
+	i := 1
+	for i < len(lines) && len(bytes.TrimSpace(lines[i])) != 0 {
+		i++
+	}
+	var encoded []byte
+	for _, line := range lines[i+1:] {
+		line = bytes.TrimSpace(line)
+		if bytes.HasPrefix(line, []byte("=")) || bytes.HasPrefix(line, []byte("-----END ")) {
+			break
+		}
+		encoded = append(encoded, line...)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
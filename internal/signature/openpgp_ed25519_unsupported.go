@@ -0,0 +1,25 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !go1.13
+
+package signature
+
+// crypto/ed25519 was added in Go 1.13, so EdDSA keys and signatures are
+// refused when built with older releases.
+const ed25519Supported = false
+
+func verifyEd25519(pub pgpEd25519Key, message, sig []byte) bool {
+	return false
+}
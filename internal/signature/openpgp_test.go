@@ -0,0 +1,258 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// made with gpg 2.2 from pgpData, by an RSA key and an ECDSA P-256 key
+// exported with `gpg --export-ssh-key <key>! | ssh-keygen -e -m PKCS8 -f -`
+const (
+	pgpData = "cba06b5736faf67e54b07b561eae94395e774c517a7d910a54369e1263ccfbd4  image.raw\n"
+
+	pgpRSAKey = `-----BEGIN PUBLIC KEY-----
+MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAybsjyNI9l10ZUiuHt6Mh
+lyurwpyvMRpqGEFMGx1qu77Kaoc5K05QV4aX89RuKesqTsMxTJIKCYiOSPEDwh8y
+t+zU4Ayq+Mgt0bPnxMQ83K/WFMKTElMOqDeaPrf0L+sgT6k9N7j6gojDqm3Gtqp9
+uSdJkuMufhoJpDtBx4zFxMRk6m7eNwRFlac7jl515jbxsqlYK/Kol2DCLvxJ6lvh
+oRj0EJS4HkTqwwj6L6GwXWTGn35DbiqKky90paKeA2OaV1p8QrMN2QFTtbmQcgxn
+G47zZ6xizs+m/rCdTw8bugFjJs50Xq5PFn0ihmYPDlr6c2bdBSjCYFnaIM/Vk7du
+1QIDAQAB
+-----END PUBLIC KEY-----`
+	pgpECKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE+Fz7OxbfYFZm1VbkQZwWP0F5UCqM
+hfzI3TbkK2NswtptLqfR/D00HWy3gPUVP54M2/ELKR038YQIRmqT4kyacg==
+-----END PUBLIC KEY-----`
+
+	// gpg --armor --detach-sign
+	pgpRSASig = `-----BEGIN PGP SIGNATURE-----
+
+iQEzBAABCgAdFiEEiZpEYdIWXF0J0yCMa6Ew7F5lenoFAmrQZV8ACgkQa6Ew7F5l
+enqa6gf/Z8yOsOAsxC+t4fp6IcB6Ew0JViNj/QyKw9qA0PTuvJUUgfdjs5JVd3dT
+aopAW44nmX3I8QqqycbQqf/xQ5Fojp5WjN+wNTIwN3QY9lwphKuyxeAivhxcdHV/
+Sc7PiG8Pf8htEl7pFu64BFFCbp7DJAyJljrHMbIgqTbn/ZvDZTBkJA85SuLrhVMo
+Am1wpPNf6bfcNxXmQWNcmD9HmZ7J5O4xtjb+0Bpou8HHS8phc7+aFQK7eOF/+dhg
+2vGC4v6+jAVXM3gjs2budObt4v8iSNlSgUnZk4GMf7d7Xl3bNObEyfnfxVdXGawZ
+xLXqEGzQITDTg4wQwn9zIWEF3Yz0Zg==
+=In86
+-----END PGP SIGNATURE-----`
+	// gpg --detach-sign, base64-encoded
+	pgpECSig = "iHUEABMIAB0WIQTw0wG38ddyKDwVavwoNhf1Enx6KgUCatBlXwAKCRAoNhf1Enx6KrzhAP4r5YPE4jsK8akdG3awaQo8M982VupCca7KfSHJZb4I6AEA9hngqdtA/RTJVUJEJYw9IbbGospmOcJxaAmKrn58gTQ="
+	// the same keys, exported with `gpg --armor --export`
+	pgpRSAKeyBlock = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mQENBGrQZV0BCADJuyPI0j2XXRlSK4e3oyGXK6vCnK8xGmoYQUwbHWq7vspqhzkr
+TlBXhpfz1G4p6ypOwzFMkgoJiI5I8QPCHzK37NTgDKr4yC3Rs+fExDzcr9YUwpMS
+Uw6oN5o+t/Qv6yBPqT03uPqCiMOqbca2qn25J0mS4y5+GgmkO0HHjMXExGTqbt43
+BEWVpzuOXnXmNvGyqVgr8qiXYMIu/EnqW+GhGPQQlLgeROrDCPovobBdZMaffkNu
+KoqTL3Slop4DY5pXWnxCsw3ZAVO1uZByDGcbjvNnrGLOz6b+sJ1PDxu6AWMmznRe
+rk8WfSKGZg8OWvpzZt0FKMJgWdogz9WTt27VABEBAAG0GlRlc3QgUlNBIDxyc2FA
+ZXhhbXBsZS5jb20+iQFOBBMBCgA4FiEEiZpEYdIWXF0J0yCMa6Ew7F5lenoFAmrQ
+ZV0CGwMFCwkIBwIGFQoJCAsCBBYCAwECHgECF4AACgkQa6Ew7F5lenpG6wf+NR2l
+j99IC2MxpQu3ytWZDTq3800IZUU5sWQJ9+4DVx7pHVmI45mccy0fAfQJPNc9f/Qi
+7MRZK+m7fy7l8tEaW2uX/z5SOr3rPXmQA/kHZdzv6MZHubwBtB7AjZTZk5rK1RZ3
+EnHQqR0i6194JuEAzKEhGjmjpdpznv+FJOaYv+DH42Y1h8linLM+XPIsKBRSZKYK
+VphRdxCDt3ngPOn0X2I1/5gNlccXJxCljs5RvrhZytH//BhIHJ0wNwFFCCBktVxz
+Jc5r9wj5iDCz4DrMcGGDqp0HeFiMox1SDhFshemG3BAfKYK6cN7PgIRcFxlbnYJW
+xem4ybo1M+TnG3yJcw==
+=V6Om
+-----END PGP PUBLIC KEY BLOCK-----`
+	pgpECKeyBlock = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mFIEatBlXRMIKoZIzj0DAQcCAwT4XPs7Ft9gVmbVVuRBnBY/QXlQKoyF/MjdNuQr
+Y2zC2m0up9H8PTQdbLeA9RU/ngzb8QspHTfxhAhGapPiTJpytBhUZXN0IEVDIDxl
+Y0BleGFtcGxlLmNvbT6IkAQTEwgAOBYhBPDTAbfx13IoPBVq/Cg2F/USfHoqBQJq
+0GVdAhsDBQsJCAcCBhUKCQgLAgQWAgMBAh4BAheAAAoJECg2F/USfHoqzpsBAKv8
+xagRjNPGX/EBF/G+B1Tq5o45DJvMkT6HF0uLSg/JAQDTFLd3uv3tkYZelKUq9vlC
+F99GOPcait3E8OPBVIoR3A==
+=lUXu
+-----END PGP PUBLIC KEY BLOCK-----`
+
+	// gpg --armor --textmode --detach-sign
+	pgpECTextSig = `-----BEGIN PGP SIGNATURE-----
+
+iHUEARMIAB0WIQTw0wG38ddyKDwVavwoNhf1Enx6KgUCatBlXwAKCRAoNhf1Enx6
+KlntAQCHiSsVcmSoLv8gfwrkx8lBjht+jBGYlJeXa5yINE7pLwEAtlsO29BIfo7v
+QRgIH/Oj2h1uaY4G8Zdr3kdZKh+iE8Q=
+=h1Bo
+-----END PGP SIGNATURE-----`
+
+	// an Ed25519 primary key with a Curve25519 encryption subkey and an
+	// Ed25519 signing subkey, as made by gpg by default, and a signature
+	// of pgpData by the signing subkey
+	pgpEdKeyBlock = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatBq6xYJKwYBBAHaRw8BAQdA/e2BqOHiMhigy6nRHgpdV73IacJ0R0FViiAg
+EnImHi+0HVRlc3QgRWQyNTUxOSA8ZWRAZXhhbXBsZS5jb20+iJAEExYIADgWIQS8
+pkumfe/xdc4S9cXzhXrAVfZ4LwUCatBq6wIbAwULCQgHAgYVCgkICwIEFgIDAQIe
+AQIXgAAKCRDzhXrAVfZ4L9eMAQCYEHMEUtSp5RjvaTmKlRr7QDBC+qrLngMfYfq/
+4WlrJAEAnaRjlz1TlG5OpK0TzyRt1i6Cn50gA+SpEDaqEQkaUAa4OARq0GryEgor
+BgEEAZdVAQUBAQdAd7r9BID/YHKhnBEDjT9Vy2USTJsFEcJMQDrSBCCLLEADAQgH
+iHgEGBYIACAWIQS8pkumfe/xdc4S9cXzhXrAVfZ4LwUCatBq8gIbDAAKCRDzhXrA
+VfZ4L/74AP41ka5GYvbLqBPKpaTEx+7vWeIFC73syFjYM1Nh9aN0KAD+MCxCuEI3
+a7V/grjT2c7qxln7niMCHK0ObWgu0R2kWAq4MwRq0GryFgkrBgEEAdpHDwEBB0Cw
+rJ+HG+H7RfzOoDjCwW9dliciPacUYKJGNJZ0QjreSojvBBgWCAAgFiEEvKZLpn3v
+8XXOEvXF84V6wFX2eC8FAmrQavICGwIAgQkQ84V6wFX2eC92IAQZFggAHRYhBKAK
+gS+DrYJHqRWK7cp/t60eRckhBQJq0GryAAoJEMp/t60eRckhEBABAIxUTxHwA70M
+UNT+aOdGp/4nPzH57ZnAtrAdZvVqv4bMAQCuhKTy2OpvsrDClSc5KB8TLzZyZqKn
+tGaNVi7Ri06qDErMAQDpuhEIK0tppGjml/aqs6xA2edqFq+XSGO2ZR4DpETqTQD/
+Ui4xFjrowi59Qa9p4pK/tnWwNFUF01BIBWviSuBEHgA=
+=0qNa
+-----END PGP PUBLIC KEY BLOCK-----`
+	pgpEdSig = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQSgCoEvg62CR6kViu3Kf7etHkXJIQUCatBq9AAKCRDKf7etHkXJ
+IS+PAQDtRQT0B0aGZ7Gd1MeEHMzZrnU5UwDrIOrCGLUE2U8rEwEAqxS2pChQrNOL
+VZBOhhqUiS95QKWHu3QpVIVj0NAC3Qc=
+=h62d
+-----END PGP SIGNATURE-----`
+
+	// a signature of pgpData by a DSA key
+	pgpDSASig = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABEIAB0WIQTaZXbiM1Y4xW6NWsKyOypOvtv4uwUCatBq7wAKCRCyOypOvtv4
+uzvRAQDxyXSMuyLEz7FbixX/Nfh5NFe8T1mcNDM/C1BQZvFISgEA0dL41aJbXAny
+idNFOe32i1gPWQJtu1blLfJpzlK2/Bc=
+=QE3C
+-----END PGP SIGNATURE-----`
+)
+
+func TestVerifyDetachedOpenPGP(t *testing.T) {
+	ecSig, err := base64.StdEncoding.DecodeString(pgpECSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keys []string
+		data string
+		sig  []byte
+		err  error
+	}{
+		{
+			keys: []string{pgpECKey, pgpRSAKey},
+			data: pgpData,
+			sig:  []byte(pgpRSASig),
+		},
+		{
+			keys: []string{pgpECKey},
+			data: pgpData,
+			sig:  ecSig,
+		},
+		{
+			keys: []string{pgpECKey},
+			data: pgpData,
+			sig:  []byte(pgpECTextSig),
+		},
+		{
+			// text signatures don't depend on line endings
+			keys: []string{pgpECKey},
+			data: pgpData[:len(pgpData)-1] + "\r\n",
+			sig:  []byte(pgpECTextSig),
+		},
+		{
+			keys: []string{pgpECKeyBlock},
+			data: pgpData,
+			sig:  ecSig,
+		},
+		{
+			keys: []string{pgpECKey, pgpRSAKeyBlock},
+			data: pgpData,
+			sig:  []byte(pgpRSASig),
+		},
+		{
+			keys: []string{pgpRSAKey},
+			data: pgpData + " ",
+			sig:  []byte(pgpRSASig),
+			err:  ErrBadDetachedSignature,
+		},
+		{
+			keys: []string{pgpRSAKey},
+			data: pgpData,
+			sig:  ecSig,
+			err:  ErrBadDetachedSignature,
+		},
+		{
+			// binary signatures do
+			keys: []string{pgpECKey},
+			data: pgpData[:len(pgpData)-1] + "\r\n",
+			sig:  ecSig,
+			err:  ErrBadDetachedSignature,
+		},
+		{
+			keys: []string{pgpECKey},
+			data: pgpData,
+			sig:  ecSig[:len(ecSig)-1],
+			err:  ErrBadDetachedSignature,
+		},
+		{
+			keys: []string{pgpRSAKey},
+			data: pgpData,
+			sig:  []byte(pgpDSASig),
+			err:  errors.New("unsupported OpenPGP public-key algorithm 17"),
+		},
+		{
+			keys: []string{"-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nmQ==\n-----END PGP PUBLIC KEY BLOCK-----"},
+			data: pgpData,
+			sig:  ecSig,
+			err:  errors.New("malformed OpenPGP public key block"),
+		},
+	}
+
+	for i, test := range tests {
+		p := Policy{Keys: test.keys}
+		if err := p.VerifyDetached([]byte(test.data), test.sig); fmt.Sprint(err) != fmt.Sprint(test.err) {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestVerifyDetachedEdDSA(t *testing.T) {
+	if !ed25519Supported {
+		t.Skip("EdDSA needs Go 1.13 or later")
+	}
+
+	tests := []struct {
+		keys []string
+		data string
+		err  error
+	}{
+		{
+			keys: []string{pgpRSAKeyBlock, pgpEdKeyBlock},
+			data: pgpData,
+		},
+		{
+			keys: []string{pgpEdKeyBlock},
+			data: pgpData + " ",
+			err:  ErrBadDetachedSignature,
+		},
+		{
+			keys: []string{pgpECKeyBlock},
+			data: pgpData,
+			err:  ErrBadDetachedSignature,
+		},
+	}
+
+	for i, test := range tests {
+		p := Policy{Keys: test.keys}
+		if err := p.VerifyDetached([]byte(test.data), []byte(pgpEdSig)); err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+		}
+	}
+}
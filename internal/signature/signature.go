@@ -0,0 +1,245 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signature verifies the detached signatures of fetched configs
+// against keys trusted by the machine.
+//
+// A signed config is wrapped in an envelope:
+//
+//	{"signedConfig": {"config": "<base64>", "signature": "<base64>", "key": "<PEM>"}}
+//
+// where the signature is a SHA-256 ECDSA (ASN.1) or RSA PKCS #1 v1.5
+// signature of the config's bytes, as produced by `openssl dgst -sha256
+// -sign`. The key is optional, and only trusted if its fingerprint was given
+// on the kernel command line.
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	ErrUnsigned     = errors.New("config is not signed, but a signature is required")
+	ErrBadSignature = errors.New("config signature could not be verified with any trusted key")
+
+	ErrNoKeys               = errors.New("no keys are trusted to verify the signature")
+	ErrBadDetachedSignature = errors.New("signature could not be verified with any trusted key")
+)
+
+// Policy is the set of keys trusted to sign configs.
+type Policy struct {
+	// Keys are PEM-encoded public keys, or ASCII-armored OpenPGP public
+	// key blocks.
+	Keys []string
+
+	// Fingerprints are the "sha256:<hex>" fingerprints of the DER-encoded
+	// public keys which may be included in a signed config's envelope.
+	Fingerprints []string
+}
+
+type envelope struct {
+	SignedConfig *struct {
+		Config    []byte `json:"config"`
+		Signature []byte `json:"signature"`
+		Key       string `json:"key"`
+	} `json:"signedConfig"`
+}
+
+// Required reports whether configs must be signed under p.
+func (p Policy) Required() bool {
+	return len(p.Keys) > 0 || len(p.Fingerprints) > 0
+}
+
+// Verify returns the config within the signed envelope raw, checking its
+// signature if p requires one. Unsigned configs are returned unchanged if
+// p doesn't require a signature, and so are empty ones, which are never
+// used.
+func (p Policy) Verify(raw []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return raw, nil
+	}
+	env, ok := parseEnvelope(raw)
+	if !p.Required() {
+		if ok {
+			return env.SignedConfig.Config, nil
+		}
+		return raw, nil
+	}
+	if !ok {
+		return nil, ErrUnsigned
+	}
+
+	signed := env.SignedConfig
+	digest := sha256.Sum256(signed.Config)
+	keys := p.Keys
+	if signed.Key != "" {
+		pub, err := ParseKey(signed.Key)
+		if err != nil {
+			return nil, fmt.Errorf("parsing envelope key: %v", err)
+		}
+		fingerprint, err := Fingerprint(pub)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range p.Fingerprints {
+			if f == fingerprint {
+				keys = append([]string{signed.Key}, keys...)
+				break
+			}
+		}
+	}
+	for _, key := range keys {
+		pubs, err := parseKeys(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, pub := range pubs {
+			if verify(pub, digest[:], signed.Signature) {
+				return signed.Config, nil
+			}
+		}
+	}
+	return nil, ErrBadSignature
+}
+
+// VerifyDetached checks that sig is a signature of data by one of the keys
+// in p, either as produced by `openssl dgst -sha256 -sign` or a binary or
+// ASCII-armored OpenPGP signature, as produced by `gpg --detach-sign`. Keys
+// vouched for by fingerprint can't be used, since there is no envelope to
+// carry them.
+func (p Policy) VerifyDetached(data, sig []byte) error {
+	if len(p.Keys) == 0 {
+		return ErrNoKeys
+	}
+	pgp, isPGP, err := parsePGPSignature(sig)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data)
+	for _, key := range p.Keys {
+		pubs, err := parseKeys(key)
+		if err != nil {
+			return err
+		}
+		for _, pub := range pubs {
+			if isPGP && pgp.verify(pub, data) {
+				return nil
+			} else if !isPGP && verify(pub, digest[:], sig) {
+				return nil
+			}
+		}
+	}
+	return ErrBadDetachedSignature
+}
+
+// Unwrap returns the config within the signed envelope raw without checking
+// its signature, or raw if it isn't signed. It's for configs which are
+// trusted some other way, such as by their hash.
+func Unwrap(raw []byte) []byte {
+	if env, ok := parseEnvelope(raw); ok {
+		return env.SignedConfig.Config
+	}
+	return raw
+}
+
+// Wrap returns config in a signed envelope with the detached signature sig,
+// for platforms which deliver the signature separately from the config.
+func Wrap(config, sig []byte) ([]byte, error) {
+	var env envelope
+	env.SignedConfig = &struct {
+		Config    []byte `json:"config"`
+		Signature []byte `json:"signature"`
+		Key       string `json:"key"`
+	}{Config: config, Signature: sig}
+	return json.Marshal(env)
+}
+
+func parseEnvelope(raw []byte) (envelope, bool) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil || env.SignedConfig == nil {
+		return envelope{}, false
+	}
+	return env, true
+}
+
+// parseKeys parses key, which is either a PEM-encoded ECDSA or RSA public
+// key or an ASCII-armored OpenPGP public key block, as exported by `gpg
+// --armor --export`, whose signing keys are all returned.
+func parseKeys(key string) ([]crypto.PublicKey, error) {
+	if data, ok := dearmor([]byte(key), "PGP PUBLIC KEY BLOCK"); ok {
+		return parsePGPKeys(data)
+	}
+	pub, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return []crypto.PublicKey{pub}, nil
+}
+
+// ParseKey parses a PEM-encoded ECDSA or RSA public key.
+func ParseKey(key string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch pub.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// Fingerprint returns the "sha256:<hex>" fingerprint of the DER encoding of
+// pub, as printed by `openssl pkey -pubin -outform DER | sha256sum`.
+func Fingerprint(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func verify(pub crypto.PublicKey, digest, sig []byte) bool {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		var esig struct {
+			R, S *big.Int
+		}
+		if rest, err := asn1.Unmarshal(sig, &esig); err != nil || len(rest) != 0 {
+			return false
+		}
+		return ecdsa.Verify(pub, digest, esig.R, esig.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, sig) == nil
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+)
+
+func pemKey(t *testing.T, s crypto.Signer) string {
+	der, err := x509.MarshalPKIXPublicKey(s.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func sign(t *testing.T, s crypto.Signer, config, key string) []byte {
+	digest := sha256.Sum256([]byte(config))
+	sig, err := s.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env envelope
+	env.SignedConfig = &struct {
+		Config    []byte `json:"config"`
+		Signature []byte `json:"signature"`
+		Key       string `json:"key"`
+	}{[]byte(config), sig, key}
+	b, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherFingerprint, err := Fingerprint(otherKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const config = `{"ignition": {"version": "3.1.0-experimental"}}`
+
+	tests := []struct {
+		policy Policy
+		in     []byte
+		out    string
+		err    error
+	}{
+		{
+			in:  []byte(config),
+			out: config,
+		},
+		{
+			in:  sign(t, ecKey, config, ""),
+			out: config,
+		},
+		{
+			policy: Policy{Keys: []string{pemKey(t, ecKey)}},
+			in:     []byte(""),
+			out:    "",
+		},
+		{
+			policy: Policy{Keys: []string{pemKey(t, ecKey)}},
+			in:     []byte(config),
+			err:    ErrUnsigned,
+		},
+		{
+			policy: Policy{Keys: []string{pemKey(t, ecKey)}},
+			in:     sign(t, ecKey, config, ""),
+			out:    config,
+		},
+		{
+			policy: Policy{Keys: []string{pemKey(t, ecKey), pemKey(t, rsaKey)}},
+			in:     sign(t, rsaKey, config, ""),
+			out:    config,
+		},
+		{
+			policy: Policy{Keys: []string{pemKey(t, ecKey)}},
+			in:     sign(t, otherKey, config, ""),
+			err:    ErrBadSignature,
+		},
+		{
+			// the envelope's key isn't trusted without its fingerprint
+			policy: Policy{Keys: []string{pemKey(t, ecKey)}},
+			in:     sign(t, otherKey, config, pemKey(t, otherKey)),
+			err:    ErrBadSignature,
+		},
+		{
+			policy: Policy{Fingerprints: []string{otherFingerprint}},
+			in:     sign(t, otherKey, config, pemKey(t, otherKey)),
+			out:    config,
+		},
+		{
+			policy: Policy{Fingerprints: []string{otherFingerprint}},
+			in:     sign(t, ecKey, config, pemKey(t, ecKey)),
+			err:    ErrBadSignature,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := test.policy.Verify(test.in)
+		if err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: bad config: want %q, got %q", i, test.out, string(out))
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const config = `{"ignition": {"version": "3.1.0-experimental"}}`
+	digest := sha256.Sum256([]byte(config))
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := Policy{Keys: []string{pemKey(t, key)}}
+	wrapped, err := Wrap([]byte(config), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := policy.Verify(wrapped); err != nil || string(out) != config {
+		t.Errorf("failed to verify wrapped config: %v", err)
+	}
+	wrapped, err = Wrap([]byte(config+" "), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Verify(wrapped); err != ErrBadSignature {
+		t.Errorf("bad error: want %v, got %v", ErrBadSignature, err)
+	}
+}
+
+func TestVerifyDetached(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := Fingerprint(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const data = "0123  file.img\n"
+	digest := sha256.Sum256([]byte(data))
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy Policy
+		data   string
+		err    error
+	}{
+		{
+			policy: Policy{Keys: []string{pemKey(t, otherKey), pemKey(t, key)}},
+			data:   data,
+		},
+		{
+			policy: Policy{Keys: []string{pemKey(t, key)}},
+			data:   data + " ",
+			err:    ErrBadDetachedSignature,
+		},
+		{
+			policy: Policy{Keys: []string{pemKey(t, otherKey)}},
+			data:   data,
+			err:    ErrBadDetachedSignature,
+		},
+		{
+			// there's no envelope to carry a key vouched for by fingerprint
+			policy: Policy{Fingerprints: []string{fingerprint}},
+			data:   data,
+			err:    ErrNoKeys,
+		},
+	}
+
+	for i, test := range tests {
+		if err := test.policy.VerifyDetached([]byte(test.data), sig); err != test.err {
+			t.Errorf("#%d: bad error: want %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseKey(pemKey(t, ecKey)); err != nil {
+		t.Errorf("failed to parse ECDSA key: %v", err)
+	}
+	if _, err := ParseKey("not a key"); err == nil {
+		t.Errorf("parsed a key from garbage")
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tpm measures data into a TPM 2.0 PCR, so remote attestation can
+// prove what a machine was provisioned with.
+//
+// Userspace can't append to the firmware's event log, so each measurement is
+// also recorded in Ignition's own event log, a file of JSON objects, one per
+// line, which a verifier replays after the firmware's.
+package tpm
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/ignition/v2/internal/distro"
+)
+
+const (
+	tagNoSessions   = 0x8001
+	tagSessions     = 0x8002
+	ccPCRExtend     = 0x00000182
+	ccGetCapability = 0x0000017a
+	capPCRs         = 0x00000005
+	rsPassword      = 0x40000009
+	algSHA1         = 0x0004
+	algSHA256       = 0x000b
+	algSHA384       = 0x000c
+	algSHA512       = 0x000d
+	maxPCR          = 23
+	headerBytes     = 10
+	responseBytes   = 4096
+)
+
+var (
+	ErrNoTPM = errors.New("no TPM device found")
+
+	// hashes are the hash algorithms of the PCR banks Ignition can extend
+	hashes = map[uint16]crypto.Hash{
+		algSHA1:   crypto.SHA1,
+		algSHA256: crypto.SHA256,
+		algSHA384: crypto.SHA384,
+		algSHA512: crypto.SHA512,
+	}
+)
+
+// Event is a measurement in Ignition's event log. SHA256 is always set;
+// the other digests are set if the PCR's bank for them was extended.
+type Event struct {
+	PCR         int    `json:"pcr"`
+	SHA1        string `json:"sha1,omitempty"`
+	SHA256      string `json:"sha256"`
+	SHA384      string `json:"sha384,omitempty"`
+	SHA512      string `json:"sha512,omitempty"`
+	Description string `json:"description"`
+}
+
+// pcrDigest is the digest of the measured data for the PCR bank using alg.
+type pcrDigest struct {
+	alg uint16
+	sum []byte
+}
+
+// Measure extends pcr in every active bank with the digest of data in that
+// bank's algorithm, after recording the measurement in the event log, so
+// that no bank is left for someone else to extend with a forged config. It
+// fails if a bank uses an algorithm Ignition doesn't support, and returns
+// ErrNoTPM if the machine has no TPM.
+func Measure(pcr int, data []byte, description string) error {
+	if pcr < 0 || pcr > maxPCR {
+		return fmt.Errorf("invalid PCR %d", pcr)
+	}
+	dev, err := os.OpenFile(distro.TPMDevicePath(), os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return ErrNoTPM
+	} else if err != nil {
+		return err
+	}
+	defer dev.Close()
+
+	algs, err := activeBanks(dev, pcr)
+	if err != nil {
+		return err
+	}
+	if len(algs) == 0 {
+		return fmt.Errorf("PCR %d isn't allocated in any bank", pcr)
+	}
+	event := Event{
+		PCR:         pcr,
+		SHA256:      hex.EncodeToString(sum(crypto.SHA256, data)),
+		Description: description,
+	}
+	var digests []pcrDigest
+	for _, alg := range algs {
+		hash, ok := hashes[alg]
+		if !ok {
+			return fmt.Errorf("PCR %d is allocated in a bank with unsupported hash algorithm 0x%04x", pcr, alg)
+		}
+		d := sum(hash, data)
+		digests = append(digests, pcrDigest{alg: alg, sum: d})
+		switch alg {
+		case algSHA1:
+			event.SHA1 = hex.EncodeToString(d)
+		case algSHA384:
+			event.SHA384 = hex.EncodeToString(d)
+		case algSHA512:
+			event.SHA512 = hex.EncodeToString(d)
+		}
+	}
+	if err := logEvent(event); err != nil {
+		return fmt.Errorf("writing event log: %v", err)
+	}
+	return extend(dev, pcr, digests)
+}
+
+func sum(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func logEvent(e Event) error {
+	path := distro.TPMEventLogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(e)
+}
+
+// activeBanks returns the hash algorithms of the banks in which pcr is
+// allocated, as reported by a TPM2_GetCapability command for the PCRs sent
+// to the TPM at rw.
+func activeBanks(rw io.ReadWriter, pcr int) ([]uint16, error) {
+	var body bytes.Buffer
+	for _, field := range []uint32{capPCRs, 0, 1} {
+		binary.Write(&body, binary.BigEndian, field)
+	}
+	resp, err := command(rw, tagNoSessions, ccGetCapability, body.Bytes(), "reading PCR banks")
+	if err != nil {
+		return nil, err
+	}
+	// skip moreData and the capability; TPML_PCR_SELECTION follows
+	if len(resp) < 9 {
+		return nil, fmt.Errorf("short PCR banks response")
+	}
+	r := bytes.NewReader(resp[5:])
+	var count uint32
+	binary.Read(r, binary.BigEndian, &count)
+	var algs []uint16
+	for i := uint32(0); i < count; i++ {
+		// TPMS_PCR_SELECTION
+		var bank struct {
+			Alg  uint16
+			Size uint8
+		}
+		if err := binary.Read(r, binary.BigEndian, &bank); err != nil {
+			return nil, fmt.Errorf("short PCR banks response")
+		}
+		selected := make([]byte, bank.Size)
+		if _, err := io.ReadFull(r, selected); err != nil {
+			return nil, fmt.Errorf("short PCR banks response")
+		}
+		if pcr/8 < len(selected) && selected[pcr/8]&(1<<uint(pcr%8)) != 0 {
+			algs = append(algs, bank.Alg)
+		}
+	}
+	return algs, nil
+}
+
+// extend sends a TPM2_PCR_Extend command for pcr with digests to the TPM at
+// rw, authorizing it with the empty password, as PCRs normally require.
+func extend(rw io.ReadWriter, pcr int, digests []pcrDigest) error {
+	var body bytes.Buffer
+	fields := []interface{}{
+		uint32(pcr),
+		// authorization area: one password session with an empty password
+		uint32(9),
+		uint32(rsPassword),
+		uint16(0), // nonce
+		uint8(0),  // session attributes
+		uint16(0), // password
+		// TPML_DIGEST_VALUES
+		uint32(len(digests)),
+	}
+	for _, d := range digests {
+		fields = append(fields, d.alg, d.sum)
+	}
+	for _, field := range fields {
+		binary.Write(&body, binary.BigEndian, field)
+	}
+	_, err := command(rw, tagSessions, ccPCRExtend, body.Bytes(), fmt.Sprintf("extending PCR %d", pcr))
+	return err
+}
+
+// command sends the command with code cc and body to the TPM at rw and
+// returns the body of its response, failing if the TPM reports an error.
+// what describes the command for errors.
+func command(rw io.ReadWriter, tag uint16, cc uint32, body []byte, what string) ([]byte, error) {
+	var cmd bytes.Buffer
+	binary.Write(&cmd, binary.BigEndian, tag)
+	binary.Write(&cmd, binary.BigEndian, uint32(headerBytes+len(body)))
+	binary.Write(&cmd, binary.BigEndian, cc)
+	cmd.Write(body)
+	if _, err := rw.Write(cmd.Bytes()); err != nil {
+		return nil, fmt.Errorf("%s: sending command: %v", what, err)
+	}
+
+	resp := make([]byte, responseBytes)
+	n, err := rw.Read(resp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading response: %v", what, err)
+	}
+	if n < headerBytes {
+		return nil, fmt.Errorf("%s: short response", what)
+	}
+	if rc := binary.BigEndian.Uint32(resp[6:10]); rc != 0 {
+		return nil, fmt.Errorf("%s failed with TPM response code 0x%x", what, rc)
+	}
+	return resp[headerBytes:n], nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tpm
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// fakeTPM records the command written to it and replies with response.
+type fakeTPM struct {
+	command  []byte
+	response []byte
+}
+
+func (f *fakeTPM) Write(b []byte) (int, error) {
+	f.command = append([]byte{}, b...)
+	return len(b), nil
+}
+
+func (f *fakeTPM) Read(b []byte) (int, error) {
+	return copy(b, f.response), nil
+}
+
+func TestExtend(t *testing.T) {
+	digest := sha256.Sum256([]byte("config"))
+	want, _ := hex.DecodeString("80020000004100000182" + // header
+		"0000000c" + // PCR 12
+		"00000009" + "40000009" + "0000" + "00" + "0000" + // password session
+		"00000001" + "000b" + hex.EncodeToString(digest[:]))
+
+	tpm := &fakeTPM{response: []byte{0x80, 0x02, 0, 0, 0, 0x13, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}}
+	if err := extend(tpm, 12, []pcrDigest{{alg: algSHA256, sum: digest[:]}}); err != nil {
+		t.Fatalf("extend failed: %v", err)
+	}
+	if !bytes.Equal(tpm.command, want) {
+		t.Errorf("bad command:\nwant %x\ngot  %x", want, tpm.command)
+	}
+
+	// TPM_RC_LOCALITY
+	tpm = &fakeTPM{response: []byte{0x80, 0x01, 0, 0, 0, 0x0a, 0, 0, 0x09, 0x07}}
+	if err := extend(tpm, 17, []pcrDigest{{alg: algSHA256, sum: digest[:]}}); err == nil {
+		t.Errorf("extend succeeded despite error response")
+	}
+}
+
+func TestExtendBanks(t *testing.T) {
+	sha1Digest := sha1.Sum([]byte("config"))
+	sha256Digest := sha256.Sum256([]byte("config"))
+	want, _ := hex.DecodeString("80020000005700000182" + // header
+		"0000000c" + // PCR 12
+		"00000009" + "40000009" + "0000" + "00" + "0000" + // password session
+		"00000002" + "0004" + hex.EncodeToString(sha1Digest[:]) +
+		"000b" + hex.EncodeToString(sha256Digest[:]))
+
+	tpm := &fakeTPM{response: []byte{0x80, 0x02, 0, 0, 0, 0x13, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}}
+	digests := []pcrDigest{
+		{alg: algSHA1, sum: sha1Digest[:]},
+		{alg: algSHA256, sum: sha256Digest[:]},
+	}
+	if err := extend(tpm, 12, digests); err != nil {
+		t.Fatalf("extend failed: %v", err)
+	}
+	if !bytes.Equal(tpm.command, want) {
+		t.Errorf("bad command:\nwant %x\ngot  %x", want, tpm.command)
+	}
+}
+
+func TestActiveBanks(t *testing.T) {
+	want, _ := hex.DecodeString("8001" + "00000016" + "0000017a" + // header
+		"00000005" + "00000000" + "00000001") // TPM_CAP_PCRS
+	response, _ := hex.DecodeString("80010000002b00000000" + // header
+		"00" + "00000005" + // moreData, TPM_CAP_PCRS
+		"00000004" +
+		"0004" + "03" + "ffffff" + // sha1, all PCRs
+		"000b" + "03" + "ffffff" + // sha256, all PCRs
+		"000c" + "03" + "000000" + // sha384, unallocated
+		"0012" + "03" + "ff0f00") // sm3, PCRs 0-11
+
+	tests := []struct {
+		pcr  int
+		algs []uint16
+	}{
+		{12, []uint16{algSHA1, algSHA256}},
+		{3, []uint16{algSHA1, algSHA256, 0x0012}},
+		{23, []uint16{algSHA1, algSHA256}},
+	}
+	for i, test := range tests {
+		tpm := &fakeTPM{response: response}
+		algs, err := activeBanks(tpm, test.pcr)
+		if err != nil {
+			t.Errorf("#%d: activeBanks failed: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(tpm.command, want) {
+			t.Errorf("#%d: bad command:\nwant %x\ngot  %x", i, want, tpm.command)
+		}
+		if !reflect.DeepEqual(algs, test.algs) {
+			t.Errorf("#%d: got banks %v, expected %v", i, algs, test.algs)
+		}
+	}
+
+	tpm := &fakeTPM{response: response[:len(response)-2]}
+	if _, err := activeBanks(tpm, 12); err == nil {
+		t.Errorf("activeBanks succeeded despite truncated response")
+	}
+}
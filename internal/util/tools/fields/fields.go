@@ -0,0 +1,195 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Generates the field reference of each spec version from the config types,
+// so that it lists exactly the fields Ignition accepts. With -check, fails
+// if the references in the documentation are out of date, or if the
+// specification of a version doesn't mention one of its fields.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	types_3_0 "github.com/coreos/ignition/v2/config/v3_0/types"
+	types_exp "github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+)
+
+type spec struct {
+	version string
+	// reference is the generated field reference, and specification the
+	// hand-written description of the fields
+	reference     string
+	specification string
+	config        interface{}
+}
+
+var specs = []spec{
+	{
+		version:       types_3_0.MaxVersion.String(),
+		reference:     "fields-v3_0.md",
+		specification: "configuration-v3_0.md",
+		config:        types_3_0.Config{},
+	},
+	{
+		version:       types_exp.MaxVersion.String(),
+		reference:     "fields-v3_1-experimental.md",
+		specification: "configuration-v3_1-experimental.md",
+		config:        types_exp.Config{},
+	},
+}
+
+func main() {
+	flags := struct {
+		help  bool
+		check bool
+		root  string
+	}{}
+
+	flag.BoolVar(&flags.help, "help", false, "Print help and exit.")
+	flag.BoolVar(&flags.check, "check", false, "Check the field references instead of writing them.")
+	flag.StringVar(&flags.root, "root", "doc", "Path to the documentation.")
+
+	flag.Parse()
+
+	if flags.help {
+		flag.Usage()
+		return
+	}
+
+	failed := false
+	for _, s := range specs {
+		var fields []string
+		var out bytes.Buffer
+		fmt.Fprintf(&out, "# Config Fields v%s #\n\n", s.version)
+		fmt.Fprintf(&out, "<!-- generated by \"go run internal/util/tools/fields/fields.go\" -- DO NOT EDIT -->\n\n")
+		fmt.Fprintf(&out, "These are the fields of version %s configs, generated from the types Ignition parses configs into. Entries in **_italics_** are optional; see [the specification](%s) for what each field means.\n\n", s.version, s.specification)
+		writeFields(&out, reflect.TypeOf(s.config), "", &fields)
+
+		path := filepath.Join(flags.root, s.reference)
+		if !flags.check {
+			if err := ioutil.WriteFile(path, out.Bytes(), 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "failed writing field reference: %v\n", err)
+				os.Exit(1)
+			}
+			continue
+		}
+
+		current, err := ioutil.ReadFile(path)
+		if err != nil || !bytes.Equal(current, out.Bytes()) {
+			fmt.Fprintf(os.Stderr, "%s is out of date; run \"go run internal/util/tools/fields/fields.go\"\n", path)
+			failed = true
+		}
+		specification, err := ioutil.ReadFile(filepath.Join(flags.root, s.specification))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed reading specification: %v\n", err)
+			os.Exit(1)
+		}
+		for _, field := range fields {
+			if !bytes.Contains(specification, []byte("**"+field+"**")) && !bytes.Contains(specification, []byte("**_"+field+"_**")) {
+				fmt.Fprintf(os.Stderr, "%s doesn't describe the field %q\n", s.specification, field)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// alwaysRequired lists fields which are required but optional in the
+// types, because they're checked while parsing.
+var alwaysRequired = map[string]bool{
+	"ignition.version": true,
+}
+
+// writeFields writes the fields of the struct type t, which is found at
+// parent, as a nested list, appending their names to names.
+func writeFields(out *bytes.Buffer, t reflect.Type, parent string, names *[]string) {
+	depth := 0
+	if parent != "" {
+		depth = strings.Count(parent, ".") + 1
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			// embedded structs hold fields shared between types
+			writeFields(out, field.Type, parent, names)
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := name
+		if parent != "" {
+			path = parent + "." + name
+		}
+		required := true
+		for _, opt := range tag[1:] {
+			if opt == "omitempty" {
+				required = alwaysRequired[path]
+			}
+		}
+		*names = append(*names, name)
+
+		formatted := "**_" + name + "_**"
+		if required {
+			formatted = "**" + name + "**"
+		}
+		fmt.Fprintf(out, "%s* %s (%s)\n", strings.Repeat("  ", depth), formatted, typeName(field.Type))
+		if elem := structType(field.Type); elem != nil {
+			writeFields(out, elem, path, names)
+		}
+	}
+}
+
+// typeName describes t as the JSON type it's parsed from.
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeName(t.Elem())
+	case reflect.Slice:
+		return "list of " + typeName(t.Elem()) + "s"
+	case reflect.Struct:
+		return "object"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return "integer"
+	default:
+		panic(fmt.Sprintf("unsupported config type %s", t))
+	}
+}
+
+// structType returns the struct type of the objects t holds, if any.
+func structType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		return t
+	}
+	return nil
+}
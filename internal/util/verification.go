@@ -15,6 +15,7 @@
 package util
 
 import (
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
 	"errors"
@@ -23,6 +24,7 @@ import (
 	"strings"
 
 	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/failure"
 )
 
 var (
@@ -42,6 +44,10 @@ func (e ErrHashMismatch) Error() string {
 		e.Calculated, e.Expected)
 }
 
+func (e ErrHashMismatch) Class() error {
+	return failure.ErrVerificationFailed
+}
+
 // HashParts will return the sum and function (in that order) of the hash stored
 // in this Verification, or an error if there is an issue during parsing.
 func HashParts(v types.Verification) (string, string, error) {
@@ -66,6 +72,9 @@ func AssertValid(verify types.Verification, data []byte) error {
 
 		var sum []byte
 		switch hashFunc {
+		case "sha256":
+			rawSum := sha256.Sum256(data)
+			sum = rawSum[:]
 		case "sha512":
 			rawSum := sha512.Sum512(data)
 			sum = rawSum[:]
@@ -97,6 +106,8 @@ func GetHasher(verify types.Verification) (hash.Hash, error) {
 	}
 
 	switch function {
+	case "sha256":
+		return sha256.New(), nil
 	case "sha512":
 		return sha512.New(), nil
 	default:
@@ -0,0 +1,78 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheme lets resources be fetched from URL schemes which Ignition
+// doesn't support itself, such as vault:// or consul://. Handlers register
+// themselves, usually from an init function, and are then used for every
+// source with their scheme: configs, file contents, and CAs alike.
+//
+// Configs using registered schemes must be written in the experimental
+// spec, since stable specs only accept the schemes they were released
+// with.
+package scheme
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/coreos/ignition/v2/internal/registry"
+)
+
+// Fetcher fetches resources whose URLs use a particular scheme.
+type Fetcher interface {
+	// Name returns the URL scheme which the fetcher handles, such as
+	// "vault".
+	Name() string
+
+	// Fetch returns the contents of the resource at u, which the caller
+	// closes. Ignition decompresses and verifies the contents itself.
+	Fetch(u url.URL) (io.ReadCloser, error)
+}
+
+// builtin are the schemes Ignition fetches without a registered Fetcher.
+var builtin = map[string]bool{
+	"data":      true,
+	"http":      true,
+	"https":     true,
+	"http+unix": true,
+	"s3":        true,
+	"tftp":      true,
+	"vault":     true,
+}
+
+var fetchers = registry.Create("URL schemes")
+
+// Register makes f the fetcher for URLs with its scheme. It panics if the
+// scheme is already handled.
+func Register(f Fetcher) {
+	if builtin[f.Name()] {
+		panic(fmt.Sprintf("URL schemes: %q is built in", f.Name()))
+	}
+	fetchers.Register(f)
+}
+
+// Get returns the fetcher registered for the scheme, or nil if there is
+// none.
+func Get(name string) Fetcher {
+	if f, ok := fetchers.Get(name).(Fetcher); ok {
+		return f
+	}
+	return nil
+}
+
+// Names returns the registered schemes.
+func Names() []string {
+	return fetchers.Names()
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheme
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type testFetcher string
+
+func (f testFetcher) Name() string {
+	return string(f)
+}
+
+func (testFetcher) Fetch(_ url.URL) (io.ReadCloser, error) {
+	return nil, nil
+}
+
+func TestRegister(t *testing.T) {
+	Register(testFetcher("example"))
+	if f := Get("example"); f != testFetcher("example") {
+		t.Errorf("registered fetcher not found: got %v", f)
+	}
+	if f := Get("missing"); f != nil {
+		t.Errorf("unregistered scheme has a fetcher: %v", f)
+	}
+	if names := Names(); !reflect.DeepEqual(names, []string{"example"}) {
+		t.Errorf("bad names: %v", names)
+	}
+
+	for _, name := range []string{"example", "https"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %q didn't panic", name)
+				}
+			}()
+			Register(testFetcher(name))
+		}()
+	}
+}
@@ -277,6 +277,7 @@ func outer(t *testing.T, test types.Test, negativeTests bool) error {
 	// Ignition
 	appendEnv := test.Env
 	appendEnv = append(appendEnv, "IGNITION_SYSTEM_CONFIG_DIR="+systemConfigDir)
+	appendEnv = append(appendEnv, "IGNITION_ROLLBACK_DIR="+filepath.Join(tmpDirectory, "rollback"))
 
 	if !negativeTests {
 		if err := runIgnition(t, ctx, "disks", "", tmpDirectory, appendEnv); err != nil {
@@ -0,0 +1,85 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/coreos/ignition/v2/tests/register"
+	"github.com/coreos/ignition/v2/tests/types"
+)
+
+func init() {
+	register.Register(register.NegativeTest, ShortReadRemoteContents())
+	register.Register(register.NegativeTest, NoSpaceForContents())
+}
+
+func ShortReadRemoteContents() types.Test {
+	name := "files.create.http.shortread"
+	in := types.GetBaseDisk()
+	out := in
+	env := []string{"IGNITION_FAULTS=short-read=1"}
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/foo/bar",
+	      "contents": {
+	        "source": "http://127.0.0.1:8080/contents"
+	      }
+	    }]
+	  }
+	}`
+	configMinVersion := "3.0.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Env:              env,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func NoSpaceForContents() types.Test {
+	name := "files.create.enospc"
+	in := types.GetBaseDisk()
+	out := in
+	env := []string{"IGNITION_FAULTS=enospc=2"}
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [
+	      {
+	        "path": "/foo/first",
+	        "contents": { "source": "data:,first" }
+	      },
+	      {
+	        "path": "/foo/second",
+	        "contents": { "source": "data:,second" }
+	      }
+	    ]
+	  }
+	}`
+	configMinVersion := "3.0.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Env:              env,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
@@ -24,6 +24,7 @@ func init() {
 	register.Register(register.NegativeTest, SymlinkResolutionCausesConflicts())
 	register.Register(register.NegativeTest, FailMatchHardLinkOnRoot())
 	register.Register(register.NegativeTest, FailMatchSymlinkOnRoot())
+	register.Register(register.NegativeTest, DanglingHardLink())
 }
 
 func WriteOverBrokenSymlink() types.Test {
@@ -177,3 +178,28 @@ func FailMatchSymlinkOnRoot() types.Test {
 		ConfigMinVersion: configMinVersion,
 	}
 }
+
+func DanglingHardLink() types.Test {
+	name := "links.hard.dangling"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "links": [{
+	      "path": "/link",
+	      "target": "/missing",
+	      "hard": true
+	    }]
+	  }
+	}`
+	configMinVersion := "3.0.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
@@ -22,6 +22,7 @@ import (
 func init() {
 	register.Register(register.NegativeTest, MissingRemoteContentsHTTP())
 	register.Register(register.NegativeTest, MissingRemoteContentsTFTP())
+	register.Register(register.NegativeTest, MissingRequiredRemoteContentsHTTP())
 }
 
 func MissingRemoteContentsHTTP() types.Test {
@@ -75,3 +76,30 @@ func MissingRemoteContentsTFTP() types.Test {
 		ConfigMinVersion: configMinVersion,
 	}
 }
+
+func MissingRequiredRemoteContentsHTTP() types.Test {
+	name := "files.create.http.notfound.required"
+	in := types.GetBaseDisk()
+	out := in
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/foo/bar",
+	      "contents": {
+	        "source": "http://127.0.0.1:8080/asdf"
+	      },
+	      "onFailure": "fail"
+	    }]
+	  }
+	}`
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"github.com/coreos/ignition/v2/tests/register"
+	"github.com/coreos/ignition/v2/tests/types"
+)
+
+func init() {
+	register.Register(register.NegativeTest, EfiSystemPartitionUndefined())
+}
+
+func EfiSystemPartitionUndefined() types.Test {
+	name := "filesystem.efi.undefined"
+	in := types.GetBaseDisk()
+	out := in
+	config := `{
+		"ignition": { "version": "$version" },
+		"storage": {
+			"efiSystemPartition": {
+				"path": "/boot/efi",
+				"bootEntries": [{
+					"label": "Fedora",
+					"loader": "/EFI/fedora/shimx64.efi"
+				}]
+			}
+		}
+	}`
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:              name,
+		In:                in,
+		Out:               out,
+		Config:            config,
+		ConfigShouldBeBad: true,
+		ConfigMinVersion:  configMinVersion,
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partitions
+
+import (
+	"github.com/coreos/ignition/v2/tests/register"
+	"github.com/coreos/ignition/v2/tests/types"
+)
+
+func init() {
+	register.Register(register.NegativeTest, AssertMismatchedPartition())
+	register.Register(register.NegativeTest, AssertMissingPartition())
+	register.Register(register.NegativeTest, AssertUndeclaredPartition())
+}
+
+func AssertMismatchedPartition() types.Test {
+	name := "partition.assert.mismatch"
+	in := types.GetBaseDisk()
+	out := in
+	config := `{
+		"ignition": {"version": "$version"},
+		"storage": {
+			"disks": [
+			{
+				"device": "$disk0",
+				"partitions": [
+				{
+					"number": 9,
+					"label": "NOT-ROOT",
+					"assert": true
+				}
+				]
+			}
+			]
+		}
+	}`
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func AssertMissingPartition() types.Test {
+	name := "partition.assert.missing"
+	in := types.GetBaseDisk()
+	out := in
+	config := `{
+		"ignition": {"version": "$version"},
+		"storage": {
+			"disks": [
+			{
+				"device": "$disk0",
+				"assert": true,
+				"partitions": [
+				{
+					"number": 10,
+					"label": "DATA"
+				}
+				]
+			}
+			]
+		}
+	}`
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func AssertUndeclaredPartition() types.Test {
+	name := "partition.assert.undeclared"
+	in := types.GetBaseDisk()
+	out := in
+	config := `{
+		"ignition": {"version": "$version"},
+		"storage": {
+			"disks": [
+			{
+				"device": "$disk0",
+				"assert": true,
+				"partitions": [
+				{
+					"number": 1,
+					"label": "EFI-SYSTEM"
+				},
+				{
+					"number": 9,
+					"label": "ROOT"
+				}
+				]
+			}
+			]
+		}
+	}`
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
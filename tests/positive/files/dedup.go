@@ -0,0 +1,73 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/coreos/ignition/v2/tests/register"
+	"github.com/coreos/ignition/v2/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, DeduplicateIdenticalFiles())
+}
+
+func DeduplicateIdenticalFiles() types.Test {
+	name := "files.create.deduplicate"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "deduplicateFiles": true,
+	    "files": [{
+	      "path": "/foo/bar",
+	      "contents": { "source": "data:,example%20file%0A" },
+	      "mode": 493
+	    },
+	    {
+	      "path": "/foo/baz",
+	      "contents": { "source": "data:,example%20file%0A" },
+	      "mode": 493
+	    }]
+	  }
+	}`
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "bar",
+				Directory: "foo",
+			},
+			Contents: "example file\n",
+			Mode:     0755,
+		},
+		{
+			Node: types.Node{
+				Name:      "baz",
+				Directory: "foo",
+			},
+			Contents: "example file\n",
+			Mode:     0755,
+		},
+	})
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
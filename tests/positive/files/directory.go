@@ -111,7 +111,7 @@ func DirCreationOverNonemptyDir() types.Test {
 	  "storage": {
 	    "directories": [{
 	      "path": "/foo/bar",
-	      "mode": 511
+	      "mode": 509
 	    }]
 	  }
 	}`
@@ -139,7 +139,7 @@ func DirCreationOverNonemptyDir() types.Test {
 				Directory: "foo",
 				Name:      "bar",
 			},
-			Mode: 0777 | int(os.ModeDir),
+			Mode: 0775 | int(os.ModeDir),
 		},
 	})
 	configMinVersion := "3.0.0"
@@ -204,7 +204,7 @@ func CheckOrdering() types.Test {
 	  "storage": {
 	    "directories": [{
 	      "path": "/foo/bar/baz",
-	      "mode": 511,
+	      "mode": 509,
 	      "overwrite": false
 	    },
 	    {
@@ -229,7 +229,7 @@ func CheckOrdering() types.Test {
 				Directory: "/",
 				Name:      "baz",
 			},
-			Mode: 0777 | int(os.ModeDir),
+			Mode: 0775 | int(os.ModeDir),
 		},
 		{
 			Node: types.Node{
@@ -0,0 +1,121 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/coreos/ignition/v2/tests/register"
+	"github.com/coreos/ignition/v2/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, EditExistingFile())
+	register.Register(register.PositiveTest, EditExistingFileWithBackup())
+}
+
+func EditExistingFile() types.Test {
+	name := "files.edit"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/etc/default/grub",
+	      "edits": [
+	        { "regex": "^GRUB_TIMEOUT=", "line": "GRUB_TIMEOUT=1" },
+	        { "regex": "^GRUB_TERMINAL=", "line": "GRUB_TERMINAL=console" },
+	        { "regex": "^GRUB_DISABLE_RECOVERY=", "absent": true }
+	      ]
+	    }]
+	  }
+	}`
+	in[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "grub",
+				Directory: "etc/default",
+			},
+			Contents: "GRUB_TIMEOUT=5\nGRUB_DISABLE_RECOVERY=true\n",
+		},
+	})
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "grub",
+				Directory: "etc/default",
+			},
+			Contents: "GRUB_TIMEOUT=1\nGRUB_TERMINAL=console\n",
+		},
+	})
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func EditExistingFileWithBackup() types.Test {
+	name := "files.edit.backup"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/etc/default/grub",
+	      "edits": [{ "regex": "^GRUB_TIMEOUT=", "line": "GRUB_TIMEOUT=1" }],
+	      "backup": true
+	    }]
+	  }
+	}`
+	in[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "grub",
+				Directory: "etc/default",
+			},
+			Contents: "GRUB_TIMEOUT=5\n",
+		},
+	})
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "grub",
+				Directory: "etc/default",
+			},
+			Contents: "GRUB_TIMEOUT=1\n",
+		},
+		{
+			Node: types.Node{
+				Name:      "grub.bak",
+				Directory: "etc/default",
+			},
+			Contents: "GRUB_TIMEOUT=5\n",
+		},
+	})
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
@@ -29,6 +29,7 @@ func init() {
 	register.Register(register.PositiveTest, WriteOverSymlink())
 	register.Register(register.PositiveTest, WriteOverBrokenSymlink())
 	register.Register(register.PositiveTest, CreateHardLinkToSymlink())
+	register.Register(register.PositiveTest, CreateHardLinkToDeeperTarget())
 }
 
 func CreateHardLinkOnRoot() types.Test {
@@ -519,3 +520,53 @@ func CreateHardLinkToSymlink() types.Test {
 		ConfigMinVersion: configMinVersion,
 	}
 }
+
+func CreateHardLinkToDeeperTarget() types.Test {
+	name := "links.hard.create.deepertarget"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/foo/bar/baz/target",
+	      "contents": {
+	        "source": "http://127.0.0.1:8080/contents"
+	      }
+	    }],
+	    "links": [{
+	      "path": "/link",
+	      "target": "/foo/bar/baz/target",
+	      "hard": true
+	    }]
+	  }
+	}`
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Directory: "foo/bar/baz",
+				Name:      "target",
+			},
+			Contents: "asdf\nfdsa",
+		},
+	})
+	out[0].Partitions.AddLinks("ROOT", []types.Link{
+		{
+			Node: types.Node{
+				Directory: "/",
+				Name:      "link",
+			},
+			Target: "/foo/bar/baz/target",
+			Hard:   true,
+		},
+	})
+	configMinVersion := "3.0.0"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
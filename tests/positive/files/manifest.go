@@ -0,0 +1,143 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/coreos/ignition/v2/tests/register"
+	"github.com/coreos/ignition/v2/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, RecordManagedPaths())
+	register.Register(register.PositiveTest, SkipPruneWithoutReconcile())
+}
+
+func RecordManagedPaths() types.Test {
+	name := "files.manifest"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "manifest": true,
+	    "files": [{
+	      "path": "/foo/bar",
+	      "contents": { "source": "data:,hello" }
+	    }]
+	  }
+	}`
+	manifest := types.File{
+		Node: types.Node{
+			Name:      "ignition-managed.paths",
+			Directory: "etc",
+		},
+	}
+	// /foo/old is dropped from the list but left in place
+	manifest.Contents = "# paths written by Ignition: kind, digest, path -- DO NOT EDIT\n" +
+		"file sha256-cba06b5736faf67e54b07b561eae94395e774c517a7d910a54369e1263ccfbd4 /foo/old\n"
+	in[0].Partitions.AddFiles("ROOT", []types.File{
+		manifest,
+		{
+			Node:     types.Node{Name: "old", Directory: "foo"},
+			Contents: "old",
+		},
+	})
+	manifest.Contents = "# paths written by Ignition: kind, digest, path -- DO NOT EDIT\n" +
+		"file sha256-2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824 /foo/bar\n"
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		manifest,
+		{
+			Node:     types.Node{Name: "bar", Directory: "foo"},
+			Contents: "hello",
+		},
+		{
+			Node:     types.Node{Name: "old", Directory: "foo"},
+			Contents: "old",
+		},
+	})
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func SkipPruneWithoutReconcile() types.Test {
+	name := "files.manifest.prune.noreconcile"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "manifest": true,
+	    "prune": true,
+	    "files": [{
+	      "path": "/foo/bar",
+	      "contents": { "source": "data:,hello" }
+	    }]
+	  }
+	}`
+	manifest := types.File{
+		Node: types.Node{
+			Name:      "ignition-managed.paths",
+			Directory: "etc",
+		},
+	}
+	// pruning only happens with --reconcile, so /foo/old is left in place
+	manifest.Contents = "# paths written by Ignition: kind, digest, path -- DO NOT EDIT\n" +
+		"file sha256-cba06b5736faf67e54b07b561eae94395e774c517a7d910a54369e1263ccfbd4 /foo/old\n" +
+		"file sha256-0682c5f2076f099c34cfdd15a9e063849ed437a49677e6fcc5b4198c76575be5 /foo/kept\n"
+	in[0].Partitions.AddFiles("ROOT", []types.File{
+		manifest,
+		{
+			Node:     types.Node{Name: "old", Directory: "foo"},
+			Contents: "old",
+		},
+		{
+			Node:     types.Node{Name: "kept", Directory: "foo"},
+			Contents: "changed",
+		},
+	})
+	manifest.Contents = "# paths written by Ignition: kind, digest, path -- DO NOT EDIT\n" +
+		"file sha256-2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824 /foo/bar\n"
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		manifest,
+		{
+			Node:     types.Node{Name: "bar", Directory: "foo"},
+			Contents: "hello",
+		},
+		{
+			Node:     types.Node{Name: "old", Directory: "foo"},
+			Contents: "old",
+		},
+		{
+			Node:     types.Node{Name: "kept", Directory: "foo"},
+			Contents: "changed",
+		},
+	})
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package files
+
+import (
+	"github.com/coreos/ignition/v2/tests/register"
+	"github.com/coreos/ignition/v2/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, MergeTOMLIntoExistingFile())
+	register.Register(register.PositiveTest, MergeJSONIntoNonexistentFile())
+}
+
+func MergeTOMLIntoExistingFile() types.Test {
+	name := "files.merge.toml"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/etc/containers/containers.conf",
+	      "contents": {
+	        "source": "data:,%5Bengine%5D%0Acgroup_manager%20%3D%20%22systemd%22%0A",
+	        "merge": "toml"
+	      }
+	    }]
+	  }
+	}`
+	in[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "containers.conf",
+				Directory: "etc/containers",
+			},
+			Contents: "[engine]\n# the cgroup manager\ncgroup_manager = \"cgroupfs\"\n\n[network]\nnetwork_backend = \"cni\"\n",
+		},
+	})
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "containers.conf",
+				Directory: "etc/containers",
+			},
+			Contents: "[engine]\n# the cgroup manager\ncgroup_manager = \"systemd\"\n\n[network]\nnetwork_backend = \"cni\"\n",
+		},
+	})
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func MergeJSONIntoNonexistentFile() types.Test {
+	name := "files.merge.json.nonexistent"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+	  "ignition": { "version": "$version" },
+	  "storage": {
+	    "files": [{
+	      "path": "/etc/foo.json",
+	      "contents": {
+	        "source": "data:,%7B%22a%22%3A%201%7D%0A",
+	        "merge": "json"
+	      },
+	      "mode": 420
+	    }]
+	  }
+	}`
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "foo.json",
+				Directory: "etc",
+			},
+			Contents: "{\"a\": 1}\n",
+		},
+	})
+	configMinVersion := "3.1.0-experimental"
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
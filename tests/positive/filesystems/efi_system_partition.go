@@ -0,0 +1,74 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystems
+
+import (
+	"github.com/coreos/ignition/v2/tests/register"
+	"github.com/coreos/ignition/v2/tests/types"
+)
+
+func init() {
+	register.Register(register.PositiveTest, PopulateEfiSystemPartition())
+}
+
+func PopulateEfiSystemPartition() types.Test {
+	name := "filesystem.efi.archive"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	mntDevices := []types.MntDevice{
+		{
+			Label:        "EFI-SYSTEM",
+			Substitution: "$DEVICE",
+		},
+	}
+	// a gzipped tar containing EFI/BOOT/BOOTX64.EFI
+	config := `{
+		"ignition": { "version": "$version" },
+		"storage": {
+			"filesystems": [{
+				"device": "$DEVICE",
+				"format": "vfat",
+				"path": "/boot/efi"
+			}],
+			"efiSystemPartition": {
+				"path": "/boot/efi",
+				"archive": {
+					"compression": "gzip",
+					"source": "data:;base64,H4sIAAAAAAACA+3RsQqDMBRA0fcpfkFN9CXuBQudXDp0dbNDEdT+f18dU7BTpMI9QxKSQAi3vVxLycyZJoR1Nun8vfZeKydFkB285qWf7PlpHJete7/O088dRGv9z113K/+qf+VjTf9d+3+Ge9STbeToH1U3+mvSvw7aSOHon908PJ4CAAAAAAAAAAAAAACAw3oDBtieJgAoAAA="
+				}
+			}
+		}
+	}`
+	configMinVersion := "3.1.0-experimental"
+
+	out[0].Partitions.AddFiles("EFI-SYSTEM", []types.File{
+		{
+			Node: types.Node{
+				Name:      "BOOTX64.EFI",
+				Directory: "EFI/BOOT",
+			},
+			Contents: "shim",
+		},
+	})
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		MntDevices:       mntDevices,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
@@ -31,6 +31,8 @@ func init() {
 	register.Register(register.PositiveTest, AppendConfigWithRemoteConfigTFTP())
 	register.Register(register.PositiveTest, ReplaceConfigWithRemoteConfigData())
 	register.Register(register.PositiveTest, AppendConfigWithRemoteConfigData())
+	register.Register(register.PositiveTest, AppendConfigReplacingList())
+	register.Register(register.PositiveTest, AppendConfigWithConditions())
 	register.Register(register.PositiveTest, VersionOnlyConfig())
 	register.Register(register.PositiveTest, EmptyUserdata())
 }
@@ -316,6 +318,97 @@ func AppendConfigWithRemoteConfigData() types.Test {
 	}
 }
 
+func AppendConfigReplacingList() types.Test {
+	name := "config.merge.listpolicies"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+          "ignition": {
+            "version": "$version",
+            "config": {
+              "merge": [{
+                "source": "data:,%7B%22ignition%22%3A%7B%22version%22%3A%223.1.0-experimental%22%7D%2C%22storage%22%3A%7B%22files%22%3A%5B%7B%22path%22%3A%22%2Ffoo%2Foverlay%22%2C%22contents%22%3A%7B%22source%22%3A%22data%3A%2Coverlay%22%7D%7D%5D%7D%7D",
+                "listPolicies": [{"list": "storage.files", "policy": "replace"}]
+              }]
+            }
+          },
+          "storage": {
+            "files": [{
+              "path": "/foo/base",
+              "contents": {"source": "data:,base"}
+            }]
+          }
+        }`
+	configMinVersion := "3.1.0-experimental"
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "overlay",
+				Directory: "foo",
+			},
+			Contents: "overlay",
+		},
+	})
+	out[0].Partitions.AddRemovedNodes("ROOT", []types.Node{
+		{
+			Name:      "base",
+			Directory: "foo",
+		},
+	})
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
+func AppendConfigWithConditions() types.Test {
+	name := "config.merge.conditions"
+	in := types.GetBaseDisk()
+	out := types.GetBaseDisk()
+	config := `{
+          "ignition": {
+            "version": "$version",
+            "config": {
+              "merge": [{
+                "source": "data:,%7B%22ignition%22%3A%7B%22version%22%3A%223.1.0-experimental%22%7D%2C%22storage%22%3A%7B%22files%22%3A%5B%7B%22path%22%3A%22%2Ffoo%2Fincluded%22%2C%22contents%22%3A%7B%22source%22%3A%22data%3A%2Cincluded%22%7D%7D%5D%7D%7D",
+                "if": {"platform": "file"}
+              }, {
+                "source": "data:,%7B%22ignition%22%3A%7B%22version%22%3A%223.1.0-experimental%22%7D%2C%22storage%22%3A%7B%22files%22%3A%5B%7B%22path%22%3A%22%2Ffoo%2Fskipped%22%2C%22contents%22%3A%7B%22source%22%3A%22data%3A%2Cskipped%22%7D%7D%5D%7D%7D",
+                "if": {"platform": "aws"}
+              }]
+            }
+          }
+        }`
+	configMinVersion := "3.1.0-experimental"
+	out[0].Partitions.AddFiles("ROOT", []types.File{
+		{
+			Node: types.Node{
+				Name:      "included",
+				Directory: "foo",
+			},
+			Contents: "included",
+		},
+	})
+	out[0].Partitions.AddRemovedNodes("ROOT", []types.Node{
+		{
+			Name:      "skipped",
+			Directory: "foo",
+		},
+	})
+
+	return types.Test{
+		Name:             name,
+		In:               in,
+		Out:              out,
+		Config:           config,
+		ConfigMinVersion: configMinVersion,
+	}
+}
+
 func VersionOnlyConfig() types.Test {
 	name := "general.versiononly"
 	in := types.GetBaseDisk()
@@ -0,0 +1,153 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fetchTimeout bounds fetching a config by URL, including reading its body.
+const fetchTimeout = 30 * time.Second
+
+// isURL returns whether the input name is a URL to fetch the config from.
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// expandInputs returns the configs named by args, replacing each directory
+// by the configs found in its tree, in lexical order. "-" names stdin.
+func expandInputs(args []string, yaml bool) ([]string, error) {
+	var names []string
+	for _, arg := range args {
+		if arg == "-" || isURL(arg) {
+			names = append(names, arg)
+			continue
+		}
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			names = append(names, arg)
+			continue
+		}
+		found, err := findConfigs(arg, yaml)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no configs found in %s", arg)
+		}
+		names = append(names, found...)
+	}
+	return names, nil
+}
+
+// findConfigs returns the files under dir which look like configs: those
+// ending in .ign or .json, and, if yaml is set, .yaml or .yml. Hidden
+// directories, such as .git, are skipped.
+func findConfigs(dir string, yaml bool) ([]string, error) {
+	var found []string
+	err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if name != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(name) {
+		case ".ign", ".json":
+			found = append(found, name)
+		case ".yaml", ".yml":
+			if yaml {
+				found = append(found, name)
+			}
+		}
+		return nil
+	})
+	return found, err
+}
+
+// readInput returns the contents of the named config: stdin for "-", the
+// response body for a URL, and otherwise the file.
+func readInput(name string) ([]byte, error) {
+	switch {
+	case name == "-":
+		return ioutil.ReadAll(os.Stdin)
+	case isURL(name):
+		return fetch(name)
+	default:
+		return ioutil.ReadFile(name)
+	}
+}
+
+func fetch(url string) ([]byte, error) {
+	tlsConfig, err := clientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// clientTLSConfig returns the TLS configuration for fetching configs: the
+// system's CAs and those in --ca-file, unless --insecure disables
+// verification.
+func clientTLSConfig() (*tls.Config, error) {
+	if flagInsecure {
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+	if flagCAFile == "" {
+		return nil, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		// not available on all platforms
+		pool = x509.NewCertPool()
+	}
+	pem, err := ioutil.ReadFile(flagCAFile)
+	if err != nil {
+		return nil, err
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", flagCAFile)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
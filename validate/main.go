@@ -15,32 +15,75 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"strings"
 
+	ign "github.com/coreos/ignition/v2/config"
+	"github.com/coreos/ignition/v2/config/diff"
+	"github.com/coreos/ignition/v2/config/lint"
+	"github.com/coreos/ignition/v2/config/shared/errors"
+	"github.com/coreos/ignition/v2/config/util"
 	config "github.com/coreos/ignition/v2/config/v3_0"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
 	"github.com/coreos/ignition/v2/internal/version"
+
+	"github.com/coreos/go-semver/semver"
+	vjson "github.com/coreos/vcontext/json"
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
 var (
-	flagVersion bool
+	flagVersion    bool
+	flagYAML       bool
+	flagFormat     string
+	flagLint       bool
+	flagLintIgnore string
+	flagPlatform   string
+	flagCAFile     string
+	flagInsecure   bool
 )
 
 func init() {
 	flag.BoolVar(&flagVersion, "version", false, "print the version of ignition-validate")
+	flag.BoolVar(&flagYAML, "yaml", false, "accept a config written in YAML")
+	flag.StringVar(&flagFormat, "format", "text", "report format, text or json")
+	flag.BoolVar(&flagLint, "lint", false, "also warn about common mistakes in the config")
+	flag.StringVar(&flagLintIgnore, "lint-ignore", "", "comma-separated lint rules to skip")
+	flag.StringVar(&flagPlatform, "platform", "", "platform the config will be provided on, to check its size limits")
+	flag.StringVar(&flagCAFile, "ca-file", "", "PEM file of additional CAs to trust when fetching configs by URL")
+	flag.BoolVar(&flagInsecure, "insecure", false, "don't verify TLS certificates when fetching configs by URL")
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %s config.ign [flags]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %s [flags] config.ign|directory|url|- ...\n  %s translate --to version [--yaml] config.ign\n  %s diff [--yaml] old.ign new.ign\n  %s render [flags] config.ign\n  %s schema version\n\n", os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 		flag.PrintDefaults()
 	}
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "translate" {
+		translateMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diffMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		renderMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		schemaMain(os.Args[2:])
+		return
+	}
+
 	flag.Parse()
 
-	runIgnValidate(os.Args[1:])
+	runIgnValidate(flag.Args())
 }
 
 func stdout(format string, a ...interface{}) {
@@ -56,34 +99,284 @@ func die(format string, a ...interface{}) {
 	os.Exit(1)
 }
 
+// result is the outcome of validating one config. err is set if the config
+// couldn't be read or parsed, or is invalid.
+type result struct {
+	name string
+	rpt  report.Report
+	err  error
+}
+
 func runIgnValidate(args []string) {
 	if flagVersion {
 		stdout(version.String)
 		return
 	}
 
-	if len(args) != 1 {
+	if len(args) == 0 || (flagFormat != "text" && flagFormat != "json") {
 		flag.Usage()
 		os.Exit(1)
 	}
-	var blob []byte
-	var err error
-	if args[0] == "-" {
-		blob, err = ioutil.ReadAll(os.Stdin)
+	names, err := expandInputs(args, flagYAML)
+	if err != nil {
+		die("couldn't find configs: %v", err)
+	}
+	var results []result
+	valid := true
+	for _, name := range names {
+		res := result{name: name}
+		if raw, err := readInput(name); err != nil {
+			res.err = fmt.Errorf("couldn't read config: %v", err)
+		} else {
+			res.rpt, res.err = validateConfig(raw)
+		}
+		if res.err != nil || res.rpt.IsFatal() {
+			valid = false
+		}
+		results = append(results, res)
+	}
+
+	if flagFormat == "json" {
+		printJSONReport(results, valid)
 	} else {
-		blob, err = ioutil.ReadFile(args[0])
+		printTextReport(results)
+	}
+	if !valid {
+		os.Exit(1)
+	}
+}
+
+// validateConfig parses and validates raw, returning the report and, if the
+// config is invalid or couldn't be parsed, an error.
+func validateConfig(raw []byte) (report.Report, error) {
+	blob := raw
+	limits := ign.PlatformLimits(flagPlatform)
+	var rpt report.Report
+	err := limits.CheckSize(raw)
+	rpt.AddOnError(path.ContextPath{}, err)
+	if err == nil && flagYAML {
+		blob, rpt, err = util.YAMLToJSON(raw)
+	}
+	if err == nil {
+		var r report.Report
+		_, r, err = config.Parse(blob)
+		if flagYAML {
+			r = util.StripMarkers(r)
+		}
+		rpt.Merge(r)
+	}
+	if err == nil {
+		var r report.Report
+		r, err = checkConfig(raw, blob, limits)
+		rpt.Merge(r)
+	}
+	if err == nil && rpt.IsFatal() {
+		err = errors.ErrInvalid
 	}
+	if err != nil && !rpt.IsFatal() {
+		err = fmt.Errorf("couldn't parse config: %v", err)
+	}
+	return rpt, err
+}
+
+// checkConfig checks the parsed config blob against limits and, with
+// --lint, runs the lint rules not disabled by --lint-ignore or by
+// suppression comments in raw.
+func checkConfig(raw, blob []byte, limits ign.Limits) (report.Report, error) {
+	cfg, _, err := ign.Parse(blob)
 	if err != nil {
-		die("couldn't read config: %v", err)
+		return report.Report{}, err
+	}
+	r := limits.Check(cfg)
+	if flagLint {
+		ignore := lint.Suppressions(raw)
+		for _, name := range strings.Split(flagLintIgnore, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				ignore[name] = true
+			}
+		}
+		r.Merge(lint.Lint(cfg, ignore))
+	}
+	if !flagYAML {
+		if cxt, err := vjson.UnmarshalToContext(blob); err == nil {
+			r.Correlate(cxt)
+		}
+	}
+	return r, nil
+}
+
+// printTextReport writes the entries of each result to stdout, and errors
+// not described by them to stderr. When there are several results, each
+// line is prefixed with the name of its config.
+func printTextReport(results []result) {
+	for _, res := range results {
+		prefix := ""
+		if len(results) > 1 {
+			prefix = res.name + ": "
+		}
+		if len(res.rpt.Entries) > 0 {
+			for _, line := range strings.Split(strings.TrimSpace(res.rpt.String()), "\n") {
+				stdout("%s%s", prefix, line)
+			}
+		}
+		if res.err != nil && !res.rpt.IsFatal() {
+			stderr("%s%v", prefix, res.err)
+		}
+	}
+}
+
+// printJSONReport writes the results to stdout in a form CI systems can
+// consume: the entries of all configs, each naming its config. Errors not
+// described by a config's report are included as errors at its root.
+func printJSONReport(results []result, valid bool) {
+	type entry struct {
+		Config string `json:"config"`
+		util.ReportEntry
+	}
+	entries := []entry{}
+	for _, res := range results {
+		rpt := res.rpt
+		if res.err != nil && !rpt.IsFatal() {
+			rpt.AddOnError(path.ContextPath{}, res.err)
+		}
+		for _, e := range util.ReportEntries(rpt) {
+			entries = append(entries, entry{res.name, e})
+		}
+	}
+	out, jsonErr := json.MarshalIndent(struct {
+		Valid   bool    `json:"valid"`
+		Entries []entry `json:"entries"`
+	}{
+		Valid:   valid,
+		Entries: entries,
+	}, "", "  ")
+	if jsonErr != nil {
+		die("couldn't format report: %v", jsonErr)
+	}
+	stdout(string(out))
+}
+
+// translateMain converts a config of any supported version to the version
+// given by --to and writes it to stdout. Anything which can't be translated
+// is reported on stderr.
+func translateMain(args []string) {
+	var to string
+	var yaml bool
+	fs := flag.NewFlagSet("translate", flag.ExitOnError)
+	fs.StringVar(&to, "to", "", "spec version to translate the config to")
+	fs.BoolVar(&yaml, "yaml", false, "accept a config written in YAML")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  %s translate --to version [--yaml] config.ign\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if to == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	v, err := semver.NewVersion(to)
+	if err != nil {
+		die("invalid version %q: %v", to, err)
+	}
+
+	blob := readConfig(fs.Arg(0))
+	if yaml {
+		blob = yamlToJSON(blob)
+	}
+	out, rpt, err := ign.Translate(blob, *v)
+	if yaml {
+		rpt = util.StripMarkers(rpt)
 	}
-	_, rpt, err := config.Parse(blob)
 	if len(rpt.Entries) > 0 {
-		stdout(rpt.String())
+		stderr(rpt.String())
 	}
-	if rpt.IsFatal() {
+	if err != nil {
+		die("couldn't translate config: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, out, "", "  "); err != nil {
+		die("couldn't format config: %v", err)
+	}
+	stdout(buf.String())
+}
+
+// diffMain writes the semantic differences between two configs to stdout,
+// exiting with status 1 if there are any, as diff(1) does.
+func diffMain(args []string) {
+	var yaml bool
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.BoolVar(&yaml, "yaml", false, "accept configs written in YAML")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  %s diff [--yaml] old.ign new.ign\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
 		os.Exit(1)
 	}
+	var cfgs [2]types.Config
+	for i, name := range fs.Args() {
+		blob := readConfig(name)
+		if yaml {
+			blob = yamlToJSON(blob)
+		}
+		cfg, rpt, err := ign.Parse(blob)
+		if err != nil {
+			if len(rpt.Entries) > 0 {
+				stderr("%s: %s", name, rpt.String())
+			}
+			die("couldn't parse %s: %v", name, err)
+		}
+		cfgs[i] = cfg
+	}
+	changes := diff.Diff(cfgs[0], cfgs[1])
+	for _, c := range changes {
+		stdout("%s", c)
+	}
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+// schemaMain writes the JSON Schema of the given spec version to stdout.
+func schemaMain(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage:\n  %s schema version\n", os.Args[0])
+		os.Exit(1)
+	}
+	v, err := semver.NewVersion(args[0])
+	if err != nil {
+		die("invalid version %q: %v", args[0], err)
+	}
+	schema, err := ign.Schema(*v)
+	if err != nil {
+		die("couldn't get schema: %v", err)
+	}
+	stdout(string(schema))
+}
+
+// readConfig returns the contents of the named config, exiting if it can't
+// be read.
+func readConfig(name string) []byte {
+	blob, err := readInput(name)
+	if err != nil {
+		die("couldn't read config: %v", err)
+	}
+	return blob
+}
+
+// yamlToJSON converts a config written in YAML to JSON, exiting if the YAML
+// is malformed.
+func yamlToJSON(blob []byte) []byte {
+	out, rpt, err := util.YAMLToJSON(blob)
+	if len(rpt.Entries) > 0 {
+		stderr(rpt.String())
+	}
 	if err != nil {
-		die("couldn't parse config: %v", err)
+		die("couldn't parse YAML: %v", err)
 	}
+	return out
 }
@@ -0,0 +1,237 @@
+// Copyright 2020 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ign "github.com/coreos/ignition/v2/config"
+	"github.com/coreos/ignition/v2/config/compress"
+	"github.com/coreos/ignition/v2/config/util"
+	latest "github.com/coreos/ignition/v2/config/v3_1_experimental"
+	"github.com/coreos/ignition/v2/config/v3_1_experimental/types"
+	"github.com/coreos/ignition/v2/internal/signature"
+	iutil "github.com/coreos/ignition/v2/internal/util"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+// maxRenderDepth bounds the nesting of referenced configs, so that a cycle
+// of references is reported rather than followed forever.
+const maxRenderDepth = 16
+
+// renderer expands the configs referenced by a config as Ignition does on
+// a node, describing the node with the flags of the render command.
+type renderer struct {
+	// dir, if set, holds the referenced configs at the paths of their URLs
+	dir          string
+	yaml         bool
+	platform     string
+	smbiosVendor string
+	disks        map[string]bool
+}
+
+// renderMain writes the config which Ignition would run on a node, with
+// the configs it references merged into it or replacing it, to stdout.
+func renderMain(args []string) {
+	var r renderer
+	var disks string
+	var compression compress.Options
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	fs.StringVar(&r.dir, "dir", "", "directory to read referenced configs from, at the paths of their URLs, instead of fetching them")
+	fs.BoolVar(&r.yaml, "yaml", false, "accept configs written in YAML")
+	fs.StringVar(&r.platform, "platform", "", "platform of the node, for platform conditions")
+	fs.StringVar(&r.smbiosVendor, "smbios-vendor", "", "SMBIOS vendor of the node, for smbiosVendor conditions")
+	fs.StringVar(&disks, "disks", "", "comma-separated disks present on the node, for diskPresent conditions")
+	fs.StringVar(&flagCAFile, "ca-file", "", "PEM file of additional CAs to trust when fetching configs")
+	fs.BoolVar(&flagInsecure, "insecure", false, "don't verify TLS certificates when fetching configs")
+	fs.StringVar(&compression.Method, "compress", "", "compress large inline file contents with gzip or zstd")
+	fs.IntVar(&compression.Threshold, "compress-threshold", compress.DefaultThreshold, "size in bytes below which --compress leaves inline contents alone")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage:\n  %s render [flags] config.ign\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if disks != "" {
+		r.disks = map[string]bool{}
+		for _, disk := range strings.Split(disks, ",") {
+			r.disks[strings.TrimSpace(disk)] = true
+		}
+	}
+
+	cfg, err := r.parse(readConfig(fs.Arg(0)))
+	if err != nil {
+		die("couldn't parse config: %v", err)
+	}
+	rendered, err := r.render(cfg, 0)
+	if err != nil {
+		die("couldn't render config: %v", err)
+	}
+	// the references have been expanded
+	rendered.Ignition.Config = types.IgnitionConfig{}
+	if compression.Method != "" {
+		if rendered, err = compress.Config(rendered, compression); err != nil {
+			die("couldn't compress config: %v", err)
+		}
+	}
+	out, err := json.MarshalIndent(rendered, "", "  ")
+	if err != nil {
+		die("couldn't format config: %v", err)
+	}
+	stdout(string(out))
+}
+
+func (r renderer) parse(raw []byte) (types.Config, error) {
+	parse := ign.Parse
+	if r.yaml {
+		parse = ign.ParseYAML
+	}
+	cfg, rpt, err := parse(raw)
+	if err != nil {
+		if len(rpt.Entries) > 0 {
+			stderr(rpt.String())
+		}
+		return types.Config{}, err
+	}
+	return cfg, nil
+}
+
+// render expands the references of cfg, which is nested depth references
+// deep, in the same order as Ignition: a replacement config replaces cfg
+// before anything is merged, and merged configs are rendered before they're
+// merged in turn.
+func (r renderer) render(cfg types.Config, depth int) (types.Config, error) {
+	if depth > maxRenderDepth {
+		return types.Config{}, fmt.Errorf("configs are nested more than %d deep", maxRenderDepth)
+	}
+	if ref := cfg.Ignition.Config.Replace; ref.Source != nil {
+		holds, err := r.conditionHolds(ref.If)
+		if err != nil {
+			return types.Config{}, err
+		}
+		if holds {
+			replacement, err := r.fetch(ref)
+			if err != nil {
+				return types.Config{}, err
+			}
+			return r.render(replacement, depth+1)
+		}
+	}
+
+	rendered := cfg
+	for _, ref := range cfg.Ignition.Config.Merge {
+		holds, err := r.conditionHolds(ref.If)
+		if err != nil {
+			return types.Config{}, err
+		}
+		if !holds {
+			continue
+		}
+		child, err := r.fetch(ref)
+		if err != nil {
+			return types.Config{}, err
+		}
+		if child, err = r.render(child, depth+1); err != nil {
+			return types.Config{}, err
+		}
+		rendered = latest.MergeWithPolicies(rendered, child, ref.ListPolicies)
+	}
+	return rendered, nil
+}
+
+// conditionHolds reports whether cond holds on the node described by the
+// flags, failing if it depends on something they don't describe.
+func (r renderer) conditionHolds(cond types.ConfigCondition) (bool, error) {
+	if cond.Platform != nil {
+		if r.platform == "" {
+			return false, fmt.Errorf("a config is conditional on the platform; set --platform")
+		}
+		if *cond.Platform != r.platform {
+			return false, nil
+		}
+	}
+	if cond.SmbiosVendor != nil {
+		if r.smbiosVendor == "" {
+			return false, fmt.Errorf("a config is conditional on the SMBIOS vendor; set --smbios-vendor")
+		}
+		if *cond.SmbiosVendor != r.smbiosVendor {
+			return false, nil
+		}
+	}
+	if cond.DiskPresent != nil {
+		if r.disks == nil {
+			return false, fmt.Errorf("a config is conditional on the presence of a disk; set --disks")
+		}
+		if !r.disks[*cond.DiskPresent] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fetch reads and parses the config referenced by ref, checking its hash.
+// Signatures and checksums manifests aren't checked, but signed configs are
+// unwrapped.
+func (r renderer) fetch(ref types.ConfigReference) (types.Config, error) {
+	u, err := url.Parse(*ref.Source)
+	if err != nil {
+		return types.Config{}, err
+	}
+	// data URLs may hold secrets
+	name := *ref.Source
+	if u.Scheme == "data" {
+		name = "data URL"
+	}
+	var raw []byte
+	switch {
+	case u.Scheme == "data":
+		if _, zstd := util.SplitZstdDataURL(*ref.Source); zstd {
+			return types.Config{}, fmt.Errorf("zstd data URLs aren't supported")
+		}
+		var data *dataurl.DataURL
+		if data, err = dataurl.DecodeString(*ref.Source); err == nil {
+			raw = data.Data
+		}
+	case r.dir != "":
+		raw, err = ioutil.ReadFile(filepath.Join(r.dir, filepath.FromSlash(u.Path)))
+	case u.Scheme == "http" || u.Scheme == "https":
+		raw, err = fetch(*ref.Source)
+	default:
+		return types.Config{}, fmt.Errorf("can't fetch %s; set --dir to read it from a directory", *ref.Source)
+	}
+	if err != nil {
+		return types.Config{}, fmt.Errorf("reading %s: %v", name, err)
+	}
+	if err := iutil.AssertValid(ref.Verification, raw); err != nil {
+		return types.Config{}, fmt.Errorf("verifying %s: %v", name, err)
+	}
+	cfg, err := r.parse(signature.Unwrap(raw))
+	if err != nil {
+		return types.Config{}, fmt.Errorf("parsing %s: %v", name, err)
+	}
+	return cfg, nil
+}